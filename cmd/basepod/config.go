@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// configBundle is the portable server configuration written by `basepod config export`
+type configBundle struct {
+	Version       string               `yaml:"version"`
+	ExportedAt    time.Time            `yaml:"exported_at"`
+	Config        *config.Config       `yaml:"config"`
+	Notifications []bundleNotification `yaml:"notifications,omitempty"`
+}
+
+// bundleNotification is a global notification target in a config bundle
+type bundleNotification struct {
+	ID              string   `yaml:"id"`
+	Name            string   `yaml:"name"`
+	Type            string   `yaml:"type"`
+	WebhookURL      string   `yaml:"webhook_url,omitempty"`
+	SlackWebhookURL string   `yaml:"slack_webhook_url,omitempty"`
+	DiscordWebhook  string   `yaml:"discord_webhook_url,omitempty"`
+	Events          []string `yaml:"events"`
+}
+
+// runConfig handles `basepod config export|import`
+func runConfig(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basepod config <export|import> [file]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "export":
+		output := ""
+		if len(args) > 1 {
+			output = args[1]
+		}
+		runConfigExport(output)
+	case "import":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: basepod config import <file> [--dry-run]")
+			os.Exit(1)
+		}
+		dryRun := len(args) > 2 && args[2] == "--dry-run"
+		runConfigImport(args[1], dryRun)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: basepod config <export|import> [file]")
+		os.Exit(1)
+	}
+}
+
+// runConfigExport writes a sanitized config bundle to output (stdout if empty)
+func runConfigExport(output string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	bundle := configBundle{
+		Version:    version,
+		ExportedAt: time.Now().UTC(),
+		Config:     cfg.Sanitized(),
+	}
+
+	// App-scoped targets reference app IDs that won't exist on the new server
+	if store, err := storage.New(); err == nil {
+		configs, _ := store.ListNotificationConfigs("", "")
+		for _, n := range configs {
+			if n.Scope != "global" {
+				continue
+			}
+			bundle.Notifications = append(bundle.Notifications, bundleNotification{
+				ID:              n.ID,
+				Name:            n.Name,
+				Type:            n.Type,
+				WebhookURL:      n.WebhookURL,
+				SlackWebhookURL: n.SlackWebhookURL,
+				DiscordWebhook:  n.DiscordWebhook,
+				Events:          n.Events,
+			})
+		}
+		store.Close()
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: skipping notification targets: %v\n", err)
+	}
+
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "" || output == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(output, data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", output, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Exported config to %s (secrets excluded, notification webhook URLs included)\n", output)
+}
+
+// runConfigImport applies a config bundle to this server, keeping local secrets
+func runConfigImport(path string, dryRun bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	var bundle configBundle
+	if err := yaml.Unmarshal(data, &bundle); err != nil || bundle.Config == nil {
+		fmt.Fprintf(os.Stderr, "Invalid config bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.MergeImported(bundle.Config)
+
+	fmt.Printf("Importing config exported from basepod %s at %s\n", bundle.Version, bundle.ExportedAt.Format(time.RFC3339))
+	fmt.Printf("  Domain: root=%q base=%q suffix=%q\n", cfg.Domain.Root, cfg.Domain.Base, cfg.Domain.Suffix)
+	fmt.Printf("  DNS: enabled=%t upstream=%v\n", cfg.DNS.Enabled, cfg.DNS.Upstream)
+	fmt.Printf("  Notification targets: %d\n", len(bundle.Notifications))
+	if dryRun {
+		fmt.Println("Dry run, nothing written.")
+		return
+	}
+
+	if err := cfg.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(bundle.Notifications) > 0 {
+		if err := config.EnsureDirectories(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create directories: %v\n", err)
+			os.Exit(1)
+		}
+		store, err := storage.New()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open storage: %v\n", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+
+		for _, n := range bundle.Notifications {
+			if existing, _ := store.GetNotificationConfig(n.ID); existing != nil {
+				continue
+			}
+			now := time.Now()
+			store.CreateNotificationConfig(&app.NotificationConfig{
+				ID:              n.ID,
+				Name:            n.Name,
+				Type:            n.Type,
+				Enabled:         true,
+				Scope:           "global",
+				WebhookURL:      n.WebhookURL,
+				SlackWebhookURL: n.SlackWebhookURL,
+				DiscordWebhook:  n.DiscordWebhook,
+				Events:          n.Events,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+			})
+		}
+	}
+
+	fmt.Println("Config imported. Run `basepod restart` to apply it; on a fresh server set the admin password in the dashboard.")
+}
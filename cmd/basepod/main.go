@@ -3,21 +3,26 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/base-go/basepod/internal/api"
+	"github.com/base-go/basepod/internal/app"
 	"github.com/base-go/basepod/internal/caddy"
 	"github.com/base-go/basepod/internal/config"
 	"github.com/base-go/basepod/internal/dns"
@@ -56,6 +61,9 @@ func main() {
 		case "update":
 			runUpdate()
 			return
+		case "config":
+			runConfig(os.Args[2:])
+			return
 		case "version":
 			fmt.Printf("basepod version %s\n", version)
 			return
@@ -69,8 +77,10 @@ func main() {
 	var (
 		showVersion = flag.Bool("version", false, "Show version")
 		port        = flag.Int("port", 3000, "API server port")
-		host        = flag.String("host", "0.0.0.0", "API server host")
+		host        = flag.String("host", "", "API server host (default: server.host from the config)")
 		setup       = flag.Bool("setup", false, "Run initial setup")
+		dev         = flag.Bool("dev", false, "Development mode: keep data in ~/.basepod-dev unless BASEPOD_HOME is set")
+		fakeRuntime = flag.Bool("fake-runtime", false, "With --dev, run apps on in-memory fakes of Podman and Caddy")
 	)
 	flag.Parse()
 
@@ -79,6 +89,17 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *fakeRuntime && !*dev {
+		log.Fatalf("--fake-runtime only works with --dev")
+	}
+	if *dev && os.Getenv("BASEPOD_HOME") == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatalf("Failed to find home directory: %v", err)
+		}
+		os.Setenv("BASEPOD_HOME", filepath.Join(home, ".basepod-dev"))
+	}
+
 	// Ensure directories exist
 	if err := config.EnsureDirectories(); err != nil {
 		log.Fatalf("Failed to create directories: %v", err)
@@ -118,67 +139,86 @@ func main() {
 	tagSyncer.Start()
 	defer tagSyncer.Stop()
 
-	// Initialize Podman client (auto-start if needed)
-	log.Printf("Connecting to Podman...")
-	if err := ensurePodmanRunning(); err != nil {
-		log.Printf("Warning: Failed to ensure Podman is running: %v", err)
-	}
-
-	pm, err := podman.NewClient()
-	if err != nil {
-		log.Printf("Warning: Failed to connect to Podman: %v", err)
-		log.Printf("Please start Podman manually: podman machine start")
+	// Initialize Podman client (auto-start if needed), or the fake runtime
+	var pm podman.Client
+	if *fakeRuntime {
+		fake := podman.NewFake()
+		fake.CreateNetwork(context.Background(), "basepod")
+		pm = fake
+		log.Printf("Using the in-memory fake container runtime; builds from source are not supported")
 	} else {
-		// Ensure CLI subprocesses (podman build, etc.) use the same socket as the API client
-		if socketPath := pm.GetSocketPath(); socketPath != "" {
-			os.Setenv("CONTAINER_HOST", "unix://"+socketPath)
+		log.Printf("Connecting to Podman...")
+		if err := ensurePodmanRunning(); err != nil {
+			log.Printf("Warning: Failed to ensure Podman is running: %v", err)
 		}
 
-		// Verify connection with ping
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		if pingErr := pm.Ping(ctx); pingErr != nil {
-			log.Printf("Warning: Podman ping failed: %v", pingErr)
+		pm, err = podman.NewClient()
+		if err != nil {
+			log.Printf("Warning: Failed to connect to Podman: %v", err)
+			log.Printf("Please start Podman manually: podman machine start")
 		} else {
-			log.Printf("Podman connected successfully")
-		}
-		cancel()
+			// Ensure CLI subprocesses (podman build, etc.) use the same socket as the API client
+			if socketPath := pm.GetSocketPath(); socketPath != "" {
+				os.Setenv("CONTAINER_HOST", "unix://"+socketPath)
+			}
 
-		// Ensure basepod network exists for inter-container communication
-		networkCtx, networkCancel := context.WithTimeout(context.Background(), 10*time.Second)
-		if err := pm.CreateNetwork(networkCtx, "basepod"); err != nil {
-			// Ignore "already exists" error
-			if !strings.Contains(err.Error(), "already exists") && !strings.Contains(err.Error(), "network already exists") {
-				log.Printf("Warning: Failed to create basepod network: %v", err)
+			// Verify connection with ping
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if pingErr := pm.Ping(ctx); pingErr != nil {
+				log.Printf("Warning: Podman ping failed: %v", pingErr)
+			} else {
+				log.Printf("Podman connected successfully")
 			}
-		} else {
-			log.Printf("Basepod network created")
+			cancel()
+
+			// Ensure basepod network exists for inter-container communication
+			networkCtx, networkCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := pm.CreateNetwork(networkCtx, "basepod"); err != nil {
+				// Ignore "already exists" error
+				if !strings.Contains(err.Error(), "already exists") && !strings.Contains(err.Error(), "network already exists") {
+					log.Printf("Warning: Failed to create basepod network: %v", err)
+				}
+			} else {
+				log.Printf("Basepod network created")
+			}
+			networkCancel()
 		}
-		networkCancel()
 	}
 
-	// Initialize Caddy client (auto-start if needed)
-	caddyURL := os.Getenv("CADDY_ADMIN_URL")
-	if caddyURL == "" {
-		caddyURL = "http://localhost:2019"
-	}
-	caddyClient := caddy.NewClient(caddyURL)
-	if err := caddyClient.Ping(); err != nil {
-		log.Printf("Caddy not running, attempting to start...")
-		if startErr := ensureCaddyRunning(); startErr != nil {
-			log.Printf("Warning: Failed to start Caddy: %v", startErr)
-			caddyClient = nil
-		} else {
-			// Retry ping
-			time.Sleep(1 * time.Second)
-			if err := caddyClient.Ping(); err != nil {
-				log.Printf("Warning: Still failed to connect to Caddy: %v", err)
+	// Initialize Caddy client (auto-start if needed), or a fake admin API
+	var caddyClient *caddy.Client
+	if *fakeRuntime {
+		fakeCaddy, _, stopCaddy, err := caddy.StartFake()
+		if err != nil {
+			log.Fatalf("Failed to start fake Caddy: %v", err)
+		}
+		defer stopCaddy()
+		caddyClient = fakeCaddy
+		log.Printf("Using an in-memory fake Caddy admin API")
+	} else {
+		caddyURL := os.Getenv("CADDY_ADMIN_URL")
+		if caddyURL == "" {
+			caddyURL = "http://localhost:2019"
+		}
+		caddyClient = caddy.NewClient(caddyURL)
+		if err := caddyClient.Ping(); err != nil {
+			log.Printf("Caddy not running, attempting to start...")
+			if startErr := ensureCaddyRunning(); startErr != nil {
+				log.Printf("Warning: Failed to start Caddy: %v", startErr)
 				caddyClient = nil
 			} else {
-				log.Printf("Caddy started successfully")
+				// Retry ping
+				time.Sleep(1 * time.Second)
+				if err := caddyClient.Ping(); err != nil {
+					log.Printf("Warning: Still failed to connect to Caddy: %v", err)
+					caddyClient = nil
+				} else {
+					log.Printf("Caddy started successfully")
+				}
 			}
+		} else {
+			log.Printf("Caddy connected successfully")
 		}
-	} else {
-		log.Printf("Caddy connected successfully")
 	}
 
 	// Initialize Caddy HTTP server and sync routes for running apps
@@ -194,16 +234,29 @@ func main() {
 		if err := caddyClient.EnsureBaseConfig(apiPort, domain); err != nil {
 			log.Printf("Warning: Failed to ensure Caddy base config: %v", err)
 		}
+		// One wildcard certificate for app subdomains instead of one each
+		if cfg2 != nil {
+			if provider, ok := cfg2.WildcardTLSProvider(); ok {
+				err := caddyClient.EnableWildcardTLS(caddy.WildcardTLS{
+					Domain:          cfg2.Domain.Root,
+					Email:           cfg2.Domain.Email,
+					DNSProvider:     provider.Provider,
+					APIToken:        provider.APIToken,
+					AccessKeyID:     provider.AccessKeyID,
+					SecretAccessKey: provider.SecretAccessKey,
+				})
+				if err != nil {
+					log.Printf("Warning: Wildcard TLS disabled: %v", err)
+				} else {
+					log.Printf("Wildcard TLS for *.%s via %s DNS challenge", cfg2.Domain.Root, provider.Provider)
+				}
+			}
+		}
 		// Then initialize routes
 		if err := initializeCaddyRoutes(caddyClient, store); err != nil {
 			log.Printf("Warning: Failed to initialize Caddy routes: %v", err)
 		}
-		// Enable Caddy access logging (logs go to stderr which launchd captures to caddy.err)
-		if err := caddyClient.EnableAccessLog(); err != nil {
-			log.Printf("Warning: Failed to enable Caddy access logging: %v", err)
-		} else {
-			log.Printf("Caddy access logging enabled (via stderr)")
-		}
+		// Access logging is configured by the API server, which knows each app's privacy settings
 	}
 
 	// Start built-in DNS server if enabled or if using local domain suffix
@@ -245,7 +298,7 @@ func main() {
 		cfg.Server.APIPort = *port
 	}
 
-	addr := fmt.Sprintf("%s:%d", *host, cfg.Server.APIPort)
+	addr := net.JoinHostPort(apiHost(cfg, *host), strconv.Itoa(cfg.Server.APIPort))
 
 	// Create HTTP server
 	server := &http.Server{
@@ -256,11 +309,16 @@ func main() {
 		IdleTimeout:  2 * time.Minute,
 	}
 
+	listener, err := listenAPI(cfg, paths, addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", addr, err)
+	}
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("Basepod server starting on %s", addr)
 		log.Printf("Base directory: %s", paths.Base)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
@@ -486,14 +544,14 @@ func initializeCaddyRoutes(caddyClient *caddy.Client, store *storage.Storage) er
 		// Handle static sites
 		if a.Type == "static" {
 			staticDir := fmt.Sprintf("%s/data/apps/%s", paths.Base, a.Name)
-			if err := caddyClient.AddStaticRoute(a.Domain, staticDir); err != nil {
+			if err := api.AddStaticAppRoute(caddyClient, &a, a.Domain, staticDir); err != nil {
 				log.Printf("Warning: Failed to add static route for %s: %v", a.Name, err)
 			} else {
 				staticCount++
 			}
 			// Add static routes for aliases
 			for _, alias := range a.Aliases {
-				if err := caddyClient.AddStaticRoute(alias, staticDir); err != nil {
+				if err := api.AddStaticAppRoute(caddyClient, &a, alias, staticDir); err != nil {
 					log.Printf("Warning: Failed to add static alias route for %s: %v", alias, err)
 				} else {
 					aliasCount++
@@ -504,25 +562,28 @@ func initializeCaddyRoutes(caddyClient *caddy.Client, store *storage.Storage) er
 
 		// Handle container apps
 		if a.Ports.HostPort > 0 {
-			routes = append(routes, caddy.Route{
-				ID:        "basepod-" + a.Name,
-				Domain:    a.Domain,
-				Upstream:  fmt.Sprintf("127.0.0.1:%d", a.Ports.HostPort),
-				EnableSSL: a.SSL.Enabled,
-			})
+			routes = append(routes, api.AppRoute(&a, a.Domain))
 			// Add routes for aliases
 			for _, alias := range a.Aliases {
-				routes = append(routes, caddy.Route{
-					ID:        fmt.Sprintf("alias-%s-%s", a.ID[:8], alias),
-					Domain:    alias,
-					Upstream:  fmt.Sprintf("127.0.0.1:%d", a.Ports.HostPort),
-					EnableSSL: a.SSL.Enabled,
-				})
+				routes = append(routes, api.AppRoute(&a, alias))
 				aliasCount++
 			}
 		}
 	}
 
+	// Old domains left behind by domain migrations are answered by basepod,
+	// which counts the hit and redirects to the new domain
+	redirects, _ := store.ListDomainRedirects("")
+	for _, d := range redirects {
+		routes = append(routes, caddy.Route{
+			ID:        "migrate-" + d.FromDomain,
+			Domain:    d.FromDomain,
+			Upstream:  fmt.Sprintf("127.0.0.1:%d", cfg.Server.APIPort),
+			EnableSSL: true,
+		})
+		redirectCount++
+	}
+
 	// Initialize container routes
 	if len(routes) > 0 {
 		if err := caddyClient.InitializeServer(routes); err != nil {
@@ -534,6 +595,68 @@ func initializeCaddyRoutes(caddyClient *caddy.Client, store *storage.Storage) er
 	return nil
 }
 
+// apiHost returns the address the API binds to: --host when given, else
+// server.host. server.local_only overrides both with loopback.
+func apiHost(cfg *config.Config, flagHost string) string {
+	host := cfg.Server.Host
+	if flagHost != "" {
+		host = flagHost
+	}
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	if cfg.Server.LocalOnly {
+		if host != "0.0.0.0" && host != "127.0.0.1" && host != "localhost" {
+			log.Printf("Warning: server.local_only is set, ignoring host %s", host)
+		}
+		host = "127.0.0.1"
+		if cfg.Domain.Root != "" {
+			log.Printf("API bound to localhost only; reach it through https://bp.%s", cfg.Domain.Root)
+		} else {
+			log.Printf("Warning: API bound to localhost only and no domain.root is set, so it is unreachable from other machines")
+		}
+	}
+	return host
+}
+
+// listenAPI opens the API listener, serving HTTPS when server.tls is set
+func listenAPI(cfg *config.Config, paths *config.Paths, addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Server.TLS.Enabled() {
+		return ln, nil
+	}
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name)
+	}
+	if cfg.Domain.Root != "" {
+		hosts = append(hosts, "bp."+cfg.Domain.Root)
+	}
+	if h, _, _ := net.SplitHostPort(addr); h != "0.0.0.0" && h != "::" {
+		hosts = append(hosts, h)
+	}
+	certDir := filepath.Join(paths.Config, "api-tls")
+	cert, err := api.LoadAPICertificate(cfg.Server.TLS, certDir, hosts)
+	if err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to load API certificate: %w", err)
+	}
+	if cfg.Server.TLS.CertFile == "" {
+		pin := ""
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			pin = app.CertPin(leaf)
+		}
+		log.Printf("Serving the API over HTTPS with a self-signed certificate from %s; bp login asks to trust it by its pin %s", certDir, pin)
+	} else {
+		log.Printf("Serving the API over HTTPS with %s", cfg.Server.TLS.CertFile)
+	}
+	return api.NewTLSListener(ln, cert), nil
+}
+
 // printUsage displays the custom help output with subcommands and flags
 func printUsage() {
 	fmt.Fprintf(os.Stderr, `basepod - Container PaaS platform
@@ -548,6 +671,7 @@ Commands:
   restart     Restart the basepod service
   status      Show service status
   update      Update to latest version
+  config      Export or import server config (config export [file], config import <file> [--dry-run])
   version     Show version
   help        Show this help
 
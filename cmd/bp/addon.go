@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// addonInfo is an addon as the API returns it
+type addonInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	Attachments []struct {
+		AppName string `json:"app_name"`
+		EnvVar  string `json:"env_var"`
+	} `json:"attachments"`
+}
+
+// cmdAddon manages Postgres, MySQL and Redis addons
+func cmdAddon(args []string) {
+	usage := `Usage:
+  bp addon create <postgres|mysql|redis> [--name <name>] [--attach <app>] [--as <VAR>]
+  bp addon list
+  bp addon attach <addon> <app> [--as <VAR>]
+  bp addon detach <addon> <app>
+  bp addon destroy <addon> [--keep-data]
+
+An addon runs in its own container with a data volume and generated
+credentials. Attaching sets its connection URL in the app's env, as
+DATABASE_URL (REDIS_URL for Redis) unless --as names another variable.
+Apps pick up attach and detach on their next restart or deploy.
+
+Example:
+  bp addon create postgres --attach myapp
+  bp restart myapp`
+
+	var name, attach, envVar string
+	keepData := false
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--name", "--attach", "--as":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, usage)
+				os.Exit(1)
+			}
+			switch args[i] {
+			case "--name":
+				name = args[i+1]
+			case "--attach":
+				attach = args[i+1]
+			case "--as":
+				envVar = args[i+1]
+			}
+			i++
+		case "--keep-data":
+			keepData = true
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	if len(rest) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	switch {
+	case rest[0] == "create" && len(rest) == 2:
+		var result struct {
+			Addon       addonInfo `json:"addon"`
+			Message     string    `json:"message"`
+			AttachError string    `json:"attach_error"`
+		}
+		addonRequest("POST", "/api/addons", map[string]interface{}{
+			"type":    rest[1],
+			"name":    name,
+			"attach":  attach,
+			"env_var": envVar,
+		}, &result)
+		fmt.Printf("Creating %s addon '%s'\n", result.Addon.Type, result.Addon.Name)
+		if result.AttachError != "" {
+			fmt.Fprintf(os.Stderr, "Not attached: %s\n", result.AttachError)
+		} else if result.Message != "" {
+			fmt.Println(result.Message)
+		}
+	case rest[0] == "list" || rest[0] == "ls":
+		listAddons()
+	case rest[0] == "attach" && len(rest) == 3:
+		var result struct {
+			Message string `json:"message"`
+		}
+		addonRequest("POST", "/api/addons/"+url.PathEscape(rest[1])+"/attach", map[string]interface{}{
+			"app":     rest[2],
+			"env_var": envVar,
+		}, &result)
+		fmt.Println(result.Message)
+	case rest[0] == "detach" && len(rest) == 3:
+		var result struct {
+			Message string `json:"message"`
+		}
+		addonRequest("DELETE", "/api/addons/"+url.PathEscape(rest[1])+"/attach/"+url.PathEscape(rest[2]), nil, &result)
+		fmt.Println(result.Message)
+	case rest[0] == "destroy" && len(rest) == 2:
+		path := "/api/addons/" + url.PathEscape(rest[1])
+		if keepData {
+			path += "?keep_data=true"
+		}
+		var result struct {
+			Detached []string `json:"detached"`
+		}
+		addonRequest("DELETE", path, nil, &result)
+		fmt.Printf("Addon '%s' destroyed\n", rest[1])
+		if len(result.Detached) > 0 {
+			fmt.Printf("Detached from %s; restart them to drop the connection URL\n", strings.Join(result.Detached, ", "))
+		}
+		if keepData {
+			fmt.Println("Data volumes were kept")
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// addonRequest calls the addon API and decodes its response into result,
+// exiting on failure
+func addonRequest(method, path string, body interface{}, result interface{}) {
+	resp, err := apiRequest(method, path, body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		data, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", strings.TrimSpace(string(data)))
+		os.Exit(1)
+	}
+	json.NewDecoder(resp.Body).Decode(result)
+}
+
+// listAddons prints the addons and the apps they're attached to
+func listAddons() {
+	var result struct {
+		Addons []addonInfo `json:"addons"`
+	}
+	addonRequest("GET", "/api/addons", nil, &result)
+	if len(result.Addons) == 0 {
+		fmt.Println("No addons. Create one with: bp addon create postgres --attach <app>")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tTYPE\tSTATUS\tATTACHED TO\n")
+	for _, a := range result.Addons {
+		var attached []string
+		for _, att := range a.Attachments {
+			attached = append(attached, att.AppName+" ("+att.EnvVar+")")
+		}
+		if len(attached) == 0 {
+			attached = []string{"-"}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.Name, a.Type, a.Status, strings.Join(attached, ", "))
+	}
+	w.Flush()
+}
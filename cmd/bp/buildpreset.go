@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// presetDockerfile asks the server to render the Dockerfile for a project
+// from a build preset ("" for the server's default). It returns the
+// Dockerfile and the preset it came from.
+func presetDockerfile(preset string, pt ProjectType, dir string, port int) (string, string, error) {
+	if preset == "" {
+		preset = "default"
+	}
+	query := url.Values{}
+	query.Set("stack", pt.runtime)
+	query.Set("port", fmt.Sprint(port))
+	if pt.runtime == "node" {
+		for _, lockfile := range []string{"yarn.lock", "pnpm-lock.yaml"} {
+			if _, err := os.Stat(filepath.Join(dir, lockfile)); err == nil {
+				query.Set("lockfile", lockfile)
+				break
+			}
+		}
+	}
+
+	req, err := newAPIRequest("", "GET", "/api/build-presets/"+url.PathEscape(preset)+"/dockerfile?"+query.Encode(), nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", "", fmt.Errorf("%s", strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Preset     string `json:"preset"`
+		Dockerfile string `json:"dockerfile"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", "", err
+	}
+	return result.Dockerfile, result.Preset, nil
+}
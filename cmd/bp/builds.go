@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type buildInfo struct {
+	ID        string     `json:"id"`
+	AppName   string     `json:"app_name"`
+	Source    string     `json:"source"`
+	Status    string     `json:"status"`
+	Position  int        `json:"position"`
+	User      string     `json:"user"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at"`
+}
+
+// cmdBuilds lists and cancels builds on the server's build queue
+func cmdBuilds(args []string) {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		cmdBuildsList()
+		return
+	}
+	switch args[0] {
+	case "cancel", "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp builds cancel <id>")
+			os.Exit(1)
+		}
+		cmdBuildsCancel(args[1])
+	default:
+		fmt.Fprintln(os.Stderr, `Usage:
+  bp builds               List running and queued builds
+  bp builds cancel <id>   Cancel a queued build, or stop a running one`)
+		os.Exit(1)
+	}
+}
+
+func fetchBuilds() ([]buildInfo, int) {
+	resp, err := apiRequest("GET", "/api/builds", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Builds      []buildInfo `json:"builds"`
+		MaxParallel int         `json:"max_parallel"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result.Builds, result.MaxParallel
+}
+
+func cmdBuildsList() {
+	builds, maxParallel := fetchBuilds()
+	if len(builds) == 0 {
+		fmt.Printf("No builds running (up to %d run at once)\n", maxParallel)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tAPP\tSTATUS\tSOURCE\tUSER\tWAITING\tRUNNING")
+	for _, b := range builds {
+		status := b.Status
+		if b.Status == "queued" {
+			status = fmt.Sprintf("queued (#%d)", b.Position)
+		}
+		user := b.User
+		if user == "" {
+			user = "-"
+		}
+		waiting, running := time.Since(b.QueuedAt), "-"
+		if b.StartedAt != nil {
+			waiting = b.StartedAt.Sub(b.QueuedAt)
+			running = time.Since(*b.StartedAt).Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", b.ID[:8], b.AppName, status, b.Source, user, waiting.Round(time.Second), running)
+	}
+	w.Flush()
+	fmt.Printf("\nUp to %d builds run at once\n", maxParallel)
+}
+
+func cmdBuildsCancel(id string) {
+	// Accept the short IDs bp builds and deploy output print
+	if len(id) < 36 {
+		builds, _ := fetchBuilds()
+		for _, b := range builds {
+			if strings.HasPrefix(b.ID, id) {
+				id = b.ID
+				break
+			}
+		}
+	}
+
+	resp, err := apiRequest("DELETE", "/api/builds/"+id, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	fmt.Println("Build cancelled")
+}
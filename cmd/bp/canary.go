@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+const canaryUsage = `Usage: bp canary <app>                       Show the app's canary
+       bp canary <app> <image> [--weight 10]  Send a share of requests to a new image
+       bp canary <app> --weight <percent>     Change the canary's share
+       bp promote <app>                       Make the canary the current release
+       bp abort <app>                         Remove the canary
+
+Source apps start a canary with: bp deploy --canary <percent>`
+
+// cmdCanary runs a new version of an app next to the current one and sends
+// it a share of the app's requests
+func cmdCanary(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, canaryUsage)
+		os.Exit(1)
+	}
+	name := args[0]
+	var req app.CanaryRequest
+	if v, ok := flagSet(args[1:], "--weight"); ok {
+		weight, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+		if err != nil || weight < 1 || weight > 99 {
+			fmt.Fprintln(os.Stderr, "--weight must be a percent between 1 and 99")
+			os.Exit(1)
+		}
+		req.Weight = weight
+	}
+	req.FreezeOverride = flagValue(args[1:], "--override")
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		req.Image = args[1]
+	}
+
+	var a app.App
+	switch {
+	case req.Image != "":
+		startCanary(name, req)
+		return
+	case req.Weight != 0:
+		usersRequest("PUT", "/api/apps/"+url.PathEscape(name)+"/canary", req, &a)
+		fmt.Printf("Canary of %s now gets %d%% of requests\n", name, a.Canary.Weight)
+		return
+	}
+
+	a = fetchApp(name)
+	if a.Canary == nil {
+		fmt.Printf("%s has no canary\n", name)
+		return
+	}
+	printCanary(&a)
+}
+
+// startCanary starts a canary from an image and prints how to finish it
+func startCanary(name string, req app.CanaryRequest) {
+	fmt.Printf("Starting canary of %s from %s...\n", name, req.Image)
+	var a app.App
+	usersRequest("POST", "/api/apps/"+url.PathEscape(name)+"/canary", req, &a)
+	printCanary(&a)
+	fmt.Println()
+	fmt.Printf("Promote it with: bp promote %s\n", name)
+	fmt.Printf("Roll it back with: bp abort %s\n", name)
+}
+
+// printCanary shows an app's canary and how requests are split
+func printCanary(a *app.App) {
+	c := a.Canary
+	if c == nil {
+		return
+	}
+	fmt.Printf("Canary:   %s\n", c.Image)
+	if c.CommitHash != "" {
+		fmt.Printf("Commit:   %s %s\n", c.CommitHash, c.CommitMsg)
+	}
+	fmt.Printf("Traffic:  %d%% canary, %d%% current (%s)\n", c.Weight, 100-c.Weight, a.Image)
+	fmt.Printf("Running:  %s\n", time.Since(c.StartedAt).Round(time.Second))
+}
+
+// cmdPromote makes an app's canary its current release
+func cmdPromote(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: bp promote <app>")
+		os.Exit(1)
+	}
+	fmt.Printf("Promoting the canary of %s...\n", args[0])
+	var a app.App
+	usersRequest("POST", "/api/apps/"+url.PathEscape(args[0])+"/canary/promote", nil, &a)
+	fmt.Printf("%s now runs %s\n", a.Name, a.Image)
+}
+
+// cmdAbort removes an app's canary, sending all requests back to the
+// current release
+func cmdAbort(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: bp abort <app>")
+		os.Exit(1)
+	}
+	var a app.App
+	usersRequest("POST", "/api/apps/"+url.PathEscape(args[0])+"/canary/abort", nil, &a)
+	fmt.Printf("Canary removed; %s serves all requests from %s\n", a.Name, a.Image)
+}
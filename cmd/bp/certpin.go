@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	certPins     = map[string]string{} // Saved pins by server host
+	certPinsMu   sync.RWMutex
+	certPinsOnce sync.Once
+)
+
+// trustPinnedCerts makes the CLI's HTTPS and websocket connections accept a
+// server's self-signed certificate when its public key matches the pin saved
+// by bp login. Certificates that verify normally are accepted as before.
+func trustPinnedCerts(cfg *CLIConfig) {
+	certPinsMu.Lock()
+	for _, server := range cfg.Servers {
+		if server.CertPin == "" {
+			continue
+		}
+		if u, err := url.Parse(server.URL); err == nil {
+			certPins[u.Hostname()] = server.CertPin
+		}
+	}
+	pinned := len(certPins) > 0
+	certPinsMu.Unlock()
+	if pinned {
+		certPinsOnce.Do(installPinnedDialer)
+	}
+}
+
+// installPinnedDialer does the TLS handshakes of the default HTTP transport
+// and websocket dialer. The TLS config can't tell which host it is verifying
+// when that is an IP address, so the handshake is done here, where the
+// address is known.
+func installPinnedDialer() {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	dialTLS := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		d := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{
+			ServerName: host,
+			// Checked by verifyPinned instead, which falls back to the pin
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				certPinsMu.RLock()
+				defer certPinsMu.RUnlock()
+				return verifyPinned(cs, host, certPins)
+			},
+		}}
+		return d.DialContext(ctx, network, addr)
+	}
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.DialTLSContext = dialTLS
+	}
+	websocket.DefaultDialer.NetDialTLSContext = dialTLS
+}
+
+// verifyPinned checks the certificate of host against the system roots, and
+// failing that against the pin saved for it
+func verifyPinned(cs tls.ConnectionState, host string, pins map[string]string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server sent no certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+	opts := x509.VerifyOptions{DNSName: host, Intermediates: x509.NewCertPool()}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(opts)
+	if err == nil {
+		return nil
+	}
+	pin, ok := pins[host]
+	if !ok {
+		return err
+	}
+	if got := app.CertPin(leaf); got != pin {
+		return fmt.Errorf("certificate of %s has pin %s, not the %s saved by bp login; run bp login again if the server's key changed", host, got, pin)
+	}
+	return nil
+}
+
+// loginCertPin checks the certificate of an https server for bp login. It
+// returns "" when the certificate verifies normally. Otherwise it returns the
+// certificate's pin once it matches want, or the user trusts it when asked.
+func loginCertPin(server, want string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Scheme != "https" {
+		return "", err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", host, &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	cs := conn.ConnectionState()
+	if verifyPinned(cs, u.Hostname(), nil) == nil {
+		return "", nil
+	}
+
+	pin := app.CertPin(cs.PeerCertificates[0])
+	if want != "" {
+		if want != pin {
+			return "", fmt.Errorf("certificate of %s has pin %s, not %s", u.Hostname(), pin, want)
+		}
+		return pin, nil
+	}
+	fmt.Printf("The certificate of %s isn't signed by a trusted authority.\n", u.Hostname())
+	fmt.Printf("Its pin is %s\n", pin)
+	fmt.Println("Check it against the one the server logs on start (basepod logs), or pass it with --pin.")
+	fmt.Print("Trust this certificate? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		return "", errors.New("certificate not trusted")
+	}
+	return pin, nil
+}
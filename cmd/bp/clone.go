@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// cmdClone copies an app's config, env and volumes to a new app, e.g. a
+// staging copy of a production app
+func cmdClone(args []string) {
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: bp clone <app> <new-name> [--domain <domain>] [--with-secrets] [--copy-volumes] [--no-deploy]")
+		os.Exit(1)
+	}
+	req := app.CloneAppRequest{Name: args[1], Domain: flagValue(args[2:], "--domain")}
+	_, req.IncludeSecrets = flagSet(args[2:], "--with-secrets")
+	_, req.CopyVolumes = flagSet(args[2:], "--copy-volumes")
+	_, req.NoDeploy = flagSet(args[2:], "--no-deploy")
+
+	if req.CopyVolumes {
+		fmt.Printf("Cloning %s to %s (copying volume data)...\n", args[0], req.Name)
+	} else {
+		fmt.Printf("Cloning %s to %s...\n", args[0], req.Name)
+	}
+	var resp struct {
+		App           app.App  `json:"app"`
+		Deployed      bool     `json:"deployed"`
+		CopiedVolumes []string `json:"copied_volumes"`
+		SkippedEnv    []string `json:"skipped_env"`
+		Warnings      []string `json:"warnings"`
+	}
+	usersRequest("POST", "/api/apps/"+url.PathEscape(args[0])+"/clone", req, &resp)
+
+	fmt.Printf("Created %s\n", resp.App.Name)
+	fmt.Printf("  Domain: %s\n", resp.App.Domain)
+	for _, v := range resp.CopiedVolumes {
+		fmt.Printf("  Copied volume: %s\n", v)
+	}
+	if len(resp.SkippedEnv) > 0 {
+		fmt.Printf("  Env vars left out as secrets: %s (--with-secrets to copy them)\n", strings.Join(resp.SkippedEnv, ", "))
+	}
+	for _, w := range resp.Warnings {
+		fmt.Printf("  Warning: %s\n", w)
+	}
+	switch {
+	case resp.Deployed:
+		fmt.Printf("\nRunning at https://%s\n", resp.App.Domain)
+	case req.NoDeploy:
+		fmt.Printf("\nStart it with: bp restart %s\n", resp.App.Name)
+	default:
+		fmt.Printf("\nNot started; deploy it with: bp deploy (name: %s)\n", resp.App.Name)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/base-go/basepod/internal/compose"
+	"gopkg.in/yaml.v3"
+)
+
+// composeFileNames are the Compose files bp init --from-compose looks for
+// when none is named, in Compose's own order of preference
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// initFromCompose writes basepod.yaml from a docker-compose file
+func initFromCompose(dir, file, appName, configPath string) {
+	if file == "" {
+		for _, name := range composeFileNames {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				file = filepath.Join(dir, name)
+				break
+			}
+		}
+		if file == "" {
+			fmt.Fprintln(os.Stderr, "No compose.yaml or docker-compose.yml found; pass the file: bp init --from-compose <file>")
+			os.Exit(1)
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", file, err)
+		os.Exit(1)
+	}
+
+	project, warnings, err := compose.Convert(data, appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to convert %s: %v\n", file, err)
+		os.Exit(1)
+	}
+
+	cliCfg, _ := loadConfig()
+	cfg := AppConfig{
+		Name:     project.Name,
+		Server:   cliCfg.CurrentContext,
+		Port:     project.Port,
+		Services: make(map[string]*ServiceConfig, len(project.Services)),
+	}
+	for name, svc := range project.Services {
+		cfg.Services[name] = &ServiceConfig{
+			Image:     svc.Image,
+			Build:     ServiceBuild{Context: svc.Build.Context, Dockerfile: svc.Build.Dockerfile},
+			Port:      svc.Port,
+			Command:   svc.Command,
+			Env:       svc.Env,
+			EnvFile:   svc.EnvFile,
+			Volumes:   svc.Volumes,
+			DependsOn: svc.DependsOn,
+		}
+	}
+	configData, _ := yaml.Marshal(cfg)
+	if err := os.WriteFile(configPath, configData, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Converted %s: %d service(s)\n", file, len(project.Services))
+	if len(warnings) > 0 {
+		fmt.Println("\nReview these before deploying:")
+		for _, w := range warnings {
+			fmt.Printf("  - %s\n", w)
+		}
+	}
+	fmt.Println("\nCreated: basepod.yaml")
+	fmt.Println("\nNext steps:")
+	fmt.Println("  bp run      # Try the services locally")
+	fmt.Println("  bp deploy")
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cmdCp copies files between an app's container and this machine
+func cmdCp(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, `Usage: bp cp <app>:<path> <local>
+       bp cp <local> <app>:<path>
+
+Copies a file or directory. A local destination that is a directory receives
+the copy inside it; use - to write the tar archive to stdout. A container
+destination ending in / is a directory to copy into, otherwise the copy is
+named after it.`)
+		os.Exit(1)
+	}
+
+	if appName, remote, ok := splitRemotePath(args[0]); ok {
+		if err := copyFromApp(appName, remote, args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if appName, remote, ok := splitRemotePath(args[1]); ok {
+		if err := copyToApp(args[0], appName, remote); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Error: one side must be <app>:<path>, e.g. web:/app/config.yml")
+	os.Exit(1)
+}
+
+// splitRemotePath splits <app>:<path>. Local paths containing a colon are
+// told apart by a / before it, so write ./a:b for a local file named a:b.
+func splitRemotePath(arg string) (appName, remote string, ok bool) {
+	appName, remote, found := strings.Cut(arg, ":")
+	if !found || appName == "" || strings.ContainsAny(appName, `/\`) {
+		return "", "", false
+	}
+	if !strings.HasPrefix(remote, "/") {
+		remote = "/" + remote
+	}
+	return appName, remote, true
+}
+
+// copyFromApp downloads a file or directory from an app's container into dest
+func copyFromApp(appName, remote, dest string) error {
+	resp, err := apiStreamRequest("/api/apps/" + url.PathEscape(appName) + "/files?path=" + url.QueryEscape(remote))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to copy: %s", strings.TrimSpace(string(body)))
+	}
+
+	if dest == "-" {
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+
+	// Into an existing directory the copy keeps its name, otherwise it
+	// takes dest's
+	rename := dest
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		rename = ""
+	}
+
+	files := 0
+	tr := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("refusing to extract %s", hdr.Name)
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		if rename != "" {
+			_, rest, _ := strings.Cut(name, "/")
+			target = filepath.Join(rename, filepath.FromSlash(rest))
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			files++
+		case tar.TypeSymlink:
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Copied %s:%s to %s (%d files)\n", appName, remote, dest, files)
+	return nil
+}
+
+// copyToApp uploads a local file or directory into an app's container
+func copyToApp(src, appName, remote string) error {
+	if _, err := os.Lstat(src); err != nil {
+		return err
+	}
+
+	// The server extracts into a directory; the archive's top entry names
+	// the copy
+	dir, name := path.Dir(remote), path.Base(remote)
+	if strings.HasSuffix(remote, "/") {
+		dir, name = path.Clean(remote), filepath.Base(src)
+	}
+
+	req, err := newAPIRequest("", "PUT", "/api/apps/"+url.PathEscape(appName)+"/files?path="+url.QueryEscape(dir), nil)
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeCopyArchive(pw, src, name))
+	}()
+	req.Body = pr
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to copy: %s", strings.TrimSpace(string(body)))
+	}
+
+	fmt.Fprintf(os.Stderr, "Copied %s to %s:%s\n", src, appName, path.Join(dir, name))
+	return nil
+}
+
+// writeCopyArchive writes src as a tar archive whose top entry is name
+func writeCopyArchive(w io.Writer, src, name string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if fi.Mode().IsRegular() {
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(tw, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// sourceManifest lists the entries of a source tarball for a delta upload,
+// with the content of each file by its SHA256
+func sourceManifest(tarball []byte) ([]app.SourceFile, map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, nil, err
+	}
+	var files []app.SourceFile
+	blobs := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		f := app.SourceFile{Path: hdr.Name, Mode: hdr.Mode, ModTime: hdr.ModTime}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			f.Type = app.SourceFileDir
+		case tar.TypeSymlink:
+			f.Type = app.SourceFileSymlink
+			f.Link = hdr.Linkname
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, nil, err
+			}
+			sum := sha256.Sum256(data)
+			f.Type = app.SourceFileRegular
+			f.Size = int64(len(data))
+			f.SHA256 = hex.EncodeToString(sum[:])
+			blobs[f.SHA256] = data
+		default:
+			return nil, nil, fmt.Errorf("%s: unsupported file type", hdr.Name)
+		}
+		files = append(files, f)
+	}
+	return files, blobs, nil
+}
+
+// serverRequest makes a request to a deploy target
+func serverRequest(client *http.Client, serverCfg *ServerConfig, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(serverCfg.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if serverCfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+serverCfg.Token)
+	}
+	return client.Do(req)
+}
+
+// uploadDelta sends the server the file contents of tarball it doesn't have
+// from earlier deploys and returns the manifest to deploy with. It returns
+// no manifest when the server predates delta uploads.
+func uploadDelta(client *http.Client, serverCfg *ServerConfig, tarball []byte) ([]app.SourceFile, error) {
+	files, blobs, err := sourceManifest(tarball)
+	if err != nil {
+		return nil, err
+	}
+
+	check := app.BlobCheckRequest{Hashes: make([]string, 0, len(blobs))}
+	for h := range blobs {
+		check.Hashes = append(check.Hashes, h)
+	}
+	body, _ := json.Marshal(check)
+	resp, err := serverRequest(client, serverCfg, "POST", "/api/deploy/blobs/check", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("checking files: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var missing app.BlobCheckResponse
+	if err := json.NewDecoder(resp.Body).Decode(&missing); err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, data := range blobs {
+		total += int64(len(data))
+	}
+	if len(missing.Missing) == 0 {
+		fmt.Printf("All %d files are on the server already (%d bytes unchanged)\n", len(blobs), total)
+		return files, nil
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	var size int64
+	for _, h := range missing.Missing {
+		data, ok := blobs[h]
+		if !ok {
+			return nil, fmt.Errorf("server asked for unknown content %s", h)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: h, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+		size += int64(len(data))
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Uploading %d of %d files (%d of %d bytes, %d compressed)...\n", len(missing.Missing), len(blobs), size, total, buf.Len())
+	resp, err = serverRequest(client, serverCfg, "POST", "/api/deploy/blobs", "application/gzip", &buf)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("uploading files: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return files, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// deployEvent mirrors one JSON line of structured deploy output
+type deployEvent struct {
+	Type       string `json:"type"`
+	Phase      string `json:"phase"`
+	Status     string `json:"status"`
+	Level      string `json:"level"`
+	Message    string `json:"message"`
+	Step       int    `json:"step"`
+	Steps      int    `json:"steps"`
+	DurationMS int64  `json:"duration_ms"`
+	App        string `json:"app"`
+	URL        string `json:"url"`
+}
+
+// printDeployEvents renders structured deploy output as it arrives and
+// returns the final result event. A stream cut off before the result is
+// reported as failed.
+func printDeployEvents(body io.Reader) deployEvent {
+	result := deployEvent{Type: "result", Status: "failed", Message: "connection closed before the deploy finished"}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e deployEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			fmt.Println(scanner.Text())
+			continue
+		}
+		switch e.Type {
+		case "phase":
+			switch e.Status {
+			case "running":
+				fmt.Printf("\n[%d/%d] %s\n", e.Step, e.Steps, strings.ToUpper(e.Phase[:1])+e.Phase[1:])
+			case "done":
+				fmt.Printf("      ✓ %s (%s)\n", e.Phase, (time.Duration(e.DurationMS) * time.Millisecond).Round(100*time.Millisecond))
+			case "failed":
+				fmt.Printf("      ✗ %s failed\n", e.Phase)
+			}
+		case "log":
+			if e.Level == "error" {
+				fmt.Fprintln(os.Stderr, "      "+e.Message)
+			} else {
+				fmt.Println("      " + e.Message)
+			}
+		case "result":
+			result = e
+		}
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// promoteSections are applied by promote-config unless --only says otherwise.
+// Domains differ between environments by design, so they're opt-in.
+var promoteSections = []string{"image", "env", "resources"}
+
+func cmdDiff(args []string) {
+	usage := "Usage: bp diff <name> --context <a> --context <b> [--show-secrets]"
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	name := args[0]
+	var contexts []string
+	showSecrets := false
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--context", "-c":
+			if i+1 < len(args) {
+				contexts = append(contexts, args[i+1])
+				i++
+			}
+		case "--show-secrets":
+			showSecrets = true
+		}
+	}
+	if len(contexts) != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	from := fetchAppContext(contexts[0], name)
+	to := fetchAppContext(contexts[1], name)
+	changes := app.DiffApps(from, to)
+	if len(changes) == 0 {
+		fmt.Printf("No differences for '%s' between %s and %s\n", name, contexts[0], contexts[1])
+		return
+	}
+
+	printChanges(changes, contexts[0], contexts[1], showSecrets)
+}
+
+func cmdPromoteConfig(args []string) {
+	usage := "Usage: bp promote-config <name> --from <context> --to <context> [--only image,env,resources,domains] [--env KEY,...] [--yes]"
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	name := args[0]
+	fromCtx, toCtx := "", ""
+	sections := promoteSections
+	var envKeys []string
+	yes := false
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--from":
+			if i+1 < len(args) {
+				fromCtx = args[i+1]
+				i++
+			}
+		case "--to":
+			if i+1 < len(args) {
+				toCtx = args[i+1]
+				i++
+			}
+		case "--only":
+			if i+1 < len(args) {
+				sections = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--env":
+			if i+1 < len(args) {
+				envKeys = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--yes", "-y":
+			yes = true
+		}
+	}
+	if fromCtx == "" || toCtx == "" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	from := fetchAppContext(fromCtx, name)
+	to := fetchAppContext(toCtx, name)
+	changes := app.SelectChanges(app.DiffApps(from, to), sections, envKeys)
+	if len(changes) == 0 {
+		fmt.Printf("Nothing to promote for '%s' from %s to %s\n", name, fromCtx, toCtx)
+		return
+	}
+
+	printChanges(changes, fromCtx, toCtx, false)
+	if !yes {
+		fmt.Printf("\nApply these changes to '%s' on %s? [y/N]: ", name, toCtx)
+		var confirm string
+		fmt.Scanln(&confirm)
+		if strings.ToLower(confirm) != "y" {
+			fmt.Println("Cancelled")
+			return
+		}
+	}
+
+	update := app.PromotionUpdate(changes, from, to)
+	resp, err := apiRequestContext(toCtx, "PUT", "/api/apps/"+name, update)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed to update app: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	fmt.Printf("Promoted %d change(s) to '%s' on %s. Run `bp restart %s` there to apply them.\n", len(changes), name, toCtx, name)
+}
+
+// fetchAppContext fetches an app from the server behind a named context
+func fetchAppContext(contextName, name string) app.App {
+	resp, err := apiRequestContext(contextName, "GET", "/api/apps/"+name, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error (%s): %v\n", contextName, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed to get app from %s: %s\n", contextName, string(body))
+		os.Exit(1)
+	}
+
+	var a app.App
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response from %s: %v\n", contextName, err)
+		os.Exit(1)
+	}
+	return a
+}
+
+func printChanges(changes []app.ConfigChange, fromName, toName string, showSecrets bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SECTION\tFIELD\t%s\t%s\n", strings.ToUpper(fromName), strings.ToUpper(toName))
+	for _, c := range changes {
+		from, to := c.Values(showSecrets)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Section, c.Field, from, to)
+	}
+	w.Flush()
+}
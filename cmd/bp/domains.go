@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// domainRedirect mirrors app.DomainRedirect for CLI output
+type domainRedirect struct {
+	ID         string     `json:"id"`
+	FromDomain string     `json:"from_domain"`
+	ToDomain   string     `json:"to_domain"`
+	Hits       int64      `json:"hits"`
+	LastHitAt  *time.Time `json:"last_hit_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func cmdDomains(args []string) {
+	usage := `Usage:
+  bp domains list <app>                               Show an app's primary domain and aliases
+  bp domains add <app> <domain>                       Serve the app on another domain too
+  bp domains rm <app> <domain>                        Stop serving the app on an alias domain
+  bp domains migrate <app> <old-domain> <new-domain>  Move an app to a new domain; the old one 301s to it
+  bp domains redirects <app>                          Show old domains and traffic still reaching them
+  bp domains unredirect <app> <old-domain>            Stop redirecting an old domain`
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		resp, err := apiRequest("GET", fmt.Sprintf("/api/apps/%s/domains", args[1]), nil)
+		domains := finishDomainsCommand(resp, err)
+		if domains.Domain != "" {
+			fmt.Printf("%s (primary)\n", domains.Domain)
+		}
+		for _, alias := range domains.Aliases {
+			fmt.Println(alias)
+		}
+		if domains.Domain == "" && len(domains.Aliases) == 0 {
+			fmt.Printf("%s has no domains.\n", args[1])
+		}
+
+	case "add":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp domains add <app> <domain>")
+			os.Exit(1)
+		}
+		resp, err := apiRequest("POST", fmt.Sprintf("/api/apps/%s/domains", args[1]), map[string]string{"domain": args[2]})
+		finishDomainsCommand(resp, err)
+		fmt.Printf("%s now also serves %s\n", args[1], args[2])
+		fmt.Println("Point DNS for the domain at this server; its certificate is issued on the first request.")
+
+	case "rm", "remove":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp domains rm <app> <domain>")
+			os.Exit(1)
+		}
+		resp, err := apiRequest("DELETE", fmt.Sprintf("/api/apps/%s/domains/%s", args[1], args[2]), nil)
+		finishDomainsCommand(resp, err)
+		fmt.Printf("%s no longer serves %s\n", args[1], args[2])
+
+	case "migrate":
+		if len(args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: bp domains migrate <app> <old-domain> <new-domain>")
+			os.Exit(1)
+		}
+		appName, from, to := args[1], args[2], args[3]
+		resp, err := apiRequest("POST", fmt.Sprintf("/api/apps/%s/domains/migrate", appName), map[string]string{
+			"from": from,
+			"to":   to,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+			os.Exit(1)
+		}
+		fmt.Printf("%s now serves %s\n", appName, to)
+		fmt.Printf("%s redirects to https://%s (301, path and query kept)\n", from, to)
+		fmt.Println("Certificates for both domains are issued on first request; point DNS for the new domain at this server.")
+		fmt.Printf("Run `bp domains redirects %s` to see traffic still reaching the old domain.\n", appName)
+
+	case "redirects":
+		resp, err := apiRequest("GET", fmt.Sprintf("/api/apps/%s/domains/redirects", args[1]), nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+			os.Exit(1)
+		}
+
+		var redirects []domainRedirect
+		json.NewDecoder(resp.Body).Decode(&redirects)
+		if len(redirects) == 0 {
+			fmt.Println("No domain redirects.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "OLD DOMAIN\tREDIRECTS TO\tHITS\tLAST HIT\tSINCE\n")
+		for _, d := range redirects {
+			lastHit := "never"
+			if d.LastHitAt != nil {
+				lastHit = d.LastHitAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", d.FromDomain, d.ToDomain, d.Hits, lastHit, d.CreatedAt.Local().Format("2006-01-02"))
+		}
+		w.Flush()
+
+	case "unredirect":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp domains unredirect <app> <old-domain>")
+			os.Exit(1)
+		}
+		resp, err := apiRequest("DELETE", fmt.Sprintf("/api/apps/%s/domains/redirects/%s", args[1], args[2]), nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+			os.Exit(1)
+		}
+		fmt.Printf("Stopped redirecting %s\n", args[2])
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// appDomains is an app's primary domain and aliases
+type appDomains struct {
+	Domain  string   `json:"domain"`
+	Aliases []string `json:"aliases"`
+}
+
+// finishDomainsCommand exits on a failed request and returns the app's
+// domains from the response
+func finishDomainsCommand(resp *http.Response, err error) appDomains {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+		os.Exit(1)
+	}
+	var domains appDomains
+	json.NewDecoder(resp.Body).Decode(&domains)
+	return domains
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/term"
+)
+
+// cmdExec runs a command, or a shell, in an app's container with this
+// terminal attached, and exits with the command's exit code
+func cmdExec(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, `Usage: bp exec <app> [command [args...]]
+
+Without a command, opens a shell (bash if the image has it, otherwise sh).
+Put -- before commands with flags of their own: bp exec web -- ls -la`)
+		os.Exit(1)
+	}
+	command := args[1:]
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+	os.Exit(runExec(args[0], command))
+}
+
+// runExec bridges the local terminal to an exec session and returns the exit
+// code. It is separate from cmdExec so deferred terminal restores run before
+// the process exits.
+func runExec(appName string, command []string) int {
+	req, err := newAPIRequest("", "GET", "/api/apps/"+url.PathEscape(appName)+"/terminal", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	q := url.Values{}
+	for _, arg := range command {
+		q.Add("cmd", arg)
+	}
+	if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		q.Set("cols", strconv.Itoa(cols))
+		q.Set("rows", strconv.Itoa(rows))
+	}
+	req.URL.RawQuery = q.Encode()
+	if req.URL.Scheme == "https" {
+		req.URL.Scheme = "wss"
+	} else {
+		req.URL.Scheme = "ws"
+	}
+
+	conn, resp, err := websocket.DefaultDialer.Dial(req.URL.String(), req.Header)
+	if err != nil {
+		if resp != nil {
+			body, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed to open exec session: %s\n", strings.TrimSpace(string(body)))
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return 1
+	}
+	defer conn.Close()
+
+	stdin := int(os.Stdin.Fd())
+	interactive := term.IsTerminal(stdin)
+	if interactive {
+		if oldState, err := term.MakeRaw(stdin); err == nil {
+			defer term.Restore(stdin, oldState)
+		}
+	}
+
+	var writeMu sync.Mutex
+	write := func(msgType int, data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(msgType, data)
+	}
+
+	// Keep the remote TTY the size of this terminal
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for range winch {
+			if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+				write(websocket.TextMessage, []byte(fmt.Sprintf("resize:%d,%d", cols, rows)))
+			}
+		}
+	}()
+
+	// Input; piped input ends with Ctrl-D, the TTY's end of file
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				if write(websocket.BinaryMessage, buf[:n]) != nil {
+					return
+				}
+			}
+			if err != nil {
+				if !interactive {
+					write(websocket.BinaryMessage, []byte{4})
+				}
+				return
+			}
+		}
+	}()
+
+	// Output until the session closes with the command's exit code
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				if code, err := strconv.Atoi(strings.TrimPrefix(closeErr.Text, "exit ")); err == nil {
+					return code
+				}
+				return 0
+			}
+			return 1
+		}
+		if msgType == websocket.TextMessage {
+			// Errors from the server, e.g. when the exec couldn't start
+			fmt.Fprintf(os.Stderr, "%s\r\n", data)
+			continue
+		}
+		os.Stdout.Write(data)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/k8s"
+)
+
+// cmdExport converts apps to other platforms' formats
+func cmdExport(args []string) {
+	if len(args) < 1 || args[0] != "k8s" {
+		fmt.Fprintln(os.Stderr, "Usage: bp export k8s [app] [--namespace <ns>] [--ingress-class <class>] [-o <file>]")
+		os.Exit(1)
+	}
+	cmdExportK8s(args[1:])
+}
+
+// cmdExportK8s writes Kubernetes manifests for a deployed app, or for the
+// services of the basepod.yaml in the current directory
+func cmdExportK8s(args []string) {
+	opts := k8s.Options{
+		Namespace:    flagValue(args, "--namespace"),
+		IngressClass: flagValue(args, "--ingress-class"),
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = flagValue(args, "-n")
+	}
+	output := flagValue(args, "-o")
+	if output == "" {
+		output = flagValue(args, "--output")
+	}
+	appName := ""
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") {
+			i++ // skip the flag's value
+			continue
+		}
+		appName = args[i]
+		break
+	}
+
+	var workloads []k8s.Workload
+	var warnings []string
+	cfg, cfgErr := loadAppConfig(".")
+	switch {
+	case appName == "" && cfgErr == nil && len(cfg.Services) > 0:
+		opts.PartOf = cfg.Name
+		workloads, warnings = stackWorkloads(cfg)
+	default:
+		if appName == "" {
+			if cfgErr != nil {
+				fmt.Fprintln(os.Stderr, "Usage: bp export k8s <app> (or run it next to a basepod.yaml)")
+				os.Exit(1)
+			}
+			appName = cfg.Name
+		}
+		workloads = []k8s.Workload{appWorkload(fetchApp(appName))}
+	}
+
+	manifests, warns, err := k8s.Manifests(workloads, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	warnings = append(warnings, warns...)
+
+	if output == "" {
+		os.Stdout.Write(manifests)
+	} else {
+		if err := os.WriteFile(output, manifests, 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", output, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", output)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+}
+
+// appWorkload describes a deployed app as a Kubernetes workload
+func appWorkload(a app.App) k8s.Workload {
+	w := k8s.Workload{
+		Name:     a.Name,
+		Image:    a.Image,
+		Replicas: a.Resources.Replicas,
+		Port:     a.Ports.ContainerPort,
+		Protocol: a.Ports.Protocol,
+		Env:      a.Env,
+		MemoryMB: a.Resources.Memory,
+		CPUs:     a.Resources.CPUs,
+	}
+	if a.HealthCheck != nil {
+		w.HealthPath = a.HealthCheck.Endpoint
+	}
+	if a.Domain != "" {
+		w.Hosts = append(w.Hosts, a.Domain)
+	}
+	w.Hosts = append(w.Hosts, a.Aliases...)
+	for _, v := range a.Volumes {
+		w.Volumes = append(w.Volumes, k8s.Volume{Name: v.Name, MountPath: v.ContainerPath, ReadOnly: v.ReadOnly, Size: v.Size})
+	}
+	return w
+}
+
+// stackWorkloads describes the services of a basepod.yaml. The service on
+// the app's port gets the app's domain.
+func stackWorkloads(cfg *AppConfig) ([]k8s.Workload, []string) {
+	order, err := orderServices(cfg.Services)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var workloads []k8s.Workload
+	var warnings []string
+	for _, name := range order {
+		svc := cfg.Services[name]
+		w := k8s.Workload{
+			Name:    name,
+			Image:   svc.Image,
+			Port:    svc.Port,
+			Command: svc.Command,
+			Env:     svc.Env,
+		}
+		if w.Image == "" {
+			w.Image = fmt.Sprintf("%s-%s:latest", cfg.Name, name)
+			warnings = append(warnings, fmt.Sprintf("%s: is built from source; build and push it as %s, or set the image in the manifest", name, w.Image))
+		}
+		if svc.Ready != nil {
+			w.HealthPath = svc.Ready.HTTP
+		}
+		if len(svc.EnvFile) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: env_file %s was not exported; add its variables to the %s-env Secret", name, strings.Join(svc.EnvFile, ", "), name))
+		}
+		if svc.Port > 0 && svc.Port == cfg.Port && cfg.Domain != "" {
+			w.Hosts = []string{cfg.Domain}
+		}
+		for _, vol := range svc.Volumes {
+			parts := strings.Split(vol, ":")
+			if len(parts) < 2 || strings.HasPrefix(parts[0], ".") || strings.HasPrefix(parts[0], "/") {
+				warnings = append(warnings, fmt.Sprintf("%s: bind mount %s was not exported", name, vol))
+				continue
+			}
+			w.Volumes = append(w.Volumes, k8s.Volume{Name: parts[0], MountPath: parts[1], ReadOnly: len(parts) > 2 && parts[2] == "ro"})
+		}
+		workloads = append(workloads, w)
+	}
+	return workloads, warnings
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// cmdFreeze manages deploy freeze windows
+func cmdFreeze(args []string) {
+	if len(args) == 0 || args[0] == "list" {
+		cmdFreezeList()
+		return
+	}
+	switch args[0] {
+	case "add":
+		cmdFreezeAdd(args[1:])
+	case "rm", "remove", "delete":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp freeze rm <id>")
+			os.Exit(1)
+		}
+		cmdFreezeRemove(args[1])
+	default:
+		fmt.Fprintln(os.Stderr, `Usage:
+  bp freeze                          List freeze windows and the active one
+  bp freeze add <name> [--days mon,fri] [--start 16:00] [--end 23:59]
+                                     Weekly freeze (server time; end defaults to midnight)
+  bp freeze add <name> --from 2026-12-24 --until 2027-01-02
+                                     Dated freeze; MM-DD dates repeat every year
+  bp freeze rm <id>                  Remove a freeze window
+
+Deploys during a freeze need: bp deploy --override "<reason>"`)
+		os.Exit(1)
+	}
+}
+
+func cmdFreezeList() {
+	resp, err := apiRequest("GET", "/api/freeze-windows", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Windows []app.FreezeWindow `json:"windows"`
+		Active  *app.FreezeWindow  `json:"active"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if len(result.Windows) == 0 {
+		fmt.Println("No freeze windows. Add one with: bp freeze add <name> --days fri --start 16:00")
+		return
+	}
+	if result.Active != nil {
+		fmt.Printf("Deploys are frozen now: %s\n\n", result.Active.Name)
+	} else {
+		fmt.Println("No freeze in effect now.")
+		fmt.Println()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tWHEN\tCREATED BY")
+	for _, fw := range result.Windows {
+		by := fw.CreatedBy
+		if by == "" {
+			by = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", fw.ID[:8], fw.Name, freezeWhen(fw), by)
+	}
+	w.Flush()
+}
+
+func cmdFreezeAdd(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: bp freeze add <name> [--days mon,fri] [--start 16:00] [--end 23:59] [--from <date> --until <date>]")
+		os.Exit(1)
+	}
+	fw := app.FreezeWindow{Name: args[0]}
+	for i := 1; i < len(args); i++ {
+		if i+1 >= len(args) {
+			break
+		}
+		switch args[i] {
+		case "--days":
+			fw.Days = strings.Split(args[i+1], ",")
+		case "--start":
+			fw.Start = args[i+1]
+		case "--end":
+			fw.End = args[i+1]
+		case "--from":
+			fw.From = args[i+1]
+		case "--until":
+			fw.Until = args[i+1]
+		default:
+			continue
+		}
+		i++
+	}
+
+	resp, err := apiRequest("POST", "/api/freeze-windows", fw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var created app.FreezeWindow
+	json.NewDecoder(resp.Body).Decode(&created)
+	fmt.Printf("Added freeze window %s: %s (%s)\n", created.ID[:8], created.Name, freezeWhen(created))
+}
+
+func cmdFreezeRemove(id string) {
+	// Accept the short IDs bp freeze prints
+	if len(id) < 36 {
+		resp, err := apiRequest("GET", "/api/freeze-windows", nil)
+		if err == nil {
+			var result struct {
+				Windows []app.FreezeWindow `json:"windows"`
+			}
+			json.NewDecoder(resp.Body).Decode(&result)
+			resp.Body.Close()
+			for _, fw := range result.Windows {
+				if strings.HasPrefix(fw.ID, id) {
+					id = fw.ID
+					break
+				}
+			}
+		}
+	}
+
+	resp, err := apiRequest("DELETE", "/api/freeze-windows/"+id, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	fmt.Println("Freeze window removed")
+}
+
+// freezeWhen describes when a freeze window applies
+func freezeWhen(fw app.FreezeWindow) string {
+	if fw.From != "" {
+		return fw.From + " to " + fw.Until
+	}
+	days := "every day"
+	if len(fw.Days) > 0 {
+		days = strings.Join(fw.Days, ",")
+	}
+	start, end := fw.Start, fw.End
+	if start == "" {
+		start = "00:00"
+	}
+	if end == "" {
+		end = "24:00"
+	}
+	return fmt.Sprintf("%s %s-%s", days, start, end)
+}
+
+// exitIfFrozen explains a deploy refused because of a deploy freeze and
+// exits; other responses are left to the caller
+func exitIfFrozen(status int, body []byte) {
+	if status != http.StatusLocked {
+		return
+	}
+	var result struct {
+		Error  string            `json:"error"`
+		Freeze *app.FreezeWindow `json:"freeze"`
+	}
+	if json.Unmarshal(body, &result) != nil || result.Freeze == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nDeploy refused: deploys are frozen (%s, %s)\n", result.Freeze.Name, freezeWhen(*result.Freeze))
+	fmt.Fprintln(os.Stderr, `To deploy anyway, give a reason: --override "<reason>"`)
+	os.Exit(1)
+}
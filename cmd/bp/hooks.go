@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// cmdHooks sets up GitHub and GitLab push webhooks
+func cmdHooks(args []string) {
+	if len(args) < 1 || args[0] != "create" {
+		fmt.Fprintln(os.Stderr, `Usage:
+  bp hooks create <app> <git_url> [--branch <branch>] [--provider github|gitlab]
+                                 Generate a webhook secret and URL; pushes to the branch deploy the app`)
+		os.Exit(1)
+	}
+	if len(args) < 3 {
+		fmt.Fprintln(os.Stderr, "Usage: bp hooks create <app> <git_url> [--branch <branch>] [--provider github|gitlab]")
+		os.Exit(1)
+	}
+	appName, gitURL := args[1], args[2]
+	branch := ""
+	provider := ""
+	for i := 3; i < len(args); i++ {
+		switch args[i] {
+		case "--branch", "-b":
+			if i+1 < len(args) {
+				branch = args[i+1]
+				i++
+			}
+		case "--provider":
+			if i+1 < len(args) {
+				provider = args[i+1]
+				i++
+			}
+		}
+	}
+	if provider == "" {
+		provider = "github"
+		if host := gitHost(gitURL); host != "" && host != "github.com" {
+			provider = "gitlab"
+		}
+	}
+	if provider != "github" && provider != "gitlab" {
+		fmt.Fprintln(os.Stderr, "--provider must be github or gitlab")
+		os.Exit(1)
+	}
+
+	resp, err := apiRequest("POST", fmt.Sprintf("/api/apps/%s/webhook/setup", appName), map[string]string{
+		"git_url": gitURL,
+		"branch":  branch,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+		os.Exit(1)
+	}
+	var result struct {
+		GitHubURL string `json:"github_url"`
+		GitLabURL string `json:"gitlab_url"`
+		Secret    string `json:"secret"`
+		Branch    string `json:"branch"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	hookURL := result.GitHubURL
+	if provider == "gitlab" {
+		hookURL = result.GitLabURL
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Webhook URL:\t%s\n", hookURL)
+	fmt.Fprintf(w, "Secret:\t%s\n", result.Secret)
+	fmt.Fprintf(w, "Branch:\t%s\n", result.Branch)
+	w.Flush()
+
+	if provider == "gitlab" {
+		fmt.Println("\nIn GitLab: Settings > Webhooks. Paste the URL, put the secret in \"Secret token\" and tick \"Push events\".")
+	} else {
+		fmt.Println("\nIn GitHub: Settings > Webhooks > Add webhook. Paste the URL, choose application/json and paste the secret.")
+	}
+	fmt.Println("The server clones the repo on each push, so private repos need a git_url it can read (e.g. with a token).")
+}
+
+// gitHost returns the host of an https or scp-like git URL
+func gitHost(gitURL string) string {
+	rest := gitURL
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	if i := strings.Index(rest, "@"); i >= 0 {
+		rest = rest[i+1:]
+	}
+	for i, c := range rest {
+		if c == '/' || c == ':' {
+			return rest[:i]
+		}
+	}
+	return rest
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// httpsProxyImage terminates TLS in front of a `bp run --https` app
+const httpsProxyImage = "docker.io/caddy:2-alpine"
+
+// httpsCAVolume keeps Caddy's local CA across runs so it only has to be trusted once
+const httpsCAVolume = "bp-https-ca"
+
+// defaultHTTPSPort is used because rootless Podman can't bind 443
+const defaultHTTPSPort = 8443
+
+// localHTTPSHost is the hostname an app is served on with --https. Browsers
+// resolve *.localhost to the loopback address without any DNS setup.
+func localHTTPSHost(appName string) string {
+	return appName + ".localhost"
+}
+
+// startHTTPSProxy runs a Caddy container that serves https://<app>.localhost
+// and proxies to the app on upstreamPort. Certificates come from mkcert when it
+// is installed, otherwise from Caddy's local CA.
+func startHTTPSProxy(appName string, upstreamPort, httpsPort int) (string, error) {
+	host := localHTTPSHost(appName)
+	container := appName + "-https"
+
+	home, _ := os.UserHomeDir()
+	certDir := filepath.Join(home, ".basepod", "certs")
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		return "", fmt.Errorf("create cert dir: %w", err)
+	}
+
+	tlsDirective := "tls internal"
+	useMkcert := false
+	if _, err := exec.LookPath("mkcert"); err == nil {
+		certFile := filepath.Join(certDir, host+".pem")
+		keyFile := filepath.Join(certDir, host+"-key.pem")
+		if _, err := os.Stat(certFile); err != nil {
+			// -install is a no-op once mkcert's CA is trusted
+			exec.Command("mkcert", "-install").Run()
+			out, err := exec.Command("mkcert", "-cert-file", certFile, "-key-file", keyFile, host).CombinedOutput()
+			if err != nil {
+				return "", fmt.Errorf("mkcert: %v\n%s", err, out)
+			}
+		}
+		tlsDirective = fmt.Sprintf("tls /certs/%s.pem /certs/%s-key.pem", host, host)
+		useMkcert = true
+	}
+
+	caddyfile := fmt.Sprintf(`{
+	auto_https disable_redirects
+}
+
+https://%s {
+	%s
+	reverse_proxy host.containers.internal:%d
+}
+`, host, tlsDirective, upstreamPort)
+	caddyfilePath := filepath.Join(certDir, appName+".Caddyfile")
+	if err := os.WriteFile(caddyfilePath, []byte(caddyfile), 0644); err != nil {
+		return "", fmt.Errorf("write Caddyfile: %w", err)
+	}
+
+	exec.Command("podman", "rm", "-f", container).Run()
+	runArgs := []string{"run", "-d", "--name", container,
+		"-p", fmt.Sprintf("%d:443", httpsPort),
+		"-v", caddyfilePath + ":/etc/caddy/Caddyfile:ro",
+		"-v", certDir + ":/certs:ro",
+		"-v", httpsCAVolume + ":/data",
+		httpsProxyImage,
+	}
+	if out, err := exec.Command("podman", runArgs...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("start HTTPS proxy: %v\n%s", err, out)
+	}
+
+	if !useMkcert {
+		trustCaddyCA(container, certDir)
+	}
+	return container, nil
+}
+
+// trustCaddyCA copies Caddy's local root certificate out of the proxy
+// container and explains how to trust it (once per machine)
+func trustCaddyCA(container, certDir string) {
+	rootPath := filepath.Join(certDir, "basepod-local-ca.crt")
+	if _, err := os.Stat(rootPath); err == nil {
+		return
+	}
+
+	// Caddy creates the CA when it issues the first certificate
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = exec.Command("podman", "cp", container+":/data/caddy/pki/authorities/local/root.crt", rootPath).Run(); err == nil {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not read the local CA certificate; the browser will show a certificate warning.")
+		return
+	}
+
+	fmt.Printf("\nLocal CA certificate: %s\n", rootPath)
+	fmt.Println("Trust it once so browsers accept https://*.localhost (or install mkcert and rerun):")
+	switch runtime.GOOS {
+	case "darwin":
+		fmt.Printf("  sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain %s\n", rootPath)
+	case "linux":
+		fmt.Printf("  sudo cp %s /usr/local/share/ca-certificates/ && sudo update-ca-certificates\n", rootPath)
+	default:
+		fmt.Println("  Import it into your system or browser certificate store as a trusted root.")
+	}
+}
+
+// stopHTTPSProxy removes the HTTPS proxy container
+func stopHTTPSProxy(container string) {
+	exec.Command("podman", "rm", "-f", container).Run()
+}
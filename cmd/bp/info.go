@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// cmdAppInfo shows an app's details with the README and app.manifest.yaml
+// from its latest deploy
+func cmdAppInfo(name string) {
+	a := fetchApp(name)
+
+	fmt.Printf("%s\n", a.Name)
+	if a.Docs != nil && a.Docs.Description != "" {
+		fmt.Printf("  %s\n", a.Docs.Description)
+	}
+	fmt.Println()
+	fmt.Printf("  Status: %s\n", a.Status)
+	if a.Domain != "" {
+		fmt.Printf("  URL: https://%s\n", a.Domain)
+	}
+	if a.Image != "" {
+		fmt.Printf("  Image: %s\n", a.Image)
+	}
+	if u := a.TemplateUpdate; u != nil {
+		fmt.Printf("  Template update available: %s %s -> %s (bp template upgrade %s)\n", u.Template, u.CurrentVersion, u.Version, a.Name)
+	}
+
+	resp, err := apiRequest("GET", "/api/apps/"+url.PathEscape(name)+"/docs", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		fmt.Println("\nNo docs yet. Add a README.md or app.manifest.yaml to the source and redeploy.")
+		return
+	}
+	var result struct {
+		Docs   app.AppDocs     `json:"docs"`
+		EnvSet map[string]bool `json:"env_set"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	docs := result.Docs
+
+	if docs.HealthEndpoint != "" {
+		fmt.Printf("  Health endpoint: %s\n", docs.HealthEndpoint)
+	}
+	if len(docs.Links) > 0 {
+		fmt.Println("\nLinks:")
+		for _, link := range docs.Links {
+			title := link.Title
+			if title == "" {
+				title = link.URL
+			}
+			fmt.Printf("  %s: %s\n", title, link.URL)
+		}
+	}
+	if len(docs.Env) > 0 {
+		fmt.Println("\nEnvironment:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tSET\tREQUIRED\tDEFAULT\tDESCRIPTION")
+		for _, e := range docs.Env {
+			set, required, def := "no", "", "-"
+			if result.EnvSet[e.Name] {
+				set = "yes"
+			}
+			if e.Required {
+				required = "yes"
+			}
+			if e.Default != "" {
+				def = e.Default
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", e.Name, set, required, def, e.Description)
+		}
+		w.Flush()
+	}
+	if readme := strings.TrimSpace(docs.Readme); readme != "" {
+		fmt.Printf("\n%s\n", readme)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// JobConfig is a job in the jobs: section of basepod.yaml: a command run on
+// schedule in a one-off container from the app image
+type JobConfig struct {
+	Schedule string `yaml:"schedule" json:"schedule"` // e.g. "0 3 * * *" or "@daily"
+	Command  string `yaml:"command" json:"command"`
+}
+
+// jobInfo is the subset of a scheduled job shown by `bp jobs`
+type jobInfo struct {
+	ID         string     `json:"id"`
+	AppName    string     `json:"app_name"`
+	Name       string     `json:"name"`
+	Schedule   string     `json:"schedule"`
+	Action     string     `json:"action"`
+	Command    string     `json:"command"`
+	Source     string     `json:"source"`
+	Enabled    bool       `json:"enabled"`
+	NextRun    *time.Time `json:"next_run"`
+	LastStatus string     `json:"last_status"`
+}
+
+// cmdJobs lists scheduled jobs across apps, runs them and shows their history
+func cmdJobs(args []string) {
+	usage := `Usage:
+  bp jobs [app]                 List scheduled jobs, of every app or one
+  bp jobs run <app> <job>       Run a job now
+  bp jobs history <app> <job>   Show a job's recent runs
+
+Define jobs in basepod.yaml; each deploy adds, updates and removes them:
+  jobs:
+    migrate:
+      schedule: "0 3 * * *"
+      command: ./bin/migrate up`
+
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help" || args[0] == "help") {
+		fmt.Println(usage)
+		return
+	}
+
+	switch {
+	case len(args) > 0 && args[0] == "run":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp jobs run <app> <job>")
+			os.Exit(1)
+		}
+		job := findJob(args[1], args[2])
+		resp, err := apiRequest("POST", fmt.Sprintf("/api/apps/%s/cron/%s/run", url.PathEscape(args[1]), job.ID), nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+			os.Exit(1)
+		}
+		fmt.Printf("Started %s on %s. Follow it with: bp jobs history %s %s\n", job.Name, args[1], args[1], job.Name)
+
+	case len(args) > 0 && args[0] == "history":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp jobs history <app> <job>")
+			os.Exit(1)
+		}
+		job := findJob(args[1], args[2])
+		resp, err := apiRequest("GET", fmt.Sprintf("/api/apps/%s/cron/%s/executions", url.PathEscape(args[1]), job.ID), nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+			os.Exit(1)
+		}
+		var result struct {
+			Executions []struct {
+				StartedAt time.Time  `json:"started_at"`
+				EndedAt   *time.Time `json:"ended_at"`
+				Status    string     `json:"status"`
+				Output    string     `json:"output"`
+				ExitCode  int        `json:"exit_code"`
+			} `json:"executions"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		if len(result.Executions) == 0 {
+			fmt.Printf("%s has not run yet.\n", job.Name)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "STARTED\tDURATION\tSTATUS\tEXIT\n")
+		for _, e := range result.Executions {
+			duration := "-"
+			if e.EndedAt != nil {
+				duration = e.EndedAt.Sub(e.StartedAt).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", e.StartedAt.Local().Format("Mon Jan 2 15:04"), duration, e.Status, e.ExitCode)
+		}
+		w.Flush()
+
+		// Output of the latest run, which is what's usually wanted
+		if last := strings.TrimSpace(result.Executions[0].Output); last != "" {
+			fmt.Printf("\nOutput of the latest run:\n%s\n", last)
+		}
+
+	default:
+		path := "/api/jobs"
+		if len(args) > 0 {
+			path += "?app=" + url.QueryEscape(args[0])
+		}
+		jobs, err := fetchJobs(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No scheduled jobs.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "APP\tJOB\tSCHEDULE\tKIND\tNEXT RUN\tLAST STATUS\n")
+		for _, job := range jobs {
+			next := "-"
+			if job.NextRun != nil && job.Enabled {
+				next = job.NextRun.Local().Format("Mon Jan 2 15:04")
+			} else if !job.Enabled {
+				next = "disabled"
+			}
+			kind := job.Action
+			if job.Source == "config" {
+				kind += " (basepod.yaml)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", job.AppName, job.Name, job.Schedule, kind, next, job.LastStatus)
+		}
+		w.Flush()
+	}
+}
+
+// fetchJobs gets scheduled jobs from the server
+func fetchJobs(path string) ([]jobInfo, error) {
+	resp, err := apiRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(respBody)))
+	}
+	var result struct {
+		Jobs []jobInfo `json:"jobs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Jobs, nil
+}
+
+// findJob looks up an app's job by name or ID prefix, exiting if there is
+// no such job
+func findJob(appName, nameOrID string) jobInfo {
+	jobs, err := fetchJobs("/api/jobs?app=" + url.QueryEscape(appName))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, job := range jobs {
+		if job.Name == nameOrID {
+			return job
+		}
+	}
+	for _, job := range jobs {
+		if strings.HasPrefix(job.ID, nameOrID) {
+			return job
+		}
+	}
+	fmt.Fprintf(os.Stderr, "No job %q on %s. List jobs with: bp jobs %s\n", nameOrID, appName, appName)
+	os.Exit(1)
+	return jobInfo{}
+}
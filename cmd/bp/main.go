@@ -11,9 +11,13 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"text/tabwriter"
@@ -30,8 +34,9 @@ var (
 
 // ServerConfig holds configuration for a single server
 type ServerConfig struct {
-	URL   string `yaml:"url"`
-	Token string `yaml:"token,omitempty"`
+	URL     string `yaml:"url"`
+	Token   string `yaml:"token,omitempty"`
+	CertPin string `yaml:"cert_pin,omitempty"` // Public key pin of a self-signed certificate trusted on login
 }
 
 // CLIConfig holds CLI configuration with multiple servers
@@ -83,14 +88,36 @@ func main() {
 		cmdApps(args)
 	case "create":
 		cmdCreate(args)
+	case "clone":
+		cmdClone(args)
+	case "canary":
+		cmdCanary(args)
+	case "promote":
+		cmdPromote(args)
+	case "abort":
+		cmdAbort(args)
+	case "protect":
+		cmdProtect(args)
+	case "purge":
+		cmdPurge(args)
 	case "start":
 		cmdStart(args)
 	case "stop":
 		cmdStop(args)
 	case "restart":
 		cmdRestart(args)
+	case "scale":
+		cmdScale(args)
 	case "logs":
 		cmdLogs(args)
+	case "watch":
+		cmdWatch(args)
+	case "exec":
+		cmdExec(args)
+	case "cp":
+		cmdCp(args)
+	case "run-task":
+		cmdRunTask(args)
 	case "delete", "rm":
 		cmdDelete(args)
 	// Template commands
@@ -105,15 +132,37 @@ func main() {
 		cmdModel(args)
 	case "chat":
 		cmdChat(args)
+	case "transcribe":
+		cmdTranscribe(args)
 	// Webhook commands
 	case "webhook":
 		cmdWebhook(args)
+	case "git":
+		cmdGit(args)
+	case "hooks", "hook":
+		cmdHooks(args)
+	case "freeze":
+		cmdFreeze(args)
+	case "builds", "build":
+		cmdBuilds(args)
 	// Rollback
 	case "rollback":
 		cmdRollback(args)
+	case "releases":
+		cmdReleases(args)
+	case "snapshots", "snapshot":
+		cmdSnapshots(args)
 	// Cron job commands
 	case "cron":
 		cmdCron(args)
+	case "jobs":
+		cmdJobs(args)
+	case "schedule":
+		cmdSchedule(args)
+	case "domains", "domain":
+		cmdDomains(args)
+	case "route", "routes":
+		cmdRoute(args)
 	// Activity log
 	case "activity":
 		cmdActivity(args)
@@ -123,30 +172,73 @@ func main() {
 	// Deploy tokens
 	case "token", "tokens":
 		cmdTokens(args)
+	// User accounts
+	case "users", "user":
+		cmdUsers(args)
+	case "2fa":
+		cmd2FA(args)
+	case "sessions", "session":
+		cmdSessions(args)
+	case "lockouts":
+		cmdLockouts(args)
 	// Metrics
 	case "metrics":
 		cmdMetrics(args)
+	case "top":
+		cmdTop(args)
 	// Database
 	case "db":
 		cmdDB(args)
+	case "addon", "addons":
+		cmdAddon(args)
 	// AI commands
 	case "analyze":
 		cmdAnalyze(args)
 	case "ai":
 		cmdAI(args)
+	case "mcp":
+		cmdMCP(args)
 	// Health check commands
 	case "health":
 		cmdHealth(args)
+	case "seo":
+		cmdSEO(args)
+	case "upstream":
+		cmdUpstream(args)
+	case "websocket":
+		cmdWebSocket(args)
+	case "traffic":
+		cmdTraffic(args)
+	case "uptime":
+		cmdUptime(args)
 	// Environment commands
 	case "env":
 		cmdEnv(args)
+	case "secret", "secrets":
+		cmdSecret(args)
+	case "diff":
+		cmdDiff(args)
+	case "promote-config":
+		cmdPromoteConfig(args)
 	// System commands
 	case "info":
 		cmdInfo(args)
 	case "status":
 		cmdStatus(args)
+	case "server":
+		cmdServer(args)
+	case "smoke-test":
+		cmdSmokeTest(args)
 	case "prune":
 		cmdPrune(args)
+	case "registry":
+		cmdRegistry(args)
+	case "volumes", "volume":
+		cmdVolumes(args)
+	case "export":
+		cmdExport(args)
+	case "migrate":
+		cmdMigrate(args)
 	case "upgrade":
 		cmdUpgrade(args)
 	case "backup":
@@ -167,64 +259,149 @@ Usage:
   bp <command> [arguments] [flags]
 
 Connection Commands:
-  login <server>          Connect to a Basepod server
+  login <server> [--email <email>]  Connect to a Basepod server (as a user with --email)
+  login <server> --pin <sha256:...>  Trust the server's self-signed certificate by the pin it logs
   logout [name]           Disconnect from server
   context [name]          List or switch server contexts
 
 Project Commands:
-  init                    Initialize basepod.yaml config
+  init [--preset <name>]  Initialize basepod.yaml config (and a Dockerfile from a build preset)
+  init --from-compose [file]  Convert docker-compose.yml services into basepod.yaml
+  export k8s [app]        Print Kubernetes manifests for an app or basepod.yaml services (-n <ns>, -o <file>)
   run [path]              Run app locally with Podman
   deploy [path]           Deploy app (local, image, or git)
+  deploy --override <reason>  Deploy during a deploy freeze (recorded in the activity log)
+  deploy --canary <percent>  Run the release next to the current one with a share of requests
+  deploy --full-upload    Send the whole source instead of only files the server doesn't have
+  deploy --recreate       Stop the old container before starting the new one from now on, instead of a blue/green switch
     --env <name>          Load basepod.<name>.yaml overlay
     --staging             Shorthand for --env staging
     --production          Shorthand for --env production
+  freeze                  List deploy freeze windows and the active one
+  freeze add <name> [--days fri --start 16:00] [--from 12-24 --until 01-02]  Add a freeze window
+  freeze rm <id>          Remove a freeze window
+  builds                  List running and queued builds
+  builds cancel <id>      Cancel a queued or running build
 
 App Commands:
   apps                    List all apps
   create <name>           Create a new app
+  clone <app> <new-name>  Copy an app's config, env and volumes to a new app (--copy-volumes, --with-secrets)
   start <name>            Start an app
   stop <name>             Stop an app
   restart <name>          Restart an app
-  logs <name>             View app logs
+  scale <name> [--replicas 3] [--memory 512m] [--cpus 0.5]  Change replicas or resource limits
+  logs <name> [-f]        View app logs (-f streams new lines)
+  watch [name] [--json]   Follow status changes, deploys, health and alerts live
+  exec <name> [command]   Run a command, or a shell, in an app's container
+  cp <name>:<path> <local>  Copy files out of an app's container (or the reverse)
+  run-task <name> -- <cmd>  Run a one-off command in a new container from the app's image
   delete <name>           Delete an app
   env <name>              Show environment variables
-  env set <name> K=V...   Set environment variables
-  env unset <name> KEY... Remove environment variables
+  env get <name> KEY      Print one environment variable
+  env set <name> K=V... [--restart]   Set environment variables
+  env unset <name> KEY... [--restart] Remove environment variables
+  secret list <name>      List encrypted secrets (names only)
+  secret set <name> KEY [--restart]   Set a secret (value read from stdin)
+  secret unset <name> KEY [--restart] Remove a secret
+  diff <name> --context <a> --context <b>  Compare an app between two servers
+  promote-config <name> --from <a> --to <b>  Copy image/env/resources from one server to another
   health <name>           Show app health status
   health check <name>     Trigger immediate health check
   health enable <name>    Enable health checks with defaults
   health disable <name>   Disable health checks
+  uptime <name> [--period 7d] [--check]  Show availability of the app's public URL
+  protect <name> --user <u> [--pass <p>]  Ask for a password on the app's domains (--off to remove)
+  purge <name>            Clear a static site's cached copies so visitors get fresh files
+  seo <name>              Show X-Robots-Tag and robots.txt per domain
+  seo <name> noindex on|off|auto  Hide the app from search engines
+  upstream <name> http|h2c|grpc  Set how the proxy talks to the app
+  upstream <name> test    Check the app answers directly and through its domain
+  websocket <name> [idle <secs>|off] [drain <secs>|off]  Tune long-lived connections
+  traffic <name> [--period 24h]  Show requests, status codes, bandwidth, top paths and visitors
+  traffic export <name> [--anonymized] [--csv]  Export access logs
+  traffic privacy <name> anonymize|keep|default  Mask client IPs in stored access logs
   webhook <name>          Show webhook config
   webhook setup <name> <url>  Enable webhook for git URL
   webhook disable <name>  Disable webhook
   webhook deliveries <name>  Show recent deliveries
+  git remote <name>       Add a "basepod" git remote: git push basepod main deploys
+  hooks create <name> <url>  Create a GitHub/GitLab push webhook for a repo
   rollback <name>         Rollback to previous deploy
+  canary <name> <image> [--weight 10]  Send a share of requests to a new image (--weight N to change it)
+  promote <name>          Make an app's canary its current release
+  abort <name>            Remove an app's canary, sending all requests back to the current release
+  releases <name>         List deploys with image size and layer history
+  snapshots <name>        List pre-deploy snapshots of an app's volumes
+  snapshots enable <name> [--keep N] [--dump <cmd>]  Snapshot volumes before each deploy
+  snapshots restore <name> <id> [--rollback]  Restore volumes (and with --rollback the image)
   cron <name>             List cron jobs for an app
   cron add <name>         Add a cron job
   cron rm <name> <id>     Delete a cron job
   cron run <name> <id>    Run a cron job now
+  jobs [name]             List scheduled jobs (defined under jobs: in basepod.yaml)
+  jobs run <name> <job>   Run a job now in a one-off container
+  jobs history <name> <job>  Show a job's recent runs
+  schedule <name>         List restart/redeploy schedules
+  schedule add <name> <restart|redeploy> <cron>  Add a schedule
+  schedule rm <name> <id> Remove a schedule
+  domains list <name>     Show an app's domain and aliases
+  domains add <name> <domain>  Serve an app on another domain too
+  domains rm <name> <domain>  Remove an alias domain
+  domains migrate <name> <old> <new>  Move to a new domain, 301 from the old one
+  domains redirects <name>  Show old domains and traffic still reaching them
+  domains unredirect <name> <old>  Stop redirecting an old domain
+  route add <domain> <path> <app>  Send a path on a shared domain to an app
+  route list <domain>     Show a domain's path and header routes
+  route rm <domain> <id|path>  Remove a route
   activity [name]         Show activity log
   metrics <name>          Show app resource metrics
+  top                     Live CPU, memory, network and disk I/O of running apps
   db link <app> <db>      Link database to app (inject DATABASE_URL)
   db info <name>          Show database connection info
+  addon create <type> [--attach <app>]  Create a Postgres, MySQL or Redis addon
+  addon list              List addons and the apps they're attached to
+  addon attach <addon> <app> [--as VAR]  Set an addon's URL in an app's env
+  addon detach <addon> <app>  Remove an addon's URL from an app's env
+  addon destroy <addon> [--keep-data]  Detach and remove an addon
 
 AI Commands:
   ai                      Interactive AI assistant
   analyze <repo-url>      Analyze repo and suggest deploy config
+  mcp [--read-only]       Run an MCP server over stdio for AI agents
+  transcribe <file>       Transcribe audio with a local Whisper model
 
 Notification & CI/CD Commands:
   notify list             List notification hooks
   notify add              Add a notification hook
   notify rm <id>          Remove a notification hook
   notify test <id>        Test a notification hook
-  tokens                  List deploy tokens
-  token create <name>     Create a deploy token
-  token rm <id>           Delete a deploy token
+  tokens                  List deploy tokens with when each was last used
+  token create <name>     Create a deploy token (--scope deploy --app <name>, --expires 90d)
+  token rm <id|name>      Revoke a deploy token
+
+User Commands:
+  users                   List users
+  users add <email>       Add a user (--role admin|deployer|viewer, --apps a,b)
+  users invite <email>    Invite a user to set their own password
+  users role <email> <r>  Change a user's role
+  users apps <email>      Show or set (--set a,b) the apps a user can reach
+  users rm <email>        Delete a user
+  users reset-2fa <email> Turn off a user's two-factor auth
+  2fa                     Show two-factor auth status for your account
+  2fa enable              Turn on two-factor auth with an authenticator app
+  2fa disable             Turn off two-factor auth
+  2fa recovery-codes      Replace your recovery codes
+  sessions [--all]        List login sessions
+  sessions revoke <id>    Sign out a session (--others: all but this one)
+  lockouts                List IPs locked out after failed logins
+  lockouts clear [ip]     Lift a lockout, or all of them
 
 Template Commands:
   templates               List available templates
   template deploy <name>  Deploy a template
   template export <name>  Export app config as template
+  template upgrade <app>  Upgrade an app to its template's recommended version
 
 Model Commands (LLM):
   models                  List LLM models
@@ -236,14 +413,31 @@ Model Commands (LLM):
 
 System Commands:
   info                    Show server info
-  status                  Show detailed status
-  prune                   Clean unused resources
+  info <name>             Show an app's README, links and documented env vars
+  status [app]            Show server status, or an app's container, usage, last deploy and events
+  server stats            Show daemon memory, goroutines, open files and AI processes
+  server profile --cpu 30s  Capture a CPU profile (requires debug.enabled)
+  smoke-test              Deploy a throwaway app and check DNS, TLS, routing, logs and exec
+  prune                   Clean unused resources (keeps app volumes and recent releases' images)
+  prune --dry-run         Show what would be removed and what is kept
+  prune --aggressive      Also remove pulled images and volumes no app uses
+  registry                List the images builds pushed to the built-in registry
+  registry gc [--dry-run] Delete registry tags no deployment uses and free their space
+  registry rm <app>:<tag> Delete one registry tag
+  volumes                 List volumes with their app, size and whether they're orphaned
+  volumes create <name>   Create a volume (--app <app> to attach it to an app)
+  volumes inspect <name>  Show a volume's app, size, labels and the containers using it
+  volumes rm <name>       Remove a volume (--force for an app's volume)
+  migrate <from> <to>     Copy apps, volumes and images between servers, then guide the DNS cutover
   upgrade                 Update Basepod
   backup                  Create or list backups
   backup list             List all backups
   backup create           Create a new backup
   backup download <id>    Download a backup
+  backup upload <file>    Upload a backup file, e.g. to move to a new server (--restore)
   backup delete <id>      Delete a backup
+  backup create --upload  Create a backup and upload it to a backup target
+  backup restore <id> --from <target>  Restore a backup stored on a target
   completion <shell>      Generate shell completion (bash, zsh, fish)
 
 Options:
@@ -256,11 +450,16 @@ Examples:
   bp run                           # Run app locally with Podman
   bp run -d                        # Run in background (detached)
   bp run -p 8080                   # Run on custom port
+  bp run --only api,db             # Start some services (plus their dependencies)
+  bp run --profile minimal         # Start a service profile from basepod.yaml
+  bp run --https                   # Also serve https://<name>.localhost:8443 with a trusted cert
+  bp run --mount-src               # Mount source and run dev_command for live reload
   bp deploy                        # Deploy from local source
   bp deploy --staging              # Deploy with basepod.staging.yaml
   bp deploy --env preview          # Deploy with basepod.preview.yaml
   bp deploy --image nginx:latest   # Deploy Docker image
   bp template deploy postgres
+  bp template deploy openwebui --model mlx-community/Llama-3.2-3B-Instruct-4bit
   bp env myapp                    # Show env vars
   bp env set myapp DB_HOST=localhost
   bp env unset myapp SECRET_KEY
@@ -293,6 +492,7 @@ func loadConfig() (*CLIConfig, error) {
 	if cfg.Servers == nil {
 		cfg.Servers = make(map[string]ServerConfig)
 	}
+	trustPinnedCerts(&cfg)
 
 	return &cfg, nil
 }
@@ -341,12 +541,50 @@ func getClient() (*http.Client, string, error) {
 
 // apiRequest makes an API request
 func apiRequest(method, path string, body interface{}) (*http.Response, error) {
-	client, server, err := getClient()
+	return apiRequestContext("", method, path, body)
+}
+
+// apiRequestContext makes an API request against a named context (empty = current)
+func apiRequestContext(contextName, method, path string, body interface{}) (*http.Response, error) {
+	req, err := newAPIRequest(contextName, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Minute, // Longer timeout for uploads
+	}
+	return client.Do(req)
+}
+
+// apiStreamRequest makes a GET request whose response is read until the server
+// or the user ends it, so it has no client timeout
+func apiStreamRequest(path string) (*http.Response, error) {
+	req, err := newAPIRequest("", "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// newAPIRequest builds an authenticated request against a named context (empty = current)
+func newAPIRequest(contextName, method, path string, body interface{}) (*http.Request, error) {
+	cfg, err := loadConfig()
 	if err != nil {
 		return nil, err
 	}
+	if contextName == "" {
+		contextName = cfg.CurrentContext
+	}
+	server, ok := cfg.Servers[contextName]
+	if !ok {
+		if contextName == "" {
+			return nil, fmt.Errorf("not logged in. Run: bp login <server>")
+		}
+		return nil, fmt.Errorf("context '%s' not found. Run: bp context", contextName)
+	}
 
-	url := strings.TrimSuffix(server, "/") + path
+	url := strings.TrimSuffix(server.URL, "/") + path
 
 	var bodyReader io.Reader
 	if body != nil {
@@ -366,25 +604,35 @@ func apiRequest(method, path string, body interface{}) (*http.Response, error) {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	cfg, _ := loadConfig()
-	if server, _, err := getCurrentServer(cfg); err == nil && server.Token != "" {
+	if server.Token != "" {
 		req.Header.Set("Authorization", "Bearer "+server.Token)
 	}
 
-	return client.Do(req)
+	return req, nil
 }
 
 func cmdLogin(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: bp login <server>")
+		fmt.Fprintln(os.Stderr, "Usage: bp login <server> [--email <email>] [--pin <sha256:...>]")
 		os.Exit(1)
 	}
 
 	server := args[0]
+	email := flagValue(args[1:], "--email")
 	if !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
 		server = "https://" + server
 	}
 
+	// A self-signed certificate is trusted by its pin, from --pin or once
+	// the user confirms it
+	pin, err := loginCertPin(server, flagValue(args[1:], "--pin"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to server: %v\n", err)
+		os.Exit(1)
+	}
+	serverCfg := ServerConfig{URL: server, CertPin: pin}
+	trustPinnedCerts(&CLIConfig{Servers: map[string]ServerConfig{"": serverCfg}})
+
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	// Test connection
@@ -427,8 +675,6 @@ func cmdLogin(args []string) {
 	contextName := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
 	contextName = strings.Split(contextName, "/")[0] // Remove any path
 
-	serverCfg := ServerConfig{URL: server}
-
 	// Auth is required if password is configured (needsSetup=false) and not authenticated
 	authRequired := !authStatus.NeedsSetup && !authStatus.Authenticated
 
@@ -446,30 +692,21 @@ func cmdLogin(args []string) {
 
 		// Authenticate
 		loginReq := map[string]string{"password": string(passwordBytes)}
-		loginBody, _ := json.Marshal(loginReq)
-		resp, err = client.Post(server+"/api/auth/login", "application/json", bytes.NewReader(loginBody))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to authenticate: %v\n", err)
-			os.Exit(1)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusUnauthorized {
-			fmt.Fprintln(os.Stderr, "Invalid password")
-			os.Exit(1)
-		}
-		if resp.StatusCode != http.StatusOK {
-			fmt.Fprintf(os.Stderr, "Authentication failed: status %d\n", resp.StatusCode)
-			os.Exit(1)
-		}
-
-		var loginResp struct {
-			Token     string    `json:"token"`
-			ExpiresAt time.Time `json:"expiresAt"`
+		if email != "" {
+			loginReq["email"] = email
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to parse login response: %v\n", err)
-			os.Exit(1)
+		loginResp, twoFactorRequired := postLogin(client, server, loginReq)
+		if twoFactorRequired {
+			// Password was right; ask for the authenticator code and retry
+			fmt.Print("Two-factor code (or recovery code): ")
+			reader := bufio.NewReader(os.Stdin)
+			code, _ := reader.ReadString('\n')
+			loginReq["totp_code"] = strings.TrimSpace(code)
+			loginResp, twoFactorRequired = postLogin(client, server, loginReq)
+			if twoFactorRequired {
+				fmt.Fprintln(os.Stderr, "Invalid two-factor code")
+				os.Exit(1)
+			}
 		}
 
 		serverCfg.Token = loginResp.Token
@@ -487,6 +724,46 @@ func cmdLogin(args []string) {
 	fmt.Printf("Logged in to %s (context: %s)\n", server, contextName)
 }
 
+type loginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// postLogin sends a login request and reports whether the server wants a
+// two-factor code; other failures exit
+func postLogin(client *http.Client, server string, loginReq map[string]string) (loginResponse, bool) {
+	loginBody, _ := json.Marshal(loginReq)
+	resp, err := client.Post(server+"/api/auth/login", "application/json", bytes.NewReader(loginBody))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to authenticate: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		var errResp struct {
+			TwoFactorRequired bool `json:"two_factor_required"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.TwoFactorRequired {
+			return loginResponse{}, true
+		}
+		fmt.Fprintln(os.Stderr, "Invalid password")
+		os.Exit(1)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Authentication failed: status %d\n", resp.StatusCode)
+		os.Exit(1)
+	}
+
+	var loginResp loginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse login response: %v\n", err)
+		os.Exit(1)
+	}
+	return loginResp, false
+}
+
 func cmdLogout(args []string) {
 	cfg, err := loadConfig()
 	if err != nil {
@@ -747,7 +1024,7 @@ func cmdApps(args []string) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tTYPE\tSTATUS\tDOMAIN\tALIASES\tIMAGE")
+	fmt.Fprintln(w, "NAME\tTYPE\tSTATUS\tUPTIME (24H)\tDOMAIN\tALIASES\tIMAGE")
 	for _, a := range result.Apps {
 		aliases := ""
 		if len(a.Aliases) > 0 {
@@ -757,14 +1034,14 @@ func cmdApps(args []string) {
 		if appType == "" {
 			appType = "container"
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.Name, appType, a.Status, a.Domain, aliases, a.Image)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.Name, appType, a.Status, formatUptime(a.Uptime), a.Domain, aliases, a.Image)
 	}
 	w.Flush()
 }
 
 func cmdCreate(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: bp create <name> [--domain <domain>] [--port <port>]")
+		fmt.Fprintln(os.Stderr, "Usage: bp create <name> [--domain <domain>] [--port <port>] [--memory 512m] [--cpus 0.5]")
 		os.Exit(1)
 	}
 
@@ -792,6 +1069,26 @@ func cmdCreate(args []string) {
 				req.Image = args[i+1]
 				i++
 			}
+		case "--memory", "-m":
+			if i+1 < len(args) {
+				memory, err := app.ParseMemory(args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				req.Memory = memory
+				i++
+			}
+		case "--cpus":
+			if i+1 < len(args) {
+				cpus, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: invalid --cpus %q\n", args[i+1])
+					os.Exit(1)
+				}
+				req.CPUs = cpus
+				i++
+			}
 		}
 	}
 
@@ -830,21 +1127,61 @@ type AppConfig struct {
 	Port      int                       `yaml:"port,omitempty"`
 	Public    string                    `yaml:"public,omitempty"`    // Public directory for static sites
 	Build     BuildConfig               `yaml:"build,omitempty"`
+	DevCommand string                   `yaml:"dev_command,omitempty"` // Command for `bp run --mount-src` (e.g., "npm run dev")
 	Env       map[string]string         `yaml:"env,omitempty"`
-	Volumes   []string                  `yaml:"volumes,omitempty"`
+	EnvFile   []string                  `yaml:"env_file,omitempty"` // Env files for `bp run` (default: .env, .env.local)
+	Volumes   []string                  `yaml:"volumes,omitempty"` // Named volumes (name:/path[:ro]) the server keeps across deploys
 	Processes []ProcessConfig           `yaml:"processes,omitempty"` // Multiple processes for multi-service apps
 	Services  map[string]*ServiceConfig `yaml:"services,omitempty"`  // Multiple services (docker-compose style)
+	Profiles  map[string][]string       `yaml:"profiles,omitempty"`  // Named service subsets for `bp run --profile`
+	SEO       *SEOConfig                `yaml:"seo,omitempty" json:"seo,omitempty"` // Search engine controls
+	Protocol  string                    `yaml:"protocol,omitempty" json:"protocol,omitempty"` // Upstream protocol: http, h2c or grpc
+	Jobs      map[string]JobConfig      `yaml:"jobs,omitempty" json:"jobs,omitempty"`         // Scheduled one-off containers (bp jobs)
+	HealthCheck string                  `yaml:"health_check,omitempty" json:"health_check,omitempty"` // Health endpoint enabled on first deploy
+	Verify    *VerifyConfig             `yaml:"verify,omitempty" json:"verify,omitempty"`     // Smoke check of each new release
+	Routing     *app.RoutingConfig        `yaml:"routing,omitempty" json:"routing,omitempty"`           // Redirects, headers, basic auth, gzip and IP allowlists in the proxy
+	Static      *app.StaticConfig         `yaml:"static,omitempty" json:"static,omitempty"`             // SPA fallback, 404 page, redirects and headers of a static site
+	Recreate    *bool                     `yaml:"recreate,omitempty" json:"recreate,omitempty"`         // Stop the old container before starting the new one instead of a blue/green switch
 	// Git info (populated at deploy time, not in yaml)
 	GitCommit  string `yaml:"-" json:"git_commit,omitempty"`
 	GitMessage string `yaml:"-" json:"git_message,omitempty"`
 	GitBranch  string `yaml:"-" json:"git_branch,omitempty"`
+	// Lockfiles with uncommitted changes (checked when build.require_lockfile is set)
+	DirtyLockfiles []string `yaml:"-" json:"dirty_lockfiles,omitempty"`
+	// Reason given with --override to deploy during a deploy freeze
+	FreezeOverride string `yaml:"-" json:"freeze_override,omitempty"`
+	// Percent of requests given with --canary to run the release as a canary
+	Canary int `yaml:"-" json:"canary,omitempty"`
 }
 
 // BuildConfig contains build configuration
 type BuildConfig struct {
 	Dockerfile string `yaml:"dockerfile,omitempty"`
 	Context    string `yaml:"context,omitempty"`
-	Command    string `yaml:"command,omitempty"` // Local build command (e.g., "npm run build")
+	Command    string `yaml:"command,omitempty"`                        // Local build command (e.g., "npm run build")
+	Preset     string `yaml:"preset,omitempty" json:"preset,omitempty"` // Server build preset a missing Dockerfile is generated from
+	// Fail deploys when lockfiles are missing or have uncommitted changes
+	RequireLockfile bool `yaml:"require_lockfile,omitempty" json:"require_lockfile,omitempty"`
+}
+
+// VerifyConfig is the verify: section of basepod.yaml. After each deploy the
+// server requests path from the new release and/or runs command in a
+// one-off container from its image; a failure marks the release failed and,
+// with rollback, brings the previous one back.
+type VerifyConfig struct {
+	Path     string `yaml:"path,omitempty" json:"path,omitempty"`         // e.g. "/healthz"
+	Status   int    `yaml:"status,omitempty" json:"status,omitempty"`     // Expected status (default: 200)
+	Body     string `yaml:"body,omitempty" json:"body,omitempty"`         // Text the response must contain
+	Command  string `yaml:"command,omitempty" json:"command,omitempty"`   // Must exit 0
+	Timeout  int    `yaml:"timeout,omitempty" json:"timeout,omitempty"`   // Seconds (default: 60)
+	Rollback bool   `yaml:"rollback,omitempty" json:"rollback,omitempty"` // Revert to the previous release on failure
+}
+
+// SEOConfig controls how search engines see the app. Unset fields default to
+// hiding preview and staging domains.
+type SEOConfig struct {
+	NoIndex   *bool  `yaml:"noindex,omitempty" json:"noindex,omitempty"`       // Send X-Robots-Tag: noindex, nofollow
+	RobotsTxt string `yaml:"robots_txt,omitempty" json:"robots_txt,omitempty"` // "app", "disallow" or the robots.txt contents to serve
 }
 
 // ProcessConfig defines a process in a multi-service app
@@ -856,15 +1193,26 @@ type ProcessConfig struct {
 
 // ServiceConfig defines a service in a multi-service app
 type ServiceConfig struct {
-	Type       string            `yaml:"type,omitempty"`       // "static", "container", "go", "python"
-	Image      string            `yaml:"image,omitempty"`      // Docker image to use
-	Build      ServiceBuild      `yaml:"build,omitempty"`      // Build configuration
-	Port       int               `yaml:"port,omitempty"`       // Internal port
-	Public     string            `yaml:"public,omitempty"`     // Public directory for static
-	Command    string            `yaml:"command,omitempty"`    // Command to run
-	Env        map[string]string `yaml:"env,omitempty"`        // Environment variables
-	Volumes    []string          `yaml:"volumes,omitempty"`    // Volume mounts
-	DependsOn  []string          `yaml:"depends_on,omitempty"` // Service dependencies
+	Type       string            `yaml:"type,omitempty"`                                   // "static", "container", "go", "python"
+	Image      string            `yaml:"image,omitempty"`                                  // Docker image to use
+	Build      ServiceBuild      `yaml:"build,omitempty"`                                  // Build configuration
+	Port       int               `yaml:"port,omitempty"`                                   // Internal port
+	Public     string            `yaml:"public,omitempty"`                                 // Public directory for static
+	Command    string            `yaml:"command,omitempty"`                                // Command to run
+	DevCommand string            `yaml:"dev_command,omitempty"`                            // Command for `bp run --mount-src`
+	Env        map[string]string `yaml:"env,omitempty"`                                    // Environment variables
+	EnvFile    []string          `yaml:"env_file,omitempty"`                               // Extra env files for this service
+	Volumes    []string          `yaml:"volumes,omitempty"`                                // Volume mounts
+	DependsOn  []string          `yaml:"depends_on,omitempty" json:"depends_on,omitempty"` // Service dependencies
+	Domain     string            `yaml:"domain,omitempty" json:"domain,omitempty"`         // Routed to this service on the server
+	Ready      *ServiceReady     `yaml:"ready,omitempty" json:"ready,omitempty"`           // How dependents know it is up
+}
+
+// ServiceReady is the ready check dependents of a service wait for: an HTTP
+// path answering below 400, or else the service's port accepting connections
+type ServiceReady struct {
+	HTTP    string `yaml:"http,omitempty" json:"http,omitempty"`       // e.g. "/health"
+	Timeout int    `yaml:"timeout,omitempty" json:"timeout,omitempty"` // Seconds (default: 60)
 }
 
 // ServiceBuild defines build config for a service
@@ -937,6 +1285,9 @@ func loadAppConfigWithEnv(dir string, env string) (*AppConfig, error) {
 		if envCfg.Build.Command != "" {
 			cfg.Build.Command = envCfg.Build.Command
 		}
+		if envCfg.DevCommand != "" {
+			cfg.DevCommand = envCfg.DevCommand
+		}
 		// Merge env vars (env-specific overrides base)
 		if len(envCfg.Env) > 0 {
 			if cfg.Env == nil {
@@ -946,6 +1297,9 @@ func loadAppConfigWithEnv(dir string, env string) (*AppConfig, error) {
 				cfg.Env[k] = v
 			}
 		}
+		if len(envCfg.EnvFile) > 0 {
+			cfg.EnvFile = envCfg.EnvFile
+		}
 		if len(envCfg.Volumes) > 0 {
 			cfg.Volumes = envCfg.Volumes
 		}
@@ -955,6 +1309,18 @@ func loadAppConfigWithEnv(dir string, env string) (*AppConfig, error) {
 		if len(envCfg.Services) > 0 {
 			cfg.Services = envCfg.Services
 		}
+		if len(envCfg.Profiles) > 0 {
+			cfg.Profiles = envCfg.Profiles
+		}
+		if envCfg.SEO != nil {
+			cfg.SEO = envCfg.SEO
+		}
+		if envCfg.Protocol != "" {
+			cfg.Protocol = envCfg.Protocol
+		}
+		if envCfg.Recreate != nil {
+			cfg.Recreate = envCfg.Recreate
+		}
 
 		fmt.Printf("Loaded config: basepod.yaml + basepod.%s.yaml\n", env)
 	}
@@ -966,6 +1332,9 @@ func cmdInit(args []string) {
 	dir := "."
 	forceStatic := false
 	forceContainer := false
+	preset := ""
+	fromCompose := false
+	composeFile := ""
 
 	// Parse args
 	for i := 0; i < len(args); i++ {
@@ -974,6 +1343,20 @@ func cmdInit(args []string) {
 			forceStatic = true
 		case "--container", "-c":
 			forceContainer = true
+		case "--preset":
+			if i+1 < len(args) {
+				preset = args[i+1]
+				i++
+			}
+		case "--from-compose":
+			fromCompose = true
+			// An optional file follows; a directory is the usual positional dir
+			if i+1 < len(args) {
+				if info, err := os.Stat(args[i+1]); err == nil && !info.IsDir() {
+					composeFile = args[i+1]
+					i++
+				}
+			}
 		default:
 			if !strings.HasPrefix(args[i], "-") {
 				dir = args[i]
@@ -991,6 +1374,11 @@ func cmdInit(args []string) {
 	absDir, _ := filepath.Abs(dir)
 	appName := filepath.Base(absDir)
 
+	if fromCompose {
+		initFromCompose(dir, composeFile, appName, configPath)
+		return
+	}
+
 	// Detect project type
 	projectType := detectProjectType(dir)
 	fmt.Printf("Detected: %s\n", projectType.description)
@@ -1073,8 +1461,10 @@ func cmdInit(args []string) {
 			Build: BuildConfig{
 				Dockerfile: "Dockerfile",
 				Context:    ".",
+				Preset:     preset,
 			},
 		}
+		cfg.HealthCheck = projectType.healthPath
 		// Only add env for Node projects
 		if projectType.runtime == "node" {
 			cfg.Env = map[string]string{"NODE_ENV": "production"}
@@ -1092,7 +1482,19 @@ func cmdInit(args []string) {
 	dockerfilePath := filepath.Join(dir, "Dockerfile")
 	createdDockerfile := false
 	if deployType == "container" && !projectType.hasDockerfile {
-		dockerfile := generateDockerfile(projectType, port)
+		// The server's build presets set the approved base images; the
+		// built-in Dockerfiles are only used when it can't be reached
+		dockerfile, usedPreset, err := presetDockerfile(preset, projectType, dir, port)
+		if err != nil {
+			if preset != "" {
+				fmt.Fprintf(os.Stderr, "Failed to get build preset %s: %v\n", preset, err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: Couldn't get the server's build presets (%v); using the built-in Dockerfile\n", err)
+			dockerfile = generateDockerfile(projectType, port)
+		} else if usedPreset != "builtin" {
+			fmt.Printf("Dockerfile from build preset: %s\n", usedPreset)
+		}
 		if dockerfile != "" {
 			if err := os.WriteFile(dockerfilePath, []byte(dockerfile), 0644); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: Failed to create Dockerfile: %v\n", err)
@@ -1109,18 +1511,22 @@ func cmdInit(args []string) {
 	} else {
 		fmt.Println("Created: basepod.yaml")
 	}
+	if deployType == "container" && projectType.healthPath != "" {
+		fmt.Printf("Health check: %s\n", projectType.healthPath)
+	}
 	fmt.Println("\nNext steps:")
 	fmt.Println("  bp deploy")
 }
 
 // ProjectType holds detected project information
 type ProjectType struct {
-	runtime       string // node, go, python, static
+	runtime       string // Build preset stack (node, go, laravel, java-maven, ...), static or docker
 	description   string
 	hasDockerfile bool
 	isStatic      bool
 	defaultPort   int
 	publicDir     string
+	healthPath    string // Health endpoint the framework provides, if it's set up
 }
 
 // detectProjectType analyzes a directory to determine the project type
@@ -1139,6 +1545,16 @@ func detectProjectType(dir string) ProjectType {
 		return pt
 	}
 
+	// Check for deno.json (Deno), which may sit next to a package.json
+	for _, name := range []string{"deno.json", "deno.jsonc"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			pt.runtime = "deno"
+			pt.description = name + " (Deno project)"
+			pt.defaultPort = 8000
+			return pt
+		}
+	}
+
 	// Check for package.json (Node/Bun)
 	if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
 		pt.runtime = "node"
@@ -1163,6 +1579,73 @@ func detectProjectType(dir string) ProjectType {
 		return pt
 	}
 
+	// Check for pyproject.toml (Python)
+	if _, err := os.Stat(filepath.Join(dir, "pyproject.toml")); err == nil {
+		pt.runtime = "pyproject"
+		pt.description = "pyproject.toml (Python project)"
+		pt.defaultPort = 8000
+		return pt
+	}
+
+	// Check for Gemfile (Ruby, Rails with bin/rails)
+	if _, err := os.Stat(filepath.Join(dir, "Gemfile")); err == nil {
+		pt.runtime = "ruby"
+		pt.description = "Gemfile (Ruby project)"
+		pt.defaultPort = 3000
+		if _, err := os.Stat(filepath.Join(dir, "bin", "rails")); err == nil {
+			pt.runtime = "rails"
+			pt.description = "Gemfile + bin/rails (Rails project)"
+			// Rails 7.1+ apps route /up to Rails::HealthController
+			if fileContains(filepath.Join(dir, "config", "routes.rb"), "rails/health") {
+				pt.healthPath = "/up"
+			}
+		}
+		return pt
+	}
+
+	// Check for Cargo.toml (Rust)
+	if _, err := os.Stat(filepath.Join(dir, "Cargo.toml")); err == nil {
+		pt.runtime = "rust"
+		pt.description = "Cargo.toml (Rust project)"
+		pt.defaultPort = 8080
+		return pt
+	}
+
+	// Check for composer.json (PHP, Laravel with artisan)
+	if _, err := os.Stat(filepath.Join(dir, "composer.json")); err == nil {
+		pt.runtime = "php"
+		pt.description = "composer.json (PHP project)"
+		pt.defaultPort = 8000
+		if _, err := os.Stat(filepath.Join(dir, "artisan")); err == nil {
+			pt.runtime = "laravel"
+			pt.description = "composer.json + artisan (Laravel project)"
+			// Laravel 11+ registers /up in bootstrap/app.php
+			if fileContains(filepath.Join(dir, "bootstrap", "app.php"), "health:") {
+				pt.healthPath = "/up"
+			}
+		}
+		return pt
+	}
+
+	// Check for pom.xml or build.gradle (Java)
+	for _, build := range []struct{ file, runtime, description string }{
+		{"pom.xml", "java-maven", "pom.xml (Java/Maven project)"},
+		{"build.gradle", "java-gradle", "build.gradle (Java/Gradle project)"},
+		{"build.gradle.kts", "java-gradle", "build.gradle.kts (Java/Gradle project)"},
+	} {
+		path := filepath.Join(dir, build.file)
+		if _, err := os.Stat(path); err == nil {
+			pt.runtime = build.runtime
+			pt.description = build.description
+			pt.defaultPort = 8080
+			// Spring Boot serves /actuator/health once actuator is a dependency
+			if fileContains(path, "spring-boot-starter-actuator") {
+				pt.healthPath = "/actuator/health"
+			}
+			return pt
+		}
+	}
+
 	// Check for common static build output directories
 	staticDirs := []string{"dist", "build", "public", "out", ".output/public", "_site"}
 	for _, d := range staticDirs {
@@ -1221,12 +1704,116 @@ RUN pip install --no-cache-dir -r requirements.txt
 COPY . .
 EXPOSE %d
 CMD ["python", "app.py"]
+`, port)
+	case "pyproject":
+		return fmt.Sprintf(`FROM python:3.12-slim
+WORKDIR /app
+COPY pyproject.toml .
+RUN pip install --no-cache-dir .
+COPY . .
+EXPOSE %d
+CMD ["python", "-m", "app"]
+`, port)
+	case "deno":
+		return fmt.Sprintf(`FROM denoland/deno:2.1.4
+WORKDIR /app
+COPY . .
+RUN deno install
+EXPOSE %d
+CMD ["deno", "task", "start"]
+`, port)
+	case "ruby":
+		return fmt.Sprintf(`FROM ruby:3.3-slim
+WORKDIR /app
+COPY Gemfile Gemfile.lock ./
+RUN bundle install
+COPY . .
+EXPOSE %d
+CMD ["ruby", "app.rb"]
+`, port)
+	case "rails":
+		return fmt.Sprintf(`FROM ruby:3.3-slim
+WORKDIR /app
+ENV RAILS_ENV=production BUNDLE_WITHOUT=development:test
+RUN apt-get update && apt-get install -y --no-install-recommends build-essential libpq-dev libyaml-dev && rm -rf /var/lib/apt/lists/*
+COPY Gemfile Gemfile.lock ./
+RUN bundle install
+COPY . .
+RUN SECRET_KEY_BASE_DUMMY=1 bin/rails assets:precompile 2>/dev/null || true
+EXPOSE %[1]d
+CMD ["bin/rails", "server", "-b", "0.0.0.0", "-p", "%[1]d"]
+`, port)
+	case "rust":
+		return fmt.Sprintf(`FROM rust:1.77-slim AS builder
+WORKDIR /app
+COPY . .
+RUN cargo build --release && find target/release -maxdepth 1 -type f -perm -u+x -exec cp {} /app/server \;
+
+FROM debian:bookworm-slim
+WORKDIR /app
+COPY --from=builder /app/server .
+EXPOSE %d
+CMD ["./server"]
+`, port)
+	case "laravel", "php":
+		cmd := `["sh", "-c", "if [ -d public ]; then exec php -S 0.0.0.0:%[1]d -t public; else exec php -S 0.0.0.0:%[1]d; fi"]`
+		extra := ""
+		if pt.runtime == "laravel" {
+			cmd = `["php", "artisan", "serve", "--host=0.0.0.0", "--port=%[1]d"]`
+			extra = "RUN docker-php-ext-install pdo_mysql\n"
+		}
+		return fmt.Sprintf(`FROM composer:2 AS vendor
+WORKDIR /app
+COPY composer.json composer.lock* ./
+RUN composer install --no-dev --no-scripts --no-autoloader --prefer-dist --ignore-platform-reqs
+COPY . .
+RUN composer dump-autoload --optimize --no-dev --no-scripts
+
+FROM php:8.3-cli
+WORKDIR /app
+`+extra+`COPY --from=vendor /app /app
+EXPOSE %[1]d
+CMD `+cmd+`
+`, port)
+	case "java-maven":
+		return fmt.Sprintf(`FROM maven:3.9-eclipse-temurin-21 AS builder
+WORKDIR /app
+COPY pom.xml .
+RUN mvn -B -q dependency:go-offline
+COPY . .
+RUN mvn -B -q -DskipTests package && cp "$(ls target/*.jar | grep -v -e '-sources.jar$' -e '-javadoc.jar$' | head -n 1)" /app/app.jar
+
+FROM eclipse-temurin:21-jre
+WORKDIR /app
+COPY --from=builder /app/app.jar .
+ENV SERVER_PORT=%[1]d PORT=%[1]d
+EXPOSE %[1]d
+CMD ["java", "-jar", "app.jar"]
+`, port)
+	case "java-gradle":
+		return fmt.Sprintf(`FROM gradle:8-jdk21 AS builder
+WORKDIR /app
+COPY . .
+RUN gradle build -x test --no-daemon -q && cp "$(ls build/libs/*.jar | grep -v -e '-plain.jar$' | head -n 1)" /app/app.jar
+
+FROM eclipse-temurin:21-jre
+WORKDIR /app
+COPY --from=builder /app/app.jar .
+ENV SERVER_PORT=%[1]d PORT=%[1]d
+EXPOSE %[1]d
+CMD ["java", "-jar", "app.jar"]
 `, port)
 	default:
 		return ""
 	}
 }
 
+// fileContains reports whether the file at path contains substr
+func fileContains(path, substr string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(data), substr)
+}
+
 // createTarball creates a gzipped tarball of the directory
 func createTarball(dir string) (*bytes.Buffer, error) {
 	var buf bytes.Buffer
@@ -1409,11 +1996,26 @@ func cmdRun(args []string) {
 	var port int
 	var detach bool
 	var env string
+	var only []string
+	var profile string
+	var https bool
+	httpsPort := defaultHTTPSPort
+	var dev *devMode
 
 	// Parse flags
 	positionalArgs := []string{}
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
+		case "--only":
+			if i+1 < len(args) {
+				only = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--profile":
+			if i+1 < len(args) {
+				profile = args[i+1]
+				i++
+			}
 		case "--port", "-p":
 			if i+1 < len(args) {
 				fmt.Sscanf(args[i+1], "%d", &port)
@@ -1421,6 +2023,23 @@ func cmdRun(args []string) {
 			}
 		case "--detach", "-d":
 			detach = true
+		case "--https":
+			https = true
+		case "--mount-src", "--dev":
+			if dev == nil {
+				dev = &devMode{}
+			}
+		case "--rebuild":
+			if dev == nil {
+				dev = &devMode{}
+			}
+			dev.Rebuild = true
+		case "--https-port":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &httpsPort)
+				https = true
+				i++
+			}
 		case "--env", "-e":
 			if i+1 < len(args) {
 				env = args[i+1]
@@ -1433,6 +2052,10 @@ func cmdRun(args []string) {
 		default:
 			if strings.HasPrefix(args[i], "--env=") {
 				env = strings.TrimPrefix(args[i], "--env=")
+			} else if strings.HasPrefix(args[i], "--only=") {
+				only = strings.Split(strings.TrimPrefix(args[i], "--only="), ",")
+			} else if strings.HasPrefix(args[i], "--profile=") {
+				profile = strings.TrimPrefix(args[i], "--profile=")
 			} else if !strings.HasPrefix(args[i], "-") {
 				positionalArgs = append(positionalArgs, args[i])
 			}
@@ -1461,6 +2084,36 @@ func cmdRun(args []string) {
 		os.Exit(1)
 	}
 
+	// Narrow multi-service apps to the requested services and their dependencies
+	if profile != "" {
+		names, ok := appCfg.Profiles[profile]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown profile %q in basepod.yaml\n", profile)
+			os.Exit(1)
+		}
+		only = append(only, names...)
+	}
+	if len(only) > 0 {
+		if len(appCfg.Services) == 0 {
+			fmt.Fprintln(os.Stderr, "--only and --profile need services defined in basepod.yaml")
+			os.Exit(1)
+		}
+		selected, err := selectServices(appCfg.Services, only)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		appCfg.Services = selected
+	}
+
+	// Env files are the base layer; env in basepod.yaml overrides them
+	fileEnv, err := loadEnvFiles(dir, appCfg.EnvFile, env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load env files: %v\n", err)
+		os.Exit(1)
+	}
+	appCfg.Env = mergeEnv(fileEnv, appCfg.Env)
+
 	// Determine port
 	if port == 0 {
 		port = appCfg.Port
@@ -1492,10 +2145,32 @@ func cmdRun(args []string) {
 	exec.Command("podman", "stop", containerName).Run()
 	exec.Command("podman", "rm", containerName).Run()
 
+	// Serve https://<app>.localhost through a local Caddy with a trusted cert
+	if https {
+		proxy, err := startHTTPSProxy(appCfg.Name, port, httpsPort)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set up HTTPS: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("HTTPS: https://%s:%d\n", localHTTPSHost(appCfg.Name), httpsPort)
+		if detach {
+			fmt.Printf("Stop HTTPS proxy: podman stop %s\n", proxy)
+		} else {
+			// Ctrl+C reaches the foreground container too; just clean up the proxy
+			interrupted := make(chan os.Signal, 1)
+			signal.Notify(interrupted, os.Interrupt)
+			go func() {
+				<-interrupted
+				stopHTTPSProxy(proxy)
+			}()
+			defer stopHTTPSProxy(proxy)
+		}
+	}
+
 	// Handle based on app type
 	if len(appCfg.Services) > 0 {
-		// Multi-service app: run with podman pod
-		runServicesApp(dir, appCfg, port, detach)
+		// Multi-service app: run on a per-app network
+		runServicesApp(dir, appCfg, fileEnv, port, detach, dev)
 	} else if len(appCfg.Processes) > 0 {
 		// Multi-process app: run with supervisord
 		runMultiProcessApp(dir, appCfg, port, detach)
@@ -1504,7 +2179,7 @@ func cmdRun(args []string) {
 		runStaticSite(dir, appCfg, port, detach)
 	} else if appCfg.Build.Dockerfile != "" {
 		// Container with Dockerfile
-		runContainerApp(dir, appCfg, port, detach)
+		runContainerApp(dir, appCfg, port, detach, dev)
 	} else {
 		fmt.Fprintln(os.Stderr, "Error: Cannot determine how to run this app.")
 		fmt.Fprintln(os.Stderr, "Set 'type: static' with 'public: <dir>' for static sites,")
@@ -1603,7 +2278,7 @@ CMD ["caddy", "file-server", "--root", "/srv", "--listen", ":80"]
 }
 
 // runContainerApp builds and runs a container app locally
-func runContainerApp(dir string, appCfg *AppConfig, port int, detach bool) {
+func runContainerApp(dir string, appCfg *AppConfig, port int, detach bool, dev *devMode) {
 	absDir, _ := filepath.Abs(dir)
 
 	// Determine Dockerfile and context
@@ -1633,15 +2308,19 @@ func runContainerApp(dir string, appCfg *AppConfig, port int, detach bool) {
 
 	imageName := appCfg.Name + ":local"
 
-	fmt.Printf("Building container: %s\n", imageName)
-	buildCmd := exec.Command("podman", "build", "-t", imageName, "-f", dockerfilePath, contextPath)
-	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
-	if err := buildCmd.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Build failed: %v\n", err)
-		os.Exit(1)
+	if dev.skipBuild(imageName) {
+		fmt.Printf("Reusing image %s (source is mounted; pass --rebuild after dependency changes)\n", imageName)
+	} else {
+		fmt.Printf("Building container: %s\n", imageName)
+		buildCmd := exec.Command("podman", "build", "-t", imageName, "-f", dockerfilePath, contextPath)
+		buildCmd.Stdout = os.Stdout
+		buildCmd.Stderr = os.Stderr
+		if err := buildCmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Build failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Build completed!")
 	}
-	fmt.Println("Build completed!")
 
 	// Build environment variables
 	envArgs := []string{}
@@ -1672,9 +2351,18 @@ func runContainerApp(dir string, appCfg *AppConfig, port int, detach bool) {
 	)
 	runArgs = append(runArgs, envArgs...)
 	runArgs = append(runArgs, volumeArgs...)
+	if dev != nil {
+		runArgs = append(runArgs, devMountArgs(imageName, contextPath)...)
+	}
 	runArgs = append(runArgs, imageName)
+	if dev != nil && appCfg.DevCommand != "" {
+		runArgs = append(runArgs, "sh", "-c", appCfg.DevCommand)
+	}
 
 	fmt.Printf("\nContainer: %s\n", appCfg.Name)
+	if dev != nil {
+		fmt.Printf("Source: %s (mounted, live)\n", contextPath)
+	}
 	fmt.Printf("URL: http://localhost:%d\n\n", port)
 
 	if detach {
@@ -1701,38 +2389,73 @@ func runContainerApp(dir string, appCfg *AppConfig, port int, detach bool) {
 	}
 }
 
-// runServicesApp runs multiple services using podman pod
-func runServicesApp(dir string, appCfg *AppConfig, port int, detach bool) {
+// runServicesApp runs multiple services on a per-app network. Each service is
+// reachable by its name and by the container name it gets on the server,
+// publishes its own port, and starts after the services it depends on are ready.
+func runServicesApp(dir string, appCfg *AppConfig, fileEnv map[string]string, port int, detach bool, dev *devMode) {
 	absDir, _ := filepath.Abs(dir)
-	podName := appCfg.Name + "-pod"
+	networkName := appCfg.Name + "-net"
 
-	fmt.Printf("Running multi-service app: %s\n", appCfg.Name)
-	fmt.Printf("Services:\n")
-	for name, svc := range appCfg.Services {
-		fmt.Printf("  - %s (port %d)\n", name, svc.Port)
+	order, err := orderServices(appCfg.Services)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Stop and remove existing pod
-	fmt.Printf("\nStopping existing pod (if any)...\n")
-	exec.Command("podman", "pod", "stop", podName).Run()
-	exec.Command("podman", "pod", "rm", podName).Run()
+	// The service on the app's port is published on --port; others on their own port
+	hostPorts := make(map[string]int)
+	for _, name := range order {
+		svc := appCfg.Services[name]
+		if svc.Port == 0 {
+			continue
+		}
+		hostPorts[name] = svc.Port
+		if svc.Port == appCfg.Port {
+			hostPorts[name] = port
+		}
+	}
 
-	// Collect all ports to expose
-	portArgs := []string{}
-	portArgs = append(portArgs, "-p", fmt.Sprintf("%d:%d", port, port))
+	fmt.Printf("Running multi-service app: %s\n", appCfg.Name)
+	fmt.Printf("Services (start order):\n")
+	for _, name := range order {
+		svc := appCfg.Services[name]
+		line := fmt.Sprintf("  - %s", name)
+		if hp, ok := hostPorts[name]; ok {
+			line += fmt.Sprintf(" (localhost:%d -> %d)", hp, svc.Port)
+		}
+		if len(svc.DependsOn) > 0 {
+			line += " after " + strings.Join(svc.DependsOn, ", ")
+		}
+		fmt.Println(line)
+	}
 
-	// Create the pod with all ports
-	fmt.Printf("Creating pod: %s\n", podName)
-	createPodArgs := []string{"pod", "create", "--name", podName}
-	createPodArgs = append(createPodArgs, portArgs...)
+	// Remove containers and the network from a previous run
+	fmt.Printf("\nStopping existing services (if any)...\n")
+	exec.Command("podman", "pod", "rm", "-f", appCfg.Name+"-pod").Run() // Older versions of bp ran services in a pod
+	for _, name := range order {
+		exec.Command("podman", "rm", "-f", fmt.Sprintf("%s-%s", appCfg.Name, name)).Run()
+	}
 
-	if err := exec.Command("podman", createPodArgs...).Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create pod: %v\n", err)
-		os.Exit(1)
+	// Reuse the network if it exists; services outside --only may still be on it
+	if exec.Command("podman", "network", "exists", networkName).Run() != nil {
+		fmt.Printf("Creating network: %s\n", networkName)
+		if out, err := exec.Command("podman", "network", "create", networkName).CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create network: %v\n%s", err, out)
+			os.Exit(1)
+		}
+	}
+
+	// Services others depend on are waited for before their dependents start
+	isDependency := make(map[string]bool)
+	for _, svc := range appCfg.Services {
+		for _, dep := range svc.DependsOn {
+			isDependency[dep] = true
+		}
 	}
 
-	// Build and run each service
-	for name, svc := range appCfg.Services {
+	var containers []string
+	for _, name := range order {
+		svc := appCfg.Services[name]
 		fmt.Printf("\n--- Service: %s ---\n", name)
 
 		var imageName string
@@ -1763,8 +2486,12 @@ func runServicesApp(dir string, appCfg *AppConfig, port int, detach bool) {
 				// Build static site container
 				buildStaticServiceImage(absDir, name, svc, imageName)
 			} else if svc.Build.Dockerfile != "" || svc.Build.Context != "" {
-				// Build from Dockerfile
-				buildServiceImage(absDir, name, svc, imageName)
+				// Build from Dockerfile (dev runs reuse the image and mount the source)
+				if dev.skipBuild(imageName) {
+					fmt.Printf("Reusing image: %s\n", imageName)
+				} else {
+					buildServiceImage(absDir, name, svc, imageName)
+				}
 			} else if svc.Type == "go" {
 				// Build Go service
 				buildGoServiceImage(absDir, name, svc, imageName)
@@ -1777,11 +2504,24 @@ func runServicesApp(dir string, appCfg *AppConfig, port int, detach bool) {
 			}
 		}
 
-		// Run the service in the pod
-		runArgs := []string{"run", "-d", "--pod", podName, "--name", fmt.Sprintf("%s-%s", appCfg.Name, name)}
+		containerName := fmt.Sprintf("%s-%s", appCfg.Name, name)
+		runArgs := []string{"run", "-d", "--name", containerName, "--network", networkName}
+		for _, alias := range serviceAliases(appCfg.Name, name) {
+			runArgs = append(runArgs, "--network-alias", alias)
+		}
+		if hp, ok := hostPorts[name]; ok {
+			runArgs = append(runArgs, "-p", fmt.Sprintf("%d:%d", hp, svc.Port))
+		}
 
-		// Add environment variables
-		for key, val := range svc.Env {
+		// Add environment variables: app env files, service env files, then service env
+		var svcFileEnv map[string]string
+		if len(svc.EnvFile) > 0 {
+			if svcFileEnv, err = loadEnvFiles(absDir, svc.EnvFile, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to load env files for %s: %v\n", name, err)
+				os.Exit(1)
+			}
+		}
+		for key, val := range mergeEnv(fileEnv, svcFileEnv, svc.Env) {
 			runArgs = append(runArgs, "-e", fmt.Sprintf("%s=%s", key, val))
 		}
 
@@ -1790,11 +2530,20 @@ func runServicesApp(dir string, appCfg *AppConfig, port int, detach bool) {
 			runArgs = append(runArgs, "-v", vol)
 		}
 
+		// Dev runs mount the source of locally built services
+		command := svc.Command
+		if dev != nil && svc.Image == "" && (svc.Build.Dockerfile != "" || svc.Build.Context != "") {
+			runArgs = append(runArgs, devMountArgs(imageName, filepath.Join(absDir, svc.Build.Context))...)
+			if svc.DevCommand != "" {
+				command = svc.DevCommand
+			}
+		}
+
 		runArgs = append(runArgs, imageName)
 
 		// Add command if specified
-		if svc.Command != "" {
-			runArgs = append(runArgs, "sh", "-c", svc.Command)
+		if command != "" {
+			runArgs = append(runArgs, "sh", "-c", command)
 		}
 
 		fmt.Printf("Starting %s...\n", name)
@@ -1805,21 +2554,37 @@ func runServicesApp(dir string, appCfg *AppConfig, port int, detach bool) {
 			fmt.Fprintf(os.Stderr, "Failed to start service %s: %v\n", name, err)
 			os.Exit(1)
 		}
+		containers = append(containers, containerName)
+
+		if isDependency[name] {
+			fmt.Printf("Waiting for %s to be ready...\n", name)
+			if err := waitForService(containerName, hostPorts[name], svc.Ready); err != nil {
+				fmt.Fprintf(os.Stderr, "Service %s did not become ready: %v\n", name, err)
+				os.Exit(1)
+			}
+		}
 	}
 
 	fmt.Printf("\n✓ All services started!\n")
-	fmt.Printf("Pod: %s\n", podName)
-	fmt.Printf("URL: http://localhost:%d\n\n", port)
-	fmt.Printf("View logs: podman pod logs -f %s\n", podName)
-	fmt.Printf("Stop: podman pod stop %s\n", podName)
+	fmt.Printf("Network: %s\n", networkName)
+	for _, name := range order {
+		if hp, ok := hostPorts[name]; ok {
+			fmt.Printf("  %s: http://localhost:%d\n", name, hp)
+		}
+	}
+	fmt.Printf("\nView logs: podman logs -f <service container>\n")
+	fmt.Printf("Stop: podman stop %s\n", strings.Join(containers, " "))
 
 	if !detach {
 		fmt.Println("\nPress Ctrl+C to stop...")
-		// Follow logs
-		cmd := exec.Command("podman", "pod", "logs", "-f", podName)
+		// Follow logs of all services until interrupted, then stop them
+		signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+		logArgs := append([]string{"logs", "-f", "--names"}, containers...)
+		cmd := exec.Command("podman", logArgs...)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		cmd.Run()
+		exec.Command("podman", append([]string{"stop"}, containers...)...).Run()
 	}
 }
 
@@ -2105,8 +2870,9 @@ stderr_logfile_maxbytes=0
 }
 
 func cmdDeploy(args []string) {
-	var image, gitURL, branch, dir, env string
-	var force bool
+	var image, gitURL, branch, dir, env, override string
+	var force, fullUpload, recreate bool
+	var canary int
 
 	// Parse flags first
 	positionalArgs := []string{}
@@ -2129,6 +2895,25 @@ func cmdDeploy(args []string) {
 			}
 		case "--force", "-f":
 			force = true
+		case "--full-upload":
+			fullUpload = true
+		case "--recreate":
+			recreate = true
+		case "--canary":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(strings.TrimSuffix(args[i+1], "%"))
+				if err != nil || n < 1 || n > 99 {
+					fmt.Fprintln(os.Stderr, "--canary must be a percent between 1 and 99")
+					os.Exit(1)
+				}
+				canary = n
+				i++
+			}
+		case "--override":
+			if i+1 < len(args) {
+				override = args[i+1]
+				i++
+			}
 		case "--env", "-e":
 			if i+1 < len(args) {
 				env = args[i+1]
@@ -2178,7 +2963,15 @@ func cmdDeploy(args []string) {
 			}
 		}
 
-		deployImageOrGit(name, image, gitURL, branch)
+		switch {
+		case canary != 0 && gitURL != "":
+			fmt.Fprintln(os.Stderr, "--canary works with --image and local source deploys, not --git")
+			os.Exit(1)
+		case canary != 0:
+			startCanary(name, app.CanaryRequest{Image: image, Weight: canary, FreezeOverride: override})
+		default:
+			deployImageOrGit(name, image, gitURL, branch, override, recreate)
+		}
 	} else {
 		// Local source deployment mode (default)
 		if len(positionalArgs) > 0 {
@@ -2186,12 +2979,12 @@ func cmdDeploy(args []string) {
 		} else {
 			dir = "."
 		}
-		deployLocalSource(dir, force, env)
+		deployLocalSource(dir, force, env, override, canary, fullUpload, recreate)
 	}
 }
 
 // deployLocalSource deploys from local source code (like old bp push)
-func deployLocalSource(dir string, force bool, env string) {
+func deployLocalSource(dir string, force bool, env, override string, canary int, fullUpload, recreate bool) {
 	// Load app config (with optional environment overlay)
 	appCfg, err := loadAppConfigWithEnv(dir, env)
 	if err != nil {
@@ -2227,6 +3020,24 @@ func deployLocalSource(dir string, force bool, env string) {
 		}
 		fmt.Println("Build completed successfully!")
 	}
+	if len(appCfg.Services) > 0 {
+		order, err := orderServices(appCfg.Services)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid services: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range order {
+			svc := appCfg.Services[name]
+			if svc.Build.Command == "" {
+				continue
+			}
+			fmt.Printf("Running build command for %s: %s\n", name, svc.Build.Command)
+			if err := runBuildCommand(filepath.Join(dir, svc.Build.Context), svc.Build.Command); err != nil {
+				fmt.Fprintf(os.Stderr, "Build failed for %s: %v\n", name, err)
+				os.Exit(1)
+			}
+		}
+	}
 
 	// Load CLI config
 	cliCfg, err := loadConfig()
@@ -2263,6 +3074,9 @@ func deployLocalSource(dir string, force bool, env string) {
 
 	// Get git info for deployment tracking
 	appCfg.GitCommit, appCfg.GitMessage, appCfg.GitBranch = getGitInfo(dir)
+	if appCfg.Build.RequireLockfile {
+		appCfg.DirtyLockfiles = dirtyLockfiles(dir)
+	}
 
 	// Handle different git scenarios
 	if appCfg.GitCommit == "" {
@@ -2307,23 +3121,45 @@ func deployLocalSource(dir string, force bool, env string) {
 	client := &http.Client{Timeout: 5 * time.Minute}
 	server := serverCfg.URL
 
+	// Send only the files the server doesn't have from earlier deploys;
+	// servers without delta uploads get the whole tarball
+	var manifest []app.SourceFile
+	if !fullUpload {
+		manifest, err = uploadDelta(client, serverCfg, tarball.Bytes())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Delta upload failed, sending the whole tarball: %v\n", err)
+			manifest = nil
+		}
+	}
+
 	// Create multipart form
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 
 	// Add config as JSON
+	appCfg.FreezeOverride = override
+	appCfg.Canary = canary
+	if recreate {
+		appCfg.Recreate = &recreate
+	}
 	configJSON, _ := json.Marshal(appCfg)
 	_ = writer.WriteField("config", string(configJSON))
 
-	// Add tarball
-	part, err := writer.CreateFormFile("source", "source.tar.gz")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create form: %v\n", err)
-		os.Exit(1)
-	}
-	if _, err := io.Copy(part, tarball); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to write tarball: %v\n", err)
-		os.Exit(1)
+	if manifest != nil {
+		// The server puts the source together from the files it has
+		manifestJSON, _ := json.Marshal(manifest)
+		_ = writer.WriteField("manifest", string(manifestJSON))
+	} else {
+		// Add tarball
+		part, err := writer.CreateFormFile("source", "source.tar.gz")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create form: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := io.Copy(part, tarball); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write tarball: %v\n", err)
+			os.Exit(1)
+		}
 	}
 	writer.Close()
 
@@ -2334,6 +3170,7 @@ func deployLocalSource(dir string, force bool, env string) {
 		os.Exit(1)
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Accept", "application/x-ndjson, text/plain") // Structured progress when the server supports it
 
 	if serverCfg.Token != "" {
 		req.Header.Set("Authorization", "Bearer "+serverCfg.Token)
@@ -2347,36 +3184,49 @@ func deployLocalSource(dir string, force bool, env string) {
 	}
 	defer resp.Body.Close()
 
-	// Stream response (build logs)
-	fmt.Println("\n--- Build Output ---")
-	buf := make([]byte, 1024)
-	for {
-		n, err := resp.Body.Read(buf)
-		if n > 0 {
-			fmt.Print(string(buf[:n]))
-		}
-		if err != nil {
-			break
-		}
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "\nDeploy failed with status: %d\n", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		exitIfFrozen(resp.StatusCode, body)
+		fmt.Fprintf(os.Stderr, "\nDeploy failed with status %d: %s\n", resp.StatusCode, string(body))
 		os.Exit(1)
 	}
 
-	fmt.Println("\nDeployed successfully!")
+	deployURL := ""
 	if appCfg.Domain != "" {
-		fmt.Printf("URL: https://%s\n", appCfg.Domain)
+		deployURL = "https://" + appCfg.Domain
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-ndjson") {
+		result := printDeployEvents(resp.Body)
+		if result.Status != "success" {
+			fmt.Fprintf(os.Stderr, "\nDeploy failed: %s\n", result.Message)
+			os.Exit(1)
+		}
+		if result.URL != "" {
+			deployURL = result.URL
+		}
+	} else {
+		// Older servers stream plain build output
+		fmt.Println("\n--- Build Output ---")
+		io.Copy(os.Stdout, resp.Body)
+	}
+
+	fmt.Println("\nDeployed successfully!")
+	if deployURL != "" {
+		fmt.Printf("URL: %s\n", deployURL)
 	}
+	printImageSummary(contextName, appCfg.Name)
 }
 
 // deployImageOrGit deploys from a Docker image or Git repository
-func deployImageOrGit(name, image, gitURL, branch string) {
+func deployImageOrGit(name, image, gitURL, branch, override string, recreate bool) {
 	req := app.DeployRequest{
-		Image:  image,
-		GitURL: gitURL,
-		Branch: branch,
+		Image:          image,
+		GitURL:         gitURL,
+		Branch:         branch,
+		FreezeOverride: override,
+	}
+	if recreate {
+		req.Recreate = &recreate
 	}
 
 	fmt.Printf("Deploying %s...\n", name)
@@ -2390,6 +3240,7 @@ func deployImageOrGit(name, image, gitURL, branch string) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		exitIfFrozen(resp.StatusCode, body)
 		fmt.Fprintf(os.Stderr, "Deploy failed: %s\n", string(body))
 		os.Exit(1)
 	}
@@ -2442,6 +3293,28 @@ func getGitInfo(dir string) (commit, message, branch string) {
 	return
 }
 
+// lockfileNames are the dependency lockfiles checked by build.require_lockfile
+var lockfileNames = []string{
+	"go.sum", "package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml",
+	"bun.lock", "bun.lockb", "Cargo.lock", "Gemfile.lock", "composer.lock", "Pipfile.lock",
+}
+
+// dirtyLockfiles lists lockfiles that are modified or untracked in git
+func dirtyLockfiles(dir string) []string {
+	args := append([]string{"-C", dir, "status", "--porcelain", "--"}, lockfileNames...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil
+	}
+	var dirty []string
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if len(line) > 3 {
+			dirty = append(dirty, strings.TrimSpace(line[3:]))
+		}
+	}
+	return dirty
+}
+
 // runBuildCommand executes a local build command in the specified directory
 func runBuildCommand(dir string, command string) error {
 	// Use shell to run the command (supports pipes, &&, etc.)
@@ -2465,24 +3338,35 @@ func runBuildCommand(dir string, command string) error {
 
 func cmdLogs(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: bp logs <name> [--tail <n>]")
+		fmt.Fprintln(os.Stderr, "Usage: bp logs <name> [--tail <n>] [-f|--follow]")
 		os.Exit(1)
 	}
 
 	name := args[0]
 	tail := "100"
+	follow := false
 
 	// Parse flags
 	for i := 1; i < len(args); i++ {
-		if args[i] == "--tail" || args[i] == "-n" {
+		switch args[i] {
+		case "--tail", "-n":
 			if i+1 < len(args) {
 				tail = args[i+1]
 				i++
 			}
+		case "--follow", "-f":
+			follow = true
 		}
 	}
 
-	resp, err := apiRequest("GET", fmt.Sprintf("/api/apps/%s/logs?tail=%s", name, tail), nil)
+	path := fmt.Sprintf("/api/apps/%s/logs?tail=%s", name, tail)
+	var resp *http.Response
+	var err error
+	if follow {
+		resp, err = apiStreamRequest(path + "&follow=true")
+	} else {
+		resp, err = apiRequest("GET", path, nil)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -2567,6 +3451,128 @@ func cmdRestart(args []string) {
 	fmt.Printf("App '%s' restarted\n", name)
 }
 
+func cmdScale(args []string) {
+	usage := `Usage: bp scale <name> [--replicas <n>] [--memory <size>] [--cpus <cores>] [--no-restart]
+
+  --replicas 3    Containers to run, load balanced round robin (1-10)
+  --memory 512m   Memory limit (m or g; 0 removes the limit)
+  --cpus 0.5      CPU limit in cores (0 removes the limit)
+  --no-restart    Only save the limits; they apply on the next restart or deploy
+
+Replicas start or stop right away. Without options, shows the current settings.`
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	name := args[0]
+	req := map[string]interface{}{}
+	restart := true
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--replicas", "-r":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, usage)
+				os.Exit(1)
+			}
+			replicas, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --replicas %q\n", args[i+1])
+				os.Exit(1)
+			}
+			req["replicas"] = replicas
+			i++
+		case "--memory", "-m":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, usage)
+				os.Exit(1)
+			}
+			memory, err := app.ParseMemory(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			req["memory"] = memory
+			i++
+		case "--cpus":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, usage)
+				os.Exit(1)
+			}
+			cpus, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --cpus %q\n", args[i+1])
+				os.Exit(1)
+			}
+			req["cpus"] = cpus
+			i++
+		case "--no-restart":
+			restart = false
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+
+	if len(req) == 0 {
+		a := fetchApp(name)
+		fmt.Printf("Resources for '%s':\n", a.Name)
+		fmt.Printf("  Replicas: %d\n", max(a.Resources.Replicas, 1))
+		fmt.Printf("  Memory:   %s\n", describeMemory(a.Resources.Memory))
+		fmt.Printf("  CPUs:     %s\n", describeCPUs(a.Resources.CPUs))
+		return
+	}
+
+	resp, err := apiRequest("PUT", "/api/apps/"+name, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed to update limits: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var a app.App
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated '%s': %d replicas, memory %s, cpus %s\n", name, max(a.Resources.Replicas, 1), describeMemory(a.Resources.Memory), describeCPUs(a.Resources.CPUs))
+
+	// Replicas are started or stopped by the update; limits need new containers
+	_, memoryChanged := req["memory"]
+	_, cpusChanged := req["cpus"]
+	if !memoryChanged && !cpusChanged {
+		return
+	}
+	if !restart || a.Status != app.StatusRunning {
+		fmt.Printf("Takes effect on the next restart or deploy (bp restart %s)\n", name)
+		return
+	}
+	fmt.Printf("Restarting '%s'...\n", name)
+	cmdRestart([]string{name})
+}
+
+// describeMemory renders a memory limit in MB for display
+func describeMemory(mb int64) string {
+	if mb <= 0 {
+		return "no limit"
+	}
+	return app.FormatMemory(mb)
+}
+
+// describeCPUs renders a CPU limit for display
+func describeCPUs(cpus float64) string {
+	if cpus <= 0 {
+		return "no limit"
+	}
+	return strconv.FormatFloat(cpus, 'f', -1, 64)
+}
+
 func cmdDelete(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "Usage: bp delete <name>")
@@ -2601,91 +3607,138 @@ func cmdDelete(args []string) {
 }
 
 func cmdEnv(args []string) {
+	usage := `Usage:
+  bp env <name>                          Show environment variables
+  bp env list <name>                     Show environment variables
+  bp env get <name> KEY                  Print one variable's value
+  bp env set <name> K=V... [--restart]   Set environment variables
+  bp env unset <name> KEY... [--restart] Remove environment variables
+
+--restart replaces the running container so it picks up the change.`
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, `Usage:
-  bp env <name>              Show environment variables
-  bp env set <name> K=V...   Set environment variables
-  bp env unset <name> KEY... Remove environment variables`)
+		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
 	}
 
 	subcmd := args[0]
 
 	switch subcmd {
-	case "set":
-		if len(args) < 3 {
-			fmt.Fprintln(os.Stderr, "Usage: bp env set <name> KEY=VALUE [KEY=VALUE...]")
-			os.Exit(1)
+	case "set", "unset":
+		restart := false
+		var rest []string
+		for _, arg := range args[1:] {
+			if arg == "--restart" {
+				restart = true
+			} else {
+				rest = append(rest, arg)
+			}
 		}
-		appName := args[1]
-		pairs := args[2:]
-
-		// Fetch current app to get existing env
-		currentApp := fetchApp(appName)
-		env := currentApp.Env
-		if env == nil {
-			env = make(map[string]string)
+		if len(rest) < 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
 		}
+		appName := rest[0]
+		req := map[string]interface{}{"restart": restart}
 
-		for _, pair := range pairs {
-			parts := strings.SplitN(pair, "=", 2)
-			if len(parts) != 2 {
-				fmt.Fprintf(os.Stderr, "Invalid format: %s (expected KEY=VALUE)\n", pair)
-				os.Exit(1)
+		if subcmd == "set" {
+			set := map[string]string{}
+			for _, pair := range rest[1:] {
+				parts := strings.SplitN(pair, "=", 2)
+				if len(parts) != 2 {
+					fmt.Fprintf(os.Stderr, "Invalid format: %s (expected KEY=VALUE)\n", pair)
+					os.Exit(1)
+				}
+				set[parts[0]] = parts[1]
 			}
-			env[parts[0]] = parts[1]
+			req["set"] = set
+		} else {
+			req["unset"] = rest[1:]
 		}
 
-		updateEnv(appName, env)
-		fmt.Printf("Environment updated for '%s'\n", appName)
-		for _, pair := range pairs {
-			parts := strings.SplitN(pair, "=", 2)
-			fmt.Printf("  %s=%s\n", parts[0], parts[1])
+		if restart {
+			fmt.Printf("Updating environment for '%s' and restarting...\n", appName)
 		}
-
-	case "unset":
-		if len(args) < 3 {
-			fmt.Fprintln(os.Stderr, "Usage: bp env unset <name> KEY [KEY...]")
-			os.Exit(1)
+		restarted := patchEnv(appName, req)
+		fmt.Printf("Environment updated for '%s'\n", appName)
+		for _, arg := range rest[1:] {
+			if subcmd == "set" {
+				fmt.Printf("  %s\n", arg)
+			} else {
+				fmt.Printf("  Removed: %s\n", arg)
+			}
 		}
-		appName := args[1]
-		keys := args[2:]
-
-		currentApp := fetchApp(appName)
-		env := currentApp.Env
-		if env == nil {
-			env = make(map[string]string)
+		if restarted {
+			fmt.Println("Restarted with the new environment")
+		} else {
+			fmt.Printf("Takes effect on the next restart or deploy (bp restart %s)\n", appName)
 		}
 
-		for _, key := range keys {
-			delete(env, key)
+	case "get":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp env get <name> KEY")
+			os.Exit(1)
 		}
-
-		updateEnv(appName, env)
-		fmt.Printf("Environment updated for '%s'\n", appName)
-		for _, key := range keys {
-			fmt.Printf("  Removed: %s\n", key)
+		value, ok := fetchEnv(args[1])[args[2]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s is not set for '%s'\n", args[2], args[1])
+			os.Exit(1)
 		}
+		fmt.Println(value)
 
 	default:
-		// "bp env <name>" — show env vars
+		// "bp env <name>" or "bp env list <name>" — show env vars
 		appName := subcmd
-		currentApp := fetchApp(appName)
+		if subcmd == "list" {
+			if len(args) != 2 {
+				fmt.Fprintln(os.Stderr, "Usage: bp env list <name>")
+				os.Exit(1)
+			}
+			appName = args[1]
+		}
+		env := fetchEnv(appName)
 
-		if len(currentApp.Env) == 0 {
+		if len(env) == 0 {
 			fmt.Printf("No environment variables set for '%s'\n", appName)
 			return
 		}
 
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintf(w, "KEY\tVALUE\n")
-		for k, v := range currentApp.Env {
-			fmt.Fprintf(w, "%s\t%s\n", k, v)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\n", k, env[k])
 		}
 		w.Flush()
 	}
 }
 
+// fetchEnv returns an app's environment variables
+func fetchEnv(appName string) map[string]string {
+	resp, err := apiRequest("GET", "/api/apps/"+appName+"/env", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed to get environment: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var env map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	return env
+}
+
 func fetchApp(name string) app.App {
 	resp, err := apiRequest("GET", "/api/apps/"+name, nil)
 	if err != nil {
@@ -2708,12 +3761,9 @@ func fetchApp(name string) app.App {
 	return a
 }
 
-func updateEnv(appName string, env map[string]string) {
-	body := map[string]interface{}{
-		"env": env,
-	}
-
-	resp, err := apiRequest("PUT", "/api/apps/"+appName, body)
+// patchEnv applies an env update and reports whether the app was restarted
+func patchEnv(appName string, req map[string]interface{}) bool {
+	resp, err := apiRequest("PATCH", "/api/apps/"+appName+"/env", req)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -2725,6 +3775,12 @@ func updateEnv(appName string, env map[string]string) {
 		fmt.Fprintf(os.Stderr, "Failed to update environment: %s\n", string(respBody))
 		os.Exit(1)
 	}
+
+	var result struct {
+		Restarted bool `json:"restarted"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result.Restarted
 }
 
 func cmdHealth(args []string) {
@@ -2849,18 +3905,82 @@ func cmdHealth(args []string) {
 		}
 		json.NewDecoder(resp.Body).Decode(&hs)
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintf(w, "Status:\t%s\n", hs.Status)
-		fmt.Fprintf(w, "Last Check:\t%s\n", formatCLITime(hs.LastCheck))
-		fmt.Fprintf(w, "Last Success:\t%s\n", formatCLITime(hs.LastSuccess))
-		fmt.Fprintf(w, "Consecutive Failures:\t%d\n", hs.ConsecutiveFailures)
-		fmt.Fprintf(w, "Total Checks:\t%d\n", hs.TotalChecks)
-		fmt.Fprintf(w, "Total Failures:\t%d\n", hs.TotalFailures)
-		if hs.LastError != "" {
-			fmt.Fprintf(w, "Last Error:\t%s\n", hs.LastError)
-		}
-		w.Flush()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Status:\t%s\n", hs.Status)
+		fmt.Fprintf(w, "Last Check:\t%s\n", formatCLITime(hs.LastCheck))
+		fmt.Fprintf(w, "Last Success:\t%s\n", formatCLITime(hs.LastSuccess))
+		fmt.Fprintf(w, "Consecutive Failures:\t%d\n", hs.ConsecutiveFailures)
+		fmt.Fprintf(w, "Total Checks:\t%d\n", hs.TotalChecks)
+		fmt.Fprintf(w, "Total Failures:\t%d\n", hs.TotalFailures)
+		if hs.LastError != "" {
+			fmt.Fprintf(w, "Last Error:\t%s\n", hs.LastError)
+		}
+		w.Flush()
+	}
+}
+
+// cmdGit sets up push-to-deploy through the server's git receiver
+func cmdGit(args []string) {
+	if len(args) < 2 || args[0] != "remote" {
+		fmt.Fprintln(os.Stderr, `Usage:
+  bp git remote <name> [--remote basepod]  Add a git remote that deploys on push`)
+		os.Exit(1)
+	}
+	appName := args[1]
+	remote := "basepod"
+	for i := 2; i < len(args); i++ {
+		if args[i] == "--remote" && i+1 < len(args) {
+			remote = args[i+1]
+			i++
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	server, ok := cfg.Servers[cfg.CurrentContext]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Not logged in. Run: bp login <server>")
+		os.Exit(1)
+	}
+
+	// Check the app exists and find the branch it deploys from
+	resp, err := apiRequest("GET", fmt.Sprintf("/api/apps/%s", appName), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+		os.Exit(1)
+	}
+	var a struct {
+		Name       string `json:"name"`
+		Deployment struct {
+			Branch string `json:"branch"`
+		} `json:"deployment"`
+	}
+	json.NewDecoder(resp.Body).Decode(&a)
+	branch := a.Deployment.Branch
+	if branch == "" {
+		branch = "main"
+	}
+
+	gitURL := strings.TrimSuffix(server.URL, "/") + "/git/" + a.Name + ".git"
+	cmd := exec.Command("git", "remote", "add", remote, gitURL)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "git remote add failed: %s\n", strings.TrimSpace(string(out)))
+		os.Exit(1)
 	}
+
+	fmt.Printf("Added git remote %s -> %s\n", remote, gitURL)
+	fmt.Printf("\nDeploy with: git push %s %s\n", remote, branch)
+	fmt.Println("Git asks for a password: use a deploy token, e.g. from")
+	fmt.Printf("  bp token create %s-push --scopes deploy:%s\n", a.Name, a.Name)
 }
 
 func cmdWebhook(args []string) {
@@ -3032,6 +4152,11 @@ func formatCLITime(ts string) string {
 }
 
 func cmdInfo(args []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmdAppInfo(args[0])
+		return
+	}
+
 	resp, err := apiRequest("GET", "/api/system/info", nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -3052,6 +4177,11 @@ func cmdInfo(args []string) {
 }
 
 func cmdStatus(args []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmdAppStatus(args[0])
+		return
+	}
+
 	cfg, err := loadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
@@ -3091,6 +4221,21 @@ func cmdStatus(args []string) {
 	if caddyStatus, ok := info["caddy_status"].(string); ok {
 		fmt.Printf("  Caddy: %s\n", caddyStatus)
 	}
+	if cache, ok := info["pull_cache"].(map[string]interface{}); ok && cache["enabled"] == true {
+		state := "stopped"
+		if cache["running"] == true {
+			state = "running"
+		}
+		fmt.Printf("  Pull cache: %s on %v, %v/%v, %v hits / %v misses\n",
+			state, cache["mirror"], cache["formatted"], cache["max_size"], cache["hits"], cache["misses"])
+	}
+	if reg, ok := info["registry"].(map[string]interface{}); ok && reg["enabled"] == true {
+		state := "stopped"
+		if reg["running"] == true {
+			state = "running"
+		}
+		fmt.Printf("  Registry: %s on %v\n", state, reg["host"])
+	}
 	fmt.Println()
 
 	// Get apps
@@ -3124,6 +4269,138 @@ func cmdStatus(args []string) {
 	}
 }
 
+// cmdAppStatus shows one app's container, uptime, resource use, last deploy,
+// health and recent events and, when it isn't running, why its container
+// stopped
+func cmdAppStatus(name string) {
+	resp, err := apiRequest("GET", "/api/apps/"+name+"/status", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed to get app status: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var st struct {
+		Name      string        `json:"name"`
+		Status    app.AppStatus `json:"status"`
+		Image     string        `json:"image"`
+		Domain    string        `json:"domain"`
+		Container *struct {
+			ID            string `json:"id"`
+			State         string `json:"state"`
+			Running       bool   `json:"running"`
+			UptimeSeconds int64  `json:"uptime_seconds"`
+			RestartCount  int    `json:"restart_count"`
+		} `json:"container"`
+		Stats *struct {
+			CPUPercent float64 `json:"cpu_percent"`
+			MemUsage   int64   `json:"mem_usage"`
+			MemLimit   int64   `json:"mem_limit"`
+		} `json:"stats"`
+		LastDeploy *app.DeploymentRecord `json:"last_deploy"`
+		Health     *app.HealthStatus     `json:"health"`
+		LastExit   *app.ContainerExit    `json:"last_exit"`
+		Events     []watchEvent          `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("App: %s\n", st.Name)
+	fmt.Printf("  Status: %s\n", st.Status)
+	if st.Image != "" {
+		fmt.Printf("  Image: %s\n", st.Image)
+	}
+	if st.Domain != "" {
+		fmt.Printf("  Domain: %s\n", st.Domain)
+	}
+
+	if c := st.Container; c != nil {
+		fmt.Println()
+		fmt.Println("Container:")
+		fmt.Printf("  ID: %s\n", c.ID[:min(12, len(c.ID))])
+		fmt.Printf("  State: %s\n", c.State)
+		if c.Running {
+			fmt.Printf("  Uptime: %s\n", time.Duration(c.UptimeSeconds)*time.Second)
+		}
+		fmt.Printf("  Restarts: %d\n", c.RestartCount)
+		if st.Stats != nil {
+			fmt.Printf("  CPU: %.1f%%\n", st.Stats.CPUPercent)
+			if st.Stats.MemLimit > 0 {
+				fmt.Printf("  Memory: %s / %s\n", formatBytesHuman(st.Stats.MemUsage), formatBytesHuman(st.Stats.MemLimit))
+			} else {
+				fmt.Printf("  Memory: %s\n", formatBytesHuman(st.Stats.MemUsage))
+			}
+		}
+	}
+
+	if d := st.LastDeploy; d != nil {
+		fmt.Println()
+		fmt.Println("Last deploy:")
+		fmt.Printf("  %s, %s (%s ago)\n", d.Status, d.DeployedAt.Local().Format("2006-01-02 15:04:05"), time.Since(d.DeployedAt).Round(time.Second))
+		if d.CommitHash != "" {
+			commit := d.CommitHash
+			if d.Branch != "" {
+				commit = d.Branch + "@" + commit
+			}
+			if d.CommitMsg != "" {
+				commit += " " + d.CommitMsg
+			}
+			fmt.Printf("  Commit: %s\n", commit)
+		}
+		if d.Image != "" {
+			fmt.Printf("  Image: %s\n", d.Image)
+		}
+	}
+
+	if h := st.Health; h != nil {
+		fmt.Println()
+		fmt.Printf("Health: %s (%d/%d checks failed)\n", h.Status, h.TotalFailures, h.TotalChecks)
+		if h.LastError != "" {
+			fmt.Printf("  Last error: %s\n", h.LastError)
+		}
+	}
+
+	if e := st.LastExit; e != nil {
+		label := "Last exit"
+		if st.Status != app.StatusRunning {
+			label = "Why it stopped"
+		}
+		fmt.Println()
+		fmt.Printf("%s: %s\n", label, e.Reason)
+		if !e.FinishedAt.IsZero() {
+			fmt.Printf("  Stopped at: %s (%s ago)\n", e.FinishedAt.Local().Format("2006-01-02 15:04:05"), time.Since(e.FinishedAt).Round(time.Second))
+		}
+		if e.OOMKilled {
+			fmt.Println("  Hint: raise the app's memory limit or reduce its memory use")
+		}
+	} else if st.Status == app.StatusStopped || st.Status == app.StatusFailed {
+		fmt.Println()
+		fmt.Println("Why it stopped: unknown (no exit was recorded)")
+	}
+
+	var lines []string
+	for _, e := range st.Events {
+		if line := formatWatchEvent(e); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > 0 {
+		fmt.Println()
+		fmt.Println("Recent events:")
+		// Oldest first, as bp watch prints them
+		for i := len(lines) - 1; i >= 0; i-- {
+			fmt.Println("  " + lines[i])
+		}
+	}
+}
+
 // ==================== Template Commands ====================
 
 func cmdTemplates(args []string) {
@@ -3173,7 +4450,7 @@ func cmdTemplates(args []string) {
 
 func cmdTemplate(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: bp template <deploy|export> <name>")
+		fmt.Fprintln(os.Stderr, "Usage: bp template <deploy|export|upgrade> <name>")
 		os.Exit(1)
 	}
 
@@ -3185,22 +4462,25 @@ func cmdTemplate(args []string) {
 		cmdTemplateDeployCmd(subargs)
 	case "export":
 		cmdTemplateExport(subargs)
+	case "upgrade":
+		cmdTemplateUpgrade(subargs)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown template command: %s\n", subcmd)
-		fmt.Fprintln(os.Stderr, "Usage: bp template <deploy|export> <name>")
+		fmt.Fprintln(os.Stderr, "Usage: bp template <deploy|export|upgrade> <name>")
 		os.Exit(1)
 	}
 }
 
 func cmdTemplateDeployCmd(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: bp template deploy <template> [--name <name>] [--env KEY=value]")
+		fmt.Fprintln(os.Stderr, "Usage: bp template deploy <template> [--name <name>] [--env KEY=value] [--model <model>]")
 		os.Exit(1)
 	}
 
 	template := args[0]
 	name := ""
 	version := ""
+	model := ""
 	env := make(map[string]string)
 
 	for i := 1; i < len(args); i++ {
@@ -3210,6 +4490,11 @@ func cmdTemplateDeployCmd(args []string) {
 				name = args[i+1]
 				i++
 			}
+		case "--model", "-m":
+			if i+1 < len(args) {
+				model = args[i+1]
+				i++
+			}
 		case "--version", "-v":
 			if i+1 < len(args) {
 				version = args[i+1]
@@ -3238,6 +4523,7 @@ func cmdTemplateDeployCmd(args []string) {
 		"name":     name,
 		"version":  version,
 		"env":      env,
+		"model":    model,
 	}
 
 	fmt.Printf("Deploying template: %s...\n", template)
@@ -3635,7 +4921,7 @@ func cmdChat(args []string) {
 	fmt.Println("Connecting to LLM server...")
 
 	// Check if model is running
-	resp, err := apiRequest("GET", "/api/models/status", nil)
+	resp, err := apiRequest("GET", "/api/mlx/status", nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -3644,8 +4930,7 @@ func cmdChat(args []string) {
 
 	var status struct {
 		Running bool   `json:"running"`
-		Model   string `json:"model"`
-		URL     string `json:"url"`
+		Model   string `json:"active_model"`
 	}
 	json.NewDecoder(resp.Body).Decode(&status)
 
@@ -3687,15 +4972,31 @@ func cmdChat(args []string) {
 		}
 
 		fmt.Print("AI: ")
-		buf := make([]byte, 256)
-		for {
-			n, err := resp.Body.Read(buf)
-			if n > 0 {
-				fmt.Print(string(buf[:n]))
+		if resp.StatusCode != http.StatusOK {
+			var errResp struct {
+				Error string `json:"error"`
 			}
-			if err != nil {
+			json.NewDecoder(resp.Body).Decode(&errResp)
+			fmt.Print("Error: " + errResp.Error)
+		}
+
+		// Print content deltas from the SSE stream
+		scanner := bufio.NewScanner(resp.Body)
+		for resp.StatusCode == http.StatusOK && scanner.Scan() {
+			line := strings.TrimPrefix(scanner.Text(), "data: ")
+			if line == "[DONE]" {
 				break
 			}
+			var chunk struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+			}
+			if json.Unmarshal([]byte(line), &chunk) == nil && len(chunk.Choices) > 0 {
+				fmt.Print(chunk.Choices[0].Delta.Content)
+			}
 		}
 		resp.Body.Close()
 		fmt.Print("\n\n")
@@ -3795,21 +5096,28 @@ You can ask things like:
 // ==================== System Commands ====================
 
 func cmdPrune(args []string) {
-	all := false
+	aggressive := false
 	dryRun := false
+	keep := 0
 
-	for _, arg := range args {
-		switch arg {
-		case "--all":
-			all = true
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--aggressive", "--all":
+			aggressive = true
 		case "--dry-run":
 			dryRun = true
+		case "--keep":
+			if i+1 < len(args) {
+				keep, _ = strconv.Atoi(args[i+1])
+				i++
+			}
 		}
 	}
 
-	req := map[string]bool{
-		"all":    all,
-		"dryRun": dryRun,
+	req := map[string]interface{}{
+		"aggressive": aggressive,
+		"dryRun":     dryRun,
+		"keep":       keep,
 	}
 
 	if dryRun {
@@ -3824,15 +5132,54 @@ func cmdPrune(args []string) {
 		os.Exit(1)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
 
+	type pruneItem struct {
+		Kind   string `json:"kind"`
+		Name   string `json:"name"`
+		Size   int64  `json:"size"`
+		Reason string `json:"reason"`
+		Error  string `json:"error"`
+	}
 	var result struct {
-		ContainersRemoved int    `json:"containersRemoved"`
-		ImagesRemoved     int    `json:"imagesRemoved"`
-		VolumesRemoved    int    `json:"volumesRemoved"`
-		SpaceReclaimed    string `json:"spaceReclaimed"`
+		Remove            []pruneItem `json:"remove"`
+		Keep              []pruneItem `json:"keep"`
+		ContainersRemoved int         `json:"containersRemoved"`
+		ImagesRemoved     int         `json:"imagesRemoved"`
+		VolumesRemoved    int         `json:"volumesRemoved"`
+		SpaceReclaimed    string      `json:"spaceReclaimed"`
 	}
 	json.NewDecoder(resp.Body).Decode(&result)
 
+	if dryRun {
+		var size int64
+		for _, item := range result.Remove {
+			fmt.Printf("  - %-9s %s (%s)\n", item.Kind, item.Name, item.Reason)
+			size += item.Size
+		}
+		for _, item := range result.Keep {
+			fmt.Printf("  = %-9s %s (%s)\n", item.Kind, item.Name, item.Reason)
+		}
+		if len(result.Remove) == 0 {
+			fmt.Println("Nothing to remove")
+		} else {
+			fmt.Printf("\n%d to remove, about %s of images\n", len(result.Remove), formatBytesHuman(size))
+		}
+		if !aggressive {
+			fmt.Println("Pulled images and volumes no app uses are kept; add --aggressive to remove them too")
+		}
+		return
+	}
+
+	for _, item := range result.Remove {
+		if item.Error != "" {
+			fmt.Fprintf(os.Stderr, "Could not remove %s %s: %s\n", item.Kind, item.Name, item.Error)
+		}
+	}
 	fmt.Printf("Containers removed: %d\n", result.ContainersRemoved)
 	fmt.Printf("Images removed: %d\n", result.ImagesRemoved)
 	fmt.Printf("Volumes removed: %d\n", result.VolumesRemoved)
@@ -3854,7 +5201,25 @@ func cmdBackup(args []string) {
 
 	switch subcmd {
 	case "list", "ls":
+		if _, remote := flagSet(subargs, "--remote"); remote {
+			listRemoteBackups(flagValue(subargs, "--remote"))
+			return
+		}
 		listBackups()
+	case "targets":
+		listBackupTargets()
+	case "upload":
+		if len(subargs) < 1 || strings.HasPrefix(subargs[0], "-") {
+			fmt.Fprintln(os.Stderr, "Usage: bp backup upload <file> [--restore]")
+			os.Exit(1)
+		}
+		uploadBackup(subargs[0], subargs[1:])
+	case "push":
+		if len(subargs) < 1 {
+			fmt.Fprintln(os.Stderr, "Usage: bp backup push <backup-id> [--target <name>]")
+			os.Exit(1)
+		}
+		pushBackup(subargs[0], flagValue(subargs[1:], "--target"))
 	case "create", "new":
 		createBackup(subargs)
 	case "download", "get":
@@ -3892,23 +5257,37 @@ func printBackupHelp() {
   bp backup restore <id>      Restore from a backup
   bp backup download <id>     Download a backup file
   bp backup delete <id>       Delete a backup
+  bp backup upload <file>     Upload a downloaded backup file (--restore to restore it)
+  bp backup targets           List the server's backup targets (S3, SFTP, rclone)
+  bp backup push <id>         Upload a backup to a target (--target <name>)
+  bp backup list --remote [name]  List the backups on a target
 
 Create Options:
   --volumes      Include container volumes (default: true)
   --no-volumes   Exclude container volumes
   --builds       Include build sources
+  --upload       Upload the backup to a target afterwards
+  --target <name>  Target to upload to (default: the first)
 
 Restore Options:
   --no-database  Don't restore database
   --no-config    Don't restore config files
   --no-apps      Don't restore static sites
   --no-volumes   Don't restore container volumes
+  --from <name>  Download the backup from a target first
 
 Examples:
   bp backup create                    # Full backup
   bp backup create --no-volumes       # Backup without volumes
   bp backup restore 20260130-151200   # Full restore
-  bp backup restore 20260130-151200 --no-config  # Restore without config`)
+  bp backup restore 20260130-151200 --no-config  # Restore without config
+  bp backup create --upload --target offsite      # Back up off the server
+  bp backup restore 20260130-151200 --from offsite  # Rebuild from a target
+
+Moving to a new server:
+  bp backup download 20260130-151200              # On the old server's context
+  bp context new-server
+  bp backup upload basepod-backup-20260130-151200.tar.gz --restore`)
 }
 
 func listBackups() {
@@ -3979,6 +5358,7 @@ func listBackups() {
 func createBackup(args []string) {
 	includeVolumes := true
 	includeBuilds := false
+	upload := false
 
 	for _, arg := range args {
 		switch arg {
@@ -3988,14 +5368,18 @@ func createBackup(args []string) {
 			includeVolumes = false
 		case "--builds":
 			includeBuilds = true
+		case "--upload":
+			upload = true
 		}
 	}
 
 	fmt.Println("Creating backup...")
 
-	req := map[string]bool{
+	req := map[string]interface{}{
 		"include_volumes": includeVolumes,
 		"include_builds":  includeBuilds,
+		"upload":          upload,
+		"target":          flagValue(args, "--target"),
 	}
 
 	resp, err := apiRequest("POST", "/api/backups", req)
@@ -4012,10 +5396,11 @@ func createBackup(args []string) {
 	}
 
 	var result struct {
-		ID        string `json:"id"`
-		SizeHuman string `json:"size_human"`
-		Path      string `json:"path"`
-		Contents  struct {
+		ID         string `json:"id"`
+		SizeHuman  string `json:"size_human"`
+		Path       string `json:"path"`
+		UploadedTo string `json:"uploaded_to"`
+		Contents   struct {
 			Database    bool     `json:"database"`
 			Config      bool     `json:"config"`
 			StaticSites []string `json:"static_sites"`
@@ -4045,6 +5430,144 @@ func createBackup(args []string) {
 	if len(result.Contents.Volumes) > 0 {
 		fmt.Printf("    - Volumes: %s\n", strings.Join(result.Contents.Volumes, ", "))
 	}
+	if result.UploadedTo != "" {
+		fmt.Printf("  Uploaded to: %s\n", result.UploadedTo)
+	}
+}
+
+func listBackupTargets() {
+	var targets []struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Path     string `json:"path"`
+		Endpoint string `json:"endpoint"`
+		Bucket   string `json:"bucket"`
+		Host     string `json:"host"`
+		Remote   string `json:"remote"`
+	}
+	usersRequest("GET", "/api/backups/targets", nil, &targets)
+	if len(targets) == 0 {
+		fmt.Println("No backup targets. Add S3, SFTP or rclone targets under backup.targets in the server config.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tLOCATION")
+	for _, t := range targets {
+		location := t.Remote
+		switch t.Type {
+		case "s3":
+			location = t.Bucket
+			if t.Endpoint != "" {
+				location = t.Endpoint + "/" + t.Bucket
+			}
+		case "sftp":
+			location = t.Host
+		}
+		if t.Path != "" {
+			location += "/" + strings.Trim(t.Path, "/")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, t.Type, location)
+	}
+	w.Flush()
+}
+
+func listRemoteBackups(target string) {
+	var result struct {
+		Target  string `json:"target"`
+		Backups []struct {
+			ID        string    `json:"id"`
+			CreatedAt time.Time `json:"created_at"`
+			SizeHuman string    `json:"size_human"`
+		} `json:"backups"`
+	}
+	usersRequest("GET", "/api/backups/remote?target="+url.QueryEscape(target), nil, &result)
+	if len(result.Backups) == 0 {
+		fmt.Printf("No backups on %s.\n", result.Target)
+		return
+	}
+	fmt.Printf("%-20s %-20s %s\n", "ID", "CREATED", "SIZE")
+	fmt.Println(strings.Repeat("-", 50))
+	for _, b := range result.Backups {
+		fmt.Printf("%-20s %-20s %s\n", b.ID, b.CreatedAt.Format("2006-01-02 15:04:05"), b.SizeHuman)
+	}
+	fmt.Printf("\nRestore one with: bp backup restore <id> --from %s\n", result.Target)
+}
+
+// uploadBackup streams a backup file to the server and, with --restore,
+// restores it there; the other restore flags apply too
+func uploadBackup(path string, args []string) {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Stream the multipart body instead of buffering the archive
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		part, err := writer.CreateFormFile("backup", filepath.Base(path))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := newAPIRequest("", "POST", "/api/backups/upload", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	req.Body = pr
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	fmt.Printf("Uploading %s (%s)...\n", filepath.Base(path), formatBytesHuman(fi.Size()))
+	resp, err := http.DefaultClient.Do(req) // No timeout: archives with volumes can be large
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var result struct {
+		ID        string `json:"id"`
+		SizeHuman string `json:"size_human"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing response: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Uploaded backup %s (%s)\n", result.ID, result.SizeHuman)
+
+	if _, restore := flagSet(args, "--restore"); restore {
+		fmt.Println()
+		restoreBackup(result.ID, args)
+	} else {
+		fmt.Printf("\nRestore it with: bp backup restore %s\n", result.ID)
+	}
+}
+
+func pushBackup(id, target string) {
+	fmt.Printf("Uploading backup %s...\n", id)
+	var result struct {
+		UploadedTo string `json:"uploaded_to"`
+	}
+	usersRequest("POST", "/api/backups/"+id+"/upload", map[string]string{"target": target}, &result)
+	fmt.Printf("Uploaded to %s\n", result.UploadedTo)
 }
 
 func downloadBackup(id string) {
@@ -4123,6 +5646,7 @@ func restoreBackup(id string, args []string) {
 	restoreConfig := true
 	restoreApps := true
 	restoreVolumes := true
+	from := flagValue(args, "--from")
 
 	for _, arg := range args {
 		switch arg {
@@ -4138,7 +5662,11 @@ func restoreBackup(id string, args []string) {
 	}
 
 	// Confirm restore
-	fmt.Printf("Restoring from backup %s...\n", id)
+	if from != "" {
+		fmt.Printf("Restoring from backup %s on %s...\n", id, from)
+	} else {
+		fmt.Printf("Restoring from backup %s...\n", id)
+	}
 	fmt.Println("This will overwrite existing data. Current files will be backed up with .bak extension.")
 	fmt.Print("Continue? [y/N]: ")
 
@@ -4151,11 +5679,12 @@ func restoreBackup(id string, args []string) {
 
 	fmt.Println("\nRestoring...")
 
-	req := map[string]bool{
+	req := map[string]interface{}{
 		"restore_database": restoreDatabase,
 		"restore_config":   restoreConfig,
 		"restore_apps":     restoreApps,
 		"restore_volumes":  restoreVolumes,
+		"from":             from,
 	}
 
 	resp, err := apiRequest("POST", "/api/backups/"+id+"/restore", req)
@@ -4611,7 +6140,7 @@ func cmdNotifications(args []string) {
 			os.Exit(1)
 		}
 
-		events := []string{"deploy_success", "deploy_failed", "health_check_fail"}
+		events := []string{"deploy_success", "deploy_failed", "health_check_fail", "container_exit", "uptime_down", "uptime_up"}
 		if eventsStr != "" {
 			events = strings.Split(eventsStr, ",")
 		}
@@ -4690,38 +6219,27 @@ func cmdNotifications(args []string) {
 // --- Deploy Tokens Command ---
 
 func cmdTokens(args []string) {
-	if len(args) == 0 || args[0] == "list" {
-		resp, err := apiRequest("GET", "/api/deploy-tokens", nil)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			respBody, _ := io.ReadAll(resp.Body)
-			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
-			os.Exit(1)
-		}
-		var result struct {
-			Tokens []struct {
-				ID        string  `json:"id"`
-				Name      string  `json:"name"`
-				Prefix    string  `json:"prefix"`
-				Scopes    []string `json:"scopes"`
-				CreatedAt string  `json:"created_at"`
-			} `json:"tokens"`
-		}
-		json.NewDecoder(resp.Body).Decode(&result)
-
-		if len(result.Tokens) == 0 {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		tokens := fetchDeployTokens()
+		if len(tokens) == 0 {
 			fmt.Println("No deploy tokens.")
 			return
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintf(w, "ID\tNAME\tPREFIX\tSCOPES\n")
-		for _, t := range result.Tokens {
-			fmt.Fprintf(w, "%s\t%s\t%s...\t%s\n", t.ID[:8], t.Name, t.Prefix, strings.Join(t.Scopes, ","))
+		fmt.Fprintf(w, "ID\tNAME\tPREFIX\tSCOPES\tLAST USED\tEXPIRES\n")
+		for _, t := range tokens {
+			lastUsed, expires := "never", "never"
+			if t.LastUsedAt != nil {
+				lastUsed = t.LastUsedAt.Local().Format("2006-01-02 15:04")
+			}
+			if t.ExpiresAt != nil {
+				expires = t.ExpiresAt.Local().Format("2006-01-02")
+				if time.Now().After(*t.ExpiresAt) {
+					expires += " (expired)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s...\t%s\t%s\t%s\n", t.ID[:8], t.Name, t.Prefix, strings.Join(t.Scopes, ","), lastUsed, expires)
 		}
 		w.Flush()
 		return
@@ -4731,21 +6249,36 @@ func cmdTokens(args []string) {
 	switch subcmd {
 	case "create":
 		if len(args) < 2 {
-			fmt.Fprintln(os.Stderr, "Usage: bp token create <name> [--scopes deploy:*]")
+			fmt.Fprintln(os.Stderr, "Usage: bp token create <name> [--scope deploy|mcp:read|mcp:write|audio:transcribe|metrics:read] [--app <name>] [--expires 90d]")
 			os.Exit(1)
 		}
 		name := args[1]
-		scopes := []string{"deploy:*"}
+		var scopes, kinds, apps []string
+		expires := ""
 		for i := 2; i < len(args)-1; i++ {
-			if args[i] == "--scopes" {
-				scopes = strings.Split(args[i+1], ",")
+			switch args[i] {
+			case "--scopes":
+				scopes = append(scopes, strings.Split(args[i+1], ",")...)
+			case "--scope":
+				kinds = append(kinds, strings.Split(args[i+1], ",")...)
+			case "--app":
+				apps = append(apps, strings.Split(args[i+1], ",")...)
+			case "--expires":
+				expires = daysToHours(args[i+1])
 			}
 		}
+		scopes = append(scopes, tokenScopes(kinds, apps)...)
+		if len(scopes) == 0 {
+			scopes = []string{"deploy:*"}
+		}
 
 		body := map[string]interface{}{
 			"name":   name,
 			"scopes": scopes,
 		}
+		if expires != "" {
+			body["expires_in"] = expires
+		}
 		resp, err := apiRequest("POST", "/api/deploy-tokens", body)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -4758,22 +6291,35 @@ func cmdTokens(args []string) {
 			os.Exit(1)
 		}
 		var result struct {
-			Token   string `json:"token"`
-			Message string `json:"message"`
+			Token     string     `json:"token"`
+			Scopes    []string   `json:"scopes"`
+			ExpiresAt *time.Time `json:"expires_at"`
 		}
 		json.NewDecoder(resp.Body).Decode(&result)
 
 		fmt.Printf("Deploy Token: %s\n", result.Token)
+		fmt.Printf("Scopes: %s\n", strings.Join(result.Scopes, ","))
+		if result.ExpiresAt != nil {
+			fmt.Printf("Expires: %s\n", result.ExpiresAt.Local().Format("2006-01-02 15:04"))
+		}
 		fmt.Println("Save this token - it won't be shown again.")
 		fmt.Println("\nUse in CI/CD:")
 		fmt.Printf("  curl -X POST https://your-server/api/deploy -H 'Authorization: Bearer %s' ...\n", result.Token)
 
-	case "rm", "delete":
+	case "rm", "delete", "revoke":
 		if len(args) < 2 {
-			fmt.Fprintln(os.Stderr, "Usage: bp token rm <id>")
+			fmt.Fprintln(os.Stderr, "Usage: bp token rm <id|name>")
 			os.Exit(1)
 		}
-		resp, err := apiRequest("DELETE", fmt.Sprintf("/api/deploy-tokens/%s", args[1]), nil)
+		// Accept the short IDs and names bp tokens prints
+		id := args[1]
+		for _, t := range fetchDeployTokens() {
+			if t.ID == id || t.Name == id || (len(id) >= 8 && strings.HasPrefix(t.ID, id)) {
+				id = t.ID
+				break
+			}
+		}
+		resp, err := apiRequest("DELETE", fmt.Sprintf("/api/deploy-tokens/%s", id), nil)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -4792,6 +6338,47 @@ func cmdTokens(args []string) {
 	}
 }
 
+func fetchDeployTokens() []app.DeployToken {
+	resp, err := apiRequest("GET", "/api/deploy-tokens", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+		os.Exit(1)
+	}
+	var result struct {
+		Tokens []app.DeployToken `json:"tokens"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result.Tokens
+}
+
+// tokenScopes turns --scope and --app flags into token scopes: the deploy
+// scope is limited to the given apps, or covers every app without any
+func tokenScopes(kinds, apps []string) []string {
+	if len(kinds) == 0 && len(apps) > 0 {
+		kinds = []string{"deploy"}
+	}
+	var scopes []string
+	for _, kind := range kinds {
+		if kind != "deploy" {
+			scopes = append(scopes, kind)
+			continue
+		}
+		if len(apps) == 0 {
+			scopes = append(scopes, "deploy:*")
+		}
+		for _, a := range apps {
+			scopes = append(scopes, "deploy:"+a)
+		}
+	}
+	return scopes
+}
+
 func cmdAnalyze(args []string) {
 	if len(args) == 0 {
 		fmt.Fprintln(os.Stderr, "Usage: bp analyze <github-repo-url>")
@@ -4880,11 +6467,13 @@ func cmdMetrics(args []string) {
 
 	var result struct {
 		Current *struct {
-			CPUPercent float64 `json:"cpu_percent"`
-			MemUsage   int64   `json:"mem_usage"`
-			MemLimit   int64   `json:"mem_limit"`
-			NetInput   int64   `json:"net_input"`
-			NetOutput  int64   `json:"net_output"`
+			CPUPercent  float64 `json:"cpu_percent"`
+			MemUsage    int64   `json:"mem_usage"`
+			MemLimit    int64   `json:"mem_limit"`
+			NetInput    int64   `json:"net_input"`
+			NetOutput   int64   `json:"net_output"`
+			BlockInput  int64   `json:"block_input"`
+			BlockOutput int64   `json:"block_output"`
 		} `json:"current"`
 		Metrics []struct {
 			CPUPercent float64 `json:"cpu_percent"`
@@ -4900,6 +6489,8 @@ func cmdMetrics(args []string) {
 		fmt.Printf("  Memory:     %s / %s\n", formatBytesHuman(result.Current.MemUsage), formatBytesHuman(result.Current.MemLimit))
 		fmt.Printf("  Net In:     %s\n", formatBytesHuman(result.Current.NetInput))
 		fmt.Printf("  Net Out:    %s\n", formatBytesHuman(result.Current.NetOutput))
+		fmt.Printf("  Disk Read:  %s\n", formatBytesHuman(result.Current.BlockInput))
+		fmt.Printf("  Disk Write: %s\n", formatBytesHuman(result.Current.BlockOutput))
 	} else {
 		fmt.Printf("No live stats available for %s (not running?)\n", appName)
 	}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// cmdMCP runs a stdio MCP server that relays JSON-RPC messages to the
+// current server's /api/mcp endpoint, so desktop agents can launch `bp mcp`.
+func cmdMCP(args []string) {
+	readOnly := false
+	for _, arg := range args {
+		switch arg {
+		case "--read-only", "--readonly":
+			readOnly = true
+		case "-h", "--help":
+			fmt.Fprintln(os.Stderr, `Usage: bp mcp [--read-only]
+
+Runs an MCP (Model Context Protocol) server over stdio for the current context.
+Example client config:
+  {"mcpServers": {"basepod": {"command": "bp", "args": ["mcp", "--read-only"]}}}`)
+			return
+		}
+	}
+
+	client, server, err := getClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	token := ""
+	if cfg, err := loadConfig(); err == nil {
+		if srv, _, err := getCurrentServer(cfg); err == nil {
+			token = srv.Token
+		}
+	}
+	endpoint := strings.TrimSuffix(server, "/") + "/api/mcp"
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	out := bufio.NewWriter(os.Stdout)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.Unmarshal(line, &msg)
+
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(line))
+		if err != nil {
+			writeMCPError(out, msg.ID, err.Error())
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if readOnly {
+			req.Header.Set("X-MCP-Read-Only", "1")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			writeMCPError(out, msg.ID, err.Error())
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusAccepted || len(msg.ID) == 0 {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			writeMCPError(out, msg.ID, fmt.Sprintf("server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+			continue
+		}
+		out.Write(bytes.TrimSpace(body))
+		out.WriteByte('\n')
+		out.Flush()
+	}
+}
+
+func writeMCPError(out *bufio.Writer, id json.RawMessage, message string) {
+	if len(id) == 0 {
+		fmt.Fprintf(os.Stderr, "mcp: %s\n", message)
+		return
+	}
+	data, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]any{"code": -32603, "message": message},
+	})
+	out.Write(data)
+	out.WriteByte('\n')
+	out.Flush()
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// migrationPlan is what `bp migrate` will copy for one app
+type migrationPlan struct {
+	App     app.App
+	Volumes []volumeInfo
+	Secrets []string // Secret keys, which can't be read back and must be set again
+}
+
+// cmdMigrate copies apps with their settings, volume data and images from
+// the server behind one context to another, then guides the DNS cutover
+func cmdMigrate(args []string) {
+	usage := "Usage: bp migrate <source-context> <dest-context> [app...] [--no-volumes] [--stop-source] [--yes]"
+	var positional []string
+	noVolumes, stopSource, yes := false, false, false
+	for _, arg := range args {
+		switch arg {
+		case "--no-volumes":
+			noVolumes = true
+		case "--stop-source":
+			stopSource = true
+		case "--yes", "-y":
+			yes = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Fprintf(os.Stderr, "Unknown flag %s\n%s\n", arg, usage)
+				os.Exit(1)
+			}
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	src, dst := positional[0], positional[1]
+	if src == dst {
+		fmt.Fprintln(os.Stderr, "Error: source and destination are the same context")
+		os.Exit(1)
+	}
+	only := make(map[string]bool)
+	for _, name := range positional[2:] {
+		only[name] = true
+	}
+
+	// Work out what to copy before touching anything
+	var srcApps, dstApps app.AppListResponse
+	mustContextRequest(src, "GET", "/api/apps", nil, &srcApps)
+	mustContextRequest(dst, "GET", "/api/apps", nil, &dstApps)
+	existing := make(map[string]bool)
+	for _, a := range dstApps.Apps {
+		existing[a.Name] = true
+	}
+	var srcVolumes []volumeInfo
+	if !noVolumes {
+		mustContextRequest(src, "GET", "/api/volumes", nil, &srcVolumes)
+	}
+
+	var plans []migrationPlan
+	var skipped []string
+	found := make(map[string]bool)
+	for _, listed := range srcApps.Apps {
+		if len(only) > 0 && !only[listed.Name] {
+			continue
+		}
+		found[listed.Name] = true
+		if existing[listed.Name] {
+			skipped = append(skipped, listed.Name)
+			continue
+		}
+		plan := migrationPlan{App: fetchAppContext(src, listed.Name)}
+		for _, v := range srcVolumes {
+			if v.App == listed.Name {
+				plan.Volumes = append(plan.Volumes, v)
+			}
+		}
+		var secrets []app.SecretInfo
+		if err := contextRequest(src, "GET", "/api/apps/"+url.PathEscape(listed.Name)+"/secrets", nil, &secrets); err == nil {
+			for _, s := range secrets {
+				plan.Secrets = append(plan.Secrets, s.Key)
+			}
+		}
+		plans = append(plans, plan)
+	}
+	for name := range only {
+		if !found[name] {
+			fmt.Fprintf(os.Stderr, "Error: app '%s' not found on %s\n", name, src)
+			os.Exit(1)
+		}
+	}
+	for _, name := range skipped {
+		fmt.Printf("Skipping %s: it already exists on %s (use bp promote-config to sync its settings)\n", name, dst)
+	}
+	if len(plans) == 0 {
+		fmt.Printf("Nothing to migrate from %s to %s\n", src, dst)
+		return
+	}
+
+	printMigrationPlan(plans, src, dst, stopSource)
+	if !yes {
+		if answer := promptLine(fmt.Sprintf("\nMigrate %d app(s) from %s to %s? [y/N]: ", len(plans), src, dst)); strings.ToLower(answer) != "y" {
+			fmt.Println("Cancelled")
+			return
+		}
+	}
+
+	var failed []string
+	for _, plan := range plans {
+		fmt.Printf("\n==> %s\n", plan.App.Name)
+		if err := migrateApp(src, dst, plan, stopSource); err != nil {
+			fmt.Fprintf(os.Stderr, "    Failed: %v\n", err)
+			failed = append(failed, plan.App.Name)
+		}
+	}
+
+	fmt.Println()
+	if len(failed) > 0 {
+		fmt.Printf("Migrated %d of %d app(s); failed: %s\n", len(plans)-len(failed), len(plans), strings.Join(failed, ", "))
+	} else {
+		fmt.Printf("Migrated %d app(s) to %s\n", len(plans), dst)
+	}
+	guideCutover(plans, failed, src, dst, stopSource, yes)
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printMigrationPlan shows what each app brings along and how its image
+// gets to the new server
+func printMigrationPlan(plans []migrationPlan, src, dst string, stopSource bool) {
+	fmt.Printf("Migration plan: %s -> %s\n\n", src, dst)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APP\tDOMAIN\tIMAGE\tVOLUMES")
+	for _, p := range plans {
+		var volumes []string
+		for _, v := range p.Volumes {
+			volumes = append(volumes, fmt.Sprintf("%s (%s)", v.Name, v.Formatted))
+		}
+		vols := strings.Join(volumes, ", ")
+		if vols == "" {
+			vols = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.App.Name, p.App.Domain, migrationImageStep(p.App), vols)
+	}
+	w.Flush()
+
+	fmt.Println()
+	for _, p := range plans {
+		if len(p.Secrets) > 0 {
+			fmt.Printf("Note: %s has secrets that can't be copied; set them again on %s: %s\n", p.App.Name, dst, strings.Join(p.Secrets, ", "))
+		}
+		if len(p.App.Services) > 0 {
+			fmt.Printf("Note: %s runs services; redeploy it from its source with `bp deploy` against %s once migrated\n", p.App.Name, dst)
+		}
+	}
+	if stopSource {
+		fmt.Printf("Apps on %s are stopped before their data is copied, so they're down until DNS points at %s.\n", src, dst)
+	} else {
+		fmt.Printf("Volumes are copied while apps on %s keep running; writes after the copy aren't carried over (see --stop-source).\n", src)
+	}
+}
+
+// migrationImageStep says how an app's image reaches the new server
+func migrationImageStep(a app.App) string {
+	switch {
+	case a.Type == app.AppTypeMLX:
+		model := ""
+		if a.MLX != nil {
+			model = a.MLX.Model
+		}
+		return "download model " + model
+	case len(a.Services) > 0:
+		return "redeploy services"
+	case a.Image == "":
+		return "not deployed"
+	case isLocalImage(a.Image):
+		return "copy " + a.Image
+	default:
+		return "pull " + a.Image
+	}
+}
+
+// isLocalImage reports whether an image was built on the server, so it
+// can't be pulled elsewhere
+func isLocalImage(image string) bool {
+	return strings.HasPrefix(image, "localhost/") || strings.HasPrefix(image, "localhost:")
+}
+
+// migrateApp recreates one app on dst and copies its data and image
+func migrateApp(src, dst string, plan migrationPlan, stopSource bool) error {
+	a := plan.App
+	name := url.PathEscape(a.Name)
+
+	if stopSource {
+		fmt.Printf("    Stopping on %s\n", src)
+		if err := contextRequest(src, "POST", "/api/apps/"+name+"/stop", nil, nil); err != nil {
+			return fmt.Errorf("stop on %s: %w", src, err)
+		}
+	}
+
+	fmt.Println("    Creating app")
+	create := app.CreateAppRequest{
+		Name:      a.Name,
+		Type:      a.Type,
+		Domain:    a.Domain,
+		Image:     a.Image,
+		Env:       a.Env,
+		Port:      a.Ports.ContainerPort,
+		Memory:    a.Resources.Memory,
+		CPUs:      a.Resources.CPUs,
+		EnableSSL: a.SSL.Enabled,
+		Volumes:   a.Volumes,
+	}
+	if a.MLX != nil {
+		create.Model = a.MLX.Model
+	}
+	if err := contextRequest(dst, "POST", "/api/apps", create, nil); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	// Settings the create request doesn't cover
+	update := app.UpdateAppRequest{
+		Aliases:         &a.Aliases,
+		RedirectURL:     &a.RedirectURL,
+		Protocol:        &a.Ports.Protocol,
+		ExposeExternal:  &a.Ports.ExposeExternal,
+		HealthCheck:     a.HealthCheck,
+		Deployment:      &a.Deployment,
+		SEO:             a.SEO,
+		Privacy:         a.Privacy,
+		WebSocket:       a.WebSocket,
+		SecurityHeaders: a.SecurityHeaders,
+	}
+	if a.Ports.Protocol == "" {
+		update.Protocol = nil
+	}
+	if a.Resources.Replicas > 1 {
+		update.Replicas = &a.Resources.Replicas
+	}
+	if err := contextRequest(dst, "PUT", "/api/apps/"+name, update, nil); err != nil {
+		return fmt.Errorf("update settings: %w", err)
+	}
+
+	for _, v := range plan.Volumes {
+		fmt.Printf("    Copying volume %s (%s)\n", v.Name, v.Formatted)
+		if err := copyVolume(src, dst, v.Name, a.Name); err != nil {
+			return fmt.Errorf("volume %s: %w", v.Name, err)
+		}
+	}
+
+	if err := copyCronJobs(src, dst, a.Name); err != nil {
+		return fmt.Errorf("cron jobs: %w", err)
+	}
+
+	switch {
+	case a.Type == app.AppTypeMLX:
+		fmt.Println("    Deploying (the model downloads in the background)")
+		return contextRequest(dst, "POST", "/api/apps/"+name+"/deploy", map[string]string{}, nil)
+	case len(a.Services) > 0 || a.Image == "":
+		return nil
+	case isLocalImage(a.Image):
+		fmt.Printf("    Copying image %s\n", a.Image)
+		if err := copyImage(src, dst, a.Image); err != nil {
+			return fmt.Errorf("image: %w", err)
+		}
+		fmt.Println("    Starting")
+		return contextRequest(dst, "POST", "/api/apps/"+name+"/restart", nil, nil)
+	default:
+		fmt.Printf("    Pulling %s and deploying\n", a.Image)
+		return contextRequest(dst, "POST", "/api/apps/"+name+"/deploy", app.DeployRequest{Image: a.Image}, nil)
+	}
+}
+
+// copyVolume streams a volume's contents from src into the same volume on dst
+func copyVolume(src, dst, volume, appName string) error {
+	err := contextRequest(dst, "POST", "/api/volumes", map[string]string{"name": volume, "app": appName}, nil)
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return err
+	}
+	return streamBetween(src, "/api/volumes/"+url.PathEscape(volume)+"/export", dst, "PUT", "/api/volumes/"+url.PathEscape(volume)+"/import")
+}
+
+// copyImage streams a locally built image from src into dst's image store
+func copyImage(src, dst, image string) error {
+	return streamBetween(src, "/api/images/export?image="+url.QueryEscape(image), dst, "POST", "/api/images/import")
+}
+
+// copyCronJobs recreates an app's cron jobs, except the ones basepod.yaml
+// defines, which come back with the next deploy
+func copyCronJobs(src, dst, appName string) error {
+	var result struct {
+		Jobs []app.CronJob `json:"jobs"`
+	}
+	path := "/api/apps/" + url.PathEscape(appName) + "/cron"
+	if err := contextRequest(src, "GET", path, nil, &result); err != nil {
+		return err
+	}
+	for _, job := range result.Jobs {
+		if job.Source == "config" {
+			continue
+		}
+		enabled := job.Enabled
+		body := map[string]interface{}{
+			"name":     job.Name,
+			"schedule": job.Schedule,
+			"action":   job.Action,
+			"command":  job.Command,
+			"enabled":  &enabled,
+		}
+		if err := contextRequest(dst, "POST", path, body, nil); err != nil {
+			return fmt.Errorf("%s: %w", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// streamBetween pipes a GET response from one server into a request to
+// another without buffering it locally
+func streamBetween(src, srcPath, dst, method, dstPath string) error {
+	req, err := newAPIRequest(src, "GET", srcPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req) // No timeout: archives can be large
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", src, readAPIError(resp))
+	}
+
+	out, err := newAPIRequest(dst, method, dstPath, nil)
+	if err != nil {
+		return err
+	}
+	out.Body = resp.Body
+	out.ContentLength = resp.ContentLength
+	out.Header.Set("Content-Type", "application/x-tar")
+	outResp, err := http.DefaultClient.Do(out)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	defer outResp.Body.Close()
+	if outResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", dst, readAPIError(outResp))
+	}
+	return nil
+}
+
+// contextRequest makes a JSON API request against a named context without a
+// client timeout, since deploys can take a while
+func contextRequest(contextName, method, path string, body, out interface{}) error {
+	req, err := newAPIRequest(contextName, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", readAPIError(resp))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// mustContextRequest is contextRequest that exits on failure
+func mustContextRequest(contextName, method, path string, body, out interface{}) {
+	if err := contextRequest(contextName, method, path, body, out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error (%s): %v\n", contextName, err)
+		os.Exit(1)
+	}
+}
+
+// readAPIError returns the error message of a failed API response
+func readAPIError(resp *http.Response) string {
+	data, _ := io.ReadAll(resp.Body)
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+		return apiErr.Error
+	}
+	if msg := strings.TrimSpace(string(data)); msg != "" {
+		return msg
+	}
+	return resp.Status
+}
+
+// guideCutover lists the DNS records to change and, interactively, checks
+// them until every domain resolves to the new server
+func guideCutover(plans []migrationPlan, failed []string, src, dst string, stopSource, yes bool) {
+	skip := make(map[string]bool)
+	for _, name := range failed {
+		skip[name] = true
+	}
+	domainApps := make(map[string]string)
+	for _, p := range plans {
+		if skip[p.App.Name] {
+			continue
+		}
+		for _, d := range append([]string{p.App.Domain}, p.App.Aliases...) {
+			if d != "" {
+				domainApps[d] = p.App.Name
+			}
+		}
+	}
+	if len(domainApps) == 0 {
+		return
+	}
+	domains := make([]string, 0, len(domainApps))
+	for d := range domainApps {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+
+	targets, err := contextAddresses(dst)
+	if err != nil {
+		fmt.Printf("\nPoint these domains at %s: %s\n", dst, strings.Join(domains, ", "))
+		return
+	}
+
+	fmt.Printf("\nDNS cutover: point these records at %s (%s)\n", dst, strings.Join(targets, ", "))
+	for {
+		pending := checkCutover(domains, targets)
+		if pending == 0 {
+			fmt.Printf("\nAll domains resolve to %s.\n", dst)
+			if !stopSource && !yes {
+				stopMigratedApps(plans, skip, src)
+			}
+			return
+		}
+		if yes {
+			fmt.Printf("\n%d domain(s) still point elsewhere; update their DNS records to finish the cutover.\n", pending)
+			return
+		}
+		if answer := promptLine(fmt.Sprintf("\n%d domain(s) still point elsewhere. Update DNS, then press Enter to check again (or type 'done' to finish): ", pending)); strings.EqualFold(answer, "done") {
+			return
+		}
+	}
+}
+
+// stopMigratedApps offers to stop the old copies once DNS has moved over
+func stopMigratedApps(plans []migrationPlan, skip map[string]bool, src string) {
+	if answer := promptLine(fmt.Sprintf("Stop the migrated apps on %s now? Do this once cached DNS has expired [y/N]: ", src)); strings.ToLower(answer) != "y" {
+		return
+	}
+	for _, p := range plans {
+		if skip[p.App.Name] {
+			continue
+		}
+		if err := contextRequest(src, "POST", "/api/apps/"+url.PathEscape(p.App.Name)+"/stop", nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to stop %s on %s: %v\n", p.App.Name, src, err)
+			continue
+		}
+		fmt.Printf("Stopped %s on %s\n", p.App.Name, src)
+	}
+}
+
+// checkCutover prints where each domain resolves and returns how many
+// don't resolve to the new server yet
+func checkCutover(domains, targets []string) int {
+	want := make(map[string]bool)
+	for _, t := range targets {
+		want[t] = true
+	}
+	pending := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOMAIN\tRESOLVES TO\tSTATUS")
+	for _, d := range domains {
+		addrs, err := net.LookupHost(d)
+		status := "pending"
+		resolved := strings.Join(addrs, ", ")
+		if err != nil {
+			resolved = "-"
+		}
+		for _, addr := range addrs {
+			if want[addr] {
+				status = "ok"
+				break
+			}
+		}
+		if status != "ok" {
+			pending++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d, resolved, status)
+	}
+	w.Flush()
+	return pending
+}
+
+// contextAddresses resolves the IP addresses of a context's server
+func contextAddresses(contextName string) ([]string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	server, ok := cfg.Servers[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context '%s' not found", contextName)
+	}
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		return nil, err
+	}
+	return net.LookupHost(u.Hostname())
+}
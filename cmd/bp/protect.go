@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// protectionInfo is what `bp protect` shows about an app's basic auth
+type protectionInfo struct {
+	Enabled  bool   `json:"enabled"`
+	Username string `json:"username"`
+}
+
+// cmdProtect puts a username and password in front of an app's domains,
+// e.g. to keep a staging site private
+func cmdProtect(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: bp protect <app> --user <name> [--pass <password>]")
+		fmt.Fprintln(os.Stderr, "       bp protect <app>          Show whether the app asks for a password")
+		fmt.Fprintln(os.Stderr, "       bp protect <app> --off    Make the app public again")
+		os.Exit(1)
+	}
+	name := args[0]
+	path := "/api/apps/" + url.PathEscape(name) + "/protect"
+	var info protectionInfo
+
+	if _, off := flagSet(args[1:], "--off"); off {
+		usersRequest("DELETE", path, nil, &info)
+		fmt.Printf("%s is public again\n", name)
+		return
+	}
+	user := flagValue(args[1:], "--user")
+	if user == "" {
+		usersRequest("GET", path, nil, &info)
+		if info.Enabled {
+			fmt.Printf("%s asks for a password (user %s)\n", name, info.Username)
+		} else {
+			fmt.Printf("%s is public; protect it with: bp protect %s --user <name>\n", name, name)
+		}
+		return
+	}
+
+	pass := flagValue(args[1:], "--pass")
+	if pass == "" {
+		pass = promptSecret("Password: ")
+		if pass == "" || promptSecret("Repeat password: ") != pass {
+			fmt.Fprintln(os.Stderr, "Passwords are empty or don't match")
+			os.Exit(1)
+		}
+	}
+	usersRequest("PUT", path, app.ProtectRequest{Username: user, Password: pass}, &info)
+	fmt.Printf("%s now asks for a password (user %s)\n", name, info.Username)
+	fmt.Printf("Make it public again with: bp protect %s --off\n", name)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// purgeResult is what `bp purge` reports
+type purgeResult struct {
+	Files         int `json:"files"`
+	Removed       int `json:"removed"`
+	Precompressed int `json:"precompressed"`
+}
+
+// cmdPurge clears a static site's server-side caches and gives its files new
+// ETags, e.g. after they were changed outside a deploy
+func cmdPurge(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: bp purge <app>")
+		os.Exit(1)
+	}
+	name := args[0]
+	var result purgeResult
+	usersRequest("POST", "/api/apps/"+url.PathEscape(name)+"/purge", nil, &result)
+	fmt.Printf("Purged %s: %d files get new ETags, %d compressed copies dropped", name, result.Files, result.Removed)
+	if result.Precompressed > 0 {
+		fmt.Printf(", %d recompressed", result.Precompressed)
+	}
+	fmt.Println()
+}
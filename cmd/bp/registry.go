@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// cmdRegistry lists and cleans up the images in the built-in registry
+func cmdRegistry(args []string) {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		cmdRegistryList()
+		return
+	}
+	switch args[0] {
+	case "gc":
+		dryRun := len(args) > 1 && args[1] == "--dry-run"
+		var result struct {
+			Deleted []string `json:"deleted"`
+			Kept    int      `json:"kept"`
+		}
+		usersRequest("POST", "/api/registry/gc", map[string]bool{"dry_run": dryRun}, &result)
+		verb := "Deleted"
+		if dryRun {
+			verb = "Would delete"
+		}
+		for _, ref := range result.Deleted {
+			fmt.Printf("  %s\n", ref)
+		}
+		fmt.Printf("%s %d tag(s), kept %d still used by deployments\n", verb, len(result.Deleted), result.Kept)
+	case "rm", "delete":
+		if len(args) < 2 || !strings.Contains(args[1], ":") {
+			fmt.Fprintln(os.Stderr, "Usage: bp registry rm <app>:<tag>")
+			os.Exit(1)
+		}
+		appName, tag, _ := strings.Cut(args[1], ":")
+		usersRequest("DELETE", "/api/registry/"+appName+"/tags/"+tag, nil, nil)
+		fmt.Printf("Deleted %s\n", args[1])
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: bp registry [gc [--dry-run] | rm <app>:<tag>]")
+		os.Exit(1)
+	}
+}
+
+func cmdRegistryList() {
+	var result struct {
+		Registry struct {
+			Enabled bool   `json:"enabled"`
+			Running bool   `json:"running"`
+			Host    string `json:"host"`
+		} `json:"registry"`
+		Repositories []struct {
+			App  string   `json:"app"`
+			Tags []string `json:"tags"`
+		} `json:"repositories"`
+	}
+	usersRequest("GET", "/api/registry", nil, &result)
+	if !result.Registry.Enabled {
+		fmt.Println("The built-in registry is off. Set podman.registry.enabled in the server config to turn it on.")
+		return
+	}
+	if !result.Registry.Running {
+		fmt.Printf("Registry on %s is not running\n", result.Registry.Host)
+		return
+	}
+	if len(result.Repositories) == 0 {
+		fmt.Printf("No images in the registry on %s yet\n", result.Registry.Host)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APP\tTAGS")
+	for _, repo := range result.Repositories {
+		fmt.Fprintf(w, "%s\t%s\n", repo.App, strings.Join(repo.Tags, ", "))
+	}
+	w.Flush()
+	fmt.Printf("\nPull with: podman pull --tls-verify=false %s/basepod/<app>:<tag>\n", result.Registry.Host)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// cmdReleases lists an app's deployments with the size of each built image
+func cmdReleases(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bp releases <app>")
+		os.Exit(1)
+	}
+
+	a := fetchApp(args[0])
+	if len(a.Deployments) == 0 {
+		fmt.Println("No releases yet.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDEPLOYED\tCOMMIT\tSTATUS\tSIZE\tCHANGE\tLAYERS\tSNAPSHOT")
+	for i, d := range a.Deployments {
+		size, change, layers := "-", "-", "-"
+		if d.ImageReport != nil {
+			size = formatImageSize(d.ImageReport.Size)
+			layers = fmt.Sprintf("%d", d.ImageReport.Layers)
+			if prev := previousReport(a.Deployments[i+1:]); prev != nil && prev.Size > 0 {
+				change = fmt.Sprintf("%+.0f%%", (float64(d.ImageReport.Size)-float64(prev.Size))/float64(prev.Size)*100)
+			}
+		}
+		commit := d.CommitHash
+		if commit == "" {
+			commit = "-"
+		}
+		snapshot := d.SnapshotID
+		if snapshot == "" {
+			snapshot = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", d.ID, d.DeployedAt.Local().Format("2006-01-02 15:04"), commit, d.Status, size, change, layers, snapshot)
+	}
+	w.Flush()
+
+	if latest := a.Deployments[0].ImageReport; latest != nil && len(latest.LargestLayers) > 0 {
+		fmt.Println("\nLargest layers (latest release):")
+		printLargestLayers(latest)
+	}
+}
+
+// printImageSummary shows the size of the image the last deploy built. It is
+// best effort: the deploy already succeeded, so errors are ignored.
+func printImageSummary(contextName, appName string) {
+	resp, err := apiRequestContext(contextName, "GET", "/api/apps/"+appName, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	var a app.App
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil || len(a.Deployments) == 0 {
+		return
+	}
+
+	report := a.Deployments[0].ImageReport
+	if report == nil {
+		return
+	}
+	fmt.Printf("Image: %s, %d layers", formatImageSize(report.Size), report.Layers)
+	if prev := previousReport(a.Deployments[1:]); prev != nil && prev.Size > 0 {
+		fmt.Printf(" (was %s, %+.0f%%)", formatImageSize(prev.Size), (float64(report.Size)-float64(prev.Size))/float64(prev.Size)*100)
+	}
+	fmt.Println()
+	printLargestLayers(report)
+}
+
+func printLargestLayers(report *app.ImageReport) {
+	for _, l := range report.LargestLayers {
+		fmt.Printf("  %10s  %s\n", formatImageSize(l.Size), l.CreatedBy)
+	}
+}
+
+// previousReport returns the most recent image report in older deployments
+func previousReport(deployments []app.DeploymentRecord) *app.ImageReport {
+	for _, d := range deployments {
+		if d.ImageReport != nil {
+			return d.ImageReport
+		}
+	}
+	return nil
+}
+
+// formatImageSize uses decimal units to match `podman images`
+func formatImageSize(b int64) string {
+	const unit = 1000
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// domainRoute mirrors a route rule from /api/domains/{domain}/routes
+type domainRoute struct {
+	ID          string `json:"id"`
+	PathPrefix  string `json:"path_prefix"`
+	HeaderName  string `json:"header_name"`
+	HeaderValue string `json:"header_value"`
+	AppName     string `json:"app_name"`
+	StripPrefix bool   `json:"strip_prefix"`
+	Priority    int    `json:"priority"`
+}
+
+// cmdRoute manages path and header routing of one domain to several apps
+func cmdRoute(args []string) {
+	usage := `Usage:
+  bp route add <domain> <path> <app> [--strip] [--priority N] [--header "Name: value"]
+                                   Send requests for <path> on <domain> to <app>
+  bp route list <domain>           Show a domain's routes in the order they're tried
+  bp route rm <domain> <id|path>   Remove a route`
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 4 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+		cmdRouteAdd(args[1], args[2], args[3], args[4:])
+	case "list", "ls":
+		printDomainRoutes(fetchDomainRoutes(args[1]))
+	case "rm", "remove", "delete":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+		cmdRouteRemove(args[1], args[2])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// cmdRouteAdd creates a route rule
+func cmdRouteAdd(domain, path, appName string, args []string) {
+	body := map[string]interface{}{"path": path, "app": appName}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--strip":
+			body["strip_prefix"] = true
+		case "--priority":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Error: --priority must be a number")
+					os.Exit(1)
+				}
+				body["priority"] = n
+				i++
+			}
+		case "--header":
+			if i+1 < len(args) {
+				name, value, _ := strings.Cut(args[i+1], ":")
+				body["header_name"] = strings.TrimSpace(name)
+				body["header_value"] = strings.TrimSpace(value)
+				i++
+			}
+		}
+	}
+
+	resp, err := apiRequest("POST", "/api/domains/"+url.PathEscape(domain)+"/routes", body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+		os.Exit(1)
+	}
+
+	fmt.Printf("Routed %s%s to '%s'\n\n", domain, path, appName)
+	printDomainRoutes(fetchDomainRoutes(domain))
+}
+
+// cmdRouteRemove deletes a route rule by ID or path prefix
+func cmdRouteRemove(domain, target string) {
+	var matches []domainRoute
+	for _, r := range fetchDomainRoutes(domain) {
+		if r.ID == target || strings.HasPrefix(r.ID, target) || r.PathPrefix == strings.TrimRight(target, "/") || (target == "/" && r.PathPrefix == "/") {
+			matches = append(matches, r)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Fprintf(os.Stderr, "No route %s on %s\n", target, domain)
+		os.Exit(1)
+	}
+	if len(matches) > 1 {
+		fmt.Fprintf(os.Stderr, "%s matches %d routes; remove one by ID (bp route list %s)\n", target, len(matches), domain)
+		os.Exit(1)
+	}
+
+	resp, err := apiRequest("DELETE", "/api/domains/"+url.PathEscape(domain)+"/routes/"+matches[0].ID, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	fmt.Printf("Removed route %s%s\n", domain, matches[0].PathPrefix)
+}
+
+func fetchDomainRoutes(domain string) []domainRoute {
+	resp, err := apiRequest("GET", "/api/domains/"+url.PathEscape(domain)+"/routes", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var routes []domainRoute
+	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	return routes
+}
+
+func printDomainRoutes(routes []domainRoute) {
+	if len(routes) == 0 {
+		fmt.Println("No routes. Add one with: bp route add <domain> <path> <app>")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPATH\tHEADER\tAPP\tSTRIP\tPRIORITY")
+	for _, r := range routes {
+		header, strip := "-", "no"
+		if r.HeaderName != "" {
+			header = r.HeaderName + ": " + r.HeaderValue
+			if r.HeaderValue == "" {
+				header = r.HeaderName + ": *"
+			}
+		}
+		if r.StripPrefix {
+			strip = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", r.ID[:8], r.PathPrefix, header, r.AppName, strip, r.Priority)
+	}
+	w.Flush()
+}
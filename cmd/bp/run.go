@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serviceWaitTimeout bounds how long `bp run` waits for a dependency to come up
+const serviceWaitTimeout = 60 * time.Second
+
+// defaultEnvFiles are loaded by `bp run` when basepod.yaml doesn't list env_file
+var defaultEnvFiles = []string{".env", ".env.local"}
+
+// parseEnvFile reads KEY=VALUE lines, skipping blanks and comments. Values may
+// be quoted and lines may start with "export ".
+func parseEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		env[key] = value
+	}
+	return env, scanner.Err()
+}
+
+// loadEnvFiles merges env files in order (later files win). With no files
+// listed, .env and .env.local plus .env.<env> are tried; listed files must exist.
+func loadEnvFiles(dir string, files []string, envName string) (map[string]string, error) {
+	optional := len(files) == 0
+	if optional {
+		files = append([]string{}, defaultEnvFiles...)
+		if envName != "" {
+			files = append(files, ".env."+envName)
+		}
+	}
+
+	merged := make(map[string]string)
+	for _, file := range files {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, file)
+		}
+		env, err := parseEnvFile(path)
+		if err != nil {
+			if optional && os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("env file %s: %w", file, err)
+		}
+		fmt.Printf("Loaded env file: %s\n", file)
+		for k, v := range env {
+			merged[k] = v
+		}
+	}
+	return merged, nil
+}
+
+// mergeEnv layers env maps; later maps win
+func mergeEnv(layers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, layer := range layers {
+		for k, v := range layer {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// orderServices sorts services so each one starts after its depends_on
+func orderServices(services map[string]*ServiceConfig) ([]string, error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var order []string
+	state := make(map[string]int) // 0 = unvisited, 1 = visiting, 2 = done
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		deps := append([]string{}, services[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, ok := services[dep]; !ok {
+				return fmt.Errorf("service %s depends on unknown service %s", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// selectServices returns the named services plus everything they depend on
+func selectServices(services map[string]*ServiceConfig, names []string) (map[string]*ServiceConfig, error) {
+	selected := make(map[string]*ServiceConfig)
+	var add func(name string) error
+	add = func(name string) error {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil
+		}
+		if _, ok := selected[name]; ok {
+			return nil
+		}
+		svc, ok := services[name]
+		if !ok {
+			return fmt.Errorf("unknown service %q", name)
+		}
+		selected[name] = svc
+		for _, dep := range svc.DependsOn {
+			if err := add(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+	return selected, nil
+}
+
+// serviceAliases are the hostnames a service answers to on the local network:
+// its short name plus the container name it gets on the server
+func serviceAliases(appName, service string) []string {
+	return []string{service, appName + "-" + service, "basepod-" + appName + "-" + service}
+}
+
+// waitForService waits until a container is healthy (if it defines a
+// healthcheck), or its ready check passes on its published port: the HTTP
+// path answers, or else the port accepts connections
+func waitForService(container string, hostPort int, ready *ServiceReady) error {
+	timeout := serviceWaitTimeout
+	if ready != nil && ready.Timeout > 0 {
+		timeout = time.Duration(ready.Timeout) * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		out, err := exec.Command("podman", "inspect", "-f", "{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}", container).Output()
+		if err != nil {
+			return fmt.Errorf("inspect %s: %w", container, err)
+		}
+		fields := strings.Fields(string(out))
+		if len(fields) > 0 && fields[0] == "exited" {
+			return fmt.Errorf("%s exited; check `podman logs %s`", container, container)
+		}
+
+		if len(fields) > 1 && fields[1] != "" {
+			if fields[1] == "healthy" {
+				return nil
+			}
+		} else if len(fields) > 0 && fields[0] == "running" {
+			if hostPort == 0 {
+				return nil
+			}
+			if ready != nil && ready.HTTP != "" {
+				if httpReady(hostPort, ready.HTTP) {
+					return nil
+				}
+			} else if conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", hostPort), time.Second); err == nil {
+				conn.Close()
+				return nil
+			}
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("%s not ready after %s", container, timeout)
+}
+
+// httpReady reports whether path on a local port answers with a status below 400
+func httpReady(port int, path string) bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < 400
+}
+
+// devMode holds the `bp run --mount-src` settings
+type devMode struct {
+	Rebuild bool // Rebuild the image even though one exists
+}
+
+// skipBuild reports whether a dev run can reuse an existing image
+func (d *devMode) skipBuild(imageName string) bool {
+	if d == nil || d.Rebuild {
+		return false
+	}
+	return exec.Command("podman", "image", "exists", imageName).Run() == nil
+}
+
+// devMountArgs bind-mounts srcDir over the image's working directory so edits
+// show up in the container without a rebuild. Dependencies installed in the
+// image (node_modules, .venv) are kept in anonymous volumes so the mount
+// doesn't hide them.
+func devMountArgs(imageName, srcDir string) []string {
+	workdir := "/app"
+	if out, err := exec.Command("podman", "image", "inspect", "-f", "{{.Config.WorkingDir}}", imageName).Output(); err == nil {
+		if wd := strings.TrimSpace(string(out)); wd != "" && wd != "/" {
+			workdir = wd
+		}
+	}
+
+	args := []string{"-v", srcDir + ":" + workdir, "-w", workdir}
+	for _, deps := range []string{"node_modules", ".venv"} {
+		args = append(args, "-v", workdir+"/"+deps)
+	}
+	return args
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// cmdSchedule manages restart/redeploy schedules, which are cron jobs with a
+// restart or redeploy action instead of a command
+func cmdSchedule(args []string) {
+	usage := `Usage:
+  bp schedule <app>                                List restart/redeploy schedules
+  bp schedule add <app> <restart|redeploy> <cron>  Add a schedule, e.g. "0 4 * * *" or "@weekly"
+  bp schedule rm <app> <id>                        Remove a schedule`
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 4 {
+			fmt.Fprintln(os.Stderr, `Usage: bp schedule add <app> <restart|redeploy> "<cron>"`)
+			os.Exit(1)
+		}
+		appName, action, schedule := args[1], args[2], args[3]
+		if action != "restart" && action != "redeploy" {
+			fmt.Fprintf(os.Stderr, "Unknown action %q: use restart or redeploy\n", action)
+			os.Exit(1)
+		}
+
+		body := map[string]interface{}{
+			"name":     action,
+			"action":   action,
+			"schedule": schedule,
+		}
+		resp, err := apiRequest("POST", fmt.Sprintf("/api/apps/%s/cron", appName), body)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+			os.Exit(1)
+		}
+
+		var job scheduleJob
+		json.NewDecoder(resp.Body).Decode(&job)
+		fmt.Printf("Scheduled %s for %s (%s)", action, appName, schedule)
+		if job.NextRun != nil {
+			fmt.Printf(", next run %s", job.NextRun.Local().Format("Mon Jan 2 15:04"))
+		}
+		fmt.Println()
+
+	case "rm", "delete":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp schedule rm <app> <id>")
+			os.Exit(1)
+		}
+		// Schedules are cron jobs, so removal is the same call
+		cmdCron(args)
+
+	default:
+		appName := args[0]
+		resp, err := apiRequest("GET", fmt.Sprintf("/api/apps/%s/cron", appName), nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+			os.Exit(1)
+		}
+
+		var result struct {
+			Jobs []scheduleJob `json:"jobs"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\tACTION\tSCHEDULE\tNEXT RUN\tLAST STATUS\n")
+		count := 0
+		for _, job := range result.Jobs {
+			if job.Action != "restart" && job.Action != "redeploy" {
+				continue
+			}
+			next := "-"
+			if job.NextRun != nil && job.Enabled {
+				next = job.NextRun.Local().Format("Mon Jan 2 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", job.ID[:8], job.Action, job.Schedule, next, job.LastStatus)
+			count++
+		}
+		if count == 0 {
+			fmt.Println("No schedules configured.")
+			return
+		}
+		w.Flush()
+	}
+}
+
+// scheduleJob is the subset of a cron job shown by `bp schedule`
+type scheduleJob struct {
+	ID         string     `json:"id"`
+	Action     string     `json:"action"`
+	Schedule   string     `json:"schedule"`
+	Enabled    bool       `json:"enabled"`
+	NextRun    *time.Time `json:"next_run"`
+	LastStatus string     `json:"last_status"`
+}
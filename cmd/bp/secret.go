@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// cmdSecret manages an app's encrypted secrets. Values are read from the
+// terminal or stdin, never from arguments, so they stay out of shell history.
+func cmdSecret(args []string) {
+	usage := `Usage:
+  bp secret list <app>                    List secret names
+  bp secret set <app> KEY [--restart]     Set a secret (value read from the terminal or stdin)
+  bp secret unset <app> KEY [--restart]   Remove a secret
+
+Secrets are encrypted at rest and injected into the app's containers like env
+vars. Their values can't be read back. --restart replaces the running container
+so it picks up the change.
+
+Example:
+  echo -n "$DB_PASSWORD" | bp secret set myapp DB_PASSWORD --restart`
+	restart := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--restart" {
+			restart = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	if len(rest) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	appName := rest[1]
+
+	switch rest[0] {
+	case "list", "ls":
+		cmdSecretList(appName)
+	case "set":
+		if len(rest) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+		value := readSecretValue(rest[2])
+		resp, err := apiRequest("PUT", "/api/apps/"+appName+"/secrets/"+rest[2], map[string]interface{}{
+			"value":   value,
+			"restart": restart,
+		})
+		finishSecretCommand(resp, err, fmt.Sprintf("Secret %s set for '%s'", rest[2], appName), appName)
+	case "unset", "rm":
+		if len(rest) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+		path := "/api/apps/" + appName + "/secrets/" + rest[2]
+		if restart {
+			path += "?restart=true"
+		}
+		resp, err := apiRequest("DELETE", path, nil)
+		finishSecretCommand(resp, err, fmt.Sprintf("Secret %s removed from '%s'", rest[2], appName), appName)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// readSecretValue prompts for a value without echoing it, or reads all of
+// stdin when it isn't a terminal
+func readSecretValue(name string) string {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Printf("Value for %s: ", name)
+		value, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return string(value)
+	}
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return strings.TrimRight(string(value), "\r\n")
+}
+
+// finishSecretCommand reports the result of a secret change
+func finishSecretCommand(resp *http.Response, err error, done, appName string) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Restarted bool `json:"restarted"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	fmt.Println(done)
+	if result.Restarted {
+		fmt.Println("Restarted with the new secrets")
+	} else {
+		fmt.Printf("Takes effect on the next restart or deploy (bp restart %s)\n", appName)
+	}
+}
+
+// cmdSecretList prints an app's secret names
+func cmdSecretList(appName string) {
+	resp, err := apiRequest("GET", "/api/apps/"+appName+"/secrets", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var secrets []struct {
+		Key       string    `json:"key"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secrets); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(secrets) == 0 {
+		fmt.Printf("No secrets set for '%s'\n", appName)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "KEY\tUPDATED\n")
+	for _, sec := range secrets {
+		fmt.Fprintf(w, "%s\t%s\n", sec.Key, sec.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// appSEO mirrors GET /api/apps/{id}/seo
+type appSEO struct {
+	Settings SEOConfig `json:"settings"`
+	Domains  []struct {
+		Domain    string `json:"domain"`
+		Preview   bool   `json:"preview"`
+		NoIndex   bool   `json:"noindex"`
+		RobotsTxt string `json:"robots_txt"`
+	} `json:"domains"`
+}
+
+// cmdSEO shows or changes an app's search engine controls
+func cmdSEO(args []string) {
+	usage := `Usage:
+  bp seo <app>                            Show what search engines see on each domain
+  bp seo <app> noindex on|off|auto        Send X-Robots-Tag: noindex (auto: on for preview/staging domains)
+  bp seo <app> robots app|disallow|auto   Serve the app's robots.txt, one blocking all crawlers, or the default
+  bp seo <app> robots --file robots.txt   Serve the given robots.txt`
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	appName := args[0]
+	current := fetchAppSEO(appName)
+	if len(args) == 1 {
+		printAppSEO(current)
+		return
+	}
+
+	settings := current.Settings
+	switch {
+	case args[1] == "noindex" && len(args) == 3:
+		switch args[2] {
+		case "on":
+			on := true
+			settings.NoIndex = &on
+		case "off":
+			off := false
+			settings.NoIndex = &off
+		case "auto":
+			settings.NoIndex = nil
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	case args[1] == "robots" && len(args) == 4 && args[2] == "--file":
+		data, err := os.ReadFile(args[3])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings.RobotsTxt = string(data)
+	case args[1] == "robots" && len(args) == 3:
+		switch args[2] {
+		case "app", "disallow":
+			settings.RobotsTxt = args[2]
+		case "auto":
+			settings.RobotsTxt = ""
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	resp, err := apiRequest("PUT", "/api/apps/"+appName, map[string]interface{}{"seo": settings})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	fmt.Printf("Search engine settings updated for '%s'\n\n", appName)
+	printAppSEO(fetchAppSEO(appName))
+}
+
+func fetchAppSEO(appName string) appSEO {
+	resp, err := apiRequest("GET", "/api/apps/"+appName+"/seo", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var seo appSEO
+	if err := json.NewDecoder(resp.Body).Decode(&seo); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	return seo
+}
+
+func printAppSEO(seo appSEO) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOMAIN\tPREVIEW\tX-ROBOTS-TAG\tROBOTS.TXT")
+	for _, d := range seo.Domains {
+		preview, tag, robots := "no", "-", "app"
+		if d.Preview {
+			preview = "yes"
+		}
+		if d.NoIndex {
+			tag = "noindex"
+		}
+		switch {
+		case d.RobotsTxt == "":
+		case strings.TrimSpace(d.RobotsTxt) == "User-agent: *\nDisallow: /":
+			robots = "disallow all"
+		default:
+			robots = "custom"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Domain, preview, tag, robots)
+	}
+	w.Flush()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// daemonStats mirrors GET /api/system/self
+type daemonStats struct {
+	PID            int       `json:"pid"`
+	Version        string    `json:"version"`
+	GoVersion      string    `json:"go_version"`
+	StartedAt      time.Time `json:"started_at"`
+	UptimeSeconds  int64     `json:"uptime_seconds"`
+	Goroutines     int       `json:"goroutines"`
+	OpenFiles      int       `json:"open_files"`
+	OpenFilesLimit int       `json:"open_files_limit"`
+	Memory         struct {
+		SysBytes       int64  `json:"sys_bytes"`
+		HeapAllocBytes int64  `json:"heap_alloc_bytes"`
+		GCCount        uint32 `json:"gc_count"`
+	} `json:"memory"`
+	Limits      map[string]interface{} `json:"limits"`
+	Warnings    []string               `json:"warnings"`
+	AIProcesses []struct {
+		Name              string     `json:"name"`
+		PID               int        `json:"pid"`
+		Model             string     `json:"model"`
+		RSSBytes          int64      `json:"rss_bytes"`
+		Responding        bool       `json:"responding"`
+		UnresponsiveSince *time.Time `json:"unresponsive_since"`
+		Restarts          int        `json:"restarts"`
+	} `json:"ai_processes"`
+}
+
+// maxProfileDuration stays under the CLI's request timeout
+const maxProfileDuration = 4 * time.Minute
+
+// cmdServer inspects the basepod daemon itself
+func cmdServer(args []string) {
+	usage := `Usage:
+  bp server stats [--json]                    Show daemon memory, goroutines, open files and AI processes
+  bp server profile --cpu 30s [-o file]       Capture a CPU profile (default: cpu.pb.gz)
+  bp server profile --heap [-o file]          Capture a heap profile (default: heap.pb.gz)
+  bp server profile --trace 5s [-o file]      Capture an execution trace (default: trace.out)
+  bp server profile --goroutines [-o file]    Dump all goroutine stacks (default: stdout)
+
+Profiling requires debug.enabled: true in the server config.`
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "stats":
+		cmdServerStats(args[1:])
+	case "profile":
+		cmdServerProfile(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// cmdServerProfile downloads a profile from the daemon's pprof endpoints
+func cmdServerProfile(args []string) {
+	path, output := "/debug/pprof/profile?seconds=30", "cpu.pb.gz"
+	wait := 30 * time.Second
+	// durationArg reads an optional duration after args[i], defaulting to 30s
+	durationArg := func(i *int) time.Duration {
+		if *i+1 >= len(args) || strings.HasPrefix(args[*i+1], "-") {
+			return 30 * time.Second
+		}
+		*i++
+		d, err := time.ParseDuration(args[*i])
+		if err != nil || d < time.Second || d > maxProfileDuration {
+			fmt.Fprintf(os.Stderr, "Invalid duration %q: use 1s to %s\n", args[*i], maxProfileDuration)
+			os.Exit(1)
+		}
+		return d
+	}
+
+	customOutput := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--cpu":
+			wait = durationArg(&i)
+			path, output = fmt.Sprintf("/debug/pprof/profile?seconds=%d", int(wait.Seconds())), "cpu.pb.gz"
+		case "--trace":
+			wait = durationArg(&i)
+			path, output = fmt.Sprintf("/debug/pprof/trace?seconds=%d", int(wait.Seconds())), "trace.out"
+		case "--heap":
+			wait, path, output = 0, "/debug/pprof/heap?gc=1", "heap.pb.gz"
+		case "--goroutines":
+			wait, path, output = 0, "/api/debug/goroutines", "-"
+		case "-o", "--output":
+			if i+1 < len(args) {
+				customOutput = args[i+1]
+				i++
+			}
+		}
+	}
+	if customOutput != "" {
+		output = customOutput
+	}
+
+	if wait > 0 {
+		fmt.Fprintf(os.Stderr, "Profiling for %s...\n", wait)
+	}
+	resp, err := apiRequest("GET", path, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		fmt.Fprintln(os.Stderr, "Profiling is disabled on this server. Set debug.enabled: true in its config and restart it.")
+		os.Exit(1)
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	if output == "-" {
+		io.Copy(os.Stdout, resp.Body)
+		return
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	n, err := io.Copy(f, resp.Body)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s (%s)\n", output, formatBytesHuman(n))
+	if strings.HasSuffix(output, ".pb.gz") {
+		fmt.Printf("Inspect with: go tool pprof -http=:0 %s\n", output)
+	} else if strings.HasSuffix(output, ".out") {
+		fmt.Printf("Inspect with: go tool trace %s\n", output)
+	}
+}
+
+// cmdServerStats shows the daemon's resource usage
+func cmdServerStats(args []string) {
+	resp, err := apiRequest("GET", "/api/system/self", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if len(args) > 0 && args[0] == "--json" {
+		fmt.Println(string(body))
+		return
+	}
+
+	var stats daemonStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("basepod %s (pid %d, %s)\n", stats.Version, stats.PID, stats.GoVersion)
+	fmt.Printf("  Uptime:      %s\n", (time.Duration(stats.UptimeSeconds) * time.Second).String())
+	fmt.Printf("  Memory:      %s (heap %s, limit %v MB)\n", formatBytesHuman(stats.Memory.SysBytes), formatBytesHuman(stats.Memory.HeapAllocBytes), stats.Limits["memory_mb"])
+	fmt.Printf("  Goroutines:  %d (limit %v)\n", stats.Goroutines, stats.Limits["goroutines"])
+	fmt.Printf("  Open files:  %d (limit %v, max %d)\n", stats.OpenFiles, stats.Limits["open_files"], stats.OpenFilesLimit)
+	fmt.Printf("  GC cycles:   %d\n", stats.Memory.GCCount)
+
+	if len(stats.AIProcesses) > 0 {
+		fmt.Println("\nAI processes:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tPID\tMODEL\tRSS\tSTATE\tRESTARTS")
+		for _, p := range stats.AIProcesses {
+			state := "responding"
+			if !p.Responding && p.UnresponsiveSince != nil {
+				state = "unresponsive " + time.Since(*p.UnresponsiveSince).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%s\t%s\t%s\t%d\n", p.Name, p.PID, p.Model, formatBytesHuman(p.RSSBytes), state, p.Restarts)
+		}
+		w.Flush()
+	}
+
+	if len(stats.Warnings) > 0 {
+		fmt.Println("\nWarnings:")
+		for _, warning := range stats.Warnings {
+			fmt.Printf("  ! %s\n", warning)
+		}
+	}
+}
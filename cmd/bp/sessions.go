@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type sessionInfo struct {
+	ID         string    `json:"id"`
+	UserEmail  string    `json:"user_email"`
+	UserRole   string    `json:"user_role"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// cmdSessions lists and revokes login sessions
+func cmdSessions(args []string) {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		cmdSessionsList(len(args) > 1 && args[1] == "--all")
+		return
+	}
+	switch args[0] {
+	case "--all":
+		cmdSessionsList(true)
+	case "revoke", "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp sessions revoke <id>|--others")
+			os.Exit(1)
+		}
+		if args[1] == "--others" {
+			var result struct {
+				Revoked int `json:"revoked"`
+			}
+			usersRequest("DELETE", "/api/auth/sessions", nil, &result)
+			fmt.Printf("Signed out %d other session(s)\n", result.Revoked)
+			return
+		}
+		usersRequest("DELETE", "/api/auth/sessions/"+args[1], nil, nil)
+		fmt.Println("Session revoked")
+	default:
+		fmt.Fprintln(os.Stderr, `Usage:
+  bp sessions [--all]            List your login sessions (--all: everyone's, admins only)
+  bp sessions revoke <id>        Sign out one session
+  bp sessions revoke --others    Sign out every session but this one`)
+		os.Exit(1)
+	}
+}
+
+func cmdSessionsList(all bool) {
+	path := "/api/auth/sessions"
+	if all {
+		path += "?all=1"
+	}
+	var result struct {
+		Sessions []sessionInfo `json:"sessions"`
+	}
+	usersRequest("GET", path, nil, &result)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if all {
+		fmt.Fprintln(w, "ID\tUSER\tIP\tCLIENT\tCREATED\tLAST SEEN")
+	} else {
+		fmt.Fprintln(w, "ID\tIP\tCLIENT\tCREATED\tLAST SEEN")
+	}
+	for _, s := range result.Sessions {
+		id := s.ID
+		if s.Current {
+			id += " *"
+		}
+		client := s.UserAgent
+		if name, _, ok := strings.Cut(client, " "); ok {
+			client = name
+		}
+		if client == "" {
+			client = "-"
+		}
+		created := s.CreatedAt.Local().Format("2006-01-02 15:04")
+		lastSeen := s.LastSeenAt.Local().Format("2006-01-02 15:04")
+		if all {
+			user := s.UserEmail
+			if user == "" {
+				user = "admin"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", id, user, s.IP, client, created, lastSeen)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", id, s.IP, client, created, lastSeen)
+		}
+	}
+	w.Flush()
+	fmt.Println("\n* this session")
+}
+
+// cmdLockouts shows and clears failed-login lockouts
+func cmdLockouts(args []string) {
+	if len(args) > 0 && (args[0] == "clear" || args[0] == "rm") {
+		path := "/api/auth/lockouts"
+		if len(args) > 1 {
+			path += "/" + args[1]
+		}
+		var result struct {
+			Cleared int `json:"cleared"`
+		}
+		usersRequest("DELETE", path, nil, &result)
+		fmt.Printf("Cleared %d IP(s)\n", result.Cleared)
+		return
+	}
+
+	var result struct {
+		Lockouts []struct {
+			IP          string     `json:"ip"`
+			Failures    int        `json:"failures"`
+			Lockouts    int        `json:"lockouts"`
+			LockedUntil *time.Time `json:"locked_until"`
+		} `json:"lockouts"`
+		MaxAttempts   int `json:"max_attempts"`
+		WindowSeconds int `json:"window_seconds"`
+	}
+	usersRequest("GET", "/api/auth/lockouts", nil, &result)
+	if len(result.Lockouts) == 0 {
+		fmt.Println("No recent failed logins")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IP\tFAILURES\tLOCKOUTS\tLOCKED FOR")
+		for _, l := range result.Lockouts {
+			locked := "-"
+			if l.LockedUntil != nil {
+				locked = time.Until(*l.LockedUntil).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", l.IP, l.Failures, l.Lockouts, locked)
+		}
+		w.Flush()
+	}
+	fmt.Printf("\nAn IP is locked out after %d failed logins within %s\n", result.MaxAttempts, time.Duration(result.WindowSeconds)*time.Second)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// smokeTestReport mirrors POST /api/system/selftest
+type smokeTestReport struct {
+	Passed bool   `json:"passed"`
+	App    string `json:"app"`
+	Domain string `json:"domain"`
+	Steps  []struct {
+		Name       string `json:"name"`
+		Status     string `json:"status"`
+		Detail     string `json:"detail"`
+		DurationMS int64  `json:"duration_ms"`
+	} `json:"steps"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// cmdSmokeTest deploys a throwaway app on the server and checks it end to end
+func cmdSmokeTest(args []string) {
+	jsonOutput := len(args) > 0 && args[0] == "--json"
+
+	if !jsonOutput {
+		fmt.Println("Deploying a test app (this can take a minute while the certificate is issued)...")
+	}
+	resp, err := apiRequest("POST", "/api/system/selftest", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var report smokeTestReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		fmt.Println(string(body))
+	} else {
+		fmt.Printf("Test app %s at %s\n\n", report.App, report.Domain)
+		for _, step := range report.Steps {
+			mark := "✓"
+			switch step.Status {
+			case "failed":
+				mark = "✗"
+			case "warn":
+				mark = "!"
+			case "skipped":
+				mark = "-"
+			}
+			line := fmt.Sprintf("  %s %-9s", mark, step.Name)
+			if step.Status != "skipped" {
+				line += fmt.Sprintf(" %6s", (time.Duration(step.DurationMS) * time.Millisecond).Round(10*time.Millisecond))
+			}
+			if step.Detail != "" {
+				line += "  " + step.Detail
+			}
+			fmt.Println(line)
+		}
+		fmt.Println()
+		if report.Passed {
+			fmt.Printf("Smoke test passed in %s\n", (time.Duration(report.DurationMS) * time.Millisecond).Round(time.Second))
+		} else {
+			fmt.Println("Smoke test failed")
+		}
+	}
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
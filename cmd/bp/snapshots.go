@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// cmdSnapshots manages an app's pre-deploy volume snapshots
+func cmdSnapshots(args []string) {
+	usage := `Usage:
+  bp snapshots <app>                 List snapshots
+  bp snapshots create <app>          Take a snapshot now
+  bp snapshots enable <app> [--keep N] [--dump <cmd>]
+                                     Snapshot volumes (and run the dump command) before each deploy
+  bp snapshots disable <app>         Stop taking pre-deploy snapshots
+  bp snapshots restore <app> <id> [--rollback]
+                                     Restore volumes; --rollback also goes back to the snapshot's image
+  bp snapshots dump <app> <id> [-o <file>]
+                                     Download the dump saved with a snapshot`
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "create", "enable", "disable", "restore", "dump":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+	switch args[0] {
+	case "create":
+		cmdSnapshotCreate(args[1])
+	case "enable":
+		cmdSnapshotConfig(args[1], true, args[2:])
+	case "disable":
+		cmdSnapshotConfig(args[1], false, nil)
+	case "restore":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp snapshots restore <app> <id> [--rollback]")
+			os.Exit(1)
+		}
+		cmdSnapshotRestore(args[1], args[2], slices.Contains(args[3:], "--rollback"))
+	case "dump":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp snapshots dump <app> <id> [-o <file>]")
+			os.Exit(1)
+		}
+		cmdSnapshotDump(args[1], args[2], args[3:])
+	default:
+		cmdSnapshotList(args[0])
+	}
+}
+
+func cmdSnapshotList(appName string) {
+	resp, err := apiRequest("GET", "/api/apps/"+appName+"/snapshots", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Config    app.SnapshotConfig `json:"config"`
+		Keep      int                `json:"keep"`
+		Snapshots []app.Snapshot     `json:"snapshots"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if result.Config.Enabled {
+		fmt.Printf("Pre-deploy snapshots: on, keeping the last %d\n", result.Keep)
+	} else {
+		fmt.Println("Pre-deploy snapshots: off (bp snapshots enable " + appName + ")")
+	}
+	if result.Config.DumpCommand != "" {
+		fmt.Printf("Dump command: %s\n", result.Config.DumpCommand)
+	}
+	if len(result.Snapshots) == 0 {
+		fmt.Println("No snapshots yet.")
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTAKEN\tTRIGGER\tIMAGE\tVOLUMES\tDUMP\tSIZE")
+	for _, snap := range result.Snapshots {
+		dump := "-"
+		if snap.Dump {
+			dump = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", snap.ID, snap.CreatedAt.Local().Format("2006-01-02 15:04"),
+			snap.Trigger, snap.Image, len(snap.Volumes), dump, formatBytesHuman(snap.Size))
+	}
+	w.Flush()
+}
+
+func cmdSnapshotCreate(appName string) {
+	fmt.Printf("Taking snapshot of %s...\n", appName)
+	resp, err := apiRequest("POST", "/api/apps/"+appName+"/snapshots", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var snap app.Snapshot
+	json.NewDecoder(resp.Body).Decode(&snap)
+	fmt.Printf("Snapshot %s saved (%d volume(s), %s)\n", snap.ID, len(snap.Volumes), formatBytesHuman(snap.Size))
+}
+
+func cmdSnapshotConfig(appName string, enabled bool, args []string) {
+	cfg := app.SnapshotConfig{Enabled: enabled}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--keep":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fmt.Fprintln(os.Stderr, "--keep must be a positive number")
+					os.Exit(1)
+				}
+				cfg.Keep = n
+				i++
+			}
+		case "--dump":
+			if i+1 < len(args) {
+				cfg.DumpCommand = args[i+1]
+				i++
+			}
+		}
+	}
+
+	resp, err := apiRequest("PUT", "/api/apps/"+appName+"/snapshots/config", cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	if enabled {
+		fmt.Printf("Pre-deploy snapshots enabled for %s\n", appName)
+	} else {
+		fmt.Printf("Pre-deploy snapshots disabled for %s\n", appName)
+	}
+}
+
+func cmdSnapshotRestore(appName, id string, rollback bool) {
+	fmt.Printf("Restoring %s from snapshot %s (the app is stopped meanwhile)...\n", appName, id)
+	resp, err := apiRequest("POST", fmt.Sprintf("/api/apps/%s/snapshots/%s/restore", appName, id), map[string]bool{
+		"rollback": rollback,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Message string `json:"message"`
+		Image   string `json:"image"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	fmt.Println(result.Message)
+	fmt.Printf("Running image: %s\n", result.Image)
+}
+
+func cmdSnapshotDump(appName, id string, args []string) {
+	output := ""
+	for i := 0; i < len(args); i++ {
+		if (args[i] == "-o" || args[i] == "--output") && i+1 < len(args) {
+			output = args[i+1]
+			i++
+		}
+	}
+
+	resp, err := apiRequest("GET", fmt.Sprintf("/api/apps/%s/snapshots/%s/dump", appName, id), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	if output == "" {
+		io.Copy(os.Stdout, resp.Body)
+		return
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	n, err := io.Copy(f, resp.Body)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s (%s)\n", output, formatBytesHuman(n))
+}
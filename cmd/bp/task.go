@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// taskEvent is one line of a task's streamed output
+type taskEvent struct {
+	Type     string `json:"type"` // output or exit
+	Stream   string `json:"stream"`
+	Data     string `json:"data"`
+	ExitCode *int   `json:"exit_code"`
+	Error    string `json:"error"`
+	RunID    string `json:"run_id"`
+}
+
+// cmdRunTask runs a one-off command in a throwaway container from an app's
+// image, streams its output and exits with its exit code
+func cmdRunTask(args []string) {
+	usage := `Usage:
+  bp run-task <app> -- <command> [args...]   Run a command in a new container from the app's image
+  bp run-task <app> --history                Show the app's recent task runs
+
+The task gets the app's env, volumes and network but not its container, so
+it can run while the app serves traffic. Use sh -c for shell syntax:
+  bp run-task web -- sh -c 'npm run migrate && npm run seed'`
+
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	appName := args[0]
+	if args[1] == "--history" {
+		showTaskRuns(appName)
+		return
+	}
+	command := args[1:]
+	if command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	os.Exit(runTask(appName, command))
+}
+
+// runTask starts a task and relays its output, returning the exit code
+func runTask(appName string, command []string) int {
+	req, err := newAPIRequest("", "POST", "/api/apps/"+url.PathEscape(appName)+"/tasks", map[string]interface{}{"command": command})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	// Leaving doesn't stop the task; say so rather than dying silently
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		fmt.Fprintf(os.Stderr, "\nDetached; the task keeps running. Check on it with: bp run-task %s --history\n", appName)
+		os.Exit(130)
+	}()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed to run task: %s\n", strings.TrimSpace(string(body)))
+		return 1
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var ev taskEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		switch ev.Type {
+		case "output":
+			if ev.Stream == "stderr" {
+				os.Stderr.WriteString(ev.Data)
+			} else {
+				os.Stdout.WriteString(ev.Data)
+			}
+		case "exit":
+			if ev.Error != "" {
+				fmt.Fprintf(os.Stderr, "Task failed: %s\n", ev.Error)
+				return 1
+			}
+			if ev.ExitCode != nil {
+				return *ev.ExitCode
+			}
+			return 0
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Lost the connection before the task finished. Check on it with: bp run-task %s --history\n", appName)
+	return 1
+}
+
+// showTaskRuns lists an app's recent task runs
+func showTaskRuns(appName string) {
+	resp, err := apiRequest("GET", "/api/apps/"+url.PathEscape(appName)+"/tasks", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+	var result struct {
+		Tasks []struct {
+			Command   []string   `json:"command"`
+			StartedAt time.Time  `json:"started_at"`
+			EndedAt   *time.Time `json:"ended_at"`
+			Status    string     `json:"status"`
+			ExitCode  int        `json:"exit_code"`
+			Error     string     `json:"error"`
+		} `json:"tasks"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Tasks) == 0 {
+		fmt.Printf("No tasks have run on %s.\n", appName)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STARTED\tDURATION\tSTATUS\tEXIT\tCOMMAND\n")
+	for _, t := range result.Tasks {
+		duration := "-"
+		if t.EndedAt != nil {
+			duration = t.EndedAt.Sub(t.StartedAt).Round(time.Second).String()
+		}
+		status := t.Status
+		if t.Error != "" {
+			status += ": " + t.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", t.StartedAt.Local().Format("Mon Jan 2 15:04"), duration, status, t.ExitCode, strings.Join(t.Command, " "))
+	}
+	w.Flush()
+}
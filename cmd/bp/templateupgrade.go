@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// cmdTemplateUpgrade moves an app installed from a template to the
+// template's recommended version, after showing the migration notes
+func cmdTemplateUpgrade(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bp template upgrade <app> [--version <version>] [--skip-backup] [--yes] [--override <reason>]")
+		os.Exit(1)
+	}
+	name := args[0]
+	version := ""
+	override := ""
+	skipBackup := false
+	yes := false
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--version", "-v":
+			if i+1 < len(args) {
+				version = args[i+1]
+				i++
+			}
+		case "--skip-backup":
+			skipBackup = true
+		case "--yes", "-y":
+			yes = true
+		case "--override":
+			if i+1 < len(args) {
+				override = args[i+1]
+				i++
+			}
+		}
+	}
+
+	a := fetchApp(name)
+	update := a.TemplateUpdate
+	if version == "" && update == nil {
+		fmt.Printf("%s is up to date\n", a.Name)
+		return
+	}
+	if update != nil && (version == "" || version == update.Version) {
+		fmt.Printf("Upgrading %s: %s %s -> %s (%s)\n", a.Name, update.Template, update.CurrentVersion, update.Version, update.Image)
+		printTemplateNotes(update.Notes)
+	} else {
+		fmt.Printf("Upgrading %s to version %s\n", a.Name, version)
+	}
+	if skipBackup {
+		fmt.Println("No backup will be taken first (--skip-backup).")
+	} else {
+		fmt.Println("A server backup is taken first.")
+	}
+
+	if !yes {
+		fmt.Print("Continue? [y/N]: ")
+		var confirm string
+		fmt.Scanln(&confirm)
+		if confirm != "y" && confirm != "Y" && confirm != "yes" {
+			fmt.Println("Upgrade cancelled.")
+			return
+		}
+	}
+
+	fmt.Println("Upgrading...")
+	resp, err := apiRequest("POST", "/api/apps/"+name+"/template/upgrade", map[string]interface{}{
+		"version":         version,
+		"skip_backup":     skipBackup,
+		"freeze_override": override,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		exitIfFrozen(resp.StatusCode, body)
+		fmt.Fprintf(os.Stderr, "Upgrade failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Template    string             `json:"template"`
+		FromVersion string             `json:"from_version"`
+		Version     string             `json:"version"`
+		BackupID    string             `json:"backup_id"`
+		Notes       []app.TemplateNote `json:"notes"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if update == nil || version != "" && version != update.Version {
+		printTemplateNotes(result.Notes)
+	}
+	fmt.Printf("Upgraded %s from %s %s to %s\n", a.Name, result.Template, result.FromVersion, result.Version)
+	if result.BackupID != "" {
+		fmt.Printf("Backup taken first: %s (bp backup restore %s)\n", result.BackupID, result.BackupID)
+	}
+}
+
+func printTemplateNotes(notes []app.TemplateNote) {
+	if len(notes) == 0 {
+		return
+	}
+	fmt.Println("\nMigration notes:")
+	for _, n := range notes {
+		fmt.Printf("  %s: %s\n", n.Version, n.Text)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// liveStats is one app's current resource use from GET /api/metrics/live
+type liveStats struct {
+	Name        string  `json:"name"`
+	CPUPercent  float64 `json:"cpu_percent"`
+	MemUsage    int64   `json:"mem_usage"`
+	MemLimit    int64   `json:"mem_limit"`
+	NetInput    int64   `json:"net_input"`
+	NetOutput   int64   `json:"net_output"`
+	BlockInput  int64   `json:"block_input"`
+	BlockOutput int64   `json:"block_output"`
+}
+
+// cmdTop shows the resource use of every running app, refreshed until
+// interrupted
+func cmdTop(args []string) {
+	interval := 2 * time.Second
+	sortBy := "cpu"
+	once := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--interval" || args[i] == "-n") && i+1 < len(args):
+			secs, err := strconv.Atoi(args[i+1])
+			if err != nil || secs < 1 {
+				fmt.Fprintln(os.Stderr, "--interval takes a whole number of seconds")
+				os.Exit(1)
+			}
+			interval = time.Duration(secs) * time.Second
+			i++
+		case args[i] == "--sort" && i+1 < len(args):
+			sortBy = args[i+1]
+			i++
+		case args[i] == "--once":
+			once = true
+		default:
+			fmt.Fprintln(os.Stderr, `Usage: bp top [--interval N] [--sort cpu|mem|net|block|name] [--once]
+
+Shows CPU, memory, network and disk I/O of every running app, refreshed
+every N seconds (default 2). --once prints one snapshot and exits.`)
+			os.Exit(1)
+		}
+	}
+	switch sortBy {
+	case "cpu", "mem", "net", "block", "name":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown sort column: %s (use cpu, mem, net, block or name)\n", sortBy)
+		os.Exit(1)
+	}
+
+	for {
+		stats := fetchLiveStats()
+		sortLiveStats(stats, sortBy)
+		if !once {
+			fmt.Print("\033[H\033[2J") // Clear the screen
+		}
+		fmt.Printf("%s  %d apps running\n\n", time.Now().Format("15:04:05"), len(stats))
+		printLiveStats(stats)
+		if once {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+func fetchLiveStats() []liveStats {
+	resp, err := apiRequest("GET", "/api/metrics/live", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Apps []liveStats `json:"apps"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+	return result.Apps
+}
+
+// sortLiveStats orders apps by the column, busiest first; by name A-Z
+func sortLiveStats(stats []liveStats, by string) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		switch by {
+		case "mem":
+			return a.MemUsage > b.MemUsage
+		case "net":
+			return a.NetInput+a.NetOutput > b.NetInput+b.NetOutput
+		case "block":
+			return a.BlockInput+a.BlockOutput > b.BlockInput+b.BlockOutput
+		case "name":
+			return a.Name < b.Name
+		}
+		return a.CPUPercent > b.CPUPercent
+	})
+}
+
+func printLiveStats(stats []liveStats) {
+	if len(stats) == 0 {
+		fmt.Println("No apps running")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCPU\tMEMORY\tMEM %\tNET IN / OUT\tBLOCK READ / WRITE")
+	for _, s := range stats {
+		memPercent := "-"
+		if s.MemLimit > 0 {
+			memPercent = fmt.Sprintf("%.1f%%", float64(s.MemUsage)*100/float64(s.MemLimit))
+		}
+		fmt.Fprintf(w, "%s\t%.1f%%\t%s\t%s\t%s / %s\t%s / %s\n", s.Name, s.CPUPercent,
+			formatBytesHuman(s.MemUsage), memPercent,
+			formatBytesHuman(s.NetInput), formatBytesHuman(s.NetOutput),
+			formatBytesHuman(s.BlockInput), formatBytesHuman(s.BlockOutput))
+	}
+	w.Flush()
+}
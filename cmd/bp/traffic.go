@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// cmdTraffic summarizes and exports an app's access logs and manages their
+// privacy settings
+func cmdTraffic(args []string) {
+	usage := `Usage:
+  bp traffic <app> [--period 24h] [--top 10]
+                                           Summarize requests, status codes, bandwidth, top paths and visitors
+  bp traffic export <app> [--anonymized] [--since 7d] [--csv] [-o file]
+                                           Export access logs within the retention period (default: stdout)
+  bp traffic privacy <app> anonymize|keep|default
+                                           Mask client IPs in stored access logs, keep them, or follow the server setting`
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "export":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+		cmdTrafficExport(args[1], args[2:])
+	case "privacy":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+		cmdTrafficPrivacy(args[1], args[2])
+	default:
+		cmdTrafficSummary(args[0], args[1:])
+	}
+}
+
+// trafficCount is a path or referrer in a traffic summary
+type trafficCount struct {
+	Value    string `json:"value"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// trafficSummary is what GET /api/apps/{id}/traffic returns
+type trafficSummary struct {
+	Period         string           `json:"period"`
+	Requests       int64            `json:"requests"`
+	Bytes          int64            `json:"bytes"`
+	UniqueVisitors int              `json:"unique_visitors"`
+	StatusClasses  map[string]int64 `json:"status_classes"`
+	StatusCodes    map[string]int64 `json:"status_codes"`
+	AvgDurationMs  float64          `json:"avg_duration_ms"`
+	TopPaths       []trafficCount   `json:"top_paths"`
+	TopReferrers   []trafficCount   `json:"top_referrers"`
+	Series         []struct {
+		Requests int64 `json:"requests"`
+	} `json:"series"`
+	Anonymized bool `json:"anonymized"`
+}
+
+// cmdTrafficSummary prints an app's requests, status codes, bandwidth, top
+// paths and referrers and unique visitors
+func cmdTrafficSummary(appName string, args []string) {
+	query := url.Values{}
+	if v := flagValue(args, "--period"); v != "" {
+		query.Set("period", v)
+	}
+	if v := flagValue(args, "--top"); v != "" {
+		query.Set("top", v)
+	}
+	path := "/api/apps/" + url.PathEscape(appName) + "/traffic"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var s trafficSummary
+	usersRequest("GET", path, nil, &s)
+
+	fmt.Printf("Traffic for %s (last %s)\n\n", appName, s.Period)
+	if s.Requests == 0 {
+		fmt.Println("No requests in this period")
+		return
+	}
+	visitors := strconv.Itoa(s.UniqueVisitors)
+	if s.Anonymized {
+		visitors += " (by masked IP)"
+	}
+	fmt.Printf("Requests:   %d\n", s.Requests)
+	fmt.Printf("Visitors:   %s\n", visitors)
+	fmt.Printf("Bandwidth:  %s\n", formatBytesHuman(s.Bytes))
+	fmt.Printf("Avg time:   %.1f ms\n", s.AvgDurationMs)
+	var classes []string
+	for _, class := range []string{"2xx", "3xx", "4xx", "5xx"} {
+		if n := s.StatusClasses[class]; n > 0 {
+			classes = append(classes, fmt.Sprintf("%s %d (%.1f%%)", class, n, float64(n)*100/float64(s.Requests)))
+		}
+	}
+	fmt.Printf("Status:     %s\n", strings.Join(classes, ", "))
+
+	var series []int64
+	for _, p := range s.Series {
+		series = append(series, p.Requests)
+	}
+	fmt.Printf("Trend:      %s\n", trafficSparkline(series))
+
+	printTrafficCounts("Top paths", s.TopPaths)
+	printTrafficCounts("Top referrers", s.TopReferrers)
+}
+
+// printTrafficCounts prints a table of paths or referrers
+func printTrafficCounts(title string, counts []trafficCount) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range counts {
+		fmt.Fprintf(w, "  %d\t%s\t%s\n", c.Requests, formatBytesHuman(c.Bytes), c.Value)
+	}
+	w.Flush()
+}
+
+// trafficSparkline draws request counts as a row of bars
+func trafficSparkline(values []int64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	var peak int64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if peak > 0 {
+			i = int(v * int64(len(bars)-1) / peak)
+		}
+		b.WriteRune(bars[i])
+	}
+	return b.String()
+}
+
+// cmdTrafficExport downloads an app's access logs
+func cmdTrafficExport(appName string, args []string) {
+	query := url.Values{}
+	output := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--anonymized", "--anonymize":
+			query.Set("anonymized", "1")
+		case "--csv":
+			query.Set("format", "csv")
+		case "--since":
+			if i+1 < len(args) {
+				query.Set("since", daysToHours(args[i+1]))
+				i++
+			}
+		case "-o", "--output":
+			if i+1 < len(args) {
+				output = args[i+1]
+				i++
+			}
+		}
+	}
+
+	path := "/api/apps/" + appName + "/access-logs/export"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := apiRequest("GET", path, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	if output == "" {
+		io.Copy(os.Stdout, resp.Body)
+		return
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	n, err := io.Copy(f, resp.Body)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s (%s)\n", output, formatBytesHuman(n))
+}
+
+// daysToHours turns "7d" into "168h" so the server can parse it as a duration
+func daysToHours(v string) string {
+	if days, err := strconv.Atoi(strings.TrimSuffix(v, "d")); err == nil && strings.HasSuffix(v, "d") {
+		return fmt.Sprintf("%dh", days*24)
+	}
+	return v
+}
+
+// cmdTrafficPrivacy sets whether an app's access logs keep full client IPs
+func cmdTrafficPrivacy(appName, mode string) {
+	privacy := map[string]interface{}{}
+	switch mode {
+	case "anonymize":
+		privacy["anonymize_ips"] = true
+	case "keep":
+		privacy["anonymize_ips"] = false
+	case "default":
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: bp traffic privacy <app> anonymize|keep|default")
+		os.Exit(1)
+	}
+
+	resp, err := apiRequest("PUT", "/api/apps/"+appName, map[string]interface{}{"privacy": privacy})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	switch mode {
+	case "anonymize":
+		fmt.Printf("Client IPs in access logs for '%s' are now masked (IPv4 /24, IPv6 /48)\n", appName)
+	case "keep":
+		fmt.Printf("Access logs for '%s' now keep full client IPs\n", appName)
+	default:
+		fmt.Printf("Access logs for '%s' now follow the server's privacy.anonymize_ips setting\n", appName)
+	}
+}
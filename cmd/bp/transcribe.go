@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cmdTranscribe uploads an audio file to the server's Whisper endpoint and prints the text
+func cmdTranscribe(args []string) {
+	var path, model, language string
+	format := "text"
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--model", "-m":
+			if i+1 < len(args) {
+				model = args[i+1]
+				i++
+			}
+		case "--language", "-l":
+			if i+1 < len(args) {
+				language = args[i+1]
+				i++
+			}
+		case "--json":
+			format = "json"
+		default:
+			path = args[i]
+		}
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "Usage: bp transcribe <file> [--model <whisper-model>] [--language <code>] [--json]")
+		os.Exit(1)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if model != "" {
+		_ = writer.WriteField("model", model)
+	}
+	if language != "" {
+		_ = writer.WriteField("language", language)
+	}
+	_ = writer.WriteField("response_format", format)
+	writer.Close()
+
+	_, server, err := getClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(server, "/")+"/v1/audio/transcriptions", &body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if cfg, err := loadConfig(); err == nil {
+		if srv, _, err := getCurrentServer(cfg); err == nil && srv.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+srv.Token)
+		}
+	}
+
+	// Long recordings can take a while on smaller models
+	client := &http.Client{Timeout: 30 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", errResp.Error)
+		os.Exit(1)
+	}
+
+	io.Copy(os.Stdout, resp.Body)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// cmd2FA manages TOTP two-factor auth for the logged-in account
+func cmd2FA(args []string) {
+	if len(args) == 0 || args[0] == "status" {
+		cmd2FAStatus()
+		return
+	}
+	switch args[0] {
+	case "enable", "on":
+		cmd2FAEnable()
+	case "disable", "off":
+		cmd2FADisable()
+	case "recovery-codes":
+		cmd2FARecoveryCodes()
+	default:
+		fmt.Fprintln(os.Stderr, `Usage:
+  bp 2fa                  Show two-factor auth status
+  bp 2fa enable           Turn on two-factor auth with an authenticator app
+  bp 2fa disable          Turn off two-factor auth
+  bp 2fa recovery-codes   Replace your recovery codes`)
+		os.Exit(1)
+	}
+}
+
+// promptLine reads a line of input after printing prompt
+func promptLine(prompt string) string {
+	fmt.Print(prompt)
+	reader := bufio.NewReader(os.Stdin)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// promptSecret reads input without echoing it when stdin is a terminal
+func promptSecret(prompt string) string {
+	fmt.Print(prompt)
+	value, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		reader := bufio.NewReader(os.Stdin)
+		line, _ := reader.ReadString('\n')
+		return strings.TrimSpace(line)
+	}
+	return string(value)
+}
+
+func printRecoveryCodes(codes []string) {
+	fmt.Println("Recovery codes (each works once, if you lose your authenticator):")
+	for _, c := range codes {
+		fmt.Printf("  %s\n", c)
+	}
+	fmt.Println("Save these somewhere safe - they won't be shown again.")
+}
+
+func cmd2FAStatus() {
+	var status struct {
+		Enabled           bool       `json:"enabled"`
+		Pending           bool       `json:"pending"`
+		EnabledAt         *time.Time `json:"enabled_at"`
+		RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	}
+	usersRequest("GET", "/api/auth/2fa", nil, &status)
+	switch {
+	case status.Enabled:
+		fmt.Printf("Two-factor auth: on (since %s)\n", status.EnabledAt.Local().Format("2006-01-02"))
+		fmt.Printf("Recovery codes left: %d\n", status.RecoveryCodesLeft)
+		if status.RecoveryCodesLeft < 3 {
+			fmt.Println("Running low - make new ones with: bp 2fa recovery-codes")
+		}
+	case status.Pending:
+		fmt.Println("Two-factor auth: enrollment started but not verified. Run: bp 2fa enable")
+	default:
+		fmt.Println("Two-factor auth: off. Turn it on with: bp 2fa enable")
+	}
+}
+
+func cmd2FAEnable() {
+	var enroll struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}
+	usersRequest("POST", "/api/auth/2fa/enroll", nil, &enroll)
+	fmt.Println("Add this account to your authenticator app.")
+	fmt.Printf("  Setup key: %s\n", enroll.Secret)
+	fmt.Printf("  URI (for a QR code): %s\n\n", enroll.ProvisioningURI)
+
+	code := promptLine("Code from the app: ")
+	var result struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	usersRequest("POST", "/api/auth/2fa/verify", map[string]string{"code": code}, &result)
+	fmt.Println("\nTwo-factor auth is on. bp login will ask for a code from now on.")
+	printRecoveryCodes(result.RecoveryCodes)
+}
+
+func cmd2FADisable() {
+	password := promptSecret("Password: ")
+	code := promptLine("Two-factor code (or recovery code): ")
+	usersRequest("POST", "/api/auth/2fa/disable", map[string]string{"password": password, "code": code}, nil)
+	fmt.Println("Two-factor auth is off")
+}
+
+func cmd2FARecoveryCodes() {
+	code := promptLine("Two-factor code: ")
+	var result struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	usersRequest("POST", "/api/auth/2fa/recovery-codes", map[string]string{"code": code}, &result)
+	fmt.Println("Your old recovery codes no longer work.")
+	printRecoveryCodes(result.RecoveryCodes)
+}
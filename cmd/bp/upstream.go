@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// upstreamTestReport mirrors POST /api/apps/{id}/upstream/test
+type upstreamTestReport struct {
+	Protocol string `json:"protocol"`
+	Passed   bool   `json:"passed"`
+	Steps    []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+		Detail string `json:"detail"`
+	} `json:"steps"`
+}
+
+// cmdUpstream shows, sets or tests the protocol Caddy uses to reach an app
+func cmdUpstream(args []string) {
+	usage := `Usage:
+  bp upstream <app>                  Show the app's upstream protocol
+  bp upstream <app> http|h2c|grpc    Proxy with HTTP/1.1, cleartext HTTP/2 or gRPC
+  bp upstream <app> test             Check the app answers directly and through its domain`
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	appName := args[0]
+
+	if len(args) == 1 {
+		a := fetchApp(appName)
+		protocol := a.Ports.Protocol
+		if protocol == "" {
+			protocol = "http"
+		}
+		fmt.Printf("%s: %s (port %d)\n", appName, protocol, a.Ports.ContainerPort)
+		return
+	}
+
+	switch args[1] {
+	case "test":
+		cmdUpstreamTest(appName)
+	case "http", "h2c", "grpc":
+		resp, err := apiRequest("PUT", "/api/apps/"+appName, map[string]interface{}{"protocol": args[1]})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+			os.Exit(1)
+		}
+		fmt.Printf("'%s' is now proxied over %s\n", appName, args[1])
+		if args[1] != "http" {
+			fmt.Printf("Check it with: bp upstream %s test\n", appName)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// cmdUpstreamTest runs the server-side connectivity check for an app
+func cmdUpstreamTest(appName string) {
+	resp, err := apiRequest("POST", "/api/apps/"+appName+"/upstream/test", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	var report upstreamTestReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Testing '%s' over %s\n\n", appName, report.Protocol)
+	for _, step := range report.Steps {
+		mark := "✓"
+		switch step.Status {
+		case "failed":
+			mark = "✗"
+		case "warn":
+			mark = "!"
+		case "skipped":
+			mark = "-"
+		}
+		fmt.Printf("  %s %-9s %s\n", mark, step.Name, step.Detail)
+	}
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// uptimeReport is what GET /api/apps/{id}/uptime returns
+type uptimeReport struct {
+	Period  string             `json:"period"`
+	URL     string             `json:"url"`
+	Summary *app.UptimeSummary `json:"summary"`
+	Checks  []app.UptimeCheck  `json:"checks"`
+}
+
+// cmdUptime shows how available an app's public URL has been, or probes it
+// now with --check
+func cmdUptime(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: bp uptime <app> [--period 24h|7d|30d] [--check]")
+		os.Exit(1)
+	}
+	name := args[0]
+	path := "/api/apps/" + url.PathEscape(name) + "/uptime"
+
+	if _, check := flagSet(args[1:], "--check"); check {
+		var c app.UptimeCheck
+		usersRequest("POST", path+"/check", nil, &c)
+		printUptimeCheck(c)
+		return
+	}
+
+	if period := flagValue(args[1:], "--period"); period != "" {
+		path += "?period=" + url.QueryEscape(period)
+	}
+	var report uptimeReport
+	usersRequest("GET", path, nil, &report)
+	s := report.Summary
+	if s == nil || s.Checks == 0 {
+		fmt.Printf("%s hasn't been probed in the last %s\n", name, report.Period)
+		return
+	}
+
+	fmt.Printf("Uptime of %s (last %s)\n\n", name, report.Period)
+	fmt.Printf("URL:           %s\n", report.URL)
+	status := s.Status
+	if s.DownSince != nil {
+		status += fmt.Sprintf(" for %s", time.Since(*s.DownSince).Round(time.Second))
+	}
+	fmt.Printf("Status:        %s\n", status)
+	fmt.Printf("Availability:  %.2f%% (%d checks, %d failed)\n", s.Availability, s.Checks, s.Failures)
+	fmt.Printf("Response time: %.0f ms average\n", s.AvgResponseMs)
+	if s.LastCheck != nil {
+		fmt.Printf("Last check:    %s ago\n", time.Since(*s.LastCheck).Round(time.Second))
+	}
+
+	var failed []app.UptimeCheck
+	for _, c := range report.Checks {
+		if !c.Up {
+			failed = append(failed, c)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Println("\nFailed checks:")
+		for _, c := range failed {
+			fmt.Printf("  %s  %s\n", c.CheckedAt.Local().Format("2006-01-02 15:04:05"), c.Error)
+		}
+	}
+}
+
+// printUptimeCheck shows the result of one probe
+func printUptimeCheck(c app.UptimeCheck) {
+	if c.Up {
+		fmt.Printf("%s is up: %d in %d ms\n", c.URL, c.StatusCode, c.ResponseMs)
+		return
+	}
+	fmt.Printf("%s is down: %s\n", c.URL, c.Error)
+}
+
+// formatUptime shows an app's availability for bp apps
+func formatUptime(u *app.UptimeSummary) string {
+	if u == nil || u.Checks == 0 {
+		return "-"
+	}
+	s := fmt.Sprintf("%.2f%%", u.Availability)
+	if u.Status == "down" {
+		s += " (down)"
+	}
+	return s
+}
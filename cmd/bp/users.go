@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+type userInfo struct {
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// cmdUsers manages the server's user accounts and their app grants
+func cmdUsers(args []string) {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		cmdUsersList()
+		return
+	}
+	switch args[0] {
+	case "add", "create":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp users add <email> [--role admin|deployer|viewer] [--password <password>] [--apps a,b]")
+			os.Exit(1)
+		}
+		cmdUsersAdd(args[1], args[2:])
+	case "invite":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp users invite <email> [--role admin|deployer|viewer]")
+			os.Exit(1)
+		}
+		cmdUsersInvite(args[1], args[2:])
+	case "role":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: bp users role <email> <admin|deployer|viewer>")
+			os.Exit(1)
+		}
+		cmdUsersRole(args[1], args[2])
+	case "apps", "grant":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp users apps <email> [--set a,b]")
+			os.Exit(1)
+		}
+		cmdUsersApps(args[1], args[2:])
+	case "rm", "delete":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp users rm <email>")
+			os.Exit(1)
+		}
+		cmdUsersRemove(args[1])
+	case "reset-2fa":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp users reset-2fa <email>")
+			os.Exit(1)
+		}
+		u := findUser(args[1])
+		usersRequest("DELETE", "/api/users/"+u.ID+"/2fa", nil, nil)
+		fmt.Printf("Two-factor auth turned off for %s\n", u.Email)
+	default:
+		fmt.Fprintln(os.Stderr, `Usage:
+  bp users                          List users
+  bp users add <email> [flags]      Add a user with a password (--role, --password, --apps)
+  bp users invite <email> [--role]  Invite a user to set their own password
+  bp users role <email> <role>      Change a user's role (admin, deployer, viewer)
+  bp users apps <email> [--set a,b] Show or set the apps a user can reach
+  bp users rm <email>               Delete a user and sign them out
+  bp users reset-2fa <email>        Turn off a user's two-factor auth`)
+		os.Exit(1)
+	}
+}
+
+// usersRequest sends an API request and exits on any non-2xx response
+func usersRequest(method, path string, body, out interface{}) {
+	resp, err := apiRequest(method, path, body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(respBody))
+		os.Exit(1)
+	}
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+func fetchUsers() []userInfo {
+	var result struct {
+		Users []userInfo `json:"users"`
+	}
+	usersRequest("GET", "/api/users", nil, &result)
+	return result.Users
+}
+
+// findUser looks a user up by email or ID prefix
+func findUser(ref string) userInfo {
+	for _, u := range fetchUsers() {
+		if strings.EqualFold(u.Email, ref) || strings.HasPrefix(u.ID, ref) {
+			return u
+		}
+	}
+	fmt.Fprintf(os.Stderr, "User not found: %s\n", ref)
+	os.Exit(1)
+	return userInfo{}
+}
+
+// appNames maps app IDs to names for display
+func appNames() map[string]string {
+	var result app.AppListResponse
+	usersRequest("GET", "/api/apps", nil, &result)
+	names := make(map[string]string, len(result.Apps))
+	for _, a := range result.Apps {
+		names[a.ID] = a.Name
+	}
+	return names
+}
+
+// flagValue returns the value following name in args, or ""
+func flagValue(args []string, name string) string {
+	value, _ := flagSet(args, name)
+	return value
+}
+
+func cmdUsersList() {
+	users := fetchUsers()
+	if len(users) == 0 {
+		fmt.Println("No users. The server password signs in as admin; add users with: bp users add <email>")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tROLE\tSTATUS\tLAST LOGIN")
+	for _, u := range users {
+		lastLogin := "never"
+		if u.LastLoginAt != nil {
+			lastLogin = u.LastLoginAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", u.ID[:8], u.Email, u.Role, u.Status, lastLogin)
+	}
+	w.Flush()
+}
+
+func cmdUsersAdd(email string, args []string) {
+	role := flagValue(args, "--role")
+	password := flagValue(args, "--password")
+	if password == "" {
+		password = promptSecret(fmt.Sprintf("Password for %s: ", email))
+	}
+	body := map[string]interface{}{
+		"email":    email,
+		"password": password,
+		"role":     role,
+	}
+	if apps := flagValue(args, "--apps"); apps != "" {
+		body["app_ids"] = strings.Split(apps, ",")
+	}
+
+	var result struct {
+		User struct {
+			Role string `json:"role"`
+		} `json:"user"`
+	}
+	usersRequest("POST", "/api/users", body, &result)
+	fmt.Printf("Added %s as %s\n", email, result.User.Role)
+}
+
+func cmdUsersInvite(email string, args []string) {
+	var result struct {
+		InviteURL string `json:"invite_url"`
+	}
+	usersRequest("POST", "/api/users/invite", map[string]string{"email": email, "role": flagValue(args, "--role")}, &result)
+	fmt.Printf("Invited %s\n", email)
+	fmt.Printf("Invite link: %s\n", result.InviteURL)
+}
+
+func cmdUsersRole(ref, role string) {
+	u := findUser(ref)
+	usersRequest("PUT", "/api/users/"+u.ID+"/role", map[string]string{"role": role}, nil)
+	fmt.Printf("%s is now %s\n", u.Email, role)
+}
+
+func cmdUsersApps(ref string, args []string) {
+	u := findUser(ref)
+	names := appNames()
+
+	if set, ok := flagSet(args, "--set"); ok {
+		appIDs := []string{}
+		for _, name := range strings.Split(set, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				appIDs = append(appIDs, fetchApp(name).ID)
+			}
+		}
+		usersRequest("PUT", "/api/users/"+u.ID+"/apps", map[string]interface{}{"app_ids": appIDs}, nil)
+		fmt.Printf("Updated app access for %s\n", u.Email)
+	}
+
+	var result struct {
+		AppIDs []string `json:"app_ids"`
+	}
+	usersRequest("GET", "/api/users/"+u.ID+"/apps", nil, &result)
+	switch {
+	case u.Role == "admin":
+		fmt.Printf("%s is an admin and can reach every app\n", u.Email)
+	case len(result.AppIDs) == 0 && u.Role == "viewer":
+		fmt.Printf("%s can view every app\n", u.Email)
+	case len(result.AppIDs) == 0:
+		fmt.Printf("%s has no apps. Grant some with: bp users apps %s --set <app,...>\n", u.Email, u.Email)
+	default:
+		fmt.Printf("Apps %s can reach:\n", u.Email)
+		for _, id := range result.AppIDs {
+			name := names[id]
+			if name == "" {
+				name = id + " (deleted)"
+			}
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
+
+// flagSet returns the value following name in args and whether name was
+// given at all, so an empty value can clear a list
+func flagSet(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		if arg == name {
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+func cmdUsersRemove(ref string) {
+	u := findUser(ref)
+	usersRequest("DELETE", "/api/users/"+u.ID, nil, nil)
+	fmt.Printf("Deleted %s\n", u.Email)
+}
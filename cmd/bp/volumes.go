@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// volumeInfo is a volume as /api/volumes reports it
+type volumeInfo struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	CreatedAt  string            `json:"created_at"`
+	Labels     map[string]string `json:"labels"`
+	App        string            `json:"app"`
+	Formatted  string            `json:"formatted"`
+	Orphaned   bool              `json:"orphaned"`
+	UsedBy     []string          `json:"used_by"`
+}
+
+// cmdVolumes lists, creates, inspects and removes the server's Podman volumes
+func cmdVolumes(args []string) {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		cmdVolumesList()
+		return
+	}
+	switch args[0] {
+	case "create":
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			fmt.Fprintln(os.Stderr, "Usage: bp volumes create <name> [--app <app>]")
+			os.Exit(1)
+		}
+		var v volumeInfo
+		usersRequest("POST", "/api/volumes", map[string]string{"name": args[1], "app": flagValue(args[2:], "--app")}, &v)
+		if v.App != "" {
+			fmt.Printf("Created volume %s for %s\n", v.Name, v.App)
+		} else {
+			fmt.Printf("Created volume %s\n", v.Name)
+		}
+	case "inspect":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: bp volumes inspect <name>")
+			os.Exit(1)
+		}
+		var v volumeInfo
+		usersRequest("GET", "/api/volumes/"+url.PathEscape(args[1]), nil, &v)
+		app := v.App
+		if app == "" {
+			app = "-"
+		}
+		fmt.Printf("Name:       %s\n", v.Name)
+		fmt.Printf("App:        %s\n", app)
+		fmt.Printf("Size:       %s\n", v.Formatted)
+		fmt.Printf("Driver:     %s\n", v.Driver)
+		fmt.Printf("Mountpoint: %s\n", v.Mountpoint)
+		fmt.Printf("Created:    %s\n", v.CreatedAt)
+		if len(v.UsedBy) > 0 {
+			fmt.Printf("Used by:    %s\n", strings.Join(v.UsedBy, ", "))
+		} else {
+			fmt.Println("Used by:    no containers")
+		}
+		if len(v.Labels) > 0 {
+			keys := make([]string, 0, len(v.Labels))
+			for k := range v.Labels {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			fmt.Println("Labels:")
+			for _, k := range keys {
+				fmt.Printf("  %s=%s\n", k, v.Labels[k])
+			}
+		}
+		if v.Orphaned {
+			fmt.Println("\nNo app owns this volume; bp prune --aggressive removes it.")
+		}
+	case "rm", "delete":
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			fmt.Fprintln(os.Stderr, "Usage: bp volumes rm <name> [--force]")
+			os.Exit(1)
+		}
+		path := "/api/volumes/" + url.PathEscape(args[1])
+		if _, force := flagSet(args[2:], "--force"); force {
+			path += "?force=true"
+		}
+		usersRequest("DELETE", path, nil, nil)
+		fmt.Printf("Removed volume %s\n", args[1])
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: bp volumes [list | create <name> [--app <app>] | inspect <name> | rm <name> [--force]]")
+		os.Exit(1)
+	}
+}
+
+func cmdVolumesList() {
+	var volumes []volumeInfo
+	usersRequest("GET", "/api/volumes", nil, &volumes)
+	if len(volumes) == 0 {
+		fmt.Println("No volumes")
+		return
+	}
+	orphans := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tAPP\tSIZE\tORPHANED")
+	for _, v := range volumes {
+		app, orphaned := v.App, ""
+		if app == "" {
+			app = "-"
+		}
+		if v.Orphaned {
+			orphaned = "yes"
+			orphans++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Name, app, v.Formatted, orphaned)
+	}
+	w.Flush()
+	if orphans > 0 {
+		fmt.Printf("\n%d orphaned volume(s); remove them with bp prune --aggressive\n", orphans)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// watchEvent is one event from GET /api/events/stream
+type watchEvent struct {
+	Type     string            `json:"type"`
+	AppName  string            `json:"app_name"`
+	Status   string            `json:"status"`
+	Action   string            `json:"action"`
+	Health   string            `json:"health"`
+	ExitCode string            `json:"exit_code"`
+	Reason   string            `json:"reason"`
+	Phase    string            `json:"phase"`
+	Message  string            `json:"message"`
+	Event    string            `json:"event"`
+	Details  map[string]string `json:"details"`
+	Time     time.Time         `json:"time"`
+}
+
+// cmdWatch follows the server's event stream for one app or all of them,
+// reconnecting where it left off until interrupted
+func cmdWatch(args []string) {
+	name := ""
+	raw := false
+	for _, arg := range args {
+		switch {
+		case arg == "--json":
+			raw = true
+		case strings.HasPrefix(arg, "-") || name != "":
+			fmt.Fprintln(os.Stderr, `Usage: bp watch [name] [--json]
+
+Prints app status changes, deploy steps, health changes and alerts as they
+happen, for one app or the whole server. --json prints the raw events.`)
+			os.Exit(1)
+		default:
+			name = arg
+		}
+	}
+
+	appID := ""
+	if name != "" {
+		a := fetchApp(name)
+		name, appID = a.Name, a.ID
+	}
+	if !raw {
+		what := "all apps"
+		if name != "" {
+			what = name
+		}
+		fmt.Printf("Watching %s (Ctrl+C to stop)\n", what)
+		printWatchSnapshot(name)
+	}
+
+	cursor := ""
+	backoff := time.Second
+	for {
+		next, err := watchEvents(cursor, appID, raw)
+		if next != "" {
+			cursor = next
+			backoff = time.Second
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Event stream: %v; reconnecting in %s\n", err, backoff)
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// printWatchSnapshot prints the current status of the watched apps
+func printWatchSnapshot(name string) {
+	resp, err := apiRequest("GET", "/api/apps", nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var result app.AppListResponse
+	if json.NewDecoder(resp.Body).Decode(&result) != nil {
+		return
+	}
+	now := time.Now()
+	for _, a := range result.Apps {
+		if name == "" || a.Name == name {
+			fmt.Println(formatWatchEvent(watchEvent{Type: "app_status", AppName: a.Name, Status: string(a.Status), Time: now}))
+		}
+	}
+}
+
+// watchEvents prints events until the stream ends and returns the cursor of
+// the last one seen
+func watchEvents(cursor, appID string, raw bool) (string, error) {
+	path := "/api/events/stream"
+	if appID != "" {
+		path += "?app=" + url.QueryEscape(appID)
+	}
+	req, err := newAPIRequest("", "GET", path, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if cursor != "" {
+		req.Header.Set("Last-Event-ID", cursor)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed to watch events: %s\n", string(body))
+		os.Exit(1)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	last := ""
+	kind := ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			kind = ""
+		case strings.HasPrefix(line, "event: "):
+			kind = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "id: "):
+			last = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: ") && kind == "":
+			data := strings.TrimPrefix(line, "data: ")
+			var e watchEvent
+			if json.Unmarshal([]byte(data), &e) != nil {
+				continue
+			}
+			if raw {
+				fmt.Println(data)
+			} else if text := formatWatchEvent(e); text != "" {
+				fmt.Println(text)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return last, err
+	}
+	return last, fmt.Errorf("stream closed")
+}
+
+// formatWatchEvent renders an event as one line, or "" for events not worth
+// a line of their own
+func formatWatchEvent(e watchEvent) string {
+	var what string
+	switch e.Type {
+	case "app_status":
+		what = "● " + e.Status
+		if e.Reason != "" {
+			what += " (" + e.Reason + ")"
+		}
+	case "container":
+		switch e.Action {
+		case "start":
+			what = "container started"
+		case "died":
+			what = "container stopped"
+			if e.ExitCode != "" {
+				what += " (exit code " + e.ExitCode + ")"
+			}
+		default:
+			return "" // Podman's health checks; health events report changes
+		}
+	case "deploy":
+		switch {
+		case e.Phase == "" && e.Status == "success":
+			what = "✓ deployed"
+		case e.Phase == "":
+			what = "✗ deploy failed"
+		case e.Status == "running":
+			what = "▸ deploy: " + e.Phase
+		case e.Status == "done":
+			what = "  ✓ " + e.Phase
+		case e.Status == "failed":
+			what = "  ✗ " + e.Phase + " failed"
+		default:
+			return "" // Skipped phases
+		}
+		if e.Message != "" {
+			what += ": " + e.Message
+		}
+	case "health":
+		if e.Health == "healthy" {
+			what = "✓ healthy"
+		} else {
+			what = "✗ " + e.Health
+		}
+		if e.Reason != "" {
+			what += ": " + e.Reason
+		}
+	case "notification":
+		what = "! " + strings.ReplaceAll(e.Event, "_", " ")
+		if reason := e.Details["reason"]; reason != "" {
+			what += ": " + reason
+		}
+	case "resync":
+		what = "missed events while disconnected; run bp apps for the current state"
+	default:
+		what = e.Type
+	}
+	target := e.AppName
+	if target == "" {
+		target = "-"
+	}
+	return fmt.Sprintf("%s  %-20s %s", e.Time.Local().Format("15:04:05"), target, what)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// cmdWebSocket shows or sets an app's websocket idle timeout and how long its
+// previous container keeps open connections after a deploy
+func cmdWebSocket(args []string) {
+	usage := `Usage:
+  bp websocket <app>                  Show idle timeout and drain period
+  bp websocket <app> idle <secs>|off  Close connections idle for this long
+  bp websocket <app> drain <secs>|off Keep old connections open this long after a deploy`
+	if len(args) < 1 || len(args)%2 == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	appName := args[0]
+	a := fetchApp(appName)
+
+	config := app.WebSocketConfig{}
+	if a.WebSocket != nil {
+		config = *a.WebSocket
+	}
+	if len(args) == 1 {
+		fmt.Printf("%s:\n", appName)
+		fmt.Printf("  idle timeout: %s\n", formatSeconds(config.IdleTimeout, "none"))
+		fmt.Printf("  drain period: %s\n", formatSeconds(config.DrainTimeout, "off (old container is stopped once the new one is ready)"))
+		return
+	}
+
+	for i := 1; i < len(args); i += 2 {
+		secs := 0
+		if args[i+1] != "off" {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				fmt.Fprintf(os.Stderr, "Invalid value %q: use a number of seconds or off\n", args[i+1])
+				os.Exit(1)
+			}
+			secs = n
+		}
+		switch args[i] {
+		case "idle":
+			config.IdleTimeout = secs
+		case "drain":
+			config.DrainTimeout = secs
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+
+	resp, err := apiRequest("PUT", "/api/apps/"+appName, map[string]interface{}{"websocket": config})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", string(body))
+		os.Exit(1)
+	}
+	fmt.Printf("Updated '%s': idle timeout %s, drain period %s\n", appName,
+		formatSeconds(config.IdleTimeout, "none"), formatSeconds(config.DrainTimeout, "off"))
+}
+
+// formatSeconds renders a setting in seconds, or unset when it is 0
+func formatSeconds(secs int, unset string) string {
+	if secs == 0 {
+		return unset
+	}
+	return (time.Duration(secs) * time.Second).String()
+}
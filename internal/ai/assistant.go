@@ -56,8 +56,20 @@ type ActionInfo struct {
 
 // Caller represents the user making a request, used for access control.
 type Caller struct {
-	UserID   string // empty for legacy admin sessions
-	UserRole string // "admin", "deployer", "viewer"
+	UserID   string          // empty for legacy admin sessions
+	UserRole string          // "admin", "deployer", "viewer"
+	Apps     map[string]bool // IDs of the only apps the caller may see; nil means all
+}
+
+// scoped reports whether the caller is limited to some apps
+func (c *Caller) scoped() bool {
+	return c != nil && c.Apps != nil
+}
+
+// serverFunctions report on or change the whole server rather than one app,
+// so callers limited to some apps can't use them
+var serverFunctions = map[string]bool{
+	"create_app": true, "storage_info": true, "system_info": true, "list_models": true, "prune_images": true,
 }
 
 // Assistant is the AI assistant engine.
@@ -307,14 +319,14 @@ func (a *Assistant) EnsureRunning() error {
 	// This is needed even if the server was already running (e.g. after basepod restart)
 	// because model weights load lazily on first inference.
 	if !a.warmedUp {
-		if err := a.warmup(); err != nil {
+		if err := mlx.GetScheduler().Run("assistant-warmup", AssistantModelID, mlx.ClassInteractive, a.warmup); err != nil {
 			// Warmup failed (stuck process). Kill and restart.
 			svc.StopAssistant()
 			if err := svc.RunOnPort(AssistantModelID, a.port); err != nil {
 				return fmt.Errorf("failed to restart assistant after stuck process: %w", err)
 			}
 			// Try warmup once more after restart
-			if err := a.warmup(); err != nil {
+			if err := a.warmup(context.Background()); err != nil {
 				return fmt.Errorf("assistant warmup failed after restart: %w", err)
 			}
 		}
@@ -324,8 +336,8 @@ func (a *Assistant) EnsureRunning() error {
 
 // warmup sends a trivial request to pre-load model weights after starting.
 // Returns an error if the completions endpoint doesn't respond within 60s (stuck process).
-func (a *Assistant) warmup() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+func (a *Assistant) warmup(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 	url := fmt.Sprintf("http://localhost:%d/v1/completions", a.port)
 	body := map[string]any{"model": AssistantModelID, "prompt": "hi", "max_tokens": 1}
@@ -587,16 +599,47 @@ func cleanResponse(s string) string {
 	return strings.TrimSpace(s)
 }
 
+// writeFunctions lists the assistant functions that mutate server state.
+var writeFunctions = map[string]bool{
+	"start_app": true, "stop_app": true, "restart_app": true,
+	"deploy_app": true, "create_app": true, "delete_app": true, "prune_images": true,
+}
+
+// Functions returns the operations the assistant can perform, for exposing
+// them through other tool protocols (e.g. MCP).
+func Functions() []AssistantFunc {
+	return assistantFunctions
+}
+
+// IsWriteFunction reports whether the named function mutates server state.
+func IsWriteFunction(name string) bool {
+	return writeFunctions[name]
+}
+
+// IsServerFunction reports whether the named function acts on the whole
+// server rather than one app.
+func IsServerFunction(name string) bool {
+	return serverFunctions[name]
+}
+
+// Execute runs a single assistant function directly, bypassing the model.
+// Access control is the same as for model-initiated calls.
+func (a *Assistant) Execute(name string, params map[string]any, caller *Caller) (string, error) {
+	if params == nil {
+		params = map[string]any{}
+	}
+	return a.executeFunction(&FunctionCall{Name: name, Parameters: params}, caller)
+}
+
 // executeFunction runs a parsed function call against basepod internals.
 func (a *Assistant) executeFunction(call *FunctionCall, caller *Caller) (string, error) {
 	// Write operations are blocked for viewers
-	writeOps := map[string]bool{
-		"start_app": true, "stop_app": true, "restart_app": true,
-		"deploy_app": true, "create_app": true, "delete_app": true, "prune_images": true,
-	}
-	if caller != nil && caller.UserRole == "viewer" && writeOps[call.Name] {
+	if caller != nil && caller.UserRole == "viewer" && writeFunctions[call.Name] {
 		return "", fmt.Errorf("permission denied: viewers cannot perform %s", call.Name)
 	}
+	if caller.scoped() && serverFunctions[call.Name] {
+		return "", fmt.Errorf("permission denied: %s is not available to accounts limited to specific apps", call.Name)
+	}
 
 	switch call.Name {
 	case "list_apps":
@@ -634,6 +677,19 @@ func (a *Assistant) executeFunction(call *FunctionCall, caller *Caller) (string,
 
 // listAppsForCaller returns apps the caller has access to.
 func (a *Assistant) listAppsForCaller(caller *Caller) ([]app.App, error) {
+	if caller.scoped() {
+		apps, err := a.storage.ListApps()
+		if err != nil {
+			return nil, err
+		}
+		var visible []app.App
+		for _, ap := range apps {
+			if caller.Apps[ap.ID] {
+				visible = append(visible, ap)
+			}
+		}
+		return visible, nil
+	}
 	if caller != nil && caller.UserRole == "deployer" && caller.UserID != "" {
 		return a.storage.ListAppsForUser(caller.UserID)
 	}
@@ -650,6 +706,11 @@ func (a *Assistant) getAppWithAccessCheck(name string, caller *Caller) (*app.App
 		return nil, nil
 	}
 
+	// Scoped callers only see their own apps, as if the others didn't exist
+	if caller.scoped() && !caller.Apps[ap.ID] {
+		return nil, nil
+	}
+
 	// Deployers can only access apps they have permission for
 	if caller != nil && caller.UserRole == "deployer" && caller.UserID != "" {
 		hasAccess, err := a.storage.UserHasAppAccess(caller.UserID, ap.ID)
@@ -852,7 +913,9 @@ func (a *Assistant) execDeployApp(params map[string]any, caller *Caller) (string
 		Ports: map[string]string{
 			fmt.Sprintf("%d", containerPort): fmt.Sprintf("%d", hostPort),
 		},
-		Env: ap.Env,
+		Env:    ap.Env,
+		Memory: ap.Resources.MemoryBytes(),
+		CPUs:   ap.Resources.CPUs,
 	}
 
 	containerID, err := a.podman.CreateContainer(ctx, opts)
@@ -912,7 +975,7 @@ func (a *Assistant) execGetLogs(params map[string]any, caller *Caller) (string,
 		return "", err
 	}
 
-	logStr := stripPodmanHeaders(logData)
+	logStr := podman.DemuxLogs(logData)
 	if logStr == "" {
 		return fmt.Sprintf("No recent logs for %s.", name), nil
 	}
@@ -1171,27 +1234,3 @@ func assignHostPort(appID string) int {
 	return 10000 + int(h%50000)
 }
 
-// stripPodmanHeaders removes Podman multiplexed stream headers from log output.
-func stripPodmanHeaders(data []byte) string {
-	var sb strings.Builder
-	pos := 0
-	for pos < len(data) {
-		if pos+8 > len(data) {
-			sb.Write(data[pos:])
-			break
-		}
-		// Frame header: [stream_type(1), padding(3), size(4 big-endian)]
-		frameSize := int(data[pos+4])<<24 | int(data[pos+5])<<16 | int(data[pos+6])<<8 | int(data[pos+7])
-		if frameSize <= 0 || frameSize > 1<<20 {
-			// Not a multiplexed stream, return as-is
-			sb.Write(data[pos:])
-			break
-		}
-		pos += 8
-		end := min(pos+frameSize, len(data))
-		sb.Write(data[pos:end])
-		pos = end
-	}
-	return sb.String()
-}
-
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+	"github.com/base-go/basepod/internal/config"
+)
+
+// defaultAccessLogRetentionDays applies when privacy.access_log_retention_days is unset
+const defaultAccessLogRetentionDays = 30
+
+// accessLogRetention is how long access log entries are kept
+func (s *Server) accessLogRetention() time.Duration {
+	days := s.config.Privacy.AccessLogRetentionDays
+	if days <= 0 {
+		days = defaultAccessLogRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// accessLogDir holds access.log, its rolled files and caddy.err
+func accessLogDir() string {
+	paths, _ := config.GetPaths()
+	return filepath.Join(paths.Base, "logs")
+}
+
+// configureAccessLogs points Caddy's access log at its own rolled file and
+// masks client IPs for apps that anonymize them
+func (s *Server) configureAccessLogs() error {
+	if s.caddy == nil {
+		return nil
+	}
+	opts := caddy.AccessLogOptions{
+		Filename:      filepath.Join(accessLogDir(), "access.log"),
+		RetentionDays: int(s.accessLogRetention() / (24 * time.Hour)),
+		AnonymizeAll:  s.config.Privacy.AnonymizeIPs,
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		a := &apps[i]
+		if a.AnonymizesIPs(false) {
+			opts.AnonymizeHosts = append(opts.AnonymizeHosts, appDomains(a)...)
+		}
+	}
+	return s.caddy.EnableAccessLog(opts)
+}
+
+// appDomains returns an app's domain and aliases
+func appDomains(a *app.App) []string {
+	var domains []string
+	if a.Domain != "" {
+		domains = append(domains, a.Domain)
+	}
+	return append(domains, a.Aliases...)
+}
+
+// anonymizeIP masks the last octet of an IPv4 address and all but the /48 of
+// an IPv6 address. A port, if present, is dropped.
+func anonymizeIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return net.IPv4(v4[0], v4[1], v4[2], 0).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// anonymizeAccessEntry masks client addresses in a Caddy access log entry
+func anonymizeAccessEntry(entry map[string]interface{}) {
+	req, ok := entry["request"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{"remote_ip", "client_ip", "remote_addr"} {
+		if v, ok := req[key].(string); ok {
+			req[key] = anonymizeIP(v)
+		}
+	}
+	if headers, ok := req["headers"].(map[string]interface{}); ok {
+		delete(headers, "X-Forwarded-For")
+		delete(headers, "X-Real-Ip")
+	}
+}
+
+// accessLogFiles returns the files that may hold access log entries, oldest
+// first. caddy.err holds entries written before access logs had their own file.
+func accessLogFiles() []string {
+	dir := accessLogDir()
+	files := []string{filepath.Join(dir, "caddy.err")}
+	rolled, _ := filepath.Glob(filepath.Join(dir, "access-*.log*"))
+	sort.Strings(rolled) // Rolled names carry a sortable timestamp
+	files = append(files, rolled...)
+	return append(files, filepath.Join(dir, "access.log"))
+}
+
+// scanAccessLog calls fn for each access log entry in path for one of domains
+// and newer than since. If tail > 0 only the last tail bytes are read.
+func scanAccessLog(path string, domains map[string]bool, since time.Time, tail int64, fn func(entry map[string]interface{})) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	} else if tail > 0 {
+		if stat, err := file.Stat(); err == nil && stat.Size() > tail {
+			file.Seek(-tail, io.SeekEnd)
+		}
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+
+		// Only process access log entries (skip Caddy operational logs)
+		logger, _ := entry["logger"].(string)
+		if !strings.Contains(logger, "http.log.access") {
+			continue
+		}
+		if ts, ok := entry["ts"].(float64); ok && ts < float64(since.Unix()) {
+			continue
+		}
+		reqMap, ok := entry["request"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		host, _ := reqMap["host"].(string)
+		if !domains[host] {
+			continue
+		}
+		fn(entry)
+	}
+	return scanner.Err()
+}
+
+// accessLogCSVHeader lists the columns of a CSV export
+var accessLogCSVHeader = []string{"time", "host", "method", "uri", "status", "size", "duration_ms", "client_ip", "user_agent", "referer"}
+
+// accessLogCSVRow flattens an access log entry into accessLogCSVHeader columns
+func accessLogCSVRow(entry map[string]interface{}) []string {
+	req, _ := entry["request"].(map[string]interface{})
+	headers, _ := req["headers"].(map[string]interface{})
+	header := func(name string) string {
+		if values, ok := headers[name].([]interface{}); ok && len(values) > 0 {
+			v, _ := values[0].(string)
+			return v
+		}
+		return ""
+	}
+	str := func(m map[string]interface{}, key string) string {
+		v, _ := m[key].(string)
+		return v
+	}
+	num := func(key string) string {
+		if v, ok := entry[key].(float64); ok {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	ts := ""
+	if v, ok := entry["ts"].(float64); ok {
+		ts = time.Unix(0, int64(v*float64(time.Second))).UTC().Format(time.RFC3339)
+	}
+	clientIP := str(req, "client_ip")
+	if clientIP == "" {
+		clientIP = str(req, "remote_ip")
+	}
+	duration := ""
+	if v, ok := entry["duration"].(float64); ok {
+		duration = strconv.FormatFloat(v*1000, 'f', 1, 64)
+	}
+	return []string{ts, str(req, "host"), str(req, "method"), str(req, "uri"), num("status"), num("size"), duration, clientIP, header("User-Agent"), header("Referer")}
+}
+
+// handleExportAccessLogs streams an app's access logs within the retention
+// period as JSON lines or CSV (?format=csv). ?anonymized=1 masks client IPs;
+// they are always masked for apps that anonymize them.
+func (s *Server) handleExportAccessLogs(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	q := r.URL.Query()
+	since := time.Now().Add(-s.accessLogRetention())
+	if v := q.Get("since"); v != "" {
+		var t time.Time
+		if d, err := time.ParseDuration(v); err == nil {
+			t = time.Now().Add(-d)
+		} else if t, err = time.Parse(time.RFC3339, v); err != nil {
+			errorResponse(w, http.StatusBadRequest, "since must be RFC3339 or a duration like 24h")
+			return
+		}
+		if t.After(since) {
+			since = t
+		}
+	}
+	anonymize := a.AnonymizesIPs(s.config.Privacy.AnonymizeIPs) || q.Get("anonymized") == "1" || q.Get("anonymized") == "true"
+	format := q.Get("format")
+	if format == "" {
+		format = "jsonl"
+	}
+	if format != "jsonl" && format != "csv" {
+		errorResponse(w, http.StatusBadRequest, "format must be jsonl or csv")
+		return
+	}
+
+	domains := map[string]bool{}
+	for _, d := range appDomains(a) {
+		domains[d] = true
+	}
+
+	filename := fmt.Sprintf("%s-access-%s.%s", a.Name, time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	var write func(entry map[string]interface{})
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		defer cw.Flush()
+		cw.Write(accessLogCSVHeader)
+		write = func(entry map[string]interface{}) { cw.Write(accessLogCSVRow(entry)) }
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(entry map[string]interface{}) { enc.Encode(entry) }
+	}
+
+	count := 0
+	for _, path := range accessLogFiles() {
+		err := scanAccessLog(path, domains, since, 0, func(entry map[string]interface{}) {
+			if anonymize {
+				anonymizeAccessEntry(entry)
+			}
+			write(entry)
+			count++
+		})
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Access log export for %s: %s: %v", a.Name, path, err)
+		}
+	}
+
+	details := fmt.Sprintf("%d entries, %s", count, format)
+	if anonymize {
+		details += ", anonymized"
+	}
+	s.logActivity("user", "access_log_export", "app", a.ID, a.Name, "success", details)
+}
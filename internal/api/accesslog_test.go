@@ -0,0 +1,71 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"203.0.113.77":              "203.0.113.0",
+		"203.0.113.77:51234":        "203.0.113.0",
+		"2001:db8:abcd:12::1":       "2001:db8:abcd::",
+		"[2001:db8:abcd:12::1]:443": "2001:db8:abcd::",
+		"not-an-ip":                 "not-an-ip",
+	}
+	for in, want := range cases {
+		if got := anonymizeIP(in); got != want {
+			t.Fatalf("anonymizeIP(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestScanAccessLogAnonymized(t *testing.T) {
+	t.Parallel()
+
+	now := float64(time.Now().Unix())
+	old := float64(time.Now().Add(-48 * time.Hour).Unix())
+	lines := []string{
+		`{"logger":"http.log.access.default","ts":` + strconv.FormatFloat(now, 'f', 3, 64) + `,"request":{"host":"shop.example.com","remote_ip":"198.51.100.23","headers":{"X-Forwarded-For":["198.51.100.23"]}},"status":200}`,
+		`{"logger":"http.log.access.default","ts":` + strconv.FormatFloat(old, 'f', 3, 64) + `,"request":{"host":"shop.example.com","remote_ip":"198.51.100.24"},"status":200}`,
+		`{"logger":"http.log.access.default","ts":` + strconv.FormatFloat(now, 'f', 3, 64) + `,"request":{"host":"other.example.com","remote_ip":"198.51.100.25"},"status":200}`,
+		`{"logger":"tls","ts":` + strconv.FormatFloat(now, 'f', 3, 64) + `,"msg":"certificate obtained"}`,
+		`not json`,
+	}
+	path := filepath.Join(t.TempDir(), "access.log")
+	data := ""
+	for _, l := range lines {
+		data += l + "\n"
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]interface{}
+	since := time.Now().Add(-24 * time.Hour)
+	err := scanAccessLog(path, map[string]bool{"shop.example.com": true}, since, 0, func(entry map[string]interface{}) {
+		anonymizeAccessEntry(entry)
+		got = append(got, entry)
+	})
+	if err != nil {
+		t.Fatalf("scanAccessLog: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d entries, want 1: %v", len(got), got)
+	}
+	req := got[0]["request"].(map[string]interface{})
+	if req["remote_ip"] != "198.51.100.0" {
+		t.Fatalf("remote_ip = %v", req["remote_ip"])
+	}
+	if _, ok := req["headers"].(map[string]interface{})["X-Forwarded-For"]; ok {
+		t.Fatal("X-Forwarded-For should be removed")
+	}
+	if row := accessLogCSVRow(got[0]); row[1] != "shop.example.com" || row[4] != "200" || row[7] != "198.51.100.0" {
+		t.Fatalf("csv row = %q", row)
+	}
+}
@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/templates"
+	"github.com/google/uuid"
+)
+
+// addonTemplates maps addon types to the templates that run them
+var addonTemplates = map[string]string{
+	"postgres": "postgres",
+	"mysql":    "mysql",
+	"redis":    "redis",
+}
+
+// addonTypes lists the addon types for error messages
+func addonTypes() string {
+	types := make([]string, 0, len(addonTemplates))
+	for t := range addonTemplates {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// defaultAddonEnvVar is the env var an attached app gets an addon's URL in
+// unless asked otherwise
+func defaultAddonEnvVar(addonType string) string {
+	if addonType == "redis" {
+		return "REDIS_URL"
+	}
+	return "DATABASE_URL"
+}
+
+// addonView fills in an addon's name and status from its backing app and
+// keeps only the attachments within the caller's scope
+func addonView(addon *app.Addon, backing *app.App, sc appScope) *app.Addon {
+	addon.Name = backing.Name
+	addon.Status = backing.Status
+	attachments := []app.AddonAttachment{}
+	for _, att := range addon.Attachments {
+		if sc.allows(att.AppID) {
+			attachments = append(attachments, att)
+		}
+	}
+	addon.Attachments = attachments
+	return addon
+}
+
+// resolveAddon finds an addon and its backing app by app ID or name. Both
+// are nil if there is no such addon.
+func (s *Server) resolveAddon(idOrName string) (*app.Addon, *app.App, error) {
+	backing, err := s.resolveApp(idOrName)
+	if err != nil || backing == nil {
+		return nil, nil, err
+	}
+	addon, err := s.storage.GetAddon(backing.ID)
+	if err != nil || addon == nil {
+		return nil, nil, err
+	}
+	return addon, backing, nil
+}
+
+// attachAddon sets an addon's URL in envVar of target and records the
+// attachment. It won't replace a value the app already has for envVar.
+func (s *Server) attachAddon(addon *app.Addon, backing, target *app.App, envVar string) error {
+	if target.ID == backing.ID {
+		return fmt.Errorf("an addon can't be attached to itself")
+	}
+	if !envKeyPattern.MatchString(envVar) {
+		return fmt.Errorf("invalid env var name %q", envVar)
+	}
+	url := databaseURL(backing)
+	if url == "" {
+		return fmt.Errorf("addon %s has no connection URL", backing.Name)
+	}
+	if current := target.Env[envVar]; current != "" && current != url {
+		return fmt.Errorf("%s already sets %s; pick another env var", target.Name, envVar)
+	}
+
+	if target.Env == nil {
+		target.Env = make(map[string]string)
+	}
+	target.Env[envVar] = url
+	target.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(target); err != nil {
+		return err
+	}
+	return s.storage.AttachAddon(&app.AddonAttachment{
+		AddonID:   addon.ID,
+		AppID:     target.ID,
+		EnvVar:    envVar,
+		CreatedAt: time.Now(),
+	})
+}
+
+// detachAddon removes an attachment and the addon's URL from the app's env.
+// The env var is kept if it no longer holds the addon's URL.
+func (s *Server) detachAddon(att app.AddonAttachment, backing *app.App) error {
+	target, err := s.storage.GetApp(att.AppID)
+	if err != nil {
+		return err
+	}
+	if target != nil && target.Env[att.EnvVar] == databaseURL(backing) {
+		delete(target.Env, att.EnvVar)
+		target.UpdatedAt = time.Now()
+		if err := s.storage.UpdateApp(target); err != nil {
+			return err
+		}
+	}
+	return s.storage.DetachAddon(att.AddonID, att.AppID)
+}
+
+// handleCreateAddon provisions a Postgres, MySQL or Redis container with a
+// data volume and generated credentials, optionally attaching it to an app
+func (s *Server) handleCreateAddon(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		Attach string `json:"attach"`  // App to attach the addon to
+		EnvVar string `json:"env_var"` // Env var for the attached app
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	templateID, ok := addonTemplates[req.Type]
+	if !ok {
+		errorResponse(w, http.StatusBadRequest, "type must be one of: "+addonTypes())
+		return
+	}
+	tmpl := templates.GetTemplate(templateID)
+	if tmpl == nil || !tmpl.IsArchSupported() {
+		errorResponse(w, http.StatusBadRequest, req.Type+" is not available on this architecture")
+		return
+	}
+
+	// Check the app to attach to before provisioning anything
+	var target *app.App
+	if req.Attach != "" {
+		sc, err := s.callerScope(r)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+			return
+		}
+		target, err = s.resolveApp(req.Attach)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if target == nil || !sc.allows(target.ID) {
+			errorResponse(w, http.StatusNotFound, "App to attach not found")
+			return
+		}
+	}
+	envVar := req.EnvVar
+	if envVar == "" {
+		envVar = defaultAddonEnvVar(req.Type)
+	}
+	if !envKeyPattern.MatchString(envVar) {
+		errorResponse(w, http.StatusBadRequest, "Invalid env var name")
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		if target != nil {
+			name = fmt.Sprintf("%s-%s", target.Name, req.Type)
+		} else {
+			name = fmt.Sprintf("%s-%s", req.Type, uuid.New().String()[:8])
+		}
+	}
+	if existing, _ := s.storage.GetAppByName(name); existing != nil {
+		errorResponse(w, http.StatusConflict, "App with this name already exists")
+		return
+	}
+
+	var volumes []app.VolumeMount
+	for _, v := range tmpl.Volumes {
+		volumes = append(volumes, app.VolumeMount{
+			Name:          v.Name,
+			ContainerPath: v.ContainerPath,
+			Size:          v.Size,
+		})
+	}
+	backing := &app.App{
+		ID:      uuid.New().String(),
+		Name:    name,
+		Image:   tmpl.GetImage(),
+		Status:  app.StatusPending,
+		Env:     mergedTemplateEnv(tmpl, nil, name, ""),
+		Volumes: volumes,
+		Ports: app.PortConfig{
+			ContainerPort: tmpl.Port,
+			Protocol:      "http",
+		},
+		Resources: app.ResourceConfig{
+			Replicas: 1,
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.storage.CreateApp(backing); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.grantCreatorAccess(r, backing.ID)
+
+	addon := &app.Addon{ID: backing.ID, Type: req.Type, CreatedAt: time.Now()}
+	if err := s.storage.CreateAddon(addon); err != nil {
+		_ = s.storage.DeleteApp(backing.ID)
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logActivity("user", "create_addon", "app", backing.ID, backing.Name, "success", req.Type)
+
+	// Attach before deploying: the URL only needs the generated credentials
+	resp := map[string]interface{}{}
+	if target != nil {
+		if err := s.attachAddon(addon, backing, target, envVar); err != nil {
+			// The addon is created either way; attaching can be retried
+			resp["attach_error"] = err.Error()
+		} else {
+			s.logActivity("user", "attach_addon", "app", target.ID, target.Name, "success", fmt.Sprintf("%s as %s", backing.Name, envVar))
+			resp["message"] = fmt.Sprintf("%s has been set on %s. Restart the app for changes to take effect.", envVar, target.Name)
+		}
+		if updated, err := s.storage.GetAddon(backing.ID); err == nil && updated != nil {
+			addon = updated
+		}
+	}
+	resp["addon"] = addonView(addon, backing, nil)
+	go s.deployFromTemplate(backing, tmpl)
+
+	jsonResponse(w, http.StatusCreated, resp)
+}
+
+// handleListAddons lists the addons the caller can see
+func (s *Server) handleListAddons(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	addons, err := s.storage.ListAddons()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	visible := []*app.Addon{}
+	for i := range addons {
+		if !sc.allows(addons[i].ID) {
+			continue
+		}
+		backing, err := s.storage.GetApp(addons[i].ID)
+		if err != nil || backing == nil {
+			continue
+		}
+		visible = append(visible, addonView(&addons[i], backing, sc))
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"addons": visible})
+}
+
+// handleGetAddon returns an addon with its attachments
+func (s *Server) handleGetAddon(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	addon, backing, err := s.resolveAddon(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if addon == nil {
+		errorResponse(w, http.StatusNotFound, "Addon not found")
+		return
+	}
+	jsonResponse(w, http.StatusOK, addonView(addon, backing, sc))
+}
+
+// handleAttachAddon sets an addon's connection URL in an app's env
+func (s *Server) handleAttachAddon(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		App    string `json:"app"`
+		EnvVar string `json:"env_var"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.App == "" {
+		errorResponse(w, http.StatusBadRequest, "app is required")
+		return
+	}
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	addon, backing, err := s.resolveAddon(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if addon == nil {
+		errorResponse(w, http.StatusNotFound, "Addon not found")
+		return
+	}
+	target, err := s.resolveApp(req.App)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if target == nil || !sc.allows(target.ID) {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	envVar := req.EnvVar
+	if envVar == "" {
+		envVar = defaultAddonEnvVar(addon.Type)
+	}
+	if err := s.attachAddon(addon, backing, target, envVar); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.logActivity("user", "attach_addon", "app", target.ID, target.Name, "success", fmt.Sprintf("%s as %s", backing.Name, envVar))
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"addon":   backing.Name,
+		"app":     target.Name,
+		"env_var": envVar,
+		"message": fmt.Sprintf("%s has been set. Restart the app for changes to take effect.", envVar),
+	})
+}
+
+// handleDetachAddon removes an addon's connection URL from an app's env
+func (s *Server) handleDetachAddon(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	addon, backing, err := s.resolveAddon(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if addon == nil {
+		errorResponse(w, http.StatusNotFound, "Addon not found")
+		return
+	}
+	target, err := s.resolveApp(r.PathValue("app"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if target == nil || !sc.allows(target.ID) {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	for _, att := range addon.Attachments {
+		if att.AppID != target.ID {
+			continue
+		}
+		if err := s.detachAddon(att, backing); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.logActivity("user", "detach_addon", "app", target.ID, target.Name, "success", backing.Name)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":  "detached",
+			"message": fmt.Sprintf("%s has been removed. Restart the app for changes to take effect.", att.EnvVar),
+		})
+		return
+	}
+	errorResponse(w, http.StatusNotFound, fmt.Sprintf("%s is not attached to %s", backing.Name, target.Name))
+}
+
+// handleDestroyAddon detaches an addon from every app and removes its
+// container and, unless ?keep_data=true, its data volumes
+func (s *Server) handleDestroyAddon(w http.ResponseWriter, r *http.Request) {
+	addon, backing, err := s.resolveAddon(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if addon == nil {
+		errorResponse(w, http.StatusNotFound, "Addon not found")
+		return
+	}
+
+	var detached []string
+	for _, att := range addon.Attachments {
+		if err := s.detachAddon(att, backing); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to detach "+att.AppName+": "+err.Error())
+			return
+		}
+		detached = append(detached, att.AppName)
+	}
+
+	ctx := context.Background()
+	if backing.ContainerID != "" {
+		_ = s.podman.StopContainer(ctx, backing.ContainerID, 10)
+		_ = s.podman.RemoveContainer(ctx, backing.ContainerID, true)
+	}
+	keepData := r.URL.Query().Get("keep_data") == "true"
+	if !keepData {
+		for _, v := range backing.Volumes {
+			if err := s.podman.RemoveVolume(ctx, appVolumeName(backing, v), true); err != nil {
+				log.Printf("Warning: failed to remove volume %s: %v", appVolumeName(backing, v), err)
+			}
+		}
+	}
+	if err := s.storage.DeleteSecretsForApp(backing.ID); err != nil {
+		log.Printf("Warning: failed to delete secrets for %s: %v", backing.Name, err)
+	}
+	if err := s.storage.DeleteAddon(backing.ID); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := s.storage.DeleteApp(backing.ID); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	details := addon.Type
+	if len(detached) > 0 {
+		details += "; detached from " + strings.Join(detached, ", ")
+	}
+	s.logActivity("user", "destroy_addon", "app", backing.ID, backing.Name, "success", details)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":    "destroyed",
+		"detached":  detached,
+		"kept_data": keepData,
+	})
+}
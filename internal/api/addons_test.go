@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/templates"
+)
+
+func TestAddonDatabaseURL(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		addonType string
+		want      string
+	}{
+		{"postgres", "postgresql://basepod:%s@basepod-shop-db:5432/shop-db?sslmode=disable"},
+		{"mysql", "mysql://basepod:%s@basepod-shop-db:3306/shop-db"},
+		{"redis", "redis://:%s@basepod-shop-db:6379"},
+	}
+	for _, c := range cases {
+		tmpl := templates.GetTemplate(addonTemplates[c.addonType])
+		if tmpl == nil {
+			t.Fatalf("%s: no template", c.addonType)
+		}
+		env := mergedTemplateEnv(tmpl, nil, "shop-db", "")
+		a := &app.App{Name: "shop-db", Env: env, Ports: app.PortConfig{ContainerPort: tmpl.Port}}
+
+		password := env["POSTGRES_PASSWORD"]
+		switch c.addonType {
+		case "mysql":
+			password = env["MYSQL_PASSWORD"]
+		case "redis":
+			password = env["REDIS_PASSWORD"]
+		}
+		if password == "" || password == "changeme" {
+			t.Fatalf("%s: password not generated: %q", c.addonType, password)
+		}
+		want := fmt.Sprintf(c.want, password)
+		if got := databaseURL(a); got != want {
+			t.Fatalf("%s: databaseURL = %q, want %q", c.addonType, got, want)
+		}
+	}
+}
+
+func TestDefaultAddonEnvVar(t *testing.T) {
+	t.Parallel()
+
+	for addonType, want := range map[string]string{"postgres": "DATABASE_URL", "mysql": "DATABASE_URL", "redis": "REDIS_URL"} {
+		if got := defaultAddonEnvVar(addonType); got != want {
+			t.Fatalf("defaultAddonEnvVar(%s) = %s, want %s", addonType, got, want)
+		}
+	}
+}
+
+func TestAddonViewFiltersAttachments(t *testing.T) {
+	t.Parallel()
+
+	addon := &app.Addon{ID: "db", Type: "postgres", Attachments: []app.AddonAttachment{
+		{AddonID: "db", AppID: "web", EnvVar: "DATABASE_URL"},
+		{AddonID: "db", AppID: "worker", EnvVar: "DATABASE_URL"},
+	}}
+	view := addonView(addon, &app.App{Name: "shop-db", Status: app.StatusRunning}, appScope{"db": true, "web": true})
+	if view.Name != "shop-db" || view.Status != app.StatusRunning {
+		t.Fatalf("view = %+v", view)
+	}
+	if len(view.Attachments) != 1 || view.Attachments[0].AppID != "web" {
+		t.Fatalf("attachments = %+v, want only web", view.Attachments)
+	}
+}
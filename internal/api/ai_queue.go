@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/base-go/basepod/internal/mlx"
+)
+
+// handleAIQueue lists running and queued AI jobs in dispatch order. Jobs
+// aren't tied to apps, so callers limited to some apps can't see it.
+func (s *Server) handleAIQueue(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, mlx.GetScheduler().Status())
+}
+
+// handleAIQueueMove moves a queued AI job to a new position (0 = next to run)
+func (s *Server) handleAIQueueMove(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Position int `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := mlx.GetScheduler().Move(r.PathValue("id"), req.Position); err != nil {
+		errorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	jsonResponse(w, http.StatusOK, mlx.GetScheduler().Status())
+}
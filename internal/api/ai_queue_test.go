@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestAIQueueNeedsUnscopedCaller(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "queue-web", Domain: "queue-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	body := map[string]interface{}{"email": "queue@example.com", "password": "deployer-pass", "role": "deployer", "app_ids": []string{"queue-web"}}
+	if code := ts.do("POST", "/api/users", body, nil); code != http.StatusCreated {
+		t.Fatalf("create user: status %d", code)
+	}
+	dev := ts.login("queue@example.com", "deployer-pass")
+
+	if code := ts.do("GET", "/api/ai/queue", nil, nil); code != http.StatusOK {
+		t.Fatalf("admin reading the AI queue: status %d, want 200", code)
+	}
+	for name, caller := range map[string]string{"session": dev, "token": ts.scopedToken(dev, "deploy:queue-web")} {
+		if code := ts.doAs(caller, "GET", "/api/ai/queue", nil, nil); code != http.StatusForbidden {
+			t.Fatalf("scoped %s reading the AI queue: status %d, want 403", name, code)
+		}
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
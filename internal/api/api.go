@@ -9,8 +9,10 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"html"
 	"io"
 	"io/fs"
 	"log"
@@ -33,8 +35,11 @@ import (
 	"github.com/base-go/basepod/internal/caddy"
 	"github.com/base-go/basepod/internal/config"
 	"github.com/base-go/basepod/internal/diskutil"
+	"github.com/base-go/basepod/internal/dnsprovider"
 	"github.com/base-go/basepod/internal/mlx"
 	"github.com/base-go/basepod/internal/podman"
+	"github.com/base-go/basepod/internal/pullcache"
+	"github.com/base-go/basepod/internal/registry"
 	"github.com/base-go/basepod/internal/storage"
 	"github.com/base-go/basepod/internal/templates"
 	"github.com/base-go/basepod/internal/web"
@@ -87,6 +92,23 @@ type Server struct {
 	healthStop      chan struct{}
 	redirectCache   map[string]*redirectCacheEntry
 	redirectCacheMu sync.RWMutex
+	generations     map[string]context.CancelFunc // in-flight chat completions by generation ID
+	generationsMu   sync.Mutex
+	pullCache       *pullcache.Cache
+	registry        *registry.Registry
+	startedAt       time.Time
+	watchdog        *watchdogState
+	events          *eventHub
+	requests        *requestCounter // Counts access log requests between metric points
+	dnsProvider     dnsprovider.Provider
+	dnsSync         dnsSyncState
+	catalog         templateCatalogState
+	builds          buildQueue
+	prom            promMetrics // Daemon counters for GET /metrics
+	twoFactorMu     sync.Mutex  // Serializes code checks so a code or recovery code is used once
+	logins          loginLimiter
+	uptime          uptimeState // Probe state of each app's public URL
+	domainRedirects domainRedirectCache
 }
 
 // NewServer creates a new API server
@@ -114,6 +136,24 @@ func NewServerWithVersion(store *storage.Storage, pm podman.Client, caddyClient
 		assistant: ai.New(store, pm),
 		router:    http.NewServeMux(),
 		version:   version,
+		startedAt: time.Now(),
+		watchdog:  newWatchdogState(),
+		events:    newEventHub(),
+		requests:  newRequestCounter(filepath.Join(accessLogDir(), "access.log")),
+	}
+	if provider, err := dnsprovider.New(cfg.DNSProvider); err != nil {
+		log.Printf("Warning: DNS provider disabled: %v", err)
+	} else {
+		s.dnsProvider = provider
+	}
+	if store != nil {
+		store.OnAppUpdate(func(a app.App) {
+			s.publishAppUpdate(a)
+			s.queueDNSSync(a)
+		})
+		if err := s.auth.UseStore(store); err != nil {
+			log.Printf("Warning: Failed to load sessions, logins won't survive restarts: %v", err)
+		}
 	}
 
 	// Setup static file serving - prefer disk over embedded
@@ -154,11 +194,42 @@ func NewServerWithVersion(store *storage.Storage, pm podman.Client, caddyClient
 	s.healthStop = make(chan struct{})
 	s.redirectCache = make(map[string]*redirectCacheEntry)
 
+	mlx.SetEvictionPolicy(cfg.AI.EvictionPolicy)
+	mlx.GetScheduler().SetLimits(cfg.AI.MaxConcurrentJobs, cfg.AI.MaxBackgroundJobs)
+
+	s.pullCache = pullcache.New(cfg.Podman.PullCache, pm)
+	if pm != nil && cfg.Podman.PullCache.Enabled {
+		pm.SetPullMirror(s.pullCache)
+	}
+	s.pullCache.Start()
+
+	s.registry = registry.New(cfg.Podman.Registry, pm)
+	s.registry.Start()
+
 	s.setupRoutes()
 
+	if err := s.configureAccessLogs(); err != nil {
+		log.Printf("Warning: Failed to enable Caddy access logging: %v", err)
+	} else if s.caddy != nil {
+		log.Printf("Caddy access logging enabled (%s)", filepath.Join(accessLogDir(), "access.log"))
+	}
+	s.applyAllDomainRoutes()
+
 	go s.runHealthChecker()
 	go s.runMetricsCollector()
 	go s.reconcileContainers()
+	go s.runLogIndexer()
+	go s.runMaintenanceScheduler()
+	go s.runContainerMonitor()
+	go s.runPodmanEvents()
+	go s.runDigestScheduler()
+	go s.runCronScheduler()
+	go s.runWatchdog()
+	go s.runTLSScanner()
+	go s.runUptimeMonitor()
+	go s.syncAllDNS()
+	go s.runTemplateCatalogSync()
+	go s.runSessionCleanup()
 
 	return s
 }
@@ -170,19 +241,46 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("GET /api/health", s.handleHealth)
 
 	// Auth routes (no auth required)
-	s.router.HandleFunc("POST /api/auth/login", s.handleLogin)
+	s.router.HandleFunc("POST /api/auth/login", s.limitLogins(s.handleLogin))
 	s.router.HandleFunc("POST /api/auth/logout", s.handleLogout)
 	s.router.HandleFunc("GET /api/auth/status", s.handleAuthStatus)
 	s.router.HandleFunc("POST /api/auth/setup", s.handleSetup) // Initial password setup
-	s.router.HandleFunc("POST /api/auth/change-password", s.requireAuth(s.requireSessionOnly(s.handleChangePassword)))
+	s.router.HandleFunc("POST /api/auth/change-password", s.requireAuth(s.requireSessionOnly(s.limitLogins(s.handleChangePassword))))
 	s.router.HandleFunc("GET /api/auth/me", s.requireAuth(s.handleGetMe))
+	s.router.HandleFunc("GET /api/auth/sessions", s.requireAuth(s.requireSessionOnly(s.handleListSessions)))
+	s.router.HandleFunc("DELETE /api/auth/sessions", s.requireAuth(s.requireSessionOnly(s.handleRevokeOtherSessions)))
+	s.router.HandleFunc("DELETE /api/auth/sessions/{id}", s.requireAuth(s.requireSessionOnly(s.handleRevokeSession)))
+
+	// Failed login tracking (admin only)
+	s.router.HandleFunc("GET /api/auth/lockouts", s.requireAdmin(s.handleListLoginLockouts))
+	s.router.HandleFunc("DELETE /api/auth/lockouts", s.requireAdmin(s.handleClearLoginLockout))
+	s.router.HandleFunc("DELETE /api/auth/lockouts/{ip}", s.requireAdmin(s.handleClearLoginLockout))
+
+	// Two-factor auth for the signed-in account (sessions only)
+	s.router.HandleFunc("GET /api/auth/2fa", s.requireAuth(s.requireSessionOnly(s.handleTwoFactorStatus)))
+	s.router.HandleFunc("POST /api/auth/2fa/enroll", s.requireAuth(s.requireSessionOnly(s.handleTwoFactorEnroll)))
+	s.router.HandleFunc("POST /api/auth/2fa/verify", s.requireAuth(s.requireSessionOnly(s.limitLogins(s.handleTwoFactorVerify))))
+	s.router.HandleFunc("POST /api/auth/2fa/disable", s.requireAuth(s.requireSessionOnly(s.limitLogins(s.handleTwoFactorDisable))))
+	s.router.HandleFunc("POST /api/auth/2fa/recovery-codes", s.requireAuth(s.requireSessionOnly(s.limitLogins(s.handleRegenerateRecoveryCodes))))
+
+	// Deploy freeze windows (admins manage them, everyone sees them)
+	s.router.HandleFunc("GET /api/freeze-windows", s.requireAuth(s.handleListFreezeWindows))
+	s.router.HandleFunc("POST /api/freeze-windows", s.requireAdmin(s.handleCreateFreezeWindow))
+	s.router.HandleFunc("DELETE /api/freeze-windows/{id}", s.requireAdmin(s.handleDeleteFreezeWindow))
+
+	// Build queue (auth required, cancelling needs write access)
+	s.router.HandleFunc("GET /api/builds", s.requireAuth(s.handleListBuilds))
+	s.router.HandleFunc("DELETE /api/builds/{id}", s.requireAuth(s.requireWriteAccess(s.handleCancelBuild)))
 
 	// User management (admin only)
 	s.router.HandleFunc("GET /api/users", s.requireAdmin(s.handleListUsers))
+	s.router.HandleFunc("POST /api/users", s.requireAdmin(s.handleCreateUser))
+	s.router.HandleFunc("GET /api/users/{id}", s.requireAdmin(s.handleGetUser))
 	s.router.HandleFunc("POST /api/users/invite", s.requireAdmin(s.handleInviteUser))
 	s.router.HandleFunc("PUT /api/users/{id}/role", s.requireAdmin(s.handleUpdateUserRole))
 	s.router.HandleFunc("DELETE /api/users/{id}", s.requireAdmin(s.handleDeleteUser))
-	s.router.HandleFunc("POST /api/auth/accept-invite", s.handleAcceptInvite)
+	s.router.HandleFunc("DELETE /api/users/{id}/2fa", s.requireAdmin(s.handleResetUserTwoFactor))
+	s.router.HandleFunc("POST /api/auth/accept-invite", s.limitLogins(s.handleAcceptInvite))
 
 	// User app access (admin only)
 	s.router.HandleFunc("GET /api/users/{id}/apps", s.requireAdmin(s.handleGetUserApps))
@@ -192,6 +290,7 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("GET /api/apps", s.requireAuth(s.handleListApps))
 	s.router.HandleFunc("POST /api/apps", s.requireAuth(s.requireSessionWriteAccess(s.handleCreateApp)))
 	s.router.HandleFunc("GET /api/apps/{id}", s.requireAuth(s.requireAppAccess(s.handleGetApp)))
+	s.router.HandleFunc("GET /api/events/stream", s.handleEventStream) // Checks auth itself: accepts reconnect tokens
 	s.router.HandleFunc("PUT /api/apps/{id}", s.requireAuth(s.requireAppAccess(s.handleUpdateApp)))
 	s.router.HandleFunc("DELETE /api/apps/{id}", s.requireAuth(s.requireAppAccess(s.handleDeleteApp)))
 
@@ -200,28 +299,99 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("POST /api/apps/{id}/stop", s.requireAuth(s.requireAppAccess(s.handleStopApp)))
 	s.router.HandleFunc("POST /api/apps/{id}/restart", s.requireAuth(s.requireAppAccess(s.handleRestartApp)))
 	s.router.HandleFunc("POST /api/apps/{id}/deploy", s.requireAuth(s.requireAppAccess(s.handleDeployApp)))
+	s.router.HandleFunc("POST /api/apps/{id}/clone", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleCloneApp))))
+	s.router.HandleFunc("POST /api/apps/{id}/canary", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleStartCanary))))
+	s.router.HandleFunc("PUT /api/apps/{id}/canary", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleSetCanaryWeight))))
+	s.router.HandleFunc("POST /api/apps/{id}/canary/promote", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handlePromoteCanary))))
+	s.router.HandleFunc("POST /api/apps/{id}/canary/abort", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleAbortCanary))))
+	s.router.HandleFunc("GET /api/apps/{id}/env", s.requireAuth(s.requireAppAccess(s.handleGetAppEnv)))
+	s.router.HandleFunc("PATCH /api/apps/{id}/env", s.requireAuth(s.requireAppAccess(s.handleUpdateAppEnv)))
+	s.router.HandleFunc("GET /api/apps/{id}/secrets", s.requireAuth(s.requireAppAccess(s.handleListSecrets)))
+	s.router.HandleFunc("PUT /api/apps/{id}/secrets/{key}", s.requireAuth(s.requireAppAccess(s.handleSetSecret)))
+	s.router.HandleFunc("DELETE /api/apps/{id}/secrets/{key}", s.requireAuth(s.requireAppAccess(s.handleDeleteSecret)))
 	s.router.HandleFunc("GET /api/apps/{id}/logs", s.requireAuth(s.requireAppAccess(s.handleGetAppLogs)))
 	s.router.HandleFunc("GET /api/apps/{id}/terminal", s.requireAuth(s.requireAppAccess(s.handleTerminal)))
+	s.router.HandleFunc("GET /api/apps/{id}/files", s.requireAuth(s.requireAppAccess(s.handleDownloadAppFiles)))
+	s.router.HandleFunc("PUT /api/apps/{id}/files", s.requireAuth(s.requireAppAccess(s.handleUploadAppFiles)))
 
 	// App health checks (auth required, per-app access)
+	s.router.HandleFunc("GET /api/apps/{id}/status", s.requireAuth(s.requireAppAccess(s.handleGetAppStatus)))
 	s.router.HandleFunc("GET /api/apps/{id}/health", s.requireAuth(s.requireAppAccess(s.handleGetAppHealth)))
 	s.router.HandleFunc("POST /api/apps/{id}/health/check", s.requireAuth(s.requireAppAccess(s.handleTriggerHealthCheck)))
+	s.router.HandleFunc("GET /api/apps/{id}/tls", s.requireAuth(s.requireAppAccess(s.handleGetTLSScan)))
+	s.router.HandleFunc("POST /api/apps/{id}/tls/scan", s.requireAuth(s.requireAppAccess(s.handleTLSScan)))
+	s.router.HandleFunc("GET /api/apps/{id}/uptime", s.requireAuth(s.requireAppAccess(s.handleGetUptime)))
+	s.router.HandleFunc("POST /api/apps/{id}/uptime/check", s.requireAuth(s.requireAppAccess(s.handleCheckUptime)))
+	s.router.HandleFunc("GET /api/apps/{id}/docs", s.requireAuth(s.requireAppAccess(s.handleGetAppDocs)))
+	s.router.HandleFunc("POST /api/apps/{id}/tasks", s.requireAuth(s.requireAppAccess(s.handleRunTask)))
+	s.router.HandleFunc("GET /api/apps/{id}/tasks", s.requireAuth(s.requireAppAccess(s.handleListTaskRuns)))
+	s.router.HandleFunc("GET /api/apps/{id}/security-headers", s.requireAuth(s.requireAppAccess(s.handleGetSecurityHeaders)))
+	s.router.HandleFunc("GET /api/security-headers", s.requireAuth(s.handleSecurityHeadersReport))
+	s.router.HandleFunc("GET /api/apps/{id}/routing", s.requireAuth(s.requireAppAccess(s.handleGetRouting)))
+	s.router.HandleFunc("PUT /api/apps/{id}/routing", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleUpdateRouting))))
+	s.router.HandleFunc("GET /api/apps/{id}/static", s.requireAuth(s.requireAppAccess(s.handleGetStatic)))
+	s.router.HandleFunc("PUT /api/apps/{id}/static", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleUpdateStatic))))
+	s.router.HandleFunc("POST /api/apps/{id}/purge", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handlePurgeApp))))
+	s.router.HandleFunc("GET /api/apps/{id}/protect", s.requireAuth(s.requireAppAccess(s.handleGetProtection)))
+	s.router.HandleFunc("PUT /api/apps/{id}/protect", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleProtectApp))))
+	s.router.HandleFunc("DELETE /api/apps/{id}/protect", s.requireAuth(s.requireSessionWriteAccess(s.requireAppAccess(s.handleUnprotectApp))))
+	s.router.HandleFunc("GET /api/addons", s.requireAuth(s.handleListAddons))
+	s.router.HandleFunc("POST /api/addons", s.requireAuth(s.requireSessionWriteAccess(s.handleCreateAddon)))
+	s.router.HandleFunc("GET /api/addons/{id}", s.requireAuth(s.requireAppAccess(s.handleGetAddon)))
+	s.router.HandleFunc("DELETE /api/addons/{id}", s.requireAuth(s.requireAppAccess(s.handleDestroyAddon)))
+	s.router.HandleFunc("POST /api/addons/{id}/attach", s.requireAuth(s.requireAppAccess(s.handleAttachAddon)))
+	s.router.HandleFunc("DELETE /api/addons/{id}/attach/{app}", s.requireAuth(s.requireAppAccess(s.handleDetachAddon)))
 
 	// System (auth required, session-only for mutating, admin-only for dangerous ops)
 	s.router.HandleFunc("GET /api/system/info", s.requireAuth(s.handleSystemInfo))
-	s.router.HandleFunc("GET /api/system/processes", s.requireAuth(s.handleSystemProcesses))
+	s.router.HandleFunc("GET /api/system/processes", s.requireAuth(s.requireUnscoped(s.handleSystemProcesses)))
 	s.router.HandleFunc("GET /api/system/config", s.handleGetConfig) // No auth - needed for login page
 	s.router.HandleFunc("PUT /api/system/config", s.requireAdmin(s.handleUpdateConfig))
+	s.router.HandleFunc("GET /api/ui-config", s.handleGetUIConfig) // No auth - read by the web UI at boot
+	s.router.HandleFunc("PUT /api/ui-config", s.requireAdmin(s.handleUpdateUIConfig))
+	s.router.HandleFunc("GET /api/build-presets", s.requireAuth(s.handleListBuildPresets))
+	s.router.HandleFunc("PUT /api/build-presets", s.requireAdmin(s.handleUpdateBuildPresets))
+	s.router.HandleFunc("GET /api/build-presets/{name}/dockerfile", s.requireAuth(s.handleRenderPresetDockerfile))
+	s.router.HandleFunc("POST /api/compose/convert", s.requireAuth(s.handleConvertCompose))
+
+	// DNS records created with the configured provider
+	s.router.HandleFunc("GET /api/dns/records", s.requireAdmin(s.handleListDNSRecords))
+	s.router.HandleFunc("POST /api/dns/sync", s.requireAdmin(s.handleSyncDNS))
+	s.router.HandleFunc("GET /api/system/branding", s.requireAuth(s.handleGetBranding))
+	s.router.HandleFunc("PUT /api/system/branding", s.requireAdmin(s.handleUpdateBranding))
+	s.router.HandleFunc("GET /api/system/branding/logo", s.handleGetLogo) // No auth - shown on the login page
+	s.router.HandleFunc("POST /api/system/branding/logo", s.requireAdmin(s.handleUploadLogo))
+	s.router.HandleFunc("DELETE /api/system/branding/logo", s.requireAdmin(s.handleDeleteLogo))
+
+	// Weekly digest
+	s.router.HandleFunc("GET /api/digest", s.requireAuth(s.requireSessionOnly(s.handleGetDigest)))
+	s.router.HandleFunc("PUT /api/digest", s.requireAuth(s.requireSessionOnly(s.handleUpdateDigest)))
+	s.router.HandleFunc("GET /api/digest/preview", s.requireAdmin(s.handlePreviewDigest))
+	s.router.HandleFunc("POST /api/digest/send", s.requireAdmin(s.handleSendDigest))
+	s.router.HandleFunc("GET /api/digest/unsubscribe", s.handleDigestUnsubscribe) // No auth - link in digest emails
 	s.router.HandleFunc("GET /api/system/version", s.requireAuth(s.handleGetVersion))
 	s.router.HandleFunc("POST /api/system/update", s.requireAdmin(s.handleSystemUpdate))
 	s.router.HandleFunc("POST /api/system/prune", s.requireAdmin(s.handleSystemPrune))
-	s.router.HandleFunc("GET /api/system/storage", s.requireAuth(s.handleSystemStorage))
-	s.router.HandleFunc("GET /api/system/volumes", s.requireAuth(s.handleListVolumes))
+	s.router.HandleFunc("GET /api/registry", s.requireAdmin(s.handleGetRegistry))
+	s.router.HandleFunc("POST /api/registry/gc", s.requireAdmin(s.handleRegistryGC))
+	s.router.HandleFunc("DELETE /api/registry/{app}/tags/{tag}", s.requireAdmin(s.handleDeleteRegistryTag))
+	s.router.HandleFunc("GET /api/system/maintenance", s.requireAdmin(s.handleGetMaintenance))
+	s.router.HandleFunc("GET /api/system/self", s.requireAdmin(s.handleGetSelf))
+	s.router.HandleFunc("POST /api/system/maintenance/run", s.requireAdmin(s.handleRunMaintenance))
+	s.router.HandleFunc("POST /api/system/selftest", s.requireAdmin(s.handleSelftest))
+	s.router.HandleFunc("GET /api/system/storage", s.requireAuth(s.requireUnscoped(s.handleSystemStorage)))
+	s.router.HandleFunc("GET /api/system/volumes", s.requireAuth(s.requireUnscoped(s.handleListVolumes)))
+	s.router.HandleFunc("GET /api/volumes", s.requireAdmin(s.handleVolumes))
+	s.router.HandleFunc("POST /api/volumes", s.requireAdmin(s.handleCreateVolume))
+	s.router.HandleFunc("GET /api/volumes/{name}", s.requireAdmin(s.handleInspectVolume))
+	s.router.HandleFunc("DELETE /api/volumes/{name}", s.requireAdmin(s.handleDeleteVolume))
+	s.router.HandleFunc("GET /api/volumes/{name}/export", s.requireAdmin(s.handleExportVolume))
+	s.router.HandleFunc("PUT /api/volumes/{name}/import", s.requireAdmin(s.handleImportVolume))
 	s.router.HandleFunc("DELETE /api/system/storage/{id}", s.requireAdmin(s.handleDeleteStorageCategory))
-	s.router.HandleFunc("GET /api/system/storage/llm", s.requireAuth(s.handleListLLMStorage))
+	s.router.HandleFunc("GET /api/system/storage/llm", s.requireAuth(s.requireUnscoped(s.handleListLLMStorage)))
 	s.router.HandleFunc("DELETE /api/system/storage/llm/{name}", s.requireAdmin(s.handleDeleteLLMStorage))
 	s.router.HandleFunc("POST /api/system/restart/{service}", s.requireAdmin(s.handleServiceRestart))
-	s.router.HandleFunc("GET /api/containers", s.requireAuth(s.handleListContainers))
+	s.router.HandleFunc("GET /api/containers", s.requireAuth(s.requireUnscoped(s.handleListContainers)))
 	s.router.HandleFunc("POST /api/containers/{id}/import", s.requireAdmin(s.handleImportContainer))
 
 	// Landing page
@@ -231,6 +401,8 @@ func (s *Server) setupRoutes() {
 	// Templates (auth required)
 	s.router.HandleFunc("GET /api/templates", s.requireAuth(s.handleListTemplates))
 	s.router.HandleFunc("POST /api/templates/{id}/deploy", s.requireAuth(s.requireSessionWriteAccess(s.handleDeployTemplate)))
+	s.router.HandleFunc("POST /api/templates/catalog/sync", s.requireAdmin(s.handleSyncTemplateCatalog))
+	s.router.HandleFunc("POST /api/apps/{id}/template/upgrade", s.requireAuth(s.requireAppAccess(s.handleTemplateUpgrade)))
 
 	// MLX LLM service (auth required, session-only for mutating)
 	s.router.HandleFunc("GET /api/mlx/status", s.requireAuth(s.handleMLXStatus))
@@ -244,26 +416,40 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("POST /api/mlx/synthesize", s.requireAuth(s.requireSessionWriteAccess(s.handleMLXSynthesize)))
 	s.router.HandleFunc("DELETE /api/mlx/models/{id}", s.requireAdmin(s.handleMLXDeleteModel))
 
-	// Chat messages (auth required)
+	// Chat completions relay and messages (auth required)
+	s.router.HandleFunc("POST /api/chat/completions", s.requireAuth(s.requireWriteAccess(s.handleChatCompletions)))
+	s.router.HandleFunc("POST /api/chat/cancel", s.requireAuth(s.requireWriteAccess(s.handleChatCancel)))
 	s.router.HandleFunc("GET /api/chat/messages/{modelId}", s.requireAuth(s.handleGetChatMessages))
 	s.router.HandleFunc("POST /api/chat/messages/{modelId}", s.requireAuth(s.handleSaveChatMessage))
 	s.router.HandleFunc("DELETE /api/chat/messages/{modelId}", s.requireAuth(s.handleClearChatMessages))
 
 	// Image tags (auth required)
 	s.router.HandleFunc("GET /api/images/tags", s.requireAuth(s.handleImageTags))
+	s.router.HandleFunc("GET /api/images/export", s.requireAdmin(s.handleExportImage))
+	s.router.HandleFunc("POST /api/images/import", s.requireAdmin(s.handleImportImage))
 
 	// Container images management (auth required)
-	s.router.HandleFunc("GET /api/container-images", s.requireAuth(s.handleListContainerImages))
+	s.router.HandleFunc("GET /api/container-images", s.requireAuth(s.requireUnscoped(s.handleListContainerImages)))
 	s.router.HandleFunc("DELETE /api/container-images/{id}", s.requireAdmin(s.handleDeleteContainerImage))
 
 	// Access logs (auth required, per-app access)
 	s.router.HandleFunc("GET /api/apps/{id}/access-logs", s.requireAuth(s.requireAppAccess(s.handleAppAccessLogs)))
+	s.router.HandleFunc("GET /api/apps/{id}/access-logs/export", s.requireAuth(s.requireAppAccess(s.handleExportAccessLogs)))
+	s.router.HandleFunc("GET /api/apps/{id}/traffic", s.requireAuth(s.requireAppAccess(s.handleAppTraffic)))
 
 	// Caddy on-demand TLS check (no auth - called by Caddy)
 	s.router.HandleFunc("GET /api/caddy/check", s.handleCaddyCheck)
 
-	// Webhook endpoint - NO auth (GitHub calls this, validated via HMAC)
+	// Webhook endpoints - NO auth (GitHub and GitLab call these, validated with
+	// the app's webhook secret)
 	s.router.HandleFunc("POST /api/apps/{id}/webhook", s.handleWebhook)
+	s.router.HandleFunc("POST /api/hooks/github", s.handleGitHubHook)
+	s.router.HandleFunc("POST /api/hooks/gitlab", s.handleGitLabHook)
+
+	// Git push-to-deploy - authenticated with basic auth inside the handler,
+	// since git clients don't send bearer tokens
+	s.router.HandleFunc("GET /git/{repo}/{rest...}", s.handleGitHTTP)
+	s.router.HandleFunc("POST /git/{repo}/{rest...}", s.handleGitHTTP)
 
 	// Webhook management (auth required, per-app access)
 	s.router.HandleFunc("POST /api/apps/{id}/webhook/setup", s.requireAuth(s.requireAppAccess(s.handleWebhookSetup)))
@@ -272,14 +458,34 @@ func (s *Server) setupRoutes() {
 	// Rollback and deployment logs (auth required, per-app access)
 	s.router.HandleFunc("POST /api/apps/{id}/rollback", s.requireAuth(s.requireAppAccess(s.handleRollback)))
 	s.router.HandleFunc("GET /api/apps/{id}/deployments/{deployId}/logs", s.requireAuth(s.requireAppAccess(s.handleDeploymentLogs)))
+	s.router.HandleFunc("GET /api/apps/{id}/snapshots", s.requireAuth(s.requireAppAccess(s.handleListSnapshots)))
+	s.router.HandleFunc("POST /api/apps/{id}/snapshots", s.requireAuth(s.requireAppAccess(s.handleCreateSnapshot)))
+	s.router.HandleFunc("PUT /api/apps/{id}/snapshots/config", s.requireAuth(s.requireAppAccess(s.handleUpdateSnapshotConfig)))
+	s.router.HandleFunc("POST /api/apps/{id}/snapshots/{snapshotId}/restore", s.requireAuth(s.requireAppAccess(s.handleRestoreSnapshot)))
+	s.router.HandleFunc("GET /api/apps/{id}/snapshots/{snapshotId}/dump", s.requireAuth(s.requireAppAccess(s.handleSnapshotDump)))
+	s.router.HandleFunc("GET /api/apps/{id}/provenance", s.requireAuth(s.requireAppAccess(s.handleGetProvenance)))
+	s.router.HandleFunc("GET /api/apps/{id}/seo", s.requireAuth(s.requireAppAccess(s.handleGetSEO)))
+	s.router.HandleFunc("POST /api/apps/{id}/upstream/test", s.requireAuth(s.requireAppAccess(s.handleTestUpstream)))
 
 	// Cron jobs (auth required, per-app access)
+	s.router.HandleFunc("GET /api/apps/{id}/domains", s.requireAuth(s.requireAppAccess(s.handleListAppDomains)))
+	s.router.HandleFunc("POST /api/apps/{id}/domains", s.requireAuth(s.requireAppAccess(s.handleAddAppDomain)))
+	s.router.HandleFunc("DELETE /api/apps/{id}/domains/{domain}", s.requireAuth(s.requireAppAccess(s.handleRemoveAppDomain)))
+	s.router.HandleFunc("POST /api/apps/{id}/domains/migrate", s.requireAuth(s.requireAppAccess(s.handleMigrateDomain)))
+	s.router.HandleFunc("GET /api/apps/{id}/domains/redirects", s.requireAuth(s.requireAppAccess(s.handleListDomainRedirects)))
+	s.router.HandleFunc("DELETE /api/apps/{id}/domains/redirects/{redirectId}", s.requireAuth(s.requireAppAccess(s.handleDeleteDomainRedirect)))
+
+	// Path and header routing of one domain to several apps
+	s.router.HandleFunc("GET /api/domains/{domain}/routes", s.requireAdmin(s.handleListDomainRoutes))
+	s.router.HandleFunc("POST /api/domains/{domain}/routes", s.requireAdmin(s.handleCreateDomainRoute))
+	s.router.HandleFunc("DELETE /api/domains/{domain}/routes/{routeId}", s.requireAdmin(s.handleDeleteDomainRoute))
 	s.router.HandleFunc("GET /api/apps/{id}/cron", s.requireAuth(s.requireAppAccess(s.handleListCronJobs)))
 	s.router.HandleFunc("POST /api/apps/{id}/cron", s.requireAuth(s.requireAppAccess(s.handleCreateCronJob)))
 	s.router.HandleFunc("PUT /api/apps/{id}/cron/{jobId}", s.requireAuth(s.requireAppAccess(s.handleUpdateCronJob)))
 	s.router.HandleFunc("DELETE /api/apps/{id}/cron/{jobId}", s.requireAuth(s.requireAppAccess(s.handleDeleteCronJob)))
 	s.router.HandleFunc("POST /api/apps/{id}/cron/{jobId}/run", s.requireAuth(s.requireAppAccess(s.handleRunCronJob)))
 	s.router.HandleFunc("GET /api/apps/{id}/cron/{jobId}/executions", s.requireAuth(s.requireAppAccess(s.handleListCronExecutions)))
+	s.router.HandleFunc("GET /api/jobs", s.requireAuth(s.handleListJobs))
 
 	// Activity log (auth required, per-app access)
 	s.router.HandleFunc("GET /api/activity", s.requireAuth(s.handleListActivity))
@@ -293,12 +499,25 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("POST /api/notifications/{id}/test", s.requireAdmin(s.handleTestNotification))
 
 	// Deploy tokens (admin only)
-	s.router.HandleFunc("GET /api/deploy-tokens", s.requireAdmin(s.handleListDeployTokens))
-	s.router.HandleFunc("POST /api/deploy-tokens", s.requireAdmin(s.handleCreateDeployToken))
-	s.router.HandleFunc("DELETE /api/deploy-tokens/{id}", s.requireAdmin(s.handleDeleteDeployToken))
+	s.router.HandleFunc("GET /api/deploy-tokens", s.requireAuth(s.requireSessionWriteAccess(s.handleListDeployTokens)))
+	s.router.HandleFunc("POST /api/deploy-tokens", s.requireAuth(s.requireSessionWriteAccess(s.handleCreateDeployToken)))
+	s.router.HandleFunc("DELETE /api/deploy-tokens/{id}", s.requireAuth(s.requireSessionWriteAccess(s.handleDeleteDeployToken)))
 
 	// App metrics (auth required, per-app access)
 	s.router.HandleFunc("GET /api/apps/{id}/metrics", s.requireAuth(s.requireAppAccess(s.handleAppMetrics)))
+	s.router.HandleFunc("GET /api/apps/{id}/metrics/series", s.requireAuth(s.requireAppAccess(s.handleAppMetricSeries)))
+	s.router.HandleFunc("GET /api/metrics/series", s.requireAuth(s.handleMetricSeries))
+	s.router.HandleFunc("GET /api/metrics/live", s.requireAuth(s.handleLiveMetrics))
+	s.router.HandleFunc("GET /api/apps/{id}/markers", s.requireAuth(s.requireAppAccess(s.handleDeployMarkers)))
+
+	// Prometheus scrape endpoint (auth required, deploy tokens need metrics:read)
+	s.router.HandleFunc("GET /metrics", s.requireAuth(s.handlePrometheusMetrics))
+
+	// Deploy markers as Grafana annotations for Prometheus dashboards (auth required)
+	s.router.HandleFunc("GET /api/annotations", s.requireAuth(s.handleAnnotations))
+
+	// Per-app volume usage (auth required, per-app access)
+	s.router.HandleFunc("GET /api/apps/{id}/volumes", s.requireAuth(s.requireAppAccess(s.handleAppVolumes)))
 
 	// Database provisioning (auth required, per-app access)
 	s.router.HandleFunc("POST /api/apps/{id}/link/{dbId}", s.requireAuth(s.requireAppAccess(s.handleLinkDatabase)))
@@ -310,11 +529,29 @@ func (s *Server) setupRoutes() {
 	// AI Assistant (auth required)
 	s.router.HandleFunc("POST /api/ai/ask", s.requireAuth(s.handleAIAsk))
 
+	// Embeddings and semantic search over indexed logs and notes (auth required)
+	s.router.HandleFunc("POST /api/ai/embeddings", s.requireAuth(s.handleAIEmbeddings))
+	s.router.HandleFunc("POST /api/ai/search", s.requireAuth(s.handleAISearch))
+	s.router.HandleFunc("POST /api/ai/documents", s.requireAuth(s.requireSessionWriteAccess(s.handleAIAddDocument)))
+	s.router.HandleFunc("DELETE /api/ai/documents/{id}", s.requireAuth(s.requireSessionWriteAccess(s.handleAIDeleteDocument)))
+
+	// AI job queue (auth required, admin-only reordering)
+	s.router.HandleFunc("GET /api/ai/queue", s.requireAuth(s.requireUnscoped(s.handleAIQueue)))
+	s.router.HandleFunc("POST /api/ai/queue/{id}/move", s.requireAdmin(s.handleAIQueueMove))
+
+	// OpenAI-compatible speech-to-text (auth required, deploy tokens need audio:transcribe)
+	s.router.HandleFunc("POST /v1/audio/transcriptions", s.requireAuth(s.handleAudioTranscriptions))
+
+	// MCP server for external agents (auth required, deploy tokens need an mcp:* scope)
+	s.router.HandleFunc("POST /api/mcp", s.requireAuth(s.handleMCP))
+
 	// Status badge (no auth)
 	s.router.HandleFunc("GET /api/badge/{id}", s.handleStatusBadge)
 
 	// Source deploy endpoint (auth required)
 	s.router.HandleFunc("POST /api/deploy", s.requireAuth(s.requireWriteAccess(s.handleSourceDeploy)))
+	s.router.HandleFunc("POST /api/deploy/blobs/check", s.requireAuth(s.requireWriteAccess(s.handleCheckBlobs)))
+	s.router.HandleFunc("POST /api/deploy/blobs", s.requireAuth(s.requireWriteAccess(s.handleUploadBlobs)))
 
 	// Construct OAuth deploy endpoints (for Construct app users)
 	s.router.HandleFunc("POST /api/construct/deploy", s.requireConstructAuth(s.handleSourceDeploy))
@@ -327,6 +564,13 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("GET /api/backups/{id}/download", s.requireAdmin(s.handleDownloadBackup))
 	s.router.HandleFunc("POST /api/backups/{id}/restore", s.requireAdmin(s.handleRestoreBackup))
 	s.router.HandleFunc("DELETE /api/backups/{id}", s.requireAdmin(s.handleDeleteBackup))
+	s.router.HandleFunc("GET /api/backups/targets", s.requireAdmin(s.handleBackupTargets))
+	s.router.HandleFunc("GET /api/backups/remote", s.requireAdmin(s.handleListRemoteBackups))
+	s.router.HandleFunc("POST /api/backups/upload", s.requireAdmin(s.handleImportBackup))
+	s.router.HandleFunc("POST /api/backups/{id}/upload", s.requireAdmin(s.handleUploadBackup))
+
+	// Profiling endpoints (admin only, off unless debug.enabled is set)
+	s.setupDebugRoutes()
 }
 
 // deployTokenKey is the context key for deploy token info
@@ -346,8 +590,22 @@ func getDeployTokenFromCtx(r *http.Request) *app.DeployToken {
 	return nil
 }
 
-func deployTokenAllowsRequest(r *http.Request) bool {
-	return r.Method == http.MethodPost && r.URL.Path == "/api/deploy"
+func deployTokenAllowsRequest(r *http.Request, dt *app.DeployToken) bool {
+	if r.Method == http.MethodGet && r.URL.Path == "/metrics" {
+		return deployTokenHasScope(dt, "metrics:read")
+	}
+	if r.Method != http.MethodPost {
+		return false
+	}
+	switch r.URL.Path {
+	case "/api/deploy":
+		return true
+	case "/api/mcp":
+		return deployTokenHasScope(dt, "mcp:read") || deployTokenHasScope(dt, "mcp:write")
+	case "/v1/audio/transcriptions":
+		return deployTokenHasScope(dt, "audio:transcribe")
+	}
+	return false
 }
 
 func deployTokenHasScope(dt *app.DeployToken, want string) bool {
@@ -450,6 +708,7 @@ func (s *Server) requireAuth(handler http.HandlerFunc) http.HandlerFunc {
 
 		// Try session auth first
 		if s.auth.ValidateSession(token) {
+			s.auth.Touch(token, requestIP(r), r.UserAgent())
 			handler(w, r)
 			return
 		}
@@ -465,8 +724,8 @@ func (s *Server) requireAuth(handler http.HandlerFunc) http.HandlerFunc {
 					errorResponse(w, http.StatusUnauthorized, "Deploy token expired")
 					return
 				}
-				if !deployTokenAllowsRequest(r) {
-					errorResponse(w, http.StatusForbidden, "Deploy tokens can only access the source deploy endpoint")
+				if !deployTokenAllowsRequest(r, dt) {
+					errorResponse(w, http.StatusForbidden, "Deploy tokens can only access the source deploy, MCP, transcription and metrics endpoints")
 					return
 				}
 				// Update last used
@@ -663,29 +922,20 @@ func (s *Server) requireAppAccess(handler http.HandlerFunc) http.HandlerFunc {
 		}
 
 		// Viewer: read-only access (GET/HEAD only)
-		if session.UserRole == "viewer" {
-			if r.Method != "GET" && r.Method != "HEAD" {
-				errorResponse(w, http.StatusForbidden, "Viewers have read-only access")
-				return
-			}
-			handler(w, r)
+		if session.UserRole == "viewer" && r.Method != "GET" && r.Method != "HEAD" {
+			errorResponse(w, http.StatusForbidden, "Viewers have read-only access")
 			return
 		}
 
-		// Deployer: check per-app access
-		if session.UserRole == "deployer" {
-			appID := r.PathValue("id")
-			if appID == "" {
-				handler(w, r)
-				return
-			}
-
-			hasAccess, err := s.storage.UserHasAppAccess(session.UserID, appID)
-			if err != nil {
-				errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
-				return
-			}
-			if !hasAccess {
+		// Deployers, and viewers given specific apps, only reach those apps
+		sc, err := s.userScope(session.UserID, session.UserRole)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+			return
+		}
+		if id := r.PathValue("id"); sc != nil && id != "" {
+			a, _ := s.resolveApp(id)
+			if a == nil || !sc.allows(a.ID) {
 				errorResponse(w, http.StatusForbidden, "You don't have access to this app")
 				return
 			}
@@ -706,107 +956,47 @@ func maskToken(token string) string {
 	return "****"
 }
 
-// sendInviteEmail sends an invitation email via configured provider (Postmark or Resend)
+// sendInviteEmail sends an invitation email via the configured provider
 func (s *Server) sendInviteEmail(toEmail, inviteURL string) {
 	cfg := s.config.Email
 	if cfg.Provider == "" {
 		return // No email provider configured, skip silently
 	}
 
-	fromAddr := cfg.FromAddress
-	if fromAddr == "" {
-		fromAddr = "noreply@basepod.app"
+	brand := s.branding()
+	product := html.EscapeString(brand.Name)
+	logo, support, supportText := "", "", ""
+	if u, err := url.Parse(inviteURL); err == nil && brand.LogoURL != "" && u.Host != "" {
+		logo = fmt.Sprintf(`<p><img src="%s://%s%s" alt="%s" style="max-height:48px;"></p>`, u.Scheme, u.Host, brand.LogoURL, product)
+	}
+	if brand.SupportURL != "" {
+		support = fmt.Sprintf(`<p>Need help? <a href="%s" style="color:%s;">Contact support</a></p>`, html.EscapeString(brand.SupportURL), brand.AccentColor)
+		supportText = "\n\nNeed help? " + brand.SupportURL
 	}
 
-	subject := "You've been invited to Basepod"
+	subject := "You've been invited to " + brand.Name
 	htmlBody := fmt.Sprintf(`<html><body>
-<h2>You've been invited to Basepod</h2>
-<p>You've been invited to join a Basepod instance. Click the link below to set your password and get started:</p>
-<p><a href="%s" style="display:inline-block;padding:12px 24px;background-color:#3b82f6;color:#ffffff;text-decoration:none;border-radius:6px;font-weight:bold;">Accept Invitation</a></p>
+%s<h2>You've been invited to %s</h2>
+<p>You've been invited to join a %s instance. Click the link below to set your password and get started:</p>
+<p><a href="%s" style="display:inline-block;padding:12px 24px;background-color:%s;color:#ffffff;text-decoration:none;border-radius:6px;font-weight:bold;">Accept Invitation</a></p>
 <p>Or copy this URL: %s</p>
 <p>This invitation link is single-use.</p>
-</body></html>`, inviteURL, inviteURL)
-	textBody := fmt.Sprintf("You've been invited to Basepod.\n\nAccept your invitation: %s\n\nThis invitation link is single-use.", inviteURL)
-
-	var reqBody []byte
-	var apiURL string
-	var headers map[string]string
-
-	switch cfg.Provider {
-	case "postmark":
-		if cfg.PostmarkToken == "" {
-			log.Printf("Email: Postmark token not configured, skipping invite email")
-			return
-		}
-		apiURL = "https://api.postmarkapp.com/email"
-		headers = map[string]string{
-			"X-Postmark-Server-Token": cfg.PostmarkToken,
-			"Content-Type":            "application/json",
-			"Accept":                  "application/json",
-		}
-		payload := map[string]string{
-			"From":     fromAddr,
-			"To":       toEmail,
-			"Subject":  subject,
-			"HtmlBody": htmlBody,
-			"TextBody": textBody,
-		}
-		reqBody, _ = json.Marshal(payload)
-
-	case "resend":
-		if cfg.ResendKey == "" {
-			log.Printf("Email: Resend API key not configured, skipping invite email")
-			return
-		}
-		apiURL = "https://api.resend.com/emails"
-		headers = map[string]string{
-			"Authorization": "Bearer " + cfg.ResendKey,
-			"Content-Type":  "application/json",
-		}
-		payload := map[string]interface{}{
-			"from":    fromAddr,
-			"to":      []string{toEmail},
-			"subject": subject,
-			"html":    htmlBody,
-			"text":    textBody,
-		}
-		reqBody, _ = json.Marshal(payload)
-
-	default:
-		log.Printf("Email: Unknown provider %q, skipping invite email", cfg.Provider)
-		return
-	}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	httpReq, err := http.NewRequest("POST", apiURL, strings.NewReader(string(reqBody)))
-	if err != nil {
-		log.Printf("Email: Failed to create request: %v", err)
-		return
-	}
-	for k, v := range headers {
-		httpReq.Header.Set(k, v)
-	}
+%s</body></html>`, logo, product, product, inviteURL, brand.AccentColor, inviteURL, support)
+	textBody := fmt.Sprintf("You've been invited to %s.\n\nAccept your invitation: %s\n\nThis invitation link is single-use.%s", brand.Name, inviteURL, supportText)
 
-	resp, err := client.Do(httpReq)
-	if err != nil {
+	if err := s.sendEmail(toEmail, subject, htmlBody, textBody); err != nil {
 		log.Printf("Email: Failed to send invite email to %s via %s: %v", toEmail, cfg.Provider, err)
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("Email: Invite email sent to %s via %s", toEmail, cfg.Provider)
-	} else {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Email: Failed to send invite email to %s via %s (HTTP %d): %s", toEmail, cfg.Provider, resp.StatusCode, string(body))
-	}
+	log.Printf("Email: Invite email sent to %s via %s", toEmail, cfg.Provider)
 }
 
 // handleLogin handles password authentication (supports legacy admin + multi-user)
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Password string `json:"password"`
-		Email    string `json:"email,omitempty"` // optional: for multi-user login
+		Email    string `json:"email,omitempty"`     // optional: for multi-user login
+		TOTPCode string `json:"totp_code,omitempty"` // 2FA code or recovery code, when 2FA is on
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request")
@@ -827,6 +1017,9 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			errorResponse(w, http.StatusUnauthorized, "Invalid email or password")
 			return
 		}
+		if !s.requireTwoFactorAtLogin(w, user.ID, req.TOTPCode) {
+			return
+		}
 		session, err = s.auth.CreateUserSession(user.ID, user.Email, user.Role)
 		if err == nil {
 			s.storage.UpdateUserLogin(user.ID)
@@ -837,6 +1030,9 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			errorResponse(w, http.StatusUnauthorized, "Invalid password")
 			return
 		}
+		if !s.requireTwoFactorAtLogin(w, adminAccountID, req.TOTPCode) {
+			return
+		}
 		session, err = s.auth.CreateSession()
 	}
 
@@ -844,6 +1040,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
+	s.auth.Touch(session.Token, requestIP(r), r.UserAgent())
 
 	// Set cookie
 	isSecure := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
@@ -973,6 +1170,7 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
+	s.auth.Touch(session.Token, requestIP(r), r.UserAgent())
 
 	// Set cookie
 	http.SetCookie(w, &http.Cookie{
@@ -1058,8 +1256,17 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	isDashboard := host == bpDomain || host == dashboardDomain
 	isRootDomain := host == rootDomain
 
+	// Domains an app migrated away from redirect to its new domain, paths included
+	if !isDashboard && !isRootDomain && host != "localhost" && host != "127.0.0.1" && s.serveDomainRedirect(w, r, host) {
+		return
+	}
+
 	// Serve API routes first (always accessible regardless of host)
-	if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/health" {
+	if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/v1/") || r.URL.Path == "/health" || s.isMetricsRequest(r, host) {
+		s.serveInstrumented(w, r)
+		return
+	}
+	if s.isDebugRequest(r, host) {
 		s.router.ServeHTTP(w, r)
 		return
 	}
@@ -1339,22 +1546,18 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 // handleListApps lists all apps (filtered by access for deployers)
 func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
-	var apps []app.App
-	var err error
-
-	// Check if deployer - filter apps by access
-	token := s.getSessionToken(r)
-	session := s.auth.GetSession(token)
-	if session != nil && session.UserRole == "deployer" && session.UserID != "" {
-		apps, err = s.storage.ListAppsForUser(session.UserID)
-	} else {
-		apps, err = s.storage.ListApps()
+	// Deployers and client logins only see their own apps
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
 	}
-
+	apps, err := s.storage.ListApps()
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	apps = sc.filterApps(apps)
 
 	if apps == nil {
 		apps = []app.App{}
@@ -1368,6 +1571,10 @@ func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	s.healthStatesMu.RUnlock()
+	s.injectUptime(apps)
+	for i := range apps {
+		apps[i] = *redactedApp(&apps[i])
+	}
 
 	jsonResponse(w, http.StatusOK, app.AppListResponse{
 		Apps:  apps,
@@ -1387,6 +1594,10 @@ func (s *Server) handleCreateApp(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusBadRequest, "Name is required")
 		return
 	}
+	if err := validateResources(req.Memory, req.CPUs); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Check if app already exists by name
 	existing, _ := s.storage.GetAppByName(req.Name)
@@ -1492,6 +1703,7 @@ func (s *Server) handleCreateApp(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.grantCreatorAccess(r, newApp.ID)
 
 	// Auto-deploy based on type
 	if appType == app.AppTypeMLX {
@@ -1540,6 +1752,15 @@ func (s *Server) handleGetApp(w http.ResponseWriter, r *http.Request) {
 		a.Health = hs
 	}
 	s.healthStatesMu.RUnlock()
+	a.TLSScan, _ = s.storage.GetTLSScan(a.ID)
+	if counts, err := s.storage.CountUptimeChecks(time.Now().Add(-uptimeSummaryPeriod)); err == nil {
+		if c, ok := counts[a.ID]; ok {
+			a.Uptime = s.uptimeSummary(a.ID, c)
+		}
+	}
+	a.Docs, _ = s.storage.GetAppDocs(a.ID)
+	a.TemplateUpdate = templateUpdateFor(a, s.templateCatalog())
+	a = redactedApp(a)
 
 	// Build response with computed fields
 	response := AppResponse{
@@ -1617,6 +1838,18 @@ func (s *Server) handleUpdateApp(w http.ResponseWriter, r *http.Request) {
 	if req.CPUs != nil {
 		a.Resources.CPUs = *req.CPUs
 	}
+	if err := validateResources(a.Resources.Memory, a.Resources.CPUs); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	oldReplicas := replicaCount(a)
+	if req.Replicas != nil {
+		if err := validateReplicas(a, *req.Replicas); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.Resources.Replicas = *req.Replicas
+	}
 	if req.EnableSSL != nil {
 		a.SSL.Enabled = *req.EnableSSL
 	}
@@ -1645,6 +1878,37 @@ func (s *Server) handleUpdateApp(w http.ResponseWriter, r *http.Request) {
 	if req.Deployment != nil {
 		a.Deployment = *req.Deployment
 	}
+	if req.SEO != nil {
+		if err := validateSEO(req.SEO); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.SEO = req.SEO
+	}
+	if req.Privacy != nil {
+		a.Privacy = req.Privacy
+	}
+	if req.Protocol != nil {
+		if err := validateProtocol(a, *req.Protocol); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.Ports.Protocol = *req.Protocol
+	}
+	if req.WebSocket != nil {
+		if err := validateWebSocket(a, req.WebSocket); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.WebSocket = req.WebSocket
+	}
+	if req.SecurityHeaders != nil {
+		if err := validateSecurityHeaders(req.SecurityHeaders); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.SecurityHeaders = req.SecurityHeaders
+	}
 
 	if req.RedirectURL != nil {
 		a.RedirectURL = *req.RedirectURL
@@ -1666,6 +1930,17 @@ func (s *Server) handleUpdateApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Start or remove replicas before the routes list them
+	replicasChanged := replicaCount(a) != oldReplicas
+	if replicasChanged && a.Status == app.StatusRunning {
+		details := fmt.Sprintf("%d → %d replicas", oldReplicas, replicaCount(a))
+		if err := s.syncReplicas(r.Context(), a, false); err != nil {
+			s.logActivity("user", "scale", "app", a.ID, a.Name, "failed", details+": "+err.Error())
+		} else {
+			s.logActivity("user", "scale", "app", a.ID, a.Name, "success", details)
+		}
+	}
+
 	// Update Caddy routes
 	if s.caddy != nil {
 		if a.RedirectURL != "" {
@@ -1709,18 +1984,37 @@ func (s *Server) handleUpdateApp(w http.ResponseWriter, r *http.Request) {
 					upstream = fmt.Sprintf("localhost:%d", assignHostPort(a.ID))
 				}
 				route := caddy.Route{
-					ID:       routeID,
-					Domain:   alias,
-					Upstream: upstream,
+					ID:        routeID,
+					Domain:    alias,
+					Upstream:  upstream,
+					Upstreams: appUpstreams(a),
+					Weights:   upstreamWeights(a),
+					SEO:       appRouteSEO(a, alias),
+					Headers:   a.ResponseHeaders(),
+					Snippets:  appRouteSnippets(a),
+					Protocol:  a.Ports.Protocol,
+					Streams:   appRouteStreams(a),
 				}
 				if err := s.caddy.AddRoute(route); err != nil {
 					log.Printf("Warning: failed to add alias route for %s: %v", alias, err)
 				}
 			}
 		}
+		if req.SEO != nil || req.Protocol != nil || req.WebSocket != nil || req.SecurityHeaders != nil || replicasChanged {
+			s.refreshAppRoutes(a)
+		}
+		if req.Protocol != nil || req.WebSocket != nil || req.SecurityHeaders != nil || replicasChanged {
+			s.applyDomainRoutesForApp(a)
+		}
+		// Hosts with masked client IPs are listed in Caddy's log config
+		if req.Privacy != nil || req.Domain != nil || req.Aliases != nil {
+			if err := s.configureAccessLogs(); err != nil {
+				log.Printf("Warning: failed to update access log settings: %v", err)
+			}
+		}
 	}
 
-	jsonResponse(w, http.StatusOK, a)
+	jsonResponse(w, http.StatusOK, redactedApp(a))
 }
 
 // handleDeleteApp deletes an app
@@ -1757,12 +2051,20 @@ func (s *Server) handleDeleteApp(w http.ResponseWriter, r *http.Request) {
 			_ = s.podman.StopContainer(ctx, a.ContainerID, 10)
 			_ = s.podman.RemoveContainer(ctx, a.ContainerID, true)
 		}
+		s.removeReplicas(ctx, a)
+		s.removeCanary(ctx, a)
+		if len(a.Services) > 0 {
+			s.removeServices(ctx, a)
+		}
 	}
 
 	// Remove Caddy routes
 	if s.caddy != nil {
 		// Container app route
 		_ = s.caddy.RemoveRoute("basepod-" + a.Name)
+		for _, svc := range a.Services {
+			_ = s.caddy.RemoveRoute(serviceRouteID(a, svc.Name))
+		}
 		// Static site routes
 		if a.Domain != "" {
 			_ = s.caddy.RemoveRoute("static-" + a.Domain)
@@ -1774,6 +2076,15 @@ func (s *Server) handleDeleteApp(w http.ResponseWriter, r *http.Request) {
 			_ = s.caddy.RemoveRoute("static-" + alias)
 		}
 	}
+	s.removeAppDomainRoutes(a)
+	if err := s.storage.DeleteSecretsForApp(a.ID); err != nil {
+		log.Printf("Warning: failed to delete secrets for %s: %v", a.Name, err)
+	}
+	_ = s.storage.DeleteTLSScan(a.ID)
+	_ = s.storage.DeleteTaskRuns(a.ID)
+	_ = s.storage.DeleteAppDocs(a.ID)
+	_ = s.storage.DeleteAddon(a.ID)
+	_ = s.storage.DeleteAttachmentsForApp(a.ID)
 
 	// Remove static site files from disk
 	if a.Type == app.AppTypeStatic {
@@ -1797,6 +2108,8 @@ func (s *Server) handleDeleteApp(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.forgetAppDNS(a.ID)
+	s.invalidateDomainRedirects() // Its redirects went with it
 
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -1830,7 +2143,7 @@ func (s *Server) handleStartApp(w http.ResponseWriter, r *http.Request) {
 			errorResponse(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		jsonResponse(w, http.StatusOK, a)
+		jsonResponse(w, http.StatusOK, redactedApp(a))
 		return
 	}
 
@@ -1839,7 +2152,12 @@ func (s *Server) handleStartApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.podman.StartContainer(ctx, a.ContainerID); err != nil {
+	if len(a.Services) > 0 {
+		err = s.startServices(ctx, a)
+	} else {
+		err = s.podman.StartContainer(ctx, a.ContainerID)
+	}
+	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -1851,12 +2169,16 @@ func (s *Server) handleStartApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.syncReplicas(ctx, a, false); err != nil {
+		log.Printf("Start %s: %v", a.Name, err)
+	}
+
 	a.Status = app.StatusRunning
 	s.storage.UpdateApp(a)
 
 	s.logActivity("user", "start", "app", a.ID, a.Name, "success", "")
 
-	jsonResponse(w, http.StatusOK, a)
+	jsonResponse(w, http.StatusOK, redactedApp(a))
 }
 
 // handleStopApp stops an app
@@ -1888,7 +2210,7 @@ func (s *Server) handleStopApp(w http.ResponseWriter, r *http.Request) {
 			errorResponse(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		jsonResponse(w, http.StatusOK, a)
+		jsonResponse(w, http.StatusOK, redactedApp(a))
 		return
 	}
 
@@ -1897,17 +2219,24 @@ func (s *Server) handleStopApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.podman.StopContainer(ctx, a.ContainerID, 30); err != nil {
+	if len(a.Services) > 0 {
+		err = s.stopServices(ctx, a, 30)
+	} else {
+		err = s.podman.StopContainer(ctx, a.ContainerID, 30)
+	}
+	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.removeReplicas(ctx, a)
 
 	a.Status = app.StatusStopped
+	a.LastExit = &app.ContainerExit{FinishedAt: time.Now(), Reason: "stopped by a user"}
 	s.storage.UpdateApp(a)
 
 	s.logActivity("user", "stop", "app", a.ID, a.Name, "success", "")
 
-	jsonResponse(w, http.StatusOK, a)
+	jsonResponse(w, http.StatusOK, redactedApp(a))
 }
 
 // handleRestartApp restarts an app by recreating the container
@@ -1942,7 +2271,7 @@ func (s *Server) handleRestartApp(w http.ResponseWriter, r *http.Request) {
 			errorResponse(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		jsonResponse(w, http.StatusOK, a)
+		jsonResponse(w, http.StatusOK, redactedApp(a))
 		return
 	}
 
@@ -1951,6 +2280,36 @@ func (s *Server) handleRestartApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(a.Services) > 0 {
+		// Services keep their containers; redeploy to change them
+		if err = s.stopServices(ctx, a, 10); err == nil {
+			err = s.startServices(ctx, a)
+		}
+	} else {
+		err = s.recreateAppContainer(ctx, a)
+	}
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := s.waitForAppReadiness(ctx, a); err != nil {
+		a.Status = app.StatusFailed
+		s.storage.UpdateApp(a)
+		errorResponse(w, http.StatusBadGateway, "App did not become ready: "+err.Error())
+		return
+	}
+
+	a.Status = app.StatusRunning
+	s.storage.UpdateApp(a)
+
+	s.logActivity("user", "restart", "app", a.ID, a.Name, "success", "")
+
+	jsonResponse(w, http.StatusOK, redactedApp(a))
+}
+
+// recreateAppContainer replaces the app's container with a new one from its current settings
+func (s *Server) recreateAppContainer(ctx context.Context, a *app.App) error {
 	// Stop and remove old container
 	containerName := "basepod-" + a.Name
 	if a.ContainerID != "" {
@@ -1962,18 +2321,13 @@ func (s *Server) handleRestartApp(w http.ResponseWriter, r *http.Request) {
 	_ = s.podman.RemoveContainer(ctx, containerName, true)
 
 	// Build volume mounts from app record
-	volumeMounts := []string{}
-	for _, v := range a.Volumes {
-		if v.HostPath != "" && v.ContainerPath != "" {
-			volumeMounts = append(volumeMounts, fmt.Sprintf("%s:%s", v.HostPath, v.ContainerPath))
-		}
-	}
+	volumeMounts := s.appVolumeMounts(ctx, a)
 
 	// Create new container with current settings
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     containerName,
 		Image:    a.Image,
-		Env:      a.Env,
+		Env:      s.containerEnv(a),
 		Networks: []string{"basepod"},
 		Volumes:  volumeMounts,
 		Ports: map[string]string{
@@ -1983,34 +2337,23 @@ func (s *Server) handleRestartApp(w http.ResponseWriter, r *http.Request) {
 			"basepod.app":    a.Name,
 			"basepod.app.id": a.ID,
 		},
-		Memory: a.Resources.Memory * 1024 * 1024,
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to create container: "+err.Error())
-		return
+		return fmt.Errorf("Failed to create container: %w", err)
 	}
 
 	// Start the new container
 	if err := s.podman.StartContainer(ctx, containerID); err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to start container: "+err.Error())
-		return
+		return fmt.Errorf("Failed to start container: %w", err)
 	}
 
 	a.ContainerID = containerID
-	if err := s.waitForAppReadiness(ctx, a); err != nil {
-		a.Status = app.StatusFailed
-		s.storage.UpdateApp(a)
-		errorResponse(w, http.StatusBadGateway, "App did not become ready: "+err.Error())
-		return
+	if err := s.syncReplicas(ctx, a, true); err != nil {
+		log.Printf("Recreate %s: %v", a.Name, err)
 	}
-
-	a.Status = app.StatusRunning
-	s.storage.UpdateApp(a)
-
-	s.logActivity("user", "restart", "app", a.ID, a.Name, "success", "")
-
-	jsonResponse(w, http.StatusOK, a)
+	return nil
 }
 
 // handleDeployApp deploys an app
@@ -2051,6 +2394,13 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	if fw := s.checkFreeze(a.ID, a.Name, req.FreezeOverride); fw != nil {
+		freezeResponse(w, fw)
+		return
+	}
+	if req.Recreate != nil {
+		a.Deployment.Recreate = *req.Recreate
+	}
 
 	// Update status
 	a.Status = app.StatusDeploying
@@ -2074,9 +2424,19 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Remove old container if exists (by ID and by name)
+	if _, err := s.preDeploySnapshot(ctx, a); err != nil {
+		a.Status = app.StatusFailed
+		s.storage.UpdateApp(a)
+		errorResponse(w, http.StatusInternalServerError, "Pre-deploy snapshot failed: "+err.Error())
+		return
+	}
+
+	// Set the old container aside to keep serving until the new one is ready,
+	// or remove it (by ID and by name) for apps deployed with recreate
 	containerName := "basepod-" + a.Name
-	if a.ContainerID != "" {
+	handoff := s.beginDrain(ctx, a)
+	defer s.finishDrain(a, handoff)
+	if a.ContainerID != "" && handoff == nil {
 		_ = s.podman.StopContainer(ctx, a.ContainerID, 10)
 		_ = s.podman.RemoveContainer(ctx, a.ContainerID, true)
 	}
@@ -2093,8 +2453,9 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     "basepod-" + a.Name,
 		Image:    image,
-		Env:      a.Env,
+		Env:      s.containerEnv(a),
 		Networks: []string{"basepod"},
+		Volumes:  s.appVolumeMounts(ctx, a),
 		Ports: map[string]string{
 			fmt.Sprintf("%d", a.Ports.ContainerPort): fmt.Sprintf("%d", a.Ports.HostPort),
 		},
@@ -2102,7 +2463,7 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 			"basepod.app":    a.Name,
 			"basepod.app.id": a.ID,
 		},
-		Memory: a.Resources.Memory * 1024 * 1024,
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
@@ -2128,16 +2489,15 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusBadGateway, "App did not become ready: "+err.Error())
 		return
 	}
+	if err := s.syncReplicas(ctx, a, true); err != nil {
+		log.Printf("Deploy %s: %v", a.Name, err)
+	}
+	s.removeCanary(ctx, a) // A full deploy replaces any canary
 
 	// Configure Caddy reverse proxy if domain is set
 	// Always use localhost with host port (container IP doesn't work on macOS with Podman VM)
 	if a.Domain != "" && s.caddy != nil {
-		route := caddy.Route{
-			ID:        "basepod-" + a.Name,
-			Domain:    a.Domain,
-			Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-			EnableSSL: a.SSL.Enabled,
-		}
+		route := AppRoute(a, a.Domain)
 
 		if err := s.caddy.AddRoute(route); err != nil {
 			// Log but don't fail deployment
@@ -2146,12 +2506,7 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 
 		// Add routes for domain aliases
 		for _, alias := range a.Aliases {
-			aliasRoute := caddy.Route{
-				ID:        fmt.Sprintf("alias-%s-%s", a.ID[:8], alias),
-				Domain:    alias,
-				Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-				EnableSSL: a.SSL.Enabled,
-			}
+			aliasRoute := AppRoute(a, alias)
 			if err := s.caddy.AddRoute(aliasRoute); err != nil {
 				fmt.Printf("Warning: Failed to configure alias route for %s: %v\n", alias, err)
 			}
@@ -2162,7 +2517,7 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 	a.Status = app.StatusRunning
 	s.storage.UpdateApp(a)
 
-	jsonResponse(w, http.StatusOK, a)
+	jsonResponse(w, http.StatusOK, redactedApp(a))
 }
 
 // handleGetAppLogs retrieves app logs
@@ -2197,11 +2552,13 @@ func (s *Server) handleGetAppLogs(w http.ResponseWriter, r *http.Request) {
 	if tail == "" {
 		tail = "100"
 	}
+	follow := r.URL.Query().Get("follow") == "true" || r.URL.Query().Get("follow") == "1"
 
 	logs, err := s.podman.ContainerLogs(ctx, a.ContainerID, podman.LogOpts{
 		Stdout: true,
 		Stderr: true,
 		Tail:   tail,
+		Follow: follow,
 	})
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
@@ -2209,44 +2566,36 @@ func (s *Server) handleGetAppLogs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer logs.Close()
 
+	flush := func() {}
+	if follow {
+		// Stream until the client disconnects: lift the server's write timeout
+		// and push each line out as it arrives
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Time{})
+		flush = func() { rc.Flush() }
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+	}
+
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusOK)
 
-	// Podman multiplexed stream: each frame has an 8-byte header
-	// [stream_type(1), padding(3), size(4 big-endian)]
-	// Strip headers and output only the payload
-	reader := bufio.NewReader(logs)
-	header := make([]byte, 8)
-	for {
-		_, err := io.ReadFull(reader, header)
-		if err != nil {
-			break
-		}
-		// Frame size from bytes 4-7 (big-endian uint32)
-		frameSize := int(header[4])<<24 | int(header[5])<<16 | int(header[6])<<8 | int(header[7])
-		if frameSize <= 0 || frameSize > 1<<20 {
-			// Invalid frame - likely not multiplexed, dump remaining as-is
-			w.Write(header[:])
-			buf := make([]byte, 4096)
-			for {
-				n, err := reader.Read(buf)
-				if n > 0 {
-					w.Write(buf[:n])
-				}
-				if err != nil {
-					break
-				}
-			}
-			break
-		}
-		// Read and write the payload
-		payload := make([]byte, frameSize)
-		_, err = io.ReadFull(reader, payload)
-		if err != nil {
-			break
-		}
-		w.Write(payload)
+	// Each release runs in a fresh container, so its logs start at the deploy marker
+	if r.URL.Query().Get("markers") != "false" {
+		io.WriteString(w, deployMarkerLine(a))
 	}
+	flush()
+
+	copyContainerLogs(w, logs, flush)
+}
+
+// copyContainerLogs writes the payload of a Podman log stream to w, calling
+// flush after each frame
+func copyContainerLogs(w io.Writer, logs io.Reader, flush func()) {
+	podman.ReadLogFrames(logs, func(_ string, data []byte) {
+		w.Write(data)
+		flush()
+	})
 }
 
 // handleSystemInfo returns system information
@@ -2276,6 +2625,9 @@ func (s *Server) handleSystemInfo(w http.ResponseWriter, r *http.Request) {
 		info["images_error"] = err.Error()
 	}
 
+	info["pull_cache"] = s.pullCache.Stats(ctx)
+	info["registry"] = s.registry.Stats()
+
 	jsonResponse(w, http.StatusOK, info)
 }
 
@@ -2512,14 +2864,11 @@ func (s *Server) handleDeleteStorageCategory(w http.ResponseWriter, r *http.Requ
 		podmanDir := filepath.Join(home, ".local", "share", "containers")
 		sizeBefore = diskutil.DirSize(podmanDir)
 
-		// Run podman system prune to clean up
-		cmds := [][]string{
-			{"podman", "system", "prune", "-af"},
-			{"podman", "builder", "prune", "-af"},
-		}
-		for _, args := range cmds {
-			cmd := exec.Command(args[0], args[1:]...)
-			cmd.Run() // best-effort
+		// Same basepod-aware prune as POST /api/system/prune: app volumes and
+		// the images of recent releases stay
+		if _, err := s.pruneSystem(r.Context(), PruneRequest{}); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
 		sizeAfter := diskutil.DirSize(podmanDir)
@@ -2904,24 +3253,12 @@ func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
 	current := s.version
 
 	// Fetch latest version from GitHub releases
-	latest := current
-	updateAvailable := false
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get("https://api.github.com/repos/base-go/basepod/releases/latest")
-	if err == nil && resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
-		var release struct {
-			TagName string `json:"tag_name"`
-		}
-		if json.NewDecoder(resp.Body).Decode(&release) == nil && release.TagName != "" {
-			latest = strings.TrimPrefix(release.TagName, "v")
-			// Compare versions semantically
-			if compareVersions(latest, current) > 0 {
-				updateAvailable = true
-			}
-		}
+	latest := latestReleaseVersion()
+	if latest == "" {
+		latest = current
 	}
+	// Compare versions semantically
+	updateAvailable := compareVersions(latest, current) > 0
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"current":         current,
@@ -2930,6 +3267,27 @@ func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// latestReleaseVersion returns the latest release version from GitHub ("" if unknown)
+func latestReleaseVersion() string {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("https://api.github.com/repos/base-go/basepod/releases/latest")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&release) != nil {
+		return ""
+	}
+	return strings.TrimPrefix(release.TagName, "v")
+}
+
 // compareVersions compares two semver strings, returns 1 if a > b, -1 if a < b, 0 if equal
 func compareVersions(a, b string) int {
 	aParts := strings.Split(a, ".")
@@ -2960,11 +3318,31 @@ func compareVersions(a, b string) int {
 
 // handleSystemUpdate triggers a self-update
 func (s *Server) handleSystemUpdate(w http.ResponseWriter, r *http.Request) {
+	if err := installLatestRelease(); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Send response first, then trigger restart in background
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "updated",
+		"message": "Update complete. Restarting service...",
+	})
+
+	// Restart by exiting - launchd/systemd KeepAlive will restart with new binary
+	go func() {
+		time.Sleep(500 * time.Millisecond) // Give time for response to be sent
+		os.Exit(0)
+	}()
+}
+
+// installLatestRelease downloads the latest release binary over the running executable.
+// The caller is responsible for restarting the process.
+func installLatestRelease() error {
 	// Determine binary path and architecture
 	execPath, err := os.Executable()
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Cannot determine executable path")
-		return
+		return fmt.Errorf("Cannot determine executable path")
 	}
 
 	// Use runtime OS and architecture
@@ -2981,37 +3359,32 @@ func (s *Server) handleSystemUpdate(w http.ResponseWriter, r *http.Request) {
 	client := &http.Client{Timeout: 120 * time.Second}
 	resp, err := client.Get(downloadURL)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to download update: "+err.Error())
-		return
+		return fmt.Errorf("Failed to download update: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Download failed with status: %d", resp.StatusCode))
-		return
+		return fmt.Errorf("Download failed with status: %d", resp.StatusCode)
 	}
 
 	// Write to temp file
 	tmpFile, err := os.CreateTemp("", "basepod-update-*")
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to create temp file: "+err.Error())
-		return
+		return fmt.Errorf("Failed to create temp file: %w", err)
 	}
 	tmpPath := tmpFile.Name()
 
 	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
 		tmpFile.Close()
 		os.Remove(tmpPath)
-		errorResponse(w, http.StatusInternalServerError, "Failed to write update: "+err.Error())
-		return
+		return fmt.Errorf("Failed to write update: %w", err)
 	}
 	tmpFile.Close()
 
 	// Make executable
 	if err := os.Chmod(tmpPath, 0755); err != nil {
 		os.Remove(tmpPath)
-		errorResponse(w, http.StatusInternalServerError, "Failed to set permissions: "+err.Error())
-		return
+		return fmt.Errorf("Failed to set permissions: %w", err)
 	}
 
 	// Replace current binary (atomic move)
@@ -3020,132 +3393,25 @@ func (s *Server) handleSystemUpdate(w http.ResponseWriter, r *http.Request) {
 		srcFile, err := os.Open(tmpPath)
 		if err != nil {
 			os.Remove(tmpPath)
-			errorResponse(w, http.StatusInternalServerError, "Failed to open temp file: "+err.Error())
-			return
+			return fmt.Errorf("Failed to open temp file: %w", err)
 		}
 		defer srcFile.Close()
 
 		dstFile, err := os.Create(execPath)
 		if err != nil {
 			os.Remove(tmpPath)
-			errorResponse(w, http.StatusInternalServerError, "Failed to replace binary (permission denied?): "+err.Error())
-			return
+			return fmt.Errorf("Failed to replace binary (permission denied?): %w", err)
 		}
 		defer dstFile.Close()
 
 		if _, err := io.Copy(dstFile, srcFile); err != nil {
 			os.Remove(tmpPath)
-			errorResponse(w, http.StatusInternalServerError, "Failed to write binary: "+err.Error())
-			return
+			return fmt.Errorf("Failed to write binary: %w", err)
 		}
 		os.Remove(tmpPath)
 	}
 
-	// Send response first, then trigger restart in background
-	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"status":  "updated",
-		"message": "Update complete. Restarting service...",
-	})
-
-	// Restart by exiting - launchd/systemd KeepAlive will restart with new binary
-	go func() {
-		time.Sleep(500 * time.Millisecond) // Give time for response to be sent
-		os.Exit(0)
-	}()
-}
-
-// handleSystemPrune removes unused containers, images, and volumes
-// but preserves images that belong to basepod-managed apps
-func (s *Server) handleSystemPrune(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	// Find podman path
-	podmanPath := "podman"
-	if _, err := exec.LookPath("podman"); err != nil {
-		for _, p := range []string{"/opt/homebrew/bin/podman", "/usr/local/bin/podman"} {
-			if _, err := os.Stat(p); err == nil {
-				podmanPath = p
-				break
-			}
-		}
-	}
-
-	// Collect images used by basepod apps so we don't delete them
-	protectedImages := map[string]bool{}
-	if apps, err := s.storage.ListApps(); err == nil {
-		for _, a := range apps {
-			if a.Image != "" {
-				protectedImages[a.Image] = true
-				// Also protect the :latest tag for this app
-				parts := strings.SplitN(a.Image, ":", 2)
-				if len(parts) == 2 {
-					protectedImages[parts[0]+":latest"] = true
-				}
-			}
-		}
-	}
-
-	var output strings.Builder
-
-	// Step 1: Prune stopped containers (safe — doesn't affect images)
-	cmd := exec.CommandContext(ctx, podmanPath, "container", "prune", "-f")
-	if out, err := cmd.CombinedOutput(); err == nil {
-		output.WriteString("Containers: " + strings.TrimSpace(string(out)) + "\n")
-	}
-
-	// Step 2: Remove only dangling (untagged) images — NOT all unused images
-	cmd = exec.CommandContext(ctx, podmanPath, "image", "prune", "-f")
-	if out, err := cmd.CombinedOutput(); err == nil {
-		output.WriteString("Dangling images: " + strings.TrimSpace(string(out)) + "\n")
-	}
-
-	// Step 3: Remove untagged/unused images that are NOT protected by basepod apps
-	if s.podman != nil {
-		images, err := s.podman.ListImages(ctx)
-		if err == nil {
-			removed := 0
-			for _, img := range images {
-				// Skip images with no repo tags (already handled by dangling prune)
-				if len(img.RepoTags) == 0 {
-					continue
-				}
-				// Skip images that are protected by basepod apps
-				isProtected := false
-				for _, tag := range img.RepoTags {
-					if protectedImages[tag] {
-						isProtected = true
-						break
-					}
-				}
-				if isProtected {
-					continue
-				}
-				// Remove unprotected basepod images (old deploy tags)
-				for _, tag := range img.RepoTags {
-					if strings.HasPrefix(tag, "localhost/basepod/") {
-						if err := s.podman.RemoveImage(ctx, img.ID, false); err == nil {
-							removed++
-						}
-						break
-					}
-				}
-			}
-			if removed > 0 {
-				output.WriteString(fmt.Sprintf("Old basepod images: %d removed\n", removed))
-			}
-		}
-	}
-
-	// Step 4: Prune build cache
-	cmd = exec.CommandContext(ctx, podmanPath, "builder", "prune", "-af")
-	if out, err := cmd.CombinedOutput(); err == nil {
-		output.WriteString("Build cache: " + strings.TrimSpace(string(out)) + "\n")
-	}
-
-	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"status": "pruned",
-		"output": output.String(),
-	})
+	return nil
 }
 
 // handleServiceRestart restarts a system service
@@ -3381,6 +3647,11 @@ func (s *Server) handleImportContainer(w http.ResponseWriter, r *http.Request) {
 			Domain:    domain,
 			Upstream:  internalHost,
 			EnableSSL: newApp.SSL.Enabled,
+			SEO:       appRouteSEO(newApp, domain),
+			Headers:   newApp.ResponseHeaders(),
+			Snippets:  appRouteSnippets(newApp),
+			Protocol:  newApp.Ports.Protocol,
+			Streams:   appRouteStreams(newApp),
 		}); err != nil {
 			log.Printf("Warning: Failed to add Caddy route for %s: %v", domain, err)
 		}
@@ -3414,7 +3685,7 @@ func (s *Server) deployPlaceholder(a *app.App) {
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     "basepod-" + a.Name,
 		Image:    placeholderImage,
-		Env:      a.Env,
+		Env:      s.containerEnv(a),
 		Networks: []string{"basepod"},
 		Ports: map[string]string{
 			fmt.Sprintf("%d", a.Ports.ContainerPort): fmt.Sprintf("%d", a.Ports.HostPort),
@@ -3423,7 +3694,7 @@ func (s *Server) deployPlaceholder(a *app.App) {
 			"basepod.app":    a.Name,
 			"basepod.app.id": a.ID,
 		},
-		Memory: a.Resources.Memory,
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
@@ -3454,19 +3725,14 @@ func (s *Server) deployPlaceholder(a *app.App) {
 
 	// Configure Caddy if domain is set
 	if a.Domain != "" && s.caddy != nil {
-		_ = s.caddy.AddRoute(caddy.Route{
-			ID:        "basepod-" + a.Name,
-			Domain:    a.Domain,
-			Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-			EnableSSL: a.SSL.Enabled,
-		})
+		_ = s.caddy.AddRoute(AppRoute(a, a.Domain))
 	}
 }
 
 // handleListTemplates returns available app templates
 func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
-		"templates": templates.GetTemplatesForArch(),
+		"templates": s.templateCatalog().Apply(templates.GetTemplatesForArch()),
 		"system":    templates.GetSystemInfo(),
 	}
 	jsonResponse(w, http.StatusOK, response)
@@ -3493,6 +3759,7 @@ func (s *Server) handleDeployTemplate(w http.ResponseWriter, r *http.Request) {
 		Env            map[string]string `json:"env"`
 		EnableSSL      bool              `json:"enableSSL"`
 		ExposeExternal bool              `json:"exposeExternal"`
+		Model          string            `json:"model"` // Model to serve for model-bound templates
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
@@ -3536,12 +3803,23 @@ func (s *Server) handleDeployTemplate(w http.ResponseWriter, r *http.Request) {
 
 	env := mergedTemplateEnv(tmpl, req.Env, name, domain)
 
+	// Chat UIs talk to the local model server, so make sure one is serving
+	if tmpl.ModelBinding {
+		port, err := ensureModelRunning(req.Model)
+		if err != nil {
+			errorResponse(w, http.StatusConflict, err.Error())
+			return
+		}
+		bindModelEnv(env, fmt.Sprintf("http://host.containers.internal:%d/v1", port), "basepod-local")
+	}
+
 	// Convert template volumes to app volumes
 	var volumes []app.VolumeMount
 	for _, v := range tmpl.Volumes {
 		volumes = append(volumes, app.VolumeMount{
 			Name:          v.Name,
 			ContainerPath: v.ContainerPath,
+			Size:          v.Size,
 		})
 	}
 
@@ -3561,6 +3839,10 @@ func (s *Server) handleDeployTemplate(w http.ResponseWriter, r *http.Request) {
 		Resources: app.ResourceConfig{
 			Replicas: 1,
 		},
+		Deployment: app.DeploymentConfig{
+			Template:        tmpl.ID,
+			TemplateVersion: tmpl.DefaultVersion,
+		},
 		SSL: app.SSLConfig{
 			Enabled:   req.EnableSSL,
 			AutoRenew: true,
@@ -3568,11 +3850,15 @@ func (s *Server) handleDeployTemplate(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	if newApp.Deployment.TemplateVersion == "" && len(tmpl.Versions) > 0 {
+		newApp.Deployment.TemplateVersion = tmpl.Versions[0]
+	}
 
 	if err := s.storage.CreateApp(newApp); err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.grantCreatorAccess(r, newApp.ID)
 
 	// Deploy with template image
 	go s.deployFromTemplate(newApp, tmpl)
@@ -3605,7 +3891,7 @@ func (s *Server) deployFromTemplate(a *app.App, tmpl *templates.Template) {
 	volumeMounts := []string{}
 	for _, v := range a.Volumes {
 		// Use named volume format: volumeName:containerPath
-		volumeName := fmt.Sprintf("basepod-%s-%s", a.Name, v.Name)
+		volumeName := s.ensureAppVolume(ctx, a, v, tmpl.ID)
 		volumeMounts = append(volumeMounts, fmt.Sprintf("%s:%s", volumeName, v.ContainerPath))
 	}
 
@@ -3613,7 +3899,7 @@ func (s *Server) deployFromTemplate(a *app.App, tmpl *templates.Template) {
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     "basepod-" + a.Name,
 		Image:    image,
-		Env:      a.Env,
+		Env:      s.containerEnv(a),
 		Command:  resolveTemplateCommand(tmpl.Command, a.Env),
 		Networks: []string{"basepod"},
 		Volumes:  volumeMounts,
@@ -3625,7 +3911,7 @@ func (s *Server) deployFromTemplate(a *app.App, tmpl *templates.Template) {
 			"basepod.app.id":   a.ID,
 			"basepod.template": tmpl.ID,
 		},
-		Memory: a.Resources.Memory,
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
@@ -3656,12 +3942,7 @@ func (s *Server) deployFromTemplate(a *app.App, tmpl *templates.Template) {
 
 	// Configure Caddy if domain is set
 	if a.Domain != "" && s.caddy != nil {
-		_ = s.caddy.AddRoute(caddy.Route{
-			ID:        "basepod-" + a.Name,
-			Domain:    a.Domain,
-			Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-			EnableSSL: a.SSL.Enabled,
-		})
+		_ = s.caddy.AddRoute(AppRoute(a, a.Domain))
 	}
 }
 
@@ -3711,6 +3992,18 @@ func (s *Server) handleCaddyCheck(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Allow domains that redirect after a domain migration
+	if d, _ := s.storage.GetDomainRedirect(domain); d != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Allow domains shared between apps by path routing
+	if routes, _ := s.storage.ListDomainRoutes(domain); len(routes) > 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	// Allow any subdomain of our base domain (for future apps)
 	if strings.HasSuffix(domain, "."+baseDomain) {
 		w.WriteHeader(http.StatusOK)
@@ -3733,12 +4026,40 @@ type SourceDeployConfig struct {
 	GitCommit  string            `json:"git_commit,omitempty"`
 	GitMessage string            `json:"git_message,omitempty"`
 	GitBranch  string            `json:"git_branch,omitempty"`
+	// Lockfiles the CLI found modified but not committed
+	DirtyLockfiles []string `json:"dirty_lockfiles,omitempty"`
+	// Search engine controls; replaces the app's settings when set
+	SEO *app.SEOConfig `json:"seo,omitempty"`
+	// Upstream protocol: http, h2c or grpc
+	Protocol string `json:"protocol,omitempty"`
+	// Scheduled one-off containers; replaces the app's basepod.yaml jobs
+	Jobs map[string]app.JobConfig `json:"jobs,omitempty"`
+	// Health check endpoint; enables health checks on apps that have none
+	HealthCheck string `json:"health_check,omitempty"`
+	// Smoke check run against each new release before the deploy succeeds
+	Verify *app.VerifyConfig `json:"verify,omitempty"`
+	// Why this deploy goes ahead during a deploy freeze
+	FreezeOverride string `json:"freeze_override,omitempty"`
+	// Containers run together in a pod instead of one built from the source root
+	Services map[string]*ServiceSpec `json:"services,omitempty"`
+	// Extra proxy rules; replaces the app's when set
+	Routing *app.RoutingConfig `json:"routing,omitempty"`
+	// How a static site's files are served; replaces the app's when set
+	Static *app.StaticConfig `json:"static,omitempty"`
+	// Percent of requests for the new release, run as a canary next to the
+	// current one until promoted (0: replace the current release)
+	Canary int `json:"canary,omitempty"`
+	// Stop the old container before starting the new one instead of a
+	// blue/green switch; saved as the app's deploy strategy when set
+	Recreate *bool `json:"recreate,omitempty"`
 }
 
 // BuildConfig contains build configuration
 type BuildConfig struct {
-	Dockerfile string `json:"dockerfile,omitempty"`
-	Context    string `json:"context,omitempty"`
+	Dockerfile      string `json:"dockerfile,omitempty"`
+	Context         string `json:"context,omitempty"`
+	RequireLockfile bool   `json:"require_lockfile,omitempty"` // Fail the deploy without committed lockfiles
+	Preset          string `json:"preset,omitempty"`           // Build preset a missing Dockerfile is generated from
 }
 
 // handleSourceDeploy handles source code deployments from the CLI
@@ -3749,10 +4070,7 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 	// Construct users: 50MB max upload, 5 apps max
 	// Admin: 500MB, unlimited apps
 	isConstructUser := getConstructUser(r) != nil
-	maxUploadBytes := int64(500 << 20) // 500MB default
-	if isConstructUser {
-		maxUploadBytes = 50 << 20 // 50MB for Construct users
-	}
+	maxUploadBytes := uploadLimit(r)
 
 	// Parse multipart form with size limit
 	if err := r.ParseMultipartForm(maxUploadBytes); err != nil {
@@ -3807,16 +4125,84 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get source tarball
-	file, _, err := r.FormFile("source")
+	// Callers limited to some apps can't deploy over anyone else's; tokens
+	// they made can't create apps either
+	sc, err := s.callerScope(r)
 	if err != nil {
-		errorResponse(w, http.StatusBadRequest, "Missing source tarball: "+err.Error())
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
 		return
 	}
-	defer file.Close()
+	if sc != nil && ((a != nil && !sc.allows(a.ID)) || (a == nil && getDeployTokenFromCtx(r) != nil)) {
+		errorResponse(w, http.StatusForbidden, "You don't have access to this app")
+		return
+	}
+
+	appID := ""
+	if a != nil {
+		appID = a.ID
+	}
+	if fw := s.checkFreeze(appID, deployConfig.Name, deployConfig.FreezeOverride); fw != nil {
+		freezeResponse(w, fw)
+		return
+	}
+
+	// basepod.yaml volumes become named volumes of the app, created on
+	// first use and kept across redeploys
+	var existingVolumes []app.VolumeMount
+	if a != nil {
+		existingVolumes = a.Volumes
+	}
+	volumes, err := parseAppVolumes(deployConfig.Volumes, existingVolumes)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Get source tarball, or the manifest of a delta upload whose file
+	// contents were sent to the blob store beforehand
+	var source io.Reader
+	if manifest := r.FormValue("manifest"); manifest != "" {
+		var files []app.SourceFile
+		if err := json.Unmarshal([]byte(manifest), &files); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid manifest JSON: "+err.Error())
+			return
+		}
+		blobDir, err := sourceBlobDir()
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		missing, err := checkSourceManifest(blobDir, files)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid manifest: "+err.Error())
+			return
+		}
+		if missing > 0 {
+			errorResponse(w, http.StatusBadRequest, fmt.Sprintf("%d files of the manifest haven't been uploaded", missing))
+			return
+		}
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(writeManifestTarball(pw, blobDir, files)) }()
+		defer pr.Close()
+		source = pr
+	} else {
+		file, _, err := r.FormFile("source")
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Missing source tarball: "+err.Error())
+			return
+		}
+		defer file.Close()
+		source = file
+	}
 
-	// Set response headers for streaming output
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// Set response headers for streaming output: plain text lines, or JSON
+	// lines with phases and a final result for clients that ask for events
+	events := wantsDeployEvents(r)
+	if events {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -3824,21 +4210,21 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var buildLog strings.Builder
-	writeLine := func(msg string) {
-		fmt.Fprintf(w, "%s\n", msg)
-		flusher.Flush()
-		buildLog.WriteString(msg + "\n")
-	}
+	stream := newDeployStream(w, flusher.Flush, events)
+	stream.progress = func(e DeployEvent) { s.publishDeploy(a, e) } // Once the app exists
+	defer stream.close(deployConfig.Name)
+	writeLine := stream.line
 
+	stream.startPhase(DeployPhaseUpload)
 	writeLine("Received source deploy request for: " + deployConfig.Name)
 
+	createdApp := a == nil
 	if a == nil {
 		// Enforce per-user app limit for Construct users
 		if cu := getConstructUser(r); cu != nil {
 			userApps, _ := s.storage.ListAppsByOwner(cu.ID)
 			if len(userApps) >= 5 {
-				errorResponse(w, http.StatusForbidden, "Free hosting limit reached (5 apps). Delete an existing app to deploy a new one.")
+				writeLine("ERROR: Free hosting limit reached (5 apps). Delete an existing app to deploy a new one.")
 				return
 			}
 		}
@@ -3856,18 +4242,6 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 			port = 8080
 		}
 
-		// Parse volumes from string format "name:container_path" to VolumeMount
-		var volumes []app.VolumeMount
-		for _, vol := range deployConfig.Volumes {
-			parts := strings.SplitN(vol, ":", 2)
-			if len(parts) == 2 {
-				volumes = append(volumes, app.VolumeMount{
-					Name:          parts[0],
-					ContainerPath: parts[1],
-				})
-			}
-		}
-
 		// Determine app type
 		appType := app.AppTypeContainer
 		if deployConfig.Type == "static" {
@@ -3912,6 +4286,7 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 			writeLine("ERROR: Failed to create app: " + err.Error())
 			return
 		}
+		s.grantCreatorAccess(r, a.ID)
 		writeLine("App created with ID: " + a.ID)
 	} else {
 		writeLine("Updating existing app: " + a.Name)
@@ -3927,16 +4302,16 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 				a.Env[k] = v
 			}
 		}
-		// Update volumes if provided
+		// Update volumes if provided. Volumes no longer declared keep
+		// their data until removed by hand.
 		if len(deployConfig.Volumes) > 0 {
-			var volumes []app.VolumeMount
-			for _, vol := range deployConfig.Volumes {
-				parts := strings.SplitN(vol, ":", 2)
-				if len(parts) == 2 {
-					volumes = append(volumes, app.VolumeMount{
-						Name:          parts[0],
-						ContainerPath: parts[1],
-					})
+			declared := make(map[string]bool, len(volumes))
+			for _, v := range volumes {
+				declared[v.Name] = true
+			}
+			for _, v := range a.Volumes {
+				if v.HostPath == "" && !declared[v.Name] {
+					writeLine(fmt.Sprintf("Volume %s is no longer mounted; its data is kept (bp volumes rm %s --force to delete it)", appVolumeName(a, v), appVolumeName(a, v)))
 				}
 			}
 			a.Volumes = volumes
@@ -3957,15 +4332,18 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 		writeLine("ERROR: Failed to create tarball file: " + err.Error())
 		return
 	}
-	if _, err := io.Copy(tarFile, file); err != nil {
+	sourceHash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tarFile, sourceHash), source); err != nil {
 		tarFile.Close()
 		writeLine("ERROR: Failed to save tarball: " + err.Error())
 		return
 	}
 	tarFile.Close()
-	writeLine("Source tarball saved")
+	sourceSHA256 := hex.EncodeToString(sourceHash.Sum(nil))
+	writeLine("Source tarball saved (sha256 " + sourceSHA256[:12] + ")")
 
 	// Extract tarball
+	stream.startPhase(DeployPhaseExtract)
 	writeLine("Extracting source...")
 	sourceDir := buildDir + "/source"
 	// Remove old source directory to prevent stale files from previous deploys
@@ -3984,6 +4362,7 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 	writeLine("Source extracted")
 
 	// Read .basepod config file if it exists
+	var buildArgs map[string]string
 	basepodConfigPath := sourceDir + "/basepod.yaml"
 	if _, err := os.Stat(basepodConfigPath); err == nil {
 		writeLine("Found basepod.yaml config file")
@@ -3998,6 +4377,20 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 				Public     string            `yaml:"public" json:"public"`
 				Env        map[string]string `yaml:"env" json:"env"`
 				BuildArgs  map[string]string `yaml:"build_args" json:"build_args"`
+				// Also accepted under build: as the CLI writes it
+				RequireLockfile bool `yaml:"require_lockfile" json:"require_lockfile"`
+				Build           struct {
+					RequireLockfile bool `yaml:"require_lockfile" json:"require_lockfile"`
+				} `yaml:"build" json:"build"`
+				SEO      *seoFileConfig           `yaml:"seo" json:"seo"`
+				Protocol string                   `yaml:"protocol" json:"protocol"`
+				Jobs     map[string]app.JobConfig `yaml:"jobs" json:"jobs"`
+				Health   string                   `yaml:"health_check" json:"health_check"`
+				Verify   *app.VerifyConfig        `yaml:"verify" json:"verify"`
+				Services map[string]*ServiceSpec  `yaml:"services" json:"services"`
+				Routing  *app.RoutingConfig       `yaml:"routing" json:"routing"`
+				Static   *app.StaticConfig        `yaml:"static" json:"static"`
+				Recreate *bool                    `yaml:"recreate" json:"recreate"`
 			}
 			// Try YAML first, then JSON
 			if err := yaml.Unmarshal(configData, &repoConfig); err != nil {
@@ -4023,6 +4416,39 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 				deployConfig.Type = repoConfig.Type
 				writeLine(fmt.Sprintf("  type: %s", repoConfig.Type))
 			}
+			if repoConfig.RequireLockfile || repoConfig.Build.RequireLockfile {
+				deployConfig.Build.RequireLockfile = true
+			}
+			if deployConfig.SEO == nil {
+				deployConfig.SEO = repoConfig.SEO.appConfig()
+			}
+			if repoConfig.Protocol != "" && deployConfig.Protocol == "" {
+				deployConfig.Protocol = repoConfig.Protocol
+				writeLine(fmt.Sprintf("  protocol: %s", repoConfig.Protocol))
+			}
+			if deployConfig.Jobs == nil {
+				deployConfig.Jobs = repoConfig.Jobs
+			}
+			if repoConfig.Verify != nil && deployConfig.Verify == nil {
+				deployConfig.Verify = repoConfig.Verify
+			}
+			if len(deployConfig.Services) == 0 {
+				deployConfig.Services = repoConfig.Services
+			}
+			if deployConfig.Routing == nil {
+				deployConfig.Routing = repoConfig.Routing
+			}
+			if deployConfig.Static == nil {
+				deployConfig.Static = repoConfig.Static
+			}
+			if deployConfig.Recreate == nil {
+				deployConfig.Recreate = repoConfig.Recreate
+			}
+			if repoConfig.Health != "" && deployConfig.HealthCheck == "" {
+				deployConfig.HealthCheck = repoConfig.Health
+				writeLine(fmt.Sprintf("  health_check: %s", repoConfig.Health))
+			}
+			buildArgs = repoConfig.BuildArgs
 			// Merge env vars (repo config as defaults, CLI overrides)
 			if len(repoConfig.Env) > 0 {
 				if deployConfig.Env == nil {
@@ -4037,77 +4463,127 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Auto-detect static site: has index.html, package.json, or any .html files with no Dockerfile
-	if deployConfig.Type == "" && a.Type != app.AppTypeStatic {
-		_, hasDockerfile := os.Stat(sourceDir + "/Dockerfile")
-		_, hasIndexHTML := os.Stat(sourceDir + "/index.html")
-		_, hasPackageJSON := os.Stat(sourceDir + "/package.json")
+	if deployConfig.SEO != nil {
+		if err := validateSEO(deployConfig.SEO); err != nil {
+			writeLine("ERROR: " + err.Error())
+			return
+		}
+		a.SEO = deployConfig.SEO
+	}
+	if deployConfig.Routing != nil {
+		if err := prepareRouting(deployConfig.Routing); err != nil {
+			writeLine("ERROR: routing: " + err.Error())
+			return
+		}
+		a.Routing = deployConfig.Routing
+	}
+	if deployConfig.Protocol != "" {
+		if err := validateProtocol(a, deployConfig.Protocol); err != nil {
+			writeLine("ERROR: " + err.Error())
+			return
+		}
+		a.Ports.Protocol = deployConfig.Protocol
+	}
+	if deployConfig.Recreate != nil {
+		a.Deployment.Recreate = *deployConfig.Recreate
+	}
+	if deployConfig.Verify != nil {
+		if err := validateVerifyConfig(deployConfig.Verify); err != nil {
+			writeLine("ERROR: " + err.Error())
+			return
+		}
+	}
+	if deployConfig.HealthCheck != "" && a.HealthCheck == nil {
+		if !strings.HasPrefix(deployConfig.HealthCheck, "/") {
+			writeLine("ERROR: health_check must be a path starting with /")
+			return
+		}
+		// Only apps without health checks get them, so settings changed in the
+		// dashboard aren't reset on every deploy
+		a.HealthCheck = &app.HealthCheckConfig{Endpoint: deployConfig.HealthCheck, Interval: 30, Timeout: 5, MaxFailures: 3, AutoRestart: true}
+		writeLine("Health checks enabled on " + deployConfig.HealthCheck)
+	}
+	if names, err := s.syncConfigJobs(a, deployConfig.Jobs); err != nil {
+		writeLine("ERROR: " + err.Error())
+		return
+	} else if len(names) > 0 {
+		writeLine("Scheduled jobs: " + strings.Join(names, ", "))
+	}
+
+	deployEnv := map[string]string{}
+	for k, v := range s.containerEnv(a) {
+		deployEnv[k] = v
+	}
+	for k, v := range deployConfig.Env {
+		deployEnv[k] = v
+	}
+	for _, warning := range s.updateAppDocs(a, sourceDir, deployEnv) {
+		writeLine("WARNING: " + warning)
+	}
 
-		// Also check for any .html files as fallback
-		hasAnyHTML := false
-		if hasIndexHTML != nil {
-			htmlFiles, _ := filepath.Glob(sourceDir + "/*.html")
-			hasAnyHTML = len(htmlFiles) > 0
+	if deployConfig.Build.RequireLockfile {
+		if err := checkLockfiles(sourceDir, deployConfig.DirtyLockfiles); err != nil {
+			writeLine("ERROR: " + err.Error())
+			writeLine("Commit your lockfiles or turn off build.require_lockfile")
+			if a.Status == app.StatusPending {
+				a.Status = app.StatusFailed
+				s.storage.UpdateApp(a)
+			}
+			return
 		}
+		writeLine("Lockfiles verified")
+	}
 
-		if hasDockerfile != nil && (hasIndexHTML == nil || hasPackageJSON == nil || hasAnyHTML) {
-			deployConfig.Type = "static"
-			writeLine("Auto-detected static site (no Dockerfile found)")
-
-			// If it's a Node.js project, build it first
-			if hasPackageJSON == nil {
-				writeLine("Building Node.js project...")
-
-				// Install dependencies
-				npmCmd := "npm"
-				if _, err := os.Stat(sourceDir + "/bun.lock"); err == nil {
-					npmCmd = "bun"
-				} else if _, err := os.Stat(sourceDir + "/yarn.lock"); err == nil {
-					npmCmd = "yarn"
-				} else if _, err := os.Stat(sourceDir + "/pnpm-lock.yaml"); err == nil {
-					npmCmd = "pnpm"
-				}
+	stream.startPhase(DeployPhaseBuild)
+	buildUser := ""
+	if session := s.auth.GetSession(s.getSessionToken(r)); session != nil {
+		buildUser = session.UserEmail
+	}
+	buildCtx, releaseBuild, err := s.acquireBuildSlot(ctx, BuildInfo{AppID: a.ID, AppName: a.Name, Source: "upload", User: buildUser}, writeLine)
+	if err != nil {
+		writeLine("ERROR: " + err.Error())
+		if a.Status == app.StatusPending {
+			a.Status = app.StatusFailed
+			s.storage.UpdateApp(a)
+		}
+		return
+	}
+	defer releaseBuild()
+	ctx = buildCtx
 
-				writeLine(fmt.Sprintf("Installing dependencies with %s...", npmCmd))
-				if npmCmd == "npm" {
-					// Try npm ci first, fall back to npm install
-					if output, err := execCommandDir(ctx, sourceDir, "npm", "ci", "--no-audit", "--no-fund"); err != nil {
-						if output2, err2 := execCommandDir(ctx, sourceDir, "npm", "install", "--no-audit", "--no-fund"); err2 != nil {
-							writeLine("WARNING: Dependency install had issues: " + err2.Error())
-							writeLine(output2)
-						} else {
-							_ = output
-							writeLine("Dependencies installed")
-						}
-					} else {
-						_ = output
-						writeLine("Dependencies installed")
-					}
-				} else {
-					if output, err := execCommandDir(ctx, sourceDir, npmCmd, "install"); err != nil {
-						writeLine("WARNING: Dependency install had issues: " + err.Error())
-						writeLine(output)
-					} else {
-						_ = output
-						writeLine("Dependencies installed")
-					}
-				}
+	if len(deployConfig.Services) > 0 {
+		s.deployServices(ctx, a, sourceDir, &deployConfig, stream, releaseBuild)
+		return
+	}
 
-				// Run build
-				writeLine("Running build...")
-				if output, err := execCommandDir(ctx, sourceDir, npmCmd, "run", "build"); err != nil {
-					writeLine("WARNING: Build had issues: " + err.Error())
-					writeLine(output)
-				} else {
-					_ = output
-					writeLine("Build complete")
+	// Without a Dockerfile, plan the build from the source's files
+	if deployConfig.Type == "" && a.Type != app.AppTypeStatic && deployConfig.Build.Dockerfile == "" && !fileExists(filepath.Join(sourceDir, "Dockerfile")) {
+		plan := detectBuildPlan(sourceDir, deployConfig.Port)
+		if plan == nil {
+			writeLine("ERROR: Could not detect project type. Please create a Dockerfile.")
+			return
+		}
+		writeLine(plan.String())
+		if plan.Stack == "static" {
+			deployConfig.Type = "static"
+			if plan.RunBuild {
+				runNodeBuild(ctx, sourceDir, writeLine)
+			}
+			if deployConfig.Public == "" {
+				deployConfig.Public = plan.PublicDir
+				if deployConfig.Public == "" {
+					deployConfig.Public = staticOutputDir(sourceDir)
 				}
 			}
+		} else if createdApp && deployConfig.Port == 0 && a.Ports.ContainerPort != plan.Port {
+			a.Ports.ContainerPort = plan.Port
+			writeLine(fmt.Sprintf("Container port: %d (the %s default)", plan.Port, plan.Stack))
 		}
 	}
 
 	// Handle static site deployment
 	if deployConfig.Type == "static" || a.Type == app.AppTypeStatic {
+		stream.startPhase(DeployPhaseRun)
 		writeLine("Deploying static site...")
 
 		// Determine public directory
@@ -4171,6 +4647,31 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// Redirects, headers and fallbacks from basepod.yaml and the site's
+		// _redirects and _headers files
+		static, err := loadStaticFiles(appDataDir, deployConfig.Static)
+		if err == nil && static != nil {
+			err = prepareStatic(static)
+		}
+		if err != nil {
+			writeLine("ERROR: static: " + err.Error())
+			return
+		}
+		if static != nil {
+			a.Static = static
+			writeLine(fmt.Sprintf("Static config: %d redirects, %d header rules, SPA fallback %t", len(static.Redirects), len(static.Headers), static.SPAFallback()))
+		}
+
+		// Compressed copies of text assets, so Caddy needn't compress them
+		// on every request
+		if a.Static.Precompresses() {
+			if stats, err := precompressStatic(ctx, appDataDir); err != nil {
+				writeLine("WARNING: Failed to precompress static files: " + err.Error())
+			} else {
+				writeLine(stats.String())
+			}
+		}
+
 		// Update app status and type
 		a.Type = app.AppTypeStatic
 		a.Status = app.StatusRunning
@@ -4183,7 +4684,7 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 			CommitMsg:  deployConfig.GitMessage,
 			Branch:     deployConfig.GitBranch,
 			Status:     "success",
-			BuildLog:   buildLog.String(),
+			BuildLog:   stream.buildLog.String(),
 			DeployedAt: time.Now(),
 		}
 		a.Deployments = append([]app.DeploymentRecord{deployRecord}, a.Deployments...)
@@ -4200,15 +4701,18 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 			writeLine("ERROR: Failed to update app: " + err.Error())
 			return
 		}
+		s.recordDeployMarker(a, deployRecord, "deploy")
 
 		// Update Caddy configuration for static site
-		if err := s.caddy.AddStaticRoute(a.Domain, appDataDir); err != nil {
+		stream.startPhase(DeployPhaseRoute)
+		if err := AddStaticAppRoute(s.caddy, a, a.Domain, appDataDir); err != nil {
 			writeLine("WARNING: Failed to update Caddy: " + err.Error())
 			// Continue anyway, can manually configure
 		}
 
 		writeLine("Static site deployed successfully!")
 		writeLine(fmt.Sprintf("URL: https://%s", a.Domain))
+		stream.succeed(a.Name, "https://"+a.Domain)
 		return
 	}
 
@@ -4231,7 +4735,11 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 	// Check if Dockerfile exists, auto-generate if not
 	if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) && deployConfig.Type != "static" && a.Type != app.AppTypeStatic {
 		writeLine("No Dockerfile found, auto-detecting stack...")
-		generated := generateDockerfile(sourceDir, deployConfig.Port)
+		generated, err := s.generateDockerfile(sourceDir, deployConfig.Port, deployConfig.Build.Preset)
+		if err != nil {
+			writeLine("ERROR: " + err.Error())
+			return
+		}
 		if generated == "" {
 			// Fallback: treat as static site if there are any servable files
 			htmlFiles, _ := filepath.Glob(sourceDir + "/*.html")
@@ -4252,6 +4760,17 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if deployConfig.Canary != 0 {
+		err := validateCanaryWeight(deployConfig.Canary)
+		if err == nil {
+			err = checkCanaryable(a)
+		}
+		if err != nil {
+			writeLine("ERROR: " + err.Error())
+			return
+		}
+	}
+
 	// Build image using Podman — unique tag for rollback support
 	// Use localhost/ prefix so Podman can resolve locally-built images
 	deployTag := fmt.Sprintf("%d", time.Now().Unix())
@@ -4273,7 +4792,8 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	output, err := execCommandStreamDir(ctx, sourceDir, podmanPath, []string{"build", "-t", imageName, "-t", imageLatest, "-f", dockerfileRel, "."}, writeLine)
+	buildCmd := append([]string{"build", "-t", imageName, "-t", imageLatest, "-f", dockerfileRel}, buildArgFlags(buildArgs)...)
+	output, err := execCommandStreamDir(ctx, sourceDir, podmanPath, append(buildCmd, "."), writeLine)
 	if err != nil {
 		writeLine("ERROR: Build failed: " + err.Error())
 		writeLine(output)
@@ -4282,10 +4802,71 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	writeLine("Image built successfully")
+	imageReport, err := s.inspectImageReport(ctx, imageName)
+	if err != nil {
+		writeLine("WARNING: Failed to read image size: " + err.Error())
+	} else {
+		for _, line := range formatImageReport(imageReport, previousImageReport(a.Deployments)) {
+			writeLine(line)
+		}
+	}
+	provenance := s.buildProvenance(ctx, podmanPath, sourceDir, dockerfileRel, buildArgs)
+	provenance.SourceSHA256 = sourceSHA256
+	provenance.GitCommit = deployConfig.GitCommit
+	registryImage := s.pushBuild(ctx, podmanPath, a, imageName, deployConfig.GitCommit, deployTag, writeLine)
+	releaseBuild()
+
+	if deployConfig.Canary != 0 {
+		stream.startPhase(DeployPhaseRun)
+		writeLine(fmt.Sprintf("Starting canary with %d%% of requests...", deployConfig.Canary))
+		a.Status = app.StatusRunning
+		err := s.startCanary(ctx, a, app.Canary{
+			Image:      imageName,
+			Weight:     deployConfig.Canary,
+			CommitHash: deployConfig.GitCommit,
+			CommitMsg:  deployConfig.GitMessage,
+			Branch:     deployConfig.GitBranch,
+		})
+		if err != nil {
+			writeLine("ERROR: " + err.Error())
+			s.storage.UpdateApp(a)
+			return
+		}
+		s.logActivity("system", "canary", "app", a.ID, a.Name, "success", fmt.Sprintf("%s at %d%%", imageName, deployConfig.Canary))
+		writeLine("")
+		writeLine("Canary running! Promote it with: bp promote " + a.Name)
+		writeLine("Or roll it back with:             bp abort " + a.Name)
+		appURL := ""
+		if a.Domain != "" {
+			appURL = "https://" + a.Domain
+		}
+		stream.succeed(a.Name, appURL)
+		return
+	}
 
-	// Remove old container if exists
+	if a.Deployment.Snapshot != nil && a.Deployment.Snapshot.Enabled {
+		writeLine("Taking pre-deploy snapshot...")
+	}
+	snapshotID, err := s.preDeploySnapshot(ctx, a)
+	if err != nil {
+		writeLine("ERROR: Pre-deploy snapshot failed: " + err.Error())
+		return
+	}
+	if snapshotID != "" {
+		writeLine("Snapshot " + snapshotID + " saved")
+	}
+
+	// Keep the old container serving until the new one is ready, or remove it
+	// for apps deployed with recreate
+	stream.startPhase(DeployPhaseRun)
 	containerName := "basepod-" + a.Name
-	if a.ContainerID != "" {
+	handoff := s.beginDrain(ctx, a)
+	defer s.finishDrain(a, handoff)
+	if handoff != nil && handoff.period > 0 {
+		writeLine(fmt.Sprintf("Old container keeps serving until the new one is ready, then its open connections for up to %s", handoff.period))
+	} else if handoff != nil {
+		writeLine("Old container keeps serving until the new one is ready")
+	} else if a.ContainerID != "" {
 		writeLine("Stopping old container...")
 		_ = s.podman.StopContainer(ctx, a.ContainerID, 10)
 		_ = s.podman.RemoveContainer(ctx, a.ContainerID, true)
@@ -4301,19 +4882,17 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 	writeLine(fmt.Sprintf("Creating container with port mapping %d -> %d...", a.Ports.ContainerPort, a.Ports.HostPort))
 
 	// Build volume mounts from app config
-	volumeMounts := []string{}
-	for _, v := range a.Volumes {
-		// Use named volume format: volumeName:containerPath
-		volumeName := fmt.Sprintf("basepod-%s-%s", a.Name, v.Name)
-		volumeMounts = append(volumeMounts, fmt.Sprintf("%s:%s", volumeName, v.ContainerPath))
-		writeLine(fmt.Sprintf("Volume: %s -> %s", volumeName, v.ContainerPath))
+	volumeMounts := s.appVolumeMounts(ctx, a)
+	for _, mount := range volumeMounts {
+		source, target, _ := strings.Cut(mount, ":")
+		writeLine(fmt.Sprintf("Volume: %s -> %s", source, target))
 	}
 
 	// Create new container with network — use latest tag (more reliable with Podman API)
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     containerName,
 		Image:    imageLatest,
-		Env:      a.Env,
+		Env:      s.containerEnv(a),
 		Networks: []string{"basepod"},
 		Volumes:  volumeMounts,
 		Ports: map[string]string{
@@ -4323,7 +4902,7 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 			"basepod.app":    a.Name,
 			"basepod.app.id": a.ID,
 		},
-		Memory: a.Resources.Memory * 1024 * 1024, // MB to bytes
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
@@ -4352,20 +4931,47 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 		s.storage.UpdateApp(a)
 		return
 	}
+	if n := replicaCount(a); n > 1 {
+		writeLine(fmt.Sprintf("Starting %d replicas...", n-1))
+	}
+	if err := s.syncReplicas(ctx, a, true); err != nil {
+		writeLine("WARNING: " + err.Error())
+	}
+	s.removeCanary(ctx, a)
+
+	// Smoke check the new release before calling the deploy a success
+	var verifyErr error
+	if deployConfig.Verify != nil {
+		stream.startPhase(DeployPhaseVerify)
+		if verifyErr = s.verifyRelease(ctx, a, deployConfig.Verify, writeLine); verifyErr == nil {
+			writeLine("Verify passed")
+		}
+	}
 
 	a.Status = app.StatusRunning
 	a.UpdatedAt = time.Now()
 
 	// Add deployment record
 	deployRecord := app.DeploymentRecord{
-		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
-		Image:      imageName,
-		CommitHash: deployConfig.GitCommit,
-		CommitMsg:  deployConfig.GitMessage,
-		Branch:     deployConfig.GitBranch,
-		Status:     "success",
-		BuildLog:   buildLog.String(),
-		DeployedAt: time.Now(),
+		ID:            fmt.Sprintf("%d", time.Now().UnixNano()),
+		Image:         imageName,
+		CommitHash:    deployConfig.GitCommit,
+		CommitMsg:     deployConfig.GitMessage,
+		Branch:        deployConfig.GitBranch,
+		Status:        "success",
+		BuildLog:      stream.buildLog.String(),
+		ImageReport:   imageReport,
+		Provenance:    provenance,
+		SnapshotID:    snapshotID,
+		DeployedAt:    time.Now(),
+		RegistryImage: registryImage,
+	}
+	if deployConfig.Verify != nil {
+		deployRecord.Verify = "passed"
+	}
+	if verifyErr != nil {
+		deployRecord.Status = "failed"
+		deployRecord.Verify = verifyErr.Error()
 	}
 	a.Deployments = append([]app.DeploymentRecord{deployRecord}, a.Deployments...)
 	// Keep only last 10 deployments
@@ -4378,6 +4984,12 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.storage.UpdateApp(a)
+	s.recordDeployMarker(a, deployRecord, "deploy")
+
+	if verifyErr != nil {
+		s.failVerifiedDeploy(ctx, a, deployConfig.Verify, verifyErr, writeLine)
+		return
+	}
 
 	// Log activity
 	s.logActivity("system", "deploy", "app", a.ID, a.Name, "success", "")
@@ -4390,130 +5002,26 @@ func (s *Server) handleSourceDeploy(w http.ResponseWriter, r *http.Request) {
 
 	// Configure Caddy if domain is set
 	if a.Domain != "" && s.caddy != nil {
+		stream.startPhase(DeployPhaseRoute)
 		writeLine("Configuring routing for: " + a.Domain)
-		_ = s.caddy.AddRoute(caddy.Route{
-			ID:        "basepod-" + a.Name,
-			Domain:    a.Domain,
-			Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-			EnableSSL: a.SSL.Enabled,
-		})
+		_ = s.caddy.AddRoute(AppRoute(a, a.Domain))
 
 		// Add routes for domain aliases
 		for _, alias := range a.Aliases {
 			writeLine("Configuring alias: " + alias)
-			_ = s.caddy.AddRoute(caddy.Route{
-				ID:        fmt.Sprintf("alias-%s-%s", a.ID[:8], alias),
-				Domain:    alias,
-				Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-				EnableSSL: a.SSL.Enabled,
-			})
+			_ = s.caddy.AddRoute(AppRoute(a, alias))
 		}
 	}
 
 	writeLine("")
 	writeLine("Deploy complete!")
 	writeLine("App: " + a.Name)
+	appURL := ""
 	if a.Domain != "" {
-		writeLine("URL: https://" + a.Domain)
-	}
-}
-
-// generateDockerfile auto-generates a Dockerfile based on detected project stack
-func generateDockerfile(sourceDir string, port int) string {
-	if port == 0 {
-		port = 8080
-	}
-
-	// Node.js (package.json)
-	if _, err := os.Stat(sourceDir + "/package.json"); err == nil {
-		// Check for package-lock.json vs yarn.lock
-		installCmd := "npm install"
-		lockCopy := "COPY package*.json ./"
-		if _, err := os.Stat(sourceDir + "/yarn.lock"); err == nil {
-			installCmd = "yarn install --frozen-lockfile"
-			lockCopy = "COPY package.json yarn.lock ./"
-		} else if _, err := os.Stat(sourceDir + "/pnpm-lock.yaml"); err == nil {
-			installCmd = "corepack enable && pnpm install --frozen-lockfile"
-			lockCopy = "COPY package.json pnpm-lock.yaml ./"
-		}
-		return fmt.Sprintf(`FROM node:20-alpine
-WORKDIR /app
-%s
-RUN %s
-COPY . .
-RUN npm run build 2>/dev/null || true
-EXPOSE %d
-CMD ["npm", "start"]
-`, lockCopy, installCmd, port)
-	}
-
-	// Go (go.mod)
-	if _, err := os.Stat(sourceDir + "/go.mod"); err == nil {
-		return fmt.Sprintf(`FROM golang:1.23-alpine AS builder
-WORKDIR /app
-COPY go.mod go.sum ./
-RUN go mod download
-COPY . .
-RUN CGO_ENABLED=0 go build -o /app/server .
-
-FROM alpine:3.19
-WORKDIR /app
-COPY --from=builder /app/server .
-EXPOSE %d
-CMD ["./server"]
-`, port)
-	}
-
-	// Python (requirements.txt or pyproject.toml)
-	if _, err := os.Stat(sourceDir + "/requirements.txt"); err == nil {
-		return fmt.Sprintf(`FROM python:3.12-slim
-WORKDIR /app
-COPY requirements.txt .
-RUN pip install --no-cache-dir -r requirements.txt
-COPY . .
-EXPOSE %d
-CMD ["python", "app.py"]
-`, port)
-	}
-	if _, err := os.Stat(sourceDir + "/pyproject.toml"); err == nil {
-		return fmt.Sprintf(`FROM python:3.12-slim
-WORKDIR /app
-COPY pyproject.toml .
-RUN pip install --no-cache-dir .
-COPY . .
-EXPOSE %d
-CMD ["python", "-m", "app"]
-`, port)
-	}
-
-	// Ruby (Gemfile)
-	if _, err := os.Stat(sourceDir + "/Gemfile"); err == nil {
-		return fmt.Sprintf(`FROM ruby:3.3-slim
-WORKDIR /app
-COPY Gemfile Gemfile.lock ./
-RUN bundle install
-COPY . .
-EXPOSE %d
-CMD ["ruby", "app.rb"]
-`, port)
-	}
-
-	// Rust (Cargo.toml)
-	if _, err := os.Stat(sourceDir + "/Cargo.toml"); err == nil {
-		return fmt.Sprintf(`FROM rust:1.77-slim AS builder
-WORKDIR /app
-COPY . .
-RUN cargo build --release
-
-FROM debian:bookworm-slim
-WORKDIR /app
-COPY --from=builder /app/target/release/* /app/
-EXPOSE %d
-CMD ["./app"]
-`, port)
+		appURL = "https://" + a.Domain
+		writeLine("URL: " + appURL)
 	}
-
-	return ""
+	stream.succeed(a.Name, appURL)
 }
 
 // execCommand executes a command and returns output
@@ -4661,37 +5169,6 @@ func (s *Server) handleAppAccessLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	paths, _ := config.GetPaths()
-	// Read from caddy.err which contains access logs (Caddy writes all logs to stderr)
-	logFile := fmt.Sprintf("%s/logs/caddy.err", paths.Base)
-
-	// Read log file
-	file, err := os.Open(logFile)
-	if err != nil {
-		jsonResponse(w, http.StatusOK, map[string]interface{}{
-			"logs":    []interface{}{},
-			"message": "No access logs yet",
-		})
-		return
-	}
-	defer file.Close()
-
-	// Get file size and seek to last 5MB if large (caddy.err has all logs, need more to find access entries)
-	stat, _ := file.Stat()
-	if stat.Size() > 5*1024*1024 {
-		file.Seek(-5*1024*1024, 2) // Last 5MB
-	}
-
-	// Collect domains to filter by (primary + aliases)
-	domains := map[string]bool{a.Domain: true}
-	for _, alias := range a.Aliases {
-		domains[alias] = true
-	}
-
-	// Parse and filter log lines
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-
 	limit := 100
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 500 {
@@ -4699,36 +5176,34 @@ func (s *Server) handleAppAccessLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var logs []map[string]interface{}
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
-
-		var entry map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			continue
-		}
-
-		// Only process access log entries (skip Caddy operational logs)
-		logger, _ := entry["logger"].(string)
-		if !strings.Contains(logger, "http.log.access") {
-			continue
-		}
+	// Collect domains to filter by (primary + aliases)
+	domains := map[string]bool{}
+	for _, d := range appDomains(a) {
+		domains[d] = true
+	}
+	anonymize := a.AnonymizesIPs(s.config.Privacy.AnonymizeIPs)
 
-		// Filter by domain - check request.host
-		reqMap, ok := entry["request"].(map[string]interface{})
-		if !ok {
-			continue
-		}
-		host, _ := reqMap["host"].(string)
-		if !domains[host] {
-			continue
+	// Read the tail of access.log; before it existed Caddy wrote access logs to
+	// caddy.err along with everything else, so read more of that
+	var logs []map[string]interface{}
+	collect := func(entry map[string]interface{}) {
+		if anonymize {
+			anonymizeAccessEntry(entry)
 		}
-
 		logs = append(logs, entry)
 	}
+	since := time.Now().Add(-s.accessLogRetention())
+	err = scanAccessLog(filepath.Join(accessLogDir(), "access.log"), domains, since, 2*1024*1024, collect)
+	if os.IsNotExist(err) {
+		err = scanAccessLog(filepath.Join(accessLogDir(), "caddy.err"), domains, since, 5*1024*1024, collect)
+	}
+	if os.IsNotExist(err) {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"logs":    []interface{}{},
+			"message": "No access logs yet",
+		})
+		return
+	}
 
 	// Return last N entries
 	if len(logs) > limit {
@@ -4837,6 +5312,7 @@ func (s *Server) checkAppHealth(a *app.App) *app.HealthStatus {
 	s.healthStatesMu.Lock()
 	defer s.healthStatesMu.Unlock()
 
+	previous := hs.Status
 	hs.TotalChecks++
 	hs.LastCheck = time.Now()
 
@@ -4859,6 +5335,9 @@ func (s *Server) checkAppHealth(a *app.App) *app.HealthStatus {
 			hs.Status = "unhealthy"
 		}
 	}
+	if hs.Status != previous {
+		s.events.publish(AppEvent{Type: "health", AppID: a.ID, AppName: a.Name, Health: hs.Status, Reason: hs.LastError})
+	}
 
 	// Auto-restart if configured
 	maxFailures := hc.MaxFailures
@@ -4894,17 +5373,12 @@ func (s *Server) restartAppForHealth(a *app.App) {
 	_ = s.podman.StopContainer(ctx, containerName, 10)
 	_ = s.podman.RemoveContainer(ctx, containerName, true)
 
-	volumeMounts := []string{}
-	for _, v := range a.Volumes {
-		if v.HostPath != "" && v.ContainerPath != "" {
-			volumeMounts = append(volumeMounts, fmt.Sprintf("%s:%s", v.HostPath, v.ContainerPath))
-		}
-	}
+	volumeMounts := s.appVolumeMounts(ctx, a)
 
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     containerName,
 		Image:    a.Image,
-		Env:      a.Env,
+		Env:      s.containerEnv(a),
 		Networks: []string{"basepod"},
 		Volumes:  volumeMounts,
 		Ports: map[string]string{
@@ -4914,16 +5388,18 @@ func (s *Server) restartAppForHealth(a *app.App) {
 			"basepod.app":    a.Name,
 			"basepod.app.id": a.ID,
 		},
-		Memory: a.Resources.Memory,
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
 		log.Printf("Health check restart failed for %s: %v", a.Name, err)
+		s.logActivity("system", "health_restart", "app", a.ID, a.Name, "failed", err.Error())
 		return
 	}
 
 	if err := s.podman.StartContainer(ctx, containerID); err != nil {
 		log.Printf("Health check restart failed to start %s: %v", a.Name, err)
+		s.logActivity("system", "health_restart", "app", a.ID, a.Name, "failed", err.Error())
 		return
 	}
 
@@ -4932,14 +5408,19 @@ func (s *Server) restartAppForHealth(a *app.App) {
 		a.Status = app.StatusFailed
 		s.storage.UpdateApp(a)
 		log.Printf("Health check restart failed waiting for %s readiness: %v", a.Name, err)
+		s.logActivity("system", "health_restart", "app", a.ID, a.Name, "failed", err.Error())
 		return
 	}
 
 	a.Status = app.StatusRunning
 	s.storage.UpdateApp(a)
 	log.Printf("Health check: successfully restarted app %s", a.Name)
+	s.logActivity("system", "health_restart", "app", a.ID, a.Name, "success", "restarted after failing health checks")
 }
 
+// reconcileDelay lets Podman finish initializing before reconcileContainers runs
+var reconcileDelay = 5 * time.Second
+
 // reconcileContainers checks all apps marked as "running" in the DB and restarts
 // any whose containers are not actually running in Podman. This recovers from
 // situations like host reboots where containers stop but the DB state is stale.
@@ -4948,8 +5429,7 @@ func (s *Server) reconcileContainers() {
 		return
 	}
 
-	// Brief delay to let Podman finish initializing
-	time.Sleep(5 * time.Second)
+	time.Sleep(reconcileDelay)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
@@ -4974,6 +5454,8 @@ func (s *Server) reconcileContainers() {
 		}
 	}
 
+	s.removeDrainingContainers(ctx, apps)
+
 	restarted := 0
 	failed := 0
 	for i := range apps {
@@ -4987,6 +5469,10 @@ func (s *Server) reconcileContainers() {
 
 		containerName := "basepod-" + a.Name
 		if runningContainers[a.ContainerID] || runningContainers[containerName] {
+			if err := s.syncReplicas(ctx, a, false); err != nil {
+				log.Printf("Reconcile: %s: %v", a.Name, err)
+			}
+			s.reconcileCanary(ctx, a, runningContainers)
 			continue // already running
 		}
 
@@ -4999,17 +5485,12 @@ func (s *Server) reconcileContainers() {
 		_ = s.podman.RemoveContainer(ctx, containerName, true)
 
 		// Build volume mounts
-		volumeMounts := []string{}
-		for _, v := range a.Volumes {
-			if v.HostPath != "" && v.ContainerPath != "" {
-				volumeMounts = append(volumeMounts, fmt.Sprintf("%s:%s", v.HostPath, v.ContainerPath))
-			}
-		}
+		volumeMounts := s.appVolumeMounts(ctx, a)
 
 		containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 			Name:     containerName,
 			Image:    a.Image,
-			Env:      a.Env,
+			Env:      s.containerEnv(a),
 			Networks: []string{"basepod"},
 			Volumes:  volumeMounts,
 			Ports: map[string]string{
@@ -5019,7 +5500,7 @@ func (s *Server) reconcileContainers() {
 				"basepod.app":    a.Name,
 				"basepod.app.id": a.ID,
 			},
-			Memory: a.Resources.Memory,
+			Memory: a.Resources.MemoryBytes(),
 			CPUs:   a.Resources.CPUs,
 		})
 		if err != nil {
@@ -5043,6 +5524,9 @@ func (s *Server) reconcileContainers() {
 			continue
 		}
 
+		if err := s.syncReplicas(ctx, a, false); err != nil {
+			log.Printf("Reconcile: %s: %v", a.Name, err)
+		}
 		s.storage.UpdateApp(a)
 		restarted++
 		log.Printf("Reconcile: successfully restarted app %s", a.Name)
@@ -5406,6 +5890,11 @@ func (s *Server) handleMLXRun(w http.ResponseWriter, r *http.Request) {
 
 	svc := mlx.GetService()
 	if err := svc.Run(req.Model); err != nil {
+		var memErr *mlx.MemoryError
+		if errors.As(err, &memErr) {
+			errorResponse(w, http.StatusConflict, err.Error())
+			return
+		}
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -5474,14 +5963,7 @@ func (s *Server) handleMLXTranscribe(w http.ResponseWriter, r *http.Request) {
 	svc := mlx.GetService()
 
 	// Find a downloaded Whisper model (must contain "whisper" in the ID)
-	models := svc.ListModels()
-	var whisperModel string
-	for _, m := range models {
-		if m.Category == "speech" && !m.DownloadedAt.IsZero() && strings.Contains(strings.ToLower(m.ID), "whisper") {
-			whisperModel = m.ID
-			break
-		}
-	}
+	whisperModel := findWhisperModel("")
 
 	if whisperModel == "" {
 		errorResponse(w, http.StatusBadRequest, "No Whisper model downloaded. Download a Whisper model from the LLMs page for voice transcription.")
@@ -5733,6 +6215,8 @@ func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
 		IncludeVolumes bool   `json:"include_volumes"`
 		IncludeBuilds  bool   `json:"include_builds"`
 		OutputDir      string `json:"output_dir"`
+		Upload         bool   `json:"upload"` // Copy it to a backup target afterwards
+		Target         string `json:"target"` // Backup target to upload to (default: the first)
 	}
 	// Set defaults
 	req.IncludeVolumes = true
@@ -5746,6 +6230,17 @@ func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
 		IncludeBuilds:  req.IncludeBuilds,
 		OutputDir:      req.OutputDir,
 	}
+	var remote backup.Remote
+	var target config.BackupTarget
+	if req.Upload {
+		var status int
+		var err error
+		if remote, target, status, err = s.backupRemote(req.Target); err != nil {
+			errorResponse(w, status, err.Error())
+			return
+		}
+		http.NewResponseController(w).SetWriteDeadline(time.Time{}) // Uploads can outlast the write timeout
+	}
 
 	// Create backup
 	b, err := s.backup.Create(ctx, opts)
@@ -5753,6 +6248,15 @@ func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, "Failed to create backup: "+err.Error())
 		return
 	}
+	uploadedTo := ""
+	if remote != nil {
+		if err := s.backup.Upload(ctx, b.ID, remote); err != nil {
+			errorResponse(w, http.StatusBadGateway, fmt.Sprintf("Backup %s was created but not uploaded to %s: %v", b.ID, target.Name, err))
+			return
+		}
+		uploadedTo = target.Name
+		s.logActivity("user", "upload_backup", "backup", b.ID, b.ID, "success", target.Name)
+	}
 
 	// Ensure arrays are never null
 	contents := b.Contents
@@ -5764,12 +6268,13 @@ func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"id":         b.ID,
-		"created_at": b.CreatedAt,
-		"size":       b.Size,
-		"size_human": backup.FormatSize(b.Size),
-		"path":       b.Path,
-		"contents":   contents,
+		"id":          b.ID,
+		"created_at":  b.CreatedAt,
+		"size":        b.Size,
+		"size_human":  backup.FormatSize(b.Size),
+		"path":        b.Path,
+		"contents":    contents,
+		"uploaded_to": uploadedTo,
 	})
 }
 
@@ -5865,10 +6370,11 @@ func (s *Server) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
 
 	// Parse options from request body (optional)
 	var req struct {
-		RestoreDatabase bool `json:"restore_database"`
-		RestoreConfig   bool `json:"restore_config"`
-		RestoreApps     bool `json:"restore_apps"`
-		RestoreVolumes  bool `json:"restore_volumes"`
+		RestoreDatabase bool   `json:"restore_database"`
+		RestoreConfig   bool   `json:"restore_config"`
+		RestoreApps     bool   `json:"restore_apps"`
+		RestoreVolumes  bool   `json:"restore_volumes"`
+		From            string `json:"from"` // Backup target to download the backup from first
 	}
 	// Set defaults - restore everything
 	req.RestoreDatabase = true
@@ -5886,6 +6392,20 @@ func (s *Server) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
 		RestoreVolumes:  req.RestoreVolumes,
 	}
 
+	// A backup on a target is downloaded first, e.g. to rebuild a fresh server
+	if req.From != "" {
+		remote, _, status, err := s.backupRemote(req.From)
+		if err != nil {
+			errorResponse(w, status, err.Error())
+			return
+		}
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		if _, err := s.backup.Fetch(ctx, remote, id); err != nil {
+			errorResponse(w, http.StatusBadGateway, fmt.Sprintf("Failed to download backup %s from %s: %v", id, req.From, err))
+			return
+		}
+	}
+
 	// Perform restore
 	result, err := s.backup.Restore(ctx, id, opts)
 	if err != nil {
@@ -5967,7 +6487,9 @@ var wsUpgrader = websocket.Upgrader{
 	},
 }
 
-// handleTerminal provides WebSocket-based terminal access to a container
+// handleTerminal provides WebSocket-based terminal access to a container.
+// Text messages "resize:<cols>,<rows>" resize the TTY; everything else is
+// input. The close frame's reason is "exit <code>" once the command ends.
 func (s *Server) handleTerminal(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := r.PathValue("id")
@@ -5997,10 +6519,9 @@ func (s *Server) handleTerminal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create exec session - try bash, fall back to sh
-	execID, err := s.podman.ExecCreate(ctx, a.ContainerID, []string{
-		"/bin/sh", "-c", "command -v bash >/dev/null && exec bash || exec sh",
-	})
+	// Create exec session for the requested command (bp exec), or a shell
+	cmd := terminalCommand(r.URL.Query())
+	execID, err := s.podman.ExecCreate(ctx, a.ContainerID, cmd)
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, "failed to create exec session: "+err.Error())
 		return
@@ -6049,6 +6570,18 @@ func (s *Server) handleTerminal(w http.ResponseWriter, r *http.Request) {
 		wsConn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Error: exec start failed (status %d): %s", resp.StatusCode, string(body))))
 		return
 	}
+	details := "shell"
+	if r.URL.Query().Get("cmd") != "" {
+		details = strings.Join(cmd, " ")
+	}
+	s.logActivity("user", "exec", "app", a.ID, a.Name, "success", details)
+
+	// Size the TTY before the command draws anything
+	cols, _ := strconv.Atoi(r.URL.Query().Get("cols"))
+	rows, _ := strconv.Atoi(r.URL.Query().Get("rows"))
+	if cols > 0 && rows > 0 {
+		_ = s.podman.ExecResize(ctx, execID, rows, cols)
+	}
 
 	// At this point, conn is the raw bidirectional stream to the exec session.
 	// Any buffered data from br needs to be handled too.
@@ -6102,6 +6635,30 @@ func (s *Server) handleTerminal(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	<-done
+
+	// Report the exit code in the close frame, e.g. for bp exec to exit with
+	reason := "exit"
+	for i := 0; i < 10; i++ {
+		inspect, err := s.podman.ExecInspect(context.Background(), execID)
+		if err != nil {
+			break
+		}
+		if !inspect.Running {
+			reason = fmt.Sprintf("exit %d", inspect.ExitCode)
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	wsConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason), time.Now().Add(time.Second))
+}
+
+// terminalCommand is the command a terminal session runs: the cmd query
+// values as arguments, or a shell - bash if the image has it, otherwise sh
+func terminalCommand(q url.Values) []string {
+	if cmd := q["cmd"]; len(cmd) > 0 && cmd[0] != "" {
+		return cmd
+	}
+	return []string{"/bin/sh", "-c", "command -v bash >/dev/null && exec bash || exec sh"}
 }
 
 // validateGitHubSignature validates the HMAC-SHA256 signature from GitHub webhooks
@@ -6135,6 +6692,7 @@ func (s *Server) handleWebhookSetup(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		GitURL string `json:"git_url"`
+		Branch string `json:"branch"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
@@ -6156,6 +6714,9 @@ func (s *Server) handleWebhookSetup(w http.ResponseWriter, r *http.Request) {
 	a.Deployment.GitURL = req.GitURL
 	a.Deployment.WebhookSecret = secret
 	a.Deployment.AutoDeploy = true
+	if req.Branch != "" {
+		a.Deployment.Branch = req.Branch
+	}
 	if a.Deployment.Branch == "" {
 		a.Deployment.Branch = "main"
 	}
@@ -6175,6 +6736,8 @@ func (s *Server) handleWebhookSetup(w http.ResponseWriter, r *http.Request) {
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"webhook_url": webhookURL,
+		"github_url":  requestBaseURL(r) + "/api/hooks/github?app=" + url.QueryEscape(a.Name),
+		"gitlab_url":  requestBaseURL(r) + "/api/hooks/gitlab?app=" + url.QueryEscape(a.Name),
 		"secret":      secret,
 		"branch":      a.Deployment.Branch,
 	})
@@ -6259,88 +6822,21 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse push payload
-	var payload struct {
-		Ref        string `json:"ref"`
-		HeadCommit struct {
-			ID      string `json:"id"`
-			Message string `json:"message"`
-		} `json:"head_commit"`
-	}
-	if err := json.Unmarshal(body, &payload); err != nil {
+	push, err := parseGitHubPush(body)
+	if err != nil {
 		errorResponse(w, http.StatusBadRequest, "Failed to parse payload")
 		return
 	}
-
-	// Extract branch from ref (refs/heads/main -> main)
-	branch := strings.TrimPrefix(payload.Ref, "refs/heads/")
-	commitHash := payload.HeadCommit.ID
-	if len(commitHash) > 7 {
-		commitHash = commitHash[:7]
-	}
-	commitMsg := payload.HeadCommit.Message
-	// Truncate commit message to first line
-	if idx := strings.Index(commitMsg, "\n"); idx > 0 {
-		commitMsg = commitMsg[:idx]
-	}
-
-	// Check branch matches
-	if branch != a.Deployment.Branch {
-		delivery := &app.WebhookDelivery{
-			ID:        deliveryID,
-			AppID:     a.ID,
-			Event:     "push",
-			Branch:    branch,
-			Commit:    commitHash,
-			Message:   commitMsg,
-			Status:    "skipped",
-			Error:     fmt.Sprintf("Branch %s does not match configured branch %s", branch, a.Deployment.Branch),
-			CreatedAt: time.Now(),
-		}
-		s.storage.SaveWebhookDelivery(delivery)
-		jsonResponse(w, http.StatusOK, map[string]string{"status": "skipped", "reason": "branch mismatch"})
-		return
-	}
-
-	// Check auto_deploy is enabled
-	if !a.Deployment.AutoDeploy {
-		delivery := &app.WebhookDelivery{
-			ID:        deliveryID,
-			AppID:     a.ID,
-			Event:     "push",
-			Branch:    branch,
-			Commit:    commitHash,
-			Message:   commitMsg,
-			Status:    "skipped",
-			Error:     "Auto-deploy is disabled",
-			CreatedAt: time.Now(),
-		}
-		s.storage.SaveWebhookDelivery(delivery)
-		jsonResponse(w, http.StatusOK, map[string]string{"status": "skipped", "reason": "auto_deploy disabled"})
+	status, reason := s.triggerPushDeploy(a, "push", push)
+	if status == "skipped" {
+		jsonResponse(w, http.StatusOK, map[string]string{"status": "skipped", "reason": reason})
 		return
 	}
-
-	// Save delivery as deploying
-	delivery := &app.WebhookDelivery{
-		ID:        deliveryID,
-		AppID:     a.ID,
-		Event:     "push",
-		Branch:    branch,
-		Commit:    commitHash,
-		Message:   commitMsg,
-		Status:    "deploying",
-		CreatedAt: time.Now(),
-	}
-	s.storage.SaveWebhookDelivery(delivery)
-
-	// Start async deploy
-	go s.deployFromGit(a, commitHash, commitMsg, branch, deliveryID)
-
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "deploying"})
 }
 
 // deployFromGit clones a git repo and builds+deploys the app
-func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, deliveryID string) {
+func (s *Server) deployFromGit(a *app.App, gitURL, commitHash, commitMsg, branch, deliveryID string) {
 	ctx := context.Background()
 	var buildLog strings.Builder
 
@@ -6359,7 +6855,6 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 	os.RemoveAll(sourceDir)
 
 	// Clone the repo
-	gitURL := a.Deployment.GitURL
 	log.Printf("Webhook deploy %s: cloning %s branch %s", a.Name, gitURL, branch)
 
 	output, err := execCommand(ctx, "git", "clone", "--depth", "1", "--branch", branch, gitURL, sourceDir)
@@ -6372,11 +6867,21 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 	}
 
 	// Read .basepod config if present
+	var buildArgs map[string]string
+	var requireLockfile bool
 	basepodCfgPath := sourceDir + "/basepod.yaml"
 	if cfgData, err := os.ReadFile(basepodCfgPath); err == nil {
 		var repoCfg struct {
-			Dockerfile string `yaml:"dockerfile" json:"dockerfile"`
-			Port       int    `yaml:"port" json:"port"`
+			Dockerfile      string            `yaml:"dockerfile" json:"dockerfile"`
+			Port            int               `yaml:"port" json:"port"`
+			BuildArgs       map[string]string `yaml:"build_args" json:"build_args"`
+			RequireLockfile bool              `yaml:"require_lockfile" json:"require_lockfile"`
+			Build           struct {
+				RequireLockfile bool `yaml:"require_lockfile" json:"require_lockfile"`
+			} `yaml:"build" json:"build"`
+			SEO      *seoFileConfig           `yaml:"seo" json:"seo"`
+			Protocol string                   `yaml:"protocol" json:"protocol"`
+			Jobs     map[string]app.JobConfig `yaml:"jobs" json:"jobs"`
 		}
 		if err := yaml.Unmarshal(cfgData, &repoCfg); err != nil {
 			_ = json.Unmarshal(cfgData, &repoCfg)
@@ -6387,8 +6892,29 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 		if repoCfg.Port > 0 && a.Ports.ContainerPort == 0 {
 			a.Ports.ContainerPort = repoCfg.Port
 		}
+		buildArgs = repoCfg.BuildArgs
+		requireLockfile = repoCfg.RequireLockfile || repoCfg.Build.RequireLockfile
+		if seo := repoCfg.SEO.appConfig(); seo != nil && validateSEO(seo) == nil {
+			a.SEO = seo
+		}
+		if repoCfg.Protocol != "" && validateProtocol(a, repoCfg.Protocol) == nil {
+			a.Ports.Protocol = repoCfg.Protocol
+		}
+		if _, err := s.syncConfigJobs(a, repoCfg.Jobs); err != nil {
+			log.Printf("Webhook deploy %s: jobs not updated: %v", a.Name, err)
+		}
 		log.Printf("Webhook deploy %s: found basepod.yaml config", a.Name)
 	}
+	for _, warning := range s.updateAppDocs(a, sourceDir, s.containerEnv(a)) {
+		log.Printf("Webhook deploy %s: %s", a.Name, warning)
+	}
+	if requireLockfile {
+		if err := checkLockfiles(sourceDir, nil); err != nil {
+			log.Printf("Webhook deploy %s: %v", a.Name, err)
+			s.storage.UpdateWebhookDeliveryStatus(deliveryID, "failed", err.Error())
+			return
+		}
+	}
 
 	// Check for Dockerfile
 	dockerfile := "Dockerfile"
@@ -6413,7 +6939,13 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 		if port == 0 {
 			port = 8080
 		}
-		generated := generateDockerfile(sourceDir, port)
+		generated, err := s.generateDockerfile(sourceDir, port, "")
+		if err != nil {
+			errMsg := "Failed to generate Dockerfile: " + err.Error()
+			log.Printf("Webhook deploy %s: %s", a.Name, errMsg)
+			s.storage.UpdateWebhookDeliveryStatus(deliveryID, "failed", errMsg)
+			return
+		}
 		if generated == "" {
 			errMsg := "No Dockerfile found and could not auto-detect project type"
 			log.Printf("Webhook deploy %s: %s", a.Name, errMsg)
@@ -6447,10 +6979,42 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 		}
 	}
 
-	output, err = execCommandDir(ctx, sourceDir, podmanPath, "build", "-t", imageName, "-t", imageLatest, "-f", dockerfileRel, ".")
-	buildLog.WriteString("$ " + podmanPath + " build -t " + imageName + " -t " + imageLatest + " -f " + dockerfileRel + " .\n" + output + "\n")
+	buildCtx, releaseBuild, err := s.acquireBuildSlot(ctx, BuildInfo{AppID: a.ID, AppName: a.Name, Source: "git"}, func(line string) {
+		buildLog.WriteString(line + "\n")
+	})
+	if err != nil {
+		log.Printf("Webhook deploy %s: %v", a.Name, err)
+		a.Status = app.StatusFailed
+		s.storage.UpdateApp(a)
+		s.storage.UpdateWebhookDeliveryStatus(deliveryID, "failed", err.Error())
+		return
+	}
+	buildCmd := append([]string{"build", "-t", imageName, "-t", imageLatest, "-f", dockerfileRel}, buildArgFlags(buildArgs)...)
+	buildCmd = append(buildCmd, ".")
+	output, err = execCommandDir(buildCtx, sourceDir, podmanPath, buildCmd...)
+	releaseBuild()
+	buildLog.WriteString("$ " + podmanPath + " " + strings.Join(buildCmd, " ") + "\n" + output + "\n")
+	if err != nil {
+		errMsg := fmt.Sprintf("Build failed: %v\n%s", err, output)
+		log.Printf("Webhook deploy %s: %s", a.Name, errMsg)
+		a.Status = app.StatusFailed
+		s.storage.UpdateApp(a)
+		s.storage.UpdateWebhookDeliveryStatus(deliveryID, "failed", errMsg)
+		return
+	}
+	imageReport, err := s.inspectImageReport(ctx, imageName)
+	if err == nil {
+		buildLog.WriteString(strings.Join(formatImageReport(imageReport, previousImageReport(a.Deployments)), "\n") + "\n")
+	}
+	provenance := s.buildProvenance(ctx, podmanPath, sourceDir, dockerfileRel, buildArgs)
+	provenance.GitCommit = commitHash
+	registryImage := s.pushBuild(ctx, podmanPath, a, imageName, commitHash, deployTag, func(line string) {
+		buildLog.WriteString(line + "\n")
+	})
+
+	snapshotID, err := s.preDeploySnapshot(ctx, a)
 	if err != nil {
-		errMsg := fmt.Sprintf("Build failed: %v\n%s", err, output)
+		errMsg := "Pre-deploy snapshot failed: " + err.Error()
 		log.Printf("Webhook deploy %s: %s", a.Name, errMsg)
 		a.Status = app.StatusFailed
 		s.storage.UpdateApp(a)
@@ -6458,9 +7022,12 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 		return
 	}
 
-	// Remove old container
+	// Keep the old container serving until the new one is ready, or remove it
+	// for apps deployed with recreate
 	containerName := "basepod-" + a.Name
-	if a.ContainerID != "" {
+	handoff := s.beginDrain(ctx, a)
+	defer s.finishDrain(a, handoff)
+	if a.ContainerID != "" && handoff == nil {
 		_ = s.podman.StopContainer(ctx, a.ContainerID, 10)
 		_ = s.podman.RemoveContainer(ctx, a.ContainerID, true)
 	}
@@ -6473,17 +7040,13 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 	}
 
 	// Build volume mounts
-	volumeMounts := []string{}
-	for _, v := range a.Volumes {
-		volumeName := fmt.Sprintf("basepod-%s-%s", a.Name, v.Name)
-		volumeMounts = append(volumeMounts, fmt.Sprintf("%s:%s", volumeName, v.ContainerPath))
-	}
+	volumeMounts := s.appVolumeMounts(ctx, a)
 
 	// Create new container
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     containerName,
 		Image:    imageName,
-		Env:      a.Env,
+		Env:      s.containerEnv(a),
 		Networks: []string{"basepod"},
 		Volumes:  volumeMounts,
 		Ports: map[string]string{
@@ -6493,7 +7056,7 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 			"basepod.app":    a.Name,
 			"basepod.app.id": a.ID,
 		},
-		Memory: a.Resources.Memory * 1024 * 1024,
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
@@ -6527,20 +7090,27 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 		s.storage.UpdateWebhookDeliveryStatus(deliveryID, "failed", errMsg)
 		return
 	}
+	if err := s.syncReplicas(ctx, a, true); err != nil {
+		log.Printf("Webhook deploy %s: %v", a.Name, err)
+	}
 
 	a.Status = app.StatusRunning
 	a.UpdatedAt = time.Now()
 
 	// Add deployment record
 	deployRecord := app.DeploymentRecord{
-		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
-		Image:      imageName,
-		CommitHash: commitHash,
-		CommitMsg:  commitMsg,
-		Branch:     branch,
-		Status:     "success",
-		BuildLog:   buildLog.String(),
-		DeployedAt: time.Now(),
+		ID:            fmt.Sprintf("%d", time.Now().UnixNano()),
+		Image:         imageName,
+		CommitHash:    commitHash,
+		CommitMsg:     commitMsg,
+		Branch:        branch,
+		Status:        "success",
+		BuildLog:      buildLog.String(),
+		ImageReport:   imageReport,
+		Provenance:    provenance,
+		SnapshotID:    snapshotID,
+		DeployedAt:    time.Now(),
+		RegistryImage: registryImage,
 	}
 	a.Deployments = append([]app.DeploymentRecord{deployRecord}, a.Deployments...)
 	if len(a.Deployments) > 10 {
@@ -6548,22 +7118,13 @@ func (s *Server) deployFromGit(a *app.App, commitHash, commitMsg, branch, delive
 	}
 
 	s.storage.UpdateApp(a)
+	s.recordDeployMarker(a, deployRecord, "deploy")
 
 	// Configure Caddy
 	if a.Domain != "" && s.caddy != nil {
-		_ = s.caddy.AddRoute(caddy.Route{
-			ID:        "basepod-" + a.Name,
-			Domain:    a.Domain,
-			Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-			EnableSSL: a.SSL.Enabled,
-		})
+		_ = s.caddy.AddRoute(AppRoute(a, a.Domain))
 		for _, alias := range a.Aliases {
-			_ = s.caddy.AddRoute(caddy.Route{
-				ID:        fmt.Sprintf("alias-%s-%s", a.ID[:8], alias),
-				Domain:    alias,
-				Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
-				EnableSSL: a.SSL.Enabled,
-			})
+			_ = s.caddy.AddRoute(AppRoute(a, alias))
 		}
 	}
 
@@ -6655,6 +7216,14 @@ func (s *Server) logActivity(actorType, action, targetType, targetID, targetName
 // --- Notification Dispatch ---
 
 func (s *Server) sendNotifications(event, appID, appName string, details map[string]string) {
+	switch event {
+	case "deploy_success":
+		s.prom.observeDeploy(appName, "success")
+	case "deploy_failed":
+		s.prom.observeDeploy(appName, "failed")
+	}
+	s.events.publish(AppEvent{Type: "notification", AppID: appID, AppName: appName, Event: event, Details: details})
+
 	configs, err := s.storage.ListNotificationConfigs(event, appID)
 	if err != nil || len(configs) == 0 {
 		return
@@ -6665,6 +7234,7 @@ func (s *Server) sendNotifications(event, appID, appName string, details map[str
 		"app_id":   appID,
 		"app_name": appName,
 		"details":  details,
+		"product":  s.config.Branding.ProductName(),
 		"time":     time.Now().UTC().Format(time.RFC3339),
 	})
 
@@ -6835,7 +7405,35 @@ func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
+	rollbackRecord, err := s.rollbackApp(r.Context(), a, targetDeploy)
+	if errors.Is(err, errAppNotReady) {
+		errorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.logActivity("user", "rollback", "app", a.ID, a.Name, "success", "")
+	s.sendNotifications("deploy_success", a.ID, a.Name, map[string]string{
+		"action": "rollback",
+		"image":  targetDeploy.Image,
+	})
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"message":    "Rollback successful",
+		"deployment": rollbackRecord,
+	})
+}
+
+// errAppNotReady is returned by rollbackApp when the container started but
+// the app didn't come up
+var errAppNotReady = errors.New("App did not become ready")
+
+// rollbackApp replaces an app's container with one from target's image and
+// records the rollback as its latest deployment
+func (s *Server) rollbackApp(ctx context.Context, a *app.App, target *app.DeploymentRecord) (app.DeploymentRecord, error) {
 	containerName := "basepod-" + a.Name
 
 	// Stop and remove current container
@@ -6847,17 +7445,33 @@ func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
 	_ = s.podman.RemoveContainer(ctx, containerName, true)
 
 	// Build volume mounts
-	volumeMounts := []string{}
-	for _, v := range a.Volumes {
-		volumeName := fmt.Sprintf("basepod-%s-%s", a.Name, v.Name)
-		volumeMounts = append(volumeMounts, fmt.Sprintf("%s:%s", volumeName, v.ContainerPath))
+	volumeMounts := s.appVolumeMounts(ctx, a)
+
+	// A pruned build image comes back from the registry copy
+	image := target.Image
+	if target.RegistryImage != "" {
+		if _, err := s.podman.ImageHistory(ctx, image); err != nil {
+			podmanPath := "podman"
+			if _, err := exec.LookPath("podman"); err != nil {
+				for _, p := range []string{"/opt/homebrew/bin/podman", "/usr/local/bin/podman"} {
+					if _, err := os.Stat(p); err == nil {
+						podmanPath = p
+						break
+					}
+				}
+			}
+			if err := s.registry.Pull(ctx, podmanPath, target.RegistryImage); err != nil {
+				return app.DeploymentRecord{}, fmt.Errorf("Image %s is gone and pulling it from the registry failed: %w", image, err)
+			}
+			image = target.RegistryImage
+		}
 	}
 
 	// Create new container from the rollback image
 	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
 		Name:     containerName,
-		Image:    targetDeploy.Image,
-		Env:      a.Env,
+		Image:    image,
+		Env:      s.containerEnv(a),
 		Networks: []string{"basepod"},
 		Volumes:  volumeMounts,
 		Ports: map[string]string{
@@ -6867,32 +7481,32 @@ func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
 			"basepod.app":    a.Name,
 			"basepod.app.id": a.ID,
 		},
-		Memory: a.Resources.Memory,
+		Memory: a.Resources.MemoryBytes(),
 		CPUs:   a.Resources.CPUs,
 	})
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to create container: "+err.Error())
 		a.Status = app.StatusFailed
 		s.storage.UpdateApp(a)
-		return
+		return app.DeploymentRecord{}, fmt.Errorf("Failed to create container: %w", err)
 	}
 
 	if err := s.podman.StartContainer(ctx, containerID); err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to start container: "+err.Error())
 		a.Status = app.StatusFailed
 		s.storage.UpdateApp(a)
-		return
+		return app.DeploymentRecord{}, fmt.Errorf("Failed to start container: %w", err)
 	}
 
 	// Update app
 	a.ContainerID = containerID
-	a.Image = targetDeploy.Image
+	a.Image = image
 	if err := s.waitForAppReadiness(ctx, a); err != nil {
-		errorResponse(w, http.StatusBadGateway, "App did not become ready: "+err.Error())
 		a.Status = app.StatusFailed
 		a.UpdatedAt = time.Now()
 		s.storage.UpdateApp(a)
-		return
+		return app.DeploymentRecord{}, fmt.Errorf("%w: %v", errAppNotReady, err)
+	}
+	if err := s.syncReplicas(ctx, a, true); err != nil {
+		log.Printf("Rollback %s: %v", a.Name, err)
 	}
 
 	a.Status = app.StatusRunning
@@ -6900,13 +7514,14 @@ func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
 
 	// Add rollback deployment record
 	rollbackRecord := app.DeploymentRecord{
-		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
-		Image:      targetDeploy.Image,
-		CommitHash: targetDeploy.CommitHash,
-		CommitMsg:  "Rollback to " + targetDeploy.ID,
-		Branch:     targetDeploy.Branch,
-		Status:     "success",
-		DeployedAt: time.Now(),
+		ID:            fmt.Sprintf("%d", time.Now().UnixNano()),
+		Image:         image,
+		CommitHash:    target.CommitHash,
+		CommitMsg:     "Rollback to " + target.ID,
+		Branch:        target.Branch,
+		Status:        "success",
+		DeployedAt:    time.Now(),
+		RegistryImage: target.RegistryImage,
 	}
 	a.Deployments = append([]app.DeploymentRecord{rollbackRecord}, a.Deployments...)
 	if len(a.Deployments) > 10 {
@@ -6914,17 +7529,9 @@ func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.storage.UpdateApp(a)
+	s.recordDeployMarker(a, rollbackRecord, "rollback")
 
-	s.logActivity("user", "rollback", "app", a.ID, a.Name, "success", "")
-	s.sendNotifications("deploy_success", a.ID, a.Name, map[string]string{
-		"action": "rollback",
-		"image":  targetDeploy.Image,
-	})
-
-	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"message":    "Rollback successful",
-		"deployment": rollbackRecord,
-	})
+	return rollbackRecord, nil
 }
 
 // --- Cron Job Handlers ---
@@ -6968,6 +7575,7 @@ func (s *Server) handleCreateCronJob(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Name     string `json:"name"`
 		Schedule string `json:"schedule"`
+		Action   string `json:"action"`
 		Command  string `json:"command"`
 		Enabled  *bool  `json:"enabled"`
 	}
@@ -6975,10 +7583,17 @@ func (s *Server) handleCreateCronJob(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-	if req.Name == "" || req.Schedule == "" || req.Command == "" {
-		errorResponse(w, http.StatusBadRequest, "name, schedule, and command are required")
+	if req.Schedule == "" {
+		errorResponse(w, http.StatusBadRequest, "schedule is required")
 		return
 	}
+	if req.Name == "" {
+		if req.Action == "" || req.Action == app.CronActionCommand {
+			errorResponse(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		req.Name = req.Action
+	}
 
 	enabled := true
 	if req.Enabled != nil {
@@ -6991,11 +7606,17 @@ func (s *Server) handleCreateCronJob(w http.ResponseWriter, r *http.Request) {
 		AppID:     a.ID,
 		Name:      req.Name,
 		Schedule:  req.Schedule,
+		Action:    req.Action,
 		Command:   req.Command,
 		Enabled:   enabled,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
+	if err := validateCronJob(job); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	job.NextRun = nextCronRun(job, now)
 
 	if err := s.storage.CreateCronJob(job); err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
@@ -7025,6 +7646,7 @@ func (s *Server) handleUpdateCronJob(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Name     *string `json:"name"`
 		Schedule *string `json:"schedule"`
+		Action   *string `json:"action"`
 		Command  *string `json:"command"`
 		Enabled  *bool   `json:"enabled"`
 	}
@@ -7039,12 +7661,22 @@ func (s *Server) handleUpdateCronJob(w http.ResponseWriter, r *http.Request) {
 	if req.Schedule != nil {
 		job.Schedule = *req.Schedule
 	}
+	if req.Action != nil {
+		job.Action = *req.Action
+	}
 	if req.Command != nil {
 		job.Command = *req.Command
 	}
 	if req.Enabled != nil {
 		job.Enabled = *req.Enabled
 	}
+	if err := validateCronJob(job); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Schedule != nil || req.Action != nil {
+		job.NextRun = nextCronRun(job, time.Now())
+	}
 
 	if err := s.storage.UpdateCronJob(job); err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
@@ -7095,54 +7727,12 @@ func (s *Server) handleRunCronJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if a.ContainerID == "" {
-		errorResponse(w, http.StatusBadRequest, "App has no running container")
-		return
-	}
-
-	// Execute the command in the container
-	ctx := context.Background()
-	execID, err := s.podman.ExecCreateDetached(ctx, a.ContainerID, []string{"/bin/sh", "-c", job.Command})
+	cronExec, err := s.executeCronJob(job, a, "user")
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to create exec: "+err.Error())
+		errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Record execution
-	cronExec := &app.CronExecution{
-		ID:        uuid.New().String(),
-		CronJobID: job.ID,
-		StartedAt: time.Now(),
-		Status:    "running",
-	}
-	s.storage.CreateCronExecution(cronExec)
-
-	// Start exec and capture output asynchronously
-	go func() {
-		output, cmdErr := s.podman.ExecStart(ctx, execID)
-		now := time.Now()
-		cronExec.EndedAt = &now
-		cronExec.Output = output
-		if cmdErr != nil {
-			cronExec.Status = "failed"
-			cronExec.ExitCode = 1
-		} else {
-			cronExec.Status = "success"
-			cronExec.ExitCode = 0
-		}
-		s.storage.UpdateCronExecution(cronExec)
-
-		// Update job last run info
-		job.LastRun = &now
-		job.LastStatus = cronExec.Status
-		if cronExec.Status == "failed" {
-			job.LastError = output
-		} else {
-			job.LastError = ""
-		}
-		s.storage.UpdateCronJob(job)
-	}()
-
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"message":      "Cron job started",
 		"execution_id": cronExec.ID,
@@ -7193,7 +7783,12 @@ func (s *Server) handleListActivity(w http.ResponseWriter, r *http.Request) {
 		offset = o
 	}
 
-	logs, err := s.storage.ListActivityLogsPaginated(targetID, action, limit, offset)
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	logs, err := s.storage.ListActivityLogsPaginated(targetID, action, sc.ids(), limit, offset)
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -7202,7 +7797,7 @@ func (s *Server) handleListActivity(w http.ResponseWriter, r *http.Request) {
 		logs = []app.ActivityLog{}
 	}
 
-	total, _ := s.storage.CountActivityLogs(targetID, action)
+	total, _ := s.storage.CountActivityLogs(targetID, action, sc.ids())
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"activities": logs,
@@ -7351,7 +7946,8 @@ func (s *Server) handleTestNotification(w http.ResponseWriter, r *http.Request)
 	payload, _ := json.Marshal(map[string]interface{}{
 		"event":    "test",
 		"app_name": "test-app",
-		"details":  map[string]string{"message": "This is a test notification from Basepod"},
+		"details":  map[string]string{"message": "This is a test notification from " + s.config.Branding.ProductName()},
+		"product":  s.config.Branding.ProductName(),
 		"time":     time.Now().UTC().Format(time.RFC3339),
 	})
 
@@ -7361,14 +7957,39 @@ func (s *Server) handleTestNotification(w http.ResponseWriter, r *http.Request)
 
 // --- Deploy Token Handlers ---
 
+// tokenManager returns the session of a caller managing deploy tokens and
+// whether they are an admin. Other users only manage tokens they created.
+func (s *Server) tokenManager(w http.ResponseWriter, r *http.Request) (*auth.Session, bool) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+	if session.UserRole == "admin" {
+		return session, true
+	}
+	if session.UserID == "" {
+		errorResponse(w, http.StatusForbidden, "Admin access required")
+		return nil, false
+	}
+	return session, false
+}
+
 func (s *Server) handleListDeployTokens(w http.ResponseWriter, r *http.Request) {
-	tokens, err := s.storage.ListDeployTokens()
+	session, admin := s.tokenManager(w, r)
+	if session == nil {
+		return
+	}
+	all, err := s.storage.ListDeployTokens()
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if tokens == nil {
-		tokens = []app.DeployToken{}
+	tokens := []app.DeployToken{}
+	for _, t := range all {
+		if admin || t.CreatedBy == session.UserID {
+			tokens = append(tokens, t)
+		}
 	}
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"tokens": tokens})
@@ -7376,8 +7997,9 @@ func (s *Server) handleListDeployTokens(w http.ResponseWriter, r *http.Request)
 
 func (s *Server) handleCreateDeployToken(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name   string   `json:"name"`
-		Scopes []string `json:"scopes"`
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn string   `json:"expires_in,omitempty"` // e.g. "2160h"; empty never expires
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
@@ -7387,10 +8009,54 @@ func (s *Server) handleCreateDeployToken(w http.ResponseWriter, r *http.Request)
 		errorResponse(w, http.StatusBadRequest, "name is required")
 		return
 	}
-	if len(req.Scopes) == 0 {
+	var expiresIn time.Duration
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			errorResponse(w, http.StatusBadRequest, "expires_in must be a positive duration, e.g. 2160h")
+			return
+		}
+		expiresIn = d
+	}
+	session, admin := s.tokenManager(w, r)
+	if session == nil {
+		return
+	}
+	if len(req.Scopes) == 0 && admin {
 		req.Scopes = []string{"deploy:*"}
 	}
 
+	// Other users can only make tokens for their own apps, e.g. for a
+	// client's CI
+	createdBy := ""
+	if !admin {
+		if len(req.Scopes) == 0 {
+			errorResponse(w, http.StatusBadRequest, "scopes are required, e.g. deploy:<app>")
+			return
+		}
+		sc, err := s.userScope(session.UserID, session.UserRole)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+			return
+		}
+		for _, scope := range req.Scopes {
+			scope = strings.TrimSpace(scope)
+			if scope == "mcp:read" || scope == "mcp:write" {
+				continue
+			}
+			name, ok := strings.CutPrefix(scope, "deploy:")
+			if !ok || name == "*" {
+				errorResponse(w, http.StatusForbidden, fmt.Sprintf("Scope %q requires an admin", scope))
+				return
+			}
+			if a, _ := s.resolveApp(name); a == nil || !sc.allows(a.ID) {
+				errorResponse(w, http.StatusForbidden, fmt.Sprintf("You don't have access to app %q", name))
+				return
+			}
+		}
+		createdBy = session.UserID
+	}
+
 	// Generate a random token
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
@@ -7412,8 +8078,13 @@ func (s *Server) handleCreateDeployToken(w http.ResponseWriter, r *http.Request)
 		TokenHash: tokenHash,
 		Prefix:    prefix,
 		Scopes:    req.Scopes,
+		CreatedBy: createdBy,
 		CreatedAt: now,
 	}
+	if expiresIn > 0 {
+		expiresAt := now.Add(expiresIn)
+		token.ExpiresAt = &expiresAt
+	}
 
 	if err := s.storage.CreateDeployToken(token); err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
@@ -7424,17 +8095,37 @@ func (s *Server) handleCreateDeployToken(w http.ResponseWriter, r *http.Request)
 
 	// Return the raw token only on creation
 	jsonResponse(w, http.StatusCreated, map[string]interface{}{
-		"id":      token.ID,
-		"name":    token.Name,
-		"token":   rawToken,
-		"prefix":  prefix,
-		"scopes":  token.Scopes,
-		"message": "Save this token - it won't be shown again",
+		"id":         token.ID,
+		"name":       token.Name,
+		"token":      rawToken,
+		"prefix":     prefix,
+		"scopes":     token.Scopes,
+		"expires_at": token.ExpiresAt,
+		"message":    "Save this token - it won't be shown again",
 	})
 }
 
 func (s *Server) handleDeleteDeployToken(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
+	session, admin := s.tokenManager(w, r)
+	if session == nil {
+		return
+	}
+	if !admin {
+		tokens, err := s.storage.ListDeployTokens()
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		owned := false
+		for _, t := range tokens {
+			owned = owned || (t.ID == id && t.CreatedBy == session.UserID)
+		}
+		if !owned {
+			errorResponse(w, http.StatusNotFound, "Deploy token not found")
+			return
+		}
+	}
 	if err := s.storage.DeleteDeployToken(id); err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -7515,21 +8206,24 @@ func (s *Server) handleAppMetrics(w http.ResponseWriter, r *http.Request) {
 		current, _ = s.podman.ContainerStats(r.Context(), a.ContainerID)
 	}
 
+	markers, _ := s.storage.ListDeployMarkers(a.ID, since, time.Time{})
+
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"app_id":  a.ID,
 		"period":  period,
 		"metrics": metrics,
+		"markers": markers,
 		"current": current,
 	})
 }
 
 // runMetricsCollector periodically collects container stats for all running apps
 func (s *Server) runMetricsCollector() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(s.metricsInterval())
 	defer ticker.Stop()
 
 	// Clean old metrics on startup
-	s.storage.CleanOldMetrics(time.Now().Add(-7 * 24 * time.Hour))
+	s.storage.CleanOldMetrics(time.Now().Add(-s.metricsRetention()))
 
 	for {
 		select {
@@ -7544,6 +8238,7 @@ func (s *Server) runMetricsCollector() {
 func (s *Server) collectMetrics() {
 	apps, _ := s.storage.ListApps()
 	ctx := context.Background()
+	requests := s.requests.count()
 
 	for _, a := range apps {
 		if a.ContainerID == "" || a.Status != app.StatusRunning {
@@ -7556,41 +8251,30 @@ func (s *Server) collectMetrics() {
 		}
 
 		metric := &app.AppMetric{
-			AppID:      a.ID,
-			CPUPercent: stats.CPUPercent,
-			MemUsage:   stats.MemUsage,
-			MemLimit:   stats.MemLimit,
-			NetInput:   stats.NetInput,
-			NetOutput:  stats.NetOutput,
-			RecordedAt: time.Now(),
-		}
+			AppID:       a.ID,
+			CPUPercent:  stats.CPUPercent,
+			MemUsage:    stats.MemUsage,
+			MemLimit:    stats.MemLimit,
+			NetInput:    stats.NetInput,
+			NetOutput:   stats.NetOutput,
+			BlockInput:  stats.BlockInput,
+			BlockOutput: stats.BlockOutput,
+			RecordedAt:  time.Now(),
+		}
+		hr := appRequests(&a, requests)
+		metric.Requests, metric.Errors = hr.Requests, hr.Errors
 		s.storage.SaveAppMetric(metric)
 	}
 
-	// Clean metrics older than 7 days periodically
-	s.storage.CleanOldMetrics(time.Now().Add(-7 * 24 * time.Hour))
+	// Drop metrics that have left the retention window
+	s.storage.CleanOldMetrics(time.Now().Add(-s.metricsRetention()))
 }
 
 // --- Database Provisioning ---
 
-// handleLinkDatabase links a database app to another app by injecting connection env vars
-func (s *Server) handleLinkDatabase(w http.ResponseWriter, r *http.Request) {
-	appID := r.PathValue("id")
-	dbID := r.PathValue("dbId")
-
-	a, err := s.resolveApp(appID)
-	if err != nil {
-		errorResponse(w, http.StatusNotFound, "App not found")
-		return
-	}
-
-	dbApp, err := s.resolveApp(dbID)
-	if err != nil {
-		errorResponse(w, http.StatusNotFound, "Database app not found")
-		return
-	}
-
-	// Generate connection string based on database type
+// databaseURL is the connection URL other apps use to reach a database app,
+// from its Postgres, MySQL or Redis env; empty if it has no env
+func databaseURL(dbApp *app.App) string {
 	connStr := ""
 	dbHost := fmt.Sprintf("basepod-%s", dbApp.Name)
 	dbPort := dbApp.Ports.ContainerPort
@@ -7625,6 +8309,27 @@ func (s *Server) handleLinkDatabase(w http.ResponseWriter, r *http.Request) {
 			connStr = fmt.Sprintf("%s:%d", dbHost, dbPort)
 		}
 	}
+	return connStr
+}
+
+// handleLinkDatabase links a database app to another app by injecting connection env vars
+func (s *Server) handleLinkDatabase(w http.ResponseWriter, r *http.Request) {
+	appID := r.PathValue("id")
+	dbID := r.PathValue("dbId")
+
+	a, err := s.resolveApp(appID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	dbApp, err := s.resolveApp(dbID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "Database app not found")
+		return
+	}
+
+	connStr := databaseURL(dbApp)
 
 	if connStr == "" {
 		errorResponse(w, http.StatusBadRequest, "Could not generate connection string for this database")
@@ -7747,6 +8452,114 @@ func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]interface{}{"users": result})
 }
 
+// validUserRole reports whether role is one of the user roles
+func validUserRole(role string) bool {
+	return role == "admin" || role == "deployer" || role == "viewer"
+}
+
+// handleCreateUser adds a user with a password directly, for setups where
+// invite emails aren't an option
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string   `json:"email"`
+		Password string   `json:"password"`
+		Role     string   `json:"role"`
+		AppIDs   []string `json:"app_ids,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if req.Email == "" || req.Password == "" {
+		errorResponse(w, http.StatusBadRequest, "Email and password are required")
+		return
+	}
+	if len(req.Password) < 8 {
+		errorResponse(w, http.StatusBadRequest, "Password must be at least 8 characters")
+		return
+	}
+	if req.Role == "" {
+		req.Role = "viewer"
+	}
+	if !validUserRole(req.Role) {
+		errorResponse(w, http.StatusBadRequest, "Role must be admin, deployer, or viewer")
+		return
+	}
+
+	existing, _ := s.storage.GetUserByEmail(req.Email)
+	if existing != nil {
+		errorResponse(w, http.StatusConflict, "User with this email already exists")
+		return
+	}
+
+	// Resolve app names so grants can be given the way the CLI shows apps
+	appIDs := make([]string, 0, len(req.AppIDs))
+	for _, idOrName := range req.AppIDs {
+		a, _ := s.resolveApp(idOrName)
+		if a == nil {
+			errorResponse(w, http.StatusNotFound, "App not found: "+idOrName)
+			return
+		}
+		appIDs = append(appIDs, a.ID)
+	}
+
+	passwordHash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+	user := &app.User{
+		ID:           uuid.New().String(),
+		Email:        req.Email,
+		PasswordHash: passwordHash,
+		Role:         req.Role,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.storage.CreateUser(user); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to create user: "+err.Error())
+		return
+	}
+	if len(appIDs) > 0 {
+		if err := s.storage.SetUserAppAccess(user.ID, appIDs); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to set user app access: "+err.Error())
+			return
+		}
+	}
+
+	s.logActivity("user", "create_user", "user", user.ID, req.Email, "success", fmt.Sprintf("role: %s", req.Role))
+	jsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"user":    user,
+		"app_ids": appIDs,
+	})
+}
+
+// handleGetUser returns a user with the apps they have been granted
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := s.storage.GetUserByID(r.PathValue("id"))
+	if err != nil || user == nil {
+		errorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	appIDs, err := s.storage.GetUserAppAccess(user.ID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to get user app access")
+		return
+	}
+	if appIDs == nil {
+		appIDs = []string{}
+	}
+	status := "active"
+	if user.PasswordHash == "" {
+		status = "invited"
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"user":    user,
+		"status":  status,
+		"app_ids": appIDs,
+	})
+}
+
 func (s *Server) handleInviteUser(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email string `json:"email"`
@@ -7764,7 +8577,7 @@ func (s *Server) handleInviteUser(w http.ResponseWriter, r *http.Request) {
 	if req.Role == "" {
 		req.Role = "viewer"
 	}
-	if req.Role != "admin" && req.Role != "deployer" && req.Role != "viewer" {
+	if !validUserRole(req.Role) {
 		errorResponse(w, http.StatusBadRequest, "Role must be admin, deployer, or viewer")
 		return
 	}
@@ -7854,6 +8667,7 @@ func (s *Server) handleAcceptInvite(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
+	s.auth.Touch(session.Token, requestIP(r), r.UserAgent())
 
 	isSecure := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
 	http.SetCookie(w, &http.Cookie{
@@ -7887,15 +8701,22 @@ func (s *Server) handleUpdateUserRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Role != "admin" && req.Role != "deployer" && req.Role != "viewer" {
+	if !validUserRole(req.Role) {
 		errorResponse(w, http.StatusBadRequest, "Role must be admin, deployer, or viewer")
 		return
 	}
 
+	user, err := s.storage.GetUserByID(userID)
+	if err != nil || user == nil {
+		errorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
 	if err := s.storage.UpdateUserRole(userID, req.Role); err != nil {
 		errorResponse(w, http.StatusInternalServerError, "Failed to update role")
 		return
 	}
+	// Sessions carry the role they were created with, so sign the user out
+	s.auth.DeleteUserSessions(userID)
 
 	s.logActivity("user", "update_role", "user", userID, "", "success", fmt.Sprintf("role: %s", req.Role))
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
@@ -7914,6 +8735,7 @@ func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, "Failed to delete user")
 		return
 	}
+	s.auth.DeleteUserSessions(userID)
 
 	s.logActivity("user", "delete_user", "user", userID, user.Email, "success", "")
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
@@ -7972,25 +8794,23 @@ func (s *Server) handleAIAnalyze(w http.ResponseWriter, r *http.Request) {
 		"env":  map[string]string{},
 	}
 
+	if st, _ := detectDockerfileStack(repoDir); st != nil {
+		suggestion["port"] = st.DefaultPort
+	}
 	switch stack {
 	case "nodejs":
-		suggestion["port"] = 3000
 		suggestion["env"] = map[string]string{"NODE_ENV": "production"}
-	case "go":
-		suggestion["port"] = 8080
 	case "python":
-		suggestion["port"] = 8000
 		suggestion["env"] = map[string]string{"PYTHONUNBUFFERED": "1"}
 	case "ruby":
-		suggestion["port"] = 3000
 		suggestion["env"] = map[string]string{"RAILS_ENV": "production"}
-	case "rust":
-		suggestion["port"] = 8080
+	case "php":
+		suggestion["env"] = map[string]string{"APP_ENV": "production"}
 	}
 
 	// If no Dockerfile exists, generate one
 	if !fileExists(repoDir + "/Dockerfile") {
-		dockerfile := generateDockerfile(repoDir, suggestion["port"].(int))
+		dockerfile, _ := s.generateDockerfile(repoDir, suggestion["port"].(int), "")
 		if dockerfile != "" {
 			suggestion["dockerfile"] = dockerfile
 		}
@@ -8032,13 +8852,10 @@ func (s *Server) handleAIAsk(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build caller from session for access control
-	var caller *ai.Caller
-	token := s.getSessionToken(r)
-	if session := s.auth.GetSession(token); session != nil {
-		caller = &ai.Caller{
-			UserID:   session.UserID,
-			UserRole: session.UserRole,
-		}
+	caller, err := s.aiCaller(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	result, err := s.assistant.Ask(req.Message, caller, req.Context)
@@ -8075,6 +8892,9 @@ func scanRepoStructure(dir string) []string {
 }
 
 func detectStack(dir string) string {
+	if fileExists(dir+"/deno.json") || fileExists(dir+"/deno.jsonc") {
+		return "deno"
+	}
 	if fileExists(dir + "/package.json") {
 		return "nodejs"
 	}
@@ -8090,7 +8910,7 @@ func detectStack(dir string) string {
 	if fileExists(dir + "/Cargo.toml") {
 		return "rust"
 	}
-	if fileExists(dir+"/pom.xml") || fileExists(dir+"/build.gradle") {
+	if fileExists(dir+"/pom.xml") || fileExists(dir+"/build.gradle") || fileExists(dir+"/build.gradle.kts") {
 		return "java"
 	}
 	if fileExists(dir + "/composer.json") {
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+const (
+	// selfSignedValidity is how long a generated API certificate lasts
+	selfSignedValidity = 365 * 24 * time.Hour
+	// selfSignedRenewBefore renews a generated certificate this long before
+	// it expires
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+	// sniffTimeout bounds how long a new connection has to send its first byte
+	sniffTimeout = 10 * time.Second
+)
+
+// LoadAPICertificate returns the certificate the API serves. With cert_file
+// set it loads that pair; with self_signed it reuses the certificate in dir,
+// generating a new one for hosts when it is missing or about to expire. A
+// renewed certificate keeps the stored key, so the pin `bp login` saved for
+// it still matches.
+func LoadAPICertificate(cfg config.APITLSConfig, dir string, hosts []string) (tls.Certificate, error) {
+	if cfg.CertFile != "" {
+		if cfg.KeyFile == "" {
+			return tls.Certificate{}, errors.New("server.tls.key_file is required with cert_file")
+		}
+		return tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && time.Until(leaf.NotAfter) > selfSignedRenewBefore {
+			return cert, nil
+		}
+	}
+
+	var key *ecdsa.PrivateKey
+	if data, err := os.ReadFile(keyPath); err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			key, _ = x509.ParseECPrivateKey(block.Bytes)
+		}
+	}
+	certPEM, keyPEM, err := generateSelfSigned(key, hosts, time.Now())
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// generateSelfSigned creates a PEM certificate and key valid for hosts,
+// which may be DNS names or IP addresses. A new key is generated when key is
+// nil.
+func generateSelfSigned(key *ecdsa.PrivateKey, hosts []string, now time.Time) ([]byte, []byte, error) {
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, nil, err
+		}
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Basepod"}, CommonName: "basepod api"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// NewTLSListener wraps ln so clients that start a TLS handshake get HTTPS.
+// Plain HTTP is still accepted from loopback, which is how Caddy, on-demand
+// TLS checks and `basepod status` reach the API; remote plain HTTP is
+// answered with a 400 telling the client to use https.
+func NewTLSListener(ln net.Listener, cert tls.Certificate) net.Listener {
+	l := &tlsListener{
+		Listener: ln,
+		config: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+			NextProtos:   []string{"http/1.1"},
+		},
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+// tlsListener sniffs the first byte of each connection to tell TLS from
+// plain HTTP. Sniffing happens off the accept loop so a slow client can't
+// hold up the others.
+type tlsListener struct {
+	net.Listener
+	config *tls.Config
+	conns  chan net.Conn
+	done   chan struct{}
+
+	mu        sync.Mutex
+	err       error
+	closeOnce sync.Once
+}
+
+func (l *tlsListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			l.mu.Lock()
+			l.err = err
+			l.mu.Unlock()
+			l.Close()
+			return
+		}
+		go l.sniff(conn)
+	}
+}
+
+func (l *tlsListener) sniff(conn net.Conn) {
+	br := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	first, err := br.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	var out net.Conn = &peekedConn{Conn: conn, r: br}
+	switch {
+	case first[0] == 0x16: // TLS handshake record
+		out = tls.Server(out, l.config)
+	case !isLoopbackAddr(conn.RemoteAddr()):
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nContent-Type: text/plain\r\nConnection: close\r\n\r\nThis server requires HTTPS.\n"))
+		conn.Close()
+		return
+	}
+
+	select {
+	case l.conns <- out:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *tlsListener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		close(l.done)
+		err = l.Listener.Close()
+	})
+	return err
+}
+
+// peekedConn replays the bytes read while sniffing
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func isLoopbackAddr(addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestLoadAPICertificateSelfSigned(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cfg := config.APITLSConfig{SelfSigned: true}
+
+	cert, err := LoadAPICertificate(cfg, dir, []string{"localhost", "127.0.0.1", "bp.example.com"})
+	if err != nil {
+		t.Fatalf("LoadAPICertificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("bp.example.com"); err != nil {
+		t.Fatalf("certificate doesn't cover bp.example.com: %v", err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Fatalf("certificate doesn't cover 127.0.0.1: %v", err)
+	}
+	if time.Until(leaf.NotAfter) < 300*24*time.Hour {
+		t.Fatalf("NotAfter = %v, want about a year out", leaf.NotAfter)
+	}
+
+	// A second start reuses the stored certificate
+	again, err := LoadAPICertificate(cfg, dir, []string{"localhost"})
+	if err != nil {
+		t.Fatalf("LoadAPICertificate again: %v", err)
+	}
+	if !bytes.Equal(again.Certificate[0], cert.Certificate[0]) {
+		t.Fatalf("self-signed certificate was regenerated instead of reused")
+	}
+
+	if _, err := LoadAPICertificate(config.APITLSConfig{CertFile: "cert.pem"}, dir, nil); err == nil {
+		t.Fatalf("cert_file without key_file: want an error")
+	}
+}
+
+func TestLoadAPICertificateRenewalKeepsKey(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cfg := config.APITLSConfig{SelfSigned: true}
+	cert, err := LoadAPICertificate(cfg, dir, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("LoadAPICertificate: %v", err)
+	}
+
+	// Replace the certificate with one about to expire, from the same key
+	key, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		t.Fatalf("private key is a %T, want ECDSA", cert.PrivateKey)
+	}
+	certPEM, _, err := generateSelfSigned(key, []string{"127.0.0.1"}, time.Now().Add(-360*24*time.Hour))
+	if err != nil {
+		t.Fatalf("generateSelfSigned: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := LoadAPICertificate(cfg, dir, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("LoadAPICertificate after expiry: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	renewedLeaf, _ := x509.ParseCertificate(renewed.Certificate[0])
+	if time.Until(renewedLeaf.NotAfter) < 300*24*time.Hour {
+		t.Fatalf("renewed NotAfter = %v, want about a year out", renewedLeaf.NotAfter)
+	}
+	if app.CertPin(renewedLeaf) != app.CertPin(leaf) {
+		t.Fatalf("renewal changed the pin from %s to %s, want the key kept", app.CertPin(leaf), app.CertPin(renewedLeaf))
+	}
+}
+
+func TestTLSListenerServesHTTPSAndLoopbackHTTP(t *testing.T) {
+	t.Parallel()
+	cert, err := LoadAPICertificate(config.APITLSConfig{SelfSigned: true}, t.TempDir(), []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("LoadAPICertificate: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			io.WriteString(w, "https")
+		} else {
+			io.WriteString(w, "http")
+		}
+	})}
+	go srv.Serve(NewTLSListener(ln, cert))
+	defer srv.Close()
+
+	addr := ln.Addr().String()
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	for scheme, want := range map[string]string{"https": "https", "http": "http"} {
+		resp, err := client.Get(scheme + "://" + addr + "/")
+		if err != nil {
+			t.Fatalf("%s request: %v", scheme, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Fatalf("%s request saw %q, want %q", scheme, body, want)
+		}
+	}
+}
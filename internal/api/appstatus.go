@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// appStatusEventLimit is how many recent events GET /api/apps/{id}/status
+// includes
+const appStatusEventLimit = 10
+
+// ContainerState is the live state of an app's container
+type ContainerState struct {
+	ID            string     `json:"id"`
+	State         string     `json:"state"` // Podman's status, e.g. running or exited
+	Running       bool       `json:"running"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	UptimeSeconds int64      `json:"uptime_seconds,omitempty"` // While running
+	RestartCount  int        `json:"restart_count"`
+}
+
+// AppStatusReport is one app's state gathered in one place for bp status
+// <app>: its container, resource use, last deploy, health and recent events
+type AppStatusReport struct {
+	AppID      string                       `json:"app_id"`
+	Name       string                       `json:"name"`
+	Status     app.AppStatus                `json:"status"`
+	Image      string                       `json:"image,omitempty"`
+	Domain     string                       `json:"domain,omitempty"`
+	Container  *ContainerState              `json:"container,omitempty"`
+	Stats      *podman.ContainerStatsResult `json:"stats,omitempty"` // Current CPU and memory, while running
+	LastDeploy *app.DeploymentRecord        `json:"last_deploy,omitempty"`
+	Health     *app.HealthStatus            `json:"health,omitempty"`
+	LastExit   *app.ContainerExit           `json:"last_exit,omitempty"`
+	Events     []AppEvent                   `json:"events"` // Newest first
+}
+
+// forApp returns up to limit of the kept events for an app, newest first
+func (h *eventHub) forApp(appID string, limit int) []AppEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := []AppEvent{}
+	for i := len(h.recent) - 1; i >= 0 && len(events) < limit; i-- {
+		if h.recent[i].AppID == appID {
+			events = append(events, h.recent[i])
+		}
+	}
+	return events
+}
+
+// containerState summarizes an inspected container; now is for the uptime
+func containerState(inspect *podman.ContainerInspect, now time.Time) *ContainerState {
+	cs := &ContainerState{
+		ID:           inspect.ID,
+		State:        inspect.State.Status,
+		Running:      inspect.State.Running,
+		RestartCount: inspect.RestartCount,
+	}
+	if started, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt); err == nil && started.Year() > 1 {
+		cs.StartedAt = &started
+		if cs.Running && now.After(started) {
+			cs.UptimeSeconds = int64(now.Sub(started).Seconds())
+		}
+	}
+	return cs
+}
+
+// handleGetAppStatus aggregates an app's container state, uptime, restarts,
+// resource use, last deploy, health and recent events
+func (s *Server) handleGetAppStatus(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	report := AppStatusReport{
+		AppID:    a.ID,
+		Name:     a.Name,
+		Status:   a.Status,
+		Image:    a.Image,
+		Domain:   a.Domain,
+		LastExit: a.LastExit,
+		Events:   s.events.forApp(a.ID, appStatusEventLimit),
+	}
+	if len(a.Deployments) > 0 {
+		last := a.Deployments[0]
+		last.BuildLog = ""
+		report.LastDeploy = &last
+	}
+	s.healthStatesMu.RLock()
+	if hs, ok := s.healthStates[a.ID]; ok {
+		health := *hs
+		report.Health = &health
+	}
+	s.healthStatesMu.RUnlock()
+
+	if a.ContainerID != "" && s.podman != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if inspect, err := s.podman.InspectContainer(ctx, a.ContainerID); err == nil {
+			report.Container = containerState(inspect, time.Now())
+			if inspect.State.Running {
+				report.Stats, _ = s.podman.ContainerStats(ctx, a.ContainerID)
+			}
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, report)
+}
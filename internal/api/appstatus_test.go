@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/podman"
+)
+
+func TestContainerState(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var inspect podman.ContainerInspect
+	inspect.ID = "abc"
+	inspect.RestartCount = 2
+	inspect.State.Status = "running"
+	inspect.State.Running = true
+	inspect.State.StartedAt = now.Add(-90 * time.Minute).Format(time.RFC3339Nano)
+	cs := containerState(&inspect, now)
+	if cs.UptimeSeconds != 5400 || cs.RestartCount != 2 || cs.StartedAt == nil {
+		t.Fatalf("running container state = %+v", cs)
+	}
+
+	inspect.State.Status = "exited"
+	inspect.State.Running = false
+	if cs := containerState(&inspect, now); cs.UptimeSeconds != 0 {
+		t.Fatalf("stopped container has uptime %d", cs.UptimeSeconds)
+	}
+
+	// Podman reports a zero time for containers that never started
+	inspect.State.StartedAt = "0001-01-01T00:00:00Z"
+	if cs := containerState(&inspect, now); cs.StartedAt != nil {
+		t.Fatalf("never-started container has start time %v", cs.StartedAt)
+	}
+}
+
+func TestEventHubForApp(t *testing.T) {
+	t.Parallel()
+	h := newEventHub()
+	for i := 0; i < 5; i++ {
+		h.publish(AppEvent{Type: "app_status", AppID: "a", Status: string(rune('0' + i))})
+		h.publish(AppEvent{Type: "app_status", AppID: "b"})
+	}
+
+	events := h.forApp("a", 3)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	for i, want := range []string{"4", "3", "2"} {
+		if events[i].AppID != "a" || events[i].Status != want {
+			t.Fatalf("event %d = %+v, want app a status %s", i, events[i], want)
+		}
+	}
+	if events := h.forApp("c", 3); len(events) != 0 {
+		t.Fatalf("unknown app has events %+v", events)
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/base-go/basepod/internal/mlx"
+)
+
+// findWhisperModel returns a downloaded Whisper model, preferring the requested ID.
+// OpenAI clients send "whisper-1", which maps to the first downloaded Whisper model.
+func findWhisperModel(requested string) string {
+	return pickWhisperModel(mlx.GetService().ListModels(), requested)
+}
+
+// pickWhisperModel is findWhisperModel over a list of models. A requested
+// model ID that isn't downloaded matches nothing.
+func pickWhisperModel(models []mlx.Model, requested string) string {
+	var fallback string
+	for _, m := range models {
+		if m.Category != "speech" || m.DownloadedAt.IsZero() || !strings.Contains(strings.ToLower(m.ID), "whisper") {
+			continue
+		}
+		if requested != "" && m.ID == requested {
+			return m.ID
+		}
+		if fallback == "" {
+			fallback = m.ID
+		}
+	}
+	if requested != "" && requested != "whisper-1" && strings.Contains(requested, "/") {
+		return ""
+	}
+	return fallback
+}
+
+// validLanguage reports whether lang looks like an ISO-639-1 code
+func validLanguage(lang string) bool {
+	if len(lang) < 2 || len(lang) > 3 {
+		return false
+	}
+	for _, c := range lang {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// transcriptionFormat checks a response_format, json when empty
+func transcriptionFormat(format string) (string, bool) {
+	switch format {
+	case "":
+		return "json", true
+	case "json", "text", "verbose_json":
+		return format, true
+	}
+	return "", false
+}
+
+// handleAudioTranscriptions implements the OpenAI-compatible /v1/audio/transcriptions endpoint
+func (s *Server) handleAudioTranscriptions(w http.ResponseWriter, r *http.Request) {
+	if !mlx.IsSupported() {
+		errorResponse(w, http.StatusServiceUnavailable, "Transcription requires MLX: "+mlx.GetUnsupportedReason())
+		return
+	}
+
+	// OpenAI caps uploads at 25MB
+	r.Body = http.MaxBytesReader(w, r.Body, 26<<20)
+	if err := r.ParseMultipartForm(25 << 20); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Missing audio file")
+		return
+	}
+	defer file.Close()
+
+	model := findWhisperModel(r.FormValue("model"))
+	if model == "" {
+		errorResponse(w, http.StatusBadRequest, "No matching Whisper model downloaded. Pull one with: bp model pull mlx-community/whisper-small-mlx")
+		return
+	}
+
+	language := strings.ToLower(strings.TrimSpace(r.FormValue("language")))
+	if language != "" && !validLanguage(language) {
+		errorResponse(w, http.StatusBadRequest, "language must be an ISO-639-1 code")
+		return
+	}
+
+	format, ok := transcriptionFormat(r.FormValue("response_format"))
+	if !ok {
+		errorResponse(w, http.StatusBadRequest, "response_format must be json, text, or verbose_json")
+		return
+	}
+
+	// Keep the original extension so ffmpeg can pick the right demuxer
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext == "" || len(ext) > 6 {
+		ext = ".audio"
+	}
+	tempFile, err := os.CreateTemp("", "transcribe-*"+ext)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to create temp file")
+		return
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, file); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to save audio")
+		return
+	}
+	tempFile.Close()
+
+	transcript, err := mlx.GetService().TranscribeSegments(tempFile.Name(), model, language)
+	if err != nil {
+		var memErr *mlx.MemoryError
+		if errors.As(err, &memErr) {
+			errorResponse(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		errorResponse(w, http.StatusInternalServerError, "Transcription failed: "+err.Error())
+		return
+	}
+
+	switch format {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, transcript.Text+"\n")
+	case "verbose_json":
+		if language == "" {
+			language = transcript.Language
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"task":     "transcribe",
+			"language": language,
+			"model":    model,
+			"duration": transcript.Duration,
+			"text":     transcript.Text,
+			"segments": transcript.Segments,
+		})
+	default:
+		jsonResponse(w, http.StatusOK, map[string]string{"text": transcript.Text})
+	}
+}
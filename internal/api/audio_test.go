@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/mlx"
+)
+
+func TestAudioTranscriptionsIsRouted(t *testing.T) {
+	ts := newTestServer(t)
+	transcriber := ts.scopedToken(ts.token, "audio:transcribe")
+	scraper := ts.scopedToken(ts.token, "metrics:read")
+
+	if code := ts.doAs("", "POST", "/v1/audio/transcriptions", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("without a token: status %d, want 401", code)
+	}
+	if code := ts.doAs(scraper, "POST", "/v1/audio/transcriptions", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("token without audio:transcribe: status %d, want 403", code)
+	}
+	// Reaching the handler: no MLX on this platform, or no audio file sent
+	var resp struct {
+		Error string `json:"error"`
+	}
+	code := ts.doAs(transcriber, "POST", "/v1/audio/transcriptions", nil, &resp)
+	if (code != http.StatusServiceUnavailable && code != http.StatusBadRequest) || resp.Error == "" {
+		t.Fatalf("audio:transcribe token: status %d, %+v, want the handler's error", code, resp)
+	}
+}
+
+func TestPickWhisperModel(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	models := []mlx.Model{
+		{ID: "mlx-community/Llama-3.2-1B-Instruct-4bit", Category: "chat", DownloadedAt: now},
+		{ID: "mlx-community/whisper-large-v3-turbo", Category: "speech"}, // Not downloaded
+		{ID: "mlx-community/whisper-small-mlx", Category: "speech", DownloadedAt: now},
+		{ID: "mlx-community/whisper-tiny", Category: "speech", DownloadedAt: now},
+	}
+	for requested, want := range map[string]string{
+		"":                                     "mlx-community/whisper-small-mlx",
+		"whisper-1":                            "mlx-community/whisper-small-mlx",
+		"mlx-community/whisper-tiny":           "mlx-community/whisper-tiny",
+		"mlx-community/whisper-large-v3-turbo": "",
+		"mlx-community/Llama-3.2-1B-Instruct-4bit": "",
+	} {
+		if got := pickWhisperModel(models, requested); got != want {
+			t.Fatalf("pickWhisperModel(%q) = %q, want %q", requested, got, want)
+		}
+	}
+	if got := pickWhisperModel(nil, "whisper-1"); got != "" {
+		t.Fatalf("pickWhisperModel without models = %q", got)
+	}
+}
+
+func TestTranscriptionParams(t *testing.T) {
+	t.Parallel()
+	for _, lang := range []string{"en", "de", "haw"} {
+		if !validLanguage(lang) {
+			t.Fatalf("validLanguage(%q) = false", lang)
+		}
+	}
+	for _, lang := range []string{"e", "english", "EN", "e1", "zh-cn"} {
+		if validLanguage(lang) {
+			t.Fatalf("validLanguage(%q) = true", lang)
+		}
+	}
+
+	for raw, want := range map[string]string{"": "json", "json": "json", "text": "text", "verbose_json": "verbose_json"} {
+		if got, ok := transcriptionFormat(raw); !ok || got != want {
+			t.Fatalf("transcriptionFormat(%q) = %q, %v, want %q", raw, got, ok, want)
+		}
+	}
+	for _, raw := range []string{"srt", "vtt", "JSON"} {
+		if _, ok := transcriptionFormat(raw); ok {
+			t.Fatalf("transcriptionFormat(%q) accepted", raw)
+		}
+	}
+}
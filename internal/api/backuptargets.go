@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/base-go/basepod/internal/backup"
+	"github.com/base-go/basepod/internal/config"
+)
+
+// backupRemote resolves a configured backup target, the first when name is
+// empty. The status is the HTTP status to answer with on error.
+func (s *Server) backupRemote(name string) (backup.Remote, config.BackupTarget, int, error) {
+	if s.config == nil || len(s.config.Backup.Targets) == 0 {
+		return nil, config.BackupTarget{}, http.StatusBadRequest, fmt.Errorf("no backup targets configured; add one under backup.targets in the server config")
+	}
+	target, ok := s.config.Backup.Target(name)
+	if !ok {
+		return nil, config.BackupTarget{}, http.StatusNotFound, fmt.Errorf("backup target %s not found", name)
+	}
+	remote, err := backup.NewRemote(target)
+	if err != nil {
+		return nil, target, http.StatusBadRequest, err
+	}
+	return remote, target, http.StatusOK, nil
+}
+
+// handleBackupTargets lists the configured backup targets, without their
+// credentials
+func (s *Server) handleBackupTargets(w http.ResponseWriter, r *http.Request) {
+	targets := []config.BackupTarget{}
+	if s.config != nil && s.config.Backup.Targets != nil {
+		targets = s.config.Backup.Targets
+	}
+	jsonResponse(w, http.StatusOK, targets)
+}
+
+// handleListRemoteBackups lists the backups stored on a target
+func (s *Server) handleListRemoteBackups(w http.ResponseWriter, r *http.Request) {
+	remote, target, status, err := s.backupRemote(r.URL.Query().Get("target"))
+	if err != nil {
+		errorResponse(w, status, err.Error())
+		return
+	}
+	backups, err := s.backup.ListRemote(r.Context(), remote)
+	if err != nil {
+		errorResponse(w, http.StatusBadGateway, fmt.Sprintf("Failed to list backups on %s: %v", target.Name, err))
+		return
+	}
+
+	type remoteBackupResponse struct {
+		ID        string    `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		Size      int64     `json:"size"`
+		SizeHuman string    `json:"size_human"`
+	}
+	response := make([]remoteBackupResponse, 0, len(backups))
+	for _, b := range backups {
+		response = append(response, remoteBackupResponse{
+			ID:        b.ID,
+			CreatedAt: b.CreatedAt,
+			Size:      b.Size,
+			SizeHuman: backup.FormatSize(b.Size),
+		})
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"target":  target.Name,
+		"backups": response,
+	})
+}
+
+// handleUploadBackup copies an existing backup to a target
+func (s *Server) handleUploadBackup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req struct {
+		Target string `json:"target"` // Default: the first target
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	remote, target, status, err := s.backupRemote(req.Target)
+	if err != nil {
+		errorResponse(w, status, err.Error())
+		return
+	}
+	if _, err := s.backup.Get(id); err != nil {
+		errorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	http.NewResponseController(w).SetWriteDeadline(time.Time{}) // Uploads can outlast the write timeout
+	if err := s.backup.Upload(r.Context(), id, remote); err != nil {
+		errorResponse(w, http.StatusBadGateway, fmt.Sprintf("Failed to upload backup %s to %s: %v", id, target.Name, err))
+		return
+	}
+	s.logActivity("user", "upload_backup", "backup", id, id, "success", target.Name)
+	jsonResponse(w, http.StatusOK, map[string]string{"id": id, "uploaded_to": target.Name})
+}
+
+// handleImportBackup stores a backup archive uploaded as the "backup" part
+// of a multipart form, streaming it to disk, so it can be restored here
+func (s *Server) handleImportBackup(w http.ResponseWriter, r *http.Request) {
+	// Archives with volumes can take longer than the server's read timeout
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Expected a multipart upload with a backup file")
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Missing backup file")
+			return
+		}
+		if part.FormName() != "backup" {
+			part.Close()
+			continue
+		}
+		b, err := s.backup.Import(part)
+		part.Close()
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid backup: "+err.Error())
+			return
+		}
+		s.logActivity("user", "import_backup", "backup", b.ID, b.ID, "success", backup.FormatSize(b.Size))
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"id":         b.ID,
+			"created_at": b.CreatedAt,
+			"size":       b.Size,
+			"size_human": backup.FormatSize(b.Size),
+			"path":       b.Path,
+			"contents":   b.Contents,
+		})
+		return
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestBackupTargets(t *testing.T) {
+	ts := newTestServer(t)
+
+	if code := ts.do("GET", "/api/backups/remote", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("remote list without targets: status %d, want 400", code)
+	}
+
+	ts.config.Backup.Targets = []config.BackupTarget{
+		{Name: "offsite", Type: "s3", Bucket: "backups", AccessKeyID: "AKID", SecretAccessKey: "s3-secret"},
+		{Name: "nas", Type: "sftp", Host: "nas.local", KeyFile: "/root/.ssh/id_backup"},
+	}
+	body := ts.get("/api/backups/targets")
+	if !strings.Contains(body, `"bucket":"backups"`) || strings.Contains(body, "s3-secret") || strings.Contains(body, "AKID") || strings.Contains(body, "id_backup") {
+		t.Fatalf("targets = %s, want them without credentials", body)
+	}
+	if code := ts.do("GET", "/api/backups/remote?target=tape", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("remote list of an unknown target: status %d, want 404", code)
+	}
+	if code := ts.do("POST", "/api/backups/20000101-000000/upload", map[string]string{"target": "nas"}, nil); code != http.StatusNotFound {
+		t.Fatalf("upload of a missing backup: status %d, want 404", code)
+	}
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+)
+
+// sourceBlobMaxAge is how long a source blob no deploy used is kept before
+// a prune removes it
+const sourceBlobMaxAge = 14 * 24 * time.Hour
+
+// maxBlobCheckHashes bounds the hashes of one blob check
+const maxBlobCheckHashes = 200000
+
+// sourceBlobDir is where the file contents of delta uploads are kept, by
+// SHA256
+func sourceBlobDir() (string, error) {
+	paths, err := config.GetPaths()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(paths.Base, "blobs"), nil
+}
+
+// validBlobHash reports whether h is a lowercase hex SHA256
+func validBlobHash(h string) bool {
+	if len(h) != sha256.Size*2 || strings.ToLower(h) != h {
+		return false
+	}
+	_, err := hex.DecodeString(h)
+	return err == nil
+}
+
+// sourceBlobPath is where the blob with hash h is stored
+func sourceBlobPath(dir, h string) string {
+	return filepath.Join(dir, h[:2], h)
+}
+
+// uploadLimit is the most a caller may upload at once: 50MB for Construct
+// users, 500MB for everyone else
+func uploadLimit(r *http.Request) int64 {
+	if getConstructUser(r) != nil {
+		return 50 << 20
+	}
+	return 500 << 20
+}
+
+// handleCheckBlobs answers which of the listed file contents a delta upload
+// still has to send
+func (s *Server) handleCheckBlobs(w http.ResponseWriter, r *http.Request) {
+	var req app.BlobCheckRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 32<<20)).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Hashes) > maxBlobCheckHashes {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("At most %d hashes can be checked at once", maxBlobCheckHashes))
+		return
+	}
+	dir, err := sourceBlobDir()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := app.BlobCheckResponse{Missing: []string{}}
+	seen := map[string]bool{}
+	for _, h := range req.Hashes {
+		if !validBlobHash(h) {
+			errorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid hash %q", h))
+			return
+		}
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		if _, err := os.Stat(sourceBlobPath(dir, h)); err != nil {
+			resp.Missing = append(resp.Missing, h)
+		}
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// handleUploadBlobs stores the file contents of a delta upload. The body is
+// a gzipped tarball with one entry per content, named by its SHA256.
+func (s *Server) handleUploadBlobs(w http.ResponseWriter, r *http.Request) {
+	dir, err := sourceBlobDir()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	limit := uploadLimit(r)
+	body := http.MaxBytesReader(w, r.Body, limit)
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Body must be a gzipped tarball")
+		return
+	}
+	stored := 0
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err == nil && !validBlobHash(hdr.Name) {
+			err = fmt.Errorf("invalid blob name %q", hdr.Name)
+		}
+		if err == nil {
+			err = storeSourceBlob(dir, hdr.Name, tr)
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			errorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload too large (max %dMB)", limit>>20))
+			return
+		}
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid blobs: "+err.Error())
+			return
+		}
+		stored++
+	}
+	jsonResponse(w, http.StatusOK, map[string]int{"stored": stored})
+}
+
+// storeSourceBlob writes a blob if its content matches its hash. It is
+// written to a temporary file first, so a deploy never sees part of one.
+func storeSourceBlob(dir, h string, r io.Reader) error {
+	dest := sourceBlobPath(dir, h)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	sum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, sum), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("blob %s: %w", h[:12], err)
+	}
+	if hex.EncodeToString(sum.Sum(nil)) != h {
+		return fmt.Errorf("blob %s: content doesn't match its hash", h[:12])
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// checkSourceManifest checks the paths of a delta upload's manifest and
+// returns how many of its files' contents aren't in the blob store
+func checkSourceManifest(dir string, files []app.SourceFile) (int, error) {
+	missing := 0
+	for _, f := range files {
+		clean := path.Clean(f.Path)
+		if f.Path == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return 0, fmt.Errorf("invalid path %q", f.Path)
+		}
+		switch f.Type {
+		case app.SourceFileDir, app.SourceFileSymlink:
+		case app.SourceFileRegular:
+			if !validBlobHash(f.SHA256) {
+				return 0, fmt.Errorf("%s: invalid hash", f.Path)
+			}
+			info, err := os.Stat(sourceBlobPath(dir, f.SHA256))
+			if err != nil {
+				missing++
+			} else if info.Size() != f.Size {
+				return 0, fmt.Errorf("%s: size doesn't match its content", f.Path)
+			}
+		default:
+			return 0, fmt.Errorf("%s: unknown type %q", f.Path, f.Type)
+		}
+	}
+	return missing, nil
+}
+
+// writeManifestTarball writes the gzipped tarball a full upload of the
+// manifest's files would have sent, from the blob store. Blobs it reads are
+// marked used, so a prune keeps them.
+func writeManifestTarball(w io.Writer, dir string, files []app.SourceFile) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.Path, Mode: f.Mode, ModTime: f.ModTime}
+		switch f.Type {
+		case app.SourceFileDir:
+			hdr.Typeflag = tar.TypeDir
+		case app.SourceFileSymlink:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = f.Link
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = f.Size
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		blob := sourceBlobPath(dir, f.SHA256)
+		in, err := os.Open(blob)
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Path, err)
+		}
+		_, err = io.CopyN(tw, in, f.Size)
+		in.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Path, err)
+		}
+		os.Chtimes(blob, now, now)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// pruneSourceBlobs removes the blobs no deploy used since before and
+// returns how many and their size
+func pruneSourceBlobs(before time.Time) (int, int64, error) {
+	dir, err := sourceBlobDir()
+	if err != nil {
+		return 0, 0, err
+	}
+	removed, size := 0, int64(0)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			return nil
+		}
+		if os.Remove(p) == nil {
+			removed++
+			size += info.Size()
+		}
+		return nil
+	})
+	return removed, size, err
+}
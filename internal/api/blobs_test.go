@@ -0,0 +1,150 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+)
+
+// blobTarball packs contents, by name, into the body of POST
+// /api/deploy/blobs
+func blobTarball(contents map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range contents {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func blobHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// postRaw makes an authenticated request with a raw body
+func (ts *testServer) postRaw(path, contentType string, body []byte) (int, string) {
+	ts.t.Helper()
+	req, _ := http.NewRequest("POST", ts.http.URL+path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(out)
+}
+
+func TestDeltaUpload(t *testing.T) {
+	ts := newTestServer(t)
+
+	index := "<h1>delta</h1>\n"
+	style := "h1 { color: teal }\n"
+	var missing app.BlobCheckResponse
+	if code := ts.do("POST", "/api/deploy/blobs/check", app.BlobCheckRequest{Hashes: []string{blobHash(index), blobHash(style)}}, &missing); code != http.StatusOK {
+		t.Fatalf("check: status %d", code)
+	}
+	if len(missing.Missing) != 2 {
+		t.Fatalf("missing = %v, want both files", missing.Missing)
+	}
+	if code := ts.do("POST", "/api/deploy/blobs/check", app.BlobCheckRequest{Hashes: []string{"../etc/passwd"}}, nil); code != http.StatusBadRequest {
+		t.Fatalf("check of a bad hash: status %d, want 400", code)
+	}
+
+	// Contents have to match their names
+	if code, _ := ts.postRaw("/api/deploy/blobs", "application/gzip", blobTarball(map[string]string{blobHash(index): style})); code != http.StatusBadRequest {
+		t.Fatalf("upload of a mismatched blob: status %d, want 400", code)
+	}
+	if code, out := ts.postRaw("/api/deploy/blobs", "application/gzip", blobTarball(map[string]string{blobHash(index): index})); code != http.StatusOK {
+		t.Fatalf("upload: status %d: %s", code, out)
+	}
+	missing = app.BlobCheckResponse{}
+	ts.do("POST", "/api/deploy/blobs/check", app.BlobCheckRequest{Hashes: []string{blobHash(index), blobHash(style)}}, &missing)
+	if len(missing.Missing) != 1 || missing.Missing[0] != blobHash(style) {
+		t.Fatalf("missing after upload = %v, want only the stylesheet", missing.Missing)
+	}
+
+	mtime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	manifest := []app.SourceFile{
+		{Path: "css", Type: app.SourceFileDir, Mode: 0755, ModTime: mtime},
+		{Path: "index.html", Type: app.SourceFileRegular, Mode: 0644, Size: int64(len(index)), SHA256: blobHash(index), ModTime: mtime},
+		{Path: "css/site.css", Type: app.SourceFileRegular, Mode: 0644, Size: int64(len(style)), SHA256: blobHash(style), ModTime: mtime},
+	}
+	deploy := func(files []app.SourceFile) (int, string) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		configJSON, _ := json.Marshal(SourceDeployConfig{Name: "delta-site", Domain: "delta-site.test", Type: "static", Public: "."})
+		mw.WriteField("config", string(configJSON))
+		manifestJSON, _ := json.Marshal(files)
+		mw.WriteField("manifest", string(manifestJSON))
+		mw.Close()
+		return ts.postRaw("/api/deploy", mw.FormDataContentType(), body.Bytes())
+	}
+
+	// Every file must be uploaded before the deploy, and stay in the source
+	if code, out := deploy(manifest); code != http.StatusBadRequest || !strings.Contains(out, "1 files") {
+		t.Fatalf("deploy with a missing file: status %d: %s", code, out)
+	}
+	escape := append([]app.SourceFile{}, manifest[:2]...)
+	escape[1].Path = "../index.html"
+	if code, _ := deploy(escape); code != http.StatusBadRequest {
+		t.Fatalf("deploy with a path outside the source: status %d, want 400", code)
+	}
+
+	ts.postRaw("/api/deploy/blobs", "application/gzip", blobTarball(map[string]string{blobHash(style): style}))
+	if code, out := deploy(manifest); code != http.StatusOK || !strings.Contains(out, "Source extracted") {
+		t.Fatalf("deploy: status %d:\n%s", code, out)
+	}
+	paths, _ := config.GetPaths()
+	site := filepath.Join(paths.Base, "data", "apps", "delta-site")
+	if data, _ := os.ReadFile(filepath.Join(site, "css", "site.css")); string(data) != style {
+		t.Fatalf("deployed css/site.css = %q, want the uploaded stylesheet", data)
+	}
+	var a app.App
+	ts.do("GET", "/api/apps/delta-site", nil, &a)
+	if info, err := os.Stat(filepath.Join(paths.Base, "builds", a.ID, "source", "index.html")); err != nil || !info.ModTime().Equal(mtime) {
+		t.Fatalf("extracted index.html: %v, want its modification time kept", err)
+	}
+}
+
+func TestPruneSourceBlobs(t *testing.T) {
+	newTestServer(t) // For BASEPOD_HOME
+
+	dir, _ := sourceBlobDir()
+	fresh, stale := "fresh\n", "stale\n"
+	for _, data := range []string{fresh, stale} {
+		if err := storeSourceBlob(dir, blobHash(data), strings.NewReader(data)); err != nil {
+			t.Fatalf("storeSourceBlob: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * sourceBlobMaxAge)
+	os.Chtimes(sourceBlobPath(dir, blobHash(stale)), old, old)
+
+	n, size, err := pruneSourceBlobs(time.Now().Add(-sourceBlobMaxAge))
+	if err != nil || n != 1 || size != int64(len(stale)) {
+		t.Fatalf("pruneSourceBlobs = %d, %d, %v; want the stale blob", n, size, err)
+	}
+	if _, err := os.Stat(sourceBlobPath(dir, blobHash(fresh))); err != nil {
+		t.Fatal("a recently used blob should be kept")
+	}
+}
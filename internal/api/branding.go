@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+// Branding is the white-label look of the web UI, emails and notifications
+type Branding struct {
+	Name        string `json:"name"`
+	LogoURL     string `json:"logo_url,omitempty"` // Set when a logo has been uploaded
+	AccentColor string `json:"accent_color"`
+	SupportURL  string `json:"support_url,omitempty"`
+}
+
+// defaultAccentColor is used when no accent color is configured
+const defaultAccentColor = "#3b82f6"
+
+// maxLogoBytes caps uploaded logos
+const maxLogoBytes = 1 << 20
+
+var accentColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// logoTypes maps the image types accepted as a logo to their file extension
+var logoTypes = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// branding returns the effective branding
+func (s *Server) branding() Branding {
+	b := Branding{
+		Name:        s.config.Branding.ProductName(),
+		AccentColor: s.config.Branding.AccentColor,
+		SupportURL:  s.config.Branding.SupportURL,
+	}
+	if b.AccentColor == "" {
+		b.AccentColor = defaultAccentColor
+	}
+	if s.config.Branding.Logo != "" {
+		b.LogoURL = "/api/system/branding/logo"
+	}
+	return b
+}
+
+// validateBranding checks branding settings before they are saved
+func validateBranding(b config.BrandingConfig) error {
+	if len(b.Name) > 64 {
+		return fmt.Errorf("name is too long (max 64 characters)")
+	}
+	if b.AccentColor != "" && !accentColorPattern.MatchString(b.AccentColor) {
+		return fmt.Errorf("accent_color must be a hex color like #3b82f6")
+	}
+	if b.SupportURL != "" {
+		u, err := url.Parse(b.SupportURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "mailto") || (u.Scheme != "mailto" && u.Host == "") {
+			return fmt.Errorf("support_url must be an http(s) or mailto: link")
+		}
+	}
+	return nil
+}
+
+// logoPath is where the uploaded logo named file is kept
+func logoPath(file string) string {
+	paths, err := config.GetPaths()
+	if err != nil || file == "" {
+		return ""
+	}
+	return filepath.Join(paths.Data, filepath.Base(file))
+}
+
+// handleGetBranding returns the branding settings
+func (s *Server) handleGetBranding(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, s.branding())
+}
+
+// handleUpdateBranding sets the product name, accent color and support link.
+// Fields left out of the request keep their value; empty strings reset them.
+func (s *Server) handleUpdateBranding(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name        *string `json:"name"`
+		AccentColor *string `json:"accent_color"`
+		SupportURL  *string `json:"support_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	b := s.config.Branding
+	if req.Name != nil {
+		b.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.AccentColor != nil {
+		b.AccentColor = strings.TrimSpace(*req.AccentColor)
+	}
+	if req.SupportURL != nil {
+		b.SupportURL = strings.TrimSpace(*req.SupportURL)
+	}
+	if err := validateBranding(b); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.config.Branding = b
+	if err := s.config.Save(); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to save config: "+err.Error())
+		return
+	}
+	s.logActivity("user", "branding_update", "system", "", "", "success", b.ProductName())
+	jsonResponse(w, http.StatusOK, s.branding())
+}
+
+// handleUploadLogo replaces the logo. The image is sent as the "logo" field
+// of a multipart form, or as the raw request body with its Content-Type.
+func (s *Server) handleUploadLogo(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLogoBytes+64<<10)
+
+	var body io.Reader = r.Body
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxLogoBytes); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid upload (max 1MB)")
+			return
+		}
+		file, header, err := r.FormFile("logo")
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Missing logo file")
+			return
+		}
+		defer file.Close()
+		body = file
+		contentType = header.Header.Get("Content-Type")
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxLogoBytes+1))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Failed to read logo")
+		return
+	}
+	if len(data) == 0 || len(data) > maxLogoBytes {
+		errorResponse(w, http.StatusBadRequest, "Logo must be between 1 byte and 1MB")
+		return
+	}
+
+	// Trust the content, not the declared type, except for SVG which
+	// http.DetectContentType reports as text
+	detected := http.DetectContentType(data)
+	if strings.HasPrefix(contentType, "image/svg+xml") && strings.Contains(string(data[:min(len(data), 1024)]), "<svg") {
+		detected = "image/svg+xml"
+	}
+	ext, ok := logoTypes[detected]
+	if !ok {
+		errorResponse(w, http.StatusBadRequest, "Logo must be a PNG, JPEG, WebP or SVG image")
+		return
+	}
+
+	file := "branding-logo" + ext
+	path := logoPath(file)
+	if path == "" {
+		errorResponse(w, http.StatusInternalServerError, "Cannot determine data path")
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to save logo: "+err.Error())
+		return
+	}
+	if old := s.config.Branding.Logo; old != "" && old != file {
+		os.Remove(logoPath(old))
+	}
+	s.config.Branding.Logo = file
+	if err := s.config.Save(); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to save config: "+err.Error())
+		return
+	}
+	s.logActivity("user", "branding_logo_upload", "system", "", "", "success", fmt.Sprintf("%s, %d bytes", detected, len(data)))
+	jsonResponse(w, http.StatusOK, s.branding())
+}
+
+// handleDeleteLogo removes the uploaded logo
+func (s *Server) handleDeleteLogo(w http.ResponseWriter, r *http.Request) {
+	if s.config.Branding.Logo != "" {
+		os.Remove(logoPath(s.config.Branding.Logo))
+		s.config.Branding.Logo = ""
+		if err := s.config.Save(); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to save config: "+err.Error())
+			return
+		}
+	}
+	jsonResponse(w, http.StatusOK, s.branding())
+}
+
+// handleGetLogo serves the uploaded logo. No auth: the login page shows it.
+func (s *Server) handleGetLogo(w http.ResponseWriter, r *http.Request) {
+	path := logoPath(s.config.Branding.Logo)
+	if path == "" {
+		errorResponse(w, http.StatusNotFound, "No logo uploaded")
+		return
+	}
+	contentType := ""
+	for t, ext := range logoTypes {
+		if filepath.Ext(path) == ext {
+			contentType = t
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// SVGs can carry scripts; never let them run
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeFile(w, r, path)
+}
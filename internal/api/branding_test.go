@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestValidateBranding(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		b       config.BrandingConfig
+		wantErr bool
+	}{
+		{"empty", config.BrandingConfig{}, false},
+		{"full", config.BrandingConfig{Name: "Acme Cloud", AccentColor: "#ff6600", SupportURL: "https://acme.example/help"}, false},
+		{"short color", config.BrandingConfig{AccentColor: "#f60"}, false},
+		{"mailto", config.BrandingConfig{SupportURL: "mailto:help@acme.example"}, false},
+		{"named color", config.BrandingConfig{AccentColor: "orange"}, true},
+		{"css injection", config.BrandingConfig{AccentColor: "#fff;background:url(x)"}, true},
+		{"javascript link", config.BrandingConfig{SupportURL: "javascript:alert(1)"}, true},
+		{"relative link", config.BrandingConfig{SupportURL: "/help"}, true},
+	}
+	for _, c := range cases {
+		if err := validateBranding(c.b); (err != nil) != c.wantErr {
+			t.Fatalf("%s: validateBranding(%+v) error = %v, wantErr %v", c.name, c.b, err, c.wantErr)
+		}
+	}
+}
+
+func TestBrandingDefaults(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{config: config.DefaultConfig()}
+	b := s.branding()
+	if b.Name != "Basepod" || b.AccentColor != defaultAccentColor || b.LogoURL != "" {
+		t.Fatalf("branding() = %+v, want Basepod defaults without a logo", b)
+	}
+
+	s.config.Branding = config.BrandingConfig{Name: "Acme Cloud", Logo: "branding-logo.png"}
+	b = s.branding()
+	if b.Name != "Acme Cloud" || b.LogoURL != "/api/system/branding/logo" {
+		t.Fatalf("branding() = %+v, want Acme Cloud with a logo URL", b)
+	}
+}
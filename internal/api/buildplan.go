@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// staticOutputDirs are where static site generators and bundlers put their
+// output, checked in this order
+var staticOutputDirs = []string{"dist", "build", "public", "out", ".output/public", "_site"}
+
+// buildPlan is how a source without a Dockerfile gets built, worked out from
+// its files the way bp init does: a Dockerfile generated for its stack, or a
+// static site
+type buildPlan struct {
+	Stack     string // dockerfileStacks name, or "static"
+	Reason    string // What gave the stack away
+	Port      int    // Port the generated Dockerfile's server listens on
+	PublicDir string // static: directory to serve, "" to find it after the build
+	RunBuild  bool   // static: run package.json's build script first
+}
+
+// String describes the plan for the deploy log
+func (p *buildPlan) String() string {
+	if p.Stack != "static" {
+		return fmt.Sprintf("Build plan: %s (%s), generated Dockerfile, port %d", p.Stack, p.Reason, p.Port)
+	}
+	if p.RunBuild {
+		return fmt.Sprintf("Build plan: static site (%s), built with its build script", p.Reason)
+	}
+	return fmt.Sprintf("Build plan: static site (%s), serving %s", p.Reason, p.PublicDir)
+}
+
+// packageScripts returns the scripts of a package.json
+func packageScripts(path string) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return nil
+	}
+	return pkg.Scripts
+}
+
+// detectBuildPlan plans the build of a source that has no Dockerfile, or
+// returns nil if nothing in it is recognized. Node projects with a start
+// script are servers; ones with only a build script are static sites built
+// on the server.
+func detectBuildPlan(sourceDir string, port int) *buildPlan {
+	if st, lockfile := detectDockerfileStack(sourceDir); st != nil {
+		plan := &buildPlan{Stack: st.Name, Reason: st.Markers[0], Port: port}
+		if plan.Port == 0 {
+			plan.Port = st.DefaultPort
+		}
+		if st.Requires != "" {
+			plan.Reason += " + " + st.Requires
+		}
+		if st.Name != "node" {
+			return plan
+		}
+		scripts := packageScripts(filepath.Join(sourceDir, "package.json"))
+		if scripts["start"] != "" {
+			plan.Reason = "package.json with a start script"
+			if lockfile != "" {
+				plan.Reason += ", " + lockfile
+			}
+			return plan
+		}
+		if scripts["build"] != "" {
+			return &buildPlan{Stack: "static", Reason: "package.json with a build script and no start script", RunBuild: true}
+		}
+		if dir := staticOutputDir(sourceDir); dir != "" {
+			return &buildPlan{Stack: "static", Reason: "package.json without scripts", PublicDir: dir}
+		}
+		return plan
+	}
+
+	if dir := staticOutputDir(sourceDir); dir != "" {
+		reason := dir + "/"
+		if dir == "." {
+			reason = "*.html"
+		}
+		return &buildPlan{Stack: "static", Reason: reason, PublicDir: dir}
+	}
+	return nil
+}
+
+// staticOutputDir returns the directory of a source that holds its static
+// site: a build output directory, "." for HTML at the top, or ""
+func staticOutputDir(sourceDir string) string {
+	for _, dir := range staticOutputDirs {
+		if fi, err := os.Stat(filepath.Join(sourceDir, dir)); err == nil && fi.IsDir() {
+			return dir
+		}
+	}
+	if htmlFiles, _ := filepath.Glob(filepath.Join(sourceDir, "*.html")); len(htmlFiles) > 0 {
+		return "."
+	}
+	return ""
+}
+
+// runNodeBuild installs a Node project's dependencies with the package
+// manager its lockfile is for and runs its build script. Failures are
+// warnings: a missing output directory is reported later.
+func runNodeBuild(ctx context.Context, sourceDir string, writeLine func(string)) {
+	writeLine("Building Node.js project...")
+
+	npmCmd := "npm"
+	if fileExists(filepath.Join(sourceDir, "bun.lock")) {
+		npmCmd = "bun"
+	} else if fileExists(filepath.Join(sourceDir, "yarn.lock")) {
+		npmCmd = "yarn"
+	} else if fileExists(filepath.Join(sourceDir, "pnpm-lock.yaml")) {
+		npmCmd = "pnpm"
+	}
+
+	writeLine(fmt.Sprintf("Installing dependencies with %s...", npmCmd))
+	if npmCmd == "npm" {
+		// Try npm ci first, fall back to npm install
+		if _, err := execCommandDir(ctx, sourceDir, "npm", "ci", "--no-audit", "--no-fund"); err != nil {
+			if output, err := execCommandDir(ctx, sourceDir, "npm", "install", "--no-audit", "--no-fund"); err != nil {
+				writeLine("WARNING: Dependency install had issues: " + err.Error())
+				writeLine(output)
+			} else {
+				writeLine("Dependencies installed")
+			}
+		} else {
+			writeLine("Dependencies installed")
+		}
+	} else {
+		if output, err := execCommandDir(ctx, sourceDir, npmCmd, "install"); err != nil {
+			writeLine("WARNING: Dependency install had issues: " + err.Error())
+			writeLine(output)
+		} else {
+			writeLine("Dependencies installed")
+		}
+	}
+
+	writeLine("Running build...")
+	if output, err := execCommandDir(ctx, sourceDir, npmCmd, "run", "build"); err != nil {
+		writeLine("WARNING: Build had issues: " + err.Error())
+		writeLine(output)
+	} else {
+		writeLine("Build complete")
+	}
+}
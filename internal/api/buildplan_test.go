@@ -0,0 +1,57 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectBuildPlan(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		stack     string
+		port      int
+		publicDir string
+		runBuild  bool
+	}{
+		{name: "node server", files: map[string]string{"package.json": `{"scripts":{"start":"node index.js","build":"tsc"}}`}, stack: "node", port: 3000},
+		{name: "node spa", files: map[string]string{"package.json": `{"scripts":{"build":"vite build"}}`, "index.html": ""}, stack: "static", runBuild: true},
+		{name: "node prebuilt", files: map[string]string{"package.json": `{}`, "dist/index.html": ""}, stack: "static", publicDir: "dist"},
+		{name: "go", files: map[string]string{"go.mod": "module x"}, stack: "go", port: 8080},
+		{name: "python", files: map[string]string{"requirements.txt": "flask"}, stack: "python", port: 8000},
+		{name: "rails", files: map[string]string{"Gemfile": "", "bin/rails": ""}, stack: "rails", port: 3000},
+		{name: "build output", files: map[string]string{"_site/index.html": ""}, stack: "static", publicDir: "_site"},
+		{name: "html", files: map[string]string{"index.html": ""}, stack: "static", publicDir: "."},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, content := range tt.files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		plan := detectBuildPlan(dir, 0)
+		if plan == nil {
+			t.Fatalf("%s: no plan", tt.name)
+		}
+		if plan.Stack != tt.stack || plan.Port != tt.port || plan.PublicDir != tt.publicDir || plan.RunBuild != tt.runBuild {
+			t.Fatalf("%s: plan = %+v", tt.name, plan)
+		}
+	}
+
+	if plan := detectBuildPlan(t.TempDir(), 0); plan != nil {
+		t.Fatalf("empty source: plan = %+v, want nil", plan)
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x"), 0644)
+	if plan := detectBuildPlan(dir, 9000); plan.Port != 9000 {
+		t.Fatalf("configured port: plan.Port = %d, want 9000", plan.Port)
+	}
+}
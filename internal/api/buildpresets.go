@@ -0,0 +1,461 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+// dockerfileData is what a Dockerfile template is rendered with
+type dockerfileData struct {
+	Image        string // Base image of the build stage, or the only stage
+	RuntimeImage string // Base image of the final stage of multi-stage builds
+	Port         int
+	CopyLock     string // node: COPY of the package manifest and lockfile
+	Install      string // node: the install command for the lockfile
+}
+
+// dockerfileStack is a stack generated Dockerfiles are known for
+type dockerfileStack struct {
+	Name        string
+	Markers     []string // Files at the top of the source, any of which identifies the stack
+	Requires    string   // File that must also exist, to tell a framework from its language
+	Image       string   // Image key of the base image
+	RuntimeKey  string   // Image key of the final stage's image, if multi-stage
+	DefaultPort int      // Port the generated Dockerfile's server listens on unless configured
+}
+
+// dockerfileStacks are detected in this order, frameworks before their
+// language
+var dockerfileStacks = []dockerfileStack{
+	{Name: "deno", Markers: []string{"deno.json", "deno.jsonc"}, Image: "deno", DefaultPort: 8000},
+	{Name: "node", Markers: []string{"package.json"}, Image: "node", DefaultPort: 3000},
+	{Name: "go", Markers: []string{"go.mod"}, Image: "go", RuntimeKey: "go-runtime", DefaultPort: 8080},
+	{Name: "python", Markers: []string{"requirements.txt"}, Image: "python", DefaultPort: 8000},
+	{Name: "pyproject", Markers: []string{"pyproject.toml"}, Image: "python", DefaultPort: 8000},
+	{Name: "rails", Markers: []string{"Gemfile"}, Requires: "bin/rails", Image: "ruby", DefaultPort: 3000},
+	{Name: "ruby", Markers: []string{"Gemfile"}, Image: "ruby", DefaultPort: 3000},
+	{Name: "rust", Markers: []string{"Cargo.toml"}, Image: "rust", RuntimeKey: "rust-runtime", DefaultPort: 8080},
+	{Name: "laravel", Markers: []string{"composer.json"}, Requires: "artisan", Image: "composer", RuntimeKey: "php", DefaultPort: 8000},
+	{Name: "php", Markers: []string{"composer.json"}, Image: "composer", RuntimeKey: "php", DefaultPort: 8000},
+	{Name: "java-maven", Markers: []string{"pom.xml"}, Image: "maven", RuntimeKey: "java-runtime", DefaultPort: 8080},
+	{Name: "java-gradle", Markers: []string{"build.gradle", "build.gradle.kts"}, Image: "gradle", RuntimeKey: "java-runtime", DefaultPort: 8080},
+}
+
+// builtinImages are the base images used unless a preset replaces them
+var builtinImages = map[string]string{
+	"node":         "node:20-alpine",
+	"deno":         "denoland/deno:2.1.4",
+	"go":           "golang:1.23-alpine",
+	"go-runtime":   "alpine:3.19",
+	"python":       "python:3.12-slim",
+	"ruby":         "ruby:3.3-slim",
+	"rust":         "rust:1.77-slim",
+	"rust-runtime": "debian:bookworm-slim",
+	"composer":     "composer:2",
+	"php":          "php:8.3-cli",
+	"maven":        "maven:3.9-eclipse-temurin-21",
+	"gradle":       "gradle:8-jdk21",
+	"java-runtime": "eclipse-temurin:21-jre",
+}
+
+// builtinDockerfiles are the Dockerfile templates per stack unless a preset
+// replaces them
+var builtinDockerfiles = map[string]string{
+	"node": `FROM {{.Image}}
+WORKDIR /app
+{{.CopyLock}}
+RUN {{.Install}}
+COPY . .
+RUN npm run build 2>/dev/null || true
+EXPOSE {{.Port}}
+CMD ["npm", "start"]
+`,
+	"go": `FROM {{.Image}} AS builder
+WORKDIR /app
+COPY go.mod go.sum ./
+RUN go mod download
+COPY . .
+RUN CGO_ENABLED=0 go build -o /app/server .
+
+FROM {{.RuntimeImage}}
+WORKDIR /app
+COPY --from=builder /app/server .
+EXPOSE {{.Port}}
+CMD ["./server"]
+`,
+	"python": `FROM {{.Image}}
+WORKDIR /app
+COPY requirements.txt .
+RUN pip install --no-cache-dir -r requirements.txt
+COPY . .
+EXPOSE {{.Port}}
+CMD ["python", "app.py"]
+`,
+	"pyproject": `FROM {{.Image}}
+WORKDIR /app
+COPY pyproject.toml .
+RUN pip install --no-cache-dir .
+COPY . .
+EXPOSE {{.Port}}
+CMD ["python", "-m", "app"]
+`,
+	"ruby": `FROM {{.Image}}
+WORKDIR /app
+COPY Gemfile Gemfile.lock ./
+RUN bundle install
+COPY . .
+EXPOSE {{.Port}}
+CMD ["ruby", "app.rb"]
+`,
+	"rails": `FROM {{.Image}}
+WORKDIR /app
+ENV RAILS_ENV=production BUNDLE_WITHOUT=development:test
+RUN apt-get update && apt-get install -y --no-install-recommends build-essential libpq-dev libyaml-dev && rm -rf /var/lib/apt/lists/*
+COPY Gemfile Gemfile.lock ./
+RUN bundle install
+COPY . .
+RUN SECRET_KEY_BASE_DUMMY=1 bin/rails assets:precompile 2>/dev/null || true
+EXPOSE {{.Port}}
+CMD ["bin/rails", "server", "-b", "0.0.0.0", "-p", "{{.Port}}"]
+`,
+	"rust": `FROM {{.Image}} AS builder
+WORKDIR /app
+COPY . .
+RUN cargo build --release && find target/release -maxdepth 1 -type f -perm -u+x -exec cp {} /app/server \;
+
+FROM {{.RuntimeImage}}
+WORKDIR /app
+COPY --from=builder /app/server .
+EXPOSE {{.Port}}
+CMD ["./server"]
+`,
+	"deno": `FROM {{.Image}}
+WORKDIR /app
+COPY . .
+RUN deno install
+EXPOSE {{.Port}}
+CMD ["deno", "task", "start"]
+`,
+	"laravel": `FROM {{.Image}} AS vendor
+WORKDIR /app
+COPY composer.json composer.lock* ./
+RUN composer install --no-dev --no-scripts --no-autoloader --prefer-dist --ignore-platform-reqs
+COPY . .
+RUN composer dump-autoload --optimize --no-dev --no-scripts
+
+FROM {{.RuntimeImage}}
+WORKDIR /app
+RUN docker-php-ext-install pdo_mysql
+COPY --from=vendor /app /app
+RUN php artisan package:discover --ansi && chown -R www-data:www-data storage bootstrap/cache
+USER www-data
+EXPOSE {{.Port}}
+CMD ["php", "artisan", "serve", "--host=0.0.0.0", "--port={{.Port}}"]
+`,
+	"php": `FROM {{.Image}} AS vendor
+WORKDIR /app
+COPY composer.json composer.lock* ./
+RUN composer install --no-dev --no-scripts --no-autoloader --prefer-dist --ignore-platform-reqs
+COPY . .
+RUN composer dump-autoload --optimize --no-dev --no-scripts
+
+FROM {{.RuntimeImage}}
+WORKDIR /app
+COPY --from=vendor /app /app
+EXPOSE {{.Port}}
+CMD ["sh", "-c", "if [ -d public ]; then exec php -S 0.0.0.0:{{.Port}} -t public; else exec php -S 0.0.0.0:{{.Port}}; fi"]
+`,
+	"java-maven": `FROM {{.Image}} AS builder
+WORKDIR /app
+COPY pom.xml .
+RUN mvn -B -q dependency:go-offline
+COPY . .
+RUN mvn -B -q -DskipTests package && cp "$(ls target/*.jar | grep -v -e '-sources.jar$' -e '-javadoc.jar$' | head -n 1)" /app/app.jar
+
+FROM {{.RuntimeImage}}
+WORKDIR /app
+COPY --from=builder /app/app.jar .
+ENV SERVER_PORT={{.Port}} PORT={{.Port}}
+EXPOSE {{.Port}}
+CMD ["java", "-jar", "app.jar"]
+`,
+	"java-gradle": `FROM {{.Image}} AS builder
+WORKDIR /app
+COPY . .
+RUN gradle build -x test --no-daemon -q && cp "$(ls build/libs/*.jar | grep -v -e '-plain.jar$' | head -n 1)" /app/app.jar
+
+FROM {{.RuntimeImage}}
+WORKDIR /app
+COPY --from=builder /app/app.jar .
+ENV SERVER_PORT={{.Port}} PORT={{.Port}}
+EXPOSE {{.Port}}
+CMD ["java", "-jar", "app.jar"]
+`,
+}
+
+var presetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// findDockerfileStack returns the stack named name, or nil
+func findDockerfileStack(name string) *dockerfileStack {
+	for i := range dockerfileStacks {
+		if dockerfileStacks[i].Name == name {
+			return &dockerfileStacks[i]
+		}
+	}
+	return nil
+}
+
+// nodeInstall returns the COPY line and install command for a Node lockfile
+func nodeInstall(lockfile string) (copyLock, install string) {
+	switch lockfile {
+	case "yarn.lock":
+		return "COPY package.json yarn.lock ./", "yarn install --frozen-lockfile"
+	case "pnpm-lock.yaml":
+		return "COPY package.json pnpm-lock.yaml ./", "corepack enable && pnpm install --frozen-lockfile"
+	default:
+		return "COPY package*.json ./", "npm install"
+	}
+}
+
+// detectDockerfileStack returns the stack of a source and its lockfile, or
+// nil if no Dockerfile can be generated for it
+func detectDockerfileStack(sourceDir string) (*dockerfileStack, string) {
+	for i, st := range dockerfileStacks {
+		if !slices.ContainsFunc(st.Markers, func(name string) bool { return fileExists(filepath.Join(sourceDir, name)) }) {
+			continue
+		}
+		if st.Requires != "" && !fileExists(filepath.Join(sourceDir, st.Requires)) {
+			continue
+		}
+		lockfile := ""
+		if st.Name == "node" {
+			for _, name := range []string{"yarn.lock", "pnpm-lock.yaml"} {
+				if fileExists(filepath.Join(sourceDir, name)) {
+					lockfile = name
+					break
+				}
+			}
+		}
+		return &dockerfileStacks[i], lockfile
+	}
+	return nil, ""
+}
+
+// renderDockerfile renders a stack's Dockerfile with the preset's images and
+// template, or the built-in ones where the preset (or nil) sets none
+func renderDockerfile(preset *config.BuildPreset, st *dockerfileStack, port int, lockfile string) (string, error) {
+	image := func(key string) string {
+		if preset != nil && preset.Images[key] != "" {
+			return preset.Images[key]
+		}
+		return builtinImages[key]
+	}
+	data := dockerfileData{Image: image(st.Image), Port: port}
+	if st.RuntimeKey != "" {
+		data.RuntimeImage = image(st.RuntimeKey)
+	}
+	if st.Name == "node" {
+		data.CopyLock, data.Install = nodeInstall(lockfile)
+	}
+
+	text := builtinDockerfiles[st.Name]
+	if preset != nil && preset.Dockerfiles[st.Name] != "" {
+		text = preset.Dockerfiles[st.Name]
+	}
+	tmpl, err := template.New(st.Name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%s Dockerfile template: %w", st.Name, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("%s Dockerfile template: %w", st.Name, err)
+	}
+	return out.String(), nil
+}
+
+// buildPreset returns the named preset, or the default preset when name is
+// empty. It returns nil for the built-in images.
+func (s *Server) buildPreset(name string) (*config.BuildPreset, error) {
+	if name == "" {
+		name = s.config.Build.DefaultPreset
+	}
+	if name == "" || name == "builtin" {
+		return nil, nil
+	}
+	preset := s.config.Build.Preset(name)
+	if preset == nil {
+		return nil, fmt.Errorf("unknown build preset %q", name)
+	}
+	return preset, nil
+}
+
+// generateDockerfile generates a Dockerfile for the stack detected in
+// sourceDir from a build preset (the default one if presetName is empty). It
+// returns "" if the stack isn't known.
+func (s *Server) generateDockerfile(sourceDir string, port int, presetName string) (string, error) {
+	st, lockfile := detectDockerfileStack(sourceDir)
+	if st == nil {
+		return "", nil
+	}
+	if port == 0 {
+		port = st.DefaultPort
+	}
+	preset, err := s.buildPreset(presetName)
+	if err != nil {
+		return "", err
+	}
+	return renderDockerfile(preset, st, port, lockfile)
+}
+
+// validateBuildConfig checks that preset names are unique, keys are known
+// and templates render, and that the default preset exists
+func validateBuildConfig(b config.BuildConfig) error {
+	seen := map[string]bool{}
+	for _, p := range b.Presets {
+		if !presetNamePattern.MatchString(p.Name) || p.Name == "builtin" || p.Name == "default" {
+			return fmt.Errorf("invalid preset name %q (lowercase letters, digits and dashes)", p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("preset %q is defined twice", p.Name)
+		}
+		seen[p.Name] = true
+		for key, image := range p.Images {
+			if _, ok := builtinImages[key]; !ok {
+				return fmt.Errorf("preset %s: unknown image key %q", p.Name, key)
+			}
+			if image == "" || strings.ContainsAny(image, " \t\r\n") {
+				return fmt.Errorf("preset %s: invalid image %q for %s", p.Name, image, key)
+			}
+		}
+		for stack := range p.Dockerfiles {
+			if findDockerfileStack(stack) == nil {
+				return fmt.Errorf("preset %s: unknown stack %q", p.Name, stack)
+			}
+		}
+		for i := range dockerfileStacks {
+			if _, err := renderDockerfile(&p, &dockerfileStacks[i], 8080, ""); err != nil {
+				return fmt.Errorf("preset %s: %w", p.Name, err)
+			}
+		}
+	}
+	if b.DefaultPreset != "" && b.DefaultPreset != "builtin" && !seen[b.DefaultPreset] {
+		return fmt.Errorf("default_preset %q is not a preset", b.DefaultPreset)
+	}
+	return nil
+}
+
+// presetImages returns the base images a preset generates Dockerfiles with
+func presetImages(preset *config.BuildPreset) map[string]string {
+	images := make(map[string]string, len(builtinImages))
+	for key, image := range builtinImages {
+		images[key] = image
+		if preset != nil && preset.Images[key] != "" {
+			images[key] = preset.Images[key]
+		}
+	}
+	return images
+}
+
+// handleListBuildPresets lists the build presets with the images each one
+// generates Dockerfiles with
+func (s *Server) handleListBuildPresets(w http.ResponseWriter, r *http.Request) {
+	type presetInfo struct {
+		config.BuildPreset
+		Images    map[string]string `json:"images"` // Effective images, built-ins included
+		Default   bool              `json:"default"`
+		Templated []string          `json:"templated,omitempty"` // Stacks with their own Dockerfile template
+	}
+	presets := []presetInfo{{
+		BuildPreset: config.BuildPreset{Name: "builtin", Description: "Basepod's built-in images"},
+		Images:      presetImages(nil),
+		Default:     s.config.Build.DefaultPreset == "" || s.config.Build.DefaultPreset == "builtin",
+	}}
+	for i := range s.config.Build.Presets {
+		p := &s.config.Build.Presets[i]
+		info := presetInfo{BuildPreset: *p, Images: presetImages(p), Default: p.Name == s.config.Build.DefaultPreset}
+		for stack := range p.Dockerfiles {
+			info.Templated = append(info.Templated, stack)
+		}
+		sort.Strings(info.Templated)
+		presets = append(presets, info)
+	}
+
+	stacks := make([]string, 0, len(dockerfileStacks))
+	for _, st := range dockerfileStacks {
+		stacks = append(stacks, st.Name)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"presets": presets,
+		"stacks":  stacks,
+	})
+}
+
+// handleUpdateBuildPresets replaces the build presets and the default one
+func (s *Server) handleUpdateBuildPresets(w http.ResponseWriter, r *http.Request) {
+	var req config.BuildConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateBuildConfig(req); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.config.Build = req
+	if err := s.config.Save(); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to save config: "+err.Error())
+		return
+	}
+	s.logActivity("user", "build_presets_update", "system", "", "", "success", fmt.Sprintf("%d presets, default %q", len(req.Presets), req.DefaultPreset))
+	s.handleListBuildPresets(w, r)
+}
+
+// handleRenderPresetDockerfile renders a preset's Dockerfile for a stack, for
+// bp init: ?stack=node&port=3000&lockfile=yarn.lock. The preset "default"
+// is whichever one is the default.
+func (s *Server) handleRenderPresetDockerfile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name == "default" {
+		name = ""
+	}
+	preset, err := s.buildPreset(name)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	st := findDockerfileStack(r.URL.Query().Get("stack"))
+	if st == nil {
+		errorResponse(w, http.StatusBadRequest, "Unknown stack")
+		return
+	}
+	port, _ := strconv.Atoi(r.URL.Query().Get("port"))
+	if port <= 0 {
+		port = st.DefaultPort
+	}
+
+	dockerfile, err := renderDockerfile(preset, st, port, r.URL.Query().Get("lockfile"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	presetName := "builtin"
+	if preset != nil {
+		presetName = preset.Name
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"preset":     presetName,
+		"stack":      st.Name,
+		"dockerfile": dockerfile,
+	})
+}
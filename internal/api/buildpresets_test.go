@@ -0,0 +1,120 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestRenderDockerfile(t *testing.T) {
+	t.Parallel()
+
+	preset := &config.BuildPreset{
+		Name:   "company",
+		Images: map[string]string{"node": "registry.example.com/node:20-hardened", "go-runtime": "registry.example.com/distroless"},
+	}
+
+	node, err := renderDockerfile(preset, findDockerfileStack("node"), 3000, "yarn.lock")
+	if err != nil {
+		t.Fatalf("renderDockerfile(node) error = %v", err)
+	}
+	for _, want := range []string{"FROM registry.example.com/node:20-hardened\n", "RUN yarn install --frozen-lockfile\n", "EXPOSE 3000\n"} {
+		if !strings.Contains(node, want) {
+			t.Fatalf("node Dockerfile lacks %q:\n%s", want, node)
+		}
+	}
+
+	goFile, err := renderDockerfile(preset, findDockerfileStack("go"), 8080, "")
+	if err != nil {
+		t.Fatalf("renderDockerfile(go) error = %v", err)
+	}
+	if !strings.Contains(goFile, "FROM golang:1.23-alpine AS builder\n") || !strings.Contains(goFile, "FROM registry.example.com/distroless\n") {
+		t.Fatalf("go Dockerfile should keep the built-in builder and use the preset runtime:\n%s", goFile)
+	}
+
+	preset.Dockerfiles = map[string]string{"python": "FROM {{.Image}}\nUSER app\nEXPOSE {{.Port}}\n"}
+	python, err := renderDockerfile(preset, findDockerfileStack("python"), 8000, "")
+	if err != nil {
+		t.Fatalf("renderDockerfile(python) error = %v", err)
+	}
+	if python != "FROM python:3.12-slim\nUSER app\nEXPOSE 8000\n" {
+		t.Fatalf("python Dockerfile = %q", python)
+	}
+}
+
+func TestDetectDockerfileStack(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if st, _ := detectDockerfileStack(dir); st != nil {
+		t.Fatalf("empty dir detected as %s", st.Name)
+	}
+	os.WriteFile(filepath.Join(dir, "pyproject.toml"), nil, 0644)
+	if st, _ := detectDockerfileStack(dir); st == nil || st.Name != "pyproject" {
+		t.Fatalf("pyproject.toml detected as %v", st)
+	}
+	os.WriteFile(filepath.Join(dir, "package.json"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "pnpm-lock.yaml"), nil, 0644)
+	if st, lockfile := detectDockerfileStack(dir); st == nil || st.Name != "node" || lockfile != "pnpm-lock.yaml" {
+		t.Fatalf("node project detected as %v, %q", st, lockfile)
+	}
+
+	for _, tt := range []struct {
+		files []string
+		want  string
+		port  int
+	}{
+		{[]string{"composer.json"}, "php", 8000},
+		{[]string{"composer.json", "artisan"}, "laravel", 8000},
+		{[]string{"Gemfile"}, "ruby", 3000},
+		{[]string{"Gemfile", "bin/rails"}, "rails", 3000},
+		{[]string{"package.json", "deno.jsonc"}, "deno", 8000},
+		{[]string{"build.gradle.kts"}, "java-gradle", 8080},
+		{[]string{"pom.xml"}, "java-maven", 8080},
+		{[]string{"Cargo.toml"}, "rust", 8080},
+	} {
+		dir := t.TempDir()
+		for _, name := range tt.files {
+			os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+			os.WriteFile(filepath.Join(dir, name), nil, 0644)
+		}
+		st, _ := detectDockerfileStack(dir)
+		if st == nil || st.Name != tt.want || st.DefaultPort != tt.port {
+			t.Fatalf("%v detected as %+v, want %s on %d", tt.files, st, tt.want, tt.port)
+		}
+		dockerfile, err := renderDockerfile(nil, st, st.DefaultPort, "")
+		if err != nil || !strings.Contains(dockerfile, "EXPOSE "+strconv.Itoa(tt.port)+"\n") {
+			t.Fatalf("%s Dockerfile = %q, %v", tt.want, dockerfile, err)
+		}
+	}
+}
+
+func TestValidateBuildConfig(t *testing.T) {
+	t.Parallel()
+
+	valid := config.BuildConfig{
+		DefaultPreset: "company-node",
+		Presets:       []config.BuildPreset{{Name: "company-node", Images: map[string]string{"node": "registry.example.com/node:20"}}},
+	}
+	if err := validateBuildConfig(valid); err != nil {
+		t.Fatalf("validateBuildConfig(valid) = %v", err)
+	}
+
+	invalid := []config.BuildConfig{
+		{DefaultPreset: "missing"},
+		{Presets: []config.BuildPreset{{Name: "Company"}}},
+		{Presets: []config.BuildPreset{{Name: "a"}, {Name: "a"}}},
+		{Presets: []config.BuildPreset{{Name: "a", Images: map[string]string{"java": "openjdk"}}}},
+		{Presets: []config.BuildPreset{{Name: "a", Images: map[string]string{"node": "node:20\nRUN curl evil"}}}},
+		{Presets: []config.BuildPreset{{Name: "a", Dockerfiles: map[string]string{"go": "FROM {{.Base}}"}}}},
+	}
+	for _, b := range invalid {
+		if err := validateBuildConfig(b); err == nil {
+			t.Fatalf("validateBuildConfig(%+v) accepted", b)
+		}
+	}
+}
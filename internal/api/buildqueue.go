@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultMaxParallelBuilds is how many image builds run at once when
+// build.max_parallel is not set; the rest wait in the build queue
+const defaultMaxParallelBuilds = 2
+
+// BuildInfo describes a queued or running build
+type BuildInfo struct {
+	ID        string     `json:"id"`
+	AppID     string     `json:"app_id,omitempty"`
+	AppName   string     `json:"app_name"`
+	Source    string     `json:"source"`             // upload or git
+	Status    string     `json:"status"`             // queued or running
+	Position  int        `json:"position,omitempty"` // 1-based place in the queue while queued
+	User      string     `json:"user,omitempty"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// queuedBuild is a build in the queue or holding a build slot
+type queuedBuild struct {
+	info    BuildInfo
+	cancel  context.CancelFunc
+	changed chan struct{} // Signalled when the queue moves
+}
+
+// buildQueue limits how many builds run at once. Builds wait their turn in
+// arrival order; the zero value is an empty queue.
+type buildQueue struct {
+	mu      sync.Mutex
+	running []*queuedBuild
+	waiting []*queuedBuild
+}
+
+// add puts a build at the end of the queue
+func (q *buildQueue) add(info BuildInfo, cancel context.CancelFunc) *queuedBuild {
+	b := &queuedBuild{info: info, cancel: cancel, changed: make(chan struct{}, 1)}
+	b.info.Status = "queued"
+	b.info.QueuedAt = time.Now()
+	q.mu.Lock()
+	q.waiting = append(q.waiting, b)
+	q.mu.Unlock()
+	return b
+}
+
+// wait blocks until b may run, at most limit builds at a time, calling queued
+// with b's place in the queue and the number of running builds whenever its
+// place changes. It returns ctx's error, with b out of the queue, if ctx is
+// done first.
+func (q *buildQueue) wait(ctx context.Context, b *queuedBuild, limit func() int, queued func(position, running int)) error {
+	lastPosition := 0
+	for {
+		q.mu.Lock()
+		position := 0
+		for i, w := range q.waiting {
+			if w == b {
+				position = i + 1
+				break
+			}
+		}
+		running := len(q.running)
+		if position == 1 && running < limit() {
+			q.waiting = q.waiting[1:]
+			q.running = append(q.running, b)
+			now := time.Now()
+			b.info.Status = "running"
+			b.info.StartedAt = &now
+			q.notifyLocked()
+			q.mu.Unlock()
+			return nil
+		}
+		q.mu.Unlock()
+
+		if position != lastPosition {
+			queued(position, running)
+			lastPosition = position
+		}
+		select {
+		case <-b.changed:
+		case <-ctx.Done():
+			q.release(b)
+			return ctx.Err()
+		}
+	}
+}
+
+// release takes b out of the queue or frees its build slot. Releasing a
+// build twice is harmless.
+func (q *buildQueue) release(b *queuedBuild) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running = removeBuild(q.running, b)
+	q.waiting = removeBuild(q.waiting, b)
+	q.notifyLocked()
+}
+
+// notifyLocked wakes the waiting builds to check their place in the queue
+func (q *buildQueue) notifyLocked() {
+	for _, w := range q.waiting {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func removeBuild(builds []*queuedBuild, b *queuedBuild) []*queuedBuild {
+	for i, other := range builds {
+		if other == b {
+			return append(builds[:i:i], builds[i+1:]...)
+		}
+	}
+	return builds
+}
+
+// list returns the running builds, then the queued ones in order
+func (q *buildQueue) list() []BuildInfo {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	builds := make([]BuildInfo, 0, len(q.running)+len(q.waiting))
+	for _, b := range q.running {
+		builds = append(builds, b.info)
+	}
+	for i, b := range q.waiting {
+		info := b.info
+		info.Position = i + 1
+		builds = append(builds, info)
+	}
+	return builds
+}
+
+// get returns the build with the given ID, or nil
+func (q *buildQueue) get(id string) *queuedBuild {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, list := range [][]*queuedBuild{q.running, q.waiting} {
+		for _, b := range list {
+			if b.info.ID == id {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// maxParallelBuilds returns build.max_parallel or the default
+func (s *Server) maxParallelBuilds() int {
+	if s.config != nil && s.config.Build.MaxParallel > 0 {
+		return s.config.Build.MaxParallel
+	}
+	return defaultMaxParallelBuilds
+}
+
+// acquireBuildSlot queues a build and blocks until it may run, reporting its
+// place in the queue through progress. DELETE /api/builds/{id} cancels the
+// returned context until release is called, which must happen once the image
+// is built.
+func (s *Server) acquireBuildSlot(ctx context.Context, info BuildInfo, progress func(string)) (context.Context, func(), error) {
+	info.ID = uuid.New().String()
+	ctx, cancel := context.WithCancel(ctx)
+	b := s.builds.add(info, cancel)
+	waited := false
+	err := s.builds.wait(ctx, b, s.maxParallelBuilds, func(position, running int) {
+		waited = true
+		progress(fmt.Sprintf("Build %s queued: position %d, %d of %d build slots busy", info.ID[:8], position, running, s.maxParallelBuilds()))
+	})
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("build %s cancelled while queued", info.ID[:8])
+	}
+	if waited {
+		progress("Build slot free, starting build")
+	}
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			s.builds.release(b)
+			started := *b.info.StartedAt
+			s.prom.observeBuild(started.Sub(b.info.QueuedAt), time.Since(started))
+		})
+	}, nil
+}
+
+// handleListBuilds lists running and queued builds of the apps the caller
+// can see
+func (s *Server) handleListBuilds(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	builds := []BuildInfo{}
+	for _, b := range s.builds.list() {
+		if sc.allows(b.AppID) {
+			builds = append(builds, b)
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"builds":       builds,
+		"max_parallel": s.maxParallelBuilds(),
+	})
+}
+
+// handleCancelBuild cancels a queued build, or stops a running one
+func (s *Server) handleCancelBuild(w http.ResponseWriter, r *http.Request) {
+	b := s.builds.get(r.PathValue("id"))
+	if b == nil {
+		errorResponse(w, http.StatusNotFound, "Build not found (it may have finished)")
+		return
+	}
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	if !sc.allows(b.info.AppID) {
+		errorResponse(w, http.StatusForbidden, "You don't have access to this app")
+		return
+	}
+	b.cancel()
+	s.logActivity("user", "build_cancel", "app", b.info.AppID, b.info.AppName, "success", b.info.ID)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "cancelled"})
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBuildQueue(t *testing.T) {
+	t.Parallel()
+
+	var q buildQueue
+	limit := func() int { return 1 }
+	noop := func(int, int) {}
+
+	first := q.add(BuildInfo{ID: "1", AppName: "a"}, func() {})
+	if err := q.wait(context.Background(), first, limit, noop); err != nil {
+		t.Fatalf("first build waited: %v", err)
+	}
+
+	second := q.add(BuildInfo{ID: "2", AppName: "b"}, func() {})
+	positions := make(chan int, 4)
+	started := make(chan error, 1)
+	go func() {
+		started <- q.wait(context.Background(), second, limit, func(position, running int) { positions <- position })
+	}()
+	if p := <-positions; p != 1 {
+		t.Fatalf("second build queued at position %d, want 1", p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	third := q.add(BuildInfo{ID: "3", AppName: "c"}, cancel)
+	cancelled := make(chan error, 1)
+	go func() { cancelled <- q.wait(ctx, third, limit, noop) }()
+
+	builds := q.list()
+	if len(builds) != 3 || builds[0].Status != "running" || builds[2].Position != 2 {
+		t.Fatalf("list = %+v", builds)
+	}
+
+	q.get("3").cancel()
+	if err := <-cancelled; err == nil {
+		t.Fatalf("cancelled build started")
+	}
+
+	q.release(first)
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("second build: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("second build did not start when the slot was freed")
+	}
+	if builds := q.list(); len(builds) != 1 || builds[0].ID != "2" {
+		t.Fatalf("list after release = %+v", builds)
+	}
+}
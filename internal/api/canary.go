@@ -0,0 +1,356 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// defaultCanaryWeight is the percent of requests a new canary gets
+const defaultCanaryWeight = 10
+
+// canaryContainerName is the container running an app's canary
+func canaryContainerName(appName string) string {
+	return "basepod-" + appName + "-canary"
+}
+
+// canaryPort is the host port of an app's canary
+func canaryPort(appID string) int {
+	return assignHostPort(appID + "#canary")
+}
+
+// appUpstreams lists the upstreams balanced with an app's own port in its
+// routes: its replicas, then its canary
+func appUpstreams(a *app.App) []string {
+	upstreams := replicaUpstreams(a)
+	if a.Canary != nil && a.Canary.HostPort > 0 {
+		upstreams = append(upstreams, fmt.Sprintf("localhost:%d", a.Canary.HostPort))
+	}
+	return upstreams
+}
+
+// upstreamWeights splits an app's requests between its containers and its
+// canary, nil (evenly) without a canary. The canary's weight is scaled by the
+// replica count so it gets its share of all requests, not of each replica's.
+func upstreamWeights(a *app.App) []int {
+	if a.Canary == nil || a.Canary.HostPort == 0 {
+		return nil
+	}
+	n := replicaCount(a)
+	weights := make([]int, 0, n+1)
+	for i := 0; i < n; i++ {
+		weights = append(weights, 100-a.Canary.Weight)
+	}
+	return append(weights, a.Canary.Weight*n)
+}
+
+// validateCanaryWeight checks a canary's percent of requests
+func validateCanaryWeight(weight int) error {
+	if weight < 1 || weight > 99 {
+		return fmt.Errorf("weight must be between 1 and 99")
+	}
+	return nil
+}
+
+// checkCanaryable reports why an app can't run a canary, if it can't
+func checkCanaryable(a *app.App) error {
+	switch {
+	case a.Type == app.AppTypeMLX || a.Type == app.AppTypeStatic || a.RedirectURL != "":
+		return fmt.Errorf("canaries are only supported for container apps")
+	case len(a.Services) > 0:
+		return fmt.Errorf("canaries aren't supported for multi-service apps")
+	case a.Status != app.StatusRunning || a.Ports.HostPort == 0:
+		return fmt.Errorf("app %s isn't running; deploy it normally first", a.Name)
+	}
+	return nil
+}
+
+// startCanary runs image next to an app's current container and, once it's
+// ready, sends it weight percent of the app's requests. A canary already
+// running is replaced.
+func (s *Server) startCanary(ctx context.Context, a *app.App, c app.Canary) error {
+	s.removeCanary(ctx, a)
+
+	c.HostPort = canaryPort(a.ID)
+	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
+		Name:     canaryContainerName(a.Name),
+		Image:    c.Image,
+		Env:      s.containerEnv(a),
+		Networks: []string{"basepod"},
+		Volumes:  s.appVolumeMounts(ctx, a),
+		Ports: map[string]string{
+			fmt.Sprintf("%d", a.Ports.ContainerPort): fmt.Sprintf("%d", c.HostPort),
+		},
+		Labels: map[string]string{
+			"basepod.app":    a.Name,
+			"basepod.app.id": a.ID,
+			"basepod.canary": "true",
+		},
+		Memory: a.Resources.MemoryBytes(),
+		CPUs:   a.Resources.CPUs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create canary container: %w", err)
+	}
+	c.ContainerID = containerID
+	if err := s.podman.StartContainer(ctx, containerID); err != nil {
+		_ = s.podman.RemoveContainer(ctx, containerID, true)
+		return fmt.Errorf("failed to start canary container: %w", err)
+	}
+
+	// Wait on the canary's own port before any traffic goes its way
+	probe := *a
+	probe.ContainerID = containerID
+	probe.Ports.HostPort = c.HostPort
+	if err := s.waitForAppReadiness(ctx, &probe); err != nil {
+		_ = s.podman.StopContainer(ctx, containerID, 10)
+		_ = s.podman.RemoveContainer(ctx, containerID, true)
+		return fmt.Errorf("canary did not become ready: %w", err)
+	}
+
+	c.StartedAt = time.Now()
+	a.Canary = &c
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		return err
+	}
+	s.refreshAppRoutes(a)
+	return nil
+}
+
+// removeCanary sends all of an app's requests back to its own containers
+// and removes its canary container, if any. The caller saves the app.
+func (s *Server) removeCanary(ctx context.Context, a *app.App) {
+	if s.podman == nil {
+		return
+	}
+	if c := a.Canary; c != nil {
+		a.Canary = nil
+		s.refreshAppRoutes(a)
+		if c.ContainerID != "" {
+			_ = s.podman.StopContainer(ctx, c.ContainerID, 10)
+			_ = s.podman.RemoveContainer(ctx, c.ContainerID, true)
+		}
+	}
+	_ = s.podman.StopContainer(ctx, canaryContainerName(a.Name), 10)
+	_ = s.podman.RemoveContainer(ctx, canaryContainerName(a.Name), true)
+}
+
+// promoteCanary makes an app's canary its current release. All requests go
+// to the canary while the app's containers are recreated from its image, so
+// none are dropped; if they don't come up the old image is restored and the
+// canary keeps its share.
+func (s *Server) promoteCanary(ctx context.Context, a *app.App) (app.DeploymentRecord, error) {
+	c := *a.Canary
+
+	onlyCanary := *a
+	onlyCanary.Canary = nil
+	onlyCanary.Ports.HostPort = c.HostPort
+	onlyCanary.Resources.Replicas = 1
+	s.refreshAppRoutes(&onlyCanary)
+
+	oldImage := a.Image
+	a.Image = c.Image
+	err := s.recreateAppContainer(ctx, a)
+	if err == nil {
+		err = s.waitForAppReadiness(ctx, a)
+	}
+	if err != nil {
+		a.Image = oldImage
+		if rerr := s.recreateAppContainer(ctx, a); rerr != nil {
+			log.Printf("Canary %s: failed to restore %s: %v", a.Name, oldImage, rerr)
+		}
+		s.storage.UpdateApp(a)
+		s.refreshAppRoutes(a)
+		return app.DeploymentRecord{}, err
+	}
+
+	rec := app.DeploymentRecord{
+		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
+		Image:      c.Image,
+		CommitHash: c.CommitHash,
+		CommitMsg:  c.CommitMsg,
+		Branch:     c.Branch,
+		Status:     "success",
+		DeployedAt: time.Now(),
+	}
+	a.Deployments = append([]app.DeploymentRecord{rec}, a.Deployments...)
+	if len(a.Deployments) > 10 {
+		a.Deployments = a.Deployments[:10]
+	}
+
+	s.removeCanary(ctx, a)
+	a.UpdatedAt = time.Now()
+	return rec, s.storage.UpdateApp(a)
+}
+
+// reconcileCanary restarts a running app's canary after the server or its
+// container went down, or drops it if it can't be started
+func (s *Server) reconcileCanary(ctx context.Context, a *app.App, running map[string]bool) {
+	if a.Canary == nil || running[a.Canary.ContainerID] || running[canaryContainerName(a.Name)] {
+		return
+	}
+	if err := s.podman.StartContainer(ctx, a.Canary.ContainerID); err == nil {
+		return
+	}
+	log.Printf("Reconcile: canary of %s is gone, sending all traffic to the app", a.Name)
+	s.removeCanary(ctx, a)
+	s.storage.UpdateApp(a)
+}
+
+// handleStartCanary deploys an image next to an app's current release and
+// sends it a share of the app's requests
+func (s *Server) handleStartCanary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req app.CanaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Image == "" {
+		errorResponse(w, http.StatusBadRequest, "Image is required")
+		return
+	}
+	if req.Weight == 0 {
+		req.Weight = defaultCanaryWeight
+	}
+	if err := validateCanaryWeight(req.Weight); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkCanaryable(a); err != nil {
+		errorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if fw := s.checkFreeze(a.ID, a.Name, req.FreezeOverride); fw != nil {
+		freezeResponse(w, fw)
+		return
+	}
+
+	if err := s.podman.PullImage(ctx, req.Image); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to pull image: "+err.Error())
+		return
+	}
+	if err := s.startCanary(ctx, a, app.Canary{Image: req.Image, Weight: req.Weight}); err != nil {
+		s.storage.UpdateApp(a)
+		errorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	s.logActivity("user", "canary", "app", a.ID, a.Name, "success", fmt.Sprintf("%s at %d%%", req.Image, req.Weight))
+	jsonResponse(w, http.StatusOK, redactedApp(a))
+}
+
+// handleSetCanaryWeight changes the share of requests an app's canary gets
+func (s *Server) handleSetCanaryWeight(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Canary == nil {
+		errorResponse(w, http.StatusNotFound, "App has no canary")
+		return
+	}
+
+	var req app.CanaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateCanaryWeight(req.Weight); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.Canary.Weight = req.Weight
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.refreshAppRoutes(a)
+	s.logActivity("user", "canary_weight", "app", a.ID, a.Name, "success", fmt.Sprintf("%d%%", req.Weight))
+	jsonResponse(w, http.StatusOK, redactedApp(a))
+}
+
+// handlePromoteCanary makes an app's canary its current release
+func (s *Server) handlePromoteCanary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Canary == nil {
+		errorResponse(w, http.StatusNotFound, "App has no canary")
+		return
+	}
+
+	rec, err := s.promoteCanary(ctx, a)
+	if err != nil {
+		s.logActivity("user", "promote", "app", a.ID, a.Name, "failed", err.Error())
+		errorResponse(w, http.StatusBadGateway, "Promote failed, the canary keeps its share: "+err.Error())
+		return
+	}
+
+	s.recordDeployMarker(a, rec, "deploy")
+	s.logActivity("user", "promote", "app", a.ID, a.Name, "success", rec.Image)
+	s.sendNotifications("deploy_success", a.ID, a.Name, map[string]string{
+		"commit": rec.CommitHash,
+		"branch": rec.Branch,
+	})
+	jsonResponse(w, http.StatusOK, redactedApp(a))
+}
+
+// handleAbortCanary removes an app's canary, sending all requests back to
+// its current release
+func (s *Server) handleAbortCanary(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Canary == nil {
+		errorResponse(w, http.StatusNotFound, "App has no canary")
+		return
+	}
+
+	image := a.Canary.Image
+	s.removeCanary(r.Context(), a)
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logActivity("user", "abort_canary", "app", a.ID, a.Name, "success", image)
+	jsonResponse(w, http.StatusOK, redactedApp(a))
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// routeProxy finds the reverse_proxy handler of a route in the fake Caddy's
+// config
+func (ts *testServer) routeProxy(id string) map[string]interface{} {
+	ts.t.Helper()
+	var find func(node interface{}) map[string]interface{}
+	find = func(node interface{}) map[string]interface{} {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if n["handler"] == "reverse_proxy" {
+				return n
+			}
+			for _, v := range n {
+				if p := find(v); p != nil {
+					return p
+				}
+			}
+		case []interface{}:
+			for _, v := range n {
+				if p := find(v); p != nil {
+					return p
+				}
+			}
+		}
+		return nil
+	}
+	proxy := find(ts.caddyRoute(id))
+	if proxy == nil {
+		ts.t.Fatalf("route %s has no reverse_proxy handler", id)
+	}
+	return proxy
+}
+
+// proxyWeights lists a reverse_proxy handler's upstreams with their weights
+// (nil when requests are split evenly)
+func proxyWeights(proxy map[string]interface{}) (dials []string, weights []int) {
+	for _, u := range proxy["upstreams"].([]interface{}) {
+		dials = append(dials, u.(map[string]interface{})["dial"].(string))
+	}
+	lb, _ := proxy["load_balancing"].(map[string]interface{})
+	policy, _ := lb["selection_policy"].(map[string]interface{})
+	if policy["policy"] == "weighted_round_robin" {
+		for _, w := range policy["weights"].([]interface{}) {
+			weights = append(weights, int(w.(float64)))
+		}
+	}
+	return dials, weights
+}
+
+func TestCanaryDeploy(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "canary-web", Domain: "canary-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/deploy", app.DeployRequest{Image: "ghcr.io/example/web:1"}, &a); code != http.StatusOK {
+		t.Fatalf("deploy: status %d", code)
+	}
+
+	if code := ts.do("POST", "/api/apps/canary-web/canary", app.CanaryRequest{Image: "ghcr.io/example/web:2", Weight: 100}, nil); code != http.StatusBadRequest {
+		t.Fatalf("canary with weight 100: status %d, want 400", code)
+	}
+	if code := ts.do("POST", "/api/apps/canary-web/canary", app.CanaryRequest{Image: "ghcr.io/example/web:2"}, &a); code != http.StatusOK {
+		t.Fatalf("start canary: status %d", code)
+	}
+	if a.Canary == nil || a.Canary.Weight != defaultCanaryWeight || a.Image != "ghcr.io/example/web:1" {
+		t.Fatalf("app = %+v, want web:1 with a canary at the default weight", a)
+	}
+	canary := ts.container(canaryContainerName("canary-web"))
+	if canary == nil || canary.Image != "ghcr.io/example/web:2" || canary.State != "running" {
+		t.Fatalf("canary container = %+v, want web:2 running", canary)
+	}
+	main := fmt.Sprintf("localhost:%d", a.Ports.HostPort)
+	canaryUpstream := fmt.Sprintf("localhost:%d", a.Canary.HostPort)
+	dials, weights := proxyWeights(ts.routeProxy("basepod-canary-web"))
+	if fmt.Sprint(dials) != fmt.Sprint([]string{main, canaryUpstream}) || fmt.Sprint(weights) != "[90 10]" {
+		t.Fatalf("route upstreams = %v weights %v, want %s and the canary at 90/10", dials, weights, main)
+	}
+
+	if code := ts.do("PUT", "/api/apps/canary-web/canary", app.CanaryRequest{Weight: 50}, &a); code != http.StatusOK {
+		t.Fatalf("set weight: status %d", code)
+	}
+	if _, weights = proxyWeights(ts.routeProxy("basepod-canary-web")); fmt.Sprint(weights) != "[50 50]" {
+		t.Fatalf("weights after change = %v, want [50 50]", weights)
+	}
+
+	// Aborting sends everything back to the current release
+	a = app.App{}
+	if code := ts.do("POST", "/api/apps/canary-web/canary/abort", nil, &a); code != http.StatusOK {
+		t.Fatalf("abort: status %d", code)
+	}
+	if a.Canary != nil || ts.container(canaryContainerName("canary-web")) != nil {
+		t.Fatalf("canary after abort = %+v, want it and its container gone", a.Canary)
+	}
+	if dials, weights = proxyWeights(ts.routeProxy("basepod-canary-web")); len(dials) != 1 || weights != nil {
+		t.Fatalf("route after abort = %v weights %v, want only %s", dials, weights, main)
+	}
+	if code := ts.do("POST", "/api/apps/canary-web/canary/promote", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("promote without a canary: status %d, want 404", code)
+	}
+
+	// Promoting makes the canary's image the current release
+	ts.do("POST", "/api/apps/canary-web/canary", app.CanaryRequest{Image: "ghcr.io/example/web:3", Weight: 25}, nil)
+	a = app.App{}
+	if code := ts.do("POST", "/api/apps/canary-web/canary/promote", nil, &a); code != http.StatusOK {
+		t.Fatalf("promote: status %d", code)
+	}
+	if a.Canary != nil || a.Image != "ghcr.io/example/web:3" || len(a.Deployments) == 0 || a.Deployments[0].Image != "ghcr.io/example/web:3" {
+		t.Fatalf("app after promote = %+v, want web:3 recorded as the current release", a)
+	}
+	if c := ts.container("basepod-canary-web"); c == nil || c.Image != "ghcr.io/example/web:3" || c.State != "running" {
+		t.Fatalf("container after promote = %+v, want web:3 running", c)
+	}
+	if ts.container(canaryContainerName("canary-web")) != nil {
+		t.Fatal("canary container left behind after promote")
+	}
+	if dials, _ = proxyWeights(ts.routeProxy("basepod-canary-web")); len(dials) != 1 || dials[0] != main {
+		t.Fatalf("route after promote = %v, want only %s", dials, main)
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
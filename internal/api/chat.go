@@ -0,0 +1,311 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/base-go/basepod/internal/mlx"
+	"github.com/google/uuid"
+)
+
+// stopMatcher truncates streamed text at the first stop sequence. It holds back
+// enough trailing characters to catch a sequence split across chunks.
+type stopMatcher struct {
+	stops   []string
+	holdLen int
+	pending string
+}
+
+func newStopMatcher(stops []string) *stopMatcher {
+	m := &stopMatcher{}
+	for _, s := range stops {
+		if s == "" {
+			continue
+		}
+		m.stops = append(m.stops, s)
+		m.holdLen = max(m.holdLen, len(s)-1)
+	}
+	return m
+}
+
+// Feed adds text and returns what is safe to emit, and whether a stop sequence was hit
+func (m *stopMatcher) Feed(text string) (string, bool) {
+	m.pending += text
+	cut := -1
+	for _, s := range m.stops {
+		if i := strings.Index(m.pending, s); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut >= 0 {
+		out := m.pending[:cut]
+		m.pending = ""
+		return out, true
+	}
+	if len(m.pending) <= m.holdLen {
+		return "", false
+	}
+	out := m.pending[:len(m.pending)-m.holdLen]
+	m.pending = m.pending[len(out):]
+	return out, false
+}
+
+// Flush returns any held-back text once the stream ends
+func (m *stopMatcher) Flush() string {
+	out := m.pending
+	m.pending = ""
+	return out
+}
+
+// parseStop accepts the OpenAI "stop" field as a string or array of strings
+func parseStop(raw interface{}) []string {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var stops []string
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				stops = append(stops, str)
+			}
+		}
+		return stops
+	}
+	return nil
+}
+
+func (s *Server) trackGeneration(cancel context.CancelFunc) string {
+	id := uuid.New().String()
+	s.generationsMu.Lock()
+	if s.generations == nil {
+		s.generations = make(map[string]context.CancelFunc)
+	}
+	s.generations[id] = cancel
+	s.generationsMu.Unlock()
+	return id
+}
+
+func (s *Server) untrackGeneration(id string) {
+	s.generationsMu.Lock()
+	delete(s.generations, id)
+	s.generationsMu.Unlock()
+}
+
+// handleChatCompletions relays OpenAI chat completions to the running MLX model.
+// Streaming responses are re-framed as SSE; a client disconnect or /api/chat/cancel
+// aborts the upstream request so the model stops generating.
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	svc := mlx.GetService()
+	status := svc.GetStatus()
+	if !status.Running {
+		errorResponse(w, http.StatusServiceUnavailable, "No model is running. Start one with: bp model run <model>")
+		return
+	}
+	defer svc.TrackRequest()()
+	if model, _ := req["model"].(string); model == "" {
+		req["model"] = status.ActiveModel
+	}
+	stops := parseStop(req["stop"])
+	stream, _ := req["stream"].(bool)
+
+	body, _ := json.Marshal(req)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	id := s.trackGeneration(cancel)
+	defer s.untrackGeneration(id)
+
+	upstreamURL := fmt.Sprintf("http://localhost:%d/v1/chat/completions", status.Port)
+	upstreamReq, err := http.NewRequestWithContext(ctx, "POST", upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	upstreamReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(upstreamReq)
+	if err != nil {
+		errorResponse(w, http.StatusBadGateway, "Model server unavailable: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("X-Generation-ID", id)
+	if resp.StatusCode != http.StatusOK || !stream {
+		relayCompletion(w, resp, stops)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorResponse(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	relayStream(w, flusher, resp.Body, stops, cancel)
+
+	if ctx.Err() != nil && r.Context().Err() != nil {
+		return // Client went away
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// relayStream re-frames an upstream SSE completion stream for the client,
+// cutting it at the first stop sequence and cancelling the upstream request
+// there
+func relayStream(w io.Writer, flusher http.Flusher, body io.Reader, stops []string, cancel context.CancelFunc) {
+	matcher := newStopMatcher(stops)
+	var last map[string]interface{}
+	writeEvent := func(chunk map[string]interface{}) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	// emit sends text as a content delta, reusing the last upstream chunk's metadata
+	emit := func(text, finish string) {
+		if text == "" && finish == "" {
+			return
+		}
+		chunk := map[string]interface{}{"object": "chat.completion.chunk"}
+		for _, k := range []string{"id", "created", "model"} {
+			if last != nil && last[k] != nil {
+				chunk[k] = last[k]
+			}
+		}
+		choice := map[string]interface{}{"index": 0, "delta": map[string]interface{}{}}
+		if text != "" {
+			choice["delta"] = map[string]interface{}{"content": text}
+		}
+		if finish != "" {
+			choice["finish_reason"] = finish
+		}
+		chunk["choices"] = []interface{}{choice}
+		writeEvent(chunk)
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			break
+		}
+
+		var chunk map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			continue
+		}
+		last = chunk
+
+		content, finish := chunkDelta(chunk)
+		if len(matcher.stops) == 0 {
+			writeEvent(chunk)
+			if finish != "" {
+				break
+			}
+			continue
+		}
+
+		out, stopped := matcher.Feed(content)
+		if stopped {
+			emit(out, "stop")
+			cancel() // Stop generating upstream
+			break
+		}
+		if finish != "" {
+			emit(out+matcher.Flush(), finish)
+			break
+		}
+		emit(out, "")
+	}
+	// A stream that ended on [DONE] or EOF rather than a finish reason or a
+	// stop sequence still owes the text held back for a possible stop
+	emit(matcher.Flush(), "")
+}
+
+// chunkDelta extracts the content delta and finish reason from a stream chunk
+func chunkDelta(chunk map[string]interface{}) (string, string) {
+	choices, _ := chunk["choices"].([]interface{})
+	if len(choices) == 0 {
+		return "", ""
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	delta, _ := choice["delta"].(map[string]interface{})
+	content, _ := delta["content"].(string)
+	finish, _ := choice["finish_reason"].(string)
+	return content, finish
+}
+
+// relayCompletion copies a non-streaming response, applying stop sequences to the message
+func relayCompletion(w http.ResponseWriter, resp *http.Response, stops []string) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	var completion map[string]interface{}
+	if len(stops) > 0 && resp.StatusCode == http.StatusOK && json.Unmarshal(data, &completion) == nil {
+		choices, _ := completion["choices"].([]interface{})
+		for _, c := range choices {
+			choice, _ := c.(map[string]interface{})
+			msg, _ := choice["message"].(map[string]interface{})
+			content, _ := msg["content"].(string)
+			if out, stopped := newStopMatcher(stops).Feed(content); stopped {
+				msg["content"] = out
+				choice["finish_reason"] = "stop"
+			}
+		}
+		jsonResponse(w, resp.StatusCode, completion)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	w.Write(data)
+}
+
+// handleChatCancel aborts a running generation by ID, or all of them when no ID is given
+func (s *Server) handleChatCancel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	s.generationsMu.Lock()
+	cancelled := 0
+	for id, cancel := range s.generations {
+		if req.ID == "" || req.ID == id {
+			cancel()
+			cancelled++
+		}
+	}
+	s.generationsMu.Unlock()
+
+	if req.ID != "" && cancelled == 0 {
+		errorResponse(w, http.StatusNotFound, "Generation not found")
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]int{"cancelled": cancelled})
+}
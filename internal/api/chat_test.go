@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStopMatcherCatchesSplitSequence(t *testing.T) {
+	t.Parallel()
+
+	m := newStopMatcher([]string{"</answer>"})
+	var out string
+	for _, chunk := range []string{"The answer is 42", "</ans", "wer> trailing"} {
+		text, stopped := m.Feed(chunk)
+		out += text
+		if stopped {
+			if out != "The answer is 42" {
+				t.Fatalf("unexpected output before stop: %q", out)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected stop sequence to be detected, got %q", out+m.Flush())
+}
+
+func TestStopMatcherFlushesHeldText(t *testing.T) {
+	t.Parallel()
+
+	m := newStopMatcher([]string{"STOP"})
+	out, stopped := m.Feed("hello ST")
+	if stopped {
+		t.Fatal("unexpected stop")
+	}
+	if got := out + m.Flush(); got != "hello ST" {
+		t.Fatalf("expected all text after flush, got %q", got)
+	}
+}
+
+func TestRelayStreamFlushesHeldTextAtEnd(t *testing.T) {
+	t.Parallel()
+
+	chunk := func(text string) string {
+		data, _ := json.Marshal(map[string]interface{}{"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": text}}}})
+		return "data: " + string(data) + "\n\n"
+	}
+	for name, upstream := range map[string]string{
+		"done": chunk("hello ") + chunk("ST") + "data: [DONE]\n\n",
+		"eof":  chunk("hello ") + chunk("ST"),
+	} {
+		rec := httptest.NewRecorder()
+		relayStream(rec, rec, strings.NewReader(upstream), []string{"STOP"}, func() {})
+
+		var got string
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			payload, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			var c map[string]interface{}
+			if err := json.Unmarshal([]byte(payload), &c); err != nil {
+				t.Fatalf("%s: undecodable event %q", name, payload)
+			}
+			text, _ := chunkDelta(c)
+			got += text
+		}
+		if got != "hello ST" {
+			t.Fatalf("%s: relayed %q, want %q", name, got, "hello ST")
+		}
+	}
+}
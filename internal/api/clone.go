@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/backup"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/google/uuid"
+)
+
+// CloneAppResponse is the new app and what was left behind copying it
+type CloneAppResponse struct {
+	App           *app.App `json:"app"`
+	Deployed      bool     `json:"deployed"`
+	CopiedVolumes []string `json:"copied_volumes"`
+	SkippedEnv    []string `json:"skipped_env"` // Secret-looking env vars not copied
+	Warnings      []string `json:"warnings"`
+}
+
+// cloneApp copies a's settings to a new app record. Runtime state, deploy
+// history, a running canary and the source's aliases stay behind, and secret-looking env vars
+// are dropped unless includeSecrets is set.
+func cloneApp(a *app.App, name, domain string, includeSecrets bool) (*app.App, []string) {
+	clone := *a
+	clone.ID = uuid.New().String()
+	clone.Name = name
+	clone.Domain = domain
+	clone.Aliases = nil
+	clone.ContainerID = ""
+	clone.Status = app.StatusPending
+	clone.Deployments = nil
+	clone.Services = nil
+	clone.Canary = nil
+	clone.LastExit = nil
+	clone.Health = nil
+	clone.TLSScan = nil
+	clone.Docs = nil
+	clone.TemplateUpdate = nil
+	clone.Ports.HostPort = 0
+	clone.Volumes = append([]app.VolumeMount(nil), a.Volumes...)
+	clone.CreatedAt = time.Now()
+	clone.UpdatedAt = time.Now()
+
+	var skipped []string
+	clone.Env = make(map[string]string, len(a.Env))
+	for k, v := range a.Env {
+		if !includeSecrets && app.IsSecretKey(k) {
+			skipped = append(skipped, k)
+			continue
+		}
+		clone.Env[k] = v
+	}
+	sort.Strings(skipped)
+	return &clone, skipped
+}
+
+// handleCloneApp copies an app's config, env and volumes under a new name
+// and domain, e.g. to spin up a staging copy of a production app
+func (s *Server) handleCloneApp(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	source, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if source == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req app.CloneAppRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Name == "" {
+		errorResponse(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if existing, _ := s.storage.GetAppByName(req.Name); existing != nil {
+		errorResponse(w, http.StatusConflict, "App with this name already exists")
+		return
+	}
+	domain := req.Domain
+	if domain == "" {
+		domain = s.config.GetAppDomain(req.Name)
+	}
+	if existing, _ := s.storage.GetAppByDomain(domain); existing != nil {
+		errorResponse(w, http.StatusConflict, "Domain already in use by another app")
+		return
+	}
+
+	clone, skipped := cloneApp(source, req.Name, domain, req.IncludeSecrets)
+	resp := CloneAppResponse{App: clone, CopiedVolumes: []string{}, SkippedEnv: skipped, Warnings: []string{}}
+	if resp.SkippedEnv == nil {
+		resp.SkippedEnv = []string{}
+	}
+	if len(source.Services) > 0 {
+		resp.Warnings = append(resp.Warnings, "Services aren't cloned; deploy the copy from its basepod.yaml to create them")
+	}
+	for _, v := range clone.Volumes {
+		if v.HostPath != "" {
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("Volume %s is a host directory (%s) shared with %s", v.ContainerPath, v.HostPath, source.Name))
+		}
+	}
+
+	if err := s.storage.CreateApp(clone); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.grantCreatorAccess(r, clone.ID)
+
+	if req.IncludeSecrets {
+		secrets, err := s.storage.SecretValues(source.ID)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, "Secrets not copied: "+err.Error())
+		}
+		for k, v := range secrets {
+			if err := s.storage.SetSecret(clone.ID, k, v); err != nil {
+				resp.Warnings = append(resp.Warnings, fmt.Sprintf("Secret %s not copied: %v", k, err))
+			}
+		}
+	} else if secrets, _ := s.storage.ListSecrets(source.ID); len(secrets) > 0 {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("%d secret(s) not copied; set them on %s or clone with secrets included", len(secrets), clone.Name))
+	}
+
+	if req.CopyVolumes {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{}) // Volume copies can be large
+		for _, v := range clone.Volumes {
+			if v.HostPath != "" {
+				continue
+			}
+			if err := s.copyAppVolume(ctx, source, clone, v); err != nil {
+				resp.Warnings = append(resp.Warnings, fmt.Sprintf("Volume %s not copied: %v", v.Name, err))
+				continue
+			}
+			resp.CopiedVolumes = append(resp.CopiedVolumes, appVolumeName(clone, v))
+		}
+	}
+
+	if !req.NoDeploy {
+		deployed, err := s.deployClone(ctx, source, clone)
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, "Deploy failed: "+err.Error())
+		}
+		resp.Deployed = deployed
+	}
+
+	s.logActivity("user", "clone", "app", clone.ID, clone.Name, "success", "from "+source.Name)
+	jsonResponse(w, http.StatusCreated, resp)
+}
+
+// copyAppVolume copies the data of one of source's named volumes into the
+// matching volume of clone
+func (s *Server) copyAppVolume(ctx context.Context, source, clone *app.App, v app.VolumeMount) error {
+	tmp, err := os.CreateTemp("", "clone-volume-*.tar")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := backup.ExportVolume(ctx, appVolumeName(source, v), tmp.Name()); err != nil {
+		return err
+	}
+	return backup.ImportVolume(ctx, s.ensureAppVolume(ctx, clone, v, ""), tmp.Name())
+}
+
+// deployClone starts a clone from the source's current image or static
+// files. It reports false for apps it can't start this way.
+func (s *Server) deployClone(ctx context.Context, source, clone *app.App) (bool, error) {
+	switch {
+	case clone.Type == app.AppTypeMLX:
+		go s.deployMLXApp(clone)
+		return true, nil
+	case clone.Type == app.AppTypeStatic:
+		paths, err := config.GetPaths()
+		if err != nil {
+			return false, err
+		}
+		from := filepath.Join(paths.Base, "data", "apps", source.Name)
+		to := filepath.Join(paths.Base, "data", "apps", clone.Name)
+		if err := copyTree(from, to); err != nil {
+			return false, err
+		}
+	case len(source.Services) > 0 || clone.Image == "":
+		return false, nil
+	default:
+		clone.Ports.HostPort = assignHostPort(clone.ID)
+		if err := s.recreateAppContainer(ctx, clone); err != nil {
+			clone.Status = app.StatusFailed
+			s.storage.UpdateApp(clone)
+			return false, err
+		}
+		if err := s.waitForAppReadiness(ctx, clone); err != nil {
+			clone.Status = app.StatusFailed
+			s.storage.UpdateApp(clone)
+			return false, fmt.Errorf("app did not become ready: %w", err)
+		}
+	}
+
+	clone.Status = app.StatusRunning
+	if err := s.storage.UpdateApp(clone); err != nil {
+		log.Printf("Clone %s: %v", clone.Name, err)
+	}
+	s.refreshAppRoutes(clone)
+	return true, nil
+}
+
+// copyTree copies a directory of regular files, keeping their modes
+func copyTree(from, to string) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+}
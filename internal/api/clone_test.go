@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestCloneApp(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{
+		Name:    "clone-shop",
+		Domain:  "clone-shop.test",
+		Env:     map[string]string{"APP_ENV": "production", "STRIPE_SECRET": "sk_live"},
+		Volumes: []app.VolumeMount{{Name: "uploads", ContainerPath: "/uploads"}},
+	}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/deploy", app.DeployRequest{Image: "ghcr.io/example/shop:1"}, &a); code != http.StatusOK {
+		t.Fatalf("deploy: status %d", code)
+	}
+	if code := ts.do("PUT", "/api/apps/clone-shop/secrets/API_TOKEN", map[string]string{"value": "t0ken"}, nil); code != http.StatusOK {
+		t.Fatalf("set secret: status %d", code)
+	}
+
+	if code := ts.do("POST", "/api/apps/clone-shop/canary", app.CanaryRequest{Image: "ghcr.io/example/shop:2"}, nil); code != http.StatusOK {
+		t.Fatalf("start canary: status %d", code)
+	}
+
+	var resp CloneAppResponse
+	if code := ts.do("POST", "/api/apps/clone-shop/clone", app.CloneAppRequest{Name: "clone-shop-staging"}, &resp); code != http.StatusCreated {
+		t.Fatalf("clone: status %d", code)
+	}
+	c := resp.App
+	if c.ID == a.ID || c.Domain != ts.config.GetAppDomain("clone-shop-staging") || !resp.Deployed || c.Status != app.StatusRunning {
+		t.Fatalf("clone = %+v (deployed %v), want a running copy on its own domain", c, resp.Deployed)
+	}
+	if stored, _ := ts.storage.GetApp(c.ID); c.Canary != nil || stored.Canary != nil {
+		t.Fatalf("clone canary = %+v, want the source's canary left behind", c.Canary)
+	}
+	if c.Env["APP_ENV"] != "production" || c.Env["STRIPE_SECRET"] != "" || len(resp.SkippedEnv) != 1 {
+		t.Fatalf("clone env = %v, skipped %v; want the secret left out", c.Env, resp.SkippedEnv)
+	}
+	if len(resp.Warnings) != 1 {
+		t.Fatalf("warnings = %v, want one about the uncopied secret", resp.Warnings)
+	}
+	container := ts.container("basepod-clone-shop-staging")
+	if container == nil || container.Image != "ghcr.io/example/shop:1" || container.State != "running" {
+		t.Fatalf("clone container = %+v, want the source's image running", container)
+	}
+	var inspected VolumeInfo
+	if code := ts.do("GET", "/api/volumes/basepod-clone-shop-staging-uploads", nil, &inspected); code != http.StatusOK {
+		t.Fatalf("clone volume: status %d, want its own uploads volume", code)
+	}
+	ts.waitForRoute("basepod-clone-shop-staging")
+
+	var withSecrets CloneAppResponse
+	ts.do("POST", "/api/apps/clone-shop/clone", app.CloneAppRequest{Name: "clone-shop-debug", IncludeSecrets: true, NoDeploy: true}, &withSecrets)
+	if withSecrets.App.Env["STRIPE_SECRET"] != "sk_live" || withSecrets.Deployed || withSecrets.App.Status != app.StatusPending {
+		t.Fatalf("clone with secrets = %+v (deployed %v)", withSecrets.App, withSecrets.Deployed)
+	}
+	var secrets []app.SecretInfo
+	ts.do("GET", "/api/apps/clone-shop-debug/secrets", nil, &secrets)
+	if len(secrets) != 1 || secrets[0].Key != "API_TOKEN" {
+		t.Fatalf("clone secrets = %+v, want API_TOKEN", secrets)
+	}
+
+	if code := ts.do("POST", "/api/apps/clone-shop/clone", app.CloneAppRequest{Name: "clone-shop-staging"}, nil); code != http.StatusConflict {
+		t.Fatalf("clone onto an existing name: status %d, want 409", code)
+	}
+	if code := ts.do("POST", "/api/apps/clone-shop/clone", app.CloneAppRequest{Name: "clone-shop-2", Domain: "clone-shop.test"}, nil); code != http.StatusConflict {
+		t.Fatalf("clone onto a taken domain: status %d, want 409", code)
+	}
+
+	// Running apps would show up in other tests' metrics
+	for _, id := range []string{a.ID, resp.App.ID, withSecrets.App.ID} {
+		if code := ts.do("DELETE", "/api/apps/"+id, nil, nil); code != http.StatusOK {
+			t.Fatalf("delete %s: status %d", id, code)
+		}
+	}
+}
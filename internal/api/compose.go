@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/base-go/basepod/internal/compose"
+)
+
+// maxComposeBytes caps the size of a Compose file sent for conversion
+const maxComposeBytes = 1 << 20
+
+// handleConvertCompose translates a docker-compose file into basepod.yaml
+// services, for the web UI and clients without bp
+func (s *Server) handleConvertCompose(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxComposeBytes)
+	var req struct {
+		Compose string `json:"compose"` // Contents of docker-compose.yml
+		Name    string `json:"name"`    // App name, unless the file sets name:
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Name == "" {
+		req.Name = "app"
+	}
+
+	project, warnings, err := compose.Convert([]byte(req.Compose), req.Name)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	out, err := project.YAML()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if warnings == nil {
+		warnings = []string{}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"project":      project,
+		"basepod_yaml": string(out),
+		"warnings":     warnings,
+	})
+}
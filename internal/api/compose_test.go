@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConvertComposeEndpoint(t *testing.T) {
+	ts := newTestServer(t)
+
+	var resp struct {
+		BasepodYAML string   `json:"basepod_yaml"`
+		Warnings    []string `json:"warnings"`
+	}
+	body := map[string]string{
+		"name":    "shop",
+		"compose": "services:\n  web:\n    image: nginx\n    ports: ['8080:80']\n    restart: always\n",
+	}
+	if code := ts.do("POST", "/api/compose/convert", body, &resp); code != http.StatusOK {
+		t.Fatalf("convert: status %d", code)
+	}
+	if !strings.Contains(resp.BasepodYAML, "name: shop") || !strings.Contains(resp.BasepodYAML, "port: 80") {
+		t.Fatalf("basepod_yaml = %q", resp.BasepodYAML)
+	}
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "restart") {
+		t.Fatalf("warnings = %v, want one about restart", resp.Warnings)
+	}
+
+	if code := ts.do("POST", "/api/compose/convert", map[string]string{"compose": "services: {}\n"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("empty compose: status %d, want 400", code)
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// containerMonitorInterval is how often running apps are checked for
+// containers that died on their own. Podman events report exits as they
+// happen; this catches any missed while the event stream was down.
+const containerMonitorInterval = time.Minute
+
+// clockDriftThreshold is how far ahead of the host Podman's clock must be
+// before an exit mentions it
+const clockDriftThreshold = time.Minute
+
+// signalNames names the signals containers are commonly killed with
+var signalNames = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	6:  "SIGABRT",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	15: "SIGTERM",
+}
+
+// explainExit returns a short explanation of why a container stopped.
+// memoryMB is the app's memory limit, if any, for out-of-memory kills.
+func explainExit(e *app.ContainerExit, memoryMB int64) string {
+	var reason string
+	switch {
+	case e.OOMKilled:
+		reason = "killed: out of memory"
+		if memoryMB > 0 {
+			reason += fmt.Sprintf(" (limit %d MB)", memoryMB)
+		}
+	case e.ExitCode == 0:
+		reason = "exited normally (exit code 0): the main process finished"
+	case e.ExitCode == 126:
+		reason = "failed to start (exit code 126): the command is not executable"
+	case e.ExitCode == 127:
+		reason = "failed to start (exit code 127): the command was not found"
+	case e.ExitCode == 137:
+		reason = "killed (exit code 137, SIGKILL): possibly out of memory or a forced stop"
+	case e.ExitCode == 139:
+		reason = "crashed (exit code 139, SIGSEGV): segmentation fault"
+	case e.ExitCode > 128 && e.ExitCode < 160:
+		sig := e.ExitCode - 128
+		name := signalNames[sig]
+		if name == "" {
+			name = fmt.Sprintf("signal %d", sig)
+		}
+		reason = fmt.Sprintf("killed (exit code %d, %s)", e.ExitCode, name)
+	default:
+		reason = fmt.Sprintf("exited with an error (exit code %d)", e.ExitCode)
+	}
+	if e.Error != "" {
+		reason += ": " + e.Error
+	}
+	if e.ClockDrift > 0 {
+		reason += fmt.Sprintf("; Podman's clock is %s ahead of this host", time.Duration(e.ClockDrift)*time.Second)
+	}
+	return reason
+}
+
+// containerExitFromInspect builds the exit record for a stopped container.
+// now is the host's time, to spot clock drift in Podman's timestamps.
+func containerExitFromInspect(inspect *podman.ContainerInspect, memoryMB int64, now time.Time) *app.ContainerExit {
+	e := &app.ContainerExit{
+		ExitCode:  inspect.State.ExitCode,
+		OOMKilled: inspect.State.OOMKilled,
+		Error:     strings.TrimSpace(inspect.State.Error),
+	}
+	if finished, err := time.Parse(time.RFC3339Nano, inspect.State.FinishedAt); err == nil && finished.Year() > 1 {
+		e.FinishedAt = finished
+		if drift := finished.Sub(now); drift > clockDriftThreshold {
+			e.ClockDrift = int(drift.Round(time.Second).Seconds())
+			e.FinishedAt = now
+		}
+	} else {
+		e.FinishedAt = now
+	}
+	e.Reason = explainExit(e, memoryMB)
+	return e
+}
+
+// inspectExit returns why an app's container stopped, or nil if it is still
+// running or can't be inspected
+func (s *Server) inspectExit(ctx context.Context, a *app.App) *app.ContainerExit {
+	if s.podman == nil || a.ContainerID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	inspect, err := s.podman.InspectContainer(ctx, a.ContainerID)
+	if err != nil || inspect.State.Running {
+		return nil
+	}
+	return containerExitFromInspect(inspect, a.Resources.Memory, time.Now())
+}
+
+// runContainerMonitor watches running apps for containers that stopped on
+// their own and records why
+func (s *Server) runContainerMonitor() {
+	ticker := time.NewTicker(containerMonitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.healthStop:
+			return
+		case <-ticker.C:
+			s.checkContainerExits()
+		}
+	}
+}
+
+// checkContainerExits marks running apps whose container has exited as
+// stopped or failed, adds the exit to the app's activity and notifies
+func (s *Server) checkContainerExits() {
+	if s.podman == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	containers, err := s.podman.ListContainers(ctx, true)
+	if err != nil {
+		return
+	}
+	states := map[string]string{}
+	for _, c := range containers {
+		states[c.ID] = c.State
+	}
+
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return
+	}
+	for i := range apps {
+		a := &apps[i]
+		if a.Status != app.StatusRunning || a.Type == app.AppTypeMLX || a.ContainerID == "" {
+			continue
+		}
+		// Missing containers are left to reconcile; created ones haven't started yet
+		state, ok := states[a.ContainerID]
+		if !ok || (state != "exited" && state != "stopped" && state != "dead") {
+			continue
+		}
+		exit := s.inspectExit(ctx, a)
+		if exit == nil {
+			continue
+		}
+
+		// The app may have been stopped or redeployed since it was listed
+		current, err := s.storage.GetApp(a.ID)
+		if err != nil || current == nil || current.Status != app.StatusRunning || current.ContainerID != a.ContainerID {
+			continue
+		}
+		s.recordContainerExit(current, exit)
+	}
+}
+
+// recordContainerExit saves why an app's container stopped and reports it
+func (s *Server) recordContainerExit(a *app.App, exit *app.ContainerExit) {
+	a.LastExit = exit
+	a.Status = app.StatusFailed
+	status := "failed"
+	if exit.ExitCode == 0 && !exit.OOMKilled {
+		a.Status = app.StatusStopped
+		status = "warning"
+	}
+	if err := s.storage.UpdateApp(a); err != nil {
+		log.Printf("Failed to record container exit for %s: %v", a.Name, err)
+		return
+	}
+	log.Printf("Container for %s stopped: %s", a.Name, exit.Reason)
+	s.logActivity("system", "container_exit", "app", a.ID, a.Name, status, exit.Reason)
+
+	details := map[string]string{
+		"reason":      exit.Reason,
+		"exit_code":   fmt.Sprintf("%d", exit.ExitCode),
+		"finished_at": exit.FinishedAt.UTC().Format(time.RFC3339),
+	}
+	if exit.OOMKilled {
+		details["oom_killed"] = "true"
+	}
+	if exit.ClockDrift > 0 {
+		details["clock_drift_seconds"] = fmt.Sprintf("%d", exit.ClockDrift)
+	}
+	s.sendNotifications("container_exit", a.ID, a.Name, details)
+}
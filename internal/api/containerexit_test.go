@@ -0,0 +1,64 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+func TestExplainExit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		exit     app.ContainerExit
+		memoryMB int64
+		want     string
+	}{
+		{"oom", app.ContainerExit{ExitCode: 137, OOMKilled: true}, 512, "out of memory (limit 512 MB)"},
+		{"clean", app.ContainerExit{ExitCode: 0}, 0, "exited normally"},
+		{"sigkill", app.ContainerExit{ExitCode: 137}, 0, "SIGKILL"},
+		{"sigterm", app.ContainerExit{ExitCode: 143}, 0, "exit code 143, SIGTERM"},
+		{"segfault", app.ContainerExit{ExitCode: 139}, 0, "segmentation fault"},
+		{"not found", app.ContainerExit{ExitCode: 127}, 0, "command was not found"},
+		{"error", app.ContainerExit{ExitCode: 1}, 0, "exited with an error (exit code 1)"},
+		{"podman error", app.ContainerExit{ExitCode: 125, Error: "crun: executable file not found"}, 0, ": crun: executable file not found"},
+		{"drift", app.ContainerExit{ExitCode: 1, ClockDrift: 300}, 0, "Podman's clock is 5m0s ahead"},
+	}
+	for _, c := range cases {
+		if got := explainExit(&c.exit, c.memoryMB); !strings.Contains(got, c.want) {
+			t.Fatalf("%s: explainExit = %q, want it to contain %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestContainerExitFromInspect(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inspect := &podman.ContainerInspect{}
+	inspect.State.ExitCode = 137
+	inspect.State.OOMKilled = true
+	inspect.State.FinishedAt = now.Add(-time.Minute).Format(time.RFC3339Nano)
+
+	e := containerExitFromInspect(inspect, 256, now)
+	if !e.OOMKilled || e.ExitCode != 137 || !e.FinishedAt.Equal(now.Add(-time.Minute)) || e.ClockDrift != 0 {
+		t.Fatalf("exit = %+v", e)
+	}
+
+	// A finish time ahead of the host means Podman's clock has drifted
+	inspect.State.FinishedAt = now.Add(10 * time.Minute).Format(time.RFC3339Nano)
+	e = containerExitFromInspect(inspect, 0, now)
+	if e.ClockDrift != 600 || !e.FinishedAt.Equal(now) {
+		t.Fatalf("drifted exit = %+v, want 600s drift and the host's time", e)
+	}
+
+	// Podman reports the zero time for containers that never finished
+	inspect.State.FinishedAt = "0001-01-01T00:00:00Z"
+	if e = containerExitFromInspect(inspect, 0, now); !e.FinishedAt.Equal(now) {
+		t.Fatalf("zero finish time = %v, want %v", e.FinishedAt, now)
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/cron"
+	"github.com/google/uuid"
+)
+
+// cronJitter spreads restarts and redeploys scheduled for the same minute so
+// apps sharing a schedule don't all go down at once
+const cronJitter = 2 * time.Minute
+
+// validateCronJob checks the schedule and action of a job, defaulting the action
+func validateCronJob(job *app.CronJob) error {
+	if _, err := cron.Parse(job.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	switch job.Action {
+	case "":
+		job.Action = app.CronActionCommand
+	case app.CronActionCommand, app.CronActionRun, app.CronActionRestart, app.CronActionRedeploy:
+	default:
+		return fmt.Errorf("invalid action %q: must be command, run, restart, or redeploy", job.Action)
+	}
+	if (job.Action == app.CronActionCommand || job.Action == app.CronActionRun) && job.Command == "" {
+		return fmt.Errorf("command is required for %s jobs", job.Action)
+	}
+	return nil
+}
+
+// nextCronRun computes when a job should next run after t. Restart and
+// redeploy jobs get a random delay of up to cronJitter.
+func nextCronRun(job *app.CronJob, t time.Time) *time.Time {
+	schedule, err := cron.Parse(job.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := schedule.Next(t)
+	if next.IsZero() {
+		return nil
+	}
+	if job.Action == app.CronActionRestart || job.Action == app.CronActionRedeploy {
+		next = next.Add(time.Duration(rand.Int63n(int64(cronJitter))))
+	}
+	return &next
+}
+
+// runCronScheduler runs enabled cron jobs when they come due
+func (s *Server) runCronScheduler() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.runDueCronJobs(time.Now())
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+func (s *Server) runDueCronJobs(now time.Time) {
+	jobs, err := s.storage.ListEnabledCronJobs()
+	if err != nil {
+		log.Printf("Cron: %v", err)
+		return
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		if job.NextRun == nil {
+			job.NextRun = nextCronRun(job, now)
+			s.storage.UpdateCronJob(job)
+			continue
+		}
+		if now.Before(*job.NextRun) {
+			continue
+		}
+
+		job.NextRun = nextCronRun(job, now)
+		s.storage.UpdateCronJob(job)
+
+		a, err := s.storage.GetApp(job.AppID)
+		if err != nil || a == nil {
+			continue
+		}
+		if _, err := s.executeCronJob(job, a, "system"); err != nil {
+			log.Printf("Cron job %s (%s): %v", job.Name, a.Name, err)
+		}
+	}
+}
+
+// executeCronJob starts a job in the background and returns its execution record
+func (s *Server) executeCronJob(job *app.CronJob, a *app.App, actor string) (*app.CronExecution, error) {
+	ctx := context.Background()
+	action := job.Action
+	if action == "" {
+		action = app.CronActionCommand
+	}
+
+	var run func() (string, error)
+	switch action {
+	case app.CronActionCommand:
+		if a.ContainerID == "" {
+			return nil, fmt.Errorf("App has no running container")
+		}
+		execID, err := s.podman.ExecCreateDetached(ctx, a.ContainerID, []string{"/bin/sh", "-c", job.Command})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create exec: %w", err)
+		}
+		run = func() (string, error) {
+			return s.podman.ExecStart(ctx, execID)
+		}
+	case app.CronActionRun:
+		if a.Type == app.AppTypeMLX || a.Image == "" {
+			return nil, fmt.Errorf("App has no image to run the job in")
+		}
+		run = func() (string, error) {
+			return s.runJobContainer(ctx, a, job)
+		}
+	case app.CronActionRestart:
+		if a.Type == app.AppTypeMLX || (a.ContainerID == "" && a.Image == "") {
+			return nil, fmt.Errorf("App cannot be restarted by schedule")
+		}
+		run = func() (string, error) {
+			if err := s.recreateAppContainer(ctx, a); err != nil {
+				return "", err
+			}
+			if err := s.waitForAppReadiness(ctx, a); err != nil {
+				a.Status = app.StatusFailed
+				s.storage.UpdateApp(a)
+				return "", fmt.Errorf("App did not become ready: %w", err)
+			}
+			a.Status = app.StatusRunning
+			s.storage.UpdateApp(a)
+			return "Container recreated", nil
+		}
+	case app.CronActionRedeploy:
+		if a.Type == app.AppTypeMLX || a.Image == "" {
+			return nil, fmt.Errorf("App cannot be redeployed by schedule")
+		}
+		run = func() (string, error) {
+			if fw := s.activeFreeze(); fw != nil {
+				return "", fmt.Errorf("deploys are frozen: %s (%s)", fw.Name, describeFreezeWindow(fw))
+			}
+			if _, err := s.redeployImage(ctx, a, true); err != nil {
+				return "", err
+			}
+			return "Redeployed " + a.Image, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown cron action: %s", action)
+	}
+
+	// Record execution
+	cronExec := &app.CronExecution{
+		ID:        uuid.New().String(),
+		CronJobID: job.ID,
+		StartedAt: time.Now(),
+		Status:    "running",
+	}
+	s.storage.CreateCronExecution(cronExec)
+
+	go func() {
+		output, cmdErr := run()
+		if cmdErr != nil && output == "" {
+			output = cmdErr.Error()
+		}
+		now := time.Now()
+		cronExec.EndedAt = &now
+		cronExec.Output = output
+		var exitErr jobExitError
+		if errors.As(cmdErr, &exitErr) {
+			cronExec.Status = "failed"
+			cronExec.ExitCode = int(exitErr)
+		} else if cmdErr != nil {
+			cronExec.Status = "failed"
+			cronExec.ExitCode = 1
+		} else {
+			cronExec.Status = "success"
+			cronExec.ExitCode = 0
+		}
+		s.storage.UpdateCronExecution(cronExec)
+
+		// Update job last run info
+		job.LastRun = &now
+		job.LastStatus = cronExec.Status
+		if cronExec.Status == "failed" {
+			job.LastError = output
+		} else {
+			job.LastError = ""
+		}
+		s.storage.UpdateCronJob(job)
+
+		s.logActivity(actor, "cron_"+action, "app", a.ID, a.Name, cronExec.Status, job.Name)
+	}()
+
+	return cronExec, nil
+}
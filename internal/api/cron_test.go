@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestValidateCronJob(t *testing.T) {
+	t.Parallel()
+
+	job := &app.CronJob{Schedule: "0 4 * * *", Command: "echo hi"}
+	if err := validateCronJob(job); err != nil {
+		t.Fatalf("validateCronJob: %v", err)
+	}
+	if job.Action != app.CronActionCommand {
+		t.Fatalf("Action = %q, want %q", job.Action, app.CronActionCommand)
+	}
+
+	for _, bad := range []*app.CronJob{
+		{Schedule: "nightly", Action: app.CronActionRestart},
+		{Schedule: "@daily", Action: "reboot"},
+		{Schedule: "@daily", Action: app.CronActionCommand},
+	} {
+		if err := validateCronJob(bad); err == nil {
+			t.Fatalf("validateCronJob(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestNextCronRunJitter(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2024, 6, 5, 10, 0, 0, 0, time.UTC)
+	want := time.Date(2024, 6, 6, 4, 0, 0, 0, time.UTC)
+
+	cmd := nextCronRun(&app.CronJob{Schedule: "0 4 * * *", Action: app.CronActionCommand}, from)
+	if cmd == nil || !cmd.Equal(want) {
+		t.Fatalf("command next run = %v, want %s", cmd, want)
+	}
+
+	for i := 0; i < 20; i++ {
+		next := nextCronRun(&app.CronJob{Schedule: "0 4 * * *", Action: app.CronActionRestart}, from)
+		if next == nil || next.Before(want) || !next.Before(want.Add(cronJitter)) {
+			t.Fatalf("restart next run = %v, want within %s of %s", next, cronJitter, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	rpprof "runtime/pprof"
+	"strings"
+)
+
+// debugEnabled reports whether the profiling endpoints are turned on
+func (s *Server) debugEnabled() bool {
+	return s.config != nil && s.config.Debug.Enabled
+}
+
+// setupDebugRoutes registers the pprof endpoints when debug.enabled is set.
+// Every endpoint requires an admin session.
+func (s *Server) setupDebugRoutes() {
+	if !s.debugEnabled() {
+		return
+	}
+
+	s.router.HandleFunc("GET /debug/pprof/", s.requireAdmin(pprof.Index))
+	s.router.HandleFunc("GET /debug/pprof/cmdline", s.requireAdmin(pprof.Cmdline))
+	s.router.HandleFunc("GET /debug/pprof/profile", s.requireAdmin(pprof.Profile))
+	s.router.HandleFunc("GET /debug/pprof/symbol", s.requireAdmin(pprof.Symbol))
+	s.router.HandleFunc("POST /debug/pprof/symbol", s.requireAdmin(pprof.Symbol))
+	s.router.HandleFunc("GET /debug/pprof/trace", s.requireAdmin(pprof.Trace))
+	s.router.HandleFunc("GET /api/debug/goroutines", s.requireAdmin(s.handleDebugGoroutines))
+}
+
+// isDebugRequest reports whether a request is for the pprof endpoints on the
+// basepod host (app domains keep their own /debug paths)
+func (s *Server) isDebugRequest(r *http.Request, host string) bool {
+	if !s.debugEnabled() || (r.URL.Path != "/debug/pprof" && !strings.HasPrefix(r.URL.Path, "/debug/pprof/")) {
+		return false
+	}
+	a, _ := s.storage.GetAppByDomainOrAlias(host)
+	return a == nil
+}
+
+// handleDebugGoroutines dumps every goroutine's stack as text. With
+// ?summary=1 identical stacks are grouped and counted instead.
+func (s *Server) handleDebugGoroutines(w http.ResponseWriter, r *http.Request) {
+	debug := 2
+	if r.URL.Query().Get("summary") == "1" {
+		debug = 1
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rpprof.Lookup("goroutine").WriteTo(w, debug)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/base-go/basepod/internal/auth"
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestDebugRoutesGated(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		enabled bool
+		want    int
+	}{
+		{"disabled", false, http.StatusNotFound},
+		{"enabled without session", true, http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		cfg := config.DefaultConfig()
+		cfg.Debug.Enabled = c.enabled
+		s := &Server{config: cfg, auth: auth.NewManager("hash"), router: http.NewServeMux()}
+		s.setupDebugRoutes()
+
+		for _, path := range []string{"/debug/pprof/", "/api/debug/goroutines"} {
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			if rec.Code != c.want {
+				t.Fatalf("%s: GET %s = %d, want %d", c.name, path, rec.Code, c.want)
+			}
+		}
+	}
+}
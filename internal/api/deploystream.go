@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Deploy phases reported by structured deploy output, in order
+const (
+	DeployPhaseUpload  = "upload"
+	DeployPhaseExtract = "extract"
+	DeployPhaseBuild   = "build"
+	DeployPhaseRun     = "run"
+	DeployPhaseVerify  = "verify"
+	DeployPhaseRoute   = "route"
+)
+
+var deployPhases = []string{DeployPhaseUpload, DeployPhaseExtract, DeployPhaseBuild, DeployPhaseRun, DeployPhaseVerify, DeployPhaseRoute}
+
+// DeployEvent is one line of structured deploy output. A deploy emits phase
+// events as it moves through deployPhases, log events for its output and
+// ends with exactly one result event.
+type DeployEvent struct {
+	Type       string    `json:"type"`             // phase, log or result
+	Phase      string    `json:"phase,omitempty"`  // Current phase (phase and log events)
+	Status     string    `json:"status,omitempty"` // phase: running, done, failed or skipped; result: success or failed
+	Level      string    `json:"level,omitempty"`  // log: info, warning or error
+	Message    string    `json:"message,omitempty"`
+	Step       int       `json:"step,omitempty"`  // phase: 1-based position among Steps phases
+	Steps      int       `json:"steps,omitempty"` // phase: total number of phases
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	App        string    `json:"app,omitempty"` // result: the deployed app
+	URL        string    `json:"url,omitempty"` // result: where the app is served
+	Time       time.Time `json:"time"`
+}
+
+// wantsDeployEvents reports whether a deploy request asked for JSON lines
+// events (?format=events or Accept: application/x-ndjson) instead of plain text
+func wantsDeployEvents(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "events" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
+
+// deployStream writes deploy output as plain text lines or as DeployEvents,
+// and keeps the plain text for the deployment's build log
+type deployStream struct {
+	w          io.Writer
+	flush      func()
+	events     bool
+	buildLog   strings.Builder
+	phase      string
+	phaseStart time.Time
+	reported   map[string]bool // Phases that have had an event
+	lastError  string
+	finished   bool
+	progress   func(DeployEvent) // If set, also gets phase and result events, e.g. for the event stream
+}
+
+func newDeployStream(w io.Writer, flush func(), events bool) *deployStream {
+	return &deployStream{w: w, flush: flush, events: events, reported: map[string]bool{}}
+}
+
+// line writes one line of output. Lines starting with "ERROR: " or
+// "WARNING: " are logged at that level; the last error becomes the failure
+// message if the deploy doesn't succeed.
+func (d *deployStream) line(msg string) {
+	d.buildLog.WriteString(msg + "\n")
+	if !d.events {
+		fmt.Fprintf(d.w, "%s\n", msg)
+		d.flush()
+		return
+	}
+
+	level := "info"
+	switch {
+	case strings.HasPrefix(msg, "ERROR: "):
+		level = "error"
+		d.lastError = strings.TrimPrefix(msg, "ERROR: ")
+	case strings.HasPrefix(msg, "WARNING: "):
+		level = "warning"
+	}
+	d.emit(DeployEvent{Type: "log", Phase: d.phase, Level: level, Message: msg})
+}
+
+// startPhase ends the current phase and starts name. Phases jumped over, such
+// as build for a static site without one, are reported as skipped.
+func (d *deployStream) startPhase(name string) {
+	d.endPhase("done")
+	for _, p := range deployPhases {
+		if p == name {
+			break
+		}
+		if !d.reported[p] {
+			d.emitPhase(p, "skipped", 0)
+		}
+	}
+	d.phase = name
+	d.phaseStart = time.Now()
+	d.emitPhase(name, "running", 0)
+}
+
+// endPhase reports the current phase as done or failed
+func (d *deployStream) endPhase(status string) {
+	if d.phase == "" {
+		return
+	}
+	d.emitPhase(d.phase, status, time.Since(d.phaseStart).Milliseconds())
+	d.phase = ""
+}
+
+// succeed ends the deploy successfully; phases not reached are skipped
+func (d *deployStream) succeed(appName, url string) {
+	d.endPhase("done")
+	for _, p := range deployPhases {
+		if !d.reported[p] {
+			d.emitPhase(p, "skipped", 0)
+		}
+	}
+	d.finished = true
+	d.emit(DeployEvent{Type: "result", Status: "success", App: appName, URL: url})
+}
+
+// close ends a deploy that didn't succeed as failed. It is deferred by the
+// handler so every return path produces a result event.
+func (d *deployStream) close(appName string) {
+	if d.finished || (!d.events && d.progress == nil) {
+		return
+	}
+	d.endPhase("failed")
+	d.finished = true
+	msg := d.lastError
+	if msg == "" {
+		msg = "deploy did not complete"
+	}
+	d.emit(DeployEvent{Type: "result", Status: "failed", App: appName, Message: msg})
+}
+
+func (d *deployStream) emitPhase(phase, status string, durationMS int64) {
+	step := 0
+	for i, p := range deployPhases {
+		if p == phase {
+			step = i + 1
+		}
+	}
+	d.reported[phase] = true
+	d.emit(DeployEvent{Type: "phase", Phase: phase, Status: status, Step: step, Steps: len(deployPhases), DurationMS: durationMS})
+}
+
+func (d *deployStream) emit(e DeployEvent) {
+	e.Time = time.Now()
+	if d.progress != nil && e.Type != "log" {
+		d.progress(e)
+	}
+	if !d.events {
+		return
+	}
+	data, _ := json.Marshal(e)
+	d.w.Write(append(data, '\n'))
+	d.flush()
+}
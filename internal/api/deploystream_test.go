@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func readDeployEvents(t *testing.T, out *bytes.Buffer) []DeployEvent {
+	t.Helper()
+	var events []DeployEvent
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var e DeployEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not an event: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestDeployStreamText(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	d := newDeployStream(&out, func() {}, false)
+	d.startPhase(DeployPhaseUpload)
+	d.line("Extracting source...")
+	d.line("ERROR: Build failed")
+	d.close("web")
+
+	want := "Extracting source...\nERROR: Build failed\n"
+	if out.String() != want {
+		t.Fatalf("text output = %q, want %q", out.String(), want)
+	}
+	if d.buildLog.String() != want {
+		t.Fatalf("build log = %q, want %q", d.buildLog.String(), want)
+	}
+}
+
+func TestDeployStreamEventsSuccess(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	d := newDeployStream(&out, func() {}, true)
+	d.startPhase(DeployPhaseUpload)
+	d.startPhase(DeployPhaseExtract)
+	d.startPhase(DeployPhaseRun) // A static site without a build step
+	d.line("WARNING: Failed to update Caddy")
+	d.succeed("site", "https://site.example.com")
+	d.close("site")
+
+	var got []string
+	for _, e := range readDeployEvents(t, &out) {
+		got = append(got, e.Type+":"+e.Phase+":"+e.Status+e.Level)
+	}
+	want := []string{
+		"phase:upload:running", "phase:upload:done",
+		"phase:extract:running", "phase:extract:done",
+		"phase:build:skipped", "phase:run:running",
+		"log:run:warning",
+		"phase:run:done", "phase:verify:skipped", "phase:route:skipped",
+		"result::success",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("events =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestDeployStreamEventsFailure(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	d := newDeployStream(&out, func() {}, true)
+	d.startPhase(DeployPhaseBuild)
+	d.line("ERROR: Build failed: exit status 1")
+	d.line("npm ERR! missing script: build")
+	d.close("web")
+
+	events := readDeployEvents(t, &out)
+	last := events[len(events)-1]
+	if last.Type != "result" || last.Status != "failed" || last.Message != "Build failed: exit status 1" {
+		t.Fatalf("result = %+v", last)
+	}
+	if phase := events[len(events)-2]; phase.Phase != DeployPhaseBuild || phase.Status != "failed" {
+		t.Fatalf("expected build phase to fail, got %+v", phase)
+	}
+}
+
+func TestDeployStreamProgress(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	d := newDeployStream(&out, func() {}, false)
+	var got []string
+	d.progress = func(e DeployEvent) { got = append(got, e.Type+":"+e.Phase+":"+e.Status) }
+	d.startPhase(DeployPhaseBuild)
+	d.line("ERROR: Build failed: exit status 1")
+	d.close("web")
+
+	want := []string{"phase:upload:skipped", "phase:extract:skipped", "phase:build:running", "phase:build:failed", "result::failed"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("progress =\n%v\nwant\n%v", got, want)
+	}
+	if out.String() != "ERROR: Build failed: exit status 1\n" {
+		t.Fatalf("plain text output = %q", out.String())
+	}
+}
@@ -0,0 +1,572 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/diskutil"
+)
+
+// Settings keys used to remember the last digest across restarts
+const (
+	digestLastWindowKey = "digest_last_window"
+	digestStateKey      = "digest_state"
+)
+
+// digestPeriod is how far back a digest looks when there is no earlier one
+const digestPeriod = 7 * 24 * time.Hour
+
+var digestMu sync.Mutex // Serializes digest sends
+
+// DigestReport is the weekly summary emailed to subscribers
+type DigestReport struct {
+	Product       string           `json:"product"`
+	From          time.Time        `json:"from"`
+	To            time.Time        `json:"to"`
+	Deploys       int              `json:"deploys"`
+	FailedDeploys int              `json:"failed_deploys"`
+	Apps          []DigestApp      `json:"apps"` // Biggest first
+	Incidents     []DigestIncident `json:"incidents"`
+	Disk          *DigestDisk      `json:"disk,omitempty"`    // Server-wide, so only for unscoped readers
+	Updates       []string         `json:"updates,omitempty"` // Server-wide, so only for unscoped readers
+}
+
+// DigestApp is one app's week
+type DigestApp struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Status        string   `json:"status"`
+	Deploys       int      `json:"deploys"`
+	FailedDeploys int      `json:"failed_deploys"`
+	Uptime        *float64 `json:"uptime,omitempty"` // Percent of passing health checks; unset without checks
+	Size          int64    `json:"size"`             // Image and volumes
+	Formatted     string   `json:"formatted"`
+}
+
+// DigestIncident is something that went wrong during the week
+type DigestIncident struct {
+	Time    time.Time `json:"time"`
+	AppID   string    `json:"app_id,omitempty"` // Empty for server-wide incidents
+	Target  string    `json:"target"`
+	Action  string    `json:"action"`
+	Details string    `json:"details,omitempty"`
+}
+
+// DigestDisk is the server's disk usage and how it changed since the last digest
+type DigestDisk struct {
+	Used      uint64  `json:"used"`
+	Total     uint64  `json:"total"`
+	Percent   float64 `json:"percent"`
+	Change    int64   `json:"change"` // Bytes since the last digest
+	Formatted string  `json:"formatted"`
+}
+
+// healthCounts are an app's cumulative health check totals
+type healthCounts struct {
+	Checks   int `json:"checks"`
+	Failures int `json:"failures"`
+}
+
+// digestState is what the last digest saw, so the next one can report changes
+type digestState struct {
+	SentAt   time.Time               `json:"sent_at"`
+	DiskUsed uint64                  `json:"disk_used"`
+	Health   map[string]healthCounts `json:"health"` // By app ID
+}
+
+// uptimePercent returns the share of passing health checks since prev.
+// Counters restart with the server; if they went backwards only cur counts.
+func uptimePercent(prev, cur healthCounts) *float64 {
+	if cur.Checks < prev.Checks || cur.Failures < prev.Failures {
+		prev = healthCounts{}
+	}
+	checks := cur.Checks - prev.Checks
+	if checks <= 0 {
+		return nil
+	}
+	pct := float64(checks-(cur.Failures-prev.Failures)) / float64(checks) * 100
+	return &pct
+}
+
+// isIncident reports whether an activity log entry belongs in the digest's
+// incident list
+func isIncident(l app.ActivityLog) bool {
+	return l.Status == "failed" || l.Action == "health_restart" || l.Action == "ai_restart" || l.Action == "daemon_limit"
+}
+
+// forScope returns the parts of the digest a reader limited to sc may see
+func (d DigestReport) forScope(sc appScope) DigestReport {
+	if sc == nil {
+		return d
+	}
+	out := d
+	out.Disk = nil
+	out.Updates = nil
+	out.Deploys, out.FailedDeploys = 0, 0
+	out.Apps = []DigestApp{}
+	for _, a := range d.Apps {
+		if sc[a.ID] {
+			out.Apps = append(out.Apps, a)
+			out.Deploys += a.Deploys
+			out.FailedDeploys += a.FailedDeploys
+		}
+	}
+	out.Incidents = []DigestIncident{}
+	for _, i := range d.Incidents {
+		if i.AppID != "" && sc[i.AppID] {
+			out.Incidents = append(out.Incidents, i)
+		}
+	}
+	return out
+}
+
+// buildDigest summarizes everything since the last digest
+func (s *Server) buildDigest(ctx context.Context, state digestState, now time.Time) (DigestReport, digestState) {
+	from := state.SentAt
+	if from.IsZero() || now.Sub(from) > 2*digestPeriod {
+		from = now.Add(-digestPeriod)
+	}
+	d := DigestReport{Product: s.config.Branding.ProductName(), From: from, To: now, Apps: []DigestApp{}, Incidents: []DigestIncident{}}
+	next := digestState{SentAt: now, Health: map[string]healthCounts{}}
+
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		log.Printf("Digest: failed to list apps: %v", err)
+	}
+	byID := map[string]int{}
+	for _, a := range apps {
+		byID[a.ID] = len(d.Apps)
+		d.Apps = append(d.Apps, DigestApp{ID: a.ID, Name: a.Name, Status: string(a.Status)})
+	}
+
+	// Deploys and incidents from the activity log
+	logs, err := s.storage.ListActivityLogsSince(from, now)
+	if err != nil {
+		log.Printf("Digest: failed to read activity: %v", err)
+	}
+	for _, l := range logs {
+		i, isApp := byID[l.TargetID]
+		isApp = isApp && l.TargetType == "app"
+		if isApp && (l.Action == "deploy" || l.Action == "rollback") {
+			d.Apps[i].Deploys++
+			d.Deploys++
+			if l.Status == "failed" {
+				d.Apps[i].FailedDeploys++
+				d.FailedDeploys++
+			}
+		}
+		if isIncident(l) {
+			incident := DigestIncident{Time: l.CreatedAt, Target: l.TargetName, Action: l.Action, Details: l.Details}
+			if isApp {
+				incident.AppID = l.TargetID
+			}
+			if len(incident.Details) > 200 {
+				incident.Details = incident.Details[:200] + "..."
+			}
+			d.Incidents = append(d.Incidents, incident)
+		}
+	}
+
+	// Uptime from health check counters
+	s.healthStatesMu.RLock()
+	for id, hs := range s.healthStates {
+		cur := healthCounts{Checks: hs.TotalChecks, Failures: hs.TotalFailures}
+		next.Health[id] = cur
+		if i, ok := byID[id]; ok {
+			d.Apps[i].Uptime = uptimePercent(state.Health[id], cur)
+		}
+	}
+	s.healthStatesMu.RUnlock()
+
+	// Size of each app's image and volumes
+	mountpoints := map[string]string{}
+	if volumes, err := s.podman.ListVolumes(ctx); err == nil {
+		for _, v := range volumes {
+			mountpoints[v.Name] = v.Mountpoint
+		}
+	}
+	images, _ := s.podman.ListImages(ctx)
+	for i := range apps {
+		a := &apps[i]
+		var size int64
+		if a.Image != "" {
+			if id := findImageID(images, a.Image); id != "" {
+				for _, img := range images {
+					if img.ID == id {
+						size += img.Size
+					}
+				}
+			}
+		}
+		for _, v := range a.Volumes {
+			if mp := mountpoints[appVolumeName(a, v)]; mp != "" {
+				size += diskutil.DirSize(mp)
+			}
+		}
+		d.Apps[byID[a.ID]].Size = size
+		d.Apps[byID[a.ID]].Formatted = diskutil.FormatBytes(size)
+	}
+	sort.SliceStable(d.Apps, func(i, j int) bool { return d.Apps[i].Size > d.Apps[j].Size })
+
+	// Disk trend
+	if paths, err := config.GetPaths(); err == nil {
+		if du, err := diskutil.GetDiskUsage(paths.Base); err == nil {
+			d.Disk = &DigestDisk{Used: du.Used, Total: du.Total, Percent: du.Percent, Formatted: du.Formatted.Used}
+			if state.DiskUsed > 0 {
+				d.Disk.Change = int64(du.Used) - int64(state.DiskUsed)
+			}
+			next.DiskUsed = du.Used
+		}
+	}
+
+	// Pending updates
+	if latest := latestReleaseVersion(); latest != "" && compareVersions(latest, s.version) > 0 {
+		d.Updates = append(d.Updates, fmt.Sprintf("%s %s is available (running %s)", d.Product, latest, s.version))
+	}
+
+	return d, next
+}
+
+// formatChange renders a byte delta like "+1.2 GB"
+func formatChange(delta int64) string {
+	if delta < 0 {
+		return "-" + diskutil.FormatBytes(-delta)
+	}
+	return "+" + diskutil.FormatBytes(delta)
+}
+
+// renderDigest returns the subject, HTML and text bodies of a digest email
+func renderDigest(d DigestReport, brand Branding, dashboardURL, unsubscribeURL string) (string, string, string) {
+	product := html.EscapeString(brand.Name)
+	period := fmt.Sprintf("%s – %s", d.From.Format("Jan 2"), d.To.Format("Jan 2, 2006"))
+	subject := fmt.Sprintf("%s weekly digest: %d deploys, %d incidents", brand.Name, d.Deploys, len(d.Incidents))
+
+	var h, t strings.Builder
+	h.WriteString(`<html><body style="font-family:sans-serif;">
+`)
+	if brand.LogoURL != "" {
+		fmt.Fprintf(&h, "<img src=\"%s\" alt=\"%s\" style=\"max-height:40px;\">\n", html.EscapeString(dashboardURL+brand.LogoURL), product)
+	}
+	fmt.Fprintf(&h, `<h2 style="color:%s;">%s weekly digest</h2>
+<p>%s</p>
+`, brand.AccentColor, product, period)
+	fmt.Fprintf(&t, "%s weekly digest\n%s\n\n", brand.Name, period)
+
+	fmt.Fprintf(&h, "<p><strong>%d</strong> deploys (%d failed) and <strong>%d</strong> incidents.</p>\n", d.Deploys, d.FailedDeploys, len(d.Incidents))
+	fmt.Fprintf(&t, "Deploys: %d (%d failed)\nIncidents: %d\n", d.Deploys, d.FailedDeploys, len(d.Incidents))
+
+	if d.Disk != nil {
+		trend := ""
+		if d.Disk.Change != 0 {
+			trend = fmt.Sprintf(" (%s since the last digest)", formatChange(d.Disk.Change))
+		}
+		fmt.Fprintf(&h, "<p>Disk: %s used, %.0f%%%s</p>\n", d.Disk.Formatted, d.Disk.Percent, trend)
+		fmt.Fprintf(&t, "Disk: %s used, %.0f%%%s\n", d.Disk.Formatted, d.Disk.Percent, trend)
+	}
+
+	if len(d.Apps) > 0 {
+		h.WriteString(`<h3>Biggest apps</h3>
+<table cellpadding="4"><tr><th align="left">App</th><th align="right">Size</th><th align="right">Uptime</th><th align="right">Deploys</th></tr>
+`)
+		t.WriteString("\nBiggest apps:\n")
+		for i, a := range d.Apps {
+			if i == 10 {
+				break
+			}
+			uptime := "–"
+			if a.Uptime != nil {
+				uptime = fmt.Sprintf("%.2f%%", *a.Uptime)
+			}
+			fmt.Fprintf(&h, "<tr><td>%s</td><td align=\"right\">%s</td><td align=\"right\">%s</td><td align=\"right\">%d</td></tr>\n", html.EscapeString(a.Name), a.Formatted, uptime, a.Deploys)
+			fmt.Fprintf(&t, "  %-24s %10s  uptime %-8s deploys %d\n", a.Name, a.Formatted, uptime, a.Deploys)
+		}
+		h.WriteString("</table>\n")
+	}
+
+	if len(d.Incidents) > 0 {
+		h.WriteString("<h3>Incidents</h3>\n<ul>\n")
+		t.WriteString("\nIncidents:\n")
+		for i, inc := range d.Incidents {
+			if i == 20 {
+				fmt.Fprintf(&h, "<li>and %d more</li>\n", len(d.Incidents)-i)
+				fmt.Fprintf(&t, "  and %d more\n", len(d.Incidents)-i)
+				break
+			}
+			line := fmt.Sprintf("%s %s %s", inc.Time.Format("Mon 15:04"), inc.Target, inc.Action)
+			if inc.Details != "" {
+				line += ": " + inc.Details
+			}
+			fmt.Fprintf(&h, "<li>%s</li>\n", html.EscapeString(line))
+			fmt.Fprintf(&t, "  %s\n", line)
+		}
+		h.WriteString("</ul>\n")
+	}
+
+	if len(d.Updates) > 0 {
+		h.WriteString("<h3>Pending updates</h3>\n<ul>\n")
+		t.WriteString("\nPending updates:\n")
+		for _, u := range d.Updates {
+			fmt.Fprintf(&h, "<li>%s</li>\n", html.EscapeString(u))
+			fmt.Fprintf(&t, "  %s\n", u)
+		}
+		h.WriteString("</ul>\n")
+	}
+
+	fmt.Fprintf(&h, `<p><a href="%s" style="color:%s;">Open %s</a></p>
+`, html.EscapeString(dashboardURL), brand.AccentColor, product)
+	if brand.SupportURL != "" {
+		fmt.Fprintf(&h, "<p>Need help? <a href=\"%s\">Contact support</a></p>\n", html.EscapeString(brand.SupportURL))
+	}
+	fmt.Fprintf(&h, `<p style="color:#888;font-size:12px;"><a href="%s" style="color:#888;">Unsubscribe</a> from this digest.</p>
+</body></html>`, html.EscapeString(unsubscribeURL))
+	fmt.Fprintf(&t, "\nOpen %s: %s\n", brand.Name, dashboardURL)
+	if brand.SupportURL != "" {
+		fmt.Fprintf(&t, "Support: %s\n", brand.SupportURL)
+	}
+	fmt.Fprintf(&t, "Unsubscribe: %s\n", unsubscribeURL)
+
+	return subject, h.String(), t.String()
+}
+
+// dashboardURL is where the web UI is reached from outside, for links in email
+func (s *Server) dashboardURL() string {
+	if s.config.Domain.Root != "" {
+		return "https://bp." + s.config.Domain.Root
+	}
+	return fmt.Sprintf("http://localhost:%d", s.config.Server.APIPort)
+}
+
+// loadDigestState reads what the last digest saw
+func (s *Server) loadDigestState() digestState {
+	var state digestState
+	if raw, _ := s.storage.GetSetting(digestStateKey); raw != "" {
+		json.Unmarshal([]byte(raw), &state)
+	}
+	return state
+}
+
+// sendDigest emails the digest to every subscriber, each seeing only the
+// apps they have access to, and returns how many were sent
+func (s *Server) sendDigest(ctx context.Context) (int, error) {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+
+	subs, err := s.storage.ListDigestSubscriptions()
+	if err != nil {
+		return 0, err
+	}
+	if len(subs) == 0 {
+		return 0, nil
+	}
+	if s.config.Email.Provider == "" {
+		return 0, fmt.Errorf("no email provider configured")
+	}
+
+	report, next := s.buildDigest(ctx, s.loadDigestState(), time.Now())
+	brand := s.branding()
+	base := s.dashboardURL()
+
+	sent := 0
+	var failures []string
+	for _, sub := range subs {
+		var sc appScope
+		if sub.UserID != "" {
+			user, err := s.storage.GetUserByID(sub.UserID)
+			if err != nil || user == nil {
+				continue // Deleted users get nothing
+			}
+			if sc, err = s.userScope(user.ID, user.Role); err != nil {
+				failures = append(failures, sub.Email)
+				continue
+			}
+		}
+		subject, htmlBody, textBody := renderDigest(report.forScope(sc), brand, base, base+"/api/digest/unsubscribe?token="+sub.Token)
+		if err := s.sendEmail(sub.Email, subject, htmlBody, textBody); err != nil {
+			log.Printf("Digest: failed to send to %s: %v", sub.Email, err)
+			failures = append(failures, sub.Email)
+			continue
+		}
+		sent++
+	}
+
+	stateJSON, _ := json.Marshal(next)
+	s.storage.SetSetting(digestStateKey, string(stateJSON))
+
+	status := "success"
+	details := fmt.Sprintf("sent to %d of %d subscribers", sent, len(subs))
+	if len(failures) > 0 {
+		status = "failed"
+		details += "; failed: " + strings.Join(failures, ", ")
+	}
+	s.logActivity("system", "digest", "system", "", "digest", status, details)
+	if len(failures) > 0 {
+		return sent, fmt.Errorf("failed to send to %s", strings.Join(failures, ", "))
+	}
+	return sent, nil
+}
+
+// digestWindow is when the weekly digest goes out
+func digestWindow(cfg config.DigestConfig) (maintenanceWindow, error) {
+	day, start := cfg.Day, cfg.Start
+	if day == "" {
+		day = "monday"
+	}
+	if start == "" {
+		start = "08:00"
+	}
+	return parseMaintenanceWindow(config.MaintenanceConfig{Day: day, Start: start})
+}
+
+// runDigestScheduler sends the digest once a week while it is enabled
+func (s *Server) runDigestScheduler() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.config == nil || !s.config.Digest.Enabled {
+				continue
+			}
+			window, err := digestWindow(s.config.Digest)
+			if err != nil {
+				continue
+			}
+			start, open := window.open(time.Now())
+			if !open {
+				continue
+			}
+			key := start.Format(time.RFC3339)
+			if last, _ := s.storage.GetSetting(digestLastWindowKey); last == key {
+				continue
+			}
+			s.storage.SetSetting(digestLastWindowKey, key)
+			if n, err := s.sendDigest(context.Background()); err != nil {
+				log.Printf("Digest: %v", err)
+			} else {
+				log.Printf("Digest: sent to %d subscribers", n)
+			}
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+// handleGetDigest returns the digest schedule and whether the caller is subscribed
+func (s *Server) handleGetDigest(w http.ResponseWriter, r *http.Request) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	resp := map[string]interface{}{
+		"enabled":    s.config.Digest.Enabled,
+		"email":      session.UserEmail,
+		"subscribed": false,
+	}
+	if window, err := digestWindow(s.config.Digest); err != nil {
+		resp["error"] = err.Error()
+	} else {
+		resp["day"] = window.day.String()
+		resp["start"] = fmt.Sprintf("%02d:%02d", int(window.start.Hours()), int(window.start.Minutes())%60)
+	}
+	if session.UserEmail != "" {
+		subs, err := s.storage.ListDigestSubscriptions()
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, sub := range subs {
+			if strings.EqualFold(sub.Email, session.UserEmail) {
+				resp["subscribed"] = true
+			}
+		}
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// handleUpdateDigest subscribes or unsubscribes the caller
+func (s *Server) handleUpdateDigest(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Subscribed bool `json:"subscribed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if session.UserEmail == "" {
+		errorResponse(w, http.StatusBadRequest, "Your account has no email address")
+		return
+	}
+
+	if req.Subscribed {
+		if _, err := s.storage.SubscribeDigest(session.UserEmail, session.UserID); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.logActivity("user", "digest_subscribe", "user", session.UserID, session.UserEmail, "success", "")
+	} else {
+		if err := s.storage.UnsubscribeDigest(session.UserEmail); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.logActivity("user", "digest_unsubscribe", "user", session.UserID, session.UserEmail, "success", "")
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"subscribed": req.Subscribed, "email": session.UserEmail})
+}
+
+// handlePreviewDigest returns the digest as it would be sent now, without
+// sending it. ?format=html returns the email itself.
+func (s *Server) handlePreviewDigest(w http.ResponseWriter, r *http.Request) {
+	report, _ := s.buildDigest(r.Context(), s.loadDigestState(), time.Now())
+	if r.URL.Query().Get("format") == "html" {
+		base := s.dashboardURL()
+		_, htmlBody, _ := renderDigest(report, s.branding(), base, base+"/api/digest/unsubscribe")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(htmlBody))
+		return
+	}
+	jsonResponse(w, http.StatusOK, report)
+}
+
+// handleSendDigest sends the digest to all subscribers now
+func (s *Server) handleSendDigest(w http.ResponseWriter, r *http.Request) {
+	sent, err := s.sendDigest(r.Context())
+	if err != nil {
+		errorResponse(w, http.StatusBadGateway, fmt.Sprintf("Sent %d: %v", sent, err))
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]int{"sent": sent})
+}
+
+// handleDigestUnsubscribe is the unsubscribe link in digest emails. No auth:
+// the token identifies the subscription.
+func (s *Server) handleDigestUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	email, err := s.storage.UnsubscribeDigestByToken(r.URL.Query().Get("token"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	message := "This unsubscribe link is no longer valid."
+	if email != "" {
+		message = html.EscapeString(email) + " won't receive the weekly digest anymore."
+		s.logActivity("user", "digest_unsubscribe", "user", "", email, "success", "unsubscribe link")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><body style=\"font-family:sans-serif;\"><h2>%s</h2><p>%s</p></body></html>", html.EscapeString(s.branding().Name), message)
+}
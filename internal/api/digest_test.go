@@ -0,0 +1,123 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUptimePercent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		prev, cur healthCounts
+		want      float64 // -1 for no value
+	}{
+		{"no checks", healthCounts{}, healthCounts{}, -1},
+		{"first digest", healthCounts{}, healthCounts{Checks: 100, Failures: 1}, 99},
+		{"delta", healthCounts{Checks: 100, Failures: 10}, healthCounts{Checks: 300, Failures: 10}, 100},
+		{"no new checks", healthCounts{Checks: 100}, healthCounts{Checks: 100}, -1},
+		{"counters reset", healthCounts{Checks: 500, Failures: 50}, healthCounts{Checks: 10, Failures: 5}, 50},
+	}
+	for _, c := range cases {
+		got := uptimePercent(c.prev, c.cur)
+		if c.want < 0 {
+			if got != nil {
+				t.Fatalf("%s: uptimePercent = %v, want none", c.name, *got)
+			}
+			continue
+		}
+		if got == nil || *got != c.want {
+			t.Fatalf("%s: uptimePercent = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDigestForScope(t *testing.T) {
+	t.Parallel()
+
+	d := DigestReport{
+		Deploys:       5,
+		FailedDeploys: 2,
+		Apps: []DigestApp{
+			{ID: "a", Name: "web", Deploys: 3, FailedDeploys: 1},
+			{ID: "b", Name: "api", Deploys: 2, FailedDeploys: 1},
+		},
+		Incidents: []DigestIncident{
+			{AppID: "a", Target: "web", Action: "deploy"},
+			{AppID: "b", Target: "api", Action: "health_restart"},
+			{Target: "basepod", Action: "daemon_limit"},
+		},
+		Disk:    &DigestDisk{Used: 1},
+		Updates: []string{"Basepod 2.0 is available"},
+	}
+
+	if got := d.forScope(nil); got.Deploys != 5 || len(got.Incidents) != 3 || got.Disk == nil {
+		t.Fatalf("forScope(nil) = %+v, want the full report", got)
+	}
+
+	got := d.forScope(appScope{"a": true})
+	if len(got.Apps) != 1 || got.Apps[0].ID != "a" {
+		t.Fatalf("apps = %+v, want only a", got.Apps)
+	}
+	if got.Deploys != 3 || got.FailedDeploys != 1 {
+		t.Fatalf("deploys = %d/%d, want 3/1", got.Deploys, got.FailedDeploys)
+	}
+	if len(got.Incidents) != 1 || got.Incidents[0].AppID != "a" {
+		t.Fatalf("incidents = %+v, want only a's", got.Incidents)
+	}
+	if got.Disk != nil || got.Updates != nil {
+		t.Fatalf("scoped digest has server-wide disk %v or updates %v", got.Disk, got.Updates)
+	}
+	if len(d.Apps) != 2 || len(d.Incidents) != 3 {
+		t.Fatalf("forScope modified the original report")
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	t.Parallel()
+
+	d := DigestReport{
+		From:      time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC),
+		To:        time.Date(2026, 1, 12, 8, 0, 0, 0, time.UTC),
+		Deploys:   4,
+		Apps:      []DigestApp{{ID: "a", Name: "<script>", Formatted: "1.0 GB"}},
+		Incidents: []DigestIncident{{Target: "web", Action: "deploy", Details: "build failed"}},
+	}
+	brand := Branding{Name: "Acme", AccentColor: "#ff6600"}
+	subject, htmlBody, textBody := renderDigest(d, brand, "https://bp.example.com", "https://bp.example.com/api/digest/unsubscribe?token=abc")
+
+	if !strings.Contains(subject, "Acme") || !strings.Contains(subject, "4 deploys") {
+		t.Fatalf("subject = %q", subject)
+	}
+	for _, body := range []string{htmlBody, textBody} {
+		if !strings.Contains(body, "/api/digest/unsubscribe?token=abc") {
+			t.Fatalf("body has no unsubscribe link:\n%s", body)
+		}
+		if !strings.Contains(body, "build failed") {
+			t.Fatalf("body has no incident:\n%s", body)
+		}
+	}
+	if strings.Contains(htmlBody, "<script>") {
+		t.Fatalf("app name not escaped in HTML:\n%s", htmlBody)
+	}
+}
+
+func TestBuildMIMEMessage(t *testing.T) {
+	t.Parallel()
+
+	msg, err := buildMIMEMessage("ops@example.com", "dev@example.com", "Weekly digest", "<p>hi</p>", "hi")
+	if err != nil {
+		t.Fatalf("buildMIMEMessage: %v", err)
+	}
+	for _, want := range []string{"To: dev@example.com\r\n", "multipart/alternative", "text/plain", "text/html"} {
+		if !strings.Contains(string(msg), want) {
+			t.Fatalf("message missing %q:\n%s", want, msg)
+		}
+	}
+
+	if _, err := buildMIMEMessage("ops@example.com", "dev@example.com\r\nBcc: x@example.com", "s", "", ""); err == nil {
+		t.Fatalf("buildMIMEMessage accepted a header with a line break")
+	}
+}
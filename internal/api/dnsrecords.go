@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/dnsprovider"
+)
+
+// dnsSyncState tracks which app domains have been synced with the DNS provider
+type dnsSyncState struct {
+	mu    sync.Mutex
+	known map[string]string // App ID to its domains when last queued
+	ipv4  string            // Detected address, once found
+
+	run sync.Mutex // Held while syncing, so syncs don't interleave
+}
+
+// localDomainSuffixes never get public DNS records
+var localDomainSuffixes = []string{".local", ".localhost", ".test", ".internal", ".pod"}
+
+// managesDNS reports whether basepod should create records for a domain.
+// Local names and subdomains of the root covered by a wildcard record are
+// left alone.
+func (s *Server) managesDNS(domain string) bool {
+	if domain == "" || domain == "localhost" || !strings.Contains(domain, ".") {
+		return false
+	}
+	for _, suffix := range localDomainSuffixes {
+		if strings.HasSuffix(domain, suffix) {
+			return false
+		}
+	}
+	if suffix := s.config.Domain.Suffix; suffix != "" && strings.HasSuffix(domain, "."+strings.TrimPrefix(suffix, ".")) {
+		return false
+	}
+	if root := s.config.Domain.Root; s.config.Domain.Wildcard && root != "" {
+		if label, ok := strings.CutSuffix(domain, "."+root); ok && !strings.Contains(label, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// dnsAddresses returns the addresses records point at
+func (s *Server) dnsAddresses() (ipv4, ipv6 string, err error) {
+	cfg := s.config.DNSProvider
+	if cfg.IPv4 != "" {
+		return cfg.IPv4, cfg.IPv6, nil
+	}
+	s.dnsSync.mu.Lock()
+	defer s.dnsSync.mu.Unlock()
+	if s.dnsSync.ipv4 == "" {
+		if s.dnsSync.ipv4, err = dnsprovider.DetectIPv4(); err != nil {
+			return "", "", err
+		}
+	}
+	return s.dnsSync.ipv4, cfg.IPv6, nil
+}
+
+// queueDNSSync syncs an app's records in the background when its domains
+// have changed since the last sync. It's called after every app update.
+func (s *Server) queueDNSSync(a app.App) {
+	if s.dnsProvider == nil {
+		return
+	}
+	key := strings.Join(appDomains(&a), ",")
+	s.dnsSync.mu.Lock()
+	if s.dnsSync.known == nil {
+		s.dnsSync.known = map[string]string{}
+	}
+	if last, ok := s.dnsSync.known[a.ID]; ok && last == key {
+		s.dnsSync.mu.Unlock()
+		return
+	}
+	s.dnsSync.known[a.ID] = key
+	s.dnsSync.mu.Unlock()
+	go s.syncAppDNS(a.ID)
+}
+
+// forgetAppDNS removes the records of a deleted app in the background
+func (s *Server) forgetAppDNS(appID string) {
+	if s.dnsProvider == nil {
+		return
+	}
+	s.dnsSync.mu.Lock()
+	delete(s.dnsSync.known, appID)
+	s.dnsSync.mu.Unlock()
+	go s.syncAppDNS(appID)
+}
+
+// syncAppDNS creates the records an app's domains need and removes the ones
+// basepod created for domains it no longer has. Records for a deleted app
+// are all removed.
+func (s *Server) syncAppDNS(appID string) {
+	s.dnsSync.run.Lock()
+	defer s.dnsSync.run.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	a, err := s.storage.GetApp(appID)
+	if err != nil {
+		log.Printf("DNS: failed to load app %s: %v", appID, err)
+		return
+	}
+	existing, err := s.storage.ListDNSRecords(appID)
+	if err != nil {
+		log.Printf("DNS: %v", err)
+		return
+	}
+
+	var want []app.DNSRecord
+	if a != nil {
+		for _, domain := range appDomains(a) {
+			if !s.managesDNS(domain) {
+				continue
+			}
+			ipv4, ipv6, err := s.dnsAddresses()
+			if err != nil {
+				log.Printf("DNS: not creating records for %s: %v", domain, err)
+				return
+			}
+			want = append(want, app.DNSRecord{Domain: domain, Type: "A", Value: ipv4})
+			if ipv6 != "" {
+				want = append(want, app.DNSRecord{Domain: domain, Type: "AAAA", Value: ipv6})
+			}
+		}
+	}
+	want = dnsRecordsFor(want, appID, s.dnsProvider.Name(), dnsprovider.TTL(s.config.DNSProvider))
+
+	for _, r := range staleDNSRecords(existing, want) {
+		err := s.dnsProvider.Delete(ctx, dnsprovider.Record{Name: r.Domain, Type: r.Type, Value: r.Value, TTL: r.TTL})
+		if err != nil && !errors.Is(err, dnsprovider.ErrNoZone) {
+			log.Printf("DNS: failed to delete %s record for %s: %v", r.Type, r.Domain, err)
+			continue
+		}
+		_ = s.storage.DeleteDNSRecord(r.Domain, r.Type)
+		s.logActivity("system", "dns_record_delete", "app", appID, appNameOf(a), "success", fmt.Sprintf("%s %s", r.Type, r.Domain))
+	}
+	for _, r := range missingDNSRecords(existing, want) {
+		err := s.dnsProvider.Upsert(ctx, dnsprovider.Record{Name: r.Domain, Type: r.Type, Value: r.Value, TTL: r.TTL})
+		if errors.Is(err, dnsprovider.ErrNoZone) {
+			log.Printf("DNS: %s is not in a zone %s manages; create its record yourself", r.Domain, s.dnsProvider.Name())
+			continue
+		}
+		if err != nil {
+			log.Printf("DNS: failed to create %s record for %s: %v", r.Type, r.Domain, err)
+			s.logActivity("system", "dns_record_create", "app", appID, appNameOf(a), "failed", fmt.Sprintf("%s %s: %v", r.Type, r.Domain, err))
+			continue
+		}
+		r.UpdatedAt = time.Now()
+		if err := s.storage.SaveDNSRecord(&r); err != nil {
+			log.Printf("DNS: %v", err)
+		}
+		s.logActivity("system", "dns_record_create", "app", appID, appNameOf(a), "success", fmt.Sprintf("%s %s -> %s", r.Type, r.Domain, r.Value))
+	}
+}
+
+// dnsRecordsFor fills in the app, provider and TTL of wanted records
+func dnsRecordsFor(records []app.DNSRecord, appID, provider string, ttl int) []app.DNSRecord {
+	for i := range records {
+		records[i].AppID = appID
+		records[i].Provider = provider
+		records[i].TTL = ttl
+	}
+	return records
+}
+
+// staleDNSRecords are the existing records that aren't wanted any more
+func staleDNSRecords(existing, want []app.DNSRecord) []app.DNSRecord {
+	var stale []app.DNSRecord
+	for _, r := range existing {
+		if !containsDNSRecord(want, r, false) {
+			stale = append(stale, r)
+		}
+	}
+	return stale
+}
+
+// missingDNSRecords are the wanted records that don't exist yet or have
+// changed
+func missingDNSRecords(existing, want []app.DNSRecord) []app.DNSRecord {
+	var missing []app.DNSRecord
+	for _, r := range want {
+		if !containsDNSRecord(existing, r, true) {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// containsDNSRecord reports whether records has r's domain and type, and
+// with sameValue also its value, TTL and provider
+func containsDNSRecord(records []app.DNSRecord, r app.DNSRecord, sameValue bool) bool {
+	for _, other := range records {
+		if other.Domain != r.Domain || other.Type != r.Type {
+			continue
+		}
+		if !sameValue || (other.Value == r.Value && other.TTL == r.TTL && other.Provider == r.Provider) {
+			return true
+		}
+	}
+	return false
+}
+
+func appNameOf(a *app.App) string {
+	if a == nil {
+		return ""
+	}
+	return a.Name
+}
+
+// syncAllDNS syncs the records of every app
+func (s *Server) syncAllDNS() {
+	if s.dnsProvider == nil || s.storage == nil {
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		log.Printf("DNS: %v", err)
+		return
+	}
+	for _, a := range apps {
+		s.queueDNSSync(a)
+	}
+}
+
+// handleListDNSRecords lists the records basepod created with the DNS provider
+func (s *Server) handleListDNSRecords(w http.ResponseWriter, r *http.Request) {
+	records, err := s.storage.ListDNSRecords("")
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	provider := ""
+	if s.dnsProvider != nil {
+		provider = s.dnsProvider.Name()
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"provider": provider,
+		"records":  records,
+	})
+}
+
+// handleSyncDNS re-syncs every app's records, e.g. after changing the
+// provider config or the server's address
+func (s *Server) handleSyncDNS(w http.ResponseWriter, r *http.Request) {
+	if s.dnsProvider == nil {
+		errorResponse(w, http.StatusBadRequest, "No DNS provider is configured (dns_provider in the server config)")
+		return
+	}
+	s.dnsSync.mu.Lock()
+	s.dnsSync.known = nil
+	s.dnsSync.ipv4 = ""
+	s.dnsSync.mu.Unlock()
+	go s.syncAllDNS()
+	jsonResponse(w, http.StatusAccepted, map[string]string{"status": "syncing"})
+}
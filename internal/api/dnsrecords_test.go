@@ -0,0 +1,64 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestManagesDNS(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{config: &config.Config{Domain: config.DomainConfig{Root: "example.com", Suffix: ".base.code", Wildcard: true}}}
+	for domain, want := range map[string]bool{
+		"":                     false,
+		"localhost":            false,
+		"blog.base.code":       false,
+		"shop.local":           false,
+		"api.example.com":      false, // Covered by the wildcard record
+		"v2.api.example.com":   true,
+		"example.com":          true,
+		"www.customer.org":     true,
+		"customer.org":         true,
+		"preview.test":         false,
+		"docs.internal":        false,
+		"site.example.com.org": true,
+	} {
+		if got := s.managesDNS(domain); got != want {
+			t.Fatalf("managesDNS(%q) = %v, want %v", domain, got, want)
+		}
+	}
+
+	s.config.Domain.Wildcard = false
+	if !s.managesDNS("api.example.com") {
+		t.Fatalf("managesDNS(api.example.com) = false without a wildcard record")
+	}
+}
+
+func TestDNSRecordDiff(t *testing.T) {
+	t.Parallel()
+
+	existing := []app.DNSRecord{
+		{Domain: "a.org", Type: "A", Value: "1.2.3.4", TTL: 300, Provider: "hetzner"},
+		{Domain: "b.org", Type: "A", Value: "1.2.3.4", TTL: 300, Provider: "hetzner"},
+		{Domain: "c.org", Type: "A", Value: "1.2.3.4", TTL: 300, Provider: "hetzner"},
+	}
+	want := dnsRecordsFor([]app.DNSRecord{
+		{Domain: "a.org", Type: "A", Value: "1.2.3.4"},
+		{Domain: "b.org", Type: "A", Value: "5.6.7.8"},
+		{Domain: "d.org", Type: "A", Value: "1.2.3.4"},
+	}, "app1", "hetzner", 300)
+
+	stale := staleDNSRecords(existing, want)
+	if len(stale) != 1 || stale[0].Domain != "c.org" {
+		t.Fatalf("stale = %+v, want c.org", stale)
+	}
+	missing := missingDNSRecords(existing, want)
+	if len(missing) != 2 || missing[0].Domain != "b.org" || missing[1].Domain != "d.org" {
+		t.Fatalf("missing = %+v, want b.org and d.org", missing)
+	}
+	if missing[0].AppID != "app1" || missing[0].TTL != 300 {
+		t.Fatalf("missing[0] = %+v, want app and TTL filled in", missing[0])
+	}
+}
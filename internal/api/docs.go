@@ -0,0 +1,163 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"gopkg.in/yaml.v3"
+)
+
+// maxReadmeBytes caps the README kept with an app; longer ones are cut
+const maxReadmeBytes = 256 << 10
+
+// appManifestFile documents an app at the top of its source
+const appManifestFile = "app.manifest.yaml"
+
+// readmeNames are the README files looked for at the top of a source, in order
+var readmeNames = []string{"README.md", "readme.md", "Readme.md", "README.markdown", "README"}
+
+// appManifest is app.manifest.yaml:
+//
+//	description: Storefront and checkout
+//	health_endpoint: /healthz
+//	links:
+//	  - title: Runbook
+//	    url: https://wiki.example.com/shop
+//	env:
+//	  - name: STRIPE_KEY
+//	    description: Secret API key
+//	    required: true
+type appManifest struct {
+	Description    string        `yaml:"description"`
+	HealthEndpoint string        `yaml:"health_endpoint"`
+	Links          []app.AppLink `yaml:"links"`
+	Env            []app.EnvDoc  `yaml:"env"`
+}
+
+// readAppDocs reads the README and app.manifest.yaml at the top of a deployed
+// source. It returns nil if the source has neither.
+func readAppDocs(sourceDir string) (*app.AppDocs, error) {
+	docs := &app.AppDocs{UpdatedAt: time.Now().UTC()}
+	found := false
+
+	for _, name := range readmeNames {
+		data, err := os.ReadFile(filepath.Join(sourceDir, name))
+		if err != nil {
+			continue
+		}
+		if len(data) > maxReadmeBytes {
+			data = append(data[:maxReadmeBytes], "\n\n…"...)
+		}
+		docs.Readme = string(data)
+		found = true
+		break
+	}
+
+	data, err := os.ReadFile(filepath.Join(sourceDir, appManifestFile))
+	if err == nil {
+		var m appManifest
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("%s: %w", appManifestFile, err)
+		}
+		for _, link := range m.Links {
+			u, err := url.Parse(link.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return nil, fmt.Errorf("%s: link %q must be an http(s) URL", appManifestFile, link.URL)
+			}
+		}
+		for _, e := range m.Env {
+			if e.Name == "" {
+				return nil, fmt.Errorf("%s: env entries need a name", appManifestFile)
+			}
+		}
+		if m.HealthEndpoint != "" && !strings.HasPrefix(m.HealthEndpoint, "/") {
+			return nil, fmt.Errorf("%s: health_endpoint must be a path starting with /", appManifestFile)
+		}
+		docs.Description = m.Description
+		docs.HealthEndpoint = m.HealthEndpoint
+		docs.Links = m.Links
+		docs.Env = m.Env
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return docs, nil
+}
+
+// missingRequiredEnv lists the env vars the docs mark as required, without a
+// default, that env doesn't set
+func missingRequiredEnv(docs *app.AppDocs, env map[string]string) []string {
+	var missing []string
+	for _, e := range docs.Env {
+		if e.Required && e.Default == "" && env[e.Name] == "" {
+			missing = append(missing, e.Name)
+		}
+	}
+	return missing
+}
+
+// updateAppDocs stores the docs in a deployed source, or drops the app's
+// docs if the source no longer has any. It returns warnings for the deploy
+// output; docs never fail a deploy.
+func (s *Server) updateAppDocs(a *app.App, sourceDir string, env map[string]string) []string {
+	docs, err := readAppDocs(sourceDir)
+	if err != nil {
+		return []string{"app docs not updated: " + err.Error()}
+	}
+	if docs == nil {
+		_ = s.storage.DeleteAppDocs(a.ID)
+		return nil
+	}
+	if err := s.storage.SaveAppDocs(a.ID, docs); err != nil {
+		return []string{"app docs not saved: " + err.Error()}
+	}
+
+	var warnings []string
+	if missing := missingRequiredEnv(docs, env); len(missing) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%s lists required env vars that aren't set: %s", appManifestFile, strings.Join(missing, ", ")))
+	}
+	return warnings
+}
+
+// handleGetAppDocs returns the README and manifest from an app's latest
+// deploy, with which documented env vars are set
+func (s *Server) handleGetAppDocs(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	docs, err := s.storage.GetAppDocs(a.ID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if docs == nil {
+		errorResponse(w, http.StatusNotFound, "App has no README.md or "+appManifestFile)
+		return
+	}
+
+	// Only whether each var is set; values can be secrets
+	env := s.containerEnv(a)
+	set := map[string]bool{}
+	for _, e := range docs.Env {
+		set[e.Name] = env[e.Name] != ""
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"docs":        docs,
+		"env_set":     set,
+		"missing_env": missingRequiredEnv(docs, env),
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadAppDocs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if docs, err := readAppDocs(dir); docs != nil || err != nil {
+		t.Fatalf("empty source = %+v, %v; want nil", docs, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Shop\n\nStorefront.\n"), 0644)
+	os.WriteFile(filepath.Join(dir, appManifestFile), []byte(`description: Storefront and checkout
+health_endpoint: /healthz
+links:
+  - title: Runbook
+    url: https://wiki.example.com/shop
+env:
+  - name: STRIPE_KEY
+    description: Secret API key
+    required: true
+  - name: LOG_LEVEL
+    required: true
+    default: info
+  - name: SENTRY_DSN
+`), 0644)
+
+	docs, err := readAppDocs(dir)
+	if err != nil {
+		t.Fatalf("readAppDocs error = %v", err)
+	}
+	if !strings.HasPrefix(docs.Readme, "# Shop") || docs.Description != "Storefront and checkout" || docs.HealthEndpoint != "/healthz" {
+		t.Fatalf("docs = %+v", docs)
+	}
+	if len(docs.Links) != 1 || docs.Links[0].Title != "Runbook" || len(docs.Env) != 3 {
+		t.Fatalf("links = %+v, env = %+v", docs.Links, docs.Env)
+	}
+
+	// A default covers a required var; only STRIPE_KEY is missing
+	missing := missingRequiredEnv(docs, map[string]string{"SENTRY_DSN": "x"})
+	if strings.Join(missing, ",") != "STRIPE_KEY" {
+		t.Fatalf("missing = %v, want [STRIPE_KEY]", missing)
+	}
+	if missing := missingRequiredEnv(docs, map[string]string{"STRIPE_KEY": "sk_live"}); len(missing) != 0 {
+		t.Fatalf("missing = %v, want none", missing)
+	}
+}
+
+func TestReadAppDocsRejectsBadManifest(t *testing.T) {
+	t.Parallel()
+
+	for _, manifest := range []string{
+		"links:\n  - title: x\n    url: javascript:alert(1)\n",
+		"health_endpoint: healthz\n",
+		"env:\n  - description: no name\n",
+		"description: [unclosed\n",
+	} {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, appManifestFile), []byte(manifest), 0644)
+		if _, err := readAppDocs(dir); err == nil {
+			t.Fatalf("manifest %q accepted", manifest)
+		}
+	}
+}
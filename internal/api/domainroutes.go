@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+	"github.com/google/uuid"
+)
+
+// sortDomainRoutes orders rules the way Caddy tries them: higher priority
+// first, then longer prefixes, then header rules before plain ones, then oldest
+func sortDomainRoutes(routes []app.DomainRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if len(a.PathPrefix) != len(b.PathPrefix) {
+			return len(a.PathPrefix) > len(b.PathPrefix)
+		}
+		if (a.HeaderName != "") != (b.HeaderName != "") {
+			return a.HeaderName != ""
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// normalizeRoutePath cleans a route's path prefix: "/api/" becomes "/api" and
+// "" becomes "/"
+func normalizeRoutePath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "/", nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("path must start with /")
+	}
+	if strings.ContainsAny(path, "*?# ") {
+		return "", fmt.Errorf("path must be a plain prefix like /api")
+	}
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed, nil
+	}
+	return "/", nil
+}
+
+// applyDomainRoutes renders a domain's route rules into Caddy
+func (s *Server) applyDomainRoutes(domain string) error {
+	if s.caddy == nil {
+		return nil
+	}
+	routes, err := s.storage.ListDomainRoutes(domain)
+	if err != nil {
+		return err
+	}
+	sortDomainRoutes(routes)
+
+	rules := make([]caddy.PathRule, 0, len(routes))
+	for _, r := range routes {
+		a, err := s.storage.GetApp(r.AppID)
+		if err != nil || a == nil {
+			continue
+		}
+		port := a.Ports.HostPort
+		if port == 0 {
+			port = assignHostPort(a.ID)
+		}
+		rules = append(rules, caddy.PathRule{
+			PathPrefix:  r.PathPrefix,
+			HeaderName:  r.HeaderName,
+			HeaderValue: r.HeaderValue,
+			StripPrefix: r.StripPrefix,
+			Upstream:    fmt.Sprintf("localhost:%d", port),
+			Upstreams:   appUpstreams(a),
+			Weights:     upstreamWeights(a),
+			Protocol:    a.Ports.Protocol,
+			Streams:     appRouteStreams(a),
+			Headers:     a.ResponseHeaders(),
+		})
+	}
+	return s.caddy.SetPathRoutes(domain, rules)
+}
+
+// applyAllDomainRoutes renders every domain's route rules into Caddy
+func (s *Server) applyAllDomainRoutes() {
+	routes, err := s.storage.ListDomainRoutes("")
+	if err != nil {
+		log.Printf("Warning: failed to load domain routes: %v", err)
+		return
+	}
+	seen := map[string]bool{}
+	for _, r := range routes {
+		if seen[r.Domain] {
+			continue
+		}
+		seen[r.Domain] = true
+		if err := s.applyDomainRoutes(r.Domain); err != nil {
+			log.Printf("Warning: failed to apply routes for %s: %v", r.Domain, err)
+		}
+	}
+}
+
+// handleListDomainRoutes lists a domain's route rules in the order they're tried
+func (s *Server) handleListDomainRoutes(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(r.PathValue("domain"))
+	routes, err := s.storage.ListDomainRoutes(domain)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sortDomainRoutes(routes)
+	for i := range routes {
+		if a, _ := s.storage.GetApp(routes[i].AppID); a != nil {
+			routes[i].AppName = a.Name
+		}
+	}
+	if routes == nil {
+		routes = []app.DomainRoute{}
+	}
+	jsonResponse(w, http.StatusOK, routes)
+}
+
+// handleCreateDomainRoute adds a rule sending a path (and optionally a header)
+// on a domain to an app
+func (s *Server) handleCreateDomainRoute(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(r.PathValue("domain"))
+	var req struct {
+		Path        string `json:"path"`
+		App         string `json:"app"`
+		HeaderName  string `json:"header_name"`
+		HeaderValue string `json:"header_value"`
+		StripPrefix bool   `json:"strip_prefix"`
+		Priority    int    `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if domain == "" || strings.ContainsAny(domain, "/ ") {
+		errorResponse(w, http.StatusBadRequest, "Invalid domain")
+		return
+	}
+	path, err := normalizeRoutePath(req.Path)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.HeaderName = strings.TrimSpace(req.HeaderName)
+	if req.HeaderName == "" && req.HeaderValue != "" {
+		errorResponse(w, http.StatusBadRequest, "header_value requires header_name")
+		return
+	}
+
+	a, err := s.resolveApp(req.App)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Type == app.AppTypeStatic || a.RedirectURL != "" {
+		errorResponse(w, http.StatusBadRequest, "Only container apps can be routed by path")
+		return
+	}
+
+	existing, err := s.storage.ListDomainRoutes(domain)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, e := range existing {
+		if e.PathPrefix == path && strings.EqualFold(e.HeaderName, req.HeaderName) && e.HeaderValue == req.HeaderValue {
+			errorResponse(w, http.StatusConflict, fmt.Sprintf("%s%s is already routed", domain, path))
+			return
+		}
+	}
+
+	route := &app.DomainRoute{
+		ID:          uuid.New().String(),
+		Domain:      domain,
+		PathPrefix:  path,
+		HeaderName:  req.HeaderName,
+		HeaderValue: req.HeaderValue,
+		AppID:       a.ID,
+		AppName:     a.Name,
+		StripPrefix: req.StripPrefix,
+		Priority:    req.Priority,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.storage.SaveDomainRoute(route); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := s.applyDomainRoutes(domain); err != nil {
+		log.Printf("Warning: failed to apply routes for %s: %v", domain, err)
+	}
+
+	s.logActivity("user", "domain_route_add", "app", a.ID, a.Name, "success", domain+path)
+	jsonResponse(w, http.StatusCreated, route)
+}
+
+// handleDeleteDomainRoute removes one of a domain's route rules
+func (s *Server) handleDeleteDomainRoute(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(r.PathValue("domain"))
+	routeID := r.PathValue("routeId")
+
+	routes, err := s.storage.ListDomainRoutes(domain)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var route *app.DomainRoute
+	for i := range routes {
+		if routes[i].ID == routeID {
+			route = &routes[i]
+			break
+		}
+	}
+	if route == nil {
+		errorResponse(w, http.StatusNotFound, "Route not found")
+		return
+	}
+
+	if err := s.storage.DeleteDomainRoute(route.ID); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := s.applyDomainRoutes(domain); err != nil {
+		log.Printf("Warning: failed to apply routes for %s: %v", domain, err)
+	}
+
+	s.logActivity("user", "domain_route_remove", "app", route.AppID, "", "success", domain+route.PathPrefix)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// applyDomainRoutesForApp re-renders the shared domains an app is routed on,
+// e.g. after its upstream protocol changed
+func (s *Server) applyDomainRoutesForApp(a *app.App) {
+	routes, err := s.storage.ListDomainRoutesForApp(a.ID)
+	if err != nil {
+		return
+	}
+	done := map[string]bool{}
+	for _, r := range routes {
+		if done[r.Domain] {
+			continue
+		}
+		done[r.Domain] = true
+		if err := s.applyDomainRoutes(r.Domain); err != nil {
+			log.Printf("Warning: failed to apply routes for %s: %v", r.Domain, err)
+		}
+	}
+}
+
+// removeAppDomainRoutes drops the route rules pointing at an app that is being
+// deleted and re-renders the domains they were on
+func (s *Server) removeAppDomainRoutes(a *app.App) {
+	routes, err := s.storage.ListDomainRoutesForApp(a.ID)
+	if err != nil || len(routes) == 0 {
+		return
+	}
+	if err := s.storage.DeleteDomainRoutesForApp(a.ID); err != nil {
+		log.Printf("Warning: failed to delete domain routes for %s: %v", a.Name, err)
+		return
+	}
+	done := map[string]bool{}
+	for _, r := range routes {
+		if done[r.Domain] {
+			continue
+		}
+		done[r.Domain] = true
+		if err := s.applyDomainRoutes(r.Domain); err != nil {
+			log.Printf("Warning: failed to apply routes for %s: %v", r.Domain, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestSortDomainRoutes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	routes := []app.DomainRoute{
+		{ID: "root", PathPrefix: "/", CreatedAt: now},
+		{ID: "api", PathPrefix: "/api", CreatedAt: now.Add(time.Second)},
+		{ID: "api-v2", PathPrefix: "/api", HeaderName: "X-Version", HeaderValue: "2", CreatedAt: now.Add(2 * time.Second)},
+		{ID: "api-v1", PathPrefix: "/api/v1", CreatedAt: now.Add(3 * time.Second)},
+		{ID: "pinned", PathPrefix: "/", Priority: 10, CreatedAt: now.Add(4 * time.Second)},
+		{ID: "root-later", PathPrefix: "/", CreatedAt: now.Add(5 * time.Second)},
+	}
+	sortDomainRoutes(routes)
+
+	want := []string{"pinned", "api-v1", "api-v2", "api", "root", "root-later"}
+	for i, id := range want {
+		if routes[i].ID != id {
+			t.Fatalf("position %d = %s, want %s (order %v)", i, routes[i].ID, id, routes)
+		}
+	}
+}
+
+func TestNormalizeRoutePath(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"", "/", false},
+		{"/", "/", false},
+		{"//", "/", false},
+		{"/api", "/api", false},
+		{"/api/", "/api", false},
+		{"api", "", true},
+		{"/api/*", "", true},
+	}
+	for _, c := range cases {
+		got, err := normalizeRoutePath(c.in)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("normalizeRoutePath(%q) error = %v, wantErr %v", c.in, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Fatalf("normalizeRoutePath(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/google/uuid"
+)
+
+// domainRedirectRouteID is the Caddy route that sends an old domain to basepod,
+// which counts the hit and answers with the redirect
+func domainRedirectRouteID(d *app.DomainRedirect) string {
+	return "migrate-" + d.FromDomain
+}
+
+// addDomainRedirectRoute routes an old domain to the basepod API server
+func (s *Server) addDomainRedirectRoute(d *app.DomainRedirect) error {
+	if s.caddy == nil {
+		return nil
+	}
+	return s.caddy.AddRoute(caddy.Route{
+		ID:        domainRedirectRouteID(d),
+		Domain:    d.FromDomain,
+		Upstream:  fmt.Sprintf("localhost:%d", s.config.Server.APIPort),
+		EnableSSL: true,
+	})
+}
+
+// domainRedirectCache keeps the migrated domains in memory, so requests for
+// app domains don't each query the database
+type domainRedirectCache struct {
+	mu      sync.Mutex
+	targets map[string]string // Old domain to new domain, nil until loaded
+}
+
+// domainRedirectTarget returns the domain host was migrated to, or ""
+func (s *Server) domainRedirectTarget(host string) string {
+	c := &s.domainRedirects
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.targets == nil {
+		redirects, err := s.storage.ListDomainRedirects("")
+		if err != nil {
+			return ""
+		}
+		c.targets = make(map[string]string, len(redirects))
+		for _, d := range redirects {
+			c.targets[d.FromDomain] = d.ToDomain
+		}
+	}
+	return c.targets[host]
+}
+
+// invalidateDomainRedirects reloads the migrated domains on the next request
+func (s *Server) invalidateDomainRedirects() {
+	s.domainRedirects.mu.Lock()
+	s.domainRedirects.targets = nil
+	s.domainRedirects.mu.Unlock()
+}
+
+// serveDomainRedirect answers requests for a migrated domain with a 301 to the
+// new domain, keeping the path and query. Returns false if host isn't migrated.
+func (s *Server) serveDomainRedirect(w http.ResponseWriter, r *http.Request, host string) bool {
+	to := s.domainRedirectTarget(host)
+	if to == "" {
+		return false
+	}
+	s.storage.RecordDomainRedirectHit(host)
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	http.Redirect(w, r, scheme+"://"+to+r.URL.RequestURI(), http.StatusMovedPermanently)
+	return true
+}
+
+// handleMigrateDomain moves an app to a new domain and keeps the old one
+// redirecting to it
+func (s *Server) handleMigrateDomain(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	from := strings.ToLower(strings.TrimSpace(req.From))
+	to := strings.ToLower(strings.TrimSpace(req.To))
+	if from == "" {
+		from = a.Domain
+	}
+	if to == "" || from == "" || from == to {
+		errorResponse(w, http.StatusBadRequest, "Both the old and new domain are required and must differ")
+		return
+	}
+	if from != a.Domain {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("%s is not the current domain of %s (%s)", from, a.Name, a.Domain))
+		return
+	}
+	if other, _ := s.storage.GetAppByDomainOrAlias(to); other != nil && other.ID != a.ID {
+		errorResponse(w, http.StatusConflict, fmt.Sprintf("%s is already used by %s", to, other.Name))
+		return
+	}
+
+	// The new domain may have been an alias; it's the primary now
+	aliases := make([]string, 0, len(a.Aliases))
+	for _, alias := range a.Aliases {
+		if alias == to {
+			s.removeAliasRoutes(a, alias)
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+	a.Aliases = aliases
+	a.Domain = to
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	redirect := &app.DomainRedirect{
+		ID:         uuid.New().String(),
+		AppID:      a.ID,
+		FromDomain: from,
+		ToDomain:   to,
+		CreatedAt:  time.Now(),
+	}
+	// Earlier migrations should point straight at the new domain, not chain
+	s.storage.RetargetDomainRedirects(a.ID, from, to)
+	if err := s.storage.SaveDomainRedirect(redirect); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Migrating back to a previously used domain retires its redirect
+	if back, _ := s.storage.GetDomainRedirect(to); back != nil {
+		s.storage.DeleteDomainRedirect(back.ID)
+		if s.caddy != nil {
+			s.caddy.RemoveRoute(domainRedirectRouteID(back))
+		}
+	}
+	s.invalidateDomainRedirects()
+
+	// Certificates for both names are issued on demand; the TLS check allows the
+	// app's domain and any migrated domain
+	if s.caddy != nil {
+		// The old domain's own route would shadow its redirect
+		s.caddy.RemoveRoute("static-" + from)
+		if err := s.addDomainRoute(a, a.Domain); err != nil {
+			log.Printf("Warning: failed to update route for %s: %v", a.Domain, err)
+		}
+		if err := s.addDomainRedirectRoute(redirect); err != nil {
+			log.Printf("Warning: failed to add redirect route for %s: %v", from, err)
+		}
+		if a.AnonymizesIPs(false) {
+			if err := s.configureAccessLogs(); err != nil {
+				log.Printf("Warning: failed to update access log settings: %v", err)
+			}
+		}
+	}
+
+	s.logActivity("user", "domain_migrate", "app", a.ID, a.Name, "success", from+" -> "+to)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"app":      a,
+		"redirect": redirect,
+	})
+}
+
+// handleListDomainRedirects lists an app's old domains and the traffic still reaching them
+func (s *Server) handleListDomainRedirects(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	redirects, err := s.storage.ListDomainRedirects(a.ID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if redirects == nil {
+		redirects = []app.DomainRedirect{}
+	}
+	jsonResponse(w, http.StatusOK, redirects)
+}
+
+// handleDeleteDomainRedirect stops redirecting an old domain
+func (s *Server) handleDeleteDomainRedirect(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	// Accept either the redirect ID or the old domain
+	target := r.PathValue("redirectId")
+	redirects, _ := s.storage.ListDomainRedirects(a.ID)
+	for i := range redirects {
+		d := &redirects[i]
+		if d.ID != target && d.FromDomain != target {
+			continue
+		}
+		if err := s.storage.DeleteDomainRedirect(d.ID); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.invalidateDomainRedirects()
+		if s.caddy != nil {
+			s.caddy.RemoveRoute(domainRedirectRouteID(d))
+		}
+		s.logActivity("user", "domain_redirect_delete", "app", a.ID, a.Name, "success", d.FromDomain)
+		jsonResponse(w, http.StatusOK, map[string]string{"message": "Redirect removed"})
+		return
+	}
+
+	errorResponse(w, http.StatusNotFound, "Redirect not found")
+}
+
+// domainNamePattern matches a hostname of dot-separated labels, optionally
+// starting with a *. wildcard
+var domainNamePattern = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// aliasRouteID is the Caddy route serving an app's alias domain
+func aliasRouteID(a *app.App, alias string) string {
+	return fmt.Sprintf("alias-%s-%s", a.ID[:8], alias)
+}
+
+// normalizeAlias lowercases a domain and strips a scheme, path and trailing
+// dot, then checks it is a hostname
+func normalizeAlias(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "https://"), "http://")
+	if i := strings.IndexByte(domain, '/'); i >= 0 {
+		domain = domain[:i]
+	}
+	domain = strings.TrimSuffix(domain, ".")
+	if len(domain) > 253 || !domainNamePattern.MatchString(domain) {
+		return "", fmt.Errorf("%q is not a valid domain", domain)
+	}
+	return domain, nil
+}
+
+// redirectRouteID is the Caddy route redirecting a redirect app's domain, its
+// own or one of its aliases
+func redirectRouteID(a *app.App, domain string) string {
+	if domain == a.Domain {
+		return "redirect-" + a.Name
+	}
+	return fmt.Sprintf("redirect-%s-%s", a.ID[:8], domain)
+}
+
+// addDomainRoute routes one of an app's domains, its own or an alias, the way
+// the app is served: a redirect, static files or the running container
+func (s *Server) addDomainRoute(a *app.App, domain string) error {
+	if s.caddy == nil {
+		return nil
+	}
+	switch {
+	case a.RedirectURL != "":
+		return s.caddy.AddRedirectRoute(redirectRouteID(a, domain), domain, strings.TrimSuffix(a.RedirectURL, "/"))
+	case a.Type == app.AppTypeStatic:
+		paths, err := config.GetPaths()
+		if err != nil {
+			return err
+		}
+		return AddStaticAppRoute(s.caddy, a, domain, fmt.Sprintf("%s/data/apps/%s", paths.Base, a.Name))
+	case a.Status != app.StatusRunning || a.Ports.HostPort == 0:
+		// Routed when the app next starts or deploys
+		return nil
+	}
+	return s.caddy.AddRoute(AppRoute(a, domain))
+}
+
+// removeAliasRoutes removes every route an alias domain may have
+func (s *Server) removeAliasRoutes(a *app.App, alias string) {
+	if s.caddy == nil {
+		return
+	}
+	_ = s.caddy.RemoveRoute(aliasRouteID(a, alias))
+	_ = s.caddy.RemoveRoute(redirectRouteID(a, alias))
+	_ = s.caddy.RemoveRoute("static-" + alias)
+}
+
+// handleListAppDomains returns an app's primary domain and aliases
+func (s *Server) handleListAppDomains(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	aliases := a.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"domain":  a.Domain,
+		"aliases": aliases,
+	})
+}
+
+// handleAddAppDomain adds an alias domain to an app and routes it
+func (s *Server) handleAddAppDomain(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	domain, err := normalizeAlias(req.Domain)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if domain == a.Domain || slices.Contains(a.Aliases, domain) {
+		errorResponse(w, http.StatusConflict, fmt.Sprintf("%s already serves %s", a.Name, domain))
+		return
+	}
+	if other, _ := s.storage.GetAppByDomainOrAlias(domain); other != nil {
+		errorResponse(w, http.StatusConflict, fmt.Sprintf("%s is already used by %s", domain, other.Name))
+		return
+	}
+	if d, _ := s.storage.GetDomainRedirect(domain); d != nil {
+		errorResponse(w, http.StatusConflict, fmt.Sprintf("%s redirects to %s; remove the redirect first", domain, d.ToDomain))
+		return
+	}
+
+	a.Aliases = append(a.Aliases, domain)
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := s.addDomainRoute(a, domain); err != nil {
+		log.Printf("Warning: failed to add alias route for %s: %v", domain, err)
+	}
+	if s.caddy != nil && a.AnonymizesIPs(false) {
+		if err := s.configureAccessLogs(); err != nil {
+			log.Printf("Warning: failed to update access log settings: %v", err)
+		}
+	}
+
+	s.logActivity("user", "domain_add", "app", a.ID, a.Name, "success", domain)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"domain":  a.Domain,
+		"aliases": a.Aliases,
+	})
+}
+
+// handleRemoveAppDomain removes an alias domain from an app and its routes
+func (s *Server) handleRemoveAppDomain(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	domain := strings.ToLower(r.PathValue("domain"))
+	if domain == a.Domain {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("%s is the primary domain of %s; use bp domains migrate to change it", domain, a.Name))
+		return
+	}
+	i := slices.Index(a.Aliases, domain)
+	if i < 0 {
+		errorResponse(w, http.StatusNotFound, fmt.Sprintf("%s is not a domain of %s", domain, a.Name))
+		return
+	}
+
+	a.Aliases = slices.Delete(a.Aliases, i, i+1)
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.removeAliasRoutes(a, domain)
+	if s.caddy != nil && a.AnonymizesIPs(false) {
+		if err := s.configureAccessLogs(); err != nil {
+			log.Printf("Warning: failed to update access log settings: %v", err)
+		}
+	}
+
+	s.logActivity("user", "domain_remove", "app", a.ID, a.Name, "success", domain)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"domain":  a.Domain,
+		"aliases": a.Aliases,
+	})
+}
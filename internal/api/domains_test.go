@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestNormalizeAlias(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]string{
+		"Example.com":               "example.com",
+		" www.example.com. ":        "www.example.com",
+		"https://shop.example.com/": "shop.example.com",
+		"*.example.com":             "*.example.com",
+		"xn--bcher-kva.example":     "xn--bcher-kva.example",
+	}
+	for in, want := range valid {
+		got, err := normalizeAlias(in)
+		if err != nil || got != want {
+			t.Fatalf("normalizeAlias(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "localhost", "exa mple.com", "-bad.example.com", "example..com", "a.*.example.com"} {
+		if got, err := normalizeAlias(in); err == nil {
+			t.Fatalf("normalizeAlias(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+// hasRoute reports whether the fake Caddy has a route with the given ID
+func (ts *testServer) hasRoute(id string) bool {
+	routes, _ := ts.caddy.GetRoutes()
+	for _, r := range routes {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// getHost requests path on the server as host, without following redirects
+func (ts *testServer) getHost(host, path string) *http.Response {
+	ts.t.Helper()
+	req, _ := http.NewRequest("GET", ts.http.URL+path, nil)
+	req.Host = host
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		ts.t.Fatalf("GET %s%s: %v", host, path, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestMigrateDomain(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "migrate-web", Domain: "migrate-old.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	ts.waitForRoute("basepod-migrate-web")
+
+	if code := ts.do("POST", "/api/apps/migrate-web/domains/migrate", map[string]string{"to": "migrate-new.test"}, nil); code != http.StatusOK {
+		t.Fatalf("migrate: status %d", code)
+	}
+	if route, _ := json.Marshal(ts.caddyRoute("basepod-migrate-web")); !strings.Contains(string(route), `"migrate-new.test"`) {
+		t.Fatalf("app route = %s, want it on migrate-new.test", route)
+	}
+	if route, _ := json.Marshal(ts.caddyRoute("migrate-migrate-old.test")); !strings.Contains(string(route), `"migrate-old.test"`) {
+		t.Fatalf("redirect route = %s, want it on migrate-old.test", route)
+	}
+
+	// The old domain redirects, keeping the path and query, and counts hits
+	for range 2 {
+		resp := ts.getHost("migrate-old.test", "/docs/page?q=1")
+		if loc := resp.Header.Get("Location"); resp.StatusCode != http.StatusMovedPermanently || loc != "http://migrate-new.test/docs/page?q=1" {
+			t.Fatalf("old domain: status %d to %q, want a 301 to the new domain with the path", resp.StatusCode, loc)
+		}
+	}
+	var redirects []app.DomainRedirect
+	ts.do("GET", "/api/apps/migrate-web/domains/redirects", nil, &redirects)
+	if len(redirects) != 1 || redirects[0].FromDomain != "migrate-old.test" || redirects[0].Hits != 2 {
+		t.Fatalf("redirects = %+v, want migrate-old.test with 2 hits", redirects)
+	}
+
+	if code := ts.do("DELETE", "/api/apps/migrate-web/domains/redirects/migrate-old.test", nil, nil); code != http.StatusOK {
+		t.Fatalf("delete redirect: status %d", code)
+	}
+	if resp := ts.getHost("migrate-old.test", "/"); resp.StatusCode == http.StatusMovedPermanently {
+		t.Fatalf("old domain still redirects after the redirect was removed")
+	}
+	if ts.hasRoute("migrate-migrate-old.test") {
+		t.Fatalf("redirect route kept after the redirect was removed")
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
+
+func TestMigrateStaticDomain(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "migrate-site", Domain: "site-old.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	stored, _ := ts.storage.GetApp(a.ID)
+	stored.Type = app.AppTypeStatic
+	if err := ts.storage.UpdateApp(stored); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	if err := ts.addDomainRoute(stored, stored.Domain); err != nil {
+		t.Fatalf("addDomainRoute: %v", err)
+	}
+	ts.waitForRoute("static-site-old.test")
+
+	if code := ts.do("POST", "/api/apps/migrate-site/domains/migrate", map[string]string{"to": "site-new.test"}, nil); code != http.StatusOK {
+		t.Fatalf("migrate: status %d", code)
+	}
+	if ts.hasRoute("static-site-old.test") {
+		t.Fatalf("static route for the old domain kept; it would shadow the redirect")
+	}
+	if !ts.hasRoute("static-site-new.test") || !ts.hasRoute("migrate-site-old.test") {
+		t.Fatalf("want a static route for site-new.test and a redirect for site-old.test")
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
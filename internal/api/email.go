@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailFrom returns the sender address for outgoing email
+func (s *Server) emailFrom() string {
+	if s.config.Email.FromAddress != "" {
+		return s.config.Email.FromAddress
+	}
+	return "noreply@basepod.app"
+}
+
+// sendEmail delivers one message through the configured provider
+func (s *Server) sendEmail(to, subject, htmlBody, textBody string) error {
+	cfg := s.config.Email
+	from := s.emailFrom()
+
+	var reqBody []byte
+	var apiURL string
+	var headers map[string]string
+
+	switch cfg.Provider {
+	case "":
+		return fmt.Errorf("no email provider configured")
+	case "smtp":
+		return s.sendSMTP(from, to, subject, htmlBody, textBody)
+	case "postmark":
+		if cfg.PostmarkToken == "" {
+			return fmt.Errorf("postmark token not configured")
+		}
+		apiURL = "https://api.postmarkapp.com/email"
+		headers = map[string]string{
+			"X-Postmark-Server-Token": cfg.PostmarkToken,
+			"Content-Type":            "application/json",
+			"Accept":                  "application/json",
+		}
+		reqBody, _ = json.Marshal(map[string]string{
+			"From":     from,
+			"To":       to,
+			"Subject":  subject,
+			"HtmlBody": htmlBody,
+			"TextBody": textBody,
+		})
+	case "resend":
+		if cfg.ResendKey == "" {
+			return fmt.Errorf("resend API key not configured")
+		}
+		apiURL = "https://api.resend.com/emails"
+		headers = map[string]string{
+			"Authorization": "Bearer " + cfg.ResendKey,
+			"Content-Type":  "application/json",
+		}
+		reqBody, _ = json.Marshal(map[string]interface{}{
+			"from":    from,
+			"to":      []string{to},
+			"subject": subject,
+			"html":    htmlBody,
+			"text":    textBody,
+		})
+	default:
+		return fmt.Errorf("unknown email provider %q", cfg.Provider)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// sendSMTP delivers a message through the configured SMTP server.
+// net/smtp upgrades to TLS with STARTTLS whenever the server offers it.
+func (s *Server) sendSMTP(from, to, subject, htmlBody, textBody string) error {
+	cfg := s.config.Email
+	if cfg.SMTPHost == "" {
+		return fmt.Errorf("smtp_host not configured")
+	}
+	port := cfg.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	msg, err := buildMIMEMessage(from, to, subject, htmlBody, textBody)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port)), auth, from, []string{to}, msg)
+}
+
+// buildMIMEMessage renders a multipart/alternative message with text and
+// HTML parts
+func buildMIMEMessage(from, to, subject, htmlBody, textBody string) ([]byte, error) {
+	for _, v := range []string{from, to, subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("email header contains a line break")
+		}
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", textBody},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		qp.Close()
+	}
+	mw.Close()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// envKeyPattern matches names that can be passed to a container as environment variables
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvUpdateRequest is the body of PATCH /api/apps/{id}/env
+type EnvUpdateRequest struct {
+	Set     map[string]string `json:"set"`
+	Unset   []string          `json:"unset"`
+	Restart bool              `json:"restart"` // Replace the running container so it sees the new values
+}
+
+// applyEnvUpdate validates req and applies it to env, returning a summary of
+// the changed keys (never their values)
+func applyEnvUpdate(env map[string]string, req EnvUpdateRequest) (string, error) {
+	for key := range req.Set {
+		if !envKeyPattern.MatchString(key) {
+			return "", fmt.Errorf("invalid variable name %q", key)
+		}
+	}
+	for _, key := range req.Unset {
+		if _, ok := req.Set[key]; ok {
+			return "", fmt.Errorf("%s is both set and unset", key)
+		}
+	}
+
+	var set, unset []string
+	for key, value := range req.Set {
+		env[key] = value
+		set = append(set, key)
+	}
+	for _, key := range req.Unset {
+		if _, ok := env[key]; ok {
+			delete(env, key)
+			unset = append(unset, key)
+		}
+	}
+	sort.Strings(set)
+	sort.Strings(unset)
+
+	var parts []string
+	if len(set) > 0 {
+		parts = append(parts, "set "+strings.Join(set, ", "))
+	}
+	if len(unset) > 0 {
+		parts = append(parts, "unset "+strings.Join(unset, ", "))
+	}
+	return strings.Join(parts, "; "), nil
+}
+
+// handleGetAppEnv returns an app's environment variables
+func (s *Server) handleGetAppEnv(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	env := a.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+	jsonResponse(w, http.StatusOK, env)
+}
+
+// handleUpdateAppEnv sets and removes an app's environment variables and,
+// if asked, replaces its running container so the change takes effect
+func (s *Server) handleUpdateAppEnv(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	var req EnvUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if a.Env == nil {
+		a.Env = map[string]string{}
+	}
+	summary, err := applyEnvUpdate(a.Env, req)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if summary == "" {
+		summary = "no changes"
+	}
+	s.logActivity("user", "env_update", "app", a.ID, a.Name, "success", summary)
+
+	restarted := false
+	if req.Restart && a.Type != app.AppTypeStatic && a.Type != app.AppTypeMLX && a.Status == app.StatusRunning && a.Image != "" {
+		if err := s.rollAppContainer(r.Context(), a); err != nil {
+			s.logActivity("user", "restart", "app", a.ID, a.Name, "failed", err.Error())
+			errorResponse(w, http.StatusBadGateway, "Environment saved, but the restart failed: "+err.Error())
+			return
+		}
+		s.logActivity("user", "restart", "app", a.ID, a.Name, "success", "environment changed")
+		restarted = true
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"env":       a.Env,
+		"restarted": restarted,
+	})
+}
+
+// rollAppContainer replaces a running app's container with one from its
+// current settings. The new container starts on the partner port and only
+// takes over once it is ready; if it never is, the old one keeps serving.
+func (s *Server) rollAppContainer(ctx context.Context, a *app.App) error {
+	handoff := s.setAsideContainer(ctx, a, a.DrainPeriod())
+	defer s.finishDrain(a, handoff)
+	if handoff != nil {
+		a.ContainerID = "" // Set aside above; recreateAppContainer must not stop it
+	}
+
+	err := s.recreateAppContainer(ctx, a)
+	if err == nil {
+		if readyErr := s.waitForAppReadiness(ctx, a); readyErr != nil {
+			err = fmt.Errorf("app did not become ready: %w", readyErr)
+		}
+	}
+	if err != nil {
+		a.Status = app.StatusFailed
+		s.storage.UpdateApp(a)
+		return err
+	}
+
+	a.Status = app.StatusRunning
+	s.storage.UpdateApp(a)
+	if handoff != nil {
+		s.refreshAppRoutes(a)
+	}
+	return nil
+}
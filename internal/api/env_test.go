@@ -0,0 +1,37 @@
+package api
+
+import "testing"
+
+func TestApplyEnvUpdate(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"A": "1", "B": "2"}
+	summary, err := applyEnvUpdate(env, EnvUpdateRequest{
+		Set:   map[string]string{"C": "3", "A": "10"},
+		Unset: []string{"B", "MISSING"},
+	})
+	if err != nil {
+		t.Fatalf("applyEnvUpdate: %v", err)
+	}
+	if len(env) != 2 || env["A"] != "10" || env["C"] != "3" {
+		t.Fatalf("env = %v, want A=10 C=3", env)
+	}
+	if summary != "set A, C; unset B" {
+		t.Fatalf("summary = %q, want %q", summary, "set A, C; unset B")
+	}
+
+	for _, req := range []EnvUpdateRequest{
+		{Set: map[string]string{"1BAD": "x"}},
+		{Set: map[string]string{"HAS-DASH": "x"}},
+		{Set: map[string]string{"": "x"}},
+		{Set: map[string]string{"X": "1"}, Unset: []string{"X"}},
+	} {
+		before := len(env)
+		if _, err := applyEnvUpdate(env, req); err == nil {
+			t.Fatalf("applyEnvUpdate(%+v) succeeded, want error", req)
+		}
+		if len(env) != before {
+			t.Fatalf("applyEnvUpdate(%+v) changed env on error", req)
+		}
+	}
+}
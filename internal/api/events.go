@@ -0,0 +1,385 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// podmanEventSettle is how long a container event is left to settle before
+// the app is checked, so API handlers that started or stopped the container
+// have saved the new status first
+const podmanEventSettle = 2 * time.Second
+
+// eventReplayLimit is how many recent events are kept for clients resuming
+// from a cursor
+const eventReplayLimit = 500
+
+// reconnectTokenTTL is how long a stream's reconnect token stays valid
+const reconnectTokenTTL = 10 * time.Minute
+
+// AppEvent is a change pushed to the web UI and bp watch over
+// GET /api/events/stream
+type AppEvent struct {
+	ID       string            `json:"id"`   // Cursor to resume after this event
+	Type     string            `json:"type"` // app_status, container, deploy, health, notification or resync
+	AppID    string            `json:"app_id,omitempty"`
+	AppName  string            `json:"app_name,omitempty"`
+	Status   string            `json:"status,omitempty"`    // app_status: the app's status; deploy: the phase's or deploy's status
+	Action   string            `json:"action,omitempty"`    // container: Podman's action, e.g. start, died or health_status
+	Health   string            `json:"health,omitempty"`    // container: health for health_status; health: healthy or unhealthy
+	ExitCode string            `json:"exit_code,omitempty"` // container: exit code for died
+	Reason   string            `json:"reason,omitempty"`    // app_status: why the container last stopped; health: the failed check
+	Phase    string            `json:"phase,omitempty"`     // deploy: phase, or empty for the deploy's result
+	Message  string            `json:"message,omitempty"`   // deploy: why it failed
+	Event    string            `json:"event,omitempty"`     // notification: e.g. deploy_success or container_exit
+	Details  map[string]string `json:"details,omitempty"`   // notification: as sent to webhooks
+	Time     time.Time         `json:"time"`
+
+	seq uint64
+}
+
+// reconnectGrant is what a reconnect token lets a stream see
+type reconnectGrant struct {
+	scope   appScope
+	expires time.Time
+}
+
+// eventHub fans AppEvents out to stream subscribers and keeps the most recent
+// ones for replay. Slow subscribers miss events rather than holding up the
+// others.
+type eventHub struct {
+	mu     sync.Mutex
+	subs   map[chan AppEvent]struct{}
+	epoch  string // Changes with every server start, so old cursors are recognized
+	seq    uint64
+	recent []AppEvent // Oldest first
+	tokens map[string]reconnectGrant
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subs:   map[chan AppEvent]struct{}{},
+		epoch:  generateRandomString(8),
+		tokens: map[string]reconnectGrant{},
+	}
+}
+
+// subscribe returns a channel of events and a function to stop receiving them
+func (h *eventHub) subscribe() (chan AppEvent, func()) {
+	ch := make(chan AppEvent, 32)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// publish numbers e, keeps it for replay and sends it to every subscriber
+// that has room for it
+func (h *eventHub) publish(e AppEvent) {
+	if h == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e.seq = h.seq
+	e.ID = h.epoch + "-" + strconv.FormatUint(h.seq, 10)
+	h.recent = append(h.recent, e)
+	if len(h.recent) > eventReplayLimit {
+		h.recent = h.recent[len(h.recent)-eventReplayLimit:]
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// cursor returns the ID of the latest event, to resume from
+func (h *eventHub) cursor() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.epoch + "-" + strconv.FormatUint(h.seq, 10)
+}
+
+// since returns the kept events after cursor. ok is false when events after
+// it are no longer kept or it is from before the server restarted, so the
+// client has to reload its state instead.
+func (h *eventHub) since(cursor string) (events []AppEvent, ok bool) {
+	epoch, n, found := strings.Cut(cursor, "-")
+	seq, err := strconv.ParseUint(n, 10, 64)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !found || err != nil || epoch != h.epoch || seq > h.seq {
+		return nil, false
+	}
+	if len(h.recent) > 0 && h.recent[0].seq > seq+1 {
+		return nil, false
+	}
+	for _, e := range h.recent {
+		if e.seq > seq {
+			events = append(events, e)
+		}
+	}
+	return events, true
+}
+
+// issueToken returns a token that reopens a stream limited to sc without other
+// credentials until it expires, e.g. for EventSource reconnects that can't send
+// an Authorization header
+func (h *eventHub) issueToken(sc appScope) (string, time.Time) {
+	token := generateRandomString(32)
+	now := time.Now()
+	expires := now.Add(reconnectTokenTTL)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for t, g := range h.tokens {
+		if now.After(g.expires) {
+			delete(h.tokens, t)
+		}
+	}
+	h.tokens[token] = reconnectGrant{scope: sc, expires: expires}
+	return token, expires
+}
+
+// redeem returns the scope of a valid reconnect token
+func (h *eventHub) redeem(token string) (appScope, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.tokens[token]
+	if !ok || time.Now().After(g.expires) {
+		return nil, false
+	}
+	return g.scope, true
+}
+
+// publishAppUpdate is registered with storage to push every saved app's
+// status to clients
+func (s *Server) publishAppUpdate(a app.App) {
+	e := AppEvent{Type: "app_status", AppID: a.ID, AppName: a.Name, Status: string(a.Status)}
+	if a.LastExit != nil && a.Status != app.StatusRunning {
+		e.Reason = a.LastExit.Reason
+	}
+	s.events.publish(e)
+}
+
+// runPodmanEvents follows Podman's container events and updates apps as
+// their containers start, die or change health. The stream is resubscribed
+// with backoff when it breaks.
+func (s *Server) runPodmanEvents() {
+	if s.podman == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.healthStop
+		cancel()
+	}()
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		events, err := s.podman.ContainerEvents(ctx)
+		if err == nil {
+			backoff = time.Second
+			for e := range events {
+				s.handlePodmanEvent(e)
+			}
+		} else {
+			log.Printf("Podman events: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// handlePodmanEvent applies one container event to the app it belongs to
+func (s *Server) handlePodmanEvent(e podman.Event) {
+	appID := e.Actor.Attributes["basepod.app.id"]
+	name := e.Actor.Attributes["name"]
+	if appID == "" || strings.HasSuffix(name, "-draining") {
+		return // Not an app container, or a previous one on its way out
+	}
+	switch e.Action {
+	case "start", "died", "health_status":
+	default:
+		return
+	}
+
+	s.events.publish(AppEvent{
+		Type:     "container",
+		AppID:    appID,
+		AppName:  e.Actor.Attributes["basepod.app"],
+		Action:   e.Action,
+		Health:   e.Actor.Attributes["health_status"],
+		ExitCode: e.Actor.Attributes["containerExitCode"],
+	})
+
+	switch e.Action {
+	case "died":
+		time.AfterFunc(podmanEventSettle, func() { s.containerDied(appID, e.Actor.ID) })
+	case "start":
+		time.AfterFunc(podmanEventSettle, func() { s.containerStarted(appID, e.Actor.ID) })
+	}
+}
+
+// containerDied records the exit of an app's current container if the app
+// still thinks it is running
+func (s *Server) containerDied(appID, containerID string) {
+	a, err := s.storage.GetApp(appID)
+	if err != nil || a == nil || a.Status != app.StatusRunning || a.ContainerID != containerID {
+		return
+	}
+	if exit := s.inspectExit(context.Background(), a); exit != nil {
+		s.recordContainerExit(a, exit)
+	}
+}
+
+// containerStarted marks an app running again when its current container was
+// started outside basepod, e.g. with podman start
+func (s *Server) containerStarted(appID, containerID string) {
+	a, err := s.storage.GetApp(appID)
+	if err != nil || a == nil || a.ContainerID != containerID {
+		return
+	}
+	if a.Status != app.StatusStopped && a.Status != app.StatusFailed {
+		return
+	}
+	a.Status = app.StatusRunning
+	if err := s.storage.UpdateApp(a); err != nil {
+		log.Printf("Podman events: failed to mark %s running: %v", a.Name, err)
+		return
+	}
+	s.logActivity("system", "container_start", "app", a.ID, a.Name, "success", "container started outside basepod")
+}
+
+// publishDeploy pushes a deploy's phase changes and result
+func (s *Server) publishDeploy(a *app.App, e DeployEvent) {
+	if a == nil || e.Type == "log" {
+		return
+	}
+	s.events.publish(AppEvent{Type: "deploy", AppID: a.ID, AppName: a.Name, Phase: e.Phase, Status: e.Status, Message: e.Message})
+}
+
+// handleEventStream streams app status changes, deploy progress, health
+// transitions and notifications as server-sent events until the client
+// disconnects. Callers limited to some apps only see those. A client resumes
+// where it left off with the Last-Event-ID header or ?cursor=, and can
+// reconnect with the hello event's reconnect token as ?token= instead of its
+// credentials. ?app= limits the stream to one app.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		sc, ok := s.events.redeem(token)
+		if !ok {
+			errorResponse(w, http.StatusUnauthorized, "Reconnect token is invalid or expired")
+			return
+		}
+		s.streamEvents(w, r, sc)
+		return
+	}
+	s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		sc, err := s.callerScope(r)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+			return
+		}
+		s.streamEvents(w, r, sc)
+	})(w, r)
+}
+
+// streamEvents writes events visible to sc, first any missed since the
+// client's cursor
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, sc appScope) {
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("cursor")
+	}
+
+	// Subscribe before reading the backlog so nothing is lost in between;
+	// events in both are sent once
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+	var backlog []AppEvent
+	resync := false
+	if cursor != "" {
+		var ok bool
+		backlog, ok = s.events.since(cursor)
+		resync = !ok
+	}
+	token, expires := s.events.issueToken(sc)
+
+	// Stream until the client disconnects: lift the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	hello, _ := json.Marshal(map[string]interface{}{
+		"type":            "hello",
+		"cursor":          s.events.cursor(),
+		"reconnect_token": token,
+		"expires_at":      expires,
+	})
+	fmt.Fprintf(w, "event: hello\ndata: %s\n\n", hello)
+
+	only := r.URL.Query().Get("app") // Optional app ID or name to follow
+	var sent uint64
+	send := func(e AppEvent) {
+		if e.seq <= sent || !sc.allows(e.AppID) || (only != "" && e.AppID != only && e.AppName != only) {
+			return
+		}
+		sent = e.seq
+		data, _ := json.Marshal(e)
+		fmt.Fprintf(w, "id: %s\ndata: %s\n\n", e.ID, data)
+	}
+	if resync {
+		// The client missed events: it has to reload everything
+		data, _ := json.Marshal(AppEvent{Type: "resync", Time: time.Now()})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	for _, e := range backlog {
+		send(e)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e := <-events:
+			send(e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
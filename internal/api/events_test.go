@@ -0,0 +1,130 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/podman"
+)
+
+func TestEventHub(t *testing.T) {
+	t.Parallel()
+
+	h := newEventHub()
+	a, unsubscribeA := h.subscribe()
+	b, unsubscribeB := h.subscribe()
+	defer unsubscribeB()
+
+	h.publish(AppEvent{Type: "app_status", AppID: "x", Status: "running"})
+	for _, ch := range []chan AppEvent{a, b} {
+		if e := <-ch; e.AppID != "x" || e.Time.IsZero() {
+			t.Fatalf("event = %+v, want app x with a time", e)
+		}
+	}
+
+	unsubscribeA()
+	h.publish(AppEvent{AppID: "y"})
+	select {
+	case e := <-a:
+		t.Fatalf("unsubscribed channel got %+v", e)
+	default:
+	}
+
+	// A subscriber that stops reading doesn't block publishing
+	for i := 0; i < 100; i++ {
+		h.publish(AppEvent{AppID: "z"})
+	}
+
+	var nilHub *eventHub
+	nilHub.publish(AppEvent{AppID: "x"})
+}
+
+func TestHandlePodmanEventFiltersContainers(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{events: newEventHub()}
+	ch, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	event := func(attrs map[string]string) podman.Event {
+		var e podman.Event
+		e.Action = "health_status"
+		e.Actor.ID = "abc"
+		e.Actor.Attributes = attrs
+		return e
+	}
+	s.handlePodmanEvent(event(map[string]string{"name": "pullcache"}))
+	s.handlePodmanEvent(event(map[string]string{"name": "basepod-web-draining", "basepod.app.id": "1"}))
+	s.handlePodmanEvent(event(map[string]string{"name": "basepod-web", "basepod.app.id": "1", "basepod.app": "web", "health_status": "unhealthy"}))
+
+	e := <-ch
+	if e.AppID != "1" || e.AppName != "web" || e.Action != "health_status" || e.Health != "unhealthy" {
+		t.Fatalf("event = %+v, want web's health_status", e)
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("unexpected extra event %+v", e)
+	default:
+	}
+}
+
+func TestEventHubReplay(t *testing.T) {
+	t.Parallel()
+
+	h := newEventHub()
+	start := h.cursor()
+	h.publish(AppEvent{AppID: "a"})
+	h.publish(AppEvent{AppID: "b"})
+	mid := h.cursor()
+	h.publish(AppEvent{AppID: "c"})
+
+	events, ok := h.since(start)
+	if !ok || len(events) != 3 || events[0].AppID != "a" {
+		t.Fatalf("since(start) = %+v, %v; want a, b and c", events, ok)
+	}
+	events, ok = h.since(mid)
+	if !ok || len(events) != 1 || events[0].AppID != "c" || events[0].ID != h.cursor() {
+		t.Fatalf("since(mid) = %+v, %v; want c", events, ok)
+	}
+	if events, ok := h.since(h.cursor()); !ok || len(events) != 0 {
+		t.Fatalf("since(latest) = %+v, %v; want nothing", events, ok)
+	}
+
+	// Cursors from another server start or for dropped events need a resync
+	if _, ok := newEventHub().since(mid); ok {
+		t.Fatalf("cursor from another hub was accepted")
+	}
+	if _, ok := h.since("garbage"); ok {
+		t.Fatalf("malformed cursor was accepted")
+	}
+	for i := 0; i < eventReplayLimit; i++ {
+		h.publish(AppEvent{AppID: "x"})
+	}
+	if _, ok := h.since(start); ok {
+		t.Fatalf("cursor older than the replay buffer was accepted")
+	}
+}
+
+func TestEventHubReconnectToken(t *testing.T) {
+	t.Parallel()
+
+	h := newEventHub()
+	token, expires := h.issueToken(appScope{"app1": true})
+	if time.Until(expires) > reconnectTokenTTL {
+		t.Fatalf("token expires at %v, after its TTL", expires)
+	}
+	sc, ok := h.redeem(token)
+	if !ok || !sc.allows("app1") || sc.allows("app2") {
+		t.Fatalf("redeem = %v, %v; want the app1 scope", sc, ok)
+	}
+	if _, ok := h.redeem("unknown"); ok {
+		t.Fatalf("unknown token was accepted")
+	}
+
+	h.mu.Lock()
+	h.tokens[token] = reconnectGrant{expires: time.Now().Add(-time.Second)}
+	h.mu.Unlock()
+	if _, ok := h.redeem(token); ok {
+		t.Fatalf("expired token was accepted")
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// maxFileUploadBytes caps a tar archive uploaded into an app's container
+const maxFileUploadBytes = 500 << 20
+
+// containerPath validates a path in an app's container given to the files
+// endpoints and cleans it
+func containerPath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("path must be absolute")
+	}
+	return path.Clean(p), nil
+}
+
+// handleDownloadAppFiles streams a file or directory from an app's container
+// as a tar archive (bp cp app:/path ./local)
+func (s *Server) handleDownloadAppFiles(w http.ResponseWriter, r *http.Request) {
+	p, err := containerPath(r.URL.Query().Get("path"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.ContainerID == "" {
+		errorResponse(w, http.StatusBadRequest, "App has no container")
+		return
+	}
+
+	archive, err := s.podman.CopyFromContainer(r.Context(), a.ContainerID, p)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, podman.ErrNoSuchPath) {
+			status = http.StatusNotFound
+		}
+		errorResponse(w, status, err.Error())
+		return
+	}
+	defer archive.Close()
+
+	s.logActivity("user", "cp", "app", a.ID, a.Name, "success", "download "+p)
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(p)+".tar"))
+	io.Copy(w, archive)
+}
+
+// handleUploadAppFiles extracts a tar archive into a directory in an app's
+// running container (bp cp ./local app:/path)
+func (s *Server) handleUploadAppFiles(w http.ResponseWriter, r *http.Request) {
+	dir, err := containerPath(r.URL.Query().Get("path"))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.ContainerID == "" || a.Status != "running" {
+		errorResponse(w, http.StatusBadRequest, "App is not running")
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxFileUploadBytes)
+	if err := s.podman.CopyToContainer(r.Context(), a.ContainerID, dir, body); err != nil {
+		s.logActivity("user", "cp", "app", a.ID, a.Name, "failed", "upload "+dir+": "+err.Error())
+		status := http.StatusInternalServerError
+		if errors.Is(err, podman.ErrNoSuchPath) {
+			status = http.StatusNotFound
+		}
+		errorResponse(w, status, err.Error())
+		return
+	}
+
+	s.logActivity("user", "cp", "app", a.ID, a.Name, "success", "upload "+dir)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "copied", "path": dir})
+}
@@ -0,0 +1,28 @@
+package api
+
+import "testing"
+
+func TestContainerPath(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"/app/config.yml":   "/app/config.yml",
+		"/app/public/":      "/app/public",
+		"/app/../etc/hosts": "/etc/hosts",
+		"/":                 "/",
+	}
+	for in, want := range cases {
+		got, err := containerPath(in)
+		if err != nil {
+			t.Fatalf("containerPath(%q) error = %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("containerPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "app/config.yml", "./config.yml"} {
+		if _, err := containerPath(in); err == nil {
+			t.Fatalf("containerPath(%q) should fail", in)
+		}
+	}
+}
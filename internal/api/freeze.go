@@ -0,0 +1,265 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/google/uuid"
+)
+
+// freezeDays are the day names freeze windows accept
+var freezeDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock parses "16:00" into minutes after midnight; "24:00" is the
+// end of the day
+func parseClock(v string) (int, error) {
+	h, m, ok := strings.Cut(v, ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", v)
+	}
+	return hour*60 + minute, nil
+}
+
+// parseFreezeDate parses "2026-12-24", or "12-24" for every year, in which
+// case the returned date's year is 0
+func parseFreezeDate(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD, or MM-DD for every year)", v)
+	}
+	return t, nil
+}
+
+// validateFreezeWindow checks a freeze window and normalizes its day names
+func validateFreezeWindow(fw *app.FreezeWindow) error {
+	fw.Name = strings.TrimSpace(fw.Name)
+	if fw.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	weekly := len(fw.Days) > 0 || fw.Start != "" || fw.End != ""
+	dated := fw.From != "" || fw.Until != ""
+	if weekly == dated {
+		return fmt.Errorf("a freeze window has days/start/end (weekly) or from/until (dated), not both")
+	}
+
+	if weekly {
+		for i, d := range fw.Days {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if len(d) > 3 {
+				d = d[:3]
+			}
+			if _, ok := freezeDays[d]; !ok {
+				return fmt.Errorf("invalid day %q", fw.Days[i])
+			}
+			fw.Days[i] = d
+		}
+		if fw.Start != "" {
+			start, err := parseClock(fw.Start)
+			if err != nil {
+				return err
+			}
+			if start == 24*60 {
+				return fmt.Errorf("start must be before 24:00")
+			}
+		}
+		if fw.End != "" {
+			if _, err := parseClock(fw.End); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if fw.From == "" || fw.Until == "" {
+		return fmt.Errorf("a dated freeze window needs both from and until")
+	}
+	from, err := parseFreezeDate(fw.From)
+	if err != nil {
+		return err
+	}
+	until, err := parseFreezeDate(fw.Until)
+	if err != nil {
+		return err
+	}
+	if (from.Year() == 0) != (until.Year() == 0) {
+		return fmt.Errorf("from and until must both have a year or both be yearly")
+	}
+	if from.Year() != 0 && until.Before(from) {
+		return fmt.Errorf("until is before from")
+	}
+	return nil
+}
+
+// freezeActive reports whether a (valid) freeze window covers now
+func freezeActive(fw app.FreezeWindow, now time.Time) bool {
+	if fw.From != "" {
+		from, err1 := parseFreezeDate(fw.From)
+		until, err2 := parseFreezeDate(fw.Until)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		if from.Year() != 0 {
+			start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, now.Location())
+			end := time.Date(until.Year(), until.Month(), until.Day()+1, 0, 0, 0, 0, now.Location())
+			return !now.Before(start) && now.Before(end)
+		}
+		// Yearly: compare month and day, wrapping past new year (Dec 24 - Jan 2)
+		day := int(now.Month())*100 + now.Day()
+		fromDay := int(from.Month())*100 + from.Day()
+		untilDay := int(until.Month())*100 + until.Day()
+		if fromDay <= untilDay {
+			return day >= fromDay && day <= untilDay
+		}
+		return day >= fromDay || day <= untilDay
+	}
+
+	start, end := 0, 24*60
+	if fw.Start != "" {
+		start, _ = parseClock(fw.Start)
+	}
+	if fw.End != "" {
+		end, _ = parseClock(fw.End)
+	}
+	onDay := func(d time.Weekday) bool {
+		if len(fw.Days) == 0 {
+			return true
+		}
+		for _, name := range fw.Days {
+			if freezeDays[name] == d {
+				return true
+			}
+		}
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return onDay(now.Weekday()) && minute >= start && minute < end
+	}
+	// Past midnight: Friday 22:00-06:00 also covers early Saturday
+	return (onDay(now.Weekday()) && minute >= start) || (onDay(now.AddDate(0, 0, -1).Weekday()) && minute < end)
+}
+
+// describeFreezeWindow says when a freeze window applies, e.g. "fri
+// 16:00-24:00" or "12-24 to 01-02"
+func describeFreezeWindow(fw *app.FreezeWindow) string {
+	if fw.From != "" {
+		return fw.From + " to " + fw.Until
+	}
+	days := "every day"
+	if len(fw.Days) > 0 {
+		days = strings.Join(fw.Days, ",")
+	}
+	start, end := fw.Start, fw.End
+	if start == "" {
+		start = "00:00"
+	}
+	if end == "" {
+		end = "24:00"
+	}
+	return fmt.Sprintf("%s %s-%s", days, start, end)
+}
+
+// activeFreeze returns the freeze window in effect now, or nil
+func (s *Server) activeFreeze() *app.FreezeWindow {
+	windows, err := s.storage.ListFreezeWindows()
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	for i := range windows {
+		if freezeActive(windows[i], now) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+// checkFreeze lets a deploy through outside freeze windows, or during one
+// when it carries an override reason, which goes in the activity log. It
+// returns the window that blocks the deploy, or nil.
+func (s *Server) checkFreeze(appID, appName, override string) *app.FreezeWindow {
+	fw := s.activeFreeze()
+	if fw == nil {
+		return nil
+	}
+	override = strings.TrimSpace(override)
+	if override == "" {
+		return fw
+	}
+	s.logActivity("user", "freeze_override", "app", appID, appName, "success", fmt.Sprintf("%s: %s", fw.Name, override))
+	return nil
+}
+
+// freezeResponse refuses a deploy during a freeze
+func freezeResponse(w http.ResponseWriter, fw *app.FreezeWindow) {
+	jsonResponse(w, http.StatusLocked, map[string]interface{}{
+		"error":  fmt.Sprintf("Deploys are frozen: %s (%s). Deploy with an override reason to go ahead anyway.", fw.Name, describeFreezeWindow(fw)),
+		"freeze": fw,
+	})
+}
+
+// handleListFreezeWindows lists the freeze windows and the one in effect
+func (s *Server) handleListFreezeWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := s.storage.ListFreezeWindows()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"windows": windows,
+		"active":  s.activeFreeze(),
+	})
+}
+
+// handleCreateFreezeWindow adds a freeze window
+func (s *Server) handleCreateFreezeWindow(w http.ResponseWriter, r *http.Request) {
+	var fw app.FreezeWindow
+	if err := json.NewDecoder(r.Body).Decode(&fw); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateFreezeWindow(&fw); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fw.ID = uuid.New().String()
+	fw.CreatedAt = time.Now()
+	if session := s.auth.GetSession(s.getSessionToken(r)); session != nil {
+		fw.CreatedBy = session.UserEmail
+	}
+	if err := s.storage.CreateFreezeWindow(&fw); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logActivity("user", "freeze_create", "freeze_window", fw.ID, fw.Name, "success", describeFreezeWindow(&fw))
+	jsonResponse(w, http.StatusCreated, fw)
+}
+
+// handleDeleteFreezeWindow removes a freeze window
+func (s *Server) handleDeleteFreezeWindow(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	found, err := s.storage.DeleteFreezeWindow(id)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		errorResponse(w, http.StatusNotFound, "Freeze window not found")
+		return
+	}
+	s.logActivity("user", "freeze_delete", "freeze_window", id, "", "success", "")
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestFreezeActive(t *testing.T) {
+	t.Parallel()
+
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	fridayAfternoon := app.FreezeWindow{Days: []string{"fri"}, Start: "16:00"}
+	overnight := app.FreezeWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00"}
+	holidays := app.FreezeWindow{From: "12-24", Until: "01-02"}
+	launch := app.FreezeWindow{From: "2026-11-01", Until: "2026-11-03"}
+
+	tests := []struct {
+		name string
+		fw   app.FreezeWindow
+		now  string
+		want bool
+	}{
+		{"friday after start", fridayAfternoon, "2026-10-16 17:30", true},
+		{"friday before start", fridayAfternoon, "2026-10-16 15:59", false},
+		{"saturday", fridayAfternoon, "2026-10-17 17:30", false},
+		{"overnight friday", overnight, "2026-10-16 23:00", true},
+		{"overnight into saturday", overnight, "2026-10-17 05:59", true},
+		{"overnight saturday morning", overnight, "2026-10-17 06:00", false},
+		{"overnight thursday", overnight, "2026-10-15 23:00", false},
+		{"holidays december", holidays, "2026-12-31 12:00", true},
+		{"holidays january", holidays, "2027-01-02 23:59", true},
+		{"holidays after", holidays, "2027-01-03 00:00", false},
+		{"holidays before", holidays, "2026-12-23 23:59", false},
+		{"launch last day", launch, "2026-11-03 23:59", true},
+		{"launch after", launch, "2026-11-04 00:00", false},
+		{"launch other year", launch, "2027-11-02 12:00", false},
+	}
+	for _, tt := range tests {
+		if got := freezeActive(tt.fw, at(tt.now)); got != tt.want {
+			t.Fatalf("%s: freezeActive at %s = %v, want %v", tt.name, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestValidateFreezeWindow(t *testing.T) {
+	t.Parallel()
+
+	fw := app.FreezeWindow{Name: " Weekend ", Days: []string{"Friday", "SAT"}, Start: "16:00"}
+	if err := validateFreezeWindow(&fw); err != nil {
+		t.Fatalf("valid weekly window: %v", err)
+	}
+	if fw.Name != "Weekend" || fw.Days[0] != "fri" || fw.Days[1] != "sat" {
+		t.Fatalf("not normalized: %+v", fw)
+	}
+
+	invalid := []app.FreezeWindow{
+		{Days: []string{"fri"}},
+		{Name: "x"},
+		{Name: "x", Days: []string{"fri"}, From: "12-24", Until: "01-02"},
+		{Name: "x", Days: []string{"someday"}},
+		{Name: "x", Start: "24:00"},
+		{Name: "x", Start: "9am"},
+		{Name: "x", From: "12-24"},
+		{Name: "x", From: "2026-12-24", Until: "01-02"},
+		{Name: "x", From: "2026-12-24", Until: "2026-12-01"},
+	}
+	for _, fw := range invalid {
+		if err := validateFreezeWindow(&fw); err == nil {
+			t.Fatalf("validateFreezeWindow(%+v) = nil, want an error", fw)
+		}
+	}
+}
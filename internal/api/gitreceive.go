@@ -0,0 +1,295 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cgi"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/google/uuid"
+)
+
+// gitPushedFile is where the post-receive hook records pushed refs for the
+// daemon to pick up once the push has finished
+const gitPushedFile = "basepod-pushed"
+
+// gitPostReceiveHook is installed in every app repo
+const gitPostReceiveHook = `#!/bin/sh
+# Installed by basepod: records pushed refs so the daemon can deploy them
+cat >> ` + gitPushedFile + `
+echo "basepod: push received, deploying in the background"
+echo "basepod: follow it with: bp webhook deliveries <app>, bp logs <app>"
+`
+
+// gitZeroHash is the new value of a deleted ref
+const gitZeroHash = "0000000000000000000000000000000000000000"
+
+// gitPushedRef is one line of post-receive input
+type gitPushedRef struct {
+	Old, New, Ref string
+}
+
+// gitRepoName maps the {repo} path segment ("myapp.git") to an app name
+func gitRepoName(segment string) (string, bool) {
+	name, ok := strings.CutSuffix(segment, ".git")
+	if !ok || name == "" || strings.ContainsAny(name, "/\\") || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	return name, true
+}
+
+// gitService returns the git service a smart HTTP request is for, or ""
+// for anything http-backend shouldn't serve
+func gitService(r *http.Request, rest string) string {
+	switch {
+	case r.Method == http.MethodGet && rest == "info/refs":
+		switch svc := r.URL.Query().Get("service"); svc {
+		case "git-upload-pack", "git-receive-pack":
+			return svc
+		}
+	case r.Method == http.MethodPost && (rest == "git-upload-pack" || rest == "git-receive-pack"):
+		return rest
+	}
+	return ""
+}
+
+// parseGitPushed reads the refs the post-receive hook recorded
+func parseGitPushed(data string) []gitPushedRef {
+	var refs []gitPushedRef
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		refs = append(refs, gitPushedRef{Old: fields[0], New: fields[1], Ref: fields[2]})
+	}
+	return refs
+}
+
+// pushedBranch returns the update to the branch an app deploys from, or
+// nil when the push didn't touch it or deleted it
+func pushedBranch(refs []gitPushedRef, branch string) *gitPushedRef {
+	for i := range refs {
+		if refs[i].Ref == "refs/heads/"+branch && refs[i].New != gitZeroHash {
+			return &refs[i]
+		}
+	}
+	return nil
+}
+
+// ensureGitRepo creates an app's bare repo with the deploy hook on first use
+func ensureGitRepo(ctx context.Context, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); os.IsNotExist(err) {
+		if output, err := execCommand(ctx, "git", "init", "--bare", "--quiet", dir); err != nil {
+			return fmt.Errorf("git init failed: %v: %s", err, output)
+		}
+		if output, err := execCommand(ctx, "git", "--git-dir", dir, "config", "http.receivepack", "true"); err != nil {
+			return fmt.Errorf("git config failed: %v: %s", err, output)
+		}
+	}
+	// Rewritten every time so repos pick up hook changes after upgrades
+	return os.WriteFile(filepath.Join(dir, "hooks", "post-receive"), []byte(gitPostReceiveHook), 0755)
+}
+
+// gitAuth checks the password of a git client's basic auth: a session token
+// with access to the app, or a deploy token that can deploy it. Viewers may
+// fetch but not push. It returns the actor to record.
+func (s *Server) gitAuth(r *http.Request, a *app.App, push bool) (string, bool) {
+	_, token, ok := r.BasicAuth()
+	if !ok || token == "" {
+		return "", false
+	}
+	if session := s.auth.GetSession(token); session != nil {
+		if push && session.UserRole == "viewer" {
+			return "", false
+		}
+		sc, err := s.userScope(session.UserID, session.UserRole)
+		if err != nil || (sc != nil && !sc.allows(a.ID)) {
+			return "", false
+		}
+		if session.UserEmail != "" {
+			return session.UserEmail, true
+		}
+		return "admin", true
+	}
+
+	h := sha256.Sum256([]byte(token))
+	dt, err := s.storage.GetDeployTokenByHash(hex.EncodeToString(h[:]))
+	if err != nil || dt == nil {
+		return "", false
+	}
+	if dt.ExpiresAt != nil && time.Now().After(*dt.ExpiresAt) {
+		return "", false
+	}
+	if !deployTokenCanDeployApp(dt, a.Name, a) {
+		return "", false
+	}
+	s.storage.UpdateDeployTokenLastUsed(dt.ID)
+	return "token:" + dt.Name, true
+}
+
+// handleGitHTTP serves an app's repo over git's smart HTTP protocol, so
+// `git push basepod main` builds and deploys the pushed tree
+func (s *Server) handleGitHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := gitRepoName(r.PathValue("repo"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	service := gitService(r, r.PathValue("rest"))
+	if service == "" {
+		http.NotFound(w, r)
+		return
+	}
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		http.Error(w, "git is not installed on the server", http.StatusNotImplemented)
+		return
+	}
+
+	a, err := s.storage.GetAppByName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if a == nil {
+		http.Error(w, "App not found: create it first with bp create "+name, http.StatusNotFound)
+		return
+	}
+	actor, ok := s.gitAuth(r, a, service == "git-receive-pack")
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="basepod"`)
+		http.Error(w, "Unauthorized: use a deploy token or session token as the password", http.StatusUnauthorized)
+		return
+	}
+
+	paths, err := config.GetPaths()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dir := filepath.Join(paths.Git, a.Name+".git")
+	if err := ensureGitRepo(r.Context(), dir); err != nil {
+		log.Printf("Git %s: %v", a.Name, err)
+		http.Error(w, "Failed to prepare repository", http.StatusInternalServerError)
+		return
+	}
+
+	if r.ContentLength < 0 {
+		cleanup, err := spoolRequestBody(r)
+		if err != nil {
+			http.Error(w, "Failed to read push: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer cleanup()
+	}
+
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Root: "/git",
+		Dir:  dir,
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + paths.Git,
+			"GIT_HTTP_EXPORT_ALL=1",
+			"REMOTE_USER=" + actor,
+		},
+	}
+	// http-backend serves /<app>.git/...; the app's real name keeps the path
+	// inside GIT_PROJECT_ROOT
+	r.URL.Path = "/git/" + a.Name + ".git/" + r.PathValue("rest")
+	backend.ServeHTTP(w, r)
+
+	if r.Method == http.MethodPost && service == "git-receive-pack" {
+		s.deployGitPush(a, dir, actor)
+	}
+}
+
+// spoolRequestBody saves a chunked request body to a temp file so the
+// request has a length; CGI can't pass chunked bodies on, and git sends
+// large pushes chunked
+func spoolRequestBody(r *http.Request) (func(), error) {
+	f, err := os.CreateTemp("", "basepod-git-*")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	n, err := io.Copy(f, r.Body)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	r.Body = f
+	r.ContentLength = n
+	r.TransferEncoding = nil
+	return cleanup, nil
+}
+
+// deployGitPush deploys the app's branch if the push that just finished
+// updated it
+func (s *Server) deployGitPush(a *app.App, dir, actor string) {
+	pushedPath := filepath.Join(dir, gitPushedFile)
+	data, err := os.ReadFile(pushedPath)
+	if err != nil {
+		return
+	}
+	_ = os.Remove(pushedPath)
+
+	branch := a.Deployment.Branch
+	if branch == "" {
+		branch = "main"
+	}
+	ref := pushedBranch(parseGitPushed(string(data)), branch)
+	if ref == nil {
+		log.Printf("Git push %s by %s: branch %s not updated, not deploying", a.Name, actor, branch)
+		return
+	}
+
+	commitMsg, _ := execCommand(context.Background(), "git", "--git-dir", dir, "log", "-1", "--format=%s", ref.New)
+	commitHash := ref.New
+	if len(commitHash) > 7 {
+		commitHash = commitHash[:7]
+	}
+
+	delivery := &app.WebhookDelivery{
+		ID:        uuid.New().String(),
+		AppID:     a.ID,
+		Event:     "git-push",
+		Branch:    branch,
+		Commit:    commitHash,
+		Message:   strings.TrimSpace(commitMsg),
+		Status:    "deploying",
+		CreatedAt: time.Now(),
+	}
+	if fw := s.activeFreeze(); fw != nil {
+		delivery.Status = "skipped"
+		delivery.Error = fmt.Sprintf("Deploys are frozen: %s (%s)", fw.Name, describeFreezeWindow(fw))
+	}
+	s.storage.SaveWebhookDelivery(delivery)
+	s.logActivity(actor, "git_push", "app", a.ID, a.Name, "success", fmt.Sprintf("%s %s", branch, commitHash))
+	if delivery.Status == "skipped" {
+		log.Printf("Git push %s: %s", a.Name, delivery.Error)
+		return
+	}
+
+	go s.deployFromGit(a, "file://"+dir, commitHash, strings.TrimSpace(commitMsg), branch, delivery.ID)
+}
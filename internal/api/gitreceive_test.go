@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitRepoName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"myapp.git": "myapp",
+		"myapp":     "",
+		".git":      "",
+		"..git":     "",
+		"a\\b.git":  "",
+	}
+	for segment, want := range tests {
+		got, ok := gitRepoName(segment)
+		if got != want || ok != (want != "") {
+			t.Fatalf("gitRepoName(%q) = %q, %v; want %q", segment, got, ok, want)
+		}
+	}
+}
+
+func TestGitService(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method, target, rest, want string
+	}{
+		{"GET", "/git/a.git/info/refs?service=git-receive-pack", "info/refs", "git-receive-pack"},
+		{"GET", "/git/a.git/info/refs?service=git-upload-pack", "info/refs", "git-upload-pack"},
+		{"GET", "/git/a.git/info/refs", "info/refs", ""},
+		{"POST", "/git/a.git/git-receive-pack", "git-receive-pack", "git-receive-pack"},
+		{"GET", "/git/a.git/git-receive-pack", "git-receive-pack", ""},
+		{"GET", "/git/a.git/HEAD", "HEAD", ""},
+		{"GET", "/git/a.git/objects/info/packs", "objects/info/packs", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		if got := gitService(r, tt.rest); got != tt.want {
+			t.Fatalf("gitService(%s %s) = %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestPushedBranch(t *testing.T) {
+	t.Parallel()
+
+	refs := parseGitPushed("aaa bbb refs/heads/feature\n" +
+		"ccc ddd refs/heads/main\n" +
+		"garbage\n" +
+		"eee " + gitZeroHash + " refs/heads/old\n")
+	if len(refs) != 3 {
+		t.Fatalf("parsed %d refs, want 3", len(refs))
+	}
+	if ref := pushedBranch(refs, "main"); ref == nil || ref.New != "ddd" {
+		t.Fatalf("pushedBranch(main) = %+v", ref)
+	}
+	if ref := pushedBranch(refs, "old"); ref != nil {
+		t.Fatalf("deleted branch was deployed: %+v", ref)
+	}
+	if ref := pushedBranch(refs, "develop"); ref != nil {
+		t.Fatalf("untouched branch was deployed: %+v", ref)
+	}
+}
+
+func TestEnsureGitRepoRecordsPushes(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	ctx := context.Background()
+	repo := filepath.Join(t.TempDir(), "myapp.git")
+	if err := ensureGitRepo(ctx, repo); err != nil {
+		t.Fatalf("ensureGitRepo: %v", err)
+	}
+	// A second call keeps the repo and rewrites the hook
+	if err := ensureGitRepo(ctx, repo); err != nil {
+		t.Fatalf("ensureGitRepo again: %v", err)
+	}
+
+	work := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "--quiet", "-b", "main")
+	if err := os.WriteFile(filepath.Join(work, "Dockerfile"), []byte("FROM scratch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "--quiet", "-m", "first")
+	out := git("push", repo, "main")
+	if !strings.Contains(out, "basepod: push received") {
+		t.Fatalf("hook message missing from push output:\n%s", out)
+	}
+
+	data, err := os.ReadFile(filepath.Join(repo, gitPushedFile))
+	if err != nil {
+		t.Fatalf("hook didn't record the push: %v", err)
+	}
+	ref := pushedBranch(parseGitPushed(string(data)), "main")
+	if ref == nil || ref.Old != gitZeroHash {
+		t.Fatalf("recorded refs = %q", data)
+	}
+	if head := strings.TrimSpace(git("rev-parse", "HEAD")); ref.New != head {
+		t.Fatalf("recorded %s, want %s", ref.New, head)
+	}
+}
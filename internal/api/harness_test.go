@@ -0,0 +1,284 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/podman"
+	"github.com/base-go/basepod/internal/storage"
+)
+
+// TestMain points BASEPOD_HOME at a scratch directory so the servers tests
+// start keep their database and files out of the real base directory
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "basepod-api-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("BASEPOD_HOME", home)
+	// Servers share the database, so a reconcile by one would restart the
+	// apps of tests running after it on its own fake runtime
+	reconcileDelay = time.Hour
+	// Deploys that never become ready fail without the full minute's wait
+	appReadyTimeout = 3 * time.Second
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// testServer is an API server running on the fake container runtime and
+// Caddy admin API, with an admin session
+type testServer struct {
+	*Server
+	t       *testing.T
+	runtime *podman.Fake
+	admin   *caddy.FakeAdmin
+	http    *httptest.Server
+	token   string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	if err := config.EnsureDirectories(); err != nil {
+		t.Fatalf("EnsureDirectories: %v", err)
+	}
+	store, err := storage.New()
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	fake := podman.NewFake()
+	fake.CreateNetwork(context.Background(), "basepod")
+	caddyClient, admin, stopCaddy, err := caddy.StartFake()
+	if err != nil {
+		t.Fatalf("caddy.StartFake: %v", err)
+	}
+	if err := caddyClient.EnsureBaseConfig(0, "test"); err != nil {
+		t.Fatalf("EnsureBaseConfig: %v", err)
+	}
+
+	s := NewServerWithVersion(store, fake, caddyClient, "test")
+	s.auth.SetPassword("test-password")
+	session, err := s.auth.CreateSession()
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	ts := &testServer{Server: s, t: t, runtime: fake, admin: admin, http: httptest.NewServer(s), token: session.Token}
+	t.Cleanup(func() {
+		ts.http.Close()
+		stopCaddy()
+		// Apps left running would hold host ports later tests' apps may hash to
+		fake.Close()
+	})
+	return ts
+}
+
+// do sends an API request as the admin and decodes a JSON response into out
+func (ts *testServer) do(method, path string, body, out interface{}) int {
+	ts.t.Helper()
+	return ts.doAs(ts.token, method, path, body, out)
+}
+
+// doAs is do with another session or API token
+func (ts *testServer) doAs(token, method, path string, body, out interface{}) int {
+	ts.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, ts.http.URL+path, reader)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if out != nil && json.Unmarshal(data, out) != nil {
+		ts.t.Fatalf("%s %s: status %d, undecodable body %s", method, path, resp.StatusCode, data)
+	}
+	return resp.StatusCode
+}
+
+// get fetches a non-JSON response body as the admin
+func (ts *testServer) get(path string) string {
+	ts.t.Helper()
+	req, _ := http.NewRequest("GET", ts.http.URL+path, nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		ts.t.Fatalf("GET %s: status %d: %s", path, resp.StatusCode, data)
+	}
+	return string(data)
+}
+
+// waitForStatus polls an app until it has the given status
+func (ts *testServer) waitForStatus(id string, status app.AppStatus) *app.App {
+	ts.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var a app.App
+		ts.do("GET", "/api/apps/"+id, nil, &a)
+		if a.Status == status {
+			return &a
+		}
+		if time.Now().After(deadline) {
+			ts.t.Fatalf("app %s is %q, want %q", id, a.Status, status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// container returns the fake container with the given name, or nil
+func (ts *testServer) container(name string) *podman.Container {
+	ts.t.Helper()
+	containers, _ := ts.runtime.ListContainers(context.Background(), true)
+	for i, c := range containers {
+		if c.Names[0] == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// waitForRoute polls the fake Caddy until a route with the given ID exists
+func (ts *testServer) waitForRoute(id string) {
+	ts.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		routes, _ := ts.caddy.GetRoutes()
+		for _, r := range routes {
+			if r.ID == id {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			ts.t.Fatalf("caddy route %s was never added", id)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// caddyRoute finds a route by ID in the fake Caddy's config, as plain JSON
+// maps and slices
+func (ts *testServer) caddyRoute(id string) map[string]interface{} {
+	ts.t.Helper()
+	data, _ := json.Marshal(ts.admin.Config())
+	var config map[string]interface{}
+	json.Unmarshal(data, &config)
+	var find func(node interface{}) map[string]interface{}
+	find = func(node interface{}) map[string]interface{} {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if n["@id"] == id {
+				return n
+			}
+			for _, v := range n {
+				if r := find(v); r != nil {
+					return r
+				}
+			}
+		case []interface{}:
+			for _, v := range n {
+				if r := find(v); r != nil {
+					return r
+				}
+			}
+		}
+		return nil
+	}
+	route := find(config)
+	if route == nil {
+		ts.t.Fatalf("caddy route %s not found", id)
+	}
+	return route
+}
+
+func TestAppLifecycleOnFakeRuntime(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	if code := ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "it-web", Domain: "it-web.test"}, &a); code != http.StatusCreated && code != http.StatusOK {
+		t.Fatalf("create app: status %d", code)
+	}
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	ts.waitForRoute("basepod-it-web")
+
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/deploy", app.DeployRequest{Image: "ghcr.io/example/web:1"}, &a); code != http.StatusOK {
+		t.Fatalf("deploy: status %d", code)
+	}
+	if a.Status != app.StatusRunning {
+		t.Fatalf("status after deploy = %q, want running", a.Status)
+	}
+	c := ts.container("basepod-it-web")
+	if c == nil || c.State != "running" {
+		t.Fatalf("container after deploy = %+v, want running", c)
+	}
+	if c.Image != "ghcr.io/example/web:1" {
+		t.Fatalf("container image = %q, want ghcr.io/example/web:1", c.Image)
+	}
+	ts.waitForRoute("basepod-it-web")
+
+	var status AppStatusReport
+	if code := ts.do("GET", "/api/apps/it-web/status", nil, &status); code != http.StatusOK {
+		t.Fatalf("status: status %d", code)
+	}
+	if status.Container == nil || !status.Container.Running || status.Stats == nil {
+		t.Fatalf("status after deploy = %+v, want a running container with stats", status)
+	}
+	if status.Image != "ghcr.io/example/web:1" {
+		t.Fatalf("status image = %q, want ghcr.io/example/web:1", status.Image)
+	}
+	var live struct {
+		Apps []LiveStats `json:"apps"`
+	}
+	ts.do("GET", "/api/metrics/live", nil, &live)
+	if len(live.Apps) != 1 || live.Apps[0].Name != "it-web" || live.Apps[0].ContainerStatsResult == nil {
+		t.Fatalf("live metrics = %+v, want stats for it-web", live.Apps)
+	}
+	scrape := ts.get("/metrics")
+	for _, want := range []string{`basepod_app_up{app="it-web",status="running"} 1`, `basepod_app_memory_usage_bytes{app="it-web"}`, `route="/api/apps/{id}/deploy"`} {
+		if !strings.Contains(scrape, want) {
+			t.Fatalf("/metrics is missing %q:\n%s", want, scrape)
+		}
+	}
+
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/stop", nil, nil); code != http.StatusOK {
+		t.Fatalf("stop: status %d", code)
+	}
+	if c := ts.container("basepod-it-web"); c == nil || c.State == "running" {
+		t.Fatalf("container after stop = %+v, want stopped", c)
+	}
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/start", nil, nil); code != http.StatusOK {
+		t.Fatalf("start: status %d", code)
+	}
+	if c := ts.container("basepod-it-web"); c == nil || c.State != "running" {
+		t.Fatalf("container after start = %+v, want running", c)
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK && code != http.StatusNoContent {
+		t.Fatalf("delete: status %d", code)
+	}
+	if c := ts.container("basepod-it-web"); c != nil {
+		t.Fatalf("container after delete = %+v, want none", c)
+	}
+}
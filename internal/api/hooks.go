@@ -0,0 +1,300 @@
+package api
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/google/uuid"
+)
+
+// gitPushEvent is a push from a git host, reduced to what a deploy needs
+type gitPushEvent struct {
+	Branch   string
+	Commit   string   // Short hash
+	Message  string   // First line
+	RepoURLs []string // Clone and web URLs of the pushed repo
+}
+
+// parseGitHubPush reads a GitHub push payload
+func parseGitHubPush(body []byte) (gitPushEvent, error) {
+	var payload struct {
+		Ref        string `json:"ref"`
+		HeadCommit struct {
+			ID      string `json:"id"`
+			Message string `json:"message"`
+		} `json:"head_commit"`
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+			SSHURL   string `json:"ssh_url"`
+			HTMLURL  string `json:"html_url"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return gitPushEvent{}, err
+	}
+	return newGitPushEvent(payload.Ref, payload.HeadCommit.ID, payload.HeadCommit.Message,
+		payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL), nil
+}
+
+// parseGitLabPush reads a GitLab push hook payload
+func parseGitLabPush(body []byte) (gitPushEvent, error) {
+	var payload struct {
+		Ref         string `json:"ref"`
+		CheckoutSHA string `json:"checkout_sha"`
+		Commits     []struct {
+			ID      string `json:"id"`
+			Message string `json:"message"`
+		} `json:"commits"`
+		Project struct {
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+			WebURL     string `json:"web_url"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return gitPushEvent{}, err
+	}
+	message := ""
+	for _, c := range payload.Commits {
+		if c.ID == payload.CheckoutSHA {
+			message = c.Message
+		}
+	}
+	return newGitPushEvent(payload.Ref, payload.CheckoutSHA, message,
+		payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL), nil
+}
+
+func newGitPushEvent(ref, commit, message string, repoURLs ...string) gitPushEvent {
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if idx := strings.Index(message, "\n"); idx > 0 {
+		message = message[:idx]
+	}
+	return gitPushEvent{
+		Branch:   strings.TrimPrefix(ref, "refs/heads/"),
+		Commit:   commit,
+		Message:  message,
+		RepoURLs: repoURLs,
+	}
+}
+
+// normalizeRepoURL reduces the ways of writing a repo URL to host/path, so
+// https://github.com/acme/site.git, git@github.com:acme/site and
+// https://user@github.com/acme/site/ all match
+func normalizeRepoURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	var host, path string
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(raw, "@"); at >= 0 && strings.Contains(raw[at:], ":") {
+		// scp-like: git@github.com:acme/site.git
+		rest := raw[at+1:]
+		colon := strings.Index(rest, ":")
+		host, path = rest[:colon], rest[colon+1:]
+	} else {
+		return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(raw, "/"), ".git"))
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host + "/" + path)
+}
+
+// repoMatches reports whether an app's git URL is one of the pushed repo's
+func repoMatches(gitURL string, repoURLs []string) bool {
+	want := normalizeRepoURL(gitURL)
+	if want == "" {
+		return false
+	}
+	for _, u := range repoURLs {
+		if normalizeRepoURL(u) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// hookCandidates returns the apps a hook call may be for: the one named by
+// ?app=, or the apps with a webhook secret whose git URL is the pushed repo
+func (s *Server) hookCandidates(r *http.Request, repoURLs []string) ([]app.App, error) {
+	if name := r.URL.Query().Get("app"); name != "" {
+		a, err := s.resolveApp(name)
+		if err != nil || a == nil {
+			return nil, err
+		}
+		return []app.App{*a}, nil
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return nil, err
+	}
+	var matched []app.App
+	for _, a := range apps {
+		if a.Deployment.WebhookSecret != "" && repoMatches(a.Deployment.GitURL, repoURLs) {
+			matched = append(matched, a)
+		}
+	}
+	return matched, nil
+}
+
+// triggerPushDeploy records a push delivery for an app and starts a deploy
+// when the branch matches and auto-deploy is on. It returns the delivery's
+// status and, for skipped pushes, why.
+func (s *Server) triggerPushDeploy(a *app.App, event string, push gitPushEvent) (string, string) {
+	delivery := &app.WebhookDelivery{
+		ID:        uuid.New().String(),
+		AppID:     a.ID,
+		Event:     event,
+		Branch:    push.Branch,
+		Commit:    push.Commit,
+		Message:   push.Message,
+		Status:    "deploying",
+		CreatedAt: time.Now(),
+	}
+	switch {
+	case push.Branch != a.Deployment.Branch:
+		delivery.Status = "skipped"
+		delivery.Error = fmt.Sprintf("Branch %s does not match configured branch %s", push.Branch, a.Deployment.Branch)
+	case !a.Deployment.AutoDeploy:
+		delivery.Status = "skipped"
+		delivery.Error = "Auto-deploy is disabled"
+	default:
+		if fw := s.activeFreeze(); fw != nil {
+			delivery.Status = "skipped"
+			delivery.Error = fmt.Sprintf("Deploys are frozen: %s (%s)", fw.Name, describeFreezeWindow(fw))
+		}
+	}
+	s.storage.SaveWebhookDelivery(delivery)
+	if delivery.Status == "deploying" {
+		go s.deployFromGit(a, a.Deployment.GitURL, push.Commit, push.Message, push.Branch, delivery.ID)
+	}
+	return delivery.Status, delivery.Error
+}
+
+// handleGitHubHook receives GitHub webhooks for any app. The app is found
+// by ?app= or by the pushed repo, and the signature is checked against
+// that app's secret.
+func (s *Server) handleGitHubHook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 25<<20))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		errorResponse(w, http.StatusForbidden, "Missing signature")
+		return
+	}
+	event := r.Header.Get("X-GitHub-Event")
+
+	var push gitPushEvent
+	if event == "push" {
+		if push, err = parseGitHubPush(body); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Failed to parse payload")
+			return
+		}
+	} else {
+		// Pings and other events carry the repo too
+		push, _ = parseGitHubPush(body)
+	}
+
+	s.serveHook(w, r, push, "github", event, func(a *app.App) bool {
+		return validateGitHubSignature(body, signature, a.Deployment.WebhookSecret)
+	})
+}
+
+// handleGitLabHook receives GitLab webhooks for any app. GitLab sends the
+// secret itself in X-Gitlab-Token.
+func (s *Server) handleGitLabHook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 25<<20))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	token := r.Header.Get("X-Gitlab-Token")
+	if token == "" {
+		errorResponse(w, http.StatusForbidden, "Missing X-Gitlab-Token")
+		return
+	}
+	event := "other"
+	if r.Header.Get("X-Gitlab-Event") == "Push Hook" {
+		event = "push"
+	}
+
+	push, err := parseGitLabPush(body)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Failed to parse payload")
+		return
+	}
+
+	s.serveHook(w, r, push, "gitlab", event, func(a *app.App) bool {
+		return hmac.Equal([]byte(token), []byte(a.Deployment.WebhookSecret))
+	})
+}
+
+// serveHook deploys every app the hook call is for and whose secret it
+// carries
+func (s *Server) serveHook(w http.ResponseWriter, r *http.Request, push gitPushEvent, provider, event string, verified func(*app.App) bool) {
+	candidates, err := s.hookCandidates(r, push.RepoURLs)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var apps []app.App
+	for _, a := range candidates {
+		if a.Deployment.WebhookSecret != "" && verified(&a) {
+			apps = append(apps, a)
+		}
+	}
+	if len(apps) == 0 {
+		// Same answer for unknown repos and wrong secrets
+		errorResponse(w, http.StatusForbidden, "No app with this webhook secret is configured for the repository")
+		return
+	}
+
+	results := []map[string]string{}
+	for i := range apps {
+		a := &apps[i]
+		status, reason := "", ""
+		switch event {
+		case "ping":
+			status = "success"
+			s.storage.SaveWebhookDelivery(&app.WebhookDelivery{
+				ID:        uuid.New().String(),
+				AppID:     a.ID,
+				Event:     provider + ":ping",
+				Status:    status,
+				Message:   "Webhook configured successfully",
+				CreatedAt: time.Now(),
+			})
+		case "push":
+			status, reason = s.triggerPushDeploy(a, provider+":push", push)
+		default:
+			status, reason = "skipped", "Unsupported event type: "+event
+		}
+		result := map[string]string{"app": a.Name, "status": status}
+		if reason != "" {
+			result["reason"] = reason
+		}
+		results = append(results, result)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// requestBaseURL is the scheme and host a request reached the server on
+func requestBaseURL(r *http.Request) string {
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host
+}
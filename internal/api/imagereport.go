@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// imageReportLayers is how many of the biggest layers a report keeps
+const imageReportLayers = 3
+
+// imageGrowthWarning is the size ratio against the previous build that gets
+// called out in the deploy output
+const imageGrowthWarning = 1.5
+
+// inspectImageReport reads the layer history of a built image
+func (s *Server) inspectImageReport(ctx context.Context, image string) (*app.ImageReport, error) {
+	layers, err := s.podman.ImageHistory(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return buildImageReport(layers), nil
+}
+
+// buildImageReport totals an image's layers and keeps the largest ones.
+// Empty layers (ENV, CMD, ...) are not counted.
+func buildImageReport(history []podman.ImageLayer) *app.ImageReport {
+	report := &app.ImageReport{}
+	var layers []app.ImageLayer
+	for _, l := range history {
+		if l.Size <= 0 {
+			continue
+		}
+		report.Size += l.Size
+		report.Layers++
+		layers = append(layers, app.ImageLayer{Size: l.Size, CreatedBy: cleanLayerCommand(l.CreatedBy)})
+	}
+
+	sort.SliceStable(layers, func(i, j int) bool { return layers[i].Size > layers[j].Size })
+	if len(layers) > imageReportLayers {
+		layers = layers[:imageReportLayers]
+	}
+	report.LargestLayers = layers
+	return report
+}
+
+// cleanLayerCommand shortens a history entry such as
+// "/bin/sh -c #(nop) COPY dir:abc in /app" to "COPY dir:abc in /app" and
+// "/bin/sh -c npm ci" to "RUN npm ci"
+func cleanLayerCommand(createdBy string) string {
+	cmd := strings.TrimSpace(createdBy)
+	if i := strings.Index(cmd, "/bin/sh -c "); i >= 0 {
+		cmd = strings.TrimSpace(cmd[i+len("/bin/sh -c "):])
+		if rest, ok := strings.CutPrefix(cmd, "#(nop)"); ok {
+			cmd = strings.TrimSpace(rest)
+		} else {
+			cmd = "RUN " + cmd
+		}
+	}
+	if len(cmd) > 80 {
+		cmd = cmd[:77] + "..."
+	}
+	return cmd
+}
+
+// previousImageReport returns the report of the most recent deploy that has one
+func previousImageReport(deployments []app.DeploymentRecord) *app.ImageReport {
+	for _, d := range deployments {
+		if d.ImageReport != nil {
+			return d.ImageReport
+		}
+	}
+	return nil
+}
+
+// formatImageReport renders a report for the build log, comparing it with the
+// previous build when there is one
+func formatImageReport(report, previous *app.ImageReport) []string {
+	line := fmt.Sprintf("Image size: %s, %d layers", formatImageSize(report.Size), report.Layers)
+	if previous != nil && previous.Size > 0 {
+		line += fmt.Sprintf(" (%s vs previous %s)", formatSizeChange(report.Size, previous.Size), formatImageSize(previous.Size))
+	}
+	lines := []string{line}
+	if previous != nil && previous.Size > 0 && float64(report.Size) >= float64(previous.Size)*imageGrowthWarning {
+		lines = append(lines, fmt.Sprintf("WARNING: Image grew %.1fx since the previous deploy", float64(report.Size)/float64(previous.Size)))
+	}
+	for _, l := range report.LargestLayers {
+		lines = append(lines, fmt.Sprintf("  %10s  %s", formatImageSize(l.Size), l.CreatedBy))
+	}
+	return lines
+}
+
+// formatSizeChange renders the difference between two sizes as a signed percentage
+func formatSizeChange(size, previous int64) string {
+	return fmt.Sprintf("%+.0f%%", (float64(size)-float64(previous))/float64(previous)*100)
+}
+
+// formatImageSize renders a byte count the way podman does (decimal units)
+func formatImageSize(b int64) string {
+	const unit = 1000
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "kMGTPE"[exp])
+}
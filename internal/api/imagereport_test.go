@@ -0,0 +1,52 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+func TestBuildImageReport(t *testing.T) {
+	t.Parallel()
+
+	report := buildImageReport([]podman.ImageLayer{
+		{CreatedBy: `/bin/sh -c #(nop) CMD ["node","server.js"]`, Size: 0},
+		{CreatedBy: "/bin/sh -c #(nop) COPY dir:abc in /app", Size: 5_000_000},
+		{CreatedBy: "/bin/sh -c npm ci", Size: 300_000_000},
+		{CreatedBy: "/bin/sh -c apk add --no-cache git", Size: 20_000_000},
+		{CreatedBy: "/bin/sh -c #(nop) ADD file:def in /", Size: 7_000_000},
+	})
+
+	if report.Size != 332_000_000 || report.Layers != 4 {
+		t.Fatalf("report = %d bytes, %d layers; want 332000000 bytes, 4 layers", report.Size, report.Layers)
+	}
+	if len(report.LargestLayers) != imageReportLayers {
+		t.Fatalf("largest layers = %d, want %d", len(report.LargestLayers), imageReportLayers)
+	}
+	if got := report.LargestLayers[0].CreatedBy; got != "RUN npm ci" {
+		t.Fatalf("largest layer = %q, want %q", got, "RUN npm ci")
+	}
+	if got := report.LargestLayers[2].CreatedBy; got != "ADD file:def in /" {
+		t.Fatalf("third layer = %q, want %q", got, "ADD file:def in /")
+	}
+}
+
+func TestFormatImageReportWarnsOnGrowth(t *testing.T) {
+	t.Parallel()
+
+	previous := &app.ImageReport{Size: 200_000_000, Layers: 10}
+	lines := formatImageReport(&app.ImageReport{Size: 900_000_000, Layers: 12}, previous)
+	if lines[0] != "Image size: 900.0 MB, 12 layers (+350% vs previous 200.0 MB)" {
+		t.Fatalf("summary = %q", lines[0])
+	}
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], "WARNING: Image grew 4.5x") {
+		t.Fatalf("expected growth warning, got %q", lines)
+	}
+
+	lines = formatImageReport(&app.ImageReport{Size: 210_000_000, Layers: 10}, previous)
+	if len(lines) != 1 {
+		t.Fatalf("unexpected warning for small growth: %q", lines)
+	}
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+	"github.com/google/uuid"
+)
+
+// jobTimeout bounds a one-off job container; it is stopped after this long
+const jobTimeout = time.Hour
+
+// jobOutputLines is how much of a job container's output its run keeps
+const jobOutputLines = "500"
+
+// jobExitError is a job container that exited with a non-zero code
+type jobExitError int
+
+func (e jobExitError) Error() string {
+	return fmt.Sprintf("exited with code %d", int(e))
+}
+
+// jobContainerName is the one-off container for a run of an app's job
+func jobContainerName(appName, jobName string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(jobName))
+	return fmt.Sprintf("basepod-%s-job-%s-%s", appName, name, uuid.New().String()[:8])
+}
+
+// createOneOffContainer creates a container from the app's image, with the
+// app's env, network, volumes and limits, that runs command. It isn't
+// labelled as an app container, so its exit doesn't touch the app's status.
+func (s *Server) createOneOffContainer(ctx context.Context, a *app.App, name string, command []string, labels map[string]string) (string, error) {
+	return s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
+		Name:     name,
+		Image:    a.Image,
+		Env:      s.containerEnv(a),
+		Networks: []string{"basepod"},
+		Volumes:  s.appVolumeMounts(ctx, a),
+		Command:  command,
+		Labels:   labels,
+		Memory:   a.Resources.MemoryBytes(),
+		CPUs:     a.Resources.CPUs,
+	})
+}
+
+// runJobContainer runs a job's command in a one-off container from the app's
+// image and returns its output. The container is removed afterwards.
+func (s *Server) runJobContainer(ctx context.Context, a *app.App, job *app.CronJob) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	containerID, err := s.createOneOffContainer(ctx, a, jobContainerName(a.Name, job.Name), []string{"/bin/sh", "-c", job.Command}, map[string]string{
+		"basepod.job":     job.ID,
+		"basepod.job.app": a.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create job container: %w", err)
+	}
+	defer s.podman.RemoveContainer(context.Background(), containerID, true)
+
+	if err := s.podman.StartContainer(ctx, containerID); err != nil {
+		return "", fmt.Errorf("failed to start job container: %w", err)
+	}
+	exitCode, waitErr := s.podman.WaitContainer(ctx, containerID)
+	if ctx.Err() != nil {
+		_ = s.podman.StopContainer(context.Background(), containerID, 10)
+		waitErr = fmt.Errorf("timed out after %s", jobTimeout)
+	}
+
+	output := ""
+	if logs, err := s.podman.ContainerLogs(context.Background(), containerID, podman.LogOpts{Stdout: true, Stderr: true, Tail: jobOutputLines}); err == nil {
+		data, _ := io.ReadAll(logs)
+		logs.Close()
+		output = podman.DemuxLogs(data)
+	}
+	if waitErr != nil {
+		return output, waitErr
+	}
+	if exitCode != 0 {
+		return output, jobExitError(exitCode)
+	}
+	return output, nil
+}
+
+// validateJobConfigs checks the jobs: section of an app's basepod.yaml
+func validateJobConfigs(jobs map[string]app.JobConfig) error {
+	for name, jc := range jobs {
+		job := &app.CronJob{Name: name, Schedule: jc.Schedule, Action: app.CronActionRun, Command: jc.Command}
+		if err := validateCronJob(job); err != nil {
+			return fmt.Errorf("job %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// syncConfigJobs makes an app's basepod.yaml jobs match jobs: new ones are
+// added, changed ones updated and ones no longer listed removed. Jobs made
+// in the dashboard or with bp cron are left alone, and a job disabled there
+// stays disabled. It returns the names of the jobs now defined.
+func (s *Server) syncConfigJobs(a *app.App, jobs map[string]app.JobConfig) ([]string, error) {
+	if err := validateJobConfigs(jobs); err != nil {
+		return nil, err
+	}
+	existing, err := s.storage.ListCronJobs(a.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	seen := map[string]bool{}
+	for i := range existing {
+		job := &existing[i]
+		if job.Source != app.CronSourceConfig {
+			continue
+		}
+		jc, ok := jobs[job.Name]
+		if !ok || seen[job.Name] {
+			if err := s.storage.DeleteCronJob(job.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		seen[job.Name] = true
+		if job.Schedule == jc.Schedule && job.Command == jc.Command && job.Action == app.CronActionRun {
+			continue
+		}
+		job.Schedule, job.Command, job.Action = jc.Schedule, jc.Command, app.CronActionRun
+		job.NextRun = nextCronRun(job, now)
+		if err := s.storage.UpdateCronJob(job); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(jobs))
+	for name, jc := range jobs {
+		names = append(names, name)
+		if seen[name] {
+			continue
+		}
+		job := &app.CronJob{
+			ID:        uuid.New().String(),
+			AppID:     a.ID,
+			Name:      name,
+			Schedule:  jc.Schedule,
+			Action:    app.CronActionRun,
+			Command:   jc.Command,
+			Source:    app.CronSourceConfig,
+			Enabled:   true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		job.NextRun = nextCronRun(job, now)
+		if err := s.storage.CreateCronJob(job); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// jobListEntry is a job in the server-wide job list
+type jobListEntry struct {
+	app.CronJob
+	AppName string `json:"app_name"`
+}
+
+// handleListJobs lists the scheduled jobs of every app the caller can see,
+// or of one app with ?app=
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	names := map[string]string{}
+	for _, a := range sc.filterApps(apps) {
+		names[a.ID] = a.Name
+	}
+
+	filter := ""
+	if name := r.URL.Query().Get("app"); name != "" {
+		a, _ := s.resolveApp(name)
+		if a == nil || names[a.ID] == "" {
+			errorResponse(w, http.StatusNotFound, "App not found")
+			return
+		}
+		filter = a.ID
+	}
+
+	jobs, err := s.storage.ListAllCronJobs()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	entries := []jobListEntry{}
+	for _, job := range jobs {
+		appName, ok := names[job.AppID]
+		if !ok || (filter != "" && job.AppID != filter) {
+			continue
+		}
+		entries = append(entries, jobListEntry{CronJob: job, AppName: appName})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].AppName != entries[j].AppName {
+			return entries[i].AppName < entries[j].AppName
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"jobs": entries})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestValidateJobConfigs(t *testing.T) {
+	t.Parallel()
+
+	ok := map[string]app.JobConfig{
+		"migrate": {Schedule: "0 3 * * *", Command: "./bin/migrate up"},
+		"report":  {Schedule: "@weekly", Command: "node report.js"},
+	}
+	if err := validateJobConfigs(ok); err != nil {
+		t.Fatalf("validateJobConfigs error = %v", err)
+	}
+	if err := validateJobConfigs(nil); err != nil {
+		t.Fatalf("validateJobConfigs(nil) error = %v", err)
+	}
+
+	bad := map[string]app.JobConfig{
+		"no command":   {Schedule: "@daily"},
+		"bad schedule": {Schedule: "every night", Command: "true"},
+	}
+	for name, jc := range bad {
+		err := validateJobConfigs(map[string]app.JobConfig{name: jc})
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("validateJobConfigs(%s) error = %v, want one naming the job", name, err)
+		}
+	}
+}
+
+func TestJobContainerName(t *testing.T) {
+	t.Parallel()
+
+	name := jobContainerName("web", "Nightly Report")
+	if !strings.HasPrefix(name, "basepod-web-job-nightly-report-") {
+		t.Fatalf("jobContainerName = %q", name)
+	}
+	if name == jobContainerName("web", "Nightly Report") {
+		t.Fatalf("jobContainerName should differ per run")
+	}
+}
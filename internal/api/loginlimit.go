@@ -0,0 +1,253 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+// Login limit defaults, used when auth.login_limit leaves a value unset
+const (
+	defaultLoginMaxAttempts = 5
+	defaultLoginWindow      = 15 * time.Minute
+	defaultLoginLockout     = time.Minute
+	defaultLoginMaxLockout  = time.Hour
+	// loginClientsSweep is how many tracked clients trigger dropping the idle ones
+	loginClientsSweep = 1024
+)
+
+// loginLimits are the resolved auth.login_limit settings
+type loginLimits struct {
+	maxAttempts int
+	window      time.Duration
+	lockout     time.Duration
+	maxLockout  time.Duration
+}
+
+func resolveLoginLimits(cfg config.LoginLimitConfig) loginLimits {
+	l := loginLimits{defaultLoginMaxAttempts, defaultLoginWindow, defaultLoginLockout, defaultLoginMaxLockout}
+	if cfg.MaxAttempts > 0 {
+		l.maxAttempts = cfg.MaxAttempts
+	}
+	if cfg.WindowSeconds > 0 {
+		l.window = time.Duration(cfg.WindowSeconds) * time.Second
+	}
+	if cfg.LockoutSeconds > 0 {
+		l.lockout = time.Duration(cfg.LockoutSeconds) * time.Second
+	}
+	if cfg.MaxLockoutSeconds > 0 {
+		l.maxLockout = time.Duration(cfg.MaxLockoutSeconds) * time.Second
+	}
+	if l.maxLockout < l.lockout {
+		l.maxLockout = l.lockout
+	}
+	return l
+}
+
+// loginClient is one IP's recent failed logins
+type loginClient struct {
+	failures    []time.Time // within the window, oldest first
+	lockouts    int         // lockouts in a row, for the backoff
+	lockedUntil time.Time
+}
+
+// loginLimiter counts failed logins per IP over a sliding window and locks
+// an IP out once it has too many, for twice as long on each repeat. The
+// zero value is ready to use.
+type loginLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*loginClient
+}
+
+// LoginLockout is an IP's login state as the lockouts API shows it
+type LoginLockout struct {
+	IP          string     `json:"ip"`
+	Failures    int        `json:"failures"`
+	Lockouts    int        `json:"lockouts"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// prune drops failures that have left the window. A client that has been
+// quiet for a whole window after its lockout starts its backoff over.
+func (c *loginClient) prune(now time.Time, limits loginLimits) {
+	cutoff := now.Add(-limits.window)
+	i := 0
+	for i < len(c.failures) && c.failures[i].Before(cutoff) {
+		i++
+	}
+	c.failures = c.failures[i:]
+	if len(c.failures) == 0 && now.After(c.lockedUntil.Add(limits.window)) {
+		c.lockouts = 0
+	}
+}
+
+// idle reports whether a client has nothing worth remembering
+func (c *loginClient) idle(now time.Time) bool {
+	return len(c.failures) == 0 && c.lockouts == 0 && !now.Before(c.lockedUntil)
+}
+
+// retryAfter returns how long ip is still locked out for, or 0
+func (l *loginLimiter) retryAfter(ip string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c := l.clients[ip]; c != nil && now.Before(c.lockedUntil) {
+		return c.lockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// fail records a failed login and returns the lockout it triggered, or 0
+func (l *loginLimiter) fail(ip string, now time.Time, limits loginLimits) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.clients == nil {
+		l.clients = make(map[string]*loginClient)
+	}
+	if len(l.clients) >= loginClientsSweep {
+		for key, c := range l.clients {
+			c.prune(now, limits)
+			if c.idle(now) {
+				delete(l.clients, key)
+			}
+		}
+	}
+	c := l.clients[ip]
+	if c == nil {
+		c = &loginClient{}
+		l.clients[ip] = c
+	}
+	c.prune(now, limits)
+	c.failures = append(c.failures, now)
+	if len(c.failures) < limits.maxAttempts {
+		return 0
+	}
+
+	lockout := limits.lockout
+	for i := 0; i < c.lockouts && lockout < limits.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > limits.maxLockout {
+		lockout = limits.maxLockout
+	}
+	c.lockouts++
+	c.lockedUntil = now.Add(lockout)
+	c.failures = nil
+	return lockout
+}
+
+// succeed forgets an IP's failures after a good login
+func (l *loginLimiter) succeed(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.clients, ip)
+}
+
+// list returns the IPs with recent failures or lockouts, locked ones first
+func (l *loginLimiter) list(now time.Time, limits loginLimits) []LoginLockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := []LoginLockout{}
+	for ip, c := range l.clients {
+		c.prune(now, limits)
+		if c.idle(now) {
+			delete(l.clients, ip)
+			continue
+		}
+		entry := LoginLockout{IP: ip, Failures: len(c.failures), Lockouts: c.lockouts}
+		if now.Before(c.lockedUntil) {
+			until := c.lockedUntil
+			entry.LockedUntil = &until
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if (result[i].LockedUntil != nil) != (result[j].LockedUntil != nil) {
+			return result[i].LockedUntil != nil
+		}
+		return result[i].IP < result[j].IP
+	})
+	return result
+}
+
+// clear forgets one IP, or every IP when ip is empty, and returns how many
+// were cleared
+func (l *loginLimiter) clear(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ip == "" {
+		n := len(l.clients)
+		l.clients = nil
+		return n
+	}
+	if _, ok := l.clients[ip]; !ok {
+		return 0
+	}
+	delete(l.clients, ip)
+	return 1
+}
+
+func (s *Server) loginLimits() loginLimits {
+	if s.config == nil {
+		return resolveLoginLimits(config.LoginLimitConfig{})
+	}
+	return resolveLoginLimits(s.config.Auth.LoginLimit)
+}
+
+// limitLogins wraps a password-checking endpoint with the login limiter:
+// locked out IPs get 429, a 401 counts as a failure and success resets
+func (s *Server) limitLogins(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := requestIP(r)
+		now := time.Now()
+		if wait := s.logins.retryAfter(ip, now); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+0.5)))
+			errorResponse(w, http.StatusTooManyRequests, fmt.Sprintf("Too many failed logins; try again in %s", wait.Round(time.Second)))
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		handler(rec, r)
+		switch {
+		case rec.status == http.StatusUnauthorized || rec.status == http.StatusNotFound:
+			if lockout := s.logins.fail(ip, now, s.loginLimits()); lockout > 0 {
+				s.logActivity("system", "login_lockout", "ip", ip, ip, "failed", fmt.Sprintf("locked out for %s after failed logins on %s", lockout, r.URL.Path))
+			}
+		case rec.status < 300:
+			s.logins.succeed(ip)
+		}
+	}
+}
+
+// handleListLoginLockouts shows IPs with recent failed logins
+func (s *Server) handleListLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	limits := s.loginLimits()
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"lockouts":            s.logins.list(time.Now(), limits),
+		"max_attempts":        limits.maxAttempts,
+		"window_seconds":      int(limits.window.Seconds()),
+		"lockout_seconds":     int(limits.lockout.Seconds()),
+		"max_lockout_seconds": int(limits.maxLockout.Seconds()),
+	})
+}
+
+// handleClearLoginLockout lifts the lockout on one IP, or on every IP for
+// DELETE /api/auth/lockouts
+func (s *Server) handleClearLoginLockout(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	cleared := s.logins.clear(ip)
+	if ip != "" && cleared == 0 {
+		errorResponse(w, http.StatusNotFound, "No failed logins from "+ip)
+		return
+	}
+	target := ip
+	if target == "" {
+		target = "all"
+	}
+	s.logActivity("user", "login_lockout_clear", "ip", target, target, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]int{"cleared": cleared})
+}
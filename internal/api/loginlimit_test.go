@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	t.Parallel()
+	limits := resolveLoginLimits(config.LoginLimitConfig{MaxAttempts: 3, WindowSeconds: 60, LockoutSeconds: 10, MaxLockoutSeconds: 30})
+	var l loginLimiter
+	now := time.Unix(1700000000, 0)
+
+	// Failures spread wider than the window never add up to a lockout
+	for i := 0; i < 5; i++ {
+		if lockout := l.fail("198.51.100.1", now.Add(time.Duration(i)*40*time.Second), limits); lockout != 0 {
+			t.Fatalf("failure %d locked out for %s, want no lockout", i, lockout)
+		}
+	}
+
+	ip := "203.0.113.9"
+	var lockouts []time.Duration
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 3; i++ {
+			if lockout := l.fail(ip, now, limits); lockout > 0 {
+				lockouts = append(lockouts, lockout)
+				now = now.Add(lockout)
+			}
+		}
+	}
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second}
+	if len(lockouts) != len(want) {
+		t.Fatalf("lockouts = %v, want %v", lockouts, want)
+	}
+	for i := range want {
+		if lockouts[i] != want[i] {
+			t.Fatalf("lockouts = %v, want %v", lockouts, want)
+		}
+	}
+	if l.retryAfter(ip, now.Add(-time.Second)) == 0 {
+		t.Fatalf("not locked out during the last lockout")
+	}
+
+	// A quiet window after the lockout resets the backoff
+	now = now.Add(2 * time.Minute)
+	l.fail(ip, now, limits)
+	l.fail(ip, now, limits)
+	if lockout := l.fail(ip, now, limits); lockout != 10*time.Second {
+		t.Fatalf("lockout after a quiet spell = %s, want 10s", lockout)
+	}
+
+	l.succeed(ip)
+	if l.retryAfter(ip, now) != 0 {
+		t.Fatalf("still locked out after a successful login")
+	}
+}
+
+func TestLoginLockoutAPI(t *testing.T) {
+	ts := newTestServer(t)
+	ts.config.Auth.LoginLimit = config.LoginLimitConfig{MaxAttempts: 2, LockoutSeconds: 60}
+
+	login := func(password string) *http.Response {
+		req, _ := http.NewRequest("POST", ts.http.URL+"/api/auth/login", bytes.NewReader([]byte(`{"password":"`+password+`"}`)))
+		req.Header.Set("X-Forwarded-For", "192.0.2.44")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	for i := 0; i < 2; i++ {
+		if resp := login("wrong"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("wrong password: status %d, want 401", resp.StatusCode)
+		}
+	}
+	resp := login("test-password")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("right password while locked out: status %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	var list struct {
+		Lockouts []LoginLockout `json:"lockouts"`
+	}
+	ts.do("GET", "/api/auth/lockouts", nil, &list)
+	if len(list.Lockouts) != 1 || list.Lockouts[0].IP != "192.0.2.44" || list.Lockouts[0].LockedUntil == nil {
+		t.Fatalf("lockouts = %+v, want 192.0.2.44 locked", list.Lockouts)
+	}
+	if code := ts.do("DELETE", "/api/auth/lockouts/192.0.2.44", nil, nil); code != http.StatusOK {
+		t.Fatalf("clear lockout: status %d", code)
+	}
+	if resp := login("test-password"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login after clearing: status %d, want 200", resp.StatusCode)
+	}
+}
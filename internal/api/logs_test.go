@@ -0,0 +1,46 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func logFrame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestCopyContainerLogs(t *testing.T) {
+	t.Parallel()
+
+	var stream bytes.Buffer
+	stream.Write(logFrame(1, "listening on :8080\n"))
+	stream.Write(logFrame(2, "warning: no cache\n"))
+	stream.Write(logFrame(1, "GET / 200\n"))
+
+	var out bytes.Buffer
+	flushes := 0
+	copyContainerLogs(&out, &stream, func() { flushes++ })
+
+	want := "listening on :8080\nwarning: no cache\nGET / 200\n"
+	if out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+	if flushes != 3 {
+		t.Fatalf("flushes = %d, want one per frame (3)", flushes)
+	}
+}
+
+func TestCopyContainerLogsUnframed(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	copyContainerLogs(&out, bytes.NewBufferString("plain tty output\nsecond line\n"), func() {})
+
+	if want := "plain tty output\nsecond line\n"; out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+}
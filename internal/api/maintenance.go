@@ -0,0 +1,457 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// Maintenance tasks, run in this order. Self-update always runs last since it
+// restarts the server.
+var maintenanceTasks = []string{"image_updates", "prune", "backup_verify", "self_update"}
+
+// Settings keys used to remember the last maintenance run across restarts
+const (
+	maintenanceLastWindowKey = "maintenance_last_window"
+	maintenanceLastReportKey = "maintenance_last_report"
+)
+
+// MaintenanceTaskResult is the outcome of one task in a maintenance run
+type MaintenanceTaskResult struct {
+	Task    string   `json:"task"`
+	Status  string   `json:"status"` // ok, failed, skipped
+	Details []string `json:"details,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// MaintenanceReport describes what a maintenance run did
+type MaintenanceReport struct {
+	Trigger    string                  `json:"trigger"` // schedule or manual
+	StartedAt  time.Time               `json:"started_at"`
+	FinishedAt time.Time               `json:"finished_at"`
+	Tasks      []MaintenanceTaskResult `json:"tasks"`
+}
+
+// maintenanceWindow is a weekly window, e.g. Sunday 03:00 for 1h
+type maintenanceWindow struct {
+	day    time.Weekday
+	start  time.Duration // Offset from midnight
+	length time.Duration
+}
+
+var maintenanceMu sync.Mutex // Serializes maintenance runs
+
+// parseMaintenanceWindow reads the window from config, applying defaults
+func parseMaintenanceWindow(cfg config.MaintenanceConfig) (maintenanceWindow, error) {
+	w := maintenanceWindow{day: time.Sunday, start: 3 * time.Hour, length: time.Hour}
+
+	if cfg.Day != "" {
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			name := strings.ToLower(d.String())
+			if strings.EqualFold(cfg.Day, name) || strings.EqualFold(cfg.Day, name[:3]) {
+				w.day = d
+				found = true
+				break
+			}
+		}
+		if !found {
+			return w, fmt.Errorf("invalid maintenance day: %s", cfg.Day)
+		}
+	}
+
+	if cfg.Start != "" {
+		t, err := time.Parse("15:04", cfg.Start)
+		if err != nil {
+			return w, fmt.Errorf("invalid maintenance start %q, expected HH:MM", cfg.Start)
+		}
+		w.start = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	if cfg.Duration != "" {
+		d, err := time.ParseDuration(cfg.Duration)
+		if err != nil || d <= 0 {
+			return w, fmt.Errorf("invalid maintenance duration: %s", cfg.Duration)
+		}
+		w.length = d
+	}
+
+	return w, nil
+}
+
+// lastStart returns the most recent time the window opened at or before now
+func (w maintenanceWindow) lastStart(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	daysBack := (int(now.Weekday()) - int(w.day) + 7) % 7
+	start := midnight.AddDate(0, 0, -daysBack).Add(w.start)
+	if start.After(now) {
+		start = start.AddDate(0, 0, -7)
+	}
+	return start
+}
+
+// open reports whether now falls inside the window, and when that window opened
+func (w maintenanceWindow) open(now time.Time) (time.Time, bool) {
+	start := w.lastStart(now)
+	return start, now.Before(start.Add(w.length))
+}
+
+// enabledMaintenanceTasks returns the configured tasks in run order
+func enabledMaintenanceTasks(cfg config.MaintenanceConfig) []string {
+	if len(cfg.Tasks) == 0 {
+		return maintenanceTasks
+	}
+	var tasks []string
+	for _, task := range maintenanceTasks {
+		for _, want := range cfg.Tasks {
+			if strings.TrimSpace(want) == task {
+				tasks = append(tasks, task)
+				break
+			}
+		}
+	}
+	return tasks
+}
+
+// runMaintenanceScheduler checks every minute whether the maintenance window is
+// open and runs the deferred tasks once per window
+func (s *Server) runMaintenanceScheduler() {
+	if s.config == nil || !s.config.Maintenance.Enabled {
+		return
+	}
+	window, err := parseMaintenanceWindow(s.config.Maintenance)
+	if err != nil {
+		log.Printf("Maintenance window disabled: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			start, open := window.open(time.Now())
+			if !open {
+				continue
+			}
+			key := start.Format(time.RFC3339)
+			if last, _ := s.storage.GetSetting(maintenanceLastWindowKey); last == key {
+				continue
+			}
+			s.storage.SetSetting(maintenanceLastWindowKey, key)
+			s.runMaintenance(context.Background(), "schedule")
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+// runMaintenance runs the enabled maintenance tasks and reports what was done
+func (s *Server) runMaintenance(ctx context.Context, trigger string) *MaintenanceReport {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+
+	tasks := enabledMaintenanceTasks(s.config.Maintenance)
+	report := &MaintenanceReport{Trigger: trigger, StartedAt: time.Now()}
+
+	log.Printf("Maintenance started (%s): %s", trigger, strings.Join(tasks, ", "))
+	s.sendNotifications("maintenance_started", "", "", map[string]string{
+		"trigger": trigger,
+		"tasks":   strings.Join(tasks, ", "),
+	})
+
+	selfUpdate := false
+	for _, task := range tasks {
+		var result MaintenanceTaskResult
+		switch task {
+		case "image_updates":
+			result = s.maintainImages(ctx)
+		case "prune":
+			result = MaintenanceTaskResult{Task: task, Status: "ok"}
+			pruned, err := s.pruneSystem(ctx, PruneRequest{})
+			if err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+				break
+			}
+			for _, line := range strings.Split(strings.TrimSpace(pruned.Summary()), "\n") {
+				if line != "" {
+					result.Details = append(result.Details, line)
+				}
+			}
+		case "backup_verify":
+			result = s.verifyLatestBackup()
+		case "self_update":
+			result = s.maintainSelfUpdate()
+			selfUpdate = result.Status == "ok"
+		}
+		report.Tasks = append(report.Tasks, result)
+	}
+	report.FinishedAt = time.Now()
+
+	status := "success"
+	summary := make([]string, 0, len(report.Tasks))
+	for _, t := range report.Tasks {
+		if t.Status == "failed" {
+			status = "failed"
+		}
+		summary = append(summary, t.Task+"="+t.Status)
+	}
+
+	reportJSON, _ := json.Marshal(report)
+	s.storage.SetSetting(maintenanceLastReportKey, string(reportJSON))
+	s.logActivity("system", "maintenance", "system", "", "maintenance", status, string(reportJSON))
+	s.sendNotifications("maintenance_completed", "", "", map[string]string{
+		"trigger":  trigger,
+		"status":   status,
+		"summary":  strings.Join(summary, ", "),
+		"duration": report.FinishedAt.Sub(report.StartedAt).Round(time.Second).String(),
+	})
+	log.Printf("Maintenance finished: %s", strings.Join(summary, ", "))
+
+	if selfUpdate {
+		// Restart by exiting - launchd/systemd KeepAlive will restart with new binary
+		go func() {
+			time.Sleep(2 * time.Second) // Let notifications go out
+			os.Exit(0)
+		}()
+	}
+
+	return report
+}
+
+// maintainImages pulls fresh images for apps that opted into auto-update and
+// redeploys the ones whose image changed
+func (s *Server) maintainImages(ctx context.Context) MaintenanceTaskResult {
+	result := MaintenanceTaskResult{Task: "image_updates", Status: "ok"}
+
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+
+	freeze := s.activeFreeze()
+	for i := range apps {
+		a := &apps[i]
+		if !a.Deployment.AutoUpdate || a.Image == "" || a.Type == app.AppTypeMLX {
+			continue
+		}
+		if freeze != nil {
+			result.Details = append(result.Details, fmt.Sprintf("%s: skipped, deploys are frozen (%s)", a.Name, freeze.Name))
+			continue
+		}
+
+		updated, err := s.redeployImage(ctx, a, false)
+		if err != nil {
+			result.Status = "failed"
+			result.Details = append(result.Details, fmt.Sprintf("%s: %v", a.Name, err))
+			continue
+		}
+		if !updated {
+			result.Details = append(result.Details, fmt.Sprintf("%s: %s is up to date", a.Name, a.Image))
+			continue
+		}
+		s.logActivity("system", "deploy", "app", a.ID, a.Name, "success", "maintenance image update")
+
+		result.Details = append(result.Details, fmt.Sprintf("%s: updated %s and redeployed", a.Name, a.Image))
+	}
+
+	if len(result.Details) == 0 {
+		result.Status = "skipped"
+		result.Details = []string{"no apps have auto-update enabled"}
+	}
+	return result
+}
+
+// redeployImage pulls the app's image and recreates its container, recording a
+// deployment. Unless force is set, the container is only recreated when the pull
+// brought in a new image; with force, a failed pull of an image that exists
+// locally (e.g. one built from git) is not an error.
+func (s *Server) redeployImage(ctx context.Context, a *app.App, force bool) (bool, error) {
+	before := s.imageID(ctx, a.Image)
+	if err := s.podman.PullImage(ctx, a.Image); err != nil && (!force || before == "") {
+		return false, fmt.Errorf("pull failed: %w", err)
+	}
+	if !force {
+		if after := s.imageID(ctx, a.Image); after == "" || after == before {
+			return false, nil
+		}
+	}
+
+	snapshotID, err := s.preDeploySnapshot(ctx, a)
+	if err != nil {
+		return false, fmt.Errorf("pre-deploy snapshot failed: %w", err)
+	}
+	if err := s.recreateAppContainer(ctx, a); err != nil {
+		return false, fmt.Errorf("redeploy failed: %w", err)
+	}
+	if err := s.waitForAppReadiness(ctx, a); err != nil {
+		a.Status = app.StatusFailed
+		a.UpdatedAt = time.Now()
+		s.storage.UpdateApp(a)
+		return false, fmt.Errorf("not ready after redeploy: %w", err)
+	}
+
+	rec := app.DeploymentRecord{
+		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
+		Image:      a.Image,
+		Status:     "success",
+		SnapshotID: snapshotID,
+		DeployedAt: time.Now(),
+	}
+	a.Deployments = append([]app.DeploymentRecord{rec}, a.Deployments...)
+	if len(a.Deployments) > 10 {
+		a.Deployments = a.Deployments[:10]
+	}
+	a.Status = app.StatusRunning
+	a.UpdatedAt = time.Now()
+	s.storage.UpdateApp(a)
+	s.recordDeployMarker(a, rec, "deploy")
+	return true, nil
+}
+
+// imageID returns the local image ID for a reference ("" if not present)
+func (s *Server) imageID(ctx context.Context, ref string) string {
+	images, err := s.podman.ListImages(ctx)
+	if err != nil {
+		return ""
+	}
+	return findImageID(images, ref)
+}
+
+// findImageID matches ref against image tags, allowing for the registry and
+// library prefixes podman adds (nginx:latest -> docker.io/library/nginx:latest)
+func findImageID(images []podman.Image, ref string) string {
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") && !strings.Contains(ref, "@") {
+		ref += ":latest"
+	}
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			if tag == ref || strings.HasSuffix(tag, "/"+ref) {
+				return img.ID
+			}
+		}
+	}
+	return ""
+}
+
+// verifyLatestBackup checks that the newest backup archive is readable
+func (s *Server) verifyLatestBackup() MaintenanceTaskResult {
+	result := MaintenanceTaskResult{Task: "backup_verify", Status: "ok"}
+	if s.backup == nil {
+		result.Status = "skipped"
+		return result
+	}
+
+	backups, err := s.backup.List()
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	if len(backups) == 0 {
+		result.Status = "skipped"
+		result.Details = []string{"no backups found"}
+		return result
+	}
+
+	latest := backups[0]
+	if err := s.backup.Verify(latest.ID); err != nil {
+		result.Status = "failed"
+		result.Error = fmt.Sprintf("backup %s: %v", latest.ID, err)
+		return result
+	}
+	result.Details = []string{fmt.Sprintf("backup %s (%s) is readable", latest.ID, latest.CreatedAt.Format(time.RFC3339))}
+	if age := time.Since(latest.CreatedAt); age > 8*24*time.Hour {
+		result.Details = append(result.Details, fmt.Sprintf("latest backup is %d days old", int(age.Hours()/24)))
+	}
+	return result
+}
+
+// maintainSelfUpdate installs a newer basepod release if one is available
+func (s *Server) maintainSelfUpdate() MaintenanceTaskResult {
+	result := MaintenanceTaskResult{Task: "self_update", Status: "skipped"}
+
+	latest := latestReleaseVersion()
+	if latest == "" {
+		result.Details = []string{"could not check for updates"}
+		return result
+	}
+	if compareVersions(latest, s.version) <= 0 {
+		result.Details = []string{fmt.Sprintf("already on latest version %s", s.version)}
+		return result
+	}
+
+	if err := installLatestRelease(); err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = "ok"
+	result.Details = []string{fmt.Sprintf("updated %s -> %s, restarting", s.version, latest)}
+	return result
+}
+
+// handleGetMaintenance returns the maintenance window and the last report
+func (s *Server) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	cfg := config.MaintenanceConfig{}
+	if s.config != nil {
+		cfg = s.config.Maintenance
+	}
+
+	resp := map[string]interface{}{
+		"enabled": cfg.Enabled,
+		"tasks":   enabledMaintenanceTasks(cfg),
+	}
+	window, err := parseMaintenanceWindow(cfg)
+	if err != nil {
+		resp["error"] = err.Error()
+	} else {
+		resp["day"] = window.day.String()
+		resp["start"] = fmt.Sprintf("%02d:%02d", int(window.start.Hours()), int(window.start.Minutes())%60)
+		resp["duration"] = window.length.String()
+		if cfg.Enabled {
+			now := time.Now()
+			if start, open := window.open(now); open {
+				resp["open"] = true
+				resp["next_window"] = start
+			} else {
+				resp["next_window"] = start.AddDate(0, 0, 7)
+			}
+		}
+	}
+
+	if last, _ := s.storage.GetSetting(maintenanceLastReportKey); last != "" {
+		var report MaintenanceReport
+		if json.Unmarshal([]byte(last), &report) == nil {
+			resp["last_report"] = report
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// handleRunMaintenance runs the maintenance tasks now, outside the window
+func (s *Server) handleRunMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !maintenanceMu.TryLock() {
+		errorResponse(w, http.StatusConflict, "Maintenance is already running")
+		return
+	}
+	maintenanceMu.Unlock()
+
+	report := s.runMaintenance(context.Background(), "manual")
+	jsonResponse(w, http.StatusOK, report)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+func TestMaintenanceWindowOpen(t *testing.T) {
+	t.Parallel()
+
+	w, err := parseMaintenanceWindow(config.MaintenanceConfig{Day: "sun", Start: "23:30", Duration: "1h"})
+	if err != nil {
+		t.Fatalf("parseMaintenanceWindow: %v", err)
+	}
+
+	// 2024-06-02 is a Sunday
+	cases := []struct {
+		now  time.Time
+		open bool
+	}{
+		{time.Date(2024, 6, 2, 23, 29, 0, 0, time.UTC), false},
+		{time.Date(2024, 6, 2, 23, 30, 0, 0, time.UTC), true},
+		{time.Date(2024, 6, 3, 0, 15, 0, 0, time.UTC), true}, // Wraps past midnight
+		{time.Date(2024, 6, 3, 0, 30, 0, 0, time.UTC), false},
+		{time.Date(2024, 6, 5, 23, 45, 0, 0, time.UTC), false},
+	}
+	for _, c := range cases {
+		start, open := w.open(c.now)
+		if open != c.open {
+			t.Fatalf("open(%s) = %t, want %t (window start %s)", c.now, open, c.open, start)
+		}
+	}
+}
+
+func TestParseMaintenanceWindowInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range []config.MaintenanceConfig{
+		{Day: "someday"},
+		{Start: "3am"},
+		{Duration: "-1h"},
+	} {
+		if _, err := parseMaintenanceWindow(cfg); err == nil {
+			t.Fatalf("parseMaintenanceWindow(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestEnabledMaintenanceTasksKeepsRunOrder(t *testing.T) {
+	t.Parallel()
+
+	got := enabledMaintenanceTasks(config.MaintenanceConfig{Tasks: []string{"self_update", "prune"}})
+	if len(got) != 2 || got[0] != "prune" || got[1] != "self_update" {
+		t.Fatalf("enabledMaintenanceTasks = %v, want [prune self_update]", got)
+	}
+}
+
+func TestFindImageID(t *testing.T) {
+	t.Parallel()
+
+	images := []podman.Image{
+		{ID: "a", RepoTags: []string{"docker.io/library/nginx:latest"}},
+		{ID: "b", RepoTags: []string{"ghcr.io/acme/api:v2"}},
+	}
+	if got := findImageID(images, "nginx"); got != "a" {
+		t.Fatalf("findImageID(nginx) = %q, want a", got)
+	}
+	if got := findImageID(images, "ghcr.io/acme/api:v2"); got != "b" {
+		t.Fatalf("findImageID(api:v2) = %q, want b", got)
+	}
+	if got := findImageID(images, "redis:7"); got != "" {
+		t.Fatalf("findImageID(redis:7) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/google/uuid"
+)
+
+// recordDeployMarker stores a marker for a release that just went live
+func (s *Server) recordDeployMarker(a *app.App, rec app.DeploymentRecord, kind string) {
+	s.storage.SaveDeployMarker(&app.DeployMarker{
+		ID:         uuid.New().String(),
+		AppID:      a.ID,
+		AppName:    a.Name,
+		ReleaseID:  rec.ID,
+		Kind:       kind,
+		Image:      rec.Image,
+		CommitHash: rec.CommitHash,
+		CommitMsg:  rec.CommitMsg,
+		CreatedAt:  rec.DeployedAt,
+	})
+}
+
+// deployMarkerLine renders the current release as a log line, e.g.
+// "==> basepod: deploy 1712345678 (abc1234) at 2024-04-05T12:00:00Z"
+func deployMarkerLine(a *app.App) string {
+	if len(a.Deployments) == 0 {
+		return ""
+	}
+	rec := a.Deployments[0]
+	version := rec.CommitHash
+	if version == "" {
+		version = rec.Image
+	}
+	kind := "deploy"
+	if strings.HasPrefix(rec.CommitMsg, "Rollback to ") {
+		kind = "rollback"
+	}
+	if version == "" {
+		return fmt.Sprintf("==> basepod: %s %s at %s\n", kind, rec.ID, rec.DeployedAt.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("==> basepod: %s %s (%s) at %s\n", kind, rec.ID, version, rec.DeployedAt.UTC().Format(time.RFC3339))
+}
+
+// handleDeployMarkers lists deploy markers for an app (?since=RFC3339, default 7 days)
+func (s *Server) handleDeployMarkers(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil || a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	if v := r.URL.Query().Get("since"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			since = t
+		}
+	}
+
+	markers, err := s.storage.ListDeployMarkers(a.ID, since, time.Time{})
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if markers == nil {
+		markers = []app.DeployMarker{}
+	}
+	jsonResponse(w, http.StatusOK, markers)
+}
+
+// handleAnnotations serves deploy markers as Grafana annotations so they can be
+// overlaid on Prometheus graphs. Accepts ?from=&to= in epoch milliseconds and ?app=.
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	var until time.Time
+	if ms, err := strconv.ParseInt(q.Get("from"), 10, 64); err == nil {
+		since = time.UnixMilli(ms)
+	}
+	if ms, err := strconv.ParseInt(q.Get("to"), 10, 64); err == nil {
+		until = time.UnixMilli(ms)
+	}
+
+	appID := ""
+	if name := q.Get("app"); name != "" {
+		a, err := s.resolveApp(name)
+		if err != nil || a == nil {
+			errorResponse(w, http.StatusNotFound, "App not found")
+			return
+		}
+		appID = a.ID
+	}
+
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if appID != "" && !sc.allows(appID) {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	markers, err := s.storage.ListDeployMarkers(appID, since, until)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	type annotation struct {
+		Time  int64    `json:"time"`
+		Title string   `json:"title"`
+		Text  string   `json:"text"`
+		Tags  []string `json:"tags"`
+	}
+	result := []annotation{}
+	for _, m := range markers {
+		if !sc.allows(m.AppID) {
+			continue
+		}
+		text := m.CommitMsg
+		if m.CommitHash != "" {
+			text = m.CommitHash + " " + text
+		} else if m.Image != "" {
+			text = m.Image
+		}
+		result = append(result, annotation{
+			Time:  m.CreatedAt.UnixMilli(),
+			Title: fmt.Sprintf("%s %s", m.AppName, m.Kind),
+			Text:  text,
+			Tags:  []string{"basepod", m.Kind, "app:" + m.AppName},
+		})
+	}
+	jsonResponse(w, http.StatusOK, result)
+}
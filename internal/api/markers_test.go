@@ -0,0 +1,30 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestDeployMarkerLineDescribesCurrentRelease(t *testing.T) {
+	t.Parallel()
+
+	deployedAt := time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+	a := &app.App{Deployments: []app.DeploymentRecord{
+		{ID: "42", CommitHash: "abc1234", DeployedAt: deployedAt},
+		{ID: "41", CommitHash: "0000000"},
+	}}
+	if got, want := deployMarkerLine(a), "==> basepod: deploy 42 (abc1234) at 2024-04-05T12:00:00Z\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	a.Deployments[0].CommitMsg = "Rollback to 41"
+	if got, want := deployMarkerLine(a), "==> basepod: rollback 42 (abc1234) at 2024-04-05T12:00:00Z\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	if got := deployMarkerLine(&app.App{}); got != "" {
+		t.Fatalf("expected no marker for an app that was never deployed, got %q", got)
+	}
+}
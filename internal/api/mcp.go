@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/base-go/basepod/internal/ai"
+	"github.com/base-go/basepod/internal/backup"
+)
+
+// mcpProtocolVersion is the MCP revision this server speaks.
+const mcpProtocolVersion = "2025-03-26"
+
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations map[string]any `json:"annotations,omitempty"`
+}
+
+// mcpSession describes what the authenticated MCP caller may do.
+type mcpSession struct {
+	caller   *ai.Caller
+	readOnly bool
+	admin    bool
+}
+
+// mcpBackupTools are served by the API layer rather than the assistant.
+var mcpBackupTools = []ai.AssistantFunc{
+	{Name: "list_backups", Description: "List server backups", Parameters: nil},
+	{Name: "create_backup", Description: "Create a new server backup", Parameters: map[string]ai.ParamDef{
+		"include_volumes": {Type: "boolean", Description: "include container volumes, default true"},
+	}},
+}
+
+// mcpSessionFor derives the caller and permissions for an MCP request.
+// Read-only mode applies when configured server-wide, when the client asks
+// for it, for viewers, and for tokens that only carry the mcp:read scope.
+// Callers limited to some apps don't get server-wide tools.
+func (s *Server) mcpSessionFor(r *http.Request) (*mcpSession, error) {
+	sess := &mcpSession{
+		readOnly: s.config.MCP.ReadOnly || r.Header.Get("X-MCP-Read-Only") == "1",
+	}
+	caller, err := s.aiCaller(r)
+	if err != nil {
+		return nil, err
+	}
+	sess.caller = caller
+
+	if dt := getDeployTokenFromCtx(r); dt != nil {
+		sess.admin = caller == nil
+		if !deployTokenHasScope(dt, "mcp:write") {
+			sess.readOnly = true
+		}
+		return sess, nil
+	}
+
+	if session := s.auth.GetSession(s.getSessionToken(r)); session != nil {
+		sess.admin = session.UserRole == "admin" || session.UserRole == ""
+		if session.UserRole == "viewer" {
+			sess.readOnly = true
+		}
+	}
+	return sess, nil
+}
+
+func (sess *mcpSession) allows(name string) bool {
+	isBackupTool := false
+	for _, fn := range mcpBackupTools {
+		if fn.Name == name {
+			isBackupTool = true
+		}
+	}
+	if isBackupTool && !sess.admin {
+		return false
+	}
+	if sess.caller != nil && sess.caller.Apps != nil && ai.IsServerFunction(name) {
+		return false
+	}
+	if sess.readOnly && (ai.IsWriteFunction(name) || name == "create_backup") {
+		return false
+	}
+	return true
+}
+
+func (sess *mcpSession) tools() []mcpTool {
+	var tools []mcpTool
+	for _, fn := range append(append([]ai.AssistantFunc{}, ai.Functions()...), mcpBackupTools...) {
+		if !sess.allows(fn.Name) {
+			continue
+		}
+		props := map[string]any{}
+		required := []string{}
+		for name, p := range fn.Parameters {
+			props[name] = map[string]any{"type": p.Type, "description": p.Description}
+			if name == "name" {
+				required = append(required, name)
+			}
+		}
+		readOnly := !ai.IsWriteFunction(fn.Name) && fn.Name != "create_backup"
+		tools = append(tools, mcpTool{
+			Name:        fn.Name,
+			Description: fn.Description,
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": props,
+				"required":   required,
+			},
+			Annotations: map[string]any{"readOnlyHint": readOnly},
+		})
+	}
+	return tools
+}
+
+// handleMCP implements the MCP streamable HTTP transport (JSON responses only).
+// `bp mcp` bridges stdio clients to this endpoint.
+func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
+	if s.config.MCP.Disabled {
+		errorResponse(w, http.StatusNotFound, "MCP server is disabled")
+		return
+	}
+
+	var req mcpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonResponse(w, http.StatusOK, mcpResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &mcpError{Code: -32700, Message: "Parse error"}})
+		return
+	}
+
+	// Notifications carry no ID and expect no response body
+	if len(req.ID) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	resp := mcpResponse{JSONRPC: "2.0", ID: req.ID}
+	sess, err := s.mcpSessionFor(r)
+	if err != nil {
+		resp.Error = &mcpError{Code: -32603, Message: "Failed to check app access"}
+		jsonResponse(w, http.StatusOK, resp)
+		return
+	}
+
+	switch req.Method {
+	case "initialize":
+		resp.Result = map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "basepod", "version": s.version},
+			"instructions":    "Manage apps, logs and backups on this basepod server.",
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = map[string]any{"tools": sess.tools()}
+	case "tools/call":
+		var params struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			resp.Error = &mcpError{Code: -32602, Message: "Invalid params"}
+			break
+		}
+		text, err := s.callMCPTool(r, sess, params.Name, params.Arguments)
+		if err != nil {
+			resp.Result = map[string]any{
+				"content": []map[string]any{{"type": "text", "text": err.Error()}},
+				"isError": true,
+			}
+			break
+		}
+		s.logActivity("mcp", "mcp_tool", "system", "", params.Name, "success", "")
+		resp.Result = map[string]any{
+			"content": []map[string]any{{"type": "text", "text": text}},
+		}
+	default:
+		resp.Error = &mcpError{Code: -32601, Message: "Method not found: " + req.Method}
+	}
+
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+func (s *Server) callMCPTool(r *http.Request, sess *mcpSession, name string, args map[string]any) (string, error) {
+	if !sess.allows(name) {
+		return "", fmt.Errorf("tool %s is not available in this session", name)
+	}
+
+	switch name {
+	case "list_backups":
+		backups, err := s.backup.List()
+		if err != nil {
+			return "", err
+		}
+		if len(backups) == 0 {
+			return "No backups found.", nil
+		}
+		var sb strings.Builder
+		for _, b := range backups {
+			fmt.Fprintf(&sb, "%s  %s  %s\n", b.ID, b.CreatedAt.Format("2006-01-02 15:04"), backup.FormatSize(b.Size))
+		}
+		return sb.String(), nil
+	case "create_backup":
+		opts := backup.DefaultOptions()
+		if v, ok := args["include_volumes"].(bool); ok {
+			opts.IncludeVolumes = v
+		}
+		b, err := s.backup.Create(r.Context(), opts)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Backup %s created (%s).", b.ID, backup.FormatSize(b.Size)), nil
+	}
+
+	return s.assistant.Execute(name, args, sess.caller)
+}
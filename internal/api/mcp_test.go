@@ -0,0 +1,150 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// mcpResult is the result of an MCP tools/list or tools/call request
+type mcpResult struct {
+	Tools   []mcpTool `json:"tools"`
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	IsError bool `json:"isError"`
+}
+
+// mcp sends an MCP request with token and returns its result
+func (ts *testServer) mcp(token, method string, params map[string]interface{}) mcpResult {
+	ts.t.Helper()
+	var resp struct {
+		Result mcpResult `json:"result"`
+		Error  *mcpError `json:"error"`
+	}
+	body := map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params}
+	if code := ts.doAs(token, "POST", "/api/mcp", body, &resp); code != http.StatusOK || resp.Error != nil {
+		ts.t.Fatalf("MCP %s: status %d, error %+v", method, code, resp.Error)
+	}
+	return resp.Result
+}
+
+// mcpToolNames lists the tools an MCP session offers
+func (ts *testServer) mcpToolNames(token string) map[string]bool {
+	ts.t.Helper()
+	names := map[string]bool{}
+	for _, tool := range ts.mcp(token, "tools/list", nil).Tools {
+		names[tool.Name] = true
+	}
+	return names
+}
+
+// mcpCall calls an MCP tool and returns its text and whether it failed
+func (ts *testServer) mcpCall(token, tool string, args map[string]interface{}) (string, bool) {
+	ts.t.Helper()
+	res := ts.mcp(token, "tools/call", map[string]interface{}{"name": tool, "arguments": args})
+	var text []string
+	for _, c := range res.Content {
+		text = append(text, c.Text)
+	}
+	return strings.Join(text, "\n"), res.IsError
+}
+
+// mcpToken creates an API token with scopes as the session of token
+func (ts *testServer) scopedToken(token string, scopes ...string) string {
+	ts.t.Helper()
+	var created struct {
+		Token string `json:"token"`
+	}
+	if code := ts.doAs(token, "POST", "/api/deploy-tokens", map[string]interface{}{"name": "agent", "scopes": scopes}, &created); code != http.StatusCreated {
+		ts.t.Fatalf("create token with %v: status %d", scopes, code)
+	}
+	return created.Token
+}
+
+func TestMCPReadOnlySessions(t *testing.T) {
+	ts := newTestServer(t)
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "mcp-web", Domain: "mcp-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+
+	writer := ts.scopedToken(ts.token, "mcp:write")
+	if tools := ts.mcpToolNames(writer); !tools["restart_app"] || !tools["create_backup"] {
+		t.Fatalf("mcp:write tools = %v, want restart_app and create_backup", tools)
+	}
+
+	reader := ts.scopedToken(ts.token, "mcp:read")
+	tools := ts.mcpToolNames(reader)
+	for _, name := range []string{"start_app", "stop_app", "restart_app", "deploy_app", "create_app", "delete_app", "prune_images", "create_backup"} {
+		if tools[name] {
+			t.Fatalf("mcp:read session offers %s", name)
+		}
+	}
+	if !tools["list_apps"] || !tools["get_app"] {
+		t.Fatalf("mcp:read tools = %v, want list_apps and get_app", tools)
+	}
+	if text, failed := ts.mcpCall(reader, "list_apps", nil); failed || !strings.Contains(text, "mcp-web") {
+		t.Fatalf("mcp:read list_apps = %q (failed %v), want mcp-web", text, failed)
+	}
+	for _, tool := range []string{"stop_app", "delete_app", "create_backup"} {
+		if text, failed := ts.mcpCall(reader, tool, map[string]interface{}{"name": "mcp-web"}); !failed {
+			t.Fatalf("mcp:read calling %s = %q, want an error", tool, text)
+		}
+	}
+	if got := ts.waitForStatus(a.ID, app.StatusRunning); got == nil {
+		t.Fatalf("app gone after read-only calls")
+	}
+
+	// So does an admin session on a server configured read-only
+	ts.config.MCP.ReadOnly = true
+	if tools := ts.mcpToolNames(ts.token); tools["stop_app"] || !tools["list_apps"] {
+		t.Fatalf("read-only server tools = %v, want list_apps without stop_app", tools)
+	}
+	if text, failed := ts.mcpCall(ts.token, "stop_app", map[string]interface{}{"name": "mcp-web"}); !failed {
+		t.Fatalf("read-only server stop_app = %q, want an error", text)
+	}
+}
+
+func TestMCPScopedSessions(t *testing.T) {
+	ts := newTestServer(t)
+	var granted, other app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "mcp-granted", Domain: "mcp-granted.test"}, &granted)
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "mcp-other", Domain: "mcp-other.test"}, &other)
+	ts.waitForStatus(granted.ID, app.StatusRunning)
+	ts.waitForStatus(other.ID, app.StatusRunning)
+
+	body := map[string]interface{}{"email": "agent@example.com", "password": "deployer-pass", "role": "deployer", "app_ids": []string{"mcp-granted"}}
+	if code := ts.do("POST", "/api/users", body, nil); code != http.StatusCreated {
+		t.Fatalf("create user: status %d", code)
+	}
+	dev := ts.login("agent@example.com", "deployer-pass")
+	token := ts.scopedToken(dev, "mcp:write", "deploy:mcp-granted")
+
+	for name, caller := range map[string]string{"session": dev, "token": token} {
+		tools := ts.mcpToolNames(caller)
+		for _, tool := range []string{"create_app", "storage_info", "system_info", "prune_images", "list_backups", "create_backup"} {
+			if tools[tool] {
+				t.Fatalf("scoped %s offers server-wide tool %s", name, tool)
+			}
+		}
+
+		text, failed := ts.mcpCall(caller, "list_apps", nil)
+		if failed || !strings.Contains(text, "mcp-granted") || strings.Contains(text, "mcp-other") {
+			t.Fatalf("scoped %s list_apps = %q (failed %v), want only mcp-granted", name, text, failed)
+		}
+		// Other apps look like they don't exist
+		for _, tool := range []string{"get_app", "stop_app"} {
+			if text, failed := ts.mcpCall(caller, tool, map[string]interface{}{"name": "mcp-other"}); !failed && !strings.Contains(text, "not found") {
+				t.Fatalf("scoped %s %s mcp-other = %q, want not found", name, tool, text)
+			}
+		}
+		if text, failed := ts.mcpCall(caller, "system_info", nil); !failed {
+			t.Fatalf("scoped %s system_info = %q, want an error", name, text)
+		}
+	}
+	if got, _ := ts.storage.GetApp(other.ID); got == nil || got.Status != app.StatusRunning {
+		t.Fatalf("ungranted app = %+v, want it still running", got)
+	}
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// defaultMetricsInterval is how often running containers' stats are sampled
+// unless metrics.interval says otherwise
+const defaultMetricsInterval = 30 * time.Second
+
+// defaultMetricsRetention is how long metric points are kept unless
+// metrics.retention_days says otherwise
+const defaultMetricsRetention = 7 * 24 * time.Hour
+
+// liveStatsConcurrency bounds the Podman stats calls made at once for
+// GET /api/metrics/live
+const liveStatsConcurrency = 8
+
+// defaultSeriesPoints is how many points a series is downsampled to unless
+// ?points= asks otherwise
+const defaultSeriesPoints = 60
+
+// maxSeriesPoints bounds ?points=
+const maxSeriesPoints = 500
+
+// hostRequests counts the requests for one host
+type hostRequests struct {
+	Requests int64
+	Errors   int64 // 5xx responses
+}
+
+// requestCounter counts the requests in Caddy's access log per host, reading
+// only what was appended since the previous count
+type requestCounter struct {
+	path   string
+	offset int64 // -1 until the first count, which starts at the end of the file
+}
+
+func newRequestCounter(path string) *requestCounter {
+	return &requestCounter{path: path, offset: -1}
+}
+
+// count returns the requests per host logged since the previous count. A
+// rolled log starts over from the beginning of the new file.
+func (c *requestCounter) count() map[string]hostRequests {
+	counts := map[string]hostRequests{}
+	file, err := os.Open(c.path)
+	if err != nil {
+		return counts
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return counts
+	}
+	if c.offset < 0 {
+		c.offset = stat.Size()
+		return counts
+	}
+	if stat.Size() < c.offset {
+		c.offset = 0
+	}
+	if _, err := file.Seek(c.offset, io.SeekStart); err != nil {
+		return counts
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A partial line is counted once Caddy finishes writing it
+			break
+		}
+		c.offset += int64(len(line))
+
+		var entry struct {
+			Logger  string `json:"logger"`
+			Status  int    `json:"status"`
+			Request struct {
+				Host string `json:"host"`
+			} `json:"request"`
+		}
+		if json.Unmarshal(line, &entry) != nil || !strings.Contains(entry.Logger, "http.log.access") {
+			continue
+		}
+		host := entry.Request.Host
+		if h, _, ok := strings.Cut(host, ":"); ok {
+			host = h
+		}
+		hr := counts[host]
+		hr.Requests++
+		if entry.Status >= 500 {
+			hr.Errors++
+		}
+		counts[host] = hr
+	}
+	return counts
+}
+
+// appRequests adds up the requests for an app's domains
+func appRequests(a *app.App, counts map[string]hostRequests) hostRequests {
+	var total hostRequests
+	for _, domain := range appDomains(a) {
+		total.Requests += counts[domain].Requests
+		total.Errors += counts[domain].Errors
+	}
+	return total
+}
+
+// MetricPoint is a bucket of a downsampled metric series: averages and peaks
+// of the resource samples in it and the sum of its requests
+type MetricPoint struct {
+	Time       time.Time `json:"time"` // Start of the bucket
+	CPUPercent float64   `json:"cpu_percent"`
+	CPUMax     float64   `json:"cpu_max"`
+	MemUsage   int64     `json:"mem_usage"`
+	MemMax     int64     `json:"mem_max"`
+	MemLimit   int64     `json:"mem_limit"`
+	Requests   int64     `json:"requests"`
+	Errors     int64     `json:"errors"`
+	Samples    int       `json:"samples"`
+}
+
+// downsampleMetrics groups metrics between since and until into points
+// buckets of equal length. Buckets without samples are left out, so a
+// stopped app's series has gaps rather than zeros.
+func downsampleMetrics(metrics []app.AppMetric, since, until time.Time, points int) []MetricPoint {
+	series := []MetricPoint{}
+	if points <= 0 || !until.After(since) {
+		return series
+	}
+	width := until.Sub(since) / time.Duration(points)
+	if width <= 0 {
+		width = 1
+	}
+
+	var cur *MetricPoint
+	var cpuSum float64
+	var memSum int64
+	flush := func() {
+		if cur != nil {
+			cur.CPUPercent = cpuSum / float64(cur.Samples)
+			cur.MemUsage = memSum / int64(cur.Samples)
+			series = append(series, *cur)
+		}
+	}
+	for _, m := range metrics {
+		if m.RecordedAt.Before(since) || !m.RecordedAt.Before(until) {
+			continue
+		}
+		start := since.Add(m.RecordedAt.Sub(since) / width * width)
+		if cur == nil || !cur.Time.Equal(start) {
+			flush()
+			cur = &MetricPoint{Time: start}
+			cpuSum, memSum = 0, 0
+		}
+		cur.Samples++
+		cpuSum += m.CPUPercent
+		memSum += m.MemUsage
+		cur.CPUMax = max(cur.CPUMax, m.CPUPercent)
+		cur.MemMax = max(cur.MemMax, m.MemUsage)
+		cur.MemLimit = max(cur.MemLimit, m.MemLimit)
+		cur.Requests += m.Requests
+		cur.Errors += m.Errors
+	}
+	flush()
+	return series
+}
+
+// seriesWindow reads ?period= (1h, 24h or 7d; default 24h) and ?points=
+func seriesWindow(r *http.Request) (period string, since, until time.Time, points int) {
+	until = time.Now()
+	period = r.URL.Query().Get("period")
+	switch period {
+	case "1h":
+		since = until.Add(-time.Hour)
+	case "7d":
+		since = until.Add(-7 * 24 * time.Hour)
+	default:
+		period = "24h"
+		since = until.Add(-24 * time.Hour)
+	}
+	points = defaultSeriesPoints
+	if n, err := strconv.Atoi(r.URL.Query().Get("points")); err == nil && n > 0 {
+		points = min(n, maxSeriesPoints)
+	}
+	return period, since, until, points
+}
+
+// handleAppMetricSeries returns an app's CPU, memory and request series
+// downsampled for graphing
+func (s *Server) handleAppMetricSeries(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	period, since, until, points := seriesWindow(r)
+	metrics, err := s.storage.ListAppMetrics(a.ID, since, -1)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to fetch metrics")
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"app_id": a.ID,
+		"period": period,
+		"points": points,
+		"series": downsampleMetrics(metrics, since, until, points),
+	})
+}
+
+// handleMetricSeries returns the downsampled series of every app the caller
+// can see, keyed by app ID, for sparklines on the app list
+func (s *Server) handleMetricSeries(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	period, since, until, points := seriesWindow(r)
+	metrics, err := s.storage.ListMetricsSince(since)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to fetch metrics")
+		return
+	}
+	byApp := map[string][]app.AppMetric{}
+	for _, m := range metrics {
+		byApp[m.AppID] = append(byApp[m.AppID], m)
+	}
+
+	series := map[string][]MetricPoint{}
+	for _, a := range sc.filterApps(apps) {
+		series[a.ID] = downsampleMetrics(byApp[a.ID], since, until, points)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"period": period,
+		"points": points,
+		"apps":   series,
+	})
+}
+
+// metricsInterval returns metrics.interval or the default
+func (s *Server) metricsInterval() time.Duration {
+	if s.config != nil && s.config.Metrics.Interval > 0 {
+		return time.Duration(s.config.Metrics.Interval) * time.Second
+	}
+	return defaultMetricsInterval
+}
+
+// metricsRetention returns metrics.retention_days or the default
+func (s *Server) metricsRetention() time.Duration {
+	if s.config != nil && s.config.Metrics.RetentionDays > 0 {
+		return time.Duration(s.config.Metrics.RetentionDays) * 24 * time.Hour
+	}
+	return defaultMetricsRetention
+}
+
+// LiveStats is an app's current resource use, for bp top
+type LiveStats struct {
+	AppID  string        `json:"app_id"`
+	Name   string        `json:"name"`
+	Status app.AppStatus `json:"status"`
+	*podman.ContainerStatsResult
+}
+
+// handleLiveMetrics samples the current stats of every running app the
+// caller can see
+func (s *Server) handleLiveMetrics(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	stats := s.liveStats(ctx, sc.filterApps(apps))
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"apps":     stats,
+		"interval": int(s.metricsInterval().Seconds()),
+	})
+}
+
+// liveStats samples the current stats of the running apps among apps. Apps
+// whose stats can't be read have none.
+func (s *Server) liveStats(ctx context.Context, apps []app.App) []LiveStats {
+	var running []app.App
+	for _, a := range apps {
+		if a.ContainerID != "" && a.Status == app.StatusRunning {
+			running = append(running, a)
+		}
+	}
+	stats := make([]LiveStats, len(running))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, liveStatsConcurrency)
+	for i, a := range running {
+		stats[i] = LiveStats{AppID: a.ID, Name: a.Name, Status: a.Status}
+		if s.podman == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, containerID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			stats[i].ContainerStatsResult, _ = s.podman.ContainerStats(ctx, containerID)
+		}(i, a.ContainerID)
+	}
+	wg.Wait()
+	return stats
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestDownsampleMetrics(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(time.Hour)
+	metrics := []app.AppMetric{
+		{CPUPercent: 10, MemUsage: 100, Requests: 5, RecordedAt: since.Add(1 * time.Minute)},
+		{CPUPercent: 30, MemUsage: 300, Requests: 7, Errors: 1, RecordedAt: since.Add(5 * time.Minute)},
+		// Nothing in the second bucket: the app was stopped
+		{CPUPercent: 50, MemUsage: 500, RecordedAt: since.Add(50 * time.Minute)},
+		{CPUPercent: 99, RecordedAt: until.Add(time.Minute)}, // Outside the window
+	}
+
+	series := downsampleMetrics(metrics, since, until, 4)
+	if len(series) != 2 {
+		t.Fatalf("len(series) = %d, want 2: %+v", len(series), series)
+	}
+	first := series[0]
+	if !first.Time.Equal(since) || first.Samples != 2 || first.CPUPercent != 20 || first.CPUMax != 30 || first.MemUsage != 200 || first.Requests != 12 || first.Errors != 1 {
+		t.Fatalf("first point = %+v", first)
+	}
+	if last := series[1]; !last.Time.Equal(since.Add(45*time.Minute)) || last.CPUPercent != 50 {
+		t.Fatalf("last point = %+v", last)
+	}
+}
+
+func TestRequestCounter(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	write := func(lines string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(lines)
+		f.Close()
+	}
+	write(`{"logger":"http.log.access","status":200,"request":{"host":"shop.example.com"}}` + "\n")
+
+	c := newRequestCounter(path)
+	if counts := c.count(); len(counts) != 0 {
+		t.Fatalf("first count = %v, want nothing: it starts at the end of the log", counts)
+	}
+
+	write(`{"logger":"http.log.access","status":200,"request":{"host":"shop.example.com"}}` + "\n" +
+		`{"logger":"http.log.access","status":502,"request":{"host":"shop.example.com:443"}}` + "\n" +
+		`{"logger":"tls","msg":"certificate obtained"}` + "\n" +
+		`{"logger":"http.log.access","status":200,"request":{"host":"blog.example.com"}}` + "\n" +
+		`{"logger":"http.log.access","status":200,"req`)
+	counts := c.count()
+	if got := counts["shop.example.com"]; got.Requests != 2 || got.Errors != 1 {
+		t.Fatalf("shop = %+v, want 2 requests and 1 error", got)
+	}
+	if got := appRequests(&app.App{Domain: "blog.example.com", Aliases: []string{"shop.example.com"}}, counts); got.Requests != 3 {
+		t.Fatalf("app requests = %+v, want 3", got)
+	}
+
+	// The partial line is counted once it's complete
+	write(`uest":{"host":"blog.example.com"}}` + "\n")
+	if got := c.count()["blog.example.com"]; got.Requests != 1 {
+		t.Fatalf("completed line = %+v, want 1 request", got)
+	}
+}
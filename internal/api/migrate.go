@@ -0,0 +1,177 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/backup"
+)
+
+// Image and volume archives let `bp migrate` move locally built images and
+// volume data between servers without a shared registry.
+
+// migratePodmanPath finds the podman binary (launchd has a minimal PATH)
+func migratePodmanPath() string {
+	podmanPath := "podman"
+	if _, err := exec.LookPath("podman"); err != nil {
+		for _, p := range []string{"/opt/homebrew/bin/podman", "/usr/local/bin/podman", "/usr/bin/podman"} {
+			if _, err := os.Stat(p); err == nil {
+				podmanPath = p
+				break
+			}
+		}
+	}
+	return podmanPath
+}
+
+// serveTempFile streams a finished temporary archive and removes it
+func serveTempFile(w http.ResponseWriter, path, filename string) {
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", fi.Size()))
+	}
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	io.Copy(w, f)
+}
+
+// receiveTempFile writes a request body to a temporary file, lifting the
+// server's deadlines since archives can be large
+func receiveTempFile(w http.ResponseWriter, r *http.Request, pattern string) (string, error) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r.Body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to receive archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// handleExportImage streams a local image as a `podman save` archive
+func (s *Server) handleExportImage(w http.ResponseWriter, r *http.Request) {
+	image := r.URL.Query().Get("image")
+	if image == "" || strings.HasPrefix(image, "-") {
+		errorResponse(w, http.StatusBadRequest, "image is required")
+		return
+	}
+	podmanPath := migratePodmanPath()
+	if err := exec.CommandContext(r.Context(), podmanPath, "image", "exists", image).Run(); err != nil {
+		errorResponse(w, http.StatusNotFound, fmt.Sprintf("Image %s not found", image))
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	f, err := os.CreateTemp("", "image-*.tar")
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	f.Close()
+	os.Remove(f.Name()) // podman save refuses to overwrite
+	if output, err := exec.CommandContext(r.Context(), podmanPath, "save", "--output", f.Name(), image).CombinedOutput(); err != nil {
+		os.Remove(f.Name())
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("podman save failed: %v (output: %s)", err, strings.TrimSpace(string(output))))
+		return
+	}
+	serveTempFile(w, f.Name(), "image.tar")
+}
+
+// handleImportImage loads an image archive made by handleExportImage
+func (s *Server) handleImportImage(w http.ResponseWriter, r *http.Request) {
+	path, err := receiveTempFile(w, r, "image-*.tar")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer os.Remove(path)
+
+	output, err := exec.CommandContext(r.Context(), migratePodmanPath(), "load", "--input", path).CombinedOutput()
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("podman load failed: %v (output: %s)", err, strings.TrimSpace(string(output))))
+		return
+	}
+	var images []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if image, ok := strings.CutPrefix(strings.TrimSpace(line), "Loaded image:"); ok {
+			images = append(images, strings.TrimSpace(image))
+		}
+	}
+	if images == nil {
+		images = []string{}
+	}
+	s.logActivity("user", "import_image", "image", "", strings.Join(images, ", "), "success", "")
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"images": images})
+}
+
+// handleExportVolume streams a volume's contents as a tar archive
+func (s *Server) handleExportVolume(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	vol, _, err := s.findVolume(r.Context(), name)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if vol == nil {
+		errorResponse(w, http.StatusNotFound, fmt.Sprintf("Volume %s not found", name))
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	f, err := os.CreateTemp("", "volume-*.tar")
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	f.Close()
+	if err := backup.ExportVolume(r.Context(), name, f.Name()); err != nil {
+		os.Remove(f.Name())
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	serveTempFile(w, f.Name(), name+".tar")
+}
+
+// handleImportVolume extracts a tar archive into a volume, creating it if
+// needed. Files already in the volume are overwritten, not removed.
+func (s *Server) handleImportVolume(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !volumeNamePattern.MatchString(name) {
+		errorResponse(w, http.StatusBadRequest, "Invalid volume name")
+		return
+	}
+	path, err := receiveTempFile(w, r, "volume-*.tar")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer os.Remove(path)
+
+	if err := backup.ImportVolume(r.Context(), name, path); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logActivity("user", "import_volume", "volume", name, name, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]string{"name": name})
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/base-go/basepod/internal/diskutil"
+)
+
+// precompressMinSize is the smallest file worth a compressed copy
+const precompressMinSize = 1024
+
+// precompressBatch is how many files are handed to one brotli run
+const precompressBatch = 64
+
+// precompressExts are the extensions of the text assets that get compressed
+// copies; images, fonts like woff2 and archives are compressed already
+var precompressExts = map[string]bool{
+	".html": true, ".htm": true, ".css": true, ".js": true, ".mjs": true, ".cjs": true,
+	".json": true, ".map": true, ".svg": true, ".xml": true, ".txt": true, ".csv": true,
+	".md": true, ".wasm": true, ".ico": true, ".webmanifest": true, ".ttf": true,
+	".otf": true, ".eot": true,
+}
+
+// precompressStats sums up the copies written for a site
+type precompressStats struct {
+	Files    int   // Files with at least a .gz copy
+	Bytes    int64 // Their size
+	Gzip     int64 // Size of their .gz copies
+	Brotli   int64 // Size of their .br copies, 0 without the brotli command
+	NoBrotli bool  // The brotli command isn't installed, so only .gz copies were written
+}
+
+// String describes the copies for the deploy log
+func (p precompressStats) String() string {
+	if p.Files == 0 {
+		return "Precompressed: no text assets to compress"
+	}
+	out := fmt.Sprintf("Precompressed %d files: %s -> %s gzip", p.Files, diskutil.FormatBytes(p.Bytes), diskutil.FormatBytes(p.Gzip))
+	if p.NoBrotli {
+		return out + " (install brotli for .br copies)"
+	}
+	return out + fmt.Sprintf(", %s brotli", diskutil.FormatBytes(p.Brotli))
+}
+
+// precompressStatic writes a .gz copy, and a .br copy when the brotli command
+// is installed, next to each text asset in dir for Caddy to serve instead of
+// compressing on every request. Copies that aren't smaller than the file are
+// removed.
+func precompressStatic(ctx context.Context, dir string) (precompressStats, error) {
+	var stats precompressStats
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !precompressExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Size() >= precompressMinSize {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	// Compress at the highest level once rather than a fast one per request,
+	// a CPU's worth of files at a time
+	sizes := make([][2]int64, len(files))
+	errs := make([]error, len(files))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for i, path := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			sizes[i][0], sizes[i][1], errs[i] = gzipFile(path)
+		}()
+	}
+	wg.Wait()
+
+	var compressed []string
+	for i, path := range files {
+		if errs[i] != nil {
+			return stats, errs[i]
+		}
+		if sizes[i][1] == 0 {
+			continue
+		}
+		stats.Files++
+		stats.Bytes += sizes[i][0]
+		stats.Gzip += sizes[i][1]
+		compressed = append(compressed, path)
+	}
+
+	brotli, err := exec.LookPath("brotli")
+	if err != nil {
+		stats.NoBrotli = true
+		return stats, nil
+	}
+	for start := 0; start < len(compressed); start += precompressBatch {
+		batch := compressed[start:min(start+precompressBatch, len(compressed))]
+		args := append([]string{"--best", "--keep", "--force", "--"}, batch...)
+		if output, err := exec.CommandContext(ctx, brotli, args...).CombinedOutput(); err != nil {
+			return stats, fmt.Errorf("brotli: %v: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	for _, path := range compressed {
+		stats.Brotli += keepSmaller(path, path+".br")
+	}
+	return stats, nil
+}
+
+// gzipFile writes path.gz at the best compression and returns the sizes of
+// the file and the copy, with 0 for a copy that wasn't worth keeping
+func gzipFile(path string) (int64, int64, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, 0, err
+	}
+	zw, _ := gzip.NewWriterLevel(out, gzip.BestCompression)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return 0, 0, err
+	}
+	// Keep the copy's modification time in step with the file, so both
+	// give the same Last-Modified
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	return info.Size(), keepSmaller(path, path+".gz"), nil
+}
+
+// keepSmaller removes a compressed copy that isn't smaller than its file and
+// returns the size of the one kept, or 0
+func keepSmaller(path, copy string) int64 {
+	orig, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	c, err := os.Stat(copy)
+	if err != nil {
+		return 0
+	}
+	if c.Size() >= orig.Size() {
+		os.Remove(copy)
+		return 0
+	}
+	return c.Size()
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrecompressStatic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	js := strings.Repeat("console.log('hello');\n", 200)
+	os.MkdirAll(filepath.Join(dir, "assets"), 0755)
+	os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte(js), 0644)
+	os.WriteFile(filepath.Join(dir, "tiny.css"), []byte("body{}"), 0644)
+	os.WriteFile(filepath.Join(dir, "photo.png"), []byte(strings.Repeat("x", 4096)), 0644)
+
+	stats, err := precompressStatic(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("precompressStatic: %v", err)
+	}
+	if stats.Files != 1 || stats.Bytes != int64(len(js)) || stats.Gzip <= 0 || stats.Gzip >= stats.Bytes {
+		t.Fatalf("stats = %+v, want app.js compressed", stats)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "assets", "app.js.gz"))
+	if err != nil {
+		t.Fatalf("app.js.gz: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != js {
+		t.Fatal("app.js.gz doesn't hold app.js")
+	}
+
+	// Small files and images are left alone
+	for _, name := range []string{"tiny.css.gz", "photo.png.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s shouldn't exist", name)
+		}
+	}
+	if !strings.HasPrefix(stats.String(), "Precompressed 1 files") {
+		t.Fatalf("summary = %q", stats.String())
+	}
+}
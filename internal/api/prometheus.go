@@ -0,0 +1,359 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// httpLatencyBuckets are the upper bounds, in seconds, of the API request
+// latency histogram
+var httpLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// buildDurationBuckets are the upper bounds, in seconds, of the build
+// duration and build queue wait histograms
+var buildDurationBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800}
+
+// histogram counts observations into cumulative buckets, as Prometheus
+// histograms do
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(bounds)+1)
+	}
+	i := sort.SearchFloat64s(bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// httpKey identifies an API request series
+type httpKey struct {
+	method, route, code string
+}
+
+// deployKey identifies a deploy result series
+type deployKey struct {
+	app, result string
+}
+
+// promMetrics holds the daemon's own counters for GET /metrics. The zero
+// value is ready to use.
+type promMetrics struct {
+	mu            sync.Mutex
+	httpRequests  map[httpKey]uint64
+	httpLatency   map[httpKey]*histogram // Keyed without the status code
+	deploys       map[deployKey]uint64
+	buildDuration histogram
+	buildWait     histogram
+}
+
+// observeRequest records an API request. route is the pattern it matched,
+// so IDs in paths don't become series of their own.
+func (m *promMetrics) observeRequest(method, route string, status int, elapsed time.Duration) {
+	if _, pattern, ok := strings.Cut(route, " "); ok {
+		route = pattern
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.httpRequests == nil {
+		m.httpRequests = map[httpKey]uint64{}
+		m.httpLatency = map[httpKey]*histogram{}
+	}
+	m.httpRequests[httpKey{method, route, strconv.Itoa(status)}]++
+	key := httpKey{method: method, route: route}
+	h := m.httpLatency[key]
+	if h == nil {
+		h = &histogram{}
+		m.httpLatency[key] = h
+	}
+	h.observe(httpLatencyBuckets, elapsed.Seconds())
+}
+
+// observeDeploy counts a finished deploy; result is success or failed
+func (m *promMetrics) observeDeploy(appName, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deploys == nil {
+		m.deploys = map[deployKey]uint64{}
+	}
+	m.deploys[deployKey{appName, result}]++
+}
+
+// observeBuild records how long a build waited for a slot and then ran
+func (m *promMetrics) observeBuild(wait, run time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buildWait.observe(buildDurationBuckets, wait.Seconds())
+	m.buildDuration.observe(buildDurationBuckets, run.Seconds())
+}
+
+// statusRecorder remembers the status code a handler wrote. It passes
+// flushes and hijacks through for streams and the terminal's WebSocket.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// serveInstrumented serves an API request, recording it for GET /metrics
+func (s *Server) serveInstrumented(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	s.router.ServeHTTP(rec, r)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	// The mux sets the pattern it matched on r
+	s.prom.observeRequest(r.Method, r.Pattern, rec.status, time.Since(start))
+}
+
+// isMetricsRequest reports whether r is a scrape of /metrics, as opposed
+// to a request for an app that has the path itself
+func (s *Server) isMetricsRequest(r *http.Request, host string) bool {
+	if r.URL.Path != "/metrics" {
+		return false
+	}
+	a, _ := s.storage.GetAppByDomainOrAlias(host)
+	return a == nil
+}
+
+// promWriter writes the Prometheus text exposition format
+type promWriter struct {
+	w io.Writer
+}
+
+// header writes a metric family's HELP and TYPE lines
+func (p promWriter) header(name, kind, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one sample; labels alternate names and values
+func (p promWriter) sample(name string, value float64, labels ...string) {
+	fmt.Fprintf(p.w, "%s%s %s\n", name, promLabels(labels...), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// histogram writes a histogram's buckets, sum and count
+func (p promWriter) histogram(name string, bounds []float64, h *histogram, labels ...string) {
+	var cumulative uint64
+	for i, bound := range bounds {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		p.sample(name+"_bucket", float64(cumulative), append(labels, "le", strconv.FormatFloat(bound, 'g', -1, 64))...)
+	}
+	p.sample(name+"_bucket", float64(h.count), append(labels, "le", "+Inf")...)
+	p.sample(name+"_sum", h.sum, labels...)
+	p.sample(name+"_count", float64(h.count), labels...)
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabels(labels ...string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, labels[i], promLabelEscaper.Replace(labels[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// write writes the daemon's request, deploy and build metrics
+func (m *promMetrics) write(p promWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p.header("basepod_http_requests_total", "counter", "API requests handled, by method, route pattern and status code.")
+	reqKeys := make([]httpKey, 0, len(m.httpRequests))
+	for k := range m.httpRequests {
+		reqKeys = append(reqKeys, k)
+	}
+	sort.Slice(reqKeys, func(i, j int) bool {
+		a, b := reqKeys[i], reqKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	for _, k := range reqKeys {
+		p.sample("basepod_http_requests_total", float64(m.httpRequests[k]), "method", k.method, "route", k.route, "code", k.code)
+	}
+
+	p.header("basepod_http_request_duration_seconds", "histogram", "API request latency, by method and route pattern.")
+	latencyKeys := make([]httpKey, 0, len(m.httpLatency))
+	for k := range m.httpLatency {
+		latencyKeys = append(latencyKeys, k)
+	}
+	sort.Slice(latencyKeys, func(i, j int) bool {
+		if latencyKeys[i].route != latencyKeys[j].route {
+			return latencyKeys[i].route < latencyKeys[j].route
+		}
+		return latencyKeys[i].method < latencyKeys[j].method
+	})
+	for _, k := range latencyKeys {
+		p.histogram("basepod_http_request_duration_seconds", httpLatencyBuckets, m.httpLatency[k], "method", k.method, "route", k.route)
+	}
+
+	p.header("basepod_deploys_total", "counter", "Finished deploys, by app and result.")
+	deployKeys := make([]deployKey, 0, len(m.deploys))
+	for k := range m.deploys {
+		deployKeys = append(deployKeys, k)
+	}
+	sort.Slice(deployKeys, func(i, j int) bool {
+		if deployKeys[i].app != deployKeys[j].app {
+			return deployKeys[i].app < deployKeys[j].app
+		}
+		return deployKeys[i].result < deployKeys[j].result
+	})
+	for _, k := range deployKeys {
+		p.sample("basepod_deploys_total", float64(m.deploys[k]), "app", k.app, "result", k.result)
+	}
+
+	p.header("basepod_build_duration_seconds", "histogram", "Time image builds held a build slot.")
+	p.histogram("basepod_build_duration_seconds", buildDurationBuckets, &m.buildDuration)
+	p.header("basepod_build_queue_wait_seconds", "histogram", "Time image builds waited in the build queue.")
+	p.histogram("basepod_build_queue_wait_seconds", buildDurationBuckets, &m.buildWait)
+}
+
+// handlePrometheusMetrics serves daemon and per-app container metrics in the
+// Prometheus text format. Scrapers authenticate with a deploy token that has
+// the metrics:read scope.
+func (s *Server) handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	apps = sc.filterApps(apps)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	stats := s.liveStats(ctx, apps)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p := promWriter{w: w}
+
+	p.header("basepod_info", "gauge", "Always 1; the version label is the running basepod version.")
+	p.sample("basepod_info", 1, "version", s.version)
+	p.header("basepod_uptime_seconds", "gauge", "Seconds since the daemon started.")
+	p.sample("basepod_uptime_seconds", time.Since(s.startedAt).Seconds())
+	p.header("basepod_goroutines", "gauge", "Goroutines in the daemon.")
+	p.sample("basepod_goroutines", float64(runtime.NumGoroutine()))
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	p.header("basepod_memory_heap_bytes", "gauge", "Heap memory in use by the daemon.")
+	p.sample("basepod_memory_heap_bytes", float64(mem.HeapInuse))
+
+	s.prom.write(p)
+
+	running, queued := 0, 0
+	for _, b := range s.builds.list() {
+		if b.Status == "running" {
+			running++
+		} else {
+			queued++
+		}
+	}
+	p.header("basepod_builds_running", "gauge", "Image builds holding a build slot.")
+	p.sample("basepod_builds_running", float64(running))
+	p.header("basepod_builds_queued", "gauge", "Image builds waiting for a build slot.")
+	p.sample("basepod_builds_queued", float64(queued))
+
+	p.header("basepod_app_up", "gauge", "1 if the app is running, 0 otherwise.")
+	for _, a := range apps {
+		up := 0.0
+		if a.Status == app.StatusRunning {
+			up = 1
+		}
+		p.sample("basepod_app_up", up, "app", a.Name, "status", string(a.Status))
+	}
+
+	families := []struct {
+		name, kind, help string
+		value            func(LiveStats) float64
+	}{
+		{"basepod_app_cpu_percent", "gauge", "CPU use of the app's container, in percent of one core.", func(l LiveStats) float64 { return l.CPUPercent }},
+		{"basepod_app_memory_usage_bytes", "gauge", "Memory used by the app's container.", func(l LiveStats) float64 { return float64(l.MemUsage) }},
+		{"basepod_app_memory_limit_bytes", "gauge", "Memory limit of the app's container.", func(l LiveStats) float64 { return float64(l.MemLimit) }},
+		{"basepod_app_network_receive_bytes_total", "counter", "Bytes received by the app's container.", func(l LiveStats) float64 { return float64(l.NetInput) }},
+		{"basepod_app_network_transmit_bytes_total", "counter", "Bytes sent by the app's container.", func(l LiveStats) float64 { return float64(l.NetOutput) }},
+		{"basepod_app_block_read_bytes_total", "counter", "Bytes read from disk by the app's container.", func(l LiveStats) float64 { return float64(l.BlockInput) }},
+		{"basepod_app_block_write_bytes_total", "counter", "Bytes written to disk by the app's container.", func(l LiveStats) float64 { return float64(l.BlockOutput) }},
+	}
+	for _, f := range families {
+		p.header(f.name, f.kind, f.help)
+		for _, l := range stats {
+			if l.ContainerStatsResult != nil {
+				p.sample(f.name, f.value(l), "app", l.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromMetricsWrite(t *testing.T) {
+	t.Parallel()
+	var m promMetrics
+	m.observeRequest("GET", "GET /api/apps/{id}", 200, 20*time.Millisecond)
+	m.observeRequest("GET", "GET /api/apps/{id}", 404, 2*time.Second)
+	m.observeRequest("GET", "", 404, time.Millisecond)
+	m.observeDeploy(`we"b`, "success")
+	m.observeBuild(0, 90*time.Second)
+
+	var b strings.Builder
+	m.write(promWriter{w: &b})
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE basepod_http_requests_total counter\n",
+		`basepod_http_requests_total{method="GET",route="/api/apps/{id}",code="200"} 1` + "\n",
+		`basepod_http_requests_total{method="GET",route="/api/apps/{id}",code="404"} 1` + "\n",
+		`basepod_http_requests_total{method="GET",route="unmatched",code="404"} 1` + "\n",
+		// Buckets are cumulative
+		`basepod_http_request_duration_seconds_bucket{method="GET",route="/api/apps/{id}",le="0.025"} 1` + "\n",
+		`basepod_http_request_duration_seconds_bucket{method="GET",route="/api/apps/{id}",le="2.5"} 2` + "\n",
+		`basepod_http_request_duration_seconds_bucket{method="GET",route="/api/apps/{id}",le="+Inf"} 2` + "\n",
+		`basepod_http_request_duration_seconds_count{method="GET",route="/api/apps/{id}"} 2` + "\n",
+		`basepod_deploys_total{app="we\"b",result="success"} 1` + "\n",
+		`basepod_build_duration_seconds_bucket{le="60"} 0` + "\n",
+		`basepod_build_duration_seconds_bucket{le="120"} 1` + "\n",
+		"basepod_build_duration_seconds_sum 90\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/auth"
+)
+
+// ProtectionStatus says whether an app's domains ask for a password,
+// without the password hash
+type ProtectionStatus struct {
+	Enabled   bool       `json:"enabled"`
+	Username  string     `json:"username,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// protectionStatus reports an app's basic auth
+func protectionStatus(a *app.App) ProtectionStatus {
+	if a.BasicAuth == nil {
+		return ProtectionStatus{}
+	}
+	return ProtectionStatus{Enabled: true, Username: a.BasicAuth.Username, UpdatedAt: &a.BasicAuth.UpdatedAt}
+}
+
+// redactedApp returns a copy of an app to send to a client, without the
+// password hashes of its basic auth. They stay in storage.
+func redactedApp(a *app.App) *app.App {
+	out := *a
+	if a.BasicAuth != nil {
+		ba := *a.BasicAuth
+		ba.PasswordHash = ""
+		out.BasicAuth = &ba
+	}
+	if a.Routing != nil {
+		out.Routing = redactRouting(a.Routing)
+	}
+	return &out
+}
+
+// redactRouting returns a copy of routing rules without basic auth password
+// hashes
+func redactRouting(r *app.RoutingConfig) *app.RoutingConfig {
+	out := *r
+	out.BasicAuth = nil
+	for _, b := range r.BasicAuth {
+		b.Password, b.PasswordHash = "", ""
+		out.BasicAuth = append(out.BasicAuth, b)
+	}
+	return &out
+}
+
+// handleGetProtection reports whether an app is behind basic auth
+func (s *Server) handleGetProtection(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	jsonResponse(w, http.StatusOK, protectionStatus(a))
+}
+
+// handleProtectApp puts basic auth in front of an app's domains, replacing
+// any username and password it had
+func (s *Server) handleProtectApp(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req app.ProtectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		errorResponse(w, http.StatusBadRequest, "Username and password are required")
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	a.BasicAuth = &app.BasicAuthConfig{Username: req.Username, PasswordHash: hash, UpdatedAt: time.Now()}
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.refreshAppRoutes(a)
+	s.logActivity("user", "protect", "app", a.ID, a.Name, "success", "user "+req.Username)
+	jsonResponse(w, http.StatusOK, protectionStatus(a))
+}
+
+// handleUnprotectApp makes an app's domains public again
+func (s *Server) handleUnprotectApp(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	a.BasicAuth = nil
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.refreshAppRoutes(a)
+	s.logActivity("user", "unprotect", "app", a.ID, a.Name, "success", "")
+	jsonResponse(w, http.StatusOK, protectionStatus(a))
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/auth"
+)
+
+func TestProtectApp(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "protect-web", Domain: "protect-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	ts.waitForRoute("basepod-protect-web")
+
+	if code := ts.do("PUT", "/api/apps/protect-web/protect", app.ProtectRequest{Username: "staging"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("protect without a password: status %d, want 400", code)
+	}
+	var status ProtectionStatus
+	if code := ts.do("PUT", "/api/apps/protect-web/protect", app.ProtectRequest{Username: "staging", Password: "s3cret"}, &status); code != http.StatusOK {
+		t.Fatalf("protect: status %d", code)
+	}
+	if !status.Enabled || status.Username != "staging" {
+		t.Fatalf("status = %+v, want enabled for staging", status)
+	}
+	stored, _ := ts.storage.GetApp(a.ID)
+	if stored.BasicAuth == nil || stored.BasicAuth.PasswordHash == "s3cret" || !auth.CheckPassword(stored.BasicAuth.PasswordHash, "s3cret") {
+		t.Fatalf("stored basic auth = %+v, want the password hashed", stored.BasicAuth)
+	}
+	route, _ := json.Marshal(ts.caddyRoute("basepod-protect-web"))
+	if !strings.Contains(string(route), `"http_basic"`) || !strings.Contains(string(route), `"staging"`) {
+		t.Fatalf("route = %s, want basic auth for staging", route)
+	}
+
+	for _, path := range []string{"/api/apps/" + a.ID, "/api/apps", "/api/apps/protect-web/routing"} {
+		if body := ts.get(path); strings.Contains(body, "password_hash") || strings.Contains(body, stored.BasicAuth.PasswordHash) {
+			t.Fatalf("GET %s = %s, want no password hash", path, body)
+		}
+	}
+
+	status = ProtectionStatus{}
+	if code := ts.do("DELETE", "/api/apps/protect-web/protect", nil, &status); code != http.StatusOK || status.Enabled {
+		t.Fatalf("unprotect: status %d, %+v", code, status)
+	}
+	route, _ = json.Marshal(ts.caddyRoute("basepod-protect-web"))
+	if strings.Contains(string(route), `"authentication"`) {
+		t.Fatalf("route after unprotect = %s, want no basic auth", route)
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// lockfileRules maps a dependency manifest to the lockfiles that pin it; any
+// one of them satisfies the manifest
+var lockfileRules = []struct {
+	Manifest  string
+	Lockfiles []string
+}{
+	{"go.mod", []string{"go.sum"}},
+	{"package.json", []string{"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lock", "bun.lockb"}},
+	{"Cargo.toml", []string{"Cargo.lock"}},
+	{"Gemfile", []string{"Gemfile.lock"}},
+	{"composer.json", []string{"composer.lock"}},
+	{"Pipfile", []string{"Pipfile.lock"}},
+}
+
+// checkLockfiles fails when a manifest in sourceDir has no lockfile, or when
+// the client reported lockfiles with uncommitted changes
+func checkLockfiles(sourceDir string, dirty []string) error {
+	if len(dirty) > 0 {
+		return fmt.Errorf("lockfiles have uncommitted changes: %s", strings.Join(dirty, ", "))
+	}
+
+	var missing []string
+	for _, rule := range lockfileRules {
+		manifest, err := os.ReadFile(filepath.Join(sourceDir, rule.Manifest))
+		if err != nil {
+			continue
+		}
+		// A module without dependencies has nothing to lock
+		if rule.Manifest == "go.mod" && !bytes.Contains(manifest, []byte("require")) {
+			continue
+		}
+		found := false
+		for _, lock := range rule.Lockfiles {
+			if _, err := os.Stat(filepath.Join(sourceDir, lock)); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("%s (for %s)", rule.Lockfiles[0], rule.Manifest))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing lockfiles: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// buildProvenance hashes the build inputs in sourceDir and resolves the
+// digests of the base images the build used
+func (s *Server) buildProvenance(ctx context.Context, podmanPath, sourceDir, dockerfileRel string, buildArgs map[string]string) *app.BuildProvenance {
+	p := &app.BuildProvenance{
+		Dockerfile:     dockerfileRel,
+		BuildArgs:      buildArgs,
+		BuilderVersion: s.version,
+		BuiltAt:        time.Now(),
+	}
+
+	dockerfilePath := filepath.Join(sourceDir, dockerfileRel)
+	if sum, err := fileSHA256(dockerfilePath); err == nil {
+		p.DockerfileSHA256 = sum
+	}
+	if data, err := os.ReadFile(dockerfilePath); err == nil {
+		for _, image := range dockerfileBaseImages(string(data)) {
+			base := app.BaseImage{Image: image}
+			if out, err := execCommand(ctx, podmanPath, "image", "inspect", "--format", "{{.Digest}}", image); err == nil {
+				base.Digest = strings.TrimSpace(out)
+			}
+			p.BaseImages = append(p.BaseImages, base)
+		}
+	}
+
+	for _, rule := range lockfileRules {
+		for _, lock := range rule.Lockfiles {
+			if sum, err := fileSHA256(filepath.Join(sourceDir, lock)); err == nil {
+				if p.Lockfiles == nil {
+					p.Lockfiles = make(map[string]string)
+				}
+				p.Lockfiles[lock] = sum
+			}
+		}
+	}
+	return p
+}
+
+// dockerfileBaseImages lists the images named by FROM instructions, skipping
+// scratch and references to earlier build stages
+func dockerfileBaseImages(dockerfile string) []string {
+	var images []string
+	stages := make(map[string]bool)
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(dockerfile))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		args := fields[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+		image := args[0]
+		if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages[strings.ToLower(args[2])] = true
+		}
+		if image == "scratch" || stages[strings.ToLower(image)] || seen[image] {
+			continue
+		}
+		seen[image] = true
+		images = append(images, image)
+	}
+	return images
+}
+
+// fileSHA256 returns the hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildArgFlags turns build args into sorted --build-arg flags
+func buildArgFlags(buildArgs map[string]string) []string {
+	keys := make([]string, 0, len(buildArgs))
+	for k := range buildArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var flags []string
+	for _, k := range keys {
+		flags = append(flags, "--build-arg", k+"="+buildArgs[k])
+	}
+	return flags
+}
+
+// handleGetProvenance lists the build provenance of an app's releases
+func (s *Server) handleGetProvenance(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	type release struct {
+		DeploymentID string               `json:"deployment_id"`
+		Image        string               `json:"image,omitempty"`
+		CommitHash   string               `json:"commit_hash,omitempty"`
+		Status       string               `json:"status"`
+		DeployedAt   time.Time            `json:"deployed_at"`
+		Provenance   *app.BuildProvenance `json:"provenance"`
+	}
+	releases := []release{}
+	for _, d := range a.Deployments {
+		if d.Provenance == nil {
+			continue
+		}
+		releases = append(releases, release{
+			DeploymentID: d.ID,
+			Image:        d.Image,
+			CommitHash:   d.CommitHash,
+			Status:       d.Status,
+			DeployedAt:   d.DeployedAt,
+			Provenance:   d.Provenance,
+		})
+	}
+	jsonResponse(w, http.StatusOK, releases)
+}
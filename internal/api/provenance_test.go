@@ -0,0 +1,60 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckLockfiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	// A go.mod without requirements needs no go.sum
+	write("go.mod", "module example.com/app\n\ngo 1.22\n")
+	if err := checkLockfiles(dir, nil); err != nil {
+		t.Fatalf("checkLockfiles without deps: %v", err)
+	}
+
+	write("go.mod", "module example.com/app\n\ngo 1.22\n\nrequire github.com/google/uuid v1.6.0\n")
+	write("package.json", "{}")
+	err := checkLockfiles(dir, nil)
+	if err == nil || !strings.Contains(err.Error(), "go.sum") || !strings.Contains(err.Error(), "package-lock.json") {
+		t.Fatalf("checkLockfiles = %v, want go.sum and package-lock.json missing", err)
+	}
+
+	write("go.sum", "")
+	write("pnpm-lock.yaml", "")
+	if err := checkLockfiles(dir, nil); err != nil {
+		t.Fatalf("checkLockfiles with lockfiles: %v", err)
+	}
+	if err := checkLockfiles(dir, []string{"go.sum"}); err == nil {
+		t.Fatal("checkLockfiles should fail on uncommitted lockfiles")
+	}
+}
+
+func TestDockerfileBaseImages(t *testing.T) {
+	t.Parallel()
+
+	dockerfile := `FROM --platform=linux/amd64 golang:1.22 AS build
+RUN go build -o /app
+FROM build AS test
+from node:20-alpine as assets
+FROM scratch
+FROM gcr.io/distroless/static
+COPY --from=build /app /app
+`
+	got := dockerfileBaseImages(dockerfile)
+	want := []string{"golang:1.22", "node:20-alpine", "gcr.io/distroless/static"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("dockerfileBaseImages = %v, want %v", got, want)
+	}
+}
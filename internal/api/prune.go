@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/diskutil"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// defaultPruneKeepReleases is how many recent releases of each app keep
+// their images through a prune, so they can still be rolled back to
+const defaultPruneKeepReleases = 3
+
+// PruneRequest is the body of POST /api/system/prune; all fields are optional
+type PruneRequest struct {
+	DryRun     bool `json:"dryRun"`
+	Aggressive bool `json:"aggressive"` // Also remove pulled images and volumes no app uses
+	Keep       int  `json:"keep"`       // Releases per app whose images are kept (default 3)
+}
+
+// PruneItem is a container, image or volume a prune removes or keeps
+type PruneItem struct {
+	Kind   string `json:"kind"` // container, image, volume
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size,omitempty"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"` // Set when the removal failed
+}
+
+// PruneReport is what a prune removed, or would remove on a dry run
+type PruneReport struct {
+	DryRun            bool        `json:"dryRun"`
+	Aggressive        bool        `json:"aggressive"`
+	Remove            []PruneItem `json:"remove"`
+	Keep              []PruneItem `json:"keep"`
+	ContainersRemoved int         `json:"containersRemoved"`
+	ImagesRemoved     int         `json:"imagesRemoved"`
+	VolumesRemoved    int         `json:"volumesRemoved"`
+	SpaceReclaimed    string      `json:"spaceReclaimed,omitempty"`
+	BuildCache        string      `json:"buildCache,omitempty"`
+	SourceBlobs       string      `json:"sourceBlobs,omitempty"` // Delta upload contents no recent deploy used
+}
+
+// Summary is the report as lines of text, for the web UI and maintenance runs
+func (r *PruneReport) Summary() string {
+	var b strings.Builder
+	verb := "Removed"
+	if r.DryRun {
+		verb = "Would remove"
+	}
+	for _, item := range r.Remove {
+		if item.Error != "" {
+			fmt.Fprintf(&b, "Failed to remove %s %s: %s\n", item.Kind, item.Name, item.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s %s (%s)\n", verb, item.Kind, item.Name, item.Reason)
+	}
+	if !r.DryRun {
+		fmt.Fprintf(&b, "Containers: %d removed, images: %d removed, volumes: %d removed\n", r.ContainersRemoved, r.ImagesRemoved, r.VolumesRemoved)
+		if r.SpaceReclaimed != "" {
+			fmt.Fprintf(&b, "Space reclaimed: %s\n", r.SpaceReclaimed)
+		}
+		if r.BuildCache != "" {
+			fmt.Fprintf(&b, "Build cache: %s\n", r.BuildCache)
+		}
+		if r.SourceBlobs != "" {
+			fmt.Fprintf(&b, "Source blobs: %s\n", r.SourceBlobs)
+		}
+	}
+	return b.String()
+}
+
+// protectedImages maps the IDs of images prune must keep to why: each app's
+// current image and the images of its last keep successful releases
+func protectedImages(apps []app.App, images []podman.Image, keep int) map[string]string {
+	protected := map[string]string{}
+	protect := func(ref, reason string) {
+		if ref == "" {
+			return
+		}
+		if id := findImageID(images, ref); id != "" {
+			if _, ok := protected[id]; !ok {
+				protected[id] = reason
+			}
+		}
+	}
+	for _, a := range apps {
+		protect(a.Image, "current image of "+a.Name)
+		kept := 0
+		for _, d := range a.Deployments {
+			if kept >= keep {
+				break
+			}
+			if d.Status != "success" || d.Image == "" {
+				continue
+			}
+			kept++
+			protect(d.Image, fmt.Sprintf("release %s of %s", d.ID, a.Name))
+		}
+	}
+	return protected
+}
+
+// appVolumes maps the names of volumes attached to existing apps to their app
+func appVolumes(apps []app.App, volumes []podman.Volume) map[string]string {
+	owned := map[string]string{}
+	byID := map[string]string{}
+	byName := map[string]string{}
+	for i := range apps {
+		a := &apps[i]
+		byID[a.ID] = a.Name
+		byName[a.Name] = a.Name
+		for _, v := range a.Volumes {
+			owned[appVolumeName(a, v)] = a.Name
+		}
+		for _, svc := range a.Services {
+			for _, v := range svc.Volumes {
+				owned[appVolumeName(a, v)] = a.Name
+			}
+		}
+	}
+	// Volumes created for an app carry its labels, whatever they are named
+	for _, v := range volumes {
+		if name, ok := byID[v.Labels["basepod.app.id"]]; ok {
+			owned[v.Name] = name
+		} else if name, ok := byName[v.Labels["basepod.app"]]; ok {
+			owned[v.Name] = name
+		}
+	}
+	return owned
+}
+
+// volumeKeepReason says why a prune must keep v, given the volumes apps
+// own; "" means v is orphaned
+func volumeKeepReason(v podman.Volume, owned map[string]string) string {
+	if owner, ok := owned[v.Name]; ok {
+		return "volume of " + owner
+	}
+	if v.Labels["basepod.registry"] == "true" {
+		return "built-in image registry"
+	}
+	return ""
+}
+
+// planPrune decides what a prune removes. Containers of existing apps, the
+// images they and their recent releases use, and volumes attached to apps
+// are always kept. Without aggressive, only stopped stray containers,
+// untagged images and old basepod builds go.
+func planPrune(apps []app.App, containers []podman.Container, images []podman.Image, volumes []podman.Volume, keep int, aggressive bool) (remove, kept []PruneItem) {
+	appContainers := map[string]string{}
+	for _, a := range apps {
+		appContainers["basepod-"+a.Name] = a.Name
+		appContainers[drainingContainerName(a.Name)] = a.Name
+		if a.ContainerID != "" {
+			appContainers[a.ContainerID] = a.Name
+		}
+		for _, svc := range a.Services {
+			appContainers[serviceContainerName(&a, svc.Name)] = a.Name
+		}
+	}
+
+	// Images of containers that stay are in use
+	inUse := map[string]bool{}
+	for _, c := range containers {
+		name := strings.TrimPrefix(firstString(c.Names), "/")
+		owner, isApp := appContainers[name]
+		if !isApp {
+			owner, isApp = appContainers[c.ID]
+		}
+		switch {
+		case isApp && c.State != "running":
+			kept = append(kept, PruneItem{Kind: "container", ID: c.ID, Name: name, Reason: "stopped container of " + owner})
+		case c.State != "running":
+			remove = append(remove, PruneItem{Kind: "container", ID: c.ID, Name: name, Reason: "stopped, not an app's container"})
+			continue
+		}
+		inUse[strings.TrimPrefix(c.ImageID, "sha256:")] = true
+		if id := findImageID(images, c.Image); id != "" {
+			inUse[strings.TrimPrefix(id, "sha256:")] = true
+		}
+	}
+
+	protected := protectedImages(apps, images, keep)
+	for _, img := range images {
+		name := firstString(img.RepoTags)
+		if name == "" {
+			name = "<none>"
+		}
+		item := PruneItem{Kind: "image", ID: img.ID, Name: name, Size: img.Size}
+		if reason, ok := protected[img.ID]; ok {
+			item.Reason = reason
+			kept = append(kept, item)
+			continue
+		}
+		if inUse[strings.TrimPrefix(img.ID, "sha256:")] {
+			continue
+		}
+		basepodBuild := false
+		for _, tag := range img.RepoTags {
+			if strings.HasPrefix(tag, "localhost/basepod/") {
+				basepodBuild = true
+			}
+		}
+		switch {
+		case len(img.RepoTags) == 0:
+			item.Reason = "untagged"
+		case basepodBuild:
+			item.Reason = "old basepod build"
+		case aggressive:
+			item.Reason = "unused"
+		default:
+			item.Reason = "unused pulled image (removed with --aggressive)"
+			kept = append(kept, item)
+			continue
+		}
+		remove = append(remove, item)
+	}
+
+	owned := appVolumes(apps, volumes)
+	for _, v := range volumes {
+		item := PruneItem{Kind: "volume", ID: v.Name, Name: v.Name}
+		if reason := volumeKeepReason(v, owned); reason != "" {
+			item.Reason = reason
+			kept = append(kept, item)
+			continue
+		}
+		if !aggressive {
+			item.Reason = "not attached to an app (removed with --aggressive)"
+			kept = append(kept, item)
+			continue
+		}
+		item.Reason = "not attached to an app"
+		remove = append(remove, item)
+	}
+	return remove, kept
+}
+
+// firstString returns the first element of s, or ""
+func firstString(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
+
+// handleSystemPrune removes stray containers, old images and, with
+// aggressive, unused volumes, but never anything an app or its recent
+// releases need
+func (s *Server) handleSystemPrune(w http.ResponseWriter, r *http.Request) {
+	var req PruneRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	report, err := s.pruneSystem(r.Context(), req)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !req.DryRun {
+		s.logActivity("user", "prune", "system", "", "", "success",
+			fmt.Sprintf("%d containers, %d images, %d volumes", report.ContainersRemoved, report.ImagesRemoved, report.VolumesRemoved))
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":            "pruned",
+		"output":            report.Summary(),
+		"dryRun":            report.DryRun,
+		"aggressive":        report.Aggressive,
+		"remove":            report.Remove,
+		"keep":              report.Keep,
+		"containersRemoved": report.ContainersRemoved,
+		"imagesRemoved":     report.ImagesRemoved,
+		"volumesRemoved":    report.VolumesRemoved,
+		"spaceReclaimed":    report.SpaceReclaimed,
+	})
+}
+
+// pruneSystem plans a prune and, unless it is a dry run, carries it out
+func (s *Server) pruneSystem(ctx context.Context, req PruneRequest) (*PruneReport, error) {
+	if s.podman == nil {
+		return nil, fmt.Errorf("podman not available")
+	}
+	if req.Keep <= 0 {
+		req.Keep = defaultPruneKeepReleases
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apps: %w", err)
+	}
+	containers, err := s.podman.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	images, err := s.podman.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := s.podman.ListVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{DryRun: req.DryRun, Aggressive: req.Aggressive}
+	report.Remove, report.Keep = planPrune(apps, containers, images, volumes, req.Keep, req.Aggressive)
+	if req.DryRun {
+		return report, nil
+	}
+
+	// Containers first so the images they held can go
+	var reclaimed int64
+	for i := range report.Remove {
+		item := &report.Remove[i]
+		var err error
+		switch item.Kind {
+		case "container":
+			if err = s.podman.RemoveContainer(ctx, item.ID, false); err == nil {
+				report.ContainersRemoved++
+			}
+		case "image":
+			if err = s.podman.RemoveImage(ctx, item.ID, false); err == nil {
+				report.ImagesRemoved++
+				reclaimed += item.Size
+			}
+		case "volume":
+			// Not forced: a volume some container still mounts stays
+			if err = s.podman.RemoveVolume(ctx, item.ID, false); err == nil {
+				report.VolumesRemoved++
+			}
+		}
+		if err != nil {
+			item.Error = err.Error()
+		}
+	}
+	if reclaimed > 0 {
+		report.SpaceReclaimed = diskutil.FormatBytes(reclaimed)
+	}
+
+	// The build cache only speeds up builds; nothing rolls back to it
+	podmanPath := "podman"
+	if _, err := exec.LookPath("podman"); err != nil {
+		for _, p := range []string{"/opt/homebrew/bin/podman", "/usr/local/bin/podman"} {
+			if _, err := os.Stat(p); err == nil {
+				podmanPath = p
+				break
+			}
+		}
+	}
+	cmd := exec.CommandContext(ctx, podmanPath, "builder", "prune", "-af")
+	if out, err := cmd.CombinedOutput(); err == nil {
+		report.BuildCache = strings.TrimSpace(string(out))
+	}
+
+	// Contents of delta uploads come back with the next upload that has them
+	if n, size, err := pruneSourceBlobs(time.Now().Add(-sourceBlobMaxAge)); err == nil && n > 0 {
+		report.SourceBlobs = fmt.Sprintf("%d removed (%s)", n, diskutil.FormatBytes(size))
+	}
+	return report, nil
+}
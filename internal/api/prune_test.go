@@ -0,0 +1,78 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+func TestPlanPrune(t *testing.T) {
+	t.Parallel()
+
+	apps := []app.App{{
+		ID:      "a1",
+		Name:    "web",
+		Image:   "localhost/basepod/web:5",
+		Volumes: []app.VolumeMount{{Name: "data", ContainerPath: "/data"}},
+		Deployments: []app.DeploymentRecord{
+			{ID: "d5", Image: "localhost/basepod/web:5", Status: "success"},
+			{ID: "d4", Image: "localhost/basepod/web:4", Status: "failed"},
+			{ID: "d3", Image: "localhost/basepod/web:3", Status: "success"},
+			{ID: "d2", Image: "localhost/basepod/web:2", Status: "success"},
+		},
+	}}
+	containers := []podman.Container{
+		{ID: "c1", Names: []string{"basepod-web"}, Image: "localhost/basepod/web:5", ImageID: "i5", State: "exited"},
+		{ID: "c2", Names: []string{"stray"}, Image: "docker.io/library/busybox:latest", ImageID: "ibusy", State: "exited"},
+		{ID: "c3", Names: []string{"db"}, Image: "docker.io/library/postgres:16", ImageID: "ipg", State: "running"},
+	}
+	images := []podman.Image{
+		{ID: "i5", RepoTags: []string{"localhost/basepod/web:5"}},
+		{ID: "i4", RepoTags: []string{"localhost/basepod/web:4"}},
+		{ID: "i3", RepoTags: []string{"localhost/basepod/web:3"}},
+		{ID: "i2", RepoTags: []string{"localhost/basepod/web:2"}},
+		{ID: "idangling"},
+		{ID: "ibusy", RepoTags: []string{"docker.io/library/busybox:latest"}},
+		{ID: "ipg", RepoTags: []string{"docker.io/library/postgres:16"}},
+	}
+	volumes := []podman.Volume{
+		{Name: "basepod-web-data"},
+		{Name: "labelled", Labels: map[string]string{"basepod.app.id": "a1"}},
+		{Name: "basepod-gone-data"},
+		{Name: "basepod-registry", Labels: map[string]string{"basepod.registry": "true"}},
+	}
+
+	removed := func(items []PruneItem) map[string]bool {
+		m := map[string]bool{}
+		for _, item := range items {
+			m[item.Kind+" "+item.ID] = true
+		}
+		return m
+	}
+
+	remove, _ := planPrune(apps, containers, images, volumes, 2, false)
+	got := removed(remove)
+	want := []string{"container c2", "image i4", "image i2", "image idangling"}
+	if len(got) != len(want) {
+		t.Fatalf("remove = %+v, want %v", remove, want)
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Fatalf("remove = %+v, missing %s", remove, w)
+		}
+	}
+
+	remove, _ = planPrune(apps, containers, images, volumes, 2, true)
+	got = removed(remove)
+	for _, w := range []string{"image ibusy", "volume basepod-gone-data"} {
+		if !got[w] {
+			t.Fatalf("aggressive remove = %+v, missing %s", remove, w)
+		}
+	}
+	for _, keep := range []string{"volume basepod-web-data", "volume labelled", "volume basepod-registry", "image ipg", "image i5", "image i3", "container c1"} {
+		if got[keep] {
+			t.Fatalf("aggressive prune removes %s", keep)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+)
+
+// PurgeResult is what purging an app's caches did
+type PurgeResult struct {
+	Files         int       `json:"files"`         // Files given a new ETag and Last-Modified
+	Removed       int       `json:"removed"`       // Compressed copies dropped
+	Precompressed int       `json:"precompressed"` // Files given fresh .gz and .br copies
+	PurgedAt      time.Time `json:"purged_at"`
+}
+
+// isCompressedCopy reports whether path is a .gz or .br copy of a text asset
+// next to it, rather than a file the site ships, like a .tar.gz download
+func isCompressedCopy(path string) bool {
+	ext := filepath.Ext(path)
+	if ext != ".gz" && ext != ".br" {
+		return false
+	}
+	orig := strings.TrimSuffix(path, ext)
+	if !precompressExts[strings.ToLower(filepath.Ext(orig))] {
+		return false
+	}
+	_, err := os.Stat(orig)
+	return err == nil
+}
+
+// purgeStatic drops a static site's compressed copies and gives every file
+// the purge time as its modification time, which changes the ETag and
+// Last-Modified Caddy sends, so browsers and CDNs fetch them again. Fresh
+// copies are written when the site is precompressed.
+func purgeStatic(ctx context.Context, dir string, precompress bool, now time.Time) (PurgeResult, error) {
+	result := PurgeResult{PurgedAt: now}
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if isCompressedCopy(path) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			result.Removed++
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for _, path := range files {
+		if err := os.Chtimes(path, now, now); err != nil {
+			return result, err
+		}
+		result.Files++
+	}
+
+	if precompress {
+		stats, err := precompressStatic(ctx, dir)
+		if err != nil {
+			return result, err
+		}
+		result.Precompressed = stats.Files
+	}
+	return result, nil
+}
+
+// handlePurgeApp clears a static site's server-side caches, e.g. after its
+// files were changed outside a deploy
+func (s *Server) handlePurgeApp(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Type != app.AppTypeStatic {
+		errorResponse(w, http.StatusBadRequest, "Only static sites have server-side caches to purge")
+		return
+	}
+
+	paths, err := config.GetPaths()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	appDataDir := filepath.Join(paths.Base, "data", "apps", a.Name)
+	if _, err := os.Stat(appDataDir); os.IsNotExist(err) {
+		errorResponse(w, http.StatusBadRequest, "App has not been deployed")
+		return
+	}
+
+	result, err := purgeStatic(r.Context(), appDataDir, a.Static.Precompresses(), time.Now())
+	if err != nil {
+		s.logActivity("user", "purge", "app", a.ID, a.Name, "failed", err.Error())
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.refreshAppRoutes(a)
+	s.logActivity("user", "purge", "app", a.ID, a.Name, "success", fmt.Sprintf("%d files, %d compressed copies dropped", result.Files, result.Removed))
+	jsonResponse(w, http.StatusOK, result)
+}
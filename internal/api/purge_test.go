@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestPurgeStatic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	js := strings.Repeat("console.log('v2');\n", 200)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte(js), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js.gz"), []byte("stale"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js.br"), []byte("stale"), 0644)
+	os.WriteFile(filepath.Join(dir, "release.tar.gz"), []byte("download"), 0644)
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(dir, "app.js"), old, old)
+
+	now := time.Now().Truncate(time.Second)
+	result, err := purgeStatic(context.Background(), dir, false, now)
+	if err != nil {
+		t.Fatalf("purgeStatic: %v", err)
+	}
+	if result.Removed != 2 || result.Files != 2 || result.Precompressed != 0 {
+		t.Fatalf("result = %+v, want both copies of app.js dropped", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "release.tar.gz")); err != nil {
+		t.Fatal("a .tar.gz the site ships should be kept")
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "app.js")); !info.ModTime().Equal(now) {
+		t.Fatalf("app.js modified %v, want %v for a new ETag", info.ModTime(), now)
+	}
+
+	result, err = purgeStatic(context.Background(), dir, true, now)
+	if err != nil || result.Precompressed != 1 {
+		t.Fatalf("purgeStatic with precompression = %+v, %v", result, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "app.js.gz")); len(data) == 0 || string(data) == "stale" {
+		t.Fatal("app.js.gz should be written afresh")
+	}
+}
+
+func TestPurgeApp(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "purge-api", Domain: "purge-api.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	if code := ts.do("POST", "/api/apps/purge-api/purge", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("purge of a container app: status %d, want 400", code)
+	}
+
+	// Turn it into a deployed static site
+	stored, _ := ts.storage.GetApp(a.ID)
+	stored.Type = app.AppTypeStatic
+	if err := ts.storage.UpdateApp(stored); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	if code := ts.do("POST", "/api/apps/purge-api/purge", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("purge before a deploy: status %d, want 400", code)
+	}
+	paths, _ := config.GetPaths()
+	dir := filepath.Join(paths.Base, "data", "apps", "purge-api")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte(strings.Repeat("<p>hello</p>\n", 200)), 0644)
+
+	var result PurgeResult
+	if code := ts.do("POST", "/api/apps/purge-api/purge", nil, &result); code != http.StatusOK {
+		t.Fatalf("purge: status %d", code)
+	}
+	if result.Files != 1 || result.Precompressed != 1 {
+		t.Fatalf("result = %+v, want index.html purged and precompressed", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.html.gz")); err != nil {
+		t.Fatalf("index.html.gz: %v", err)
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
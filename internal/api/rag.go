@@ -0,0 +1,357 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/mlx"
+	"github.com/base-go/basepod/internal/podman"
+	"github.com/base-go/basepod/internal/rag"
+	"github.com/google/uuid"
+)
+
+const (
+	logIndexInterval  = 10 * time.Minute
+	logIndexChunkSize = 20
+	logIndexBatchSize = 32
+)
+
+// embed returns embeddings for texts using the configured local model
+func (s *Server) embed(texts []string, class string) ([][]float32, error) {
+	if !mlx.IsSupported() {
+		return nil, fmt.Errorf("embeddings require MLX: %s", mlx.GetUnsupportedReason())
+	}
+	return mlx.GetService().EmbedClass(texts, s.config.AI.EmbeddingModel, class)
+}
+
+// handleAIEmbeddings returns embeddings in the OpenAI response shape
+func (s *Server) handleAIEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input json.RawMessage `json:"input"`
+		Model string          `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// input may be a single string or an array of strings
+	var inputs []string
+	var single string
+	if err := json.Unmarshal(req.Input, &single); err == nil {
+		inputs = []string{single}
+	} else if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		errorResponse(w, http.StatusBadRequest, "input must be a string or array of strings")
+		return
+	}
+	if len(inputs) == 0 {
+		errorResponse(w, http.StatusBadRequest, "input is required")
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = s.config.AI.EmbeddingModel
+	}
+	if model == "" {
+		model = mlx.DefaultEmbeddingModel
+	}
+	if !mlx.IsSupported() {
+		errorResponse(w, http.StatusServiceUnavailable, "Embeddings require MLX: "+mlx.GetUnsupportedReason())
+		return
+	}
+	vectors, err := mlx.GetService().Embed(inputs, model)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := make([]map[string]interface{}, len(vectors))
+	for i, v := range vectors {
+		data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": v}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"model":  model,
+		"data":   data,
+	})
+}
+
+// handleAISearch runs a semantic search over indexed logs and notes
+func (s *Server) handleAISearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query string `json:"q"`
+		App   string `json:"app"`
+		Since string `json:"since"` // RFC3339 or duration like "24h"
+		Limit int    `json:"limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		errorResponse(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 50 {
+		req.Limit = 10
+	}
+
+	// Callers limited to some apps only write to those apps' documents;
+	// shared notes are outside every scope
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	var appID string
+	if req.App != "" {
+		a, err := s.resolveApp(req.App)
+		if err != nil || a == nil || !sc.allows(a.ID) {
+			errorResponse(w, http.StatusNotFound, "App not found")
+			return
+		}
+		appID = a.ID
+	} else if sc != nil {
+		errorResponse(w, http.StatusForbidden, "app is required for callers limited to some apps")
+		return
+	}
+
+	var since time.Time
+	if req.Since != "" {
+		if d, err := time.ParseDuration(req.Since); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, req.Since); err == nil {
+			since = t
+		} else {
+			errorResponse(w, http.StatusBadRequest, "since must be RFC3339 or a duration like 24h")
+			return
+		}
+	}
+
+	docs, err := s.storage.ListAIDocuments(appID, since, time.Time{})
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	docs = s.filterDocsForCaller(r, docs)
+	if len(docs) == 0 {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"results": []rag.Result{}})
+		return
+	}
+
+	vectors, err := s.embed([]string{req.Query}, mlx.ClassInteractive)
+	if err != nil {
+		errorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	results := rag.Rank(vectors[0], docs, req.Limit)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// filterDocsForCaller hides documents from apps outside the caller's scope
+func (s *Server) filterDocsForCaller(r *http.Request, docs []app.AIDocument) []app.AIDocument {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		return nil
+	}
+	if sc == nil {
+		return docs
+	}
+	var filtered []app.AIDocument
+	for _, d := range docs {
+		if sc[d.AppID] {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
+
+// handleAIAddDocument indexes a note or doc snippet, optionally attached to an app
+func (s *Server) handleAIAddDocument(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		App     string `json:"app"`
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		errorResponse(w, http.StatusBadRequest, "content is required")
+		return
+	}
+
+	// Callers limited to some apps only write to those apps' documents;
+	// shared notes are outside every scope
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	var appID string
+	if req.App != "" {
+		a, err := s.resolveApp(req.App)
+		if err != nil || a == nil || !sc.allows(a.ID) {
+			errorResponse(w, http.StatusNotFound, "App not found")
+			return
+		}
+		appID = a.ID
+	} else if sc != nil {
+		errorResponse(w, http.StatusForbidden, "app is required for callers limited to some apps")
+		return
+	}
+
+	vectors, err := s.embed([]string{req.Title + "\n" + req.Content}, mlx.ClassInteractive)
+	if err != nil {
+		errorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	now := time.Now()
+	doc := &app.AIDocument{
+		ID:        uuid.New().String(),
+		AppID:     appID,
+		Source:    "note",
+		Title:     req.Title,
+		Content:   req.Content,
+		Embedding: vectors[0],
+		Timestamp: now,
+		CreatedAt: now,
+	}
+	if err := s.storage.SaveAIDocument(doc); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	jsonResponse(w, http.StatusCreated, doc)
+}
+
+// handleAIDeleteDocument removes an indexed document. Documents of apps
+// outside the caller's scope are reported missing.
+func (s *Server) handleAIDeleteDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.storage.GetAIDocument(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	if doc == nil || !sc.allows(doc.AppID) {
+		errorResponse(w, http.StatusNotFound, "Document not found")
+		return
+	}
+	if err := s.storage.DeleteAIDocument(doc.ID); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "Document deleted"})
+}
+
+// runLogIndexer periodically embeds new log lines of running apps when ai.index_logs is on
+func (s *Server) runLogIndexer() {
+	if !s.config.AI.IndexLogs || !mlx.IsSupported() {
+		return
+	}
+
+	ticker := time.NewTicker(logIndexInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.indexAppLogs()
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+func (s *Server) indexAppLogs() {
+	apps, _ := s.storage.ListApps()
+	for _, a := range apps {
+		if a.ContainerID == "" || a.Status != app.StatusRunning {
+			continue
+		}
+		if err := s.indexLogsForApp(&a); err != nil {
+			log.Printf("Log indexer: %s: %v", a.Name, err)
+		}
+	}
+
+	retention := s.config.AI.IndexRetentionDays
+	if retention <= 0 {
+		retention = 14
+	}
+	s.storage.CleanOldAIDocuments(time.Now().AddDate(0, 0, -retention))
+}
+
+func (s *Server) indexLogsForApp(a *app.App) error {
+	cursorKey := "ai_index_cursor:" + a.ID
+	since := time.Now().Add(-logIndexInterval).Unix()
+	if v, _ := s.storage.GetSetting(cursorKey); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			since = n
+		}
+	}
+	now := time.Now().Unix()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	logs, err := s.podman.ContainerLogs(ctx, a.ContainerID, podman.LogOpts{
+		Stdout:     true,
+		Stderr:     true,
+		Timestamps: true,
+		Since:      strconv.FormatInt(since, 10),
+	})
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(logs, 4<<20))
+	logs.Close()
+	if err != nil {
+		return err
+	}
+
+	chunks := rag.ChunkLogs(strings.Split(podman.DemuxLogs(data), "\n"), logIndexChunkSize)
+	for start := 0; start < len(chunks); start += logIndexBatchSize {
+		batch := chunks[start:min(start+logIndexBatchSize, len(chunks))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.Content
+		}
+		vectors, err := s.embed(texts, mlx.ClassBackground)
+		if err != nil {
+			return err
+		}
+		for i, c := range batch {
+			ts := c.Timestamp
+			if ts.IsZero() {
+				ts = time.Unix(now, 0)
+			}
+			s.storage.SaveAIDocument(&app.AIDocument{
+				ID:        uuid.New().String(),
+				AppID:     a.ID,
+				Source:    "log",
+				Title:     a.Name,
+				Content:   c.Content,
+				Embedding: vectors[i],
+				Timestamp: ts,
+				CreatedAt: time.Now(),
+			})
+		}
+	}
+
+	return s.storage.SetSetting(cursorKey, strconv.FormatInt(now, 10))
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestAIDocumentScope(t *testing.T) {
+	ts := newTestServer(t)
+
+	var granted, other app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "rag-granted", Domain: "rag-granted.test"}, &granted)
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "rag-other", Domain: "rag-other.test"}, &other)
+	body := map[string]interface{}{"email": "rag@example.com", "password": "deployer-pass", "role": "deployer", "app_ids": []string{"rag-granted"}}
+	if code := ts.do("POST", "/api/users", body, nil); code != http.StatusCreated {
+		t.Fatalf("create user: status %d", code)
+	}
+	dev := ts.login("rag@example.com", "deployer-pass")
+
+	// Writing into another app's index, or the shared one, is refused before
+	// anything is embedded
+	note := map[string]string{"app": "rag-other", "title": "runbook", "content": "restart the worker"}
+	if code := ts.doAs(dev, "POST", "/api/ai/documents", note, nil); code != http.StatusNotFound {
+		t.Fatalf("deployer adding to an ungranted app: status %d, want 404", code)
+	}
+	note["app"] = ""
+	if code := ts.doAs(dev, "POST", "/api/ai/documents", note, nil); code != http.StatusForbidden {
+		t.Fatalf("deployer adding a shared note: status %d, want 403", code)
+	}
+
+	now := time.Now()
+	for _, d := range []app.AIDocument{
+		{ID: "doc-other", AppID: other.ID, Source: "log", Content: "other app log", Timestamp: now, CreatedAt: now},
+		{ID: "doc-granted", AppID: granted.ID, Source: "note", Content: "granted app note", Timestamp: now, CreatedAt: now},
+	} {
+		if err := ts.storage.SaveAIDocument(&d); err != nil {
+			t.Fatalf("SaveAIDocument: %v", err)
+		}
+	}
+
+	if code := ts.doAs(dev, "DELETE", "/api/ai/documents/doc-other", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("deployer deleting an ungranted app's document: status %d, want 404", code)
+	}
+	if doc, _ := ts.storage.GetAIDocument("doc-other"); doc == nil {
+		t.Fatal("the ungranted app's document was deleted")
+	}
+	if code := ts.doAs(dev, "DELETE", "/api/ai/documents/doc-granted", nil, nil); code != http.StatusOK {
+		t.Fatalf("deployer deleting a granted app's document: status %d, want 200", code)
+	}
+	if code := ts.do("DELETE", "/api/ai/documents/doc-other", nil, nil); code != http.StatusOK {
+		t.Fatalf("admin deleting a document: status %d, want 200", code)
+	}
+	if code := ts.do("DELETE", "/api/ai/documents/doc-other", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("deleting a missing document: status %d, want 404", code)
+	}
+}
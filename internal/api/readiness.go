@@ -4,15 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"time"
 
 	"github.com/base-go/basepod/internal/app"
 )
 
-const (
-	appReadyTimeout      = 60 * time.Second
-	appReadyPollInterval = 250 * time.Millisecond
-)
+const appReadyPollInterval = 250 * time.Millisecond
+
+// appReadyTimeout is how long a started container has to become ready
+var appReadyTimeout = 60 * time.Second
 
 func waitForLocalPort(ctx context.Context, port int) error {
 	if port <= 0 {
@@ -44,6 +45,40 @@ func waitForLocalPort(ctx context.Context, port int) error {
 	}
 }
 
+// waitForLocalHTTP polls path on a local port until it answers with a
+// status below 400
+func waitForLocalHTTP(ctx context.Context, port int, path string) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, path)
+	client := &http.Client{Timeout: 2 * time.Second}
+	ticker := time.NewTicker(appReadyPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 400 {
+				return nil
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s: %w", url, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForAppReadiness waits for an app's container to pass its health check
+// if it has an endpoint, else to accept connections on its port
 func (s *Server) waitForAppReadiness(ctx context.Context, a *app.App) error {
 	if a == nil || a.Ports.HostPort <= 0 {
 		return nil
@@ -52,5 +87,19 @@ func (s *Server) waitForAppReadiness(ctx context.Context, a *app.App) error {
 	readyCtx, cancel := context.WithTimeout(ctx, appReadyTimeout)
 	defer cancel()
 
-	return waitForLocalPort(readyCtx, a.Ports.HostPort)
+	var err error
+	if a.HealthCheck != nil && a.HealthCheck.Endpoint != "" {
+		err = waitForLocalHTTP(readyCtx, a.Ports.HostPort, a.HealthCheck.Endpoint)
+	} else {
+		err = waitForLocalPort(readyCtx, a.Ports.HostPort)
+	}
+	if err != nil {
+		// A container that died on startup says more than the timeout
+		if exit := s.inspectExit(context.Background(), a); exit != nil {
+			a.LastExit = exit
+			return fmt.Errorf("container stopped: %s", exit.Reason)
+		}
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/registry"
+)
+
+// pushBuild pushes a freshly built image to the built-in registry when it
+// is on and returns the registry reference, or "" when nothing was pushed.
+// A failed push only warns; the deploy goes on with the local image.
+func (s *Server) pushBuild(ctx context.Context, podmanPath string, a *app.App, image, gitCommit, deployTag string, writeLine func(string)) string {
+	if s.registry == nil || !s.registry.Enabled() {
+		return ""
+	}
+	ref, err := s.registry.Push(ctx, podmanPath, image, a.Name, registry.Tag(gitCommit, deployTag))
+	if err != nil {
+		writeLine("WARNING: Failed to push image to the registry: " + err.Error())
+		return ""
+	}
+	writeLine("Pushed image to the registry: " + ref)
+	return ref
+}
+
+// registryRefs returns the registry images each app's deployment history
+// still points at, keyed by app name
+func registryRefs(apps []app.App) map[string]map[string]bool {
+	refs := make(map[string]map[string]bool)
+	for _, a := range apps {
+		for _, d := range a.Deployments {
+			if d.RegistryImage == "" {
+				continue
+			}
+			if refs[a.Name] == nil {
+				refs[a.Name] = make(map[string]bool)
+			}
+			refs[a.Name][d.RegistryImage] = true
+		}
+	}
+	return refs
+}
+
+// handleGetRegistry lists the registry's app repositories and tags
+func (s *Server) handleGetRegistry(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{
+		"registry":     s.registry.Stats(),
+		"repositories": []registry.Repository{},
+	}
+	if s.registry.Running() {
+		repos, err := s.registry.Repositories(r.Context())
+		if err != nil {
+			errorResponse(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		resp["repositories"] = repos
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// handleDeleteRegistryTag removes one tag of an app's repository
+func (s *Server) handleDeleteRegistryTag(w http.ResponseWriter, r *http.Request) {
+	if !s.registry.Running() {
+		errorResponse(w, http.StatusServiceUnavailable, "Registry is not running; set podman.registry.enabled")
+		return
+	}
+	appName, tag := r.PathValue("app"), r.PathValue("tag")
+	if err := s.registry.DeleteTag(r.Context(), registry.Repo(appName), tag); err != nil {
+		if errors.Is(err, registry.ErrTagNotFound) {
+			errorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		errorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	s.logActivity("user", "registry_tag_delete", "app", "", appName, "success", registry.Repo(appName)+":"+tag)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleRegistryGC deletes the tags no deployment in an app's history uses,
+// including every tag of apps that no longer exist, then frees their layers.
+// With dry_run it only reports what would go.
+func (s *Server) handleRegistryGC(w http.ResponseWriter, r *http.Request) {
+	if !s.registry.Running() {
+		errorResponse(w, http.StatusServiceUnavailable, "Registry is not running; set podman.registry.enabled")
+		return
+	}
+	var req struct {
+		DryRun bool `json:"dry_run"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	ctx := r.Context()
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	repos, err := s.registry.Repositories(ctx)
+	if err != nil {
+		errorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	refs := registryRefs(apps)
+	deleted := []string{}
+	kept := 0
+	for _, repo := range repos {
+		for _, tag := range repo.Tags {
+			if refs[repo.App][s.registry.Ref(repo.App, tag)] {
+				kept++
+				continue
+			}
+			if !req.DryRun {
+				if err := s.registry.DeleteTag(ctx, repo.Name, tag); err != nil {
+					errorResponse(w, http.StatusBadGateway, err.Error())
+					return
+				}
+			}
+			deleted = append(deleted, repo.Name+":"+tag)
+		}
+	}
+
+	resp := map[string]interface{}{
+		"deleted": deleted,
+		"kept":    kept,
+		"dry_run": req.DryRun,
+	}
+	if !req.DryRun {
+		if _, err := s.registry.GarbageCollect(ctx); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.logActivity("user", "registry_gc", "system", "", "registry", "success", strings.Join(deleted, ", "))
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// maxReplicas caps how many containers one app runs
+const maxReplicas = 10
+
+// validateReplicas checks a replica count for an app
+func validateReplicas(a *app.App, replicas int) error {
+	if replicas < 1 || replicas > maxReplicas {
+		return fmt.Errorf("replicas must be between 1 and %d", maxReplicas)
+	}
+	if replicas > 1 && (a.Type == app.AppTypeMLX || a.Type == app.AppTypeStatic || a.RedirectURL != "") {
+		return fmt.Errorf("replicas are only supported for container apps")
+	}
+	return nil
+}
+
+// replicaCount is how many containers an app runs. Replica 1 is the app's
+// own basepod-<name> container; the others are its replicas.
+func replicaCount(a *app.App) int {
+	if a.Type == app.AppTypeMLX || a.Type == app.AppTypeStatic || a.RedirectURL != "" || a.Resources.Replicas < 1 {
+		return 1
+	}
+	return min(a.Resources.Replicas, maxReplicas)
+}
+
+// replicaContainerName is the container for an app's replica i (2 and up)
+func replicaContainerName(appName string, i int) string {
+	return fmt.Sprintf("basepod-%s-replica-%d", appName, i)
+}
+
+// replicaPort is the host port for an app's replica i. Unlike the app's own
+// container it keeps its port across deploys, so routes only change when the
+// app is scaled.
+func replicaPort(appID string, i int) int {
+	return assignHostPort(fmt.Sprintf("%s#%d", appID, i))
+}
+
+// replicaUpstreams lists the upstreams of an app's replicas, balanced with the
+// app's own port in its routes
+func replicaUpstreams(a *app.App) []string {
+	var upstreams []string
+	for i := 2; i <= replicaCount(a); i++ {
+		upstreams = append(upstreams, fmt.Sprintf("localhost:%d", replicaPort(a.ID, i)))
+	}
+	return upstreams
+}
+
+// syncReplicas brings an app's replicas in line with its replica count:
+// replicas beyond it are removed and missing or stopped ones started from the
+// app's current image and settings. With recreate every replica is replaced,
+// e.g. after a deploy. Replicas are replaced one at a time so the others keep
+// serving, and share the app's volumes.
+func (s *Server) syncReplicas(ctx context.Context, a *app.App, recreate bool) error {
+	if s.podman == nil {
+		return nil
+	}
+	want := replicaCount(a)
+
+	containers, err := s.podman.ListContainers(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+	running := map[int]bool{}
+	for _, c := range containers {
+		if c.Labels["basepod.app.id"] != a.ID {
+			continue
+		}
+		i, err := strconv.Atoi(c.Labels["basepod.replica"])
+		if err != nil {
+			continue // The app's own container
+		}
+		if i > want {
+			_ = s.podman.StopContainer(ctx, c.ID, 10)
+			_ = s.podman.RemoveContainer(ctx, c.ID, true)
+			continue
+		}
+		running[i] = c.State == "running"
+	}
+	if want < 2 || a.Image == "" {
+		return nil
+	}
+
+	volumeMounts := s.appVolumeMounts(ctx, a)
+	var failed []string
+	for i := 2; i <= want; i++ {
+		if running[i] && !recreate {
+			continue
+		}
+		if err := s.startReplica(ctx, a, i, volumeMounts); err != nil {
+			log.Printf("Replica %d of %s: %v", i, a.Name, err)
+			failed = append(failed, fmt.Sprintf("replica %d: %v", i, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// appVolumeMounts lists an app's volumes as podman mounts, creating named
+// volumes on first use, for the app's container, its replicas and jobs
+func (s *Server) appVolumeMounts(ctx context.Context, a *app.App) []string {
+	volumeMounts := []string{}
+	for _, v := range a.Volumes {
+		var mount string
+		if v.HostPath != "" && v.ContainerPath != "" {
+			mount = fmt.Sprintf("%s:%s", v.HostPath, v.ContainerPath)
+		} else if v.Name != "" && v.ContainerPath != "" {
+			mount = fmt.Sprintf("%s:%s", s.ensureAppVolume(ctx, a, v, ""), v.ContainerPath)
+		} else {
+			continue
+		}
+		if v.ReadOnly {
+			mount += ":ro"
+		}
+		volumeMounts = append(volumeMounts, mount)
+	}
+	return volumeMounts
+}
+
+// startReplica replaces an app's replica i and waits for it to accept
+// connections
+func (s *Server) startReplica(ctx context.Context, a *app.App, i int, volumeMounts []string) error {
+	name := replicaContainerName(a.Name, i)
+	_ = s.podman.StopContainer(ctx, name, 10)
+	_ = s.podman.RemoveContainer(ctx, name, true)
+
+	port := replicaPort(a.ID, i)
+	containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
+		Name:     name,
+		Image:    a.Image,
+		Env:      s.containerEnv(a),
+		Networks: []string{"basepod"},
+		Volumes:  volumeMounts,
+		Ports: map[string]string{
+			fmt.Sprintf("%d", a.Ports.ContainerPort): fmt.Sprintf("%d", port),
+		},
+		Labels: map[string]string{
+			"basepod.app":     a.Name,
+			"basepod.app.id":  a.ID,
+			"basepod.replica": strconv.Itoa(i),
+		},
+		Memory: a.Resources.MemoryBytes(),
+		CPUs:   a.Resources.CPUs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create container: %w", err)
+	}
+	if err := s.podman.StartContainer(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	readyCtx, cancel := context.WithTimeout(ctx, appReadyTimeout)
+	defer cancel()
+	return waitForLocalPort(readyCtx, port)
+}
+
+// removeReplicas stops and removes all of an app's replicas
+func (s *Server) removeReplicas(ctx context.Context, a *app.App) {
+	if s.podman == nil {
+		return
+	}
+	containers, err := s.podman.ListContainers(ctx, true)
+	if err != nil {
+		return
+	}
+	for _, c := range containers {
+		if c.Labels["basepod.app.id"] == a.ID && c.Labels["basepod.replica"] != "" {
+			_ = s.podman.StopContainer(ctx, c.ID, 10)
+			_ = s.podman.RemoveContainer(ctx, c.ID, true)
+		}
+	}
+}
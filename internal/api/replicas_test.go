@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+)
+
+func TestReplicaCount(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		a    app.App
+		want int
+	}{
+		{"unset", app.App{Type: app.AppTypeContainer}, 1},
+		{"three", app.App{Type: app.AppTypeContainer, Resources: app.ResourceConfig{Replicas: 3}}, 3},
+		{"capped", app.App{Type: app.AppTypeContainer, Resources: app.ResourceConfig{Replicas: 50}}, maxReplicas},
+		{"static", app.App{Type: app.AppTypeStatic, Resources: app.ResourceConfig{Replicas: 3}}, 1},
+		{"redirect", app.App{Type: app.AppTypeContainer, RedirectURL: "https://example.com", Resources: app.ResourceConfig{Replicas: 3}}, 1},
+	}
+	for _, c := range cases {
+		if got := replicaCount(&c.a); got != c.want {
+			t.Fatalf("%s: replicaCount = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestValidateReplicas(t *testing.T) {
+	t.Parallel()
+
+	container := &app.App{Type: app.AppTypeContainer}
+	static := &app.App{Type: app.AppTypeStatic}
+	if err := validateReplicas(container, 3); err != nil {
+		t.Fatalf("validateReplicas(3) error = %v", err)
+	}
+	if err := validateReplicas(container, 0); err == nil {
+		t.Fatalf("validateReplicas(0) should fail")
+	}
+	if err := validateReplicas(container, maxReplicas+1); err == nil {
+		t.Fatalf("validateReplicas(%d) should fail", maxReplicas+1)
+	}
+	if err := validateReplicas(static, 1); err != nil {
+		t.Fatalf("validateReplicas(static, 1) error = %v", err)
+	}
+	if err := validateReplicas(static, 2); err == nil {
+		t.Fatalf("validateReplicas(static, 2) should fail")
+	}
+}
+
+func TestReplicaUpstreams(t *testing.T) {
+	t.Parallel()
+
+	a := &app.App{ID: "0f8c2e9a-replicas", Type: app.AppTypeContainer, Resources: app.ResourceConfig{Replicas: 4}}
+	a.Ports.HostPort = assignHostPort(a.ID)
+
+	upstreams := replicaUpstreams(a)
+	if len(upstreams) != 3 {
+		t.Fatalf("replicaUpstreams = %v, want 3 upstreams", upstreams)
+	}
+	seen := map[int]bool{a.Ports.HostPort: true, drainPartnerPort(a.ID, a.Ports.HostPort): true}
+	for i := 2; i <= 4; i++ {
+		port := replicaPort(a.ID, i)
+		if seen[port] {
+			t.Fatalf("replica %d port %d is already in use by the app", i, port)
+		}
+		seen[port] = true
+	}
+
+	a.Resources.Replicas = 1
+	if upstreams := replicaUpstreams(a); len(upstreams) != 0 {
+		t.Fatalf("replicaUpstreams with 1 replica = %v, want none", upstreams)
+	}
+}
+
+func TestAddRouteLoadBalancesReplicas(t *testing.T) {
+	t.Parallel()
+
+	var route map[string]interface{}
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &route)
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer admin.Close()
+
+	err := caddy.NewClient(admin.URL).AddRoute(caddy.Route{
+		ID:        "basepod-web",
+		Domain:    "web.example.com",
+		Upstream:  "localhost:10001",
+		Upstreams: []string{"localhost:10002", "localhost:10003"},
+	})
+	if err != nil {
+		t.Fatalf("AddRoute error = %v", err)
+	}
+
+	handle := route["handle"].([]interface{})
+	proxy := handle[0].(map[string]interface{})
+	if upstreams := proxy["upstreams"].([]interface{}); len(upstreams) != 3 {
+		t.Fatalf("upstreams = %v, want 3", upstreams)
+	}
+	lb, ok := proxy["load_balancing"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("proxy has no load_balancing: %v", proxy)
+	}
+	if policy := lb["selection_policy"].(map[string]interface{})["policy"]; policy != "round_robin" {
+		t.Fatalf("selection policy = %v, want round_robin", policy)
+	}
+}
@@ -0,0 +1,18 @@
+package api
+
+import "fmt"
+
+// minMemoryMB is the smallest memory limit Podman accepts
+const minMemoryMB = 6
+
+// validateResources checks an app's memory (MB) and CPU limits; zero means
+// no limit
+func validateResources(memoryMB int64, cpus float64) error {
+	if memoryMB < 0 || (memoryMB > 0 && memoryMB < minMemoryMB) {
+		return fmt.Errorf("memory must be 0 (no limit) or at least %d MB", minMemoryMB)
+	}
+	if cpus < 0 || cpus > 1024 {
+		return fmt.Errorf("cpus must be 0 (no limit) or a number of cores like 0.5")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestValidateResources(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		memory  int64
+		cpus    float64
+		wantErr bool
+	}{
+		{0, 0, false},
+		{512, 0.5, false},
+		{6, 16, false},
+		{5, 0, true},
+		{-1, 0, true},
+		{0, -0.5, true},
+	}
+	for _, c := range cases {
+		if err := validateResources(c.memory, c.cpus); (err != nil) != c.wantErr {
+			t.Fatalf("validateResources(%d, %v) error = %v, wantErr %v", c.memory, c.cpus, err, c.wantErr)
+		}
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"512m", 512, false},
+		{"512M", 512, false},
+		{"512mb", 512, false},
+		{"1g", 1024, false},
+		{"1.5G", 1536, false},
+		{"256", 256, false},
+		{"0", 0, false},
+		{"", 0, false},
+		{"lots", 0, true},
+		{"-1g", 0, true},
+	}
+	for _, c := range cases {
+		got, err := app.ParseMemory(c.in)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Fatalf("ParseMemory(%q) = %d, %v; want %d, wantErr %v", c.in, got, err, c.want, c.wantErr)
+		}
+	}
+
+	if got := (app.ResourceConfig{Memory: 512}).MemoryBytes(); got != 512<<20 {
+		t.Fatalf("MemoryBytes = %d, want %d", got, 512<<20)
+	}
+}
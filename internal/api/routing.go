@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/auth"
+	"github.com/base-go/basepod/internal/caddy"
+)
+
+// appRouteSnippets returns the extra routing rules for an app's routes
+func appRouteSnippets(a *app.App) caddy.Snippets {
+	var s caddy.Snippets
+	if a.BasicAuth != nil {
+		s.BasicAuth = append(s.BasicAuth, caddy.BasicAuth{Username: a.BasicAuth.Username, PasswordHash: a.BasicAuth.PasswordHash})
+	}
+	if r := a.Routing; r != nil {
+		s.Gzip = r.Gzip
+		for _, allow := range r.IPAllowlist {
+			s.IPAllowlist = append(s.IPAllowlist, caddy.IPAllow(allow))
+		}
+		for _, b := range r.BasicAuth {
+			s.BasicAuth = append(s.BasicAuth, caddy.BasicAuth{Path: b.Path, Username: b.Username, PasswordHash: b.PasswordHash})
+		}
+		for _, rd := range r.Redirects {
+			s.Redirects = append(s.Redirects, caddy.Redirect(rd))
+		}
+		for _, h := range r.Headers {
+			s.Headers = append(s.Headers, caddy.PathHeaders(h))
+		}
+	}
+	// A static site's own redirects and headers come after the routing ones
+	if st := a.Static; st != nil && a.Type == app.AppTypeStatic {
+		for _, rd := range st.Redirects {
+			s.Redirects = append(s.Redirects, caddy.Redirect(rd))
+		}
+		for _, h := range st.Headers {
+			s.Headers = append(s.Headers, caddy.PathHeaders(h))
+		}
+	}
+	return s
+}
+
+// AppRoute returns the Caddy route of a running container app on domain, its
+// own or one of its aliases
+func AppRoute(a *app.App, domain string) caddy.Route {
+	id := "basepod-" + a.Name
+	if domain != a.Domain {
+		id = fmt.Sprintf("alias-%s-%s", a.ID[:8], domain)
+	}
+	return caddy.Route{
+		ID:        id,
+		Domain:    domain,
+		Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
+		Upstreams: appUpstreams(a),
+		Weights:   upstreamWeights(a),
+		EnableSSL: a.SSL.Enabled,
+		SEO:       appRouteSEO(a, domain),
+		Headers:   a.ResponseHeaders(),
+		Snippets:  appRouteSnippets(a),
+		Protocol:  a.Ports.Protocol,
+		Streams:   appRouteStreams(a),
+	}
+}
+
+// AddStaticAppRoute adds the Caddy route serving a static site's files from
+// dir on domain, its own or one of its aliases
+func AddStaticAppRoute(c *caddy.Client, a *app.App, domain, dir string) error {
+	return c.AddStaticRoute(domain, dir, appRouteSEO(a, domain), a.ResponseHeaders(), appRouteSnippets(a), appStaticSite(a))
+}
+
+// validRoutePath checks a rule's path matcher: empty (every path) or
+// starting with /
+func validRoutePath(path string) error {
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("path %q must start with /", path)
+	}
+	return nil
+}
+
+// prepareRouting checks an app's routing rules and hashes any plain basic
+// auth passwords, so they're never stored
+func prepareRouting(r *app.RoutingConfig) error {
+	for i := range r.Redirects {
+		rd := &r.Redirects[i]
+		if rd.From == "" || rd.To == "" {
+			return fmt.Errorf("redirects need a from path and a to URL")
+		}
+		if err := validRoutePath(rd.From); err != nil {
+			return fmt.Errorf("redirect: %w", err)
+		}
+		switch rd.Status {
+		case 0:
+			rd.Status = http.StatusMovedPermanently
+		case 301, 302, 303, 307, 308:
+		default:
+			return fmt.Errorf("redirect from %s: status must be 301, 302, 303, 307 or 308", rd.From)
+		}
+	}
+	for _, h := range r.Headers {
+		if err := validRoutePath(h.Path); err != nil {
+			return fmt.Errorf("headers: %w", err)
+		}
+		if len(h.Set) == 0 {
+			return fmt.Errorf("headers for %q set nothing", h.Path)
+		}
+		for name := range h.Set {
+			if name == "" || strings.ContainsAny(name, " :\t\r\n") {
+				return fmt.Errorf("invalid header name %q", name)
+			}
+		}
+	}
+	for i := range r.BasicAuth {
+		b := &r.BasicAuth[i]
+		if err := validRoutePath(b.Path); err != nil {
+			return fmt.Errorf("basic_auth: %w", err)
+		}
+		if b.Username == "" {
+			return fmt.Errorf("basic_auth needs a username")
+		}
+		if b.Password != "" {
+			hash, err := auth.HashPassword(b.Password)
+			if err != nil {
+				return err
+			}
+			b.PasswordHash = hash
+			b.Password = ""
+		}
+		if !strings.HasPrefix(b.PasswordHash, "$2") {
+			return fmt.Errorf("basic_auth for %s needs a password or a bcrypt password_hash", b.Username)
+		}
+	}
+	for _, allow := range r.IPAllowlist {
+		if err := validRoutePath(allow.Path); err != nil {
+			return fmt.Errorf("ip_allowlist: %w", err)
+		}
+		if len(allow.IPs) == 0 {
+			return fmt.Errorf("ip_allowlist for %q lists no addresses", allow.Path)
+		}
+		for _, ip := range allow.IPs {
+			if _, err := netip.ParsePrefix(ip); err == nil {
+				continue
+			}
+			if _, err := netip.ParseAddr(ip); err != nil {
+				return fmt.Errorf("ip_allowlist: %q is not an IP address or CIDR range", ip)
+			}
+		}
+	}
+	return nil
+}
+
+// keepRoutingPasswords fills in the stored hash of each basic auth rule sent
+// without a password, so rules read from the API, which leaves hashes out,
+// can be sent back unchanged
+func keepRoutingPasswords(r, existing *app.RoutingConfig) {
+	if existing == nil {
+		return
+	}
+	for i := range r.BasicAuth {
+		b := &r.BasicAuth[i]
+		if b.Password != "" || b.PasswordHash != "" {
+			continue
+		}
+		for _, old := range existing.BasicAuth {
+			if old.Path == b.Path && old.Username == b.Username {
+				b.PasswordHash = old.PasswordHash
+				break
+			}
+		}
+	}
+}
+
+// handleGetRouting returns an app's extra routing rules
+func (s *Server) handleGetRouting(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	routing := a.Routing
+	if routing == nil {
+		routing = &app.RoutingConfig{}
+	}
+	jsonResponse(w, http.StatusOK, redactRouting(routing))
+}
+
+// handleUpdateRouting replaces an app's extra routing rules, as a deploy with
+// a routing: block in basepod.yaml does
+func (s *Server) handleUpdateRouting(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var routing app.RoutingConfig
+	if err := json.NewDecoder(r.Body).Decode(&routing); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	keepRoutingPasswords(&routing, a.Routing)
+	if err := prepareRouting(&routing); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.Routing = &routing
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.refreshAppRoutes(a)
+	s.logActivity("user", "update_routing", "app", a.ID, a.Name, "success", "")
+	jsonResponse(w, http.StatusOK, redactRouting(a.Routing))
+}
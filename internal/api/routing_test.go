@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/auth"
+)
+
+func TestPrepareRouting(t *testing.T) {
+	t.Parallel()
+
+	r := app.RoutingConfig{
+		Redirects:   []app.RedirectRule{{From: "/old", To: "https://example.com/new"}},
+		BasicAuth:   []app.BasicAuthRule{{Path: "/admin/*", Username: "ops", Password: "hunter2"}},
+		IPAllowlist: []app.IPAllowRule{{Path: "/admin/*", IPs: []string{"10.0.0.0/8", "203.0.113.7", "::1"}}},
+	}
+	if err := prepareRouting(&r); err != nil {
+		t.Fatalf("prepareRouting: %v", err)
+	}
+	if r.Redirects[0].Status != http.StatusMovedPermanently {
+		t.Fatalf("redirect status = %d, want 301 by default", r.Redirects[0].Status)
+	}
+	b := r.BasicAuth[0]
+	if b.Password != "" || !auth.CheckPassword(b.PasswordHash, "hunter2") {
+		t.Fatalf("basic auth = %+v, want only a hash of the password kept", b)
+	}
+
+	for _, bad := range []app.RoutingConfig{
+		{Redirects: []app.RedirectRule{{From: "old", To: "/new"}}},
+		{Redirects: []app.RedirectRule{{From: "/old", To: "/new", Status: 200}}},
+		{Headers: []app.HeaderRule{{Path: "/assets/*"}}},
+		{Headers: []app.HeaderRule{{Set: map[string]string{"Bad Name": "x"}}}},
+		{BasicAuth: []app.BasicAuthRule{{Username: "ops"}}},
+		{BasicAuth: []app.BasicAuthRule{{Password: "hunter2"}}},
+		{IPAllowlist: []app.IPAllowRule{{IPs: []string{"10.0.0.300"}}}},
+		{IPAllowlist: []app.IPAllowRule{{Path: "/admin"}}},
+	} {
+		if err := prepareRouting(&bad); err == nil {
+			t.Fatalf("prepareRouting(%+v) = nil error", bad)
+		}
+	}
+}
+
+func TestAppRoute(t *testing.T) {
+	t.Parallel()
+	a := &app.App{
+		ID:      "0123456789abcdef",
+		Name:    "web",
+		Domain:  "web.example.com",
+		Aliases: []string{"www.example.com"},
+		Ports:   app.PortConfig{HostPort: 8100},
+		Canary:  &app.Canary{HostPort: 8200, Weight: 10},
+	}
+
+	r := AppRoute(a, a.Domain)
+	if r.ID != "basepod-web" || r.Domain != a.Domain || r.Upstream != "localhost:8100" {
+		t.Fatalf("route = %+v, want basepod-web for %s on localhost:8100", r, a.Domain)
+	}
+	if len(r.Upstreams) != 1 || r.Upstreams[0] != "localhost:8200" || len(r.Weights) != 2 || r.Weights[1] != 10 {
+		t.Fatalf("route upstreams = %v weights = %v, want the canary at 10%%", r.Upstreams, r.Weights)
+	}
+	if r := AppRoute(a, "www.example.com"); r.ID != "alias-01234567-www.example.com" || r.Domain != "www.example.com" {
+		t.Fatalf("alias route = %+v", r)
+	}
+}
+
+func TestUpdateRouting(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "routing-web", Domain: "routing-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	ts.waitForRoute("basepod-routing-web")
+
+	routing := app.RoutingConfig{
+		BasicAuth: []app.BasicAuthRule{{Username: "staging", Password: "s3cret"}},
+		Gzip:      true,
+	}
+	if code := ts.do("PUT", "/api/apps/routing-web/routing", routing, nil); code != http.StatusOK {
+		t.Fatalf("update routing: status %d", code)
+	}
+	stored, _ := ts.storage.GetApp(a.ID)
+	if len(stored.Routing.BasicAuth) != 1 || stored.Routing.BasicAuth[0].Password != "" || !auth.CheckPassword(stored.Routing.BasicAuth[0].PasswordHash, "s3cret") {
+		t.Fatalf("stored routing = %+v, want the password stored hashed", stored.Routing)
+	}
+	for _, path := range []string{"/api/apps/" + a.ID, "/api/apps", "/api/apps/routing-web/routing"} {
+		if body := ts.get(path); strings.Contains(body, "password_hash") || strings.Contains(body, stored.Routing.BasicAuth[0].PasswordHash) {
+			t.Fatalf("GET %s = %s, want no password hash", path, body)
+		}
+	}
+
+	// Sending the routing back as returned keeps the stored password
+	var saved app.RoutingConfig
+	ts.do("GET", "/api/apps/routing-web/routing", nil, &saved)
+	if code := ts.do("PUT", "/api/apps/routing-web/routing", saved, nil); code != http.StatusOK {
+		t.Fatalf("resend routing: status %d", code)
+	}
+	stored, _ = ts.storage.GetApp(a.ID)
+	if len(stored.Routing.BasicAuth) != 1 || !auth.CheckPassword(stored.Routing.BasicAuth[0].PasswordHash, "s3cret") {
+		t.Fatalf("routing after resending = %+v, want the password kept", stored.Routing)
+	}
+
+	route, _ := json.Marshal(ts.caddyRoute("basepod-routing-web"))
+	if !strings.Contains(string(route), `"authentication"`) || !strings.Contains(string(route), `"encode"`) {
+		t.Fatalf("route = %s, want basic auth and compression", route)
+	}
+
+	if code := ts.do("PUT", "/api/apps/routing-web/routing", app.RoutingConfig{Redirects: []app.RedirectRule{{From: "nope", To: "/"}}}, nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid routing: status %d, want 400", code)
+	}
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
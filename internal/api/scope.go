@@ -0,0 +1,158 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/base-go/basepod/internal/ai"
+	"github.com/base-go/basepod/internal/app"
+)
+
+// appScope is the set of app IDs a caller is limited to. A nil scope means
+// the caller can see every app.
+type appScope map[string]bool
+
+// allows reports whether an app is within the scope
+func (sc appScope) allows(appID string) bool {
+	return sc == nil || sc[appID]
+}
+
+// ids returns the app IDs in the scope, or nil when it is unlimited
+func (sc appScope) ids() []string {
+	if sc == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(sc))
+	for id := range sc {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// filterApps keeps the apps within the scope
+func (sc appScope) filterApps(apps []app.App) []app.App {
+	if sc == nil {
+		return apps
+	}
+	visible := []app.App{}
+	for _, a := range apps {
+		if sc[a.ID] {
+			visible = append(visible, a)
+		}
+	}
+	return visible
+}
+
+// userScope returns the apps a user with role is limited to. Admins see
+// everything and deployers only the apps granted to them. Viewers with app
+// grants are client logins and see only those apps; viewers without grants
+// keep read-only access to every app.
+func (s *Server) userScope(userID, role string) (appScope, error) {
+	if role == "admin" || role == "" || userID == "" {
+		return nil, nil
+	}
+	granted, err := s.storage.GetUserAppAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "viewer" && len(granted) == 0 {
+		return nil, nil
+	}
+	sc := appScope{}
+	for _, id := range granted {
+		sc[id] = true
+	}
+	return sc, nil
+}
+
+// tokenScope returns the apps a deploy token is limited to. Tokens made by an
+// admin are unlimited; tokens made by other users only reach the apps named in
+// their deploy: scopes that the user can still access.
+func (s *Server) tokenScope(dt *app.DeployToken) (appScope, error) {
+	if dt.CreatedBy == "" {
+		return nil, nil
+	}
+	user, err := s.storage.GetUserByID(dt.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	sc := appScope{}
+	if user == nil {
+		return sc, nil
+	}
+	owner, err := s.userScope(user.ID, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range dt.Scopes {
+		name, ok := strings.CutPrefix(strings.TrimSpace(scope), "deploy:")
+		if !ok || name == "*" {
+			continue
+		}
+		if a, _ := s.resolveApp(name); a != nil && owner.allows(a.ID) {
+			sc[a.ID] = true
+		}
+	}
+	return sc, nil
+}
+
+// callerScope returns the apps the caller of r is limited to
+func (s *Server) callerScope(r *http.Request) (appScope, error) {
+	if dt := getDeployTokenFromCtx(r); dt != nil {
+		return s.tokenScope(dt)
+	}
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		return nil, nil
+	}
+	return s.userScope(session.UserID, session.UserRole)
+}
+
+// aiCaller describes the caller of r to the assistant, including the apps
+// it is limited to
+func (s *Server) aiCaller(r *http.Request) (*ai.Caller, error) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		return nil, err
+	}
+	caller := &ai.Caller{Apps: sc}
+	if session := s.auth.GetSession(s.getSessionToken(r)); session != nil {
+		caller.UserID = session.UserID
+		caller.UserRole = session.UserRole
+	}
+	if caller.UserRole == "" && sc == nil {
+		return nil, nil
+	}
+	return caller, nil
+}
+
+// grantCreatorAccess gives a caller limited to some apps access to an app
+// they just created, so it doesn't vanish from their view
+func (s *Server) grantCreatorAccess(r *http.Request, appID string) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil || session.UserRole == "admin" || session.UserRole == "" || session.UserID == "" {
+		return
+	}
+	if err := s.storage.GrantUserAppAccess(session.UserID, appID); err != nil {
+		log.Printf("Warning: failed to grant %s access to new app %s: %v", session.UserID, appID, err)
+	}
+}
+
+// requireUnscoped limits server-wide endpoints to callers who can see every app
+func (s *Server) requireUnscoped(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sc, err := s.callerScope(r)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+			return
+		}
+		if sc != nil {
+			errorResponse(w, http.StatusForbidden, "Not available to accounts limited to specific apps")
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestAppScope(t *testing.T) {
+	t.Parallel()
+
+	apps := []app.App{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	var all appScope
+	if !all.allows("anything") || all.ids() != nil || len(all.filterApps(apps)) != 3 {
+		t.Fatalf("nil scope should allow every app")
+	}
+
+	sc := appScope{"c": true, "a": true}
+	if !sc.allows("a") || sc.allows("b") {
+		t.Fatalf("scope allows wrong apps")
+	}
+	if ids := sc.ids(); len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Fatalf("ids = %v, want [a c]", ids)
+	}
+	visible := sc.filterApps(apps)
+	if len(visible) != 2 || visible[0].ID != "a" || visible[1].ID != "c" {
+		t.Fatalf("filterApps = %v, want a and c", visible)
+	}
+
+	none := appScope{}
+	if none.allows("a") || none.ids() == nil || len(none.filterApps(apps)) != 0 {
+		t.Fatalf("empty scope should allow no apps")
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// containerEnv is the environment a new container for a gets: its env vars
+// plus its decrypted secrets, which win on a name clash
+func (s *Server) containerEnv(a *app.App) map[string]string {
+	secrets, err := s.storage.SecretValues(a.ID)
+	if err != nil {
+		log.Printf("Warning: failed to load secrets for %s: %v", a.Name, err)
+	}
+	if len(secrets) == 0 {
+		return a.Env
+	}
+	env := make(map[string]string, len(a.Env)+len(secrets))
+	for k, v := range a.Env {
+		env[k] = v
+	}
+	for k, v := range secrets {
+		env[k] = v
+	}
+	return env
+}
+
+// handleListSecrets lists an app's secret names; values are never returned
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	secrets, err := s.storage.ListSecrets(a.ID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if secrets == nil {
+		secrets = []app.SecretInfo{}
+	}
+	jsonResponse(w, http.StatusOK, secrets)
+}
+
+// handleSetSecret stores an app secret encrypted and, if asked, replaces the
+// running container so it sees the new value
+func (s *Server) handleSetSecret(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	name := r.PathValue("key")
+	if !envKeyPattern.MatchString(name) {
+		errorResponse(w, http.StatusBadRequest, "Invalid secret name (letters, digits and _ only)")
+		return
+	}
+	var req struct {
+		Value   string `json:"value"`
+		Restart bool   `json:"restart"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Value) > 64<<10 {
+		errorResponse(w, http.StatusBadRequest, "Secret is too large (max 64KB)")
+		return
+	}
+
+	if err := s.storage.SetSecret(a.ID, name, req.Value); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logActivity("user", "secret_set", "app", a.ID, a.Name, "success", name)
+	s.finishSecretChange(w, r, a, req.Restart)
+}
+
+// handleDeleteSecret removes an app secret
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	name := r.PathValue("key")
+	found, err := s.storage.DeleteSecret(a.ID, name)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		errorResponse(w, http.StatusNotFound, "Secret not found")
+		return
+	}
+	s.logActivity("user", "secret_unset", "app", a.ID, a.Name, "success", name)
+	s.finishSecretChange(w, r, a, r.URL.Query().Get("restart") == "true")
+}
+
+// finishSecretChange restarts the app if asked and it is running, then
+// reports whether it did
+func (s *Server) finishSecretChange(w http.ResponseWriter, r *http.Request, a *app.App, restart bool) {
+	restarted := false
+	if restart && a.Type != app.AppTypeStatic && a.Type != app.AppTypeMLX && a.Status == app.StatusRunning && a.Image != "" {
+		if err := s.rollAppContainer(r.Context(), a); err != nil {
+			s.logActivity("user", "restart", "app", a.ID, a.Name, "failed", err.Error())
+			errorResponse(w, http.StatusBadGateway, "Secrets updated, but the restart failed: "+err.Error())
+			return
+		}
+		s.logActivity("user", "restart", "app", a.ID, a.Name, "success", "secrets changed")
+		restarted = true
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"restarted": restarted})
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// coreSecurityHeaders are the headers the missing-headers report checks for
+var coreSecurityHeaders = []string{
+	"Strict-Transport-Security",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Content-Security-Policy",
+}
+
+// validateSecurityHeaders checks an app's security header settings
+func validateSecurityHeaders(c *app.SecurityHeadersConfig) error {
+	if !app.IsSecurityPreset(c.Preset) {
+		return fmt.Errorf("preset must be strict, balanced or off")
+	}
+	if strings.ContainsAny(c.CSP, "\r\n") {
+		return fmt.Errorf("csp must be a single line")
+	}
+	for name, value := range c.Overrides {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %s must be a single line", name)
+		}
+	}
+	return nil
+}
+
+// missingSecurityHeaders lists the core security headers an app's responses
+// don't get
+func missingSecurityHeaders(a *app.App) []string {
+	headers := a.ResponseHeaders()
+	missing := []string{}
+	for _, name := range coreSecurityHeaders {
+		if headers[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// securityHeadersReport is an app's security header settings and what they
+// add up to
+type securityHeadersReport struct {
+	AppID     string            `json:"app_id"`
+	AppName   string            `json:"app_name"`
+	Domain    string            `json:"domain"`
+	Preset    string            `json:"preset"`
+	CSP       string            `json:"csp,omitempty"`
+	Overrides map[string]string `json:"overrides,omitempty"`
+	Headers   map[string]string `json:"headers"`
+	Missing   []string          `json:"missing"`
+}
+
+func newSecurityHeadersReport(a *app.App) securityHeadersReport {
+	report := securityHeadersReport{
+		AppID:   a.ID,
+		AppName: a.Name,
+		Domain:  a.Domain,
+		Preset:  app.SecurityPresetOff,
+		Headers: a.ResponseHeaders(),
+		Missing: missingSecurityHeaders(a),
+	}
+	if a.SecurityHeaders != nil {
+		report.Preset = a.SecurityHeaders.Preset
+		report.CSP = a.SecurityHeaders.CSP
+		report.Overrides = map[string]string{}
+		for name, value := range a.SecurityHeaders.Overrides {
+			report.Overrides[textproto.CanonicalMIMEHeaderKey(name)] = value
+		}
+	}
+	return report
+}
+
+// handleGetSecurityHeaders returns an app's security header settings and the
+// headers its responses get
+func (s *Server) handleGetSecurityHeaders(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	jsonResponse(w, http.StatusOK, newSecurityHeadersReport(a))
+}
+
+// handleSecurityHeadersReport lists the apps the caller can see whose
+// responses are missing core security headers
+func (s *Server) handleSecurityHeadersReport(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.callerScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check app access")
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reports := []securityHeadersReport{}
+	for _, a := range sc.filterApps(apps) {
+		// Only apps served on a domain send headers
+		if a.Domain == "" || a.RedirectURL != "" {
+			continue
+		}
+		if report := newSecurityHeadersReport(&a); len(report.Missing) > 0 {
+			reports = append(reports, report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].AppName < reports[j].AppName })
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"apps": reports})
+}
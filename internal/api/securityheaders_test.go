@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		config  *app.SecurityHeadersConfig
+		header  string
+		want    string
+		missing int
+	}{
+		{"not configured", nil, "Strict-Transport-Security", "", 4},
+		{"balanced", &app.SecurityHeadersConfig{Preset: "balanced"}, "Referrer-Policy", "strict-origin-when-cross-origin", 1},
+		{"balanced with csp", &app.SecurityHeadersConfig{Preset: "balanced", CSP: "default-src 'self'"}, "Content-Security-Policy", "default-src 'self'", 0},
+		{"strict", &app.SecurityHeadersConfig{Preset: "strict"}, "X-Frame-Options", "DENY", 0},
+		{"override", &app.SecurityHeadersConfig{Preset: "strict", Overrides: map[string]string{"x-frame-options": "SAMEORIGIN"}}, "X-Frame-Options", "SAMEORIGIN", 0},
+		{"override removes", &app.SecurityHeadersConfig{Preset: "strict", Overrides: map[string]string{"Strict-Transport-Security": ""}}, "Strict-Transport-Security", "", 1},
+		{"off with override", &app.SecurityHeadersConfig{Preset: "off", Overrides: map[string]string{"Referrer-Policy": "no-referrer"}}, "Referrer-Policy", "no-referrer", 3},
+	}
+	for _, c := range cases {
+		a := &app.App{SecurityHeaders: c.config}
+		if got := a.ResponseHeaders()[c.header]; got != c.want {
+			t.Fatalf("%s: %s = %q, want %q", c.name, c.header, got, c.want)
+		}
+		if missing := missingSecurityHeaders(a); len(missing) != c.missing {
+			t.Fatalf("%s: missing = %v, want %d headers", c.name, missing, c.missing)
+		}
+	}
+}
+
+func TestValidateSecurityHeaders(t *testing.T) {
+	t.Parallel()
+
+	valid := []*app.SecurityHeadersConfig{
+		{Preset: "strict"},
+		{Preset: "off", Overrides: map[string]string{"X-Frame-Options": "DENY"}},
+	}
+	for _, c := range valid {
+		if err := validateSecurityHeaders(c); err != nil {
+			t.Fatalf("validateSecurityHeaders(%+v) = %v", c, err)
+		}
+	}
+	invalid := []*app.SecurityHeadersConfig{
+		{},
+		{Preset: "paranoid"},
+		{Preset: "balanced", CSP: "default-src 'self'\r\nX-Evil: 1"},
+		{Preset: "balanced", Overrides: map[string]string{"Bad Name": "1"}},
+	}
+	for _, c := range invalid {
+		if err := validateSecurityHeaders(c); err == nil {
+			t.Fatalf("validateSecurityHeaders(%+v) accepted", c)
+		}
+	}
+}
+
+func TestAddRouteSetsHeaders(t *testing.T) {
+	t.Parallel()
+
+	var route map[string]interface{}
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &route)
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer admin.Close()
+
+	a := &app.App{SecurityHeaders: &app.SecurityHeadersConfig{Preset: "balanced"}}
+	err := caddy.NewClient(admin.URL).AddRoute(caddy.Route{
+		ID:       "basepod-web",
+		Domain:   "web.example.com",
+		Upstream: "localhost:10001",
+		Headers:  a.ResponseHeaders(),
+	})
+	if err != nil {
+		t.Fatalf("AddRoute error = %v", err)
+	}
+
+	handle := route["handle"].([]interface{})
+	headers := handle[0].(map[string]interface{})
+	if headers["handler"] != "headers" {
+		t.Fatalf("first handler = %v, want headers", headers["handler"])
+	}
+	response := headers["response"].(map[string]interface{})
+	if response["deferred"] != true {
+		t.Fatalf("headers are not deferred: %v", response)
+	}
+	set := response["set"].(map[string]interface{})
+	if nosniff := set["X-Content-Type-Options"].([]interface{}); len(nosniff) != 1 || nosniff[0] != "nosniff" {
+		t.Fatalf("X-Content-Type-Options = %v", nosniff)
+	}
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/mlx"
+)
+
+// watchdogInterval is how often the daemon checks itself and its AI servers
+const watchdogInterval = 30 * time.Second
+
+// watchdogLimits are the resolved soft limits from WatchdogConfig
+type watchdogLimits struct {
+	MemoryBytes   uint64
+	Goroutines    int
+	OpenFiles     int
+	AIHangTimeout time.Duration
+}
+
+// resolveWatchdogLimits applies defaults to the watchdog config. fileLimit is
+// the process's open file limit (0 if unknown).
+func resolveWatchdogLimits(cfg config.WatchdogConfig, fileLimit int) watchdogLimits {
+	l := watchdogLimits{
+		MemoryBytes:   1024 << 20,
+		Goroutines:    10000,
+		OpenFiles:     fileLimit * 8 / 10,
+		AIHangTimeout: 2 * time.Minute,
+	}
+	if cfg.MemoryMB > 0 {
+		l.MemoryBytes = uint64(cfg.MemoryMB) << 20
+	}
+	if cfg.Goroutines > 0 {
+		l.Goroutines = cfg.Goroutines
+	}
+	if cfg.OpenFiles > 0 {
+		l.OpenFiles = cfg.OpenFiles
+	}
+	if d, err := time.ParseDuration(cfg.AIHangTimeout); err == nil && d > 0 {
+		l.AIHangTimeout = d
+	}
+	return l
+}
+
+// DaemonStats is a snapshot of the basepod daemon's own resource usage
+type DaemonStats struct {
+	PID            int                    `json:"pid"`
+	Version        string                 `json:"version"`
+	GoVersion      string                 `json:"go_version"`
+	StartedAt      time.Time              `json:"started_at"`
+	UptimeSeconds  int64                  `json:"uptime_seconds"`
+	Memory         DaemonMemory           `json:"memory"`
+	Goroutines     int                    `json:"goroutines"`
+	OpenFiles      int                    `json:"open_files"`
+	OpenFilesLimit int                    `json:"open_files_limit"`
+	Limits         map[string]interface{} `json:"limits"`
+	Warnings       []string               `json:"warnings"`
+	AIProcesses    []AIProcessStats       `json:"ai_processes"`
+}
+
+// DaemonMemory is the Go runtime's view of the daemon's memory
+type DaemonMemory struct {
+	SysBytes        uint64 `json:"sys_bytes"` // Obtained from the OS
+	HeapAllocBytes  uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes  uint64 `json:"heap_inuse_bytes"`
+	StackInuseBytes uint64 `json:"stack_inuse_bytes"`
+	GCCount         uint32 `json:"gc_count"`
+}
+
+// AIProcessStats describes an MLX server supervised by the watchdog
+type AIProcessStats struct {
+	Name              string     `json:"name"` // chat or assistant
+	PID               int        `json:"pid"`
+	Port              int        `json:"port"`
+	Model             string     `json:"model"`
+	RSSBytes          int64      `json:"rss_bytes"`
+	Responding        bool       `json:"responding"`
+	UnresponsiveSince *time.Time `json:"unresponsive_since,omitempty"`
+	Restarts          int        `json:"restarts"`
+	LastRestart       *time.Time `json:"last_restart,omitempty"`
+}
+
+// aiWatch tracks the responsiveness of one AI server between checks
+type aiWatch struct {
+	pid          int
+	firstSeen    time.Time
+	responding   bool
+	failingSince time.Time
+	restarts     int
+	lastRestart  time.Time
+}
+
+// watchdogState is what the watchdog remembers between checks
+type watchdogState struct {
+	mu       sync.Mutex
+	ai       map[string]*aiWatch
+	warnings map[string]bool // Limits currently exceeded, to warn once per breach
+}
+
+func newWatchdogState() *watchdogState {
+	return &watchdogState{ai: make(map[string]*aiWatch), warnings: make(map[string]bool)}
+}
+
+// runWatchdog checks the daemon against its soft limits and restarts AI
+// servers that stop answering
+func (s *Server) runWatchdog() {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkSoftLimits()
+			s.watchAIServers(time.Now())
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+// checkSoftLimits logs each limit once when it is first exceeded and again
+// when usage drops back under it
+func (s *Server) checkSoftLimits() {
+	stats := s.collectDaemonStats()
+
+	s.watchdog.mu.Lock()
+	defer s.watchdog.mu.Unlock()
+	current := make(map[string]bool)
+	for _, w := range stats.Warnings {
+		key, _, _ := strings.Cut(w, ":")
+		current[key] = true
+		if !s.watchdog.warnings[key] {
+			log.Printf("Watchdog: %s", w)
+			s.logActivity("system", "daemon_limit", "system", "", "basepod", "warning", w)
+		}
+	}
+	for key := range s.watchdog.warnings {
+		if !current[key] {
+			log.Printf("Watchdog: %s back under its limit", key)
+		}
+	}
+	s.watchdog.warnings = current
+}
+
+// softLimitWarnings compares usage with the limits. Each warning starts with
+// the name of the limit followed by a colon.
+func softLimitWarnings(stats *DaemonStats, limits watchdogLimits) []string {
+	var warnings []string
+	if limits.MemoryBytes > 0 && stats.Memory.SysBytes > limits.MemoryBytes {
+		warnings = append(warnings, fmt.Sprintf("memory: %d MB in use, soft limit %d MB", stats.Memory.SysBytes>>20, limits.MemoryBytes>>20))
+	}
+	if limits.Goroutines > 0 && stats.Goroutines > limits.Goroutines {
+		warnings = append(warnings, fmt.Sprintf("goroutines: %d running, soft limit %d", stats.Goroutines, limits.Goroutines))
+	}
+	if limits.OpenFiles > 0 && stats.OpenFiles > limits.OpenFiles {
+		warnings = append(warnings, fmt.Sprintf("open_files: %d open, soft limit %d (hard limit %d)", stats.OpenFiles, limits.OpenFiles, stats.OpenFilesLimit))
+	}
+	return warnings
+}
+
+// watchAIServers restarts MLX servers that are running but haven't answered
+// for longer than the hang timeout. A freshly started server gets the same
+// grace period to load its model.
+func (s *Server) watchAIServers(now time.Time) {
+	cfg := s.watchdogConfig()
+	limits := resolveWatchdogLimits(cfg, 0)
+
+	svc := mlx.GetService()
+	servers := map[string]mlx.Status{
+		"chat":      svc.GetStatus(),
+		"assistant": mlx.AssistantStatus(),
+	}
+	for name, status := range servers {
+		s.watchdog.mu.Lock()
+		w := s.watchdog.ai[name]
+		if w == nil || w.pid != status.PID {
+			restarts, lastRestart := 0, time.Time{}
+			if w != nil {
+				restarts, lastRestart = w.restarts, w.lastRestart
+			}
+			w = &aiWatch{pid: status.PID, firstSeen: now, restarts: restarts, lastRestart: lastRestart}
+			s.watchdog.ai[name] = w
+		}
+		s.watchdog.mu.Unlock()
+
+		if !status.Running {
+			continue
+		}
+
+		err := probeAIServer(status.Port)
+		s.watchdog.mu.Lock()
+		w.responding = err == nil
+		if err == nil {
+			w.failingSince = time.Time{}
+			s.watchdog.mu.Unlock()
+			continue
+		}
+		if w.failingSince.IsZero() {
+			w.failingSince = now
+		}
+		unresponsive := now.Sub(w.failingSince)
+		hung := now.Sub(w.firstSeen) >= limits.AIHangTimeout && unresponsive >= limits.AIHangTimeout
+		s.watchdog.mu.Unlock()
+
+		if !hung || cfg.DisableAIRestart {
+			continue
+		}
+
+		details := fmt.Sprintf("%s server (%s, pid %d) unresponsive for %s: %v", name, status.ActiveModel, status.PID, unresponsive.Round(time.Second), err)
+		log.Printf("Watchdog: restarting %s", details)
+		var restartErr error
+		if name == "assistant" {
+			restartErr = svc.RestartAssistant()
+		} else {
+			restartErr = svc.Restart()
+		}
+
+		s.watchdog.mu.Lock()
+		w.restarts++
+		w.lastRestart = now
+		w.failingSince = time.Time{}
+		w.firstSeen = now
+		s.watchdog.mu.Unlock()
+
+		if restartErr != nil {
+			log.Printf("Watchdog: failed to restart %s server: %v", name, restartErr)
+			s.logActivity("system", "ai_restart", "system", "", "mlx-"+name, "failed", details+": "+restartErr.Error())
+		} else {
+			s.logActivity("system", "ai_restart", "system", "", "mlx-"+name, "success", details)
+		}
+	}
+}
+
+// probeAIServer checks that an MLX server answers its models endpoint
+func probeAIServer(port int) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/v1/models", port))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Server) watchdogConfig() config.WatchdogConfig {
+	if s.config == nil {
+		return config.WatchdogConfig{}
+	}
+	return s.config.Watchdog
+}
+
+// collectDaemonStats gathers the daemon's resource usage and AI server state
+func (s *Server) collectDaemonStats() DaemonStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := DaemonStats{
+		PID:            os.Getpid(),
+		Version:        s.version,
+		GoVersion:      runtime.Version(),
+		StartedAt:      s.startedAt,
+		UptimeSeconds:  int64(time.Since(s.startedAt).Seconds()),
+		Goroutines:     runtime.NumGoroutine(),
+		OpenFiles:      openFileCount(),
+		OpenFilesLimit: openFileLimit(),
+		Memory: DaemonMemory{
+			SysBytes:        mem.Sys,
+			HeapAllocBytes:  mem.HeapAlloc,
+			HeapInuseBytes:  mem.HeapInuse,
+			StackInuseBytes: mem.StackInuse,
+			GCCount:         mem.NumGC,
+		},
+		AIProcesses: []AIProcessStats{},
+	}
+
+	cfg := s.watchdogConfig()
+	limits := resolveWatchdogLimits(cfg, stats.OpenFilesLimit)
+	stats.Limits = map[string]interface{}{
+		"memory_mb":       limits.MemoryBytes >> 20,
+		"goroutines":      limits.Goroutines,
+		"open_files":      limits.OpenFiles,
+		"ai_hang_timeout": limits.AIHangTimeout.String(),
+		"ai_restart":      !cfg.DisableAIRestart,
+	}
+	stats.Warnings = softLimitWarnings(&stats, limits)
+	if stats.Warnings == nil {
+		stats.Warnings = []string{}
+	}
+
+	servers := []struct {
+		name   string
+		status mlx.Status
+	}{
+		{"chat", mlx.GetService().GetStatus()},
+		{"assistant", mlx.AssistantStatus()},
+	}
+	s.watchdog.mu.Lock()
+	defer s.watchdog.mu.Unlock()
+	for _, srv := range servers {
+		if !srv.status.Running {
+			continue
+		}
+		p := AIProcessStats{
+			Name:       srv.name,
+			PID:        srv.status.PID,
+			Port:       srv.status.Port,
+			Model:      srv.status.ActiveModel,
+			RSSBytes:   processRSS(srv.status.PID),
+			Responding: true,
+		}
+		if w := s.watchdog.ai[srv.name]; w != nil {
+			if w.pid == srv.status.PID {
+				p.Responding = w.responding || w.failingSince.IsZero()
+				if !w.failingSince.IsZero() {
+					since := w.failingSince
+					p.UnresponsiveSince = &since
+				}
+			}
+			p.Restarts = w.restarts
+			if !w.lastRestart.IsZero() {
+				last := w.lastRestart
+				p.LastRestart = &last
+			}
+		}
+		stats.AIProcesses = append(stats.AIProcesses, p)
+	}
+	return stats
+}
+
+// openFileCount counts the daemon's open file descriptors
+func openFileCount() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries) - 1 // The directory read holds one itself
+		}
+	}
+	return 0
+}
+
+// openFileLimit returns the soft limit on open files
+func openFileLimit() int {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0
+	}
+	if rl.Cur > math.MaxInt32 {
+		return 0 // Unlimited
+	}
+	return int(rl.Cur)
+}
+
+// processRSS returns a process's resident memory in bytes
+func processRSS(pid int) int64 {
+	out, err := exec.Command("ps", "-o", "rss=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0
+	}
+	kb, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kb * 1024
+}
+
+// handleGetSelf reports the daemon's own health
+func (s *Server) handleGetSelf(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, s.collectDaemonStats())
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestResolveWatchdogLimits(t *testing.T) {
+	t.Parallel()
+
+	l := resolveWatchdogLimits(config.WatchdogConfig{}, 1000)
+	if l.MemoryBytes != 1024<<20 || l.Goroutines != 10000 || l.OpenFiles != 800 || l.AIHangTimeout != 2*time.Minute {
+		t.Fatalf("defaults = %+v", l)
+	}
+
+	l = resolveWatchdogLimits(config.WatchdogConfig{MemoryMB: 256, Goroutines: 500, OpenFiles: 100, AIHangTimeout: "5m"}, 1000)
+	if l.MemoryBytes != 256<<20 || l.Goroutines != 500 || l.OpenFiles != 100 || l.AIHangTimeout != 5*time.Minute {
+		t.Fatalf("configured = %+v", l)
+	}
+}
+
+func TestSoftLimitWarnings(t *testing.T) {
+	t.Parallel()
+
+	limits := watchdogLimits{MemoryBytes: 100 << 20, Goroutines: 50, OpenFiles: 10}
+	stats := &DaemonStats{Memory: DaemonMemory{SysBytes: 50 << 20}, Goroutines: 20, OpenFiles: 5}
+	if w := softLimitWarnings(stats, limits); len(w) != 0 {
+		t.Fatalf("unexpected warnings: %q", w)
+	}
+
+	stats.Memory.SysBytes = 150 << 20
+	stats.OpenFiles = 12
+	w := softLimitWarnings(stats, limits)
+	if len(w) != 2 || !strings.HasPrefix(w[0], "memory:") || !strings.HasPrefix(w[1], "open_files:") {
+		t.Fatalf("warnings = %q", w)
+	}
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+	"github.com/base-go/basepod/internal/podman"
+	"github.com/google/uuid"
+)
+
+// selftestImage serves the self-test page; busybox is small and has httpd
+const selftestImage = "docker.io/library/busybox:latest"
+
+// selftestTimeout bounds a whole self-test run, teardown excluded
+const selftestTimeout = 3 * time.Minute
+
+// SelftestStep is the outcome of one check in a self-test
+type SelftestStep struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // ok, warn, failed, skipped
+	Detail     string `json:"detail,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// SelftestReport is the result of POST /api/system/selftest
+type SelftestReport struct {
+	Passed     bool           `json:"passed"`
+	App        string         `json:"app"`
+	Domain     string         `json:"domain"`
+	Steps      []SelftestStep `json:"steps"`
+	DurationMS int64          `json:"duration_ms"`
+}
+
+// selftestRun records steps; once a step fails the ones that depend on it are skipped
+type selftestRun struct {
+	report *SelftestReport
+	failed bool
+}
+
+// step runs fn unless an earlier step failed. fn returns a detail message,
+// and an error or a warning (status "warn") via selftestWarning.
+func (t *selftestRun) step(name string, fn func() (string, error)) {
+	if t.failed {
+		t.report.Steps = append(t.report.Steps, SelftestStep{Name: name, Status: "skipped"})
+		return
+	}
+	start := time.Now()
+	detail, err := fn()
+	st := SelftestStep{Name: name, Status: "ok", Detail: detail, DurationMS: time.Since(start).Milliseconds()}
+	if w, ok := err.(selftestWarning); ok {
+		st.Status = "warn"
+		st.Detail = string(w)
+	} else if err != nil {
+		st.Status = "failed"
+		st.Detail = err.Error()
+		t.failed = true
+	}
+	t.report.Steps = append(t.report.Steps, st)
+}
+
+// selftestWarning marks a step that worked with a caveat
+type selftestWarning string
+
+func (w selftestWarning) Error() string { return string(w) }
+
+// runSelftest deploys a throwaway app, checks that it is reachable end to end
+// and removes it again
+func (s *Server) runSelftest(ctx context.Context) *SelftestReport {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, selftestTimeout)
+	defer cancel()
+
+	name := "selftest-" + generateRandomString(6)
+	token := generateRandomString(24)
+	a := &app.App{
+		ID:     uuid.New().String(),
+		Name:   name,
+		Type:   app.AppTypeContainer,
+		Domain: s.config.GetAppDomain(name),
+		Status: app.StatusDeploying,
+		Env:    map[string]string{},
+		Ports: app.PortConfig{
+			ContainerPort: 8080,
+			Protocol:      "http",
+		},
+		Resources: app.ResourceConfig{Replicas: 1},
+		SSL:       app.SSLConfig{Enabled: true},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	a.Ports.HostPort = assignHostPort(a.ID)
+
+	report := &SelftestReport{App: name, Domain: a.Domain}
+	t := &selftestRun{report: report}
+	defer s.teardownSelftest(a, t)
+
+	t.step("pull", func() (string, error) {
+		if err := s.podman.PullImage(ctx, selftestImage); err != nil {
+			return "", err
+		}
+		return selftestImage, nil
+	})
+
+	t.step("deploy", func() (string, error) {
+		// The app record lets Caddy's on-demand TLS check issue a certificate
+		if err := s.storage.CreateApp(a); err != nil {
+			return "", fmt.Errorf("create app: %w", err)
+		}
+		script := fmt.Sprintf("mkdir -p /www && echo %s > /www/index.html && echo selftest ready && exec httpd -f -v -p 8080 -h /www", token)
+		id, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
+			Name:     "basepod-" + name,
+			Image:    selftestImage,
+			Command:  []string{"sh", "-c", script},
+			Networks: []string{"basepod"},
+			Ports:    map[string]string{"8080": fmt.Sprintf("%d", a.Ports.HostPort)},
+			Labels: map[string]string{
+				"basepod.app":    name,
+				"basepod.app.id": a.ID,
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		a.ContainerID = id
+		if err := s.podman.StartContainer(ctx, id); err != nil {
+			return "", err
+		}
+		if err := s.waitForAppReadiness(ctx, a); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("container %s on port %d", name, a.Ports.HostPort), nil
+	})
+
+	t.step("route", func() (string, error) {
+		if s.caddy == nil {
+			return "", fmt.Errorf("Caddy is not configured")
+		}
+		return a.Domain, s.caddy.AddRoute(caddy.Route{
+			ID:        "basepod-" + name,
+			Domain:    a.Domain,
+			Upstream:  fmt.Sprintf("localhost:%d", a.Ports.HostPort),
+			EnableSSL: true,
+		})
+	})
+
+	t.step("dns", func() (string, error) {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, a.Domain)
+		if err != nil {
+			// Routing is still checked locally, so this doesn't stop the run
+			return "", selftestWarning(fmt.Sprintf("%s does not resolve (%v); configure a wildcard record for new apps", a.Domain, err))
+		}
+		addrs := make([]string, len(ips))
+		for i, ip := range ips {
+			addrs[i] = ip.String()
+		}
+		return strings.Join(addrs, ", "), nil
+	})
+
+	t.step("tls", func() (string, error) {
+		return selftestTLS(ctx, a.Domain)
+	})
+
+	t.step("routing", func() (string, error) {
+		body, err := selftestFetch(ctx, a.Domain)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(body) != token {
+			return "", fmt.Errorf("unexpected response from https://%s: %.60q", a.Domain, body)
+		}
+		return "https://" + a.Domain + " served the test page", nil
+	})
+
+	t.step("logs", func() (string, error) {
+		rc, err := s.podman.ContainerLogs(ctx, a.ContainerID, podman.LogOpts{Stdout: true, Stderr: true, Tail: "50"})
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		data, _ := io.ReadAll(io.LimitReader(rc, 64<<10))
+		if !strings.Contains(string(data), "selftest ready") {
+			return "", fmt.Errorf("container output not found in logs")
+		}
+		return "container output captured", nil
+	})
+
+	t.step("exec", func() (string, error) {
+		execID, err := s.podman.ExecCreate(ctx, a.ContainerID, []string{"cat", "/www/index.html"})
+		if err != nil {
+			return "", err
+		}
+		out, err := s.podman.ExecStart(ctx, execID)
+		if err != nil {
+			return "", err
+		}
+		if !strings.Contains(out, token) {
+			return "", fmt.Errorf("unexpected exec output: %.60q", out)
+		}
+		return "command ran inside the container", nil
+	})
+
+	report.Passed = !t.failed
+	report.DurationMS = time.Since(started).Milliseconds()
+	return report
+}
+
+// teardownSelftest removes everything the self-test created, even after a failure
+func (s *Server) teardownSelftest(a *app.App, t *selftestRun) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	var problems []string
+	if s.caddy != nil {
+		s.caddy.RemoveRoute("basepod-" + a.Name)
+	}
+	if err := s.podman.RemoveContainer(ctx, "basepod-"+a.Name, true); err != nil && a.ContainerID != "" {
+		problems = append(problems, err.Error())
+	}
+	if existing, _ := s.storage.GetApp(a.ID); existing != nil {
+		if err := s.storage.DeleteApp(a.ID); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	st := SelftestStep{Name: "teardown", Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+	if len(problems) > 0 {
+		st.Status = "failed"
+		st.Detail = strings.Join(problems, "; ")
+		t.report.Passed = false
+	}
+	t.report.Steps = append(t.report.Steps, st)
+}
+
+// selftestTLS waits for Caddy to serve a certificate for domain. Certificates
+// are issued on demand, so the first handshake can take a while.
+func selftestTLS(ctx context.Context, domain string) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt < 10; attempt++ {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: domain}}
+		conn, err := dialer.DialContext(ctx, "tcp", "127.0.0.1:443")
+		if err == nil {
+			cert := conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+			conn.Close()
+			return fmt.Sprintf("issued by %s, expires %s", cert.Issuer.CommonName, cert.NotAfter.Format("2006-01-02")), nil
+		}
+		lastErr = err
+
+		// A certificate from an untrusted CA (local dev setups) still proves TLS works
+		insecure := &tls.Dialer{Config: &tls.Config{ServerName: domain, InsecureSkipVerify: true}}
+		if conn, err := insecure.DialContext(ctx, "tcp", "127.0.0.1:443"); err == nil {
+			cert := conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+			conn.Close()
+			return "", selftestWarning(fmt.Sprintf("certificate from %s is not publicly trusted: %v", cert.Issuer.CommonName, lastErr))
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no certificate for %s: %w", domain, lastErr)
+		case <-time.After(3 * time.Second):
+		}
+	}
+	return "", fmt.Errorf("no certificate for %s: %w", domain, lastErr)
+}
+
+// selftestFetch requests the test page through Caddy on this host, so routing
+// is checked even when public DNS isn't set up yet
+func selftestFetch(ctx context.Context, domain string) (string, error) {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{ServerName: domain, InsecureSkipVerify: true}, // Trust is checked by the tls step
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, network, "127.0.0.1:443")
+		},
+	}
+	client := &http.Client{Transport: transport, Timeout: 15 * time.Second}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://"+domain+"/", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("https://%s returned %d", domain, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return string(body), err
+}
+
+// handleSelftest runs the end-to-end self-test
+func (s *Server) handleSelftest(w http.ResponseWriter, r *http.Request) {
+	report := s.runSelftest(r.Context())
+
+	status := "success"
+	if !report.Passed {
+		status = "failed"
+	}
+	s.logActivity("user", "selftest", "system", "", report.App, status, fmt.Sprintf("%d steps in %dms", len(report.Steps), report.DurationMS))
+	jsonResponse(w, http.StatusOK, report)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelftestRunSkipsAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	run := &selftestRun{report: &SelftestReport{}}
+	run.step("pull", func() (string, error) { return "ok", nil })
+	run.step("dns", func() (string, error) { return "", selftestWarning("no record") })
+	run.step("tls", func() (string, error) { return "", errors.New("handshake failed") })
+	run.step("exec", func() (string, error) {
+		t.Fatalf("step ran after a failure")
+		return "", nil
+	})
+
+	want := []string{"ok", "warn", "failed", "skipped"}
+	if len(run.report.Steps) != len(want) {
+		t.Fatalf("steps = %+v", run.report.Steps)
+	}
+	for i, st := range run.report.Steps {
+		if st.Status != want[i] {
+			t.Fatalf("step %s status = %q, want %q", st.Name, st.Status, want[i])
+		}
+	}
+	if run.report.Steps[1].Detail != "no record" || !run.failed {
+		t.Fatalf("report = %+v", run.report)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+	"github.com/base-go/basepod/internal/config"
+)
+
+// seoFileConfig is the seo section of basepod.yaml
+type seoFileConfig struct {
+	NoIndex   *bool  `yaml:"noindex" json:"noindex"`
+	RobotsTxt string `yaml:"robots_txt" json:"robots_txt"`
+}
+
+// appConfig converts the basepod.yaml section to app settings; nil if absent
+func (c *seoFileConfig) appConfig() *app.SEOConfig {
+	if c == nil {
+		return nil
+	}
+	return &app.SEOConfig{NoIndex: c.NoIndex, RobotsTxt: c.RobotsTxt}
+}
+
+// validateSEO rejects robots.txt contents Caddy shouldn't be asked to serve
+func validateSEO(c *app.SEOConfig) error {
+	if c != nil && len(c.RobotsTxt) > 64<<10 {
+		return fmt.Errorf("robots_txt is too large (max 64KB)")
+	}
+	return nil
+}
+
+// appRouteSEO returns the search engine settings for one of an app's domains
+func appRouteSEO(a *app.App, domain string) caddy.SEO {
+	noindex, robotsTxt := a.SearchPolicy(domain)
+	return caddy.SEO{NoIndex: noindex, RobotsTxt: robotsTxt}
+}
+
+// refreshAppRoutes re-applies a running app's Caddy routes, e.g. after its
+// search engine settings changed
+func (s *Server) refreshAppRoutes(a *app.App) {
+	if s.caddy == nil || a.Domain == "" || a.RedirectURL != "" || a.Status != app.StatusRunning {
+		return
+	}
+
+	if a.Type == app.AppTypeStatic {
+		paths, _ := config.GetPaths()
+		appDataDir := fmt.Sprintf("%s/data/apps/%s", paths.Base, a.Name)
+		if err := AddStaticAppRoute(s.caddy, a, a.Domain, appDataDir); err != nil {
+			log.Printf("Warning: failed to update static route for %s: %v", a.Domain, err)
+		}
+		return
+	}
+	if a.Ports.HostPort == 0 {
+		return
+	}
+
+	if err := s.caddy.AddRoute(AppRoute(a, a.Domain)); err != nil {
+		log.Printf("Warning: failed to update route for %s: %v", a.Domain, err)
+	}
+	for _, alias := range a.Aliases {
+		if err := s.caddy.AddRoute(AppRoute(a, alias)); err != nil {
+			log.Printf("Warning: failed to update alias route for %s: %v", alias, err)
+		}
+	}
+}
+
+// DomainSEO is the effective search engine policy for one domain
+type DomainSEO struct {
+	Domain    string `json:"domain"`
+	Preview   bool   `json:"preview"`
+	NoIndex   bool   `json:"noindex"`
+	RobotsTxt string `json:"robots_txt,omitempty"` // Served by basepod; empty when the app serves its own
+}
+
+// handleGetSEO returns an app's search engine settings and what they resolve
+// to for each of its domains
+func (s *Server) handleGetSEO(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	domains := []DomainSEO{}
+	for _, domain := range append([]string{a.Domain}, a.Aliases...) {
+		if domain == "" {
+			continue
+		}
+		noindex, robotsTxt := a.SearchPolicy(domain)
+		domains = append(domains, DomainSEO{
+			Domain:    domain,
+			Preview:   app.IsPreviewDomain(domain),
+			NoIndex:   noindex,
+			RobotsTxt: robotsTxt,
+		})
+	}
+
+	settings := a.SEO
+	if settings == nil {
+		settings = &app.SEOConfig{}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"settings": settings,
+		"domains":  domains,
+	})
+}
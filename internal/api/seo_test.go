@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestAppRouteSEO(t *testing.T) {
+	t.Parallel()
+
+	off := false
+	on := true
+	cases := []struct {
+		name        string
+		seo         *app.SEOConfig
+		domain      string
+		wantNoIndex bool
+		wantRobots  string
+	}{
+		{"production", nil, "shop.example.com", false, ""},
+		{"staging subdomain", nil, "shop.staging.example.com", true, app.DisallowAllRobots},
+		{"preview suffix", nil, "shop-preview.example.com", true, app.DisallowAllRobots},
+		{"pull request", nil, "shop-pr-42.example.com", true, app.DisallowAllRobots},
+		{"pr is not a preview", nil, "pr.example.com", false, ""},
+		{"opted out on preview", &app.SEOConfig{NoIndex: &off, RobotsTxt: app.RobotsApp}, "shop-staging.example.com", false, ""},
+		{"forced on production", &app.SEOConfig{NoIndex: &on, RobotsTxt: app.RobotsDisallow}, "shop.example.com", true, app.DisallowAllRobots},
+		{"custom robots", &app.SEOConfig{RobotsTxt: "User-agent: *\nAllow: /\n"}, "shop.example.com", false, "User-agent: *\nAllow: /\n"},
+	}
+	for _, c := range cases {
+		got := appRouteSEO(&app.App{SEO: c.seo}, c.domain)
+		if got.NoIndex != c.wantNoIndex || got.RobotsTxt != c.wantRobots {
+			t.Fatalf("%s: appRouteSEO(%s) = %+v", c.name, c.domain, got)
+		}
+	}
+}
@@ -0,0 +1,561 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// ServiceSpec is one service of a multi-service basepod.yaml deploy
+type ServiceSpec struct {
+	Image     string            `json:"image,omitempty" yaml:"image"`
+	Build     ServiceBuildSpec  `json:"build,omitempty" yaml:"build"`
+	Port      int               `json:"port,omitempty" yaml:"port"`
+	Domain    string            `json:"domain,omitempty" yaml:"domain"` // Routed to this service
+	Command   string            `json:"command,omitempty" yaml:"command"`
+	Env       map[string]string `json:"env,omitempty" yaml:"env"`
+	Volumes   []string          `json:"volumes,omitempty" yaml:"volumes"` // name:/path[:ro]
+	DependsOn []string          `json:"depends_on,omitempty" yaml:"depends_on"`
+	Ready     *app.ServiceReady `json:"ready,omitempty" yaml:"ready"` // How dependents know it's up
+}
+
+// ServiceBuildSpec is where a service's image is built from, relative to the source root
+type ServiceBuildSpec struct {
+	Context    string `json:"context,omitempty" yaml:"context"`
+	Dockerfile string `json:"dockerfile,omitempty" yaml:"dockerfile"`
+}
+
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// appPodName is the Podman pod a multi-service app's containers share
+func appPodName(a *app.App) string {
+	return "basepod-" + a.Name
+}
+
+// serviceContainerName is the container a service of app runs in; the CLI's
+// local stack resolves the same name (see serviceAliases in cmd/bp)
+func serviceContainerName(a *app.App, service string) string {
+	return "basepod-" + a.Name + "-" + service
+}
+
+// serviceRouteID is the Caddy route of a service with its own domain
+func serviceRouteID(a *app.App, service string) string {
+	return "basepod-" + a.Name + "-" + service
+}
+
+// orderServiceSpecs sorts services so each one starts after its depends_on
+func orderServiceSpecs(services map[string]*ServiceSpec) ([]string, error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var order []string
+	state := make(map[string]int) // 0 = unvisited, 1 = visiting, 2 = done
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		deps := append([]string{}, services[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, ok := services[dep]; !ok {
+				return fmt.Errorf("service %s depends on unknown service %s", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// validateServiceSpecs checks names, images and volumes, and returns the
+// start order
+func validateServiceSpecs(services map[string]*ServiceSpec) ([]string, error) {
+	for name, svc := range services {
+		if svc == nil {
+			return nil, fmt.Errorf("service %s is empty", name)
+		}
+		if !serviceNamePattern.MatchString(name) || len(name) > 40 {
+			return nil, fmt.Errorf("invalid service name %q: use lowercase letters, digits and dashes", name)
+		}
+		if svc.Image == "" && svc.Build.Context == "" && svc.Build.Dockerfile == "" {
+			return nil, fmt.Errorf("service %s needs an image or a build context", name)
+		}
+		if svc.Port < 0 || svc.Port > 65535 {
+			return nil, fmt.Errorf("service %s has invalid port %d", name, svc.Port)
+		}
+		if svc.Domain != "" && svc.Port == 0 {
+			return nil, fmt.Errorf("service %s has a domain but no port", name)
+		}
+		if svc.Ready != nil {
+			if svc.Port == 0 {
+				return nil, fmt.Errorf("service %s has a ready check but no port", name)
+			}
+			if svc.Ready.HTTP != "" && !strings.HasPrefix(svc.Ready.HTTP, "/") {
+				return nil, fmt.Errorf("service %s: ready.http must be a path starting with /", name)
+			}
+			if svc.Ready.Timeout < 0 {
+				return nil, fmt.Errorf("service %s: ready.timeout must not be negative", name)
+			}
+		}
+		for _, vol := range svc.Volumes {
+			if _, err := parseVolumeSpec(vol); err != nil {
+				return nil, fmt.Errorf("service %s: %w", name, err)
+			}
+		}
+	}
+	return orderServiceSpecs(services)
+}
+
+// mainService picks the service that serves the app's domain: the one on
+// the app's port, else the first with a port
+func mainService(services map[string]*ServiceSpec, order []string, port int) string {
+	for _, name := range order {
+		if port > 0 && services[name].Port == port {
+			return name
+		}
+	}
+	for _, name := range order {
+		if services[name].Port > 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+// serviceHosts are the /etc/hosts entries that let services reach each
+// other by name inside their pod, as they do in the local stack
+func serviceHosts(a *app.App, order []string) []string {
+	var hosts []string
+	for _, name := range order {
+		for _, alias := range []string{name, a.Name + "-" + name, serviceContainerName(a, name)} {
+			hosts = append(hosts, alias+":127.0.0.1")
+		}
+	}
+	return hosts
+}
+
+// deployServices builds and runs the services of a multi-service deploy in
+// one Podman pod, replacing the app's previous containers, and routes the
+// app's domain to its main service plus each service's own domain
+func (s *Server) deployServices(ctx context.Context, a *app.App, sourceDir string, deployConfig *SourceDeployConfig, stream *deployStream, releaseBuild func()) {
+	writeLine := stream.line
+	fail := func(msg string) {
+		writeLine("ERROR: " + msg)
+		a.Status = app.StatusFailed
+		a.UpdatedAt = time.Now()
+		s.storage.UpdateApp(a)
+	}
+
+	order, err := validateServiceSpecs(deployConfig.Services)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	main := mainService(deployConfig.Services, order, deployConfig.Port)
+	writeLine(fmt.Sprintf("Services (start order): %s", strings.Join(order, ", ")))
+
+	a.Status = app.StatusDeploying
+	s.storage.UpdateApp(a)
+
+	podmanPath := "podman"
+	if _, err := exec.LookPath("podman"); err != nil {
+		for _, p := range []string{"/opt/homebrew/bin/podman", "/usr/local/bin/podman"} {
+			if _, err := os.Stat(p); err == nil {
+				podmanPath = p
+				break
+			}
+		}
+	}
+	deployTag := fmt.Sprintf("%d", time.Now().Unix())
+	images := make(map[string]string, len(order))
+	for _, name := range order {
+		svc := deployConfig.Services[name]
+		if svc.Image != "" {
+			writeLine(fmt.Sprintf("[%s] Pulling %s", name, svc.Image))
+			if err := s.podman.PullImage(ctx, svc.Image); err != nil {
+				fail(fmt.Sprintf("[%s] Failed to pull %s: %v", name, svc.Image, err))
+				return
+			}
+			images[name] = svc.Image
+			continue
+		}
+
+		contextDir, err := resolvePathWithinBase(sourceDir, svc.Build.Context)
+		if err != nil {
+			fail(fmt.Sprintf("[%s] Invalid build context: %v", name, err))
+			return
+		}
+		dockerfile := svc.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		dockerfilePath, err := resolvePathWithinBase(contextDir, dockerfile)
+		if err != nil {
+			fail(fmt.Sprintf("[%s] Invalid Dockerfile path: %v", name, err))
+			return
+		}
+		if !fileExists(dockerfilePath) {
+			fail(fmt.Sprintf("[%s] Dockerfile not found: %s", name, filepath.Join(svc.Build.Context, dockerfile)))
+			return
+		}
+		dockerfileRel, _ := filepath.Rel(contextDir, dockerfilePath)
+
+		imageName := fmt.Sprintf("localhost/basepod/%s-%s:%s", a.Name, name, deployTag)
+		imageLatest := fmt.Sprintf("localhost/basepod/%s-%s:latest", a.Name, name)
+		writeLine(fmt.Sprintf("[%s] Building image: %s", name, imageName))
+		buildCmd := []string{"build", "-t", imageName, "-t", imageLatest, "-f", dockerfileRel, "."}
+		if output, err := execCommandStreamDir(ctx, contextDir, podmanPath, buildCmd, writeLine); err != nil {
+			writeLine(output)
+			fail(fmt.Sprintf("[%s] Build failed: %v", name, err))
+			return
+		}
+		images[name] = imageName
+	}
+	writeLine("Images ready")
+	releaseBuild()
+
+	// Services are replaced together, since they share the pod's ports
+	stream.startPhase(DeployPhaseRun)
+	writeLine("Stopping old containers...")
+	s.removeServices(ctx, a)
+	if a.ContainerID != "" {
+		_ = s.podman.StopContainer(ctx, a.ContainerID, 10)
+		_ = s.podman.RemoveContainer(ctx, a.ContainerID, true)
+	}
+	_ = s.podman.StopContainer(ctx, "basepod-"+a.Name, 10)
+	_ = s.podman.RemoveContainer(ctx, "basepod-"+a.Name, true)
+	s.removeReplicas(ctx, a)
+
+	if a.Ports.HostPort == 0 {
+		a.Ports.HostPort = assignHostPort(a.ID)
+	}
+	previous := make(map[string]app.Service, len(a.Services))
+	for _, svc := range a.Services {
+		previous[svc.Name] = svc
+	}
+	services := make([]app.Service, 0, len(order))
+	ports := map[string]string{}
+	for _, name := range order {
+		spec := deployConfig.Services[name]
+		svc := app.Service{
+			Name:      name,
+			Image:     images[name],
+			Port:      spec.Port,
+			Domain:    spec.Domain,
+			Command:   spec.Command,
+			Env:       spec.Env,
+			DependsOn: spec.DependsOn,
+			Ready:     spec.Ready,
+		}
+		for _, vol := range spec.Volumes {
+			v, _ := parseVolumeSpec(vol)
+			svc.Volumes = append(svc.Volumes, v)
+		}
+		// Every port is published on loopback, so readiness can be checked
+		// from here; only routed services are reachable from outside
+		switch {
+		case name == main:
+			svc.HostPort = a.Ports.HostPort
+			if svc.Domain == "" {
+				svc.Domain = a.Domain
+			}
+		case svc.Port > 0:
+			svc.HostPort = previous[name].HostPort
+			if svc.HostPort == 0 {
+				svc.HostPort = assignHostPort(a.ID + "/" + name)
+			}
+		}
+		if svc.HostPort > 0 {
+			ports[fmt.Sprintf("%d", svc.Port)] = fmt.Sprintf("%d", svc.HostPort)
+		}
+		services = append(services, svc)
+	}
+
+	podName := appPodName(a)
+	if _, err := s.podman.CreatePod(ctx, podman.CreatePodOpts{
+		Name:     podName,
+		Ports:    ports,
+		Networks: []string{"basepod"},
+		Hosts:    serviceHosts(a, order),
+		Labels: map[string]string{
+			"basepod.app":    a.Name,
+			"basepod.app.id": a.ID,
+		},
+	}); err != nil {
+		fail("Failed to create pod: " + err.Error())
+		return
+	}
+	writeLine("Pod: " + podName)
+
+	appEnv := s.containerEnv(a)
+	for i := range services {
+		svc := &services[i]
+		env := make(map[string]string, len(appEnv)+len(svc.Env))
+		for k, v := range appEnv {
+			env[k] = v
+		}
+		for k, v := range svc.Env {
+			env[k] = v
+		}
+		var volumeMounts []string
+		for _, v := range svc.Volumes {
+			mount := fmt.Sprintf("%s:%s", s.ensureAppVolume(ctx, a, v, ""), v.ContainerPath)
+			if v.ReadOnly {
+				mount += ":ro"
+			}
+			volumeMounts = append(volumeMounts, mount)
+		}
+		var command []string
+		if svc.Command != "" {
+			command = []string{"sh", "-c", svc.Command}
+		}
+
+		containerID, err := s.podman.CreateContainer(ctx, podman.CreateContainerOpts{
+			Name:    serviceContainerName(a, svc.Name),
+			Image:   svc.Image,
+			Env:     env,
+			Volumes: volumeMounts,
+			Command: command,
+			Pod:     podName,
+			Labels: map[string]string{
+				"basepod.app":     a.Name,
+				"basepod.app.id":  a.ID,
+				"basepod.service": svc.Name,
+			},
+		})
+		if err != nil {
+			fail(fmt.Sprintf("[%s] Failed to create container: %v", svc.Name, err))
+			return
+		}
+		svc.ContainerID = containerID
+		if err := s.waitForDependencies(ctx, services[:i], *svc, writeLine); err != nil {
+			fail(err.Error())
+			return
+		}
+		writeLine(fmt.Sprintf("[%s] Starting %s", svc.Name, svc.Image))
+		if err := s.podman.StartContainer(ctx, containerID); err != nil {
+			fail(fmt.Sprintf("[%s] Failed to start container: %v", svc.Name, err))
+			return
+		}
+		if svc.Name == main {
+			a.ContainerID = containerID
+			a.Image = svc.Image
+			a.Ports.ContainerPort = svc.Port
+		}
+	}
+	a.Services = services
+	if main == "" {
+		// Nothing to route to; the app is only its services
+		a.ContainerID = services[0].ContainerID
+		a.Image = services[0].Image
+		a.Ports.HostPort = 0
+	}
+
+	if err := s.waitForAppReadiness(ctx, a); err != nil {
+		fail("App did not become ready: " + err.Error())
+		return
+	}
+
+	a.Status = app.StatusRunning
+	a.UpdatedAt = time.Now()
+	deployRecord := app.DeploymentRecord{
+		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
+		Image:      a.Image,
+		CommitHash: deployConfig.GitCommit,
+		CommitMsg:  deployConfig.GitMessage,
+		Branch:     deployConfig.GitBranch,
+		Status:     "success",
+		BuildLog:   stream.buildLog.String(),
+		DeployedAt: time.Now(),
+	}
+	a.Deployments = append([]app.DeploymentRecord{deployRecord}, a.Deployments...)
+	if len(a.Deployments) > 10 {
+		a.Deployments = a.Deployments[:10]
+	}
+	s.storage.UpdateApp(a)
+	s.recordDeployMarker(a, deployRecord, "deploy")
+	s.logActivity("system", "deploy", "app", a.ID, a.Name, "success", "")
+	s.sendNotifications("deploy_success", a.ID, a.Name, map[string]string{
+		"commit": deployConfig.GitCommit,
+		"branch": deployConfig.GitBranch,
+	})
+
+	stream.startPhase(DeployPhaseRoute)
+	s.routeServices(a, previous)
+
+	writeLine("")
+	writeLine("Deploy complete!")
+	appURL := ""
+	for _, svc := range a.Services {
+		if svc.Domain != "" {
+			writeLine(fmt.Sprintf("%s: https://%s", svc.Name, svc.Domain))
+			if svc.Name == main {
+				appURL = "https://" + svc.Domain
+			}
+		}
+	}
+	stream.succeed(a.Name, appURL)
+}
+
+// routeServices points the app's domain and aliases at its main service and
+// each other service's domain at that service, dropping routes of services
+// that are gone
+func (s *Server) routeServices(a *app.App, previous map[string]app.Service) {
+	if s.caddy == nil {
+		return
+	}
+	current := make(map[string]bool, len(a.Services))
+	for _, svc := range a.Services {
+		current[svc.Name] = true
+		if svc.HostPort == 0 || svc.Domain == "" {
+			continue
+		}
+		upstream := fmt.Sprintf("localhost:%d", svc.HostPort)
+		if svc.ContainerID == a.ContainerID && a.Domain != "" {
+			_ = s.caddy.AddRoute(caddy.Route{
+				ID:        "basepod-" + a.Name,
+				Domain:    a.Domain,
+				Upstream:  upstream,
+				EnableSSL: a.SSL.Enabled,
+				SEO:       appRouteSEO(a, a.Domain),
+				Headers:   a.ResponseHeaders(),
+				Snippets:  appRouteSnippets(a),
+				Protocol:  a.Ports.Protocol,
+			})
+			for _, alias := range a.Aliases {
+				_ = s.caddy.AddRoute(caddy.Route{
+					ID:        fmt.Sprintf("alias-%s-%s", a.ID[:8], alias),
+					Domain:    alias,
+					Upstream:  upstream,
+					EnableSSL: a.SSL.Enabled,
+					SEO:       appRouteSEO(a, alias),
+					Headers:   a.ResponseHeaders(),
+					Snippets:  appRouteSnippets(a),
+					Protocol:  a.Ports.Protocol,
+				})
+			}
+			if svc.Domain == a.Domain {
+				continue
+			}
+		}
+		_ = s.caddy.AddRoute(caddy.Route{
+			ID:        serviceRouteID(a, svc.Name),
+			Domain:    svc.Domain,
+			Upstream:  upstream,
+			EnableSSL: a.SSL.Enabled,
+			SEO:       appRouteSEO(a, svc.Domain),
+			Headers:   a.ResponseHeaders(),
+			Snippets:  appRouteSnippets(a),
+		})
+	}
+	for name := range previous {
+		if !current[name] {
+			_ = s.caddy.RemoveRoute(serviceRouteID(a, name))
+		}
+	}
+}
+
+// removeServices removes a multi-service app's pod and its containers
+func (s *Server) removeServices(ctx context.Context, a *app.App) {
+	for i := len(a.Services) - 1; i >= 0; i-- {
+		_ = s.podman.StopContainer(ctx, serviceContainerName(a, a.Services[i].Name), 10)
+	}
+	_ = s.podman.RemovePod(ctx, appPodName(a), true)
+}
+
+// startServices starts a multi-service app's containers in dependency
+// order, each once the services it depends on are ready
+func (s *Server) startServices(ctx context.Context, a *app.App) error {
+	for i, svc := range a.Services {
+		if err := s.waitForDependencies(ctx, a.Services[:i], svc, func(string) {}); err != nil {
+			return err
+		}
+		if err := s.podman.StartContainer(ctx, serviceContainerName(a, svc.Name)); err != nil {
+			return fmt.Errorf("%s: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
+
+// waitForDependencies waits until the services svc depends on, among the
+// already started ones, are ready
+func (s *Server) waitForDependencies(ctx context.Context, started []app.Service, svc app.Service, writeLine func(string)) error {
+	for _, dep := range svc.DependsOn {
+		for _, other := range started {
+			if other.Name != dep {
+				continue
+			}
+			writeLine(fmt.Sprintf("[%s] Waiting for %s to be ready...", svc.Name, dep))
+			if err := s.waitForService(ctx, other); err != nil {
+				return fmt.Errorf("%s is not ready, so %s wasn't started: %w", dep, svc.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// waitForService waits for a service's ready check: its HTTP path if it
+// has one, else its port
+func (s *Server) waitForService(ctx context.Context, svc app.Service) error {
+	if svc.HostPort <= 0 {
+		return nil
+	}
+	timeout := appReadyTimeout
+	if svc.Ready != nil && svc.Ready.Timeout > 0 {
+		timeout = time.Duration(svc.Ready.Timeout) * time.Second
+	}
+	readyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	if svc.Ready != nil && svc.Ready.HTTP != "" {
+		err = waitForLocalHTTP(readyCtx, svc.HostPort, svc.Ready.HTTP)
+	} else {
+		err = waitForLocalPort(readyCtx, svc.HostPort)
+	}
+	if err != nil && svc.ContainerID != "" {
+		// A container that died on startup says more than the timeout
+		if info, inspectErr := s.podman.InspectContainer(context.Background(), svc.ContainerID); inspectErr == nil && !info.State.Running {
+			return fmt.Errorf("container exited with code %d", info.State.ExitCode)
+		}
+	}
+	return err
+}
+
+// stopServices stops a multi-service app's containers, dependents first
+func (s *Server) stopServices(ctx context.Context, a *app.App, timeout int) error {
+	for i := len(a.Services) - 1; i >= 0; i-- {
+		if err := s.podman.StopContainer(ctx, serviceContainerName(a, a.Services[i].Name), timeout); err != nil {
+			return fmt.Errorf("%s: %w", a.Services[i].Name, err)
+		}
+	}
+	return nil
+}
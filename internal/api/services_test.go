@@ -0,0 +1,162 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// sourceDeploy uploads a one-file source tarball with config to /api/deploy
+// and returns the build output
+func (ts *testServer) sourceDeploy(config SourceDeployConfig) string {
+	ts.t.Helper()
+	var src bytes.Buffer
+	gz := gzip.NewWriter(&src)
+	tw := tar.NewWriter(gz)
+	readme := []byte("# shop\n")
+	tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0644, Size: int64(len(readme))})
+	tw.Write(readme)
+	tw.Close()
+	gz.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	configJSON, _ := json.Marshal(config)
+	mw.WriteField("config", string(configJSON))
+	part, _ := mw.CreateFormFile("source", "source.tar.gz")
+	part.Write(src.Bytes())
+	mw.Close()
+
+	req, _ := http.NewRequest("POST", ts.http.URL+"/api/deploy", &body)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatalf("POST /api/deploy: %v", err)
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	return string(out)
+}
+
+func TestDeployServices(t *testing.T) {
+	ts := newTestServer(t)
+
+	output := ts.sourceDeploy(SourceDeployConfig{
+		Name:   "svc-shop",
+		Domain: "svc-shop.test",
+		Port:   3000,
+		Services: map[string]*ServiceSpec{
+			"web":   {Image: "ghcr.io/example/web:1", Port: 3000, DependsOn: []string{"db"}, Env: map[string]string{"DATABASE_URL": "postgres://db:5432/shop"}},
+			"admin": {Image: "ghcr.io/example/admin:1", Port: 4000, Domain: "admin.svc-shop.test"},
+			"db":    {Image: "postgres:16", Port: 5432, Volumes: []string{"pgdata:/var/lib/postgresql/data"}},
+		},
+	})
+	if !strings.Contains(output, "Deploy complete!") {
+		t.Fatalf("deploy output:\n%s", output)
+	}
+	if !strings.Contains(output, "Services (start order): admin, db, web") {
+		t.Fatalf("deploy output lacks the dependency order:\n%s", output)
+	}
+
+	var a app.App
+	ts.do("GET", "/api/apps/svc-shop", nil, &a)
+	if a.Status != app.StatusRunning || len(a.Services) != 3 {
+		t.Fatalf("app = %s with %d services, want running with 3", a.Status, len(a.Services))
+	}
+	web := ts.container("basepod-svc-shop-web")
+	if web == nil || web.State != "running" || a.ContainerID != web.ID {
+		t.Fatalf("web container = %+v, want the app's running main container", web)
+	}
+	for _, name := range []string{"basepod-svc-shop-db", "basepod-svc-shop-admin"} {
+		if c := ts.container(name); c == nil || c.State != "running" || c.Labels["basepod.service"] == "" {
+			t.Fatalf("%s = %+v, want a running service container", name, c)
+		}
+	}
+	ts.waitForRoute("basepod-svc-shop")
+	ts.waitForRoute("basepod-svc-shop-admin")
+
+	var pgdata VolumeInfo
+	ts.do("GET", "/api/volumes/basepod-svc-shop-pgdata", nil, &pgdata)
+	if pgdata.App != "svc-shop" || len(pgdata.UsedBy) != 1 || pgdata.UsedBy[0] != "basepod-svc-shop-db" {
+		t.Fatalf("pgdata = %+v, want svc-shop's volume used by the db container", pgdata)
+	}
+
+	if code := ts.do("POST", "/api/apps/svc-shop/stop", nil, nil); code != http.StatusOK {
+		t.Fatalf("stop: status %d", code)
+	}
+	if c := ts.container("basepod-svc-shop-db"); c.State == "running" {
+		t.Fatalf("db still running after stop")
+	}
+	if code := ts.do("DELETE", "/api/apps/svc-shop", nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+	if c := ts.container("basepod-svc-shop-db"); c != nil {
+		t.Fatalf("db container left after delete: %+v", c)
+	}
+}
+
+func TestValidateServiceSpecs(t *testing.T) {
+	t.Parallel()
+	cases := map[string]map[string]*ServiceSpec{
+		"no image":     {"web": {Port: 80}},
+		"bad name":     {"Web_1": {Image: "nginx"}},
+		"host path":    {"web": {Image: "nginx", Volumes: []string{"/etc:/data"}}},
+		"cycle":        {"a": {Image: "x", DependsOn: []string{"b"}}, "b": {Image: "x", DependsOn: []string{"a"}}},
+		"unknown dep":  {"web": {Image: "nginx", DependsOn: []string{"db"}}},
+		"domain no ip": {"web": {Image: "nginx", Domain: "web.test"}},
+		"ready no ip":  {"web": {Image: "nginx", Ready: &app.ServiceReady{}}},
+		"ready path":   {"web": {Image: "nginx", Port: 80, Ready: &app.ServiceReady{HTTP: "health"}}},
+	}
+	for name, services := range cases {
+		if _, err := validateServiceSpecs(services); err == nil {
+			t.Fatalf("%s: want an error", name)
+		}
+	}
+
+	order, err := validateServiceSpecs(map[string]*ServiceSpec{
+		"web":    {Build: ServiceBuildSpec{Context: "web"}, Port: 3000, DependsOn: []string{"db", "cache"}},
+		"db":     {Image: "postgres:16", Volumes: []string{"data:/var/lib/postgresql/data:ro"}},
+		"cache":  {Image: "redis:7"},
+		"worker": {Image: "worker", DependsOn: []string{"db"}},
+	})
+	if err != nil || strings.Join(order, ",") != "cache,db,web,worker" {
+		t.Fatalf("order = %v, %v", order, err)
+	}
+}
+
+func TestWaitForLocalHTTP(t *testing.T) {
+	t.Parallel()
+	var ready atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	if err := waitForLocalHTTP(ctx, port, "/health"); err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Fatalf("waitForLocalHTTP on a 503 = %v, want a timeout naming the status", err)
+	}
+
+	ready.Store(true)
+	if err := waitForLocalHTTP(context.Background(), port, "/health"); err != nil {
+		t.Fatalf("waitForLocalHTTP: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/auth"
+)
+
+// sessionCleanupInterval is how often expired sessions are dropped
+const sessionCleanupInterval = time.Hour
+
+// SessionInfo is a login session as the sessions API shows it
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id,omitempty"`
+	UserEmail  string    `json:"user_email,omitempty"`
+	UserRole   string    `json:"user_role"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// runSessionCleanup drops expired sessions from memory and storage
+func (s *Server) runSessionCleanup() {
+	ticker := time.NewTicker(sessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.auth.CleanupExpiredSessions()
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+// requestIP returns the client address of r. The API is usually reached
+// through Caddy, so X-Forwarded-For is trusted when the peer is local.
+func requestIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	return host
+}
+
+// sameAccount reports whether two sessions belong to the same account. The
+// server password's sessions all share the empty user ID.
+func sameAccount(a, b *auth.Session) bool {
+	return a.UserID == b.UserID
+}
+
+// handleListSessions lists the caller's sessions; admins can pass ?all=1
+// to see everyone's
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	token := s.getSessionToken(r)
+	caller := s.auth.GetSession(token)
+	if caller == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	all := r.URL.Query().Get("all") != ""
+	if all && caller.UserRole != "admin" {
+		errorResponse(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+
+	currentID := auth.SessionID(token)
+	result := []SessionInfo{}
+	for _, sess := range s.auth.ListSessions() {
+		if !all && !sameAccount(&sess, caller) {
+			continue
+		}
+		result = append(result, SessionInfo{
+			ID:         sess.ID,
+			UserID:     sess.UserID,
+			UserEmail:  sess.UserEmail,
+			UserRole:   sess.UserRole,
+			IP:         sess.IP,
+			UserAgent:  sess.UserAgent,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: sess.LastSeenAt,
+			ExpiresAt:  sess.ExpiresAt,
+			Current:    sess.ID == currentID,
+		})
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"sessions": result})
+}
+
+// handleRevokeSession signs out one session. Users can revoke their own
+// sessions; admins can revoke anyone's.
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	caller := s.auth.GetSession(s.getSessionToken(r))
+	if caller == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id := r.PathValue("id")
+	var target *auth.Session
+	for _, sess := range s.auth.ListSessions() {
+		if sess.ID == id {
+			target = &sess
+			break
+		}
+	}
+	if target == nil || (caller.UserRole != "admin" && !sameAccount(target, caller)) {
+		errorResponse(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	s.auth.DeleteSessionByID(id)
+
+	s.logActivity("user", "session_revoke", "user", target.UserID, target.UserEmail, "success", "session "+id)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// handleRevokeOtherSessions signs out every session of the caller's
+// account except the one making the request
+func (s *Server) handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	token := s.getSessionToken(r)
+	caller := s.auth.GetSession(token)
+	if caller == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentID := auth.SessionID(token)
+	revoked := 0
+	for _, sess := range s.auth.ListSessions() {
+		if sess.ID != currentID && sameAccount(&sess, caller) && s.auth.DeleteSessionByID(sess.ID) {
+			revoked++
+		}
+	}
+
+	s.logActivity("user", "session_revoke_others", "user", caller.UserID, caller.UserEmail, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]int{"revoked": revoked})
+}
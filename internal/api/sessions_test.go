@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSessionsAPI(t *testing.T) {
+	ts := newTestServer(t)
+	body := map[string]interface{}{"email": "sessions@example.com", "password": "sessions-pass", "role": "viewer"}
+	if code := ts.do("POST", "/api/users", body, nil); code != http.StatusCreated {
+		t.Fatalf("create user: status %d", code)
+	}
+	laptop := ts.login("sessions@example.com", "sessions-pass")
+	phone := ts.login("sessions@example.com", "sessions-pass")
+	ci := ts.login("sessions@example.com", "sessions-pass")
+
+	var list struct {
+		Sessions []SessionInfo `json:"sessions"`
+	}
+	if code := ts.doAs(laptop, "GET", "/api/auth/sessions", nil, &list); code != http.StatusOK {
+		t.Fatalf("list sessions: status %d", code)
+	}
+	if len(list.Sessions) != 3 {
+		t.Fatalf("user sees %d sessions, want 3", len(list.Sessions))
+	}
+	var current, other *SessionInfo
+	for i, sess := range list.Sessions {
+		if sess.UserEmail != "sessions@example.com" || sess.IP == "" {
+			t.Fatalf("session = %+v, want the user's with an IP", sess)
+		}
+		if sess.Current {
+			current = &list.Sessions[i]
+		} else {
+			other = &list.Sessions[i]
+		}
+	}
+	if current == nil || other == nil {
+		t.Fatalf("sessions = %+v, want one marked current", list.Sessions)
+	}
+
+	// Users can't see or revoke the admin's sessions
+	if code := ts.doAs(laptop, "GET", "/api/auth/sessions?all=1", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("non-admin listing all sessions: status %d, want 403", code)
+	}
+	var adminList struct {
+		Sessions []SessionInfo `json:"sessions"`
+	}
+	ts.do("GET", "/api/auth/sessions", nil, &adminList)
+	for _, sess := range adminList.Sessions {
+		if code := ts.doAs(laptop, "DELETE", "/api/auth/sessions/"+sess.ID, nil, nil); code != http.StatusNotFound {
+			t.Fatalf("revoking the admin's session: status %d, want 404", code)
+		}
+	}
+
+	if code := ts.doAs(laptop, "DELETE", "/api/auth/sessions/"+other.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("revoke session: status %d", code)
+	}
+	var revoked struct {
+		Revoked int `json:"revoked"`
+	}
+	if code := ts.doAs(laptop, "DELETE", "/api/auth/sessions", nil, &revoked); code != http.StatusOK || revoked.Revoked != 1 {
+		t.Fatalf("revoke others: status %d, revoked %d, want 1", code, revoked.Revoked)
+	}
+	for _, token := range []string{phone, ci} {
+		if code := ts.doAs(token, "GET", "/api/auth/me", nil, nil); code != http.StatusUnauthorized {
+			t.Fatalf("revoked session: status %d, want 401", code)
+		}
+	}
+	if code := ts.doAs(laptop, "GET", "/api/auth/me", nil, nil); code != http.StatusOK {
+		t.Fatalf("current session after revoking others: status %d, want 200", code)
+	}
+	if stored, _ := ts.storage.LoadSessions(); len(stored) == 0 {
+		t.Fatalf("sessions were not persisted")
+	}
+}
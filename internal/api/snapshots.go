@@ -0,0 +1,451 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/backup"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+const (
+	defaultSnapshotKeep = 3
+	maxSnapshotKeep     = 50
+	snapshotDumpTimeout = 10 * time.Minute
+	snapshotDumpFile    = "dump.out"
+)
+
+// snapshotsDir is where an app's snapshots are kept, one directory each
+func snapshotsDir(appID string) (string, error) {
+	paths, err := config.GetPaths()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(paths.Data, "snapshots", appID), nil
+}
+
+// validSnapshotID reports whether id is one basepod generated, so it's safe
+// to use as a path segment
+func validSnapshotID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshotKeep is how many snapshots an app keeps
+func snapshotKeep(cfg *app.SnapshotConfig) int {
+	if cfg == nil || cfg.Keep <= 0 {
+		return defaultSnapshotKeep
+	}
+	return cfg.Keep
+}
+
+// snapshotsToPrune returns the snapshots beyond the newest keep
+func snapshotsToPrune(snaps []app.Snapshot, keep int) []app.Snapshot {
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.After(snaps[j].CreatedAt) })
+	if len(snaps) <= keep {
+		return nil
+	}
+	return snaps[keep:]
+}
+
+// execStreams splits a non-TTY exec's multiplexed output into stdout and
+// stderr
+func execStreams(data []byte) ([]byte, []byte) {
+	var stdout, stderr []byte
+	podman.ReadLogFrames(bytes.NewReader(data), func(stream string, payload []byte) {
+		if stream == "stderr" {
+			stderr = append(stderr, payload...)
+		} else {
+			stdout = append(stdout, payload...)
+		}
+	})
+	return stdout, stderr
+}
+
+// listSnapshots returns an app's snapshots, newest first
+func listSnapshots(appID string) ([]app.Snapshot, error) {
+	dir, err := snapshotsDir(appID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []app.Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snaps := []app.Snapshot{}
+	for _, e := range entries {
+		if !e.IsDir() || !validSnapshotID(e.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name(), "snapshot.json"))
+		if err != nil {
+			continue // Still being written, or a failed snapshot
+		}
+		var snap app.Snapshot
+		if json.Unmarshal(data, &snap) == nil {
+			snaps = append(snaps, snap)
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.After(snaps[j].CreatedAt) })
+	return snaps, nil
+}
+
+// getSnapshot returns one of an app's snapshots and its directory
+func getSnapshot(appID, id string) (*app.Snapshot, string, error) {
+	if !validSnapshotID(id) {
+		return nil, "", nil
+	}
+	dir, err := snapshotsDir(appID)
+	if err != nil {
+		return nil, "", err
+	}
+	dir = filepath.Join(dir, id)
+	data, err := os.ReadFile(filepath.Join(dir, "snapshot.json"))
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var snap app.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, "", err
+	}
+	return &snap, dir, nil
+}
+
+// preDeploySnapshot snapshots an app's volumes before a deploy replaces its
+// container, if the app has snapshots turned on. It returns "" when there
+// was nothing to snapshot.
+func (s *Server) preDeploySnapshot(ctx context.Context, a *app.App) (string, error) {
+	cfg := a.Deployment.Snapshot
+	if cfg == nil || !cfg.Enabled || a.ContainerID == "" {
+		return "", nil
+	}
+	snap, err := s.createSnapshot(ctx, a, "deploy")
+	if err != nil || snap == nil {
+		return "", err
+	}
+	return snap.ID, nil
+}
+
+// createSnapshot runs the app's dump command in its running container and
+// exports its named volumes, then prunes snapshots beyond the app's keep
+func (s *Server) createSnapshot(ctx context.Context, a *app.App, trigger string) (*app.Snapshot, error) {
+	var volumes []string
+	for _, v := range a.Volumes {
+		if v.Name != "" && v.HostPath == "" {
+			volumes = append(volumes, appVolumeName(a, v))
+		}
+	}
+	dumpCommand := ""
+	if a.Deployment.Snapshot != nil {
+		dumpCommand = a.Deployment.Snapshot.DumpCommand
+	}
+	if len(volumes) == 0 && dumpCommand == "" {
+		return nil, nil
+	}
+
+	root, err := snapshotsDir(a.ID)
+	if err != nil {
+		return nil, err
+	}
+	snap := &app.Snapshot{
+		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
+		AppID:     a.ID,
+		Image:     a.Image,
+		Volumes:   volumes,
+		Trigger:   trigger,
+		CreatedAt: time.Now(),
+	}
+	dir := filepath.Join(root, snap.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	fail := func(err error) (*app.Snapshot, error) {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	// Dump first: the dump is consistent on its own, the volume copy is
+	// taken from a running database
+	if dumpCommand != "" {
+		if a.ContainerID == "" {
+			return fail(fmt.Errorf("dump_command needs a running container"))
+		}
+		output, err := s.runSnapshotDump(ctx, a, dumpCommand)
+		if err != nil {
+			return fail(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, snapshotDumpFile), output, 0600); err != nil {
+			return fail(err)
+		}
+		snap.Dump = true
+		snap.Size += int64(len(output))
+	}
+
+	for _, volume := range volumes {
+		tarPath := filepath.Join(dir, volume+".tar")
+		if err := backup.ExportVolume(ctx, volume, tarPath); err != nil {
+			return fail(fmt.Errorf("volume %s: %w", volume, err))
+		}
+		if info, err := os.Stat(tarPath); err == nil {
+			snap.Size += info.Size()
+		}
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fail(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "snapshot.json"), data, 0600); err != nil {
+		return fail(err)
+	}
+
+	if snaps, err := listSnapshots(a.ID); err == nil {
+		for _, old := range snapshotsToPrune(snaps, snapshotKeep(a.Deployment.Snapshot)) {
+			if err := os.RemoveAll(filepath.Join(root, old.ID)); err != nil {
+				log.Printf("Snapshot %s/%s: %v", a.Name, old.ID, err)
+			}
+		}
+	}
+	return snap, nil
+}
+
+// runSnapshotDump runs the dump command in the app's container and returns
+// its stdout; a non-zero exit fails the snapshot
+func (s *Server) runSnapshotDump(ctx context.Context, a *app.App, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, snapshotDumpTimeout)
+	defer cancel()
+
+	execID, err := s.podman.ExecCreateDetached(ctx, a.ContainerID, []string{"/bin/sh", "-c", command})
+	if err != nil {
+		return nil, fmt.Errorf("dump command: %w", err)
+	}
+	raw, err := s.podman.ExecStart(ctx, execID)
+	if err != nil {
+		return nil, fmt.Errorf("dump command: %w", err)
+	}
+	stdout, stderr := execStreams([]byte(raw))
+
+	inspect, err := s.podman.ExecInspect(context.Background(), execID)
+	if err != nil {
+		return nil, fmt.Errorf("dump command: %w", err)
+	}
+	if inspect.ExitCode != 0 {
+		msg := strings.TrimSpace(string(stderr))
+		if len(msg) > 500 {
+			msg = msg[len(msg)-500:]
+		}
+		return nil, fmt.Errorf("dump command exited with %d: %s", inspect.ExitCode, msg)
+	}
+	return stdout, nil
+}
+
+// restoreSnapshot puts a snapshot's volumes back with the app stopped. With
+// rollback the app comes back on the image the snapshot was taken from,
+// otherwise its current container is started again.
+func (s *Server) restoreSnapshot(ctx context.Context, a *app.App, snap *app.Snapshot, dir string, rollback bool) error {
+	if a.ContainerID != "" {
+		_ = s.podman.StopContainer(ctx, a.ContainerID, 10)
+	}
+	for _, volume := range snap.Volumes {
+		if err := backup.ImportVolume(ctx, volume, filepath.Join(dir, volume+".tar")); err != nil {
+			return fmt.Errorf("volume %s: %w", volume, err)
+		}
+	}
+
+	if rollback && snap.Image != "" && snap.Image != a.Image {
+		_, err := s.rollbackApp(ctx, a, &app.DeploymentRecord{ID: "snapshot " + snap.ID, Image: snap.Image})
+		return err
+	}
+	if a.ContainerID == "" {
+		return nil
+	}
+	if err := s.podman.StartContainer(ctx, a.ContainerID); err != nil {
+		return fmt.Errorf("Failed to start container: %w", err)
+	}
+	return nil
+}
+
+// handleListSnapshots lists an app's snapshots
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	snaps, err := listSnapshots(a.ID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cfg := a.Deployment.Snapshot
+	if cfg == nil {
+		cfg = &app.SnapshotConfig{}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"config":    cfg,
+		"keep":      snapshotKeep(cfg),
+		"snapshots": snaps,
+	})
+}
+
+// handleCreateSnapshot takes a snapshot now
+func (s *Server) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	snap, err := s.createSnapshot(r.Context(), a, "manual")
+	if err != nil {
+		s.logActivity("user", "snapshot", "app", a.ID, a.Name, "failed", err.Error())
+		errorResponse(w, http.StatusInternalServerError, "Snapshot failed: "+err.Error())
+		return
+	}
+	if snap == nil {
+		errorResponse(w, http.StatusBadRequest, "App has no named volumes or dump command to snapshot")
+		return
+	}
+	s.logActivity("user", "snapshot", "app", a.ID, a.Name, "success", snap.ID)
+	jsonResponse(w, http.StatusCreated, snap)
+}
+
+// handleUpdateSnapshotConfig turns pre-deploy snapshots on or off for an app
+func (s *Server) handleUpdateSnapshotConfig(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	var cfg app.SnapshotConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if cfg.Keep < 0 || cfg.Keep > maxSnapshotKeep {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("keep must be at most %d", maxSnapshotKeep))
+		return
+	}
+	cfg.DumpCommand = strings.TrimSpace(cfg.DumpCommand)
+
+	a.Deployment.Snapshot = &cfg
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	state := "disabled"
+	if cfg.Enabled {
+		state = "enabled"
+	}
+	s.logActivity("user", "snapshot_config", "app", a.ID, a.Name, "success", state)
+	jsonResponse(w, http.StatusOK, cfg)
+}
+
+// handleRestoreSnapshot restores an app's volumes from a snapshot
+func (s *Server) handleRestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	snap, dir, err := getSnapshot(a.ID, r.PathValue("snapshotId"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if snap == nil {
+		errorResponse(w, http.StatusNotFound, "Snapshot not found")
+		return
+	}
+	var req struct {
+		Rollback bool `json:"rollback"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if err := s.restoreSnapshot(r.Context(), a, snap, dir, req.Rollback); err != nil {
+		s.logActivity("user", "snapshot_restore", "app", a.ID, a.Name, "failed", err.Error())
+		errorResponse(w, http.StatusInternalServerError, "Restore failed: "+err.Error())
+		return
+	}
+	s.logActivity("user", "snapshot_restore", "app", a.ID, a.Name, "success", snap.ID)
+
+	message := fmt.Sprintf("Restored %d volume(s) from snapshot %s", len(snap.Volumes), snap.ID)
+	if snap.Dump {
+		message += "; the database dump is not loaded automatically, fetch it with bp snapshots dump"
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"message":  message,
+		"snapshot": snap,
+		"image":    a.Image,
+	})
+}
+
+// handleSnapshotDump downloads the dump saved with a snapshot
+func (s *Server) handleSnapshotDump(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	snap, dir, err := getSnapshot(a.ID, r.PathValue("snapshotId"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if snap == nil || !snap.Dump {
+		errorResponse(w, http.StatusNotFound, "Snapshot has no dump")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.dump", a.Name, snap.ID))
+	http.ServeFile(w, r, filepath.Join(dir, snapshotDumpFile))
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestExecStreams(t *testing.T) {
+	t.Parallel()
+
+	var data []byte
+	data = append(data, logFrame(1, "CREATE TABLE")...)
+	data = append(data, logFrame(2, "warning")...)
+	data = append(data, logFrame(1, "")...)
+	data = append(data, logFrame(1, " users;")...)
+
+	stdout, stderr := execStreams(data)
+	if string(stdout) != "CREATE TABLE users;" {
+		t.Fatalf("stdout = %q", stdout)
+	}
+	if string(stderr) != "warning" {
+		t.Fatalf("stderr = %q", stderr)
+	}
+}
+
+func TestValidSnapshotID(t *testing.T) {
+	t.Parallel()
+
+	for id, want := range map[string]bool{
+		"1760000000000000000": true,
+		"":                    false,
+		"../other":            false,
+		"123abc":              false,
+	} {
+		if got := validSnapshotID(id); got != want {
+			t.Fatalf("validSnapshotID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestSnapshotsToPrune(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	snaps := []app.Snapshot{
+		{ID: "2", CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "4", CreatedAt: now},
+		{ID: "1", CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: "3", CreatedAt: now.Add(-time.Hour)},
+	}
+	pruned := snapshotsToPrune(snaps, 2)
+	if len(pruned) != 2 || pruned[0].ID != "2" || pruned[1].ID != "1" {
+		t.Fatalf("pruned = %+v, want the two oldest", pruned)
+	}
+	if got := snapshotsToPrune(snaps, 5); got != nil {
+		t.Fatalf("pruned %d snapshots with room to keep all", len(got))
+	}
+	if got := snapshotKeep(nil); got != defaultSnapshotKeep {
+		t.Fatalf("snapshotKeep(nil) = %d", got)
+	}
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+)
+
+// appStaticSite returns how an app's static route serves its files
+func appStaticSite(a *app.App) caddy.StaticSite {
+	site := caddy.StaticSite{SPA: a.Static.SPAFallback(), Precompressed: a.Static.Precompresses()}
+	if a.Static != nil {
+		site.NotFound = a.Static.NotFound
+	}
+	return site
+}
+
+// parseRedirectsFile reads a _redirects file: one "from to [status]" rule
+// per line, # for comments. A from path may end in * to match everything
+// under it.
+func parseRedirectsFile(data []byte) ([]app.RedirectRule, error) {
+	var rules []app.RedirectRule
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("_redirects line %d: want \"from to [status]\"", n)
+		}
+		rule := app.RedirectRule{From: fields[0], To: fields[1]}
+		if strings.Contains(rule.To, ":splat") || strings.Contains(rule.From, "/:") {
+			return nil, fmt.Errorf("_redirects line %d: placeholders like :splat aren't supported", n)
+		}
+		if len(fields) == 3 {
+			status, err := strconv.Atoi(strings.TrimSuffix(fields[2], "!"))
+			if err != nil {
+				return nil, fmt.Errorf("_redirects line %d: invalid status %q", n, fields[2])
+			}
+			rule.Status = status
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// parseHeadersFile reads a _headers file: a path on its own line followed
+// by indented "Name: value" lines for it, # for comments
+func parseHeadersFile(data []byte) ([]app.HeaderRule, error) {
+	var rules []app.HeaderRule
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if raw[0] != ' ' && raw[0] != '\t' {
+			rules = append(rules, app.HeaderRule{Path: line, Set: map[string]string{}})
+			continue
+		}
+		if len(rules) == 0 {
+			return nil, fmt.Errorf("_headers line %d: header before any path", n)
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("_headers line %d: want \"Name: value\"", n)
+		}
+		rules[len(rules)-1].Set[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return rules, scanner.Err()
+}
+
+// loadStaticFiles adds the rules in a site's _redirects and _headers files
+// to cfg and removes the files, so they aren't served. The file's rules come
+// after the ones from basepod.yaml.
+func loadStaticFiles(dir string, cfg *app.StaticConfig) (*app.StaticConfig, error) {
+	var redirects []app.RedirectRule
+	var headers []app.HeaderRule
+	if data, err := os.ReadFile(filepath.Join(dir, "_redirects")); err == nil {
+		if redirects, err = parseRedirectsFile(data); err != nil {
+			return nil, err
+		}
+		os.Remove(filepath.Join(dir, "_redirects"))
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "_headers")); err == nil {
+		if headers, err = parseHeadersFile(data); err != nil {
+			return nil, err
+		}
+		os.Remove(filepath.Join(dir, "_headers"))
+	}
+	if cfg == nil && redirects == nil && headers == nil {
+		return nil, nil
+	}
+
+	merged := &app.StaticConfig{}
+	if cfg != nil {
+		*merged = *cfg
+		merged.Redirects = append([]app.RedirectRule(nil), cfg.Redirects...)
+		merged.Headers = append([]app.HeaderRule(nil), cfg.Headers...)
+	}
+	merged.Redirects = append(merged.Redirects, redirects...)
+	merged.Headers = append(merged.Headers, headers...)
+	return merged, nil
+}
+
+// prepareStatic checks a static site's config and fills in redirect statuses
+func prepareStatic(c *app.StaticConfig) error {
+	if c.NotFound != "" {
+		if !strings.HasPrefix(c.NotFound, "/") {
+			return fmt.Errorf("not_found %q must start with /", c.NotFound)
+		}
+		if c.SPA != nil && *c.SPA {
+			return fmt.Errorf("not_found is only used with spa: false")
+		}
+	}
+	// Redirects and headers follow the same rules as routing's
+	return prepareRouting(&app.RoutingConfig{Redirects: c.Redirects, Headers: c.Headers})
+}
+
+// handleGetStatic returns how a static site's files are served
+func (s *Server) handleGetStatic(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	static := a.Static
+	if static == nil {
+		static = &app.StaticConfig{}
+	}
+	jsonResponse(w, http.StatusOK, static)
+}
+
+// handleUpdateStatic replaces a static site's config, as a deploy with a
+// static: block in basepod.yaml does
+func (s *Server) handleUpdateStatic(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Type != app.AppTypeStatic {
+		errorResponse(w, http.StatusBadRequest, "Only static sites have static settings; use routing for other apps")
+		return
+	}
+
+	var static app.StaticConfig
+	if err := json.NewDecoder(r.Body).Decode(&static); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := prepareStatic(&static); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.Static = &static
+	a.UpdatedAt = time.Now()
+	if err := s.storage.UpdateApp(a); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.refreshAppRoutes(a)
+	s.logActivity("user", "update_static", "app", a.ID, a.Name, "success", "")
+	jsonResponse(w, http.StatusOK, a.Static)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestParseRedirectsFile(t *testing.T) {
+	t.Parallel()
+
+	rules, err := parseRedirectsFile([]byte(`# Moved pages
+/old-blog/*   https://blog.example.com   301
+/pricing      /plans                     302!
+
+/docs /guide
+`))
+	if err != nil {
+		t.Fatalf("parseRedirectsFile: %v", err)
+	}
+	if len(rules) != 3 || rules[0].From != "/old-blog/*" || rules[0].Status != 301 || rules[1].Status != 302 || rules[2].Status != 0 {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	for _, bad := range []string{"/only-from", "/blog/* /news/:splat 301", "/a /b fast"} {
+		if _, err := parseRedirectsFile([]byte(bad)); err == nil {
+			t.Fatalf("parseRedirectsFile(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseHeadersFile(t *testing.T) {
+	t.Parallel()
+
+	rules, err := parseHeadersFile([]byte(`/assets/*
+  Cache-Control: public, max-age=31536000, immutable
+  X-Robots-Tag: noindex
+# HTML is revalidated
+/*.html
+	Cache-Control: no-cache
+`))
+	if err != nil {
+		t.Fatalf("parseHeadersFile: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Path != "/assets/*" || rules[0].Set["Cache-Control"] != "public, max-age=31536000, immutable" || len(rules[0].Set) != 2 {
+		t.Fatalf("rules = %+v", rules)
+	}
+	if rules[1].Path != "/*.html" || rules[1].Set["Cache-Control"] != "no-cache" {
+		t.Fatalf("html rule = %+v", rules[1])
+	}
+
+	if _, err := parseHeadersFile([]byte("  Cache-Control: no-cache\n")); err == nil {
+		t.Fatal("a header before any path should fail")
+	}
+}
+
+func TestLoadStaticFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "_redirects"), []byte("/old /new\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "_headers"), []byte("/assets/*\n  Cache-Control: max-age=60\n"), 0644)
+
+	yamlConfig := &app.StaticConfig{NotFound: "/404.html", Redirects: []app.RedirectRule{{From: "/a", To: "/b"}}}
+	cfg, err := loadStaticFiles(dir, yamlConfig)
+	if err != nil {
+		t.Fatalf("loadStaticFiles: %v", err)
+	}
+	if len(cfg.Redirects) != 2 || cfg.Redirects[0].From != "/a" || cfg.Redirects[1].From != "/old" || len(cfg.Headers) != 1 {
+		t.Fatalf("config = %+v, want basepod.yaml rules then the files'", cfg)
+	}
+	if len(yamlConfig.Redirects) != 1 {
+		t.Fatalf("basepod.yaml config was changed: %+v", yamlConfig)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "_redirects")); !os.IsNotExist(err) {
+		t.Fatal("_redirects should not be served")
+	}
+	if err := prepareStatic(cfg); err != nil || cfg.Redirects[1].Status != 301 {
+		t.Fatalf("prepareStatic: %v, status %d", err, cfg.Redirects[1].Status)
+	}
+	if cfg.SPAFallback() {
+		t.Fatal("a site with a 404 page should not fall back to /index.html")
+	}
+
+	if cfg, err := loadStaticFiles(t.TempDir(), nil); err != nil || cfg != nil {
+		t.Fatalf("no config and no files = %+v, %v; want nil", cfg, err)
+	}
+}
+
+func TestPrepareStatic(t *testing.T) {
+	t.Parallel()
+
+	on := true
+	cases := map[string]app.StaticConfig{
+		"relative 404 page":  {NotFound: "404.html"},
+		"SPA with 404 page":  {SPA: &on, NotFound: "/404.html"},
+		"rewrite status":     {Redirects: []app.RedirectRule{{From: "/app/*", To: "/index.html", Status: 200}}},
+		"header without set": {Headers: []app.HeaderRule{{Path: "/assets/*"}}},
+	}
+	for name, c := range cases {
+		if err := prepareStatic(&c); err == nil {
+			t.Fatalf("%s: prepareStatic should fail", name)
+		}
+	}
+
+	var none *app.StaticConfig
+	if !none.SPAFallback() || !(&app.StaticConfig{}).SPAFallback() {
+		t.Fatal("sites should fall back to /index.html by default")
+	}
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+	"github.com/google/uuid"
+)
+
+// taskOutputBytes is how much of a task's output its run keeps
+const taskOutputBytes = 64 << 10
+
+// TaskEvent is one line of a task's streamed output (bp run-task). A task
+// streams output events and ends with exactly one exit event.
+type TaskEvent struct {
+	Type     string `json:"type"`                // output or exit
+	Stream   string `json:"stream,omitempty"`    // output: stdout or stderr
+	Data     string `json:"data,omitempty"`      // output
+	ExitCode *int   `json:"exit_code,omitempty"` // exit: the command's exit code
+	Error    string `json:"error,omitempty"`     // exit: why the task couldn't run or finish
+	RunID    string `json:"run_id,omitempty"`    // exit
+}
+
+// taskContainerName is the one-off container for a task run
+func taskContainerName(appName, runID string) string {
+	return fmt.Sprintf("basepod-%s-task-%s", appName, runID[:8])
+}
+
+// appendTail appends data to buf and keeps only its last max bytes
+func appendTail(buf, data []byte, max int) []byte {
+	buf = append(buf, data...)
+	if len(buf) > max {
+		buf = append(buf[:0], buf[len(buf)-max:]...)
+	}
+	return buf
+}
+
+// runTaskContainer runs a task's command in a one-off container from the
+// app's image, passing its output to fn as it comes, and returns the exit
+// code. The container is removed afterwards.
+func (s *Server) runTaskContainer(ctx context.Context, a *app.App, run *app.TaskRun, fn func(stream string, data []byte)) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	containerID, err := s.createOneOffContainer(ctx, a, taskContainerName(a.Name, run.ID), run.Command, map[string]string{
+		"basepod.task":     run.ID,
+		"basepod.task.app": a.ID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create task container: %w", err)
+	}
+	defer s.podman.RemoveContainer(context.Background(), containerID, true)
+
+	if err := s.podman.StartContainer(ctx, containerID); err != nil {
+		return 0, fmt.Errorf("failed to start task container: %w", err)
+	}
+	if logs, err := s.podman.ContainerLogs(ctx, containerID, podman.LogOpts{Stdout: true, Stderr: true, Follow: true}); err == nil {
+		podman.ReadLogFrames(logs, fn)
+		logs.Close()
+	}
+
+	exitCode, err := s.podman.WaitContainer(ctx, containerID)
+	if ctx.Err() != nil {
+		_ = s.podman.StopContainer(context.Background(), containerID, 10)
+		return 0, fmt.Errorf("timed out after %s", jobTimeout)
+	}
+	return exitCode, err
+}
+
+// handleRunTask runs a one-off command in a throwaway container from an
+// app's image and streams its output as JSON lines of TaskEvents. The task
+// keeps running if the client goes away, and its run is recorded either way.
+func (s *Server) handleRunTask(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Type == app.AppTypeStatic || a.Type == app.AppTypeMLX || a.RedirectURL != "" {
+		errorResponse(w, http.StatusBadRequest, "Tasks are only supported for container apps")
+		return
+	}
+	if a.Image == "" {
+		errorResponse(w, http.StatusBadRequest, "App has not been deployed yet")
+		return
+	}
+
+	var req struct {
+		Command []string `json:"command"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Command) == 0 || req.Command[0] == "" {
+		errorResponse(w, http.StatusBadRequest, "command is required")
+		return
+	}
+
+	run := &app.TaskRun{
+		ID:        uuid.New().String(),
+		AppID:     a.ID,
+		Command:   req.Command,
+		StartedAt: time.Now(),
+		Status:    "running",
+	}
+	if err := s.storage.CreateTaskRun(run); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Stream until the task ends: lift the server's write timeout and push
+	// each frame out as it arrives
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	emit := func(ev TaskEvent) {
+		// Fails once the client is gone; the task carries on regardless
+		if enc.Encode(ev) == nil {
+			rc.Flush()
+		}
+	}
+
+	var output []byte
+	exitCode, runErr := s.runTaskContainer(context.Background(), a, run, func(stream string, data []byte) {
+		output = appendTail(output, data, taskOutputBytes)
+		emit(TaskEvent{Type: "output", Stream: stream, Data: string(data)})
+	})
+
+	ended := time.Now()
+	run.EndedAt = &ended
+	run.ExitCode = exitCode
+	run.Output = string(output)
+	run.Status = "success"
+	if runErr != nil {
+		run.Status = "failed"
+		run.Error = runErr.Error()
+	} else if exitCode != 0 {
+		run.Status = "failed"
+	}
+	if err := s.storage.UpdateTaskRun(run); err != nil {
+		log.Printf("Warning: failed to record task run %s: %v", run.ID, err)
+	}
+
+	details := fmt.Sprintf("%s (exit %d)", strings.Join(run.Command, " "), exitCode)
+	if runErr != nil {
+		details = fmt.Sprintf("%s: %v", strings.Join(run.Command, " "), runErr)
+	}
+	s.logActivity("user", "run_task", "app", a.ID, a.Name, run.Status, details)
+
+	emit(TaskEvent{Type: "exit", ExitCode: &exitCode, Error: run.Error, RunID: run.ID})
+}
+
+// handleListTaskRuns lists an app's recent task runs
+func (s *Server) handleListTaskRuns(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	runs, err := s.storage.ListTaskRuns(a.ID, 20)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"tasks": runs})
+}
@@ -0,0 +1,14 @@
+package api
+
+import "testing"
+
+func TestAppendTail(t *testing.T) {
+	t.Parallel()
+
+	var buf []byte
+	buf = appendTail(buf, []byte("hello "), 8)
+	buf = appendTail(buf, []byte("world"), 8)
+	if string(buf) != "lo world" {
+		t.Fatalf("tail = %q, want %q", buf, "lo world")
+	}
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
+	"github.com/base-go/basepod/internal/mlx"
 	"github.com/base-go/basepod/internal/templates"
 )
 
@@ -48,6 +50,8 @@ func hardenTemplateEnv(templateID, appName string, env map[string]string) {
 		"RABBITMQ_DEFAULT_PASS":      24,
 		"KEY":                        32,
 		"SECRET":                     48,
+		"WEBUI_SECRET_KEY":           48,
+		"ACCESS_CODE":                24,
 	} {
 		if value, ok := env[key]; ok && shouldRegenerateTemplateSecret(value) {
 			env[key] = generateRandomString(length)
@@ -85,6 +89,57 @@ func hardenTemplateEnv(templateID, appName string, env map[string]string) {
 	}
 }
 
+// ensureModelRunning starts model (or the first downloaded LLM) if no model is
+// being served and returns the model server port. A different model already
+// being served is left alone, since apps and chats may be using it.
+func ensureModelRunning(model string) (int, error) {
+	if !mlx.IsSupported() {
+		return 0, fmt.Errorf("model-bound templates require MLX: %s", mlx.GetUnsupportedReason())
+	}
+	svc := mlx.GetService()
+	status := svc.GetStatus()
+	if status.Running {
+		if model != "" && model != status.ActiveModel {
+			return 0, fmt.Errorf("%s is already being served; deploy with that model or stop it first with: bp model stop", status.ActiveModel)
+		}
+		return status.Port, nil
+	}
+
+	if model == "" {
+		for _, m := range svc.ListModels() {
+			if m.Downloaded && (m.Category == "chat" || m.Category == "code") {
+				model = m.ID
+				break
+			}
+		}
+	}
+	if model == "" {
+		return 0, fmt.Errorf("no chat model downloaded. Pull one with: bp model pull <model>")
+	}
+	if err := svc.Run(model); err != nil {
+		return 0, err
+	}
+	return svc.GetStatus().Port, nil
+}
+
+// bindModelEnv points a model-bound template at the local model gateway. User-supplied
+// OPENAI_API_BASE/KEY win, and template values like "${OPENAI_API_BASE}" are expanded.
+func bindModelEnv(env map[string]string, baseURL, apiKey string) {
+	if env["OPENAI_API_BASE"] == "" {
+		env["OPENAI_API_BASE"] = baseURL
+	}
+	if env["OPENAI_API_KEY"] == "" {
+		env["OPENAI_API_KEY"] = apiKey
+	}
+	for k, v := range env {
+		if strings.Contains(v, "${OPENAI_API_") {
+			env[k] = os.Expand(v, func(key string) string {
+				return env[key]
+			})
+		}
+	}
+}
+
 func shouldRegenerateTemplateSecret(value string) bool {
 	if strings.TrimSpace(value) == "" {
 		return true
@@ -108,3 +108,42 @@ func TestMergedTemplateEnvUpdatesTemplateURLsAndCommands(t *testing.T) {
 		t.Fatalf("expected redis command password %q, got %q", env["REDIS_PASSWORD"], command[2])
 	}
 }
+
+func TestBindModelEnvExpandsGatewayPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	tmpl := templates.GetTemplate("openwebui")
+	if tmpl == nil {
+		t.Fatal("openwebui template not found")
+	}
+	if !tmpl.ModelBinding {
+		t.Fatal("expected openwebui template to bind to the local model gateway")
+	}
+
+	env := mergedTemplateEnv(tmpl, nil, "chat", "chat.example.com")
+	bindModelEnv(env, "http://host.containers.internal:8080/v1", "basepod-local")
+
+	if env["OPENAI_API_BASE"] != "http://host.containers.internal:8080/v1" {
+		t.Fatalf("expected OPENAI_API_BASE to be injected, got %q", env["OPENAI_API_BASE"])
+	}
+	if env["OPENAI_API_BASE_URL"] != env["OPENAI_API_BASE"] {
+		t.Fatalf("expected OPENAI_API_BASE_URL to expand to the gateway, got %q", env["OPENAI_API_BASE_URL"])
+	}
+	if env["OPENAI_API_KEY"] != "basepod-local" {
+		t.Fatalf("expected OPENAI_API_KEY to be injected, got %q", env["OPENAI_API_KEY"])
+	}
+}
+
+func TestBindModelEnvPreservesUserGateway(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"OPENAI_API_BASE":  "https://api.example.com/v1",
+		"OPENAI_PROXY_URL": "${OPENAI_API_BASE}",
+	}
+	bindModelEnv(env, "http://host.containers.internal:8080/v1", "basepod-local")
+
+	if env["OPENAI_PROXY_URL"] != "https://api.example.com/v1" {
+		t.Fatalf("expected user-supplied gateway to win, got %q", env["OPENAI_PROXY_URL"])
+	}
+}
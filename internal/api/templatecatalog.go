@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/backup"
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/templates"
+)
+
+// templateCatalogInterval is how often the remote template catalog is synced
+const templateCatalogInterval = 6 * time.Hour
+
+// templateCatalogState holds the last synced remote template catalog
+type templateCatalogState struct {
+	mu      sync.RWMutex
+	catalog *templates.Catalog // nil until the first sync or cache load
+}
+
+// templateCatalogCachePath is where the last synced catalog is kept, so
+// restarts have it before the next sync
+func templateCatalogCachePath() string {
+	paths, err := config.GetPaths()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(paths.Data, "template-catalog.json")
+}
+
+// templateCatalog returns the current catalog, which may be nil
+func (s *Server) templateCatalog() *templates.Catalog {
+	s.catalog.mu.RLock()
+	defer s.catalog.mu.RUnlock()
+	return s.catalog.catalog
+}
+
+// runTemplateCatalogSync loads the cached catalog and keeps it synced with
+// templates.catalog_url
+func (s *Server) runTemplateCatalogSync() {
+	if s.config.Templates.CatalogURL == "" {
+		return
+	}
+	if data, err := os.ReadFile(templateCatalogCachePath()); err == nil {
+		if c, err := templates.ParseCatalog(data); err == nil {
+			s.catalog.mu.Lock()
+			s.catalog.catalog = c
+			s.catalog.mu.Unlock()
+		}
+	}
+
+	syncNow := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := s.syncTemplateCatalog(ctx); err != nil {
+			log.Printf("Template catalog: %v", err)
+		}
+	}
+	syncNow()
+
+	ticker := time.NewTicker(templateCatalogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			syncNow()
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+// syncTemplateCatalog fetches the remote catalog and notifies about apps
+// whose template got a newer recommended version
+func (s *Server) syncTemplateCatalog(ctx context.Context) error {
+	url := s.config.Templates.CatalogURL
+	if url == "" {
+		return fmt.Errorf("no template catalog is configured (templates.catalog_url in the server config)")
+	}
+	c, err := templates.FetchCatalog(ctx, &http.Client{Timeout: 30 * time.Second}, url)
+	if err != nil {
+		return err
+	}
+	if data, err := json.Marshal(c); err == nil {
+		if path := templateCatalogCachePath(); path != "" {
+			_ = os.WriteFile(path, data, 0600)
+		}
+	}
+
+	s.catalog.mu.Lock()
+	previous := s.catalog.catalog
+	s.catalog.catalog = c
+	s.catalog.mu.Unlock()
+
+	s.notifyTemplateUpdates(previous, c)
+	return nil
+}
+
+// notifyTemplateUpdates sends template_update for apps that have an update
+// under the new catalog that they didn't have under the previous one
+func (s *Server) notifyTemplateUpdates(previous, current *templates.Catalog) {
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return
+	}
+	for i := range apps {
+		a := &apps[i]
+		update := templateUpdateFor(a, current)
+		if update == nil {
+			continue
+		}
+		if before := templateUpdateFor(a, previous); before != nil && before.Version == update.Version {
+			continue
+		}
+		s.sendNotifications("template_update", a.ID, a.Name, map[string]string{
+			"template": update.Template,
+			"current":  update.CurrentVersion,
+			"version":  update.Version,
+		})
+	}
+}
+
+// appTemplate returns the template an app was installed from, if it's
+// known; apps installed before templates were recorded are matched by image
+func appTemplate(a *app.App) (*templates.Template, string) {
+	if a.Deployment.Template != "" {
+		return templates.GetTemplate(a.Deployment.Template), a.Deployment.TemplateVersion
+	}
+	image, version, ok := splitImageTag(a.Image)
+	if !ok {
+		return nil, ""
+	}
+	return templates.GetTemplateByImage(image), version
+}
+
+// splitImageTag splits "ghost:5.80" into "ghost" and "5.80"
+func splitImageTag(image string) (string, string, bool) {
+	colon := strings.LastIndex(image, ":")
+	if colon < 0 || colon < strings.LastIndex(image, "/") {
+		return image, "", false
+	}
+	return image[:colon], image[colon+1:], true
+}
+
+// templateUpdateFor returns the app's template update under catalog c (or
+// the built-in versions when c is nil), or nil if it's up to date
+func templateUpdateFor(a *app.App, c *templates.Catalog) *app.TemplateUpdate {
+	tmpl, current := appTemplate(a)
+	if tmpl == nil || current == "" {
+		return nil
+	}
+	recommended := c.RecommendedVersion(tmpl)
+	if !templates.VersionNewer(recommended, current) {
+		return nil
+	}
+	update := &app.TemplateUpdate{
+		Template:       tmpl.ID,
+		CurrentVersion: current,
+		Version:        recommended,
+		Image:          tmpl.BuildImage(recommended, false),
+	}
+	for _, n := range c.Entry(tmpl.ID).NotesBetween(current, recommended) {
+		update.Notes = append(update.Notes, app.TemplateNote{Version: n.Version, Text: n.Text})
+	}
+	return update
+}
+
+// handleSyncTemplateCatalog syncs the remote template catalog now
+func (s *Server) handleSyncTemplateCatalog(w http.ResponseWriter, r *http.Request) {
+	if err := s.syncTemplateCatalog(r.Context()); err != nil {
+		errorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	c := s.templateCatalog()
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"templates": len(c.Templates),
+		"synced_at": c.SyncedAt,
+	})
+}
+
+// handleTemplateUpgrade moves an app to its template's recommended (or a
+// chosen) version: a backup is taken, the image is bumped and the app is
+// redeployed. The migration notes for the versions skipped over are
+// returned.
+func (s *Server) handleTemplateUpgrade(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	var req struct {
+		Version        string `json:"version"`
+		SkipBackup     bool   `json:"skip_backup"`
+		FreezeOverride string `json:"freeze_override"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	tmpl, current := appTemplate(a)
+	if tmpl == nil {
+		errorResponse(w, http.StatusBadRequest, "App wasn't installed from a template")
+		return
+	}
+	if fw := s.checkFreeze(a.ID, a.Name, req.FreezeOverride); fw != nil {
+		freezeResponse(w, fw)
+		return
+	}
+	c := s.templateCatalog()
+	target := req.Version
+	if target == "" {
+		target = c.RecommendedVersion(tmpl)
+	}
+	if target == "" || target == current {
+		errorResponse(w, http.StatusConflict, fmt.Sprintf("%s already runs %s %s", a.Name, tmpl.ID, current))
+		return
+	}
+	var notes []app.TemplateNote
+	for _, n := range c.Entry(tmpl.ID).NotesBetween(current, target) {
+		notes = append(notes, app.TemplateNote{Version: n.Version, Text: n.Text})
+	}
+
+	backupID := ""
+	if !req.SkipBackup {
+		b, err := s.backup.Create(r.Context(), backup.DefaultOptions())
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Backup before upgrade failed: "+err.Error())
+			return
+		}
+		backupID = b.ID
+	}
+
+	previousImage := a.Image
+	a.Image = tmpl.BuildImage(target, false)
+	a.Deployment.Template = tmpl.ID
+	a.Deployment.TemplateVersion = target
+	if _, err := s.redeployImage(r.Context(), a, true); err != nil {
+		s.logActivity("user", "template_upgrade", "app", a.ID, a.Name, "failed", err.Error())
+		// Put the previous version back; the backup covers anything the new
+		// version's first start already migrated
+		a.Image = previousImage
+		a.Deployment.TemplateVersion = current
+		if _, rerr := s.redeployImage(context.Background(), a, true); rerr != nil {
+			log.Printf("Template upgrade %s: restoring %s failed: %v", a.Name, previousImage, rerr)
+		}
+		msg := fmt.Sprintf("Upgrade to %s failed: %v", target, err)
+		if backupID != "" {
+			msg += " (backup " + backupID + " was taken first)"
+		}
+		errorResponse(w, http.StatusBadGateway, msg)
+		return
+	}
+
+	s.logActivity("user", "template_upgrade", "app", a.ID, a.Name, "success", fmt.Sprintf("%s %s -> %s", tmpl.ID, current, target))
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"template":     tmpl.ID,
+		"from_version": current,
+		"version":      target,
+		"image":        a.Image,
+		"backup_id":    backupID,
+		"notes":        notes,
+	})
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/templates"
+)
+
+func TestTemplateUpdateFor(t *testing.T) {
+	t.Parallel()
+
+	catalog, err := templates.ParseCatalog([]byte(`{"templates": [{"id": "ghost", "recommended_version": "6",
+		"migration_notes": [{"version": "6", "text": "Back up content/ first"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := &app.App{Image: "ghost:5", Deployment: app.DeploymentConfig{Template: "ghost", TemplateVersion: "5"}}
+	update := templateUpdateFor(recorded, catalog)
+	if update == nil {
+		t.Fatal("no update for ghost 5 with 6 recommended")
+	}
+	if update.Version != "6" || update.CurrentVersion != "5" || update.Image != "ghost:6" {
+		t.Fatalf("update = %+v", update)
+	}
+	if len(update.Notes) != 1 || update.Notes[0].Text != "Back up content/ first" {
+		t.Fatalf("notes = %+v", update.Notes)
+	}
+
+	// Apps installed before templates were recorded are matched by image
+	if update := templateUpdateFor(&app.App{Image: "ghost:5"}, catalog); update == nil || update.Template != "ghost" {
+		t.Fatalf("image match = %+v", update)
+	}
+
+	for name, a := range map[string]*app.App{
+		"up to date":   {Deployment: app.DeploymentConfig{Template: "ghost", TemplateVersion: "6"}},
+		"latest tag":   {Image: "ghost:latest"},
+		"not template": {Image: "registry.example.com:5000/myapp"},
+		"built-in":     {Image: "postgres:17"},
+	} {
+		if update := templateUpdateFor(a, catalog); update != nil {
+			t.Fatalf("%s: unexpected update %+v", name, update)
+		}
+	}
+
+	// Without a catalog the built-in default is the recommended version
+	if update := templateUpdateFor(&app.App{Image: "postgres:16"}, nil); update == nil || update.Version != "17" {
+		t.Fatalf("built-in update = %+v", update)
+	}
+}
+
+func TestSplitImageTag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		image, name, tag string
+		ok               bool
+	}{
+		{"ghost:5", "ghost", "5", true},
+		{"ghost", "ghost", "", false},
+		{"registry.example.com:5000/ghost", "registry.example.com:5000/ghost", "", false},
+		{"registry.example.com:5000/ghost:5.1", "registry.example.com:5000/ghost", "5.1", true},
+	}
+	for _, tt := range tests {
+		name, tag, ok := splitImageTag(tt.image)
+		if name != tt.name || tag != tt.tag || ok != tt.ok {
+			t.Fatalf("splitImageTag(%q) = %q, %q, %v", tt.image, name, tag, ok)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTerminalCommand(t *testing.T) {
+	t.Parallel()
+
+	if cmd := terminalCommand(url.Values{}); cmd[0] != "/bin/sh" || !strings.Contains(cmd[2], "bash") {
+		t.Fatalf("default command = %v, want a shell", cmd)
+	}
+	q := url.Values{"cmd": {"ls", "-la", "/app data"}}
+	if cmd := terminalCommand(q); strings.Join(cmd, "|") != "ls|-la|/app data" {
+		t.Fatalf("command = %v, want ls -la '/app data'", cmd)
+	}
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// tlsScanInterval is how often each app's domain is scanned
+const tlsScanInterval = 24 * time.Hour
+
+// tlsHandshakeTimeout bounds each connection a scan makes
+const tlsHandshakeTimeout = 5 * time.Second
+
+// hstsPreferredMaxAge is the HSTS max-age, in seconds, an A+ needs (180 days)
+const hstsPreferredMaxAge = 180 * 24 * 60 * 60
+
+// certExpiryWarning is how close to expiring a certificate gets reported
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// tlsGrades lists the grades from best to worst
+var tlsGrades = []string{"A+", "A", "A-", "B", "C", "F"}
+
+// tlsVersions are the protocol versions a scan tries, oldest first
+var tlsVersions = []struct {
+	version uint16
+	name    string
+}{
+	{tls.VersionTLS10, "TLS 1.0"},
+	{tls.VersionTLS11, "TLS 1.1"},
+	{tls.VersionTLS12, "TLS 1.2"},
+	{tls.VersionTLS13, "TLS 1.3"},
+}
+
+// weakCipherSuites are the suites a scan flags: the ones Go considers
+// insecure, plus RSA key exchange, which has no forward secrecy
+func weakCipherSuites() []*tls.CipherSuite {
+	suites := tls.InsecureCipherSuites()
+	for _, cs := range tls.CipherSuites() {
+		if strings.HasPrefix(cs.Name, "TLS_RSA_") {
+			suites = append(suites, cs)
+		}
+	}
+	return suites
+}
+
+// scanTLS checks the TLS setup of domain, served at addr: the certificate
+// chain, which protocol versions and weak cipher suites are accepted, and
+// whether HSTS is sent
+func scanTLS(ctx context.Context, domain, addr string) *app.TLSScan {
+	scan := &app.TLSScan{Domain: domain, ScannedAt: time.Now().UTC()}
+	handshake := func(cfg *tls.Config) (*tls.ConnectionState, error) {
+		cfg.ServerName = domain
+		hctx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+		defer cancel()
+		conn, err := (&tls.Dialer{Config: cfg}).DialContext(hctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		state := conn.(*tls.Conn).ConnectionState()
+		return &state, nil
+	}
+
+	state, err := handshake(&tls.Config{})
+	if err == nil {
+		scan.ChainValid = true
+	} else {
+		scan.ChainError = err.Error()
+		// The rest of the scan still says something about a certificate
+		// that isn't trusted
+		state, err = handshake(&tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			scan.Error = err.Error()
+			scan.Grade, scan.Issues = gradeTLSScan(scan, time.Now())
+			return scan
+		}
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		scan.CertIssuer = cert.Issuer.CommonName
+		scan.CertExpires = cert.NotAfter
+	}
+
+	for _, v := range tlsVersions {
+		if _, err := handshake(&tls.Config{MinVersion: v.version, MaxVersion: v.version, InsecureSkipVerify: true}); err == nil {
+			scan.Protocols = append(scan.Protocols, v.name)
+		}
+	}
+
+	// Cipher suites are only negotiated up to TLS 1.2
+	if len(scan.Protocols) > 0 && scan.Protocols[0] != "TLS 1.3" {
+		for _, cs := range weakCipherSuites() {
+			cfg := &tls.Config{
+				MinVersion:         tls.VersionTLS10,
+				MaxVersion:         tls.VersionTLS12,
+				CipherSuites:       []uint16{cs.ID},
+				InsecureSkipVerify: true,
+			}
+			if _, err := handshake(cfg); err == nil {
+				scan.WeakCiphers = append(scan.WeakCiphers, cs.Name)
+			}
+		}
+	}
+
+	scan.HSTSMaxAge, scan.HSTS = checkHSTS(ctx, domain, addr)
+	scan.Grade, scan.Issues = gradeTLSScan(scan, time.Now())
+	return scan
+}
+
+// checkHSTS requests the domain's home page and returns the max-age of its
+// Strict-Transport-Security header
+func checkHSTS(ctx context.Context, domain, addr string) (int64, bool) {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{ServerName: domain, InsecureSkipVerify: true}, // Trust is graded from the chain check
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{Timeout: tlsHandshakeTimeout}).DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   15 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://"+domain+"/", nil)
+	if err != nil {
+		return 0, false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	resp.Body.Close()
+	return parseHSTS(resp.Header.Get("Strict-Transport-Security"))
+}
+
+// parseHSTS returns the max-age of a Strict-Transport-Security header and
+// whether it turns HSTS on; max-age=0 turns it off
+func parseHSTS(header string) (int64, bool) {
+	for _, directive := range strings.Split(header, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			maxAge, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return maxAge, maxAge > 0
+		}
+	}
+	return 0, false
+}
+
+// gradeTLSScan grades a scan from A+ to F and lists what lowered the grade.
+// A scan that couldn't connect is graded "-".
+func gradeTLSScan(scan *app.TLSScan, now time.Time) (string, []string) {
+	if scan.Error != "" {
+		return "-", nil
+	}
+	grade := tlsGrades[0]
+	var issues []string
+	lower := func(to, issue string) {
+		issues = append(issues, issue)
+		if slices.Index(tlsGrades, to) > slices.Index(tlsGrades, grade) {
+			grade = to
+		}
+	}
+
+	if !scan.ChainValid {
+		lower("F", "certificate is not trusted: "+scan.ChainError)
+	} else if left := scan.CertExpires.Sub(now); left < certExpiryWarning {
+		issues = append(issues, fmt.Sprintf("certificate expires in %d days", int(left.Hours()/24)))
+	}
+	for _, old := range []string{"TLS 1.0", "TLS 1.1"} {
+		if slices.Contains(scan.Protocols, old) {
+			lower("B", "accepts "+old+", which is deprecated")
+		}
+	}
+	if !slices.Contains(scan.Protocols, "TLS 1.3") {
+		lower("A", "does not support TLS 1.3")
+	}
+
+	var broken, weak []string
+	for _, name := range scan.WeakCiphers {
+		if strings.Contains(name, "RC4") || strings.Contains(name, "3DES") {
+			broken = append(broken, name)
+		} else {
+			weak = append(weak, name)
+		}
+	}
+	if len(broken) > 0 {
+		lower("C", "accepts broken cipher suites: "+strings.Join(broken, ", "))
+	}
+	if len(weak) > 0 {
+		lower("B", "accepts weak cipher suites: "+strings.Join(weak, ", "))
+	}
+
+	if !scan.HSTS {
+		lower("A-", "no Strict-Transport-Security header")
+	} else if scan.HSTSMaxAge < hstsPreferredMaxAge {
+		lower("A", fmt.Sprintf("HSTS max-age is under 180 days (%d seconds)", scan.HSTSMaxAge))
+	}
+	return grade, issues
+}
+
+// tlsGradeWorse reports whether grade is worse than previous. Scans that
+// couldn't connect aren't compared, so a network blip doesn't alert.
+func tlsGradeWorse(grade, previous string) bool {
+	i, j := slices.Index(tlsGrades, grade), slices.Index(tlsGrades, previous)
+	return i >= 0 && j >= 0 && i > j
+}
+
+// scanAppTLS scans an app's domain, stores the result and notifies when the
+// grade dropped since the last scan
+func (s *Server) scanAppTLS(ctx context.Context, a *app.App) *app.TLSScan {
+	previous, _ := s.storage.GetTLSScan(a.ID)
+	scan := scanTLS(ctx, a.Domain, net.JoinHostPort(a.Domain, "443"))
+	if err := s.storage.SaveTLSScan(a.ID, scan); err != nil {
+		log.Printf("TLS scan of %s: %v", a.Domain, err)
+	}
+
+	if previous != nil && tlsGradeWorse(scan.Grade, previous.Grade) {
+		details := fmt.Sprintf("grade dropped from %s to %s: %s", previous.Grade, scan.Grade, strings.Join(scan.Issues, "; "))
+		s.logActivity("system", "tls_scan", "app", a.ID, a.Name, "warning", details)
+		s.sendNotifications("tls_grade_dropped", a.ID, a.Name, map[string]string{
+			"domain":         a.Domain,
+			"grade":          scan.Grade,
+			"previous_grade": previous.Grade,
+			"issues":         strings.Join(scan.Issues, "; "),
+		})
+	}
+	return scan
+}
+
+// runTLSScanner scans each app's domain once per tlsScanInterval
+func (s *Server) runTLSScanner() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.scanDueTLS()
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+// scanDueTLS scans the domains whose last scan is older than tlsScanInterval
+func (s *Server) scanDueTLS() {
+	if s.caddy == nil {
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return
+	}
+	for i := range apps {
+		a := &apps[i]
+		if a.Domain == "" {
+			continue
+		}
+		if last, _ := s.storage.GetTLSScan(a.ID); last != nil && last.Domain == a.Domain && time.Since(last.ScannedAt) < tlsScanInterval {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		s.scanAppTLS(ctx, a)
+		cancel()
+	}
+}
+
+// handleGetTLSScan returns the latest TLS scan of an app's domain
+func (s *Server) handleGetTLSScan(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	scan, err := s.storage.GetTLSScan(a.ID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if scan == nil {
+		errorResponse(w, http.StatusNotFound, "Domain has not been scanned yet")
+		return
+	}
+	jsonResponse(w, http.StatusOK, scan)
+}
+
+// handleTLSScan scans an app's domain now and returns the result
+func (s *Server) handleTLSScan(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Domain == "" {
+		errorResponse(w, http.StatusBadRequest, "App has no domain")
+		return
+	}
+	jsonResponse(w, http.StatusOK, s.scanAppTLS(r.Context(), a))
+}
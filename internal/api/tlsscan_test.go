@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestScanTLS(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+	}))
+	defer srv.Close()
+
+	scan := scanTLS(context.Background(), "example.com", srv.Listener.Addr().String())
+	if scan.Error != "" {
+		t.Fatalf("scan error = %s", scan.Error)
+	}
+	if scan.ChainValid || scan.ChainError == "" {
+		t.Fatalf("self-signed test certificate should not verify: %+v", scan)
+	}
+	if !slices.Contains(scan.Protocols, "TLS 1.3") || slices.Contains(scan.Protocols, "TLS 1.0") {
+		t.Fatalf("protocols = %v", scan.Protocols)
+	}
+	if !scan.HSTS || scan.HSTSMaxAge != 31536000 {
+		t.Fatalf("HSTS = %v, max-age %d", scan.HSTS, scan.HSTSMaxAge)
+	}
+	if scan.Grade != "F" {
+		t.Fatalf("grade = %s, want F", scan.Grade)
+	}
+}
+
+func TestScanTLSUnreachable(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.Listener.Addr().String()
+	srv.Close()
+
+	scan := scanTLS(context.Background(), "example.com", addr)
+	if scan.Error == "" || scan.Grade != "-" {
+		t.Fatalf("scan of a closed port = %+v", scan)
+	}
+}
+
+func TestGradeTLSScan(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	good := func() *app.TLSScan {
+		return &app.TLSScan{
+			Protocols:   []string{"TLS 1.2", "TLS 1.3"},
+			HSTS:        true,
+			HSTSMaxAge:  hstsPreferredMaxAge,
+			ChainValid:  true,
+			CertExpires: now.AddDate(0, 2, 0),
+		}
+	}
+
+	cases := []struct {
+		name   string
+		modify func(*app.TLSScan)
+		want   string
+	}{
+		{"good", func(*app.TLSScan) {}, "A+"},
+		{"short hsts", func(s *app.TLSScan) { s.HSTSMaxAge = 3600 }, "A"},
+		{"no hsts", func(s *app.TLSScan) { s.HSTS, s.HSTSMaxAge = false, 0 }, "A-"},
+		{"no tls 1.3", func(s *app.TLSScan) { s.Protocols = []string{"TLS 1.2"} }, "A"},
+		{"tls 1.0", func(s *app.TLSScan) { s.Protocols = append([]string{"TLS 1.0"}, s.Protocols...) }, "B"},
+		{"rsa kex", func(s *app.TLSScan) { s.WeakCiphers = []string{"TLS_RSA_WITH_AES_128_GCM_SHA256"} }, "B"},
+		{"3des", func(s *app.TLSScan) { s.WeakCiphers = []string{"TLS_RSA_WITH_3DES_EDE_CBC_SHA"} }, "C"},
+		{"untrusted", func(s *app.TLSScan) { s.ChainValid, s.ChainError = false, "x509: unknown authority" }, "F"},
+		{"unreachable", func(s *app.TLSScan) { s.Error = "connection refused" }, "-"},
+	}
+	for _, c := range cases {
+		scan := good()
+		c.modify(scan)
+		grade, issues := gradeTLSScan(scan, now)
+		if grade != c.want {
+			t.Fatalf("%s: grade = %s, want %s (issues %v)", c.name, grade, c.want, issues)
+		}
+		if grade != "A+" && grade != "-" && len(issues) == 0 {
+			t.Fatalf("%s: grade %s has no issues", c.name, grade)
+		}
+	}
+
+	expiring := good()
+	expiring.CertExpires = now.Add(3 * 24 * time.Hour)
+	if grade, issues := gradeTLSScan(expiring, now); grade != "A+" || len(issues) != 1 {
+		t.Fatalf("expiring certificate: grade %s, issues %v", grade, issues)
+	}
+}
+
+func TestParseHSTS(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]int64{
+		"max-age=63072000; includeSubDomains; preload": 63072000,
+		`max-age="300"`:     300,
+		"max-age=0":         0,
+		"":                  0,
+		"includeSubDomains": 0,
+	}
+	for header, want := range cases {
+		got, ok := parseHSTS(header)
+		if got != want || ok != (want > 0) {
+			t.Fatalf("parseHSTS(%q) = %d, %v; want %d", header, got, ok, want)
+		}
+	}
+	if !tlsGradeWorse("B", "A+") || tlsGradeWorse("A", "B") || tlsGradeWorse("-", "A") {
+		t.Fatalf("tlsGradeWorse ordering is wrong")
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestDeployTokenLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+
+	if code := ts.do("POST", "/api/deploy-tokens", map[string]interface{}{"name": "ci", "expires_in": "soon"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("bad expires_in: status %d, want 400", code)
+	}
+
+	var created struct {
+		ID        string     `json:"id"`
+		Token     string     `json:"token"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	body := map[string]interface{}{"name": "ci", "scopes": []string{"metrics:read"}, "expires_in": "720h"}
+	if code := ts.do("POST", "/api/deploy-tokens", body, &created); code != http.StatusCreated {
+		t.Fatalf("create token: status %d", code)
+	}
+	if created.ExpiresAt == nil || created.ExpiresAt.Before(time.Now().Add(719*time.Hour)) {
+		t.Fatalf("expires_at = %v, want about 30 days out", created.ExpiresAt)
+	}
+
+	// The token only reaches what its scopes allow
+	if code := ts.doAs(created.Token, "GET", "/api/apps", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("token listing apps: status %d, want 403", code)
+	}
+	if code := ts.doAs(created.Token, "GET", "/api/deploy-tokens", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("token listing tokens: status %d, want 403", code)
+	}
+
+	if code := ts.doAs(created.Token, "GET", "/metrics", nil, nil); code != http.StatusOK {
+		t.Fatalf("token scraping metrics: status %d, want 200", code)
+	}
+
+	var list struct {
+		Tokens []app.DeployToken `json:"tokens"`
+	}
+	ts.do("GET", "/api/deploy-tokens", nil, &list)
+	var listed *app.DeployToken
+	for i := range list.Tokens {
+		if list.Tokens[i].ID == created.ID {
+			listed = &list.Tokens[i]
+		}
+	}
+	if listed == nil || listed.LastUsedAt == nil || listed.ExpiresAt == nil {
+		t.Fatalf("tokens = %+v, want %s with last_used_at and expires_at", list.Tokens, created.ID)
+	}
+
+	if code := ts.do("DELETE", "/api/deploy-tokens/"+created.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("revoke token: status %d", code)
+	}
+	if code := ts.doAs(created.Token, "GET", "/api/apps", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("revoked token: status %d, want 401", code)
+	}
+}
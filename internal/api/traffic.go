@@ -0,0 +1,279 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTrafficPeriod is the window summarized unless ?period= says otherwise
+const defaultTrafficPeriod = 24 * time.Hour
+
+// defaultTrafficTop is how many paths and referrers are listed unless ?top=
+// says otherwise
+const defaultTrafficTop = 10
+
+// maxTrafficTop bounds ?top=
+const maxTrafficTop = 100
+
+// trafficPoints is how many buckets a traffic summary's series has
+const trafficPoints = 24
+
+// TrafficCount is a path or referrer and the requests for it
+type TrafficCount struct {
+	Value    string `json:"value"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// TrafficPoint is a bucket of an app's requests
+type TrafficPoint struct {
+	Time     time.Time `json:"time"` // Start of the bucket
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"` // 5xx responses
+	Bytes    int64     `json:"bytes"`
+}
+
+// TrafficSummary sums up an app's access logs over a period
+type TrafficSummary struct {
+	Period         string           `json:"period"`
+	Since          time.Time        `json:"since"`
+	Until          time.Time        `json:"until"`
+	Requests       int64            `json:"requests"`
+	Bytes          int64            `json:"bytes"` // Response bytes sent
+	UniqueVisitors int              `json:"unique_visitors"`
+	StatusClasses  map[string]int64 `json:"status_classes"` // "2xx" -> requests
+	StatusCodes    map[string]int64 `json:"status_codes"`   // "404" -> requests
+	AvgDurationMs  float64          `json:"avg_duration_ms"`
+	TopPaths       []TrafficCount   `json:"top_paths"`
+	TopReferrers   []TrafficCount   `json:"top_referrers"`
+	Series         []TrafficPoint   `json:"series"`
+	Anonymized     bool             `json:"anonymized"` // Visitors were counted by masked IPs
+}
+
+// trafficStats adds up access log entries into a TrafficSummary
+type trafficStats struct {
+	summary   TrafficSummary
+	domains   map[string]bool
+	width     time.Duration
+	visitors  map[string]bool
+	paths     map[string]*TrafficCount
+	referrers map[string]*TrafficCount
+	duration  float64
+}
+
+func newTrafficStats(period string, since, until time.Time, domains map[string]bool) *trafficStats {
+	t := &trafficStats{
+		summary: TrafficSummary{
+			Period:        period,
+			Since:         since,
+			Until:         until,
+			StatusClasses: map[string]int64{},
+			StatusCodes:   map[string]int64{},
+			TopPaths:      []TrafficCount{},
+			TopReferrers:  []TrafficCount{},
+			Series:        make([]TrafficPoint, trafficPoints),
+		},
+		domains:   domains,
+		width:     until.Sub(since) / trafficPoints,
+		visitors:  map[string]bool{},
+		paths:     map[string]*TrafficCount{},
+		referrers: map[string]*TrafficCount{},
+	}
+	if t.width <= 0 {
+		t.width = 1
+	}
+	for i := range t.summary.Series {
+		t.summary.Series[i].Time = since.Add(time.Duration(i) * t.width)
+	}
+	return t
+}
+
+// add counts one Caddy access log entry
+func (t *trafficStats) add(entry map[string]interface{}) {
+	ts, _ := entry["ts"].(float64)
+	at := time.Unix(0, int64(ts*float64(time.Second)))
+	if at.Before(t.summary.Since) || at.After(t.summary.Until) {
+		return
+	}
+	req, _ := entry["request"].(map[string]interface{})
+	status, _ := entry["status"].(float64)
+	size, _ := entry["size"].(float64)
+	bytes := int64(size)
+	s := &t.summary
+
+	s.Requests++
+	s.Bytes += bytes
+	code := int(status)
+	s.StatusCodes[strconv.Itoa(code)]++
+	s.StatusClasses[fmt.Sprintf("%dxx", code/100)]++
+	if d, ok := entry["duration"].(float64); ok {
+		t.duration += d
+	}
+
+	bucket := min(int(at.Sub(s.Since)/t.width), len(s.Series)-1)
+	s.Series[bucket].Requests++
+	s.Series[bucket].Bytes += bytes
+	if code >= 500 {
+		s.Series[bucket].Errors++
+	}
+
+	ip, _ := req["client_ip"].(string)
+	if ip == "" {
+		ip, _ = req["remote_ip"].(string)
+	}
+	if ip != "" {
+		t.visitors[ip] = true
+	}
+
+	uri, _ := req["uri"].(string)
+	path, _, _ := strings.Cut(uri, "?")
+	if path != "" {
+		countTraffic(t.paths, path, bytes)
+	}
+	if ref := t.referrer(req); ref != "" {
+		countTraffic(t.referrers, ref, bytes)
+	}
+}
+
+// referrer returns the host of a request's Referer, or "" if there is none
+// or it is one of the app's own domains
+func (t *trafficStats) referrer(req map[string]interface{}) string {
+	headers, _ := req["headers"].(map[string]interface{})
+	values, _ := headers["Referer"].([]interface{})
+	if len(values) == 0 {
+		return ""
+	}
+	raw, _ := values[0].(string)
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || t.domains[u.Hostname()] {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// countTraffic adds a request to the count for key
+func countTraffic(counts map[string]*TrafficCount, key string, bytes int64) {
+	c := counts[key]
+	if c == nil {
+		c = &TrafficCount{Value: key}
+		counts[key] = c
+	}
+	c.Requests++
+	c.Bytes += bytes
+}
+
+// topTraffic returns the n counts with the most requests
+func topTraffic(counts map[string]*TrafficCount, n int) []TrafficCount {
+	top := make([]TrafficCount, 0, len(counts))
+	for _, c := range counts {
+		top = append(top, *c)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+		return top[i].Value < top[j].Value
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// result finishes the summary with the top n paths and referrers
+func (t *trafficStats) result(n int) TrafficSummary {
+	s := t.summary
+	s.UniqueVisitors = len(t.visitors)
+	if s.Requests > 0 {
+		s.AvgDurationMs = t.duration * 1000 / float64(s.Requests)
+	}
+	s.TopPaths = topTraffic(t.paths, n)
+	s.TopReferrers = topTraffic(t.referrers, n)
+	return s
+}
+
+// parseTrafficPeriod reads a period like 1h, 24h or 7d
+func parseTrafficPeriod(v string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid period %q", v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period %q", v)
+	}
+	return d, nil
+}
+
+// handleAppTraffic summarizes an app's access logs: requests, status codes,
+// bandwidth, top paths and referrers and unique visitors. ?period= (default
+// 24h, at most the access log retention) and ?top= (default 10) shape it.
+func (s *Server) handleAppTraffic(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	q := r.URL.Query()
+	period := q.Get("period")
+	window := defaultTrafficPeriod
+	if period != "" {
+		if window, err = parseTrafficPeriod(period); err != nil {
+			errorResponse(w, http.StatusBadRequest, "period must be a duration like 1h, 24h or 7d")
+			return
+		}
+	} else {
+		period = "24h"
+	}
+	if retention := s.accessLogRetention(); window > retention {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("period is longer than the %d day access log retention", int(retention/(24*time.Hour))))
+		return
+	}
+	top := defaultTrafficTop
+	if n, err := strconv.Atoi(q.Get("top")); err == nil && n > 0 {
+		top = min(n, maxTrafficTop)
+	}
+
+	domains := map[string]bool{}
+	for _, d := range appDomains(a) {
+		domains[d] = true
+	}
+	anonymize := a.AnonymizesIPs(s.config.Privacy.AnonymizeIPs)
+	until := time.Now()
+	since := until.Add(-window)
+	stats := newTrafficStats(period, since, until, domains)
+	stats.summary.Anonymized = anonymize
+
+	for _, path := range accessLogFiles() {
+		// Rolled files older than the period can't hold entries in it
+		if info, err := os.Stat(path); err == nil && info.ModTime().Before(since) {
+			continue
+		}
+		err := scanAccessLog(path, domains, since, 0, func(entry map[string]interface{}) {
+			if anonymize {
+				anonymizeAccessEntry(entry)
+			}
+			stats.add(entry)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Traffic summary for %s: %s: %v", a.Name, path, err)
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, stats.result(top))
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestTrafficStats(t *testing.T) {
+	t.Parallel()
+
+	until := time.Now()
+	since := until.Add(-24 * time.Hour)
+	domains := map[string]bool{"shop.example.com": true}
+	entry := func(ago time.Duration, ip, uri string, status, size int, referer string) map[string]interface{} {
+		headers := map[string]interface{}{}
+		if referer != "" {
+			headers["Referer"] = []interface{}{referer}
+		}
+		return map[string]interface{}{
+			"ts":       float64(until.Add(-ago).UnixNano()) / float64(time.Second),
+			"status":   float64(status),
+			"size":     float64(size),
+			"duration": 0.02,
+			"request": map[string]interface{}{
+				"host": "shop.example.com", "client_ip": ip, "uri": uri, "headers": headers,
+			},
+		}
+	}
+
+	stats := newTrafficStats("24h", since, until, domains)
+	stats.add(entry(time.Minute, "198.51.100.1", "/", 200, 1000, "https://news.example.org/item?id=1"))
+	stats.add(entry(time.Minute, "198.51.100.1", "/pricing?plan=pro", 200, 500, "https://shop.example.com/"))
+	stats.add(entry(2*time.Hour, "198.51.100.2", "/", 304, 0, ""))
+	stats.add(entry(3*time.Hour, "198.51.100.3", "/missing", 404, 100, ""))
+	stats.add(entry(22*time.Hour+30*time.Minute, "198.51.100.3", "/api/cart", 502, 50, ""))
+	stats.add(entry(48*time.Hour, "198.51.100.4", "/", 200, 1000, "")) // Outside the period
+
+	got := stats.result(2)
+	if got.Requests != 5 || got.Bytes != 1650 || got.UniqueVisitors != 3 {
+		t.Fatalf("requests %d, bytes %d, visitors %d; want 5, 1650, 3", got.Requests, got.Bytes, got.UniqueVisitors)
+	}
+	if got.StatusClasses["2xx"] != 2 || got.StatusClasses["3xx"] != 1 || got.StatusClasses["4xx"] != 1 || got.StatusClasses["5xx"] != 1 {
+		t.Fatalf("status classes = %v", got.StatusClasses)
+	}
+	if got.StatusCodes["404"] != 1 || got.StatusCodes["200"] != 2 {
+		t.Fatalf("status codes = %v", got.StatusCodes)
+	}
+	if len(got.TopPaths) != 2 || got.TopPaths[0].Value != "/" || got.TopPaths[0].Requests != 2 {
+		t.Fatalf("top paths = %+v, want / first with 2 requests", got.TopPaths)
+	}
+	if len(got.TopReferrers) != 1 || got.TopReferrers[0].Value != "news.example.org" {
+		t.Fatalf("top referrers = %+v, want only news.example.org", got.TopReferrers)
+	}
+	if len(got.Series) != trafficPoints || got.Series[trafficPoints-1].Requests != 2 || got.Series[1].Errors != 1 {
+		t.Fatalf("series = %+v", got.Series)
+	}
+	if got.AvgDurationMs < 19 || got.AvgDurationMs > 21 {
+		t.Fatalf("avg duration = %v ms, want 20", got.AvgDurationMs)
+	}
+}
+
+func TestParseTrafficPeriod(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]time.Duration{"1h": time.Hour, "24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour, "30m": 30 * time.Minute}
+	for in, want := range cases {
+		if got, err := parseTrafficPeriod(in); err != nil || got != want {
+			t.Fatalf("parseTrafficPeriod(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0d", "-1h", "week"} {
+		if _, err := parseTrafficPeriod(in); err == nil {
+			t.Fatalf("parseTrafficPeriod(%q) should fail", in)
+		}
+	}
+}
+
+func TestAppTraffic(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "traffic-web", Domain: "traffic-web.test"}, &a)
+
+	now := strconv.FormatFloat(float64(time.Now().Unix()), 'f', 3, 64)
+	lines := `{"logger":"http.log.access.default","ts":` + now + `,"request":{"host":"traffic-web.test","client_ip":"198.51.100.9","uri":"/docs"},"status":200,"size":2048}` + "\n" +
+		`{"logger":"http.log.access.default","ts":` + now + `,"request":{"host":"traffic-web.test","client_ip":"198.51.100.9","uri":"/docs"},"status":500,"size":10}` + "\n" +
+		`{"logger":"http.log.access.default","ts":` + now + `,"request":{"host":"elsewhere.test","client_ip":"198.51.100.8","uri":"/"},"status":200,"size":10}` + "\n"
+	f, err := os.OpenFile(filepath.Join(accessLogDir(), "access.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(lines)
+	f.Close()
+
+	var summary TrafficSummary
+	if code := ts.do("GET", "/api/apps/traffic-web/traffic?period=1h", nil, &summary); code != http.StatusOK {
+		t.Fatalf("traffic: status %d", code)
+	}
+	if summary.Requests != 2 || summary.Bytes != 2058 || summary.UniqueVisitors != 1 || summary.StatusClasses["5xx"] != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if len(summary.TopPaths) != 1 || summary.TopPaths[0].Value != "/docs" {
+		t.Fatalf("top paths = %+v", summary.TopPaths)
+	}
+	if code := ts.do("GET", "/api/apps/traffic-web/traffic?period=365d", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("period beyond retention: status %d, want 400", code)
+	}
+
+	ts.do("DELETE", "/api/apps/"+a.ID, nil, nil)
+}
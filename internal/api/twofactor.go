@@ -0,0 +1,329 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/base-go/basepod/internal/auth"
+)
+
+// recoveryCodeCount is how many recovery codes enabling 2FA hands out
+const recoveryCodeCount = 10
+
+// adminAccountID keys the two-factor auth of the server password, which has
+// no user row
+const adminAccountID = "admin"
+
+// twoFactorAccount returns the two-factor account ID and the name shown in
+// authenticator apps for a session
+func twoFactorAccount(session *auth.Session) (string, string) {
+	if session.UserID == "" {
+		return adminAccountID, "admin"
+	}
+	return session.UserID, session.UserEmail
+}
+
+// checkTwoFactor verifies the second factor for an account that passed its
+// password check. code may be a TOTP code or an unused recovery code. It
+// reports whether the account has 2FA and, if so, whether code satisfied it.
+func (s *Server) checkTwoFactor(accountID, code string) (required, ok bool, err error) {
+	s.twoFactorMu.Lock()
+	defer s.twoFactorMu.Unlock()
+
+	tf, err := s.storage.GetTwoFactor(accountID)
+	if err != nil {
+		return true, false, err
+	}
+	if tf == nil || !tf.Enabled {
+		return false, true, nil
+	}
+	if code == "" {
+		return true, false, nil
+	}
+	if step, valid := auth.ValidateTOTP(tf.Secret, code, time.Now(), tf.LastStep); valid {
+		return true, true, s.storage.SetTwoFactorLastStep(accountID, step)
+	}
+	hash := auth.HashRecoveryCode(code)
+	for i, h := range tf.RecoveryCodes {
+		if h == hash {
+			remaining := append(tf.RecoveryCodes[:i:i], tf.RecoveryCodes[i+1:]...)
+			if err := s.storage.SetRecoveryCodes(accountID, remaining); err != nil {
+				return true, false, err
+			}
+			s.logActivity("user", "2fa_recovery_code_used", "user", accountID, "", "success", "")
+			return true, true, nil
+		}
+	}
+	return true, false, nil
+}
+
+// newRecoveryCodes generates recovery codes and their hashes for storage
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashRecoveryCode(c)
+	}
+	return codes, hashes, nil
+}
+
+// handleTwoFactorStatus reports whether the caller has 2FA turned on
+func (s *Server) handleTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	accountID, _ := twoFactorAccount(session)
+	tf, err := s.storage.GetTwoFactor(accountID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to read two-factor auth: "+err.Error())
+		return
+	}
+	resp := map[string]interface{}{"enabled": false, "pending": false}
+	if tf != nil {
+		resp["enabled"] = tf.Enabled
+		resp["pending"] = !tf.Enabled
+		resp["enabled_at"] = tf.EnabledAt
+		resp["recovery_codes_left"] = len(tf.RecoveryCodes)
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// handleTwoFactorEnroll starts 2FA enrollment with a new secret. It takes
+// effect once a code from the authenticator app is verified.
+func (s *Server) handleTwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	accountID, label := twoFactorAccount(session)
+	existing, err := s.storage.GetTwoFactor(accountID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to read two-factor auth: "+err.Error())
+		return
+	}
+	if existing != nil && existing.Enabled {
+		errorResponse(w, http.StatusConflict, "Two-factor auth is already on; turn it off first to enroll a new device")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+	if err := s.storage.StartTwoFactor(accountID, secret); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	issuer := "Basepod"
+	if s.config.Domain.Root != "" {
+		issuer = "Basepod (" + s.config.Domain.Root + ")"
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"secret":           secret,
+		"provisioning_uri": auth.TOTPProvisioningURI(secret, label, issuer),
+		"message":          "Scan the URI as a QR code, then verify a code to turn on two-factor auth",
+	})
+}
+
+// handleTwoFactorVerify confirms enrollment with a code from the
+// authenticator app and returns the recovery codes, shown only this once
+func (s *Server) handleTwoFactorVerify(w http.ResponseWriter, r *http.Request) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	accountID, label := twoFactorAccount(session)
+	tf, err := s.storage.GetTwoFactor(accountID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to read two-factor auth: "+err.Error())
+		return
+	}
+	if tf == nil {
+		errorResponse(w, http.StatusBadRequest, "Start enrollment first: POST /api/auth/2fa/enroll")
+		return
+	}
+	if tf.Enabled {
+		errorResponse(w, http.StatusConflict, "Two-factor auth is already on")
+		return
+	}
+	step, ok := auth.ValidateTOTP(tf.Secret, req.Code, time.Now(), 0)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Invalid code; check the authenticator app and the server clock")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	if err := s.storage.EnableTwoFactor(accountID, hashes, step); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.logActivity("user", "2fa_enable", "user", accountID, label, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"enabled":        true,
+		"recovery_codes": codes,
+		"message":        "Save these recovery codes - they won't be shown again",
+	})
+}
+
+// handleTwoFactorDisable turns 2FA off after checking the password and a
+// current code, so a stolen session alone can't remove it
+func (s *Server) handleTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if !s.checkAccountPassword(session, req.Password) {
+		errorResponse(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+	accountID, label := twoFactorAccount(session)
+	required, ok, err := s.checkTwoFactor(accountID, req.Code)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check two-factor code: "+err.Error())
+		return
+	}
+	if required && !ok {
+		errorResponse(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+	if err := s.storage.DeleteTwoFactor(accountID); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.logActivity("user", "2fa_disable", "user", accountID, label, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]bool{"enabled": false})
+}
+
+// handleRegenerateRecoveryCodes replaces the recovery codes after checking
+// a current code
+func (s *Server) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	session := s.auth.GetSession(s.getSessionToken(r))
+	if session == nil {
+		errorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	accountID, label := twoFactorAccount(session)
+	required, ok, err := s.checkTwoFactor(accountID, req.Code)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check two-factor code: "+err.Error())
+		return
+	}
+	if !required {
+		errorResponse(w, http.StatusBadRequest, "Two-factor auth is off")
+		return
+	}
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	if err := s.storage.SetRecoveryCodes(accountID, hashes); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.logActivity("user", "2fa_recovery_codes", "user", accountID, label, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"recovery_codes": codes,
+		"message":        "Save these recovery codes - they won't be shown again",
+	})
+}
+
+// checkAccountPassword checks the password of the account behind a session
+func (s *Server) checkAccountPassword(session *auth.Session, password string) bool {
+	if session.UserID == "" {
+		return s.auth.ValidatePassword(password)
+	}
+	user, err := s.storage.GetUserByID(session.UserID)
+	if err != nil || user == nil {
+		return false
+	}
+	return auth.CheckPassword(user.PasswordHash, password)
+}
+
+// requireTwoFactorAtLogin checks the second factor during login and writes
+// the error response if it is missing or wrong. A missing code gets
+// "two_factor_required" so clients know to prompt for one.
+func (s *Server) requireTwoFactorAtLogin(w http.ResponseWriter, accountID, code string) bool {
+	required, ok, err := s.checkTwoFactor(accountID, code)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to check two-factor code: "+err.Error())
+		return false
+	}
+	if !required || ok {
+		return true
+	}
+	if code == "" {
+		jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"error":               "Two-factor code required",
+			"two_factor_required": true,
+		})
+		return false
+	}
+	s.logActivity("user", "login", "user", accountID, "", "failed", "invalid two-factor code")
+	jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{
+		"error":               "Invalid two-factor code",
+		"two_factor_required": true,
+	})
+	return false
+}
+
+// handleResetUserTwoFactor lets an admin turn off a user's 2FA when they
+// have lost both their authenticator and recovery codes
+func (s *Server) handleResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := s.storage.GetUserByID(r.PathValue("id"))
+	if err != nil || user == nil {
+		errorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err := s.storage.DeleteTwoFactor(user.ID); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logActivity("user", "2fa_reset", "user", user.ID, user.Email, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]bool{"enabled": false})
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/auth"
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestTwoFactorEnrollAndLogin(t *testing.T) {
+	ts := newTestServer(t)
+	body := map[string]interface{}{"email": "2fa@example.com", "password": "two-factor-pass", "role": "deployer"}
+	if code := ts.do("POST", "/api/users", body, nil); code != http.StatusCreated {
+		t.Fatalf("create user: status %d", code)
+	}
+	token := ts.login("2fa@example.com", "two-factor-pass")
+
+	var enroll struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}
+	if code := ts.doAs(token, "POST", "/api/auth/2fa/enroll", nil, &enroll); code != http.StatusOK || enroll.Secret == "" {
+		t.Fatalf("enroll: status %d, %+v", code, enroll)
+	}
+	// Enrollment isn't enforced until a code is verified
+	ts.login("2fa@example.com", "two-factor-pass")
+
+	now := time.Now()
+	code, _ := auth.TOTPCode(enroll.Secret, now)
+	wrong := string('0'+(code[0]-'0'+1)%10) + code[1:]
+	if status := ts.doAs(token, "POST", "/api/auth/2fa/verify", map[string]string{"code": wrong}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("verify with a wrong code: status %d, want 401", status)
+	}
+	var verified struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if status := ts.doAs(token, "POST", "/api/auth/2fa/verify", map[string]string{"code": code}, &verified); status != http.StatusOK || len(verified.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("verify: status %d, %d recovery codes", status, len(verified.RecoveryCodes))
+	}
+
+	login := func(totp string) (int, bool) {
+		var resp struct {
+			Token             string `json:"token"`
+			TwoFactorRequired bool   `json:"two_factor_required"`
+		}
+		req := map[string]string{"email": "2fa@example.com", "password": "two-factor-pass", "totp_code": totp}
+		status := ts.doAs("", "POST", "/api/auth/login", req, &resp)
+		return status, resp.TwoFactorRequired
+	}
+	if status, required := login(""); status != http.StatusUnauthorized || !required {
+		t.Fatalf("login without a code: status %d, two_factor_required %v", status, required)
+	}
+	// The code used to verify can't be replayed
+	if status, _ := login(code); status != http.StatusUnauthorized {
+		t.Fatalf("login replaying the verify code: status %d, want 401", status)
+	}
+	next, _ := auth.TOTPCode(enroll.Secret, now.Add(30*time.Second))
+	if status, _ := login(next); status != http.StatusOK {
+		t.Fatalf("login with the next code: status %d, want 200", status)
+	}
+	recovery := verified.RecoveryCodes[0]
+	if status, _ := login(recovery); status != http.StatusOK {
+		t.Fatalf("login with a recovery code: status %d, want 200", status)
+	}
+	if status, _ := login(recovery); status != http.StatusUnauthorized {
+		t.Fatalf("reusing a recovery code: status %d, want 401", status)
+	}
+
+	var status struct {
+		Enabled           bool `json:"enabled"`
+		RecoveryCodesLeft int  `json:"recovery_codes_left"`
+	}
+	ts.doAs(token, "GET", "/api/auth/2fa", nil, &status)
+	if !status.Enabled || status.RecoveryCodesLeft != recoveryCodeCount-1 {
+		t.Fatalf("2fa status = %+v, want on with %d codes left", status, recoveryCodeCount-1)
+	}
+
+	if code := ts.doAs(token, "POST", "/api/auth/2fa/disable", map[string]string{"password": "wrong-password", "code": verified.RecoveryCodes[1]}, nil); code != http.StatusUnauthorized {
+		t.Fatalf("disable with a wrong password: status %d, want 401", code)
+	}
+	if code := ts.doAs(token, "POST", "/api/auth/2fa/disable", map[string]string{"password": "two-factor-pass", "code": verified.RecoveryCodes[1]}, nil); code != http.StatusOK {
+		t.Fatalf("disable: status %d", code)
+	}
+	ts.login("2fa@example.com", "two-factor-pass")
+}
+
+func TestTwoFactorCodesAreRateLimited(t *testing.T) {
+	ts := newTestServer(t)
+	ts.config.Auth.LoginLimit = config.LoginLimitConfig{MaxAttempts: 2, LockoutSeconds: 60}
+	body := map[string]interface{}{"email": "guess@example.com", "password": "two-factor-pass", "role": "deployer"}
+	if code := ts.do("POST", "/api/users", body, nil); code != http.StatusCreated {
+		t.Fatalf("create user: status %d", code)
+	}
+	token := ts.login("guess@example.com", "two-factor-pass")
+	var enroll struct {
+		Secret string `json:"secret"`
+	}
+	ts.doAs(token, "POST", "/api/auth/2fa/enroll", nil, &enroll)
+	code, _ := auth.TOTPCode(enroll.Secret, time.Now())
+	wrong := string('0'+(code[0]-'0'+1)%10) + code[1:]
+
+	// Guessing codes locks the IP out like failed logins, right code or not
+	for i := 0; i < 2; i++ {
+		if status := ts.doAs(token, "POST", "/api/auth/2fa/verify", map[string]string{"code": wrong}, nil); status != http.StatusUnauthorized {
+			t.Fatalf("verify guess %d: status %d, want 401", i+1, status)
+		}
+	}
+	if status := ts.doAs(token, "POST", "/api/auth/2fa/verify", map[string]string{"code": code}, nil); status != http.StatusTooManyRequests {
+		t.Fatalf("verify after guessing: status %d, want 429", status)
+	}
+
+	ts.do("DELETE", "/api/auth/lockouts", nil, nil)
+	if status := ts.doAs(token, "POST", "/api/auth/2fa/verify", map[string]string{"code": code}, nil); status != http.StatusOK {
+		t.Fatalf("verify: status %d", status)
+	}
+	for i := 0; i < 2; i++ {
+		if status := ts.doAs(token, "POST", "/api/auth/2fa/recovery-codes", map[string]string{"code": wrong}, nil); status != http.StatusUnauthorized {
+			t.Fatalf("recovery codes guess %d: status %d, want 401", i+1, status)
+		}
+	}
+	if status := ts.doAs(token, "POST", "/api/auth/2fa/recovery-codes", map[string]string{"code": wrong}, nil); status != http.StatusTooManyRequests {
+		t.Fatalf("recovery codes after guessing: status %d, want 429", status)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/base-go/basepod/internal/mlx"
+)
+
+// UIFeature is a section of the web UI and whether it should be shown
+type UIFeature struct {
+	Available bool   `json:"available"`        // Supported on this server
+	Enabled   bool   `json:"enabled"`          // Available and not hidden by an admin
+	Reason    string `json:"reason,omitempty"` // Why it isn't available
+}
+
+// UIConfig is what the web UI needs at boot, from GET /api/ui-config
+type UIConfig struct {
+	Name       string               `json:"name"`
+	Branding   Branding             `json:"branding"`
+	BaseDomain string               `json:"base_domain"` // Apps are served as <name>.<base_domain>
+	APIBase    string               `json:"api_base"`
+	Platform   string               `json:"platform"`
+	Features   map[string]UIFeature `json:"features"`
+}
+
+// uiFeatureNames lists the web UI sections that can be hidden
+var uiFeatureNames = []string{"models", "chat", "audio", "ai", "backups", "templates", "mcp"}
+
+// uiFeatureAvailability reports whether this server supports a UI feature
+// and, if not, why
+func (s *Server) uiFeatureAvailability(name string) (bool, string) {
+	switch name {
+	case "models", "chat", "audio", "ai":
+		// Local models run on MLX
+		if !mlx.IsSupported() {
+			return false, mlx.GetUnsupportedReason()
+		}
+	case "backups":
+		if s.backup == nil {
+			return false, "backup service is not running"
+		}
+	case "mcp":
+		if s.config.MCP.Disabled {
+			return false, "disabled in config (mcp.disabled)"
+		}
+	}
+	return true, ""
+}
+
+// uiConfig builds the web UI's boot config
+func (s *Server) uiConfig() UIConfig {
+	cfg := UIConfig{
+		Name:       s.config.Branding.ProductName(),
+		Branding:   s.branding(),
+		BaseDomain: strings.TrimPrefix(s.config.GetAppDomain(""), "."),
+		APIBase:    strings.TrimRight(s.config.WebUI.APIURL, "/"),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Features:   map[string]UIFeature{},
+	}
+	if cfg.APIBase == "" {
+		cfg.APIBase = "/api"
+	}
+	hidden := map[string]bool{}
+	for _, name := range s.config.WebUI.HiddenFeatures {
+		hidden[name] = true
+	}
+	for _, name := range uiFeatureNames {
+		available, reason := s.uiFeatureAvailability(name)
+		cfg.Features[name] = UIFeature{Available: available, Enabled: available && !hidden[name], Reason: reason}
+	}
+	return cfg
+}
+
+// handleGetUIConfig returns branding, the base domain and which features the
+// web UI should show. No auth: the UI needs it before the login page.
+func (s *Server) handleGetUIConfig(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, s.uiConfig())
+}
+
+// handleUpdateUIConfig sets the web UI's branding and shows or hides features
+// on this server
+func (s *Server) handleUpdateUIConfig(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     *string         `json:"name"`
+		Features map[string]bool `json:"features"` // Feature name -> shown
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	hidden := map[string]bool{}
+	for _, name := range s.config.WebUI.HiddenFeatures {
+		hidden[name] = true
+	}
+	var changes []string
+	for name, shown := range req.Features {
+		known := false
+		for _, f := range uiFeatureNames {
+			known = known || f == name
+		}
+		if !known {
+			errorResponse(w, http.StatusBadRequest, fmt.Sprintf("Unknown feature %q (expected one of %s)", name, strings.Join(uiFeatureNames, ", ")))
+			return
+		}
+		hidden[name] = !shown
+		changes = append(changes, fmt.Sprintf("%s=%t", name, shown))
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		b := s.config.Branding
+		b.Name = name
+		if err := validateBranding(b); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.config.Branding.Name = name
+		changes = append(changes, "name="+name)
+	}
+
+	s.config.WebUI.HiddenFeatures = nil
+	for _, name := range uiFeatureNames {
+		if hidden[name] {
+			s.config.WebUI.HiddenFeatures = append(s.config.WebUI.HiddenFeatures, name)
+		}
+	}
+	if err := s.config.Save(); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Failed to save config: "+err.Error())
+		return
+	}
+
+	sort.Strings(changes)
+	s.logActivity("user", "ui_config_update", "system", "", "", "success", strings.Join(changes, ", "))
+	jsonResponse(w, http.StatusOK, s.uiConfig())
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/mlx"
+)
+
+func TestUIConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.DefaultConfig()
+	cfg.Domain.Root = "example.com"
+	cfg.WebUI.HiddenFeatures = []string{"templates"}
+	s := &Server{config: cfg}
+
+	got := s.uiConfig()
+	if got.Name != "Basepod" || got.APIBase != "/api" || got.BaseDomain != "example.com" {
+		t.Fatalf("uiConfig() = %+v, want default name and api base on example.com", got)
+	}
+	if f := got.Features["templates"]; !f.Available || f.Enabled {
+		t.Fatalf("templates = %+v, want available but hidden", f)
+	}
+	if f := got.Features["models"]; f.Available != mlx.IsSupported() || f.Enabled != mlx.IsSupported() {
+		t.Fatalf("models = %+v, want availability to follow MLX support", f)
+	}
+	if f := got.Features["backups"]; f.Available {
+		t.Fatalf("backups = %+v, want unavailable without a backup service", f)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// validateProtocol checks that an app can be proxied with protocol
+func validateProtocol(a *app.App, protocol string) error {
+	if !app.ValidProtocol(protocol) {
+		return fmt.Errorf("protocol must be %s, %s or %s", app.ProtocolHTTP, app.ProtocolH2C, app.ProtocolGRPC)
+	}
+	if protocol != "" && protocol != app.ProtocolHTTP && (a.Type == app.AppTypeStatic || a.RedirectURL != "") {
+		return fmt.Errorf("%s is only supported for container apps", protocol)
+	}
+	return nil
+}
+
+// probeClient returns a client that talks to addr with the given protocol.
+// With useTLS the connection is made to Caddy and HTTP/2 is negotiated via
+// ALPN; otherwise h2c and grpc use cleartext HTTP/2 (prior knowledge).
+func probeClient(addr, serverName, protocol string, useTLS bool) *http.Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{Timeout: 5 * time.Second}).DialContext(ctx, network, addr)
+		},
+	}
+	protocols := new(http.Protocols)
+	switch {
+	case useTLS:
+		transport.TLSClientConfig = &tls.Config{ServerName: serverName, InsecureSkipVerify: true} // Only reachability is checked here
+		protocols.SetHTTP1(protocol == app.ProtocolHTTP || protocol == "")
+		protocols.SetHTTP2(true)
+	case protocol == app.ProtocolH2C || protocol == app.ProtocolGRPC:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+	}
+	transport.Protocols = protocols
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// grpcHealthStatus names grpc.health.v1.HealthCheckResponse.ServingStatus values
+var grpcHealthStatus = map[byte]string{0: "UNKNOWN", 1: "SERVING", 2: "NOT_SERVING", 3: "SERVICE_UNKNOWN"}
+
+// probeUpstream sends one request to baseURL the way the app's protocol
+// expects: a GET for http and h2c, a grpc.health.v1 Health/Check call for grpc
+func probeUpstream(ctx context.Context, client *http.Client, baseURL, protocol string) (string, error) {
+	if protocol != app.ProtocolGRPC {
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/", nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return fmt.Sprintf("%s %d", resp.Proto, resp.StatusCode), nil
+	}
+
+	// An empty HealthCheckRequest: uncompressed flag plus a zero length prefix
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/grpc.health.v1.Health/Check", bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.ProtoMajor != 2 {
+		return "", fmt.Errorf("answered over %s; gRPC needs HTTP/2", resp.Proto)
+	}
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status") // Trailers-only responses
+	}
+	switch status {
+	case "":
+		return "", fmt.Errorf("HTTP %d without grpc-status; not a gRPC server", resp.StatusCode)
+	case "0":
+		// HealthCheckResponse: field 1 (status) is encoded as 0x08 <value>
+		if len(body) >= 7 && body[5] == 0x08 {
+			if name, ok := grpcHealthStatus[body[6]]; ok {
+				return "health check: " + name, nil
+			}
+		}
+		return "health check: SERVING", nil
+	case "12":
+		return "gRPC server answering (no grpc.health.v1 service)", nil
+	}
+	msg := resp.Trailer.Get("Grpc-Message")
+	if msg == "" {
+		msg = resp.Header.Get("Grpc-Message")
+	}
+	return "", fmt.Errorf("health check failed: grpc-status %s %s", status, msg)
+}
+
+// UpstreamTestReport is the result of POST /api/apps/{id}/upstream/test
+type UpstreamTestReport struct {
+	Protocol string         `json:"protocol"`
+	Passed   bool           `json:"passed"`
+	Steps    []SelftestStep `json:"steps"`
+}
+
+// handleTestUpstream checks that an app answers on its upstream protocol,
+// first directly and then through Caddy on its domain
+func (s *Server) handleTestUpstream(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Type == app.AppTypeStatic || a.RedirectURL != "" {
+		errorResponse(w, http.StatusBadRequest, "Only container apps have an upstream to test")
+		return
+	}
+
+	protocol := a.Ports.Protocol
+	if protocol == "" {
+		protocol = app.ProtocolHTTP
+	}
+	report := &UpstreamTestReport{Protocol: protocol}
+	run := &selftestRun{report: &SelftestReport{}}
+	ctx := r.Context()
+
+	run.step("upstream", func() (string, error) {
+		if a.Status != app.StatusRunning || a.Ports.HostPort == 0 {
+			return "", fmt.Errorf("app is not running")
+		}
+		addr := fmt.Sprintf("127.0.0.1:%d", a.Ports.HostPort)
+		detail, err := probeUpstream(ctx, probeClient(addr, "", protocol, false), "http://"+addr, protocol)
+		if err != nil && protocol != app.ProtocolHTTP {
+			// Say so if the app only speaks HTTP/1.1
+			if _, h1err := probeUpstream(ctx, probeClient(addr, "", app.ProtocolHTTP, false), "http://"+addr, app.ProtocolHTTP); h1err == nil {
+				return "", fmt.Errorf("%v; the app answers HTTP/1.1, set protocol to http", err)
+			}
+		}
+		return detail, err
+	})
+	run.step("edge", func() (string, error) {
+		if a.Domain == "" {
+			return "", selftestWarning("app has no domain")
+		}
+		if !a.SSL.Enabled && protocol != app.ProtocolHTTP {
+			return "", selftestWarning("TLS is off for this app; gRPC and HTTP/2 clients need TLS at the edge")
+		}
+		if !a.SSL.Enabled {
+			return probeUpstream(ctx, probeClient("127.0.0.1:80", a.Domain, protocol, false), "http://"+a.Domain, protocol)
+		}
+		return probeUpstream(ctx, probeClient("127.0.0.1:443", a.Domain, protocol, true), "https://"+a.Domain, protocol)
+	})
+
+	report.Steps = run.report.Steps
+	report.Passed = !run.failed
+	status := "success"
+	if !report.Passed {
+		status = "failed"
+	}
+	var details []string
+	for _, st := range report.Steps {
+		details = append(details, st.Name+"="+st.Status)
+	}
+	s.logActivity("user", "upstream_test", "app", a.ID, a.Name, status, protocol+": "+strings.Join(details, ", "))
+	jsonResponse(w, http.StatusOK, report)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestValidateProtocol(t *testing.T) {
+	t.Parallel()
+
+	container := &app.App{Type: app.AppTypeContainer}
+	static := &app.App{Type: app.AppTypeStatic}
+	cases := []struct {
+		name     string
+		a        *app.App
+		protocol string
+		wantErr  bool
+	}{
+		{"default", container, "", false},
+		{"grpc container", container, app.ProtocolGRPC, false},
+		{"h2c container", container, app.ProtocolH2C, false},
+		{"unknown", container, "tcp", true},
+		{"grpc static", static, app.ProtocolGRPC, true},
+		{"http static", static, app.ProtocolHTTP, false},
+	}
+	for _, c := range cases {
+		if err := validateProtocol(c.a, c.protocol); (err != nil) != c.wantErr {
+			t.Fatalf("%s: validateProtocol(%q) error = %v, wantErr %v", c.name, c.protocol, err, c.wantErr)
+		}
+	}
+}
+
+// h2cServer starts a cleartext HTTP/2 server with handler
+func h2cServer(t *testing.T, handler http.HandlerFunc) string {
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestProbeUpstreamGRPC(t *testing.T) {
+	t.Parallel()
+
+	addr := h2cServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" {
+			http.Error(w, "want gRPC", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte{0, 0, 0, 0, 2, 0x08, 0x01}) // HealthCheckResponse{status: SERVING}
+		w.Header().Set("Grpc-Status", "0")
+	})
+
+	detail, err := probeUpstream(context.Background(), probeClient(addr, "", app.ProtocolGRPC, false), "http://"+addr, app.ProtocolGRPC)
+	if err != nil {
+		t.Fatalf("probeUpstream: %v", err)
+	}
+	if detail != "health check: SERVING" {
+		t.Fatalf("detail = %q", detail)
+	}
+}
+
+func TestProbeUpstreamNotGRPC(t *testing.T) {
+	t.Parallel()
+
+	addr := h2cServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "hello")
+	})
+
+	if _, err := probeUpstream(context.Background(), probeClient(addr, "", app.ProtocolGRPC, false), "http://"+addr, app.ProtocolGRPC); err == nil {
+		t.Fatalf("probeUpstream succeeded against a plain HTTP server")
+	}
+	detail, err := probeUpstream(context.Background(), probeClient(addr, "", app.ProtocolH2C, false), "http://"+addr, app.ProtocolH2C)
+	if err != nil {
+		t.Fatalf("probeUpstream h2c: %v", err)
+	}
+	if detail != "HTTP/2.0 200" {
+		t.Fatalf("detail = %q, want HTTP/2.0 200", detail)
+	}
+}
@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/storage"
+)
+
+// defaultUptimeInterval is how often each app's public URL is probed unless
+// uptime.interval says otherwise
+const defaultUptimeInterval = time.Minute
+
+// defaultUptimeRetention is how long probe results are kept unless
+// uptime.retention_days says otherwise
+const defaultUptimeRetention = 30 * 24 * time.Hour
+
+// defaultUptimeFailures is how many probes in a row must fail before an app
+// counts as down unless uptime.failures says otherwise
+const defaultUptimeFailures = 2
+
+// uptimeConcurrency bounds the probes made at once
+const uptimeConcurrency = 8
+
+// uptimeSummaryPeriod is the window of the availability shown with each app
+const uptimeSummaryPeriod = 24 * time.Hour
+
+// uptimeState tracks whether each app's public URL is up between probes
+type uptimeState struct {
+	mu     sync.Mutex
+	apps   map[string]*appUptime // By app ID
+	client *http.Client          // Probes go through this client; nil for the default
+}
+
+// appUptime is the probe state of one app
+type appUptime struct {
+	lastCheck time.Time
+	failures  int // Failed probes in a row
+	down      bool
+	downSince time.Time
+}
+
+// uptimeInterval returns uptime.interval or the default
+func (s *Server) uptimeInterval() time.Duration {
+	if s.config != nil && s.config.Uptime.Interval > 0 {
+		return time.Duration(s.config.Uptime.Interval) * time.Second
+	}
+	return defaultUptimeInterval
+}
+
+// uptimeRetention returns uptime.retention_days or the default
+func (s *Server) uptimeRetention() time.Duration {
+	if s.config != nil && s.config.Uptime.RetentionDays > 0 {
+		return time.Duration(s.config.Uptime.RetentionDays) * 24 * time.Hour
+	}
+	return defaultUptimeRetention
+}
+
+// uptimeFailures returns uptime.failures or the default
+func (s *Server) uptimeFailures() int {
+	if s.config != nil && s.config.Uptime.Failures > 0 {
+		return s.config.Uptime.Failures
+	}
+	return defaultUptimeFailures
+}
+
+// uptimeClient returns the client probes go through. Redirects aren't
+// followed and certificates are verified, as a visitor's browser would.
+func (s *Server) uptimeClient() *http.Client {
+	if s.uptime.client != nil {
+		return s.uptime.client
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// uptimeURL is the public URL of an app that gets probed
+func uptimeURL(a *app.App) string {
+	if a.SSL.Enabled {
+		return "https://" + a.Domain + "/"
+	}
+	return "http://" + a.Domain + "/"
+}
+
+// probeUptime requests url once. Any response below 500 counts as up, so
+// redirects, password prompts and missing pages don't.
+func probeUptime(ctx context.Context, client *http.Client, appID, url string) *app.UptimeCheck {
+	check := &app.UptimeCheck{AppID: appID, URL: url, CheckedAt: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	req.Header.Set("User-Agent", "basepod-uptime/1.0")
+	start := time.Now()
+	resp, err := client.Do(req)
+	check.ResponseMs = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	resp.Body.Close()
+	check.StatusCode = resp.StatusCode
+	check.Up = resp.StatusCode < 500
+	if !check.Up {
+		check.Error = resp.Status
+	}
+	return check
+}
+
+// checkAppUptime probes an app's public URL, stores the result and notifies
+// when the app goes down or comes back
+func (s *Server) checkAppUptime(ctx context.Context, a *app.App) *app.UptimeCheck {
+	check := probeUptime(ctx, s.uptimeClient(), a.ID, uptimeURL(a))
+	if err := s.storage.SaveUptimeCheck(check); err != nil {
+		log.Printf("Uptime check of %s: %v", a.Name, err)
+	}
+
+	s.uptime.mu.Lock()
+	if s.uptime.apps == nil {
+		s.uptime.apps = map[string]*appUptime{}
+	}
+	st := s.uptime.apps[a.ID]
+	if st == nil {
+		st = &appUptime{}
+		s.uptime.apps[a.ID] = st
+	}
+	st.lastCheck = check.CheckedAt
+	wentDown, cameBack := false, false
+	var downFor time.Duration
+	if check.Up {
+		if st.down {
+			cameBack = true
+			downFor = check.CheckedAt.Sub(st.downSince)
+		}
+		st.failures, st.down, st.downSince = 0, false, time.Time{}
+	} else {
+		st.failures++
+		if st.failures == 1 {
+			st.downSince = check.CheckedAt
+		}
+		if !st.down && st.failures >= s.uptimeFailures() {
+			st.down = true
+			wentDown = true
+		}
+	}
+	downSince := st.downSince
+	s.uptime.mu.Unlock()
+
+	switch {
+	case wentDown:
+		details := fmt.Sprintf("%s is down: %s", check.URL, check.Error)
+		s.logActivity("system", "uptime", "app", a.ID, a.Name, "failed", details)
+		s.sendNotifications("uptime_down", a.ID, a.Name, map[string]string{
+			"url":        check.URL,
+			"error":      check.Error,
+			"down_since": downSince.UTC().Format(time.RFC3339),
+		})
+	case cameBack:
+		details := fmt.Sprintf("%s is back up after %s", check.URL, downFor.Round(time.Second))
+		s.logActivity("system", "uptime", "app", a.ID, a.Name, "success", details)
+		s.sendNotifications("uptime_up", a.ID, a.Name, map[string]string{
+			"url":         check.URL,
+			"downtime":    downFor.Round(time.Second).String(),
+			"status_code": fmt.Sprint(check.StatusCode),
+		})
+	}
+	return check
+}
+
+// runUptimeMonitor probes each running app's public URL every uptime interval
+func (s *Server) runUptimeMonitor() {
+	if s.config != nil && s.config.Uptime.Disabled {
+		return
+	}
+	ticker := time.NewTicker(s.uptimeInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkAllUptime()
+		case <-s.healthStop:
+			return
+		}
+	}
+}
+
+// checkAllUptime probes every running app with a domain and drops the state
+// of apps that aren't probed anymore, so a stopped app isn't reported down
+func (s *Server) checkAllUptime() {
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return
+	}
+
+	probed := map[string]bool{}
+	sem := make(chan struct{}, uptimeConcurrency)
+	var wg sync.WaitGroup
+	for i := range apps {
+		a := &apps[i]
+		if a.Domain == "" || strings.HasPrefix(a.Domain, "*.") || a.Status != app.StatusRunning {
+			continue
+		}
+		probed[a.ID] = true
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			s.checkAppUptime(ctx, a)
+		}()
+	}
+	wg.Wait()
+
+	s.uptime.mu.Lock()
+	for id := range s.uptime.apps {
+		if !probed[id] {
+			delete(s.uptime.apps, id)
+		}
+	}
+	s.uptime.mu.Unlock()
+
+	if err := s.storage.CleanOldUptimeChecks(time.Now().Add(-s.uptimeRetention())); err != nil {
+		log.Printf("Failed to clean old uptime checks: %v", err)
+	}
+}
+
+// uptimeSummary combines an app's check counts with its probe state
+func (s *Server) uptimeSummary(appID string, counts storage.UptimeCounts) *app.UptimeSummary {
+	sum := &app.UptimeSummary{Status: "unknown", Checks: counts.Checks, Failures: counts.Checks - counts.Up, AvgResponseMs: counts.AvgResponseMs}
+	if counts.Checks > 0 {
+		sum.Availability = float64(counts.Up) * 100 / float64(counts.Checks)
+	}
+
+	s.uptime.mu.Lock()
+	defer s.uptime.mu.Unlock()
+	st := s.uptime.apps[appID]
+	if st == nil {
+		return sum
+	}
+	last := st.lastCheck
+	sum.LastCheck = &last
+	sum.Status = "up"
+	if st.down {
+		sum.Status = "down"
+		since := st.downSince
+		sum.DownSince = &since
+	}
+	return sum
+}
+
+// injectUptime sets the 24h availability of each app that has been probed
+func (s *Server) injectUptime(apps []app.App) {
+	counts, err := s.storage.CountUptimeChecks(time.Now().Add(-uptimeSummaryPeriod))
+	if err != nil {
+		return
+	}
+	for i := range apps {
+		if c, ok := counts[apps[i].ID]; ok {
+			apps[i].Uptime = s.uptimeSummary(apps[i].ID, c)
+		}
+	}
+}
+
+// UptimeReport is an app's availability over a period with its recent checks
+type UptimeReport struct {
+	Period  string             `json:"period"`
+	URL     string             `json:"url,omitempty"`
+	Summary *app.UptimeSummary `json:"summary"`
+	Checks  []app.UptimeCheck  `json:"checks"` // Newest last; failed ones and the latest limit ones
+}
+
+// handleGetUptime returns an app's availability and response times over
+// ?period= (default 24h, at most the retention), with up to ?limit= recent
+// checks (default 50) and every failed one
+func (s *Server) handleGetUptime(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	window := uptimeSummaryPeriod
+	if period != "" {
+		if window, err = parseTrafficPeriod(period); err != nil {
+			errorResponse(w, http.StatusBadRequest, "period must be a duration like 1h, 24h or 7d")
+			return
+		}
+	} else {
+		period = "24h"
+	}
+	if window > s.uptimeRetention() {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("period is longer than the %d day uptime retention", int(s.uptimeRetention()/(24*time.Hour))))
+		return
+	}
+	limit := 50
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, 1000)
+	}
+
+	checks, err := s.storage.ListUptimeChecks(a.ID, time.Now().Add(-window))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var counts storage.UptimeCounts
+	var responseSum int64
+	recent := []app.UptimeCheck{}
+	for i, c := range checks {
+		counts.Checks++
+		if c.Up {
+			counts.Up++
+			responseSum += c.ResponseMs
+		}
+		if !c.Up || i >= len(checks)-limit {
+			recent = append(recent, c)
+		}
+	}
+	if counts.Up > 0 {
+		counts.AvgResponseMs = float64(responseSum) / float64(counts.Up)
+	}
+
+	report := UptimeReport{Period: period, Summary: s.uptimeSummary(a.ID, counts), Checks: recent}
+	if a.Domain != "" {
+		report.URL = uptimeURL(a)
+	}
+	jsonResponse(w, http.StatusOK, report)
+}
+
+// handleCheckUptime probes an app's public URL now
+func (s *Server) handleCheckUptime(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if a.Domain == "" {
+		errorResponse(w, http.StatusBadRequest, "App has no domain")
+		return
+	}
+	if strings.HasPrefix(a.Domain, "*.") {
+		errorResponse(w, http.StatusBadRequest, "Wildcard domains can't be probed")
+		return
+	}
+	jsonResponse(w, http.StatusOK, s.checkAppUptime(r.Context(), a))
+}
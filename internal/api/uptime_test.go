@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestUptimeMonitor(t *testing.T) {
+	ts := newTestServer(t)
+
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer site.Close()
+	// Every domain resolves to the test site
+	ts.uptime.client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, site.Listener.Addr().String())
+		},
+	}}
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "uptime-web", Domain: "uptime-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+
+	var check app.UptimeCheck
+	if code := ts.do("POST", "/api/apps/uptime-web/uptime/check", nil, &check); code != http.StatusOK {
+		t.Fatalf("check: status %d", code)
+	}
+	if !check.Up || check.StatusCode != http.StatusOK || check.URL == "" {
+		t.Fatalf("check = %+v, want up with 200", check)
+	}
+
+	// One failure isn't an outage yet; the second one is
+	status.Store(http.StatusBadGateway)
+	ts.checkAppUptime(context.Background(), &a)
+	var report UptimeReport
+	ts.do("GET", "/api/apps/uptime-web/uptime", nil, &report)
+	if report.Summary.Status != "up" || report.Summary.Checks != 2 || report.Summary.Failures != 1 {
+		t.Fatalf("after one failure: %+v, want up with 1 of 2 failed", report.Summary)
+	}
+	ts.checkAppUptime(context.Background(), &a)
+	report = UptimeReport{}
+	ts.do("GET", "/api/apps/uptime-web/uptime", nil, &report)
+	if report.Summary.Status != "down" || report.Summary.DownSince == nil {
+		t.Fatalf("after two failures: %+v, want down", report.Summary)
+	}
+	if got := report.Summary.Availability; got < 33 || got > 34 {
+		t.Fatalf("availability = %.2f, want 33.33", got)
+	}
+	if n := len(report.Checks); n != 3 || report.Checks[2].Error == "" {
+		t.Fatalf("checks = %+v, want 3 with the last failed", report.Checks)
+	}
+
+	var list app.AppListResponse
+	ts.do("GET", "/api/apps", nil, &list)
+	for _, listed := range list.Apps {
+		if listed.ID == a.ID && (listed.Uptime == nil || listed.Uptime.Status != "down") {
+			t.Fatalf("listed uptime = %+v, want down", listed.Uptime)
+		}
+	}
+
+	status.Store(http.StatusOK)
+	ts.checkAppUptime(context.Background(), &a)
+	report = UptimeReport{}
+	ts.do("GET", "/api/apps/uptime-web/uptime", nil, &report)
+	if report.Summary.Status != "up" || report.Summary.DownSince != nil {
+		t.Fatalf("after recovery: %+v, want up", report.Summary)
+	}
+	if code := ts.do("GET", "/api/apps/uptime-web/uptime?period=365d", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("period beyond retention: status %d, want 400", code)
+	}
+
+	ts.do("DELETE", "/api/apps/"+a.ID, nil, nil)
+}
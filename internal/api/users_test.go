@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// login signs in as a user and returns the session token
+func (ts *testServer) login(email, password string) string {
+	ts.t.Helper()
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if code := ts.doAs("", "POST", "/api/auth/login", map[string]string{"email": email, "password": password}, &resp); code != http.StatusOK {
+		ts.t.Fatalf("login %s: status %d", email, code)
+	}
+	return resp.Token
+}
+
+func TestUserRolesAndAppGrants(t *testing.T) {
+	ts := newTestServer(t)
+
+	var granted, other app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "rbac-granted", Domain: "rbac-granted.test"}, &granted)
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "rbac-other", Domain: "rbac-other.test"}, &other)
+	ts.waitForStatus(granted.ID, app.StatusRunning)
+	ts.waitForStatus(other.ID, app.StatusRunning)
+
+	var created struct {
+		User   app.User `json:"user"`
+		AppIDs []string `json:"app_ids"`
+	}
+	body := map[string]interface{}{"email": "dev@example.com", "password": "deployer-pass", "role": "deployer", "app_ids": []string{"rbac-granted"}}
+	if code := ts.do("POST", "/api/users", body, &created); code != http.StatusCreated {
+		t.Fatalf("create user: status %d", code)
+	}
+	if len(created.AppIDs) != 1 || created.AppIDs[0] != granted.ID {
+		t.Fatalf("granted apps = %v, want [%s]", created.AppIDs, granted.ID)
+	}
+	if code := ts.do("POST", "/api/users", body, nil); code != http.StatusConflict {
+		t.Fatalf("duplicate user: status %d, want 409", code)
+	}
+	if code := ts.do("POST", "/api/users", map[string]string{"email": "x@example.com", "password": "long-enough", "role": "owner"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown role: status %d, want 400", code)
+	}
+
+	dev := ts.login("dev@example.com", "deployer-pass")
+	var list app.AppListResponse
+	ts.doAs(dev, "GET", "/api/apps", nil, &list)
+	if len(list.Apps) != 1 || list.Apps[0].ID != granted.ID {
+		t.Fatalf("deployer sees %d apps, want only rbac-granted", len(list.Apps))
+	}
+	if code := ts.doAs(dev, "GET", "/api/apps/rbac-other", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("deployer reading an ungranted app: status %d, want 403", code)
+	}
+	if code := ts.doAs(dev, "POST", "/api/apps/"+other.ID+"/stop", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("deployer stopping an ungranted app: status %d, want 403", code)
+	}
+	if code := ts.doAs(dev, "POST", "/api/apps/"+granted.ID+"/restart", nil, nil); code != http.StatusOK {
+		t.Fatalf("deployer restarting a granted app: status %d, want 200", code)
+	}
+	if code := ts.doAs(dev, "GET", "/api/users", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("deployer listing users: status %d, want 403", code)
+	}
+
+	// A role change signs the user out; the new role applies from their next login
+	if code := ts.do("PUT", "/api/users/"+created.User.ID+"/role", map[string]string{"role": "viewer"}, nil); code != http.StatusOK {
+		t.Fatalf("change role: status %d", code)
+	}
+	if code := ts.doAs(dev, "GET", "/api/apps/"+granted.ID, nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("session from before the role change: status %d, want 401", code)
+	}
+	dev = ts.login("dev@example.com", "deployer-pass")
+	if code := ts.doAs(dev, "POST", "/api/apps/"+granted.ID+"/restart", nil, nil); code != http.StatusForbidden {
+		t.Fatalf("viewer restarting an app: status %d, want 403", code)
+	}
+	if code := ts.doAs(dev, "GET", "/api/apps/"+granted.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("viewer reading a granted app: status %d, want 200", code)
+	}
+
+	var fetched struct {
+		User   app.User `json:"user"`
+		AppIDs []string `json:"app_ids"`
+	}
+	if code := ts.do("GET", "/api/users/"+created.User.ID, nil, &fetched); code != http.StatusOK || fetched.User.Role != "viewer" || len(fetched.AppIDs) != 1 {
+		t.Fatalf("get user: status %d, %+v", code, fetched)
+	}
+
+	// Deleting a user signs them out and drops their grants
+	if code := ts.do("DELETE", "/api/users/"+created.User.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete user: status %d", code)
+	}
+	if code := ts.doAs(dev, "GET", "/api/apps/"+granted.ID, nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("deleted user's session: status %d, want 401", code)
+	}
+	if ids, _ := ts.storage.GetUserAppAccess(created.User.ID); len(ids) != 0 {
+		t.Fatalf("grants left after delete: %v", ids)
+	}
+}
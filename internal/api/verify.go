@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+const (
+	defaultVerifyTimeout = time.Minute
+	maxVerifyTimeout     = 10 * time.Minute
+	verifyRetryInterval  = 2 * time.Second
+)
+
+// validateVerifyConfig checks the verify: section of an app's basepod.yaml
+func validateVerifyConfig(v *app.VerifyConfig) error {
+	if v.Path == "" && v.Command == "" {
+		return fmt.Errorf("verify: needs a path, a command or both")
+	}
+	if v.Path != "" && !strings.HasPrefix(v.Path, "/") {
+		return fmt.Errorf("verify: path must start with /")
+	}
+	if v.Path == "" && (v.Status != 0 || v.Body != "") {
+		return fmt.Errorf("verify: status and body need a path")
+	}
+	if v.Status != 0 && (v.Status < 100 || v.Status > 599) {
+		return fmt.Errorf("verify: invalid status %d", v.Status)
+	}
+	if v.Timeout < 0 || time.Duration(v.Timeout)*time.Second > maxVerifyTimeout {
+		return fmt.Errorf("verify: timeout must be at most %d seconds", int(maxVerifyTimeout.Seconds()))
+	}
+	return nil
+}
+
+// verifyRelease runs an app's post-deploy check against its new container
+func (s *Server) verifyRelease(ctx context.Context, a *app.App, v *app.VerifyConfig, writeLine func(string)) error {
+	timeout := defaultVerifyTimeout
+	if v.Timeout > 0 {
+		timeout = time.Duration(v.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if v.Path != "" {
+		writeLine(fmt.Sprintf("Verifying GET %s...", v.Path))
+		if err := verifyHTTP(ctx, fmt.Sprintf("http://localhost:%d", a.Ports.HostPort), v); err != nil {
+			return err
+		}
+	}
+	if v.Command != "" {
+		writeLine("Verifying with: " + v.Command)
+		if err := s.verifyCommand(ctx, a, v.Command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyHTTP requests the check's path until it answers with the expected
+// status and body, giving apps that are still warming up until ctx ends
+func verifyHTTP(ctx context.Context, baseURL string, v *app.VerifyConfig) error {
+	want := v.Status
+	if want == 0 {
+		want = http.StatusOK
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	check := func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+v.Path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("GET %s: %w", v.Path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if resp.StatusCode != want {
+			return fmt.Errorf("GET %s returned %d, want %d", v.Path, resp.StatusCode, want)
+		}
+		if v.Body != "" && !strings.Contains(string(body), v.Body) {
+			return fmt.Errorf("GET %s response doesn't contain %q", v.Path, v.Body)
+		}
+		return nil
+	}
+
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(verifyRetryInterval):
+		}
+	}
+}
+
+// verifyCommand runs the check's command in a one-off container from the
+// app's new image. The command fails the check by exiting non-zero; the
+// end of its output is part of the error.
+func (s *Server) verifyCommand(ctx context.Context, a *app.App, command string) error {
+	containerID, err := s.createOneOffContainer(ctx, a, "basepod-"+a.Name+"-verify", []string{"/bin/sh", "-c", command}, map[string]string{
+		"basepod.verify.app": a.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create verify container: %w", err)
+	}
+	defer s.podman.RemoveContainer(context.Background(), containerID, true)
+
+	if err := s.podman.StartContainer(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to start verify container: %w", err)
+	}
+	exitCode, waitErr := s.podman.WaitContainer(ctx, containerID)
+	if ctx.Err() != nil {
+		_ = s.podman.StopContainer(context.Background(), containerID, 10)
+		return fmt.Errorf("verify command timed out")
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	if exitCode == 0 {
+		return nil
+	}
+
+	output := ""
+	if logs, err := s.podman.ContainerLogs(context.Background(), containerID, podman.LogOpts{Stdout: true, Stderr: true, Tail: "20"}); err == nil {
+		data, _ := io.ReadAll(logs)
+		logs.Close()
+		output = strings.TrimSpace(podman.DemuxLogs(data))
+	}
+	if output == "" {
+		return fmt.Errorf("verify command exited with %d", exitCode)
+	}
+	return fmt.Errorf("verify command exited with %d:\n%s", exitCode, output)
+}
+
+// previousRelease returns the newest successful deployment before the
+// latest one that has an image to roll back to, or nil
+func previousRelease(deployments []app.DeploymentRecord) *app.DeploymentRecord {
+	for i := 1; i < len(deployments); i++ {
+		if deployments[i].Status == "success" && deployments[i].Image != "" {
+			return &deployments[i]
+		}
+	}
+	return nil
+}
+
+// failVerifiedDeploy ends a deploy whose release failed its check: the
+// failure is reported and, with verify.rollback, the previous release is
+// brought back
+func (s *Server) failVerifiedDeploy(ctx context.Context, a *app.App, v *app.VerifyConfig, verifyErr error, writeLine func(string)) {
+	s.logActivity("system", "deploy", "app", a.ID, a.Name, "failed", "verify: "+verifyErr.Error())
+	s.sendNotifications("deploy_failed", a.ID, a.Name, map[string]string{
+		"reason": "verify failed: " + verifyErr.Error(),
+	})
+
+	target := previousRelease(a.Deployments)
+	switch {
+	case !v.Rollback:
+		writeLine("The new release keeps running; set verify.rollback to revert failed releases")
+	case target == nil:
+		writeLine("WARNING: No previous release to roll back to")
+	default:
+		writeLine("Rolling back to " + target.Image + "...")
+		if _, err := s.rollbackApp(ctx, a, target); err != nil {
+			writeLine("WARNING: Rollback failed: " + err.Error())
+		} else {
+			writeLine("Rolled back to the previous release")
+			s.logActivity("system", "rollback", "app", a.ID, a.Name, "success", "verify failed")
+		}
+	}
+	writeLine("ERROR: Verify failed: " + verifyErr.Error())
+}
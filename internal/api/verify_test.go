@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestValidateVerifyConfig(t *testing.T) {
+	t.Parallel()
+
+	valid := []app.VerifyConfig{
+		{Path: "/healthz"},
+		{Path: "/", Status: 301, Body: "ok", Timeout: 120, Rollback: true},
+		{Command: "curl -fsS http://localhost:3000/"},
+	}
+	for _, v := range valid {
+		if err := validateVerifyConfig(&v); err != nil {
+			t.Fatalf("validateVerifyConfig(%+v) = %v", v, err)
+		}
+	}
+
+	invalid := []app.VerifyConfig{
+		{},
+		{Path: "healthz"},
+		{Command: "true", Status: 200},
+		{Path: "/", Status: 42},
+		{Path: "/", Timeout: 3600},
+	}
+	for _, v := range invalid {
+		if err := validateVerifyConfig(&v); err == nil {
+			t.Fatalf("validateVerifyConfig(%+v) = nil, want an error", v)
+		}
+	}
+}
+
+func TestVerifyHTTP(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Warming up on the first request
+		if requests.Add(1) == 1 {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"ok","version":"1.4.2"}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := verifyHTTP(ctx, srv.URL, &app.VerifyConfig{Path: "/healthz", Body: `"version":"1.4.2"`}); err != nil {
+		t.Fatalf("verifyHTTP = %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("verifyHTTP made %d requests, want a retry after the 503", n)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := verifyHTTP(ctx, srv.URL, &app.VerifyConfig{Path: "/healthz", Body: "1.5.0"})
+	if err == nil || !strings.Contains(err.Error(), `doesn't contain "1.5.0"`) {
+		t.Fatalf("verifyHTTP with the wrong body = %v", err)
+	}
+}
+
+func TestPreviousRelease(t *testing.T) {
+	t.Parallel()
+
+	deployments := []app.DeploymentRecord{
+		{ID: "4", Image: "basepod/web:4", Status: "failed"},
+		{ID: "3", Image: "basepod/web:3", Status: "failed"},
+		{ID: "2", Status: "success"},
+		{ID: "1", Image: "basepod/web:1", Status: "success"},
+	}
+	if got := previousRelease(deployments); got == nil || got.ID != "1" {
+		t.Fatalf("previousRelease = %+v, want deployment 1", got)
+	}
+	if got := previousRelease(deployments[:3]); got != nil {
+		t.Fatalf("previousRelease without a good image = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,356 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/diskutil"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+// AppVolumeInfo reports the on-disk size of one of an app's named volumes
+type AppVolumeInfo struct {
+	Name          string `json:"name"`
+	Volume        string `json:"volume"` // Podman volume name
+	ContainerPath string `json:"container_path"`
+	Size          int64  `json:"size"`
+	Formatted     string `json:"formatted"`
+	SuggestedSize string `json:"suggested_size,omitempty"`
+	Warning       string `json:"warning,omitempty"`
+}
+
+// appVolumeName returns the Podman volume name backing a named app volume
+func appVolumeName(a *app.App, v app.VolumeMount) string {
+	return fmt.Sprintf("basepod-%s-%s", a.Name, v.Name)
+}
+
+// ensureAppVolume creates the named volume for v with basepod labels so it can be
+// traced back to its app. Existing volumes are left untouched.
+func (s *Server) ensureAppVolume(ctx context.Context, a *app.App, v app.VolumeMount, templateID string) string {
+	name := appVolumeName(a, v)
+	labels := map[string]string{
+		"basepod.app":    a.Name,
+		"basepod.app.id": a.ID,
+		"basepod.volume": v.Name,
+	}
+	if templateID != "" {
+		labels["basepod.template"] = templateID
+	}
+	if volumes, err := s.podman.ListVolumes(ctx); err == nil {
+		for _, vol := range volumes {
+			if vol.Name == name {
+				return name
+			}
+		}
+	}
+	if err := s.podman.CreateVolume(ctx, name, labels); err != nil {
+		log.Printf("Warning: failed to create volume %s: %v", name, err)
+	}
+	return name
+}
+
+// parseVolumeSpec reads a basepod.yaml "name:/path[:ro]" named volume. Host
+// paths aren't allowed, since deploys can come from tokens scoped to one app.
+func parseVolumeSpec(spec string) (app.VolumeMount, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || !strings.HasPrefix(parts[1], "/") {
+		return app.VolumeMount{}, fmt.Errorf("invalid volume %q: use name:/container/path", spec)
+	}
+	if strings.HasPrefix(parts[0], "/") || strings.HasPrefix(parts[0], ".") || strings.HasPrefix(parts[0], "~") {
+		return app.VolumeMount{}, fmt.Errorf("volume %q mounts a host path; use a named volume", spec)
+	}
+	if !volumeNamePattern.MatchString(parts[0]) {
+		return app.VolumeMount{}, fmt.Errorf("invalid volume name %q: use letters, digits, dots, dashes and underscores", parts[0])
+	}
+	v := app.VolumeMount{Name: parts[0], ContainerPath: parts[1]}
+	if len(parts) == 3 {
+		if parts[2] != "ro" && parts[2] != "rw" {
+			return app.VolumeMount{}, fmt.Errorf("invalid volume %q: mode must be ro or rw", spec)
+		}
+		v.ReadOnly = parts[2] == "ro"
+	}
+	return v, nil
+}
+
+// parseAppVolumes reads an app's basepod.yaml volumes, keeping the
+// suggested size of ones the app already has
+func parseAppVolumes(specs []string, existing []app.VolumeMount) ([]app.VolumeMount, error) {
+	sizes := make(map[string]string, len(existing))
+	for _, v := range existing {
+		sizes[v.Name] = v.Size
+	}
+	seen := map[string]bool{}
+	var volumes []app.VolumeMount
+	for _, spec := range specs {
+		v, err := parseVolumeSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("volume %s is declared twice", v.Name)
+		}
+		seen[v.Name] = true
+		v.Size = sizes[v.Name]
+		volumes = append(volumes, v)
+	}
+	return volumes, nil
+}
+
+// volumeWarning flags a volume that outgrew its suggested size or the server-wide limit
+func volumeWarning(size int64, suggested, limit string) string {
+	if limit != "" {
+		if max, err := diskutil.ParseBytes(limit); err == nil && max > 0 && size > max {
+			return fmt.Sprintf("uses %s, above the %s volume limit", diskutil.FormatBytes(size), limit)
+		}
+	}
+	if suggested != "" {
+		if max, err := diskutil.ParseBytes(suggested); err == nil && max > 0 && size > max {
+			return fmt.Sprintf("uses %s, above the suggested %s", diskutil.FormatBytes(size), suggested)
+		}
+	}
+	return ""
+}
+
+// handleAppVolumes reports per-volume disk usage for an app
+func (s *Server) handleAppVolumes(w http.ResponseWriter, r *http.Request) {
+	a, err := s.resolveApp(r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if a == nil {
+		errorResponse(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	mountpoints := make(map[string]string)
+	if volumes, err := s.podman.ListVolumes(r.Context()); err == nil {
+		for _, vol := range volumes {
+			mountpoints[vol.Name] = vol.Mountpoint
+		}
+	}
+
+	limit := ""
+	if s.config != nil {
+		limit = s.config.Podman.VolumeWarnSize
+	}
+
+	result := []AppVolumeInfo{}
+	for _, v := range a.Volumes {
+		info := AppVolumeInfo{
+			Name:          v.Name,
+			ContainerPath: v.ContainerPath,
+			SuggestedSize: v.Size,
+		}
+		path := v.HostPath
+		if path == "" {
+			info.Volume = appVolumeName(a, v)
+			path = mountpoints[info.Volume]
+		}
+		if path != "" {
+			info.Size = diskutil.DirSize(path)
+		}
+		info.Formatted = diskutil.FormatBytes(info.Size)
+		info.Warning = volumeWarning(info.Size, v.Size, limit)
+		result = append(result, info)
+	}
+
+	jsonResponse(w, http.StatusOK, result)
+}
+
+// VolumeInfo describes a Podman volume with the app it belongs to and its
+// disk usage. Orphaned volumes belong to no app and are removed by an
+// aggressive prune.
+type VolumeInfo struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	CreatedAt  string            `json:"created_at"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	App        string            `json:"app,omitempty"`
+	Size       int64             `json:"size"`
+	Formatted  string            `json:"formatted"`
+	Orphaned   bool              `json:"orphaned"`
+	UsedBy     []string          `json:"used_by,omitempty"` // Containers mounting it; set by inspect
+}
+
+// CreateVolumeRequest is the body of POST /api/volumes
+type CreateVolumeRequest struct {
+	Name string `json:"name"`
+	App  string `json:"app,omitempty"` // Labels the volume as this app's
+}
+
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// volumeInfo describes v, given the volumes apps own
+func volumeInfo(v podman.Volume, owned map[string]string) VolumeInfo {
+	info := VolumeInfo{
+		Name:       v.Name,
+		Driver:     v.Driver,
+		Mountpoint: v.Mountpoint,
+		CreatedAt:  v.CreatedAt,
+		Labels:     v.Labels,
+		App:        owned[v.Name],
+		Orphaned:   volumeKeepReason(v, owned) == "",
+	}
+	if v.Mountpoint != "" {
+		info.Size = diskutil.DirSize(v.Mountpoint)
+	}
+	info.Formatted = diskutil.FormatBytes(info.Size)
+	return info
+}
+
+// findVolume looks up a volume and the volumes apps own
+func (s *Server) findVolume(ctx context.Context, name string) (*podman.Volume, map[string]string, error) {
+	volumes, err := s.podman.ListVolumes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := appVolumes(apps, volumes)
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return &volumes[i], owned, nil
+		}
+	}
+	return nil, owned, nil
+}
+
+// handleVolumes lists Podman volumes with their app, size and whether
+// they're orphaned
+func (s *Server) handleVolumes(w http.ResponseWriter, r *http.Request) {
+	volumes, err := s.podman.ListVolumes(r.Context())
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "failed to list volumes: "+err.Error())
+		return
+	}
+	apps, err := s.storage.ListApps()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	owned := appVolumes(apps, volumes)
+	result := []VolumeInfo{}
+	for _, v := range volumes {
+		result = append(result, volumeInfo(v, owned))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	jsonResponse(w, http.StatusOK, result)
+}
+
+// handleCreateVolume creates a named volume, labeled as an app's when one
+// is given so it survives prunes and follows the app's lifecycle
+func (s *Server) handleCreateVolume(w http.ResponseWriter, r *http.Request) {
+	var req CreateVolumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !volumeNamePattern.MatchString(req.Name) || len(req.Name) > 128 {
+		errorResponse(w, http.StatusBadRequest, "Invalid volume name: use letters, digits, dots, dashes and underscores")
+		return
+	}
+	existing, _, err := s.findVolume(r.Context(), req.Name)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if existing != nil {
+		errorResponse(w, http.StatusConflict, fmt.Sprintf("Volume %s already exists", req.Name))
+		return
+	}
+
+	labels := map[string]string{}
+	if req.App != "" {
+		a, err := s.resolveApp(req.App)
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if a == nil {
+			errorResponse(w, http.StatusNotFound, "App not found")
+			return
+		}
+		labels["basepod.app"] = a.Name
+		labels["basepod.app.id"] = a.ID
+	}
+	if err := s.podman.CreateVolume(r.Context(), req.Name, labels); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logActivity("user", "create_volume", "volume", req.Name, req.Name, "success", req.App)
+
+	v, owned, err := s.findVolume(r.Context(), req.Name)
+	if err != nil || v == nil {
+		jsonResponse(w, http.StatusCreated, VolumeInfo{Name: req.Name, Labels: labels})
+		return
+	}
+	jsonResponse(w, http.StatusCreated, volumeInfo(*v, owned))
+}
+
+// handleInspectVolume describes one volume and the containers mounting it
+func (s *Server) handleInspectVolume(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	v, owned, err := s.findVolume(ctx, r.PathValue("name"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if v == nil {
+		errorResponse(w, http.StatusNotFound, "Volume not found")
+		return
+	}
+	info := volumeInfo(*v, owned)
+	if containers, err := s.podman.ListContainers(ctx, true); err == nil {
+		for _, c := range containers {
+			inspect, err := s.podman.InspectContainer(ctx, c.ID)
+			if err != nil {
+				continue
+			}
+			for _, m := range inspect.Mounts {
+				if m.Type == "volume" && m.Name == v.Name {
+					info.UsedBy = append(info.UsedBy, strings.TrimPrefix(inspect.Name, "/"))
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(info.UsedBy)
+	jsonResponse(w, http.StatusOK, info)
+}
+
+// handleDeleteVolume removes a volume. An app's volume holds its data, so
+// removing one takes ?force=true.
+func (s *Server) handleDeleteVolume(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	force := r.URL.Query().Get("force") == "true"
+	v, owned, err := s.findVolume(r.Context(), name)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if v == nil {
+		errorResponse(w, http.StatusNotFound, "Volume not found")
+		return
+	}
+	if reason := volumeKeepReason(*v, owned); reason != "" && !force {
+		errorResponse(w, http.StatusConflict, fmt.Sprintf("Volume %s is a %s; pass force=true to remove it", name, reason))
+		return
+	}
+	if err := s.podman.RemoveVolume(r.Context(), name, force); err != nil {
+		errorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.logActivity("user", "delete_volume", "volume", name, name, "success", "")
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestVolumeWarningFlagsOversizedVolumes(t *testing.T) {
+	t.Parallel()
+
+	const gb = int64(1) << 30
+
+	if got := volumeWarning(2*gb, "10GB", ""); got != "" {
+		t.Fatalf("expected no warning under the suggested size, got %q", got)
+	}
+	if got := volumeWarning(12*gb, "10GB", ""); !strings.Contains(got, "suggested 10GB") {
+		t.Fatalf("expected suggested-size warning, got %q", got)
+	}
+	if got := volumeWarning(6*gb, "10GB", "5GB"); !strings.Contains(got, "5GB volume limit") {
+		t.Fatalf("expected server limit warning, got %q", got)
+	}
+	if got := volumeWarning(6*gb, "", ""); got != "" {
+		t.Fatalf("expected no warning without a size, got %q", got)
+	}
+}
+
+func TestVolumesAPI(t *testing.T) {
+	ts := newTestServer(t)
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "vol-shop", Domain: "vol-shop.test"}, &a)
+
+	var created VolumeInfo
+	if code := ts.do("POST", "/api/volumes", CreateVolumeRequest{Name: "vol-shop-uploads", App: "vol-shop"}, &created); code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+	if created.App != "vol-shop" || created.Orphaned {
+		t.Fatalf("created = %+v, want a volume of vol-shop", created)
+	}
+	if code := ts.do("POST", "/api/volumes", CreateVolumeRequest{Name: "vol-stray"}, nil); code != http.StatusCreated {
+		t.Fatalf("create stray: status %d", code)
+	}
+	if code := ts.do("POST", "/api/volumes", CreateVolumeRequest{Name: "vol-stray"}, nil); code != http.StatusConflict {
+		t.Fatalf("create existing: status %d, want 409", code)
+	}
+	if code := ts.do("POST", "/api/volumes", CreateVolumeRequest{Name: "../etc"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("create invalid name: status %d, want 400", code)
+	}
+
+	var volumes []VolumeInfo
+	ts.do("GET", "/api/volumes", nil, &volumes)
+	orphaned := map[string]bool{}
+	for _, v := range volumes {
+		orphaned[v.Name] = v.Orphaned
+	}
+	if orphaned["vol-shop-uploads"] || !orphaned["vol-stray"] {
+		t.Fatalf("orphaned = %v, want only vol-stray", orphaned)
+	}
+
+	if code := ts.do("DELETE", "/api/volumes/vol-shop-uploads", nil, nil); code != http.StatusConflict {
+		t.Fatalf("delete app volume: status %d, want 409", code)
+	}
+	if code := ts.do("DELETE", "/api/volumes/vol-shop-uploads?force=true", nil, nil); code != http.StatusOK {
+		t.Fatalf("force delete app volume: status %d", code)
+	}
+	if code := ts.do("DELETE", "/api/volumes/vol-stray", nil, nil); code != http.StatusOK {
+		t.Fatalf("delete stray: status %d", code)
+	}
+	if code := ts.do("GET", "/api/volumes/vol-stray", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("inspect deleted: status %d, want 404", code)
+	}
+}
+
+func TestParseAppVolumes(t *testing.T) {
+	t.Parallel()
+	existing := []app.VolumeMount{{Name: "data", ContainerPath: "/old", Size: "10GB"}}
+	volumes, err := parseAppVolumes([]string{"data:/var/lib/postgresql/data", "cache:/cache:ro"}, existing)
+	if err != nil {
+		t.Fatalf("parseAppVolumes: %v", err)
+	}
+	if len(volumes) != 2 || volumes[0].ContainerPath != "/var/lib/postgresql/data" || volumes[0].Size != "10GB" || !volumes[1].ReadOnly {
+		t.Fatalf("volumes = %+v", volumes)
+	}
+	for _, specs := range [][]string{{"./data:/data"}, {"/srv:/data"}, {"~/data:/data"}, {"data"}, {"da ta:/data"}, {"data:/a", "data:/b"}} {
+		if _, err := parseAppVolumes(specs, nil); err == nil {
+			t.Fatalf("parseAppVolumes(%v): want an error", specs)
+		}
+	}
+}
+
+func TestSourceDeployRejectsBindMounts(t *testing.T) {
+	ts := newTestServer(t)
+	output := ts.sourceDeploy(SourceDeployConfig{Name: "vol-bind", Volumes: []string{"./data:/data"}})
+	if !strings.Contains(output, "host path") {
+		t.Fatalf("deploy output = %q, want the bind mount rejected", output)
+	}
+	var a app.App
+	if code := ts.do("GET", "/api/apps/vol-bind", nil, &a); code != http.StatusNotFound {
+		t.Fatalf("app created despite the rejected volume: status %d", code)
+	}
+}
+
+func TestMigrationArchivesValidate(t *testing.T) {
+	ts := newTestServer(t)
+	if code := ts.do("GET", "/api/volumes/no-such-volume/export", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("export of a missing volume: status %d, want 404", code)
+	}
+	if code := ts.do("PUT", "/api/volumes/bad%20name/import", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("import into an invalid volume name: status %d, want 400", code)
+	}
+	if code := ts.do("GET", "/api/images/export", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("export without an image: status %d, want 400", code)
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+	"github.com/base-go/basepod/internal/caddy"
+)
+
+// validateWebSocket checks an app's long-lived connection settings
+func validateWebSocket(a *app.App, c *app.WebSocketConfig) error {
+	if (c.IdleTimeout != 0 || c.DrainTimeout != 0) && (a.Type == app.AppTypeStatic || a.RedirectURL != "") {
+		return fmt.Errorf("websocket settings are only supported for container apps")
+	}
+	if c.IdleTimeout != 0 && (c.IdleTimeout < app.MinWebSocketIdleTimeout || c.IdleTimeout > app.MaxWebSocketIdleTimeout) {
+		return fmt.Errorf("idle_timeout must be 0 (no limit) or between %d and %d seconds", app.MinWebSocketIdleTimeout, app.MaxWebSocketIdleTimeout)
+	}
+	if c.DrainTimeout < 0 || c.DrainTimeout > app.MaxDrainTimeout {
+		return fmt.Errorf("drain_timeout must be between 0 and %d seconds", app.MaxDrainTimeout)
+	}
+	return nil
+}
+
+// appRouteStreams returns the long-lived connection settings for an app's
+// routes. The close delay matches the drain period so connections to the
+// previous container survive the route switch at the end of a deploy.
+func appRouteStreams(a *app.App) caddy.Streams {
+	return caddy.Streams{IdleTimeout: a.StreamIdleTimeout(), CloseDelay: a.DrainPeriod()}
+}
+
+// drainingContainerName is what an app's previous container is renamed to
+// while it drains
+func drainingContainerName(appName string) string {
+	return "basepod-" + appName + "-draining"
+}
+
+// drainPartnerPort is the host port for a new container while the previous
+// one drains on port. Apps alternate between their assigned port and the one
+// 25000 away in the same range.
+func drainPartnerPort(appID string, port int) int {
+	base := assignHostPort(appID)
+	if port != base {
+		return base
+	}
+	return 10000 + (base-10000+25000)%50000
+}
+
+// drainHandoff is an app's previous container, kept serving its open
+// connections while a deploy brings up the next one
+type drainHandoff struct {
+	containerID string
+	hostPort    int
+	image       string
+	period      time.Duration
+}
+
+// beginDrain starts a blue/green deploy: the app's current container is moved
+// aside instead of stopped. It is renamed, keeps serving on its host port, and
+// a.Ports.HostPort switches to the partner port for the new container; routes
+// only move over once that one is ready. It returns nil for apps deployed with
+// recreate, in which case the caller stops the old container as usual.
+func (s *Server) beginDrain(ctx context.Context, a *app.App) *drainHandoff {
+	if a.Deployment.Recreate {
+		return nil
+	}
+	return s.setAsideContainer(ctx, a, a.DrainPeriod())
+}
+
+// setAsideContainer is beginDrain with an explicit drain period; with 0 the
+// old container is stopped as soon as the new one is serving
+func (s *Server) setAsideContainer(ctx context.Context, a *app.App, period time.Duration) *drainHandoff {
+	if a.ContainerID == "" || a.Ports.HostPort == 0 {
+		return nil
+	}
+	// A container still draining from an earlier deploy is cut off now
+	draining := drainingContainerName(a.Name)
+	_ = s.podman.StopContainer(ctx, draining, 10)
+	_ = s.podman.RemoveContainer(ctx, draining, true)
+
+	if err := s.podman.RenameContainer(ctx, a.ContainerID, draining); err != nil {
+		log.Printf("Warning: failed to set aside container for %s, stopping it instead: %v", a.Name, err)
+		return nil
+	}
+	h := &drainHandoff{containerID: a.ContainerID, hostPort: a.Ports.HostPort, image: a.Image, period: period}
+	a.Ports.HostPort = drainPartnerPort(a.ID, a.Ports.HostPort)
+	return h
+}
+
+// finishDrain is deferred after beginDrain or setAsideContainer. If the new
+// container is running, the old one is stopped once its drain period is
+// over; otherwise the new one is removed and the old one put back in place.
+func (s *Server) finishDrain(a *app.App, h *drainHandoff) {
+	if h == nil {
+		return
+	}
+	ctx := context.Background()
+
+	if a.Status == app.StatusRunning && a.ContainerID != h.containerID {
+		// Shared domains route by port; the app's routes were updated by the deploy
+		s.applyDomainRoutesForApp(a)
+		go func() {
+			time.Sleep(h.period)
+			_ = s.podman.StopContainer(context.Background(), h.containerID, 10)
+			_ = s.podman.RemoveContainer(context.Background(), h.containerID, true)
+			log.Printf("Stopped drained container for %s", a.Name)
+		}()
+		return
+	}
+
+	containerName := "basepod-" + a.Name
+	if a.ContainerID != h.containerID && a.ContainerID != "" {
+		_ = s.podman.RemoveContainer(ctx, a.ContainerID, true)
+	}
+	_ = s.podman.RemoveContainer(ctx, containerName, true)
+	if err := s.podman.RenameContainer(ctx, h.containerID, containerName); err != nil {
+		log.Printf("Warning: failed to restore previous container for %s: %v", a.Name, err)
+		return
+	}
+	a.ContainerID = h.containerID
+	a.Ports.HostPort = h.hostPort
+	a.Image = h.image
+	a.Status = app.StatusRunning
+	a.UpdatedAt = time.Now()
+	s.storage.UpdateApp(a)
+	log.Printf("New container for %s failed; previous container kept serving", a.Name)
+}
+
+// removeDrainingContainers removes containers left draining when the server
+// stopped
+func (s *Server) removeDrainingContainers(ctx context.Context, apps []app.App) {
+	containers, err := s.podman.ListContainers(ctx, true)
+	if err != nil {
+		return
+	}
+	active := map[string]bool{}
+	draining := map[string]bool{}
+	for _, a := range apps {
+		active["basepod-"+a.Name] = true
+		draining[drainingContainerName(a.Name)] = true
+	}
+	for _, c := range containers {
+		for _, name := range c.Names {
+			if draining[name] && !active[name] {
+				log.Printf("Reconcile: removing leftover draining container %s", name)
+				_ = s.podman.RemoveContainer(ctx, c.ID, true)
+				break
+			}
+		}
+	}
+}
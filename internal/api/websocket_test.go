@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestValidateWebSocket(t *testing.T) {
+	t.Parallel()
+
+	container := &app.App{Type: app.AppTypeContainer}
+	static := &app.App{Type: app.AppTypeStatic}
+	cases := []struct {
+		name    string
+		a       *app.App
+		config  app.WebSocketConfig
+		wantErr bool
+	}{
+		{"defaults", container, app.WebSocketConfig{}, false},
+		{"idle and drain", container, app.WebSocketConfig{IdleTimeout: 300, DrainTimeout: 60}, false},
+		{"idle too short", container, app.WebSocketConfig{IdleTimeout: 5}, true},
+		{"idle too long", container, app.WebSocketConfig{IdleTimeout: app.MaxWebSocketIdleTimeout + 1}, true},
+		{"negative drain", container, app.WebSocketConfig{DrainTimeout: -1}, true},
+		{"drain too long", container, app.WebSocketConfig{DrainTimeout: app.MaxDrainTimeout + 1}, true},
+		{"static", static, app.WebSocketConfig{DrainTimeout: 60}, true},
+		{"static reset", static, app.WebSocketConfig{}, false},
+	}
+	for _, c := range cases {
+		if err := validateWebSocket(c.a, &c.config); (err != nil) != c.wantErr {
+			t.Fatalf("%s: validateWebSocket(%+v) error = %v, wantErr %v", c.name, c.config, err, c.wantErr)
+		}
+	}
+}
+
+func TestDrainPartnerPort(t *testing.T) {
+	t.Parallel()
+
+	for _, id := range []string{"a", "app-1", "0f8e2c1a-6d3b-4c55-9a7e-1b2c3d4e5f60"} {
+		base := assignHostPort(id)
+		partner := drainPartnerPort(id, base)
+		if partner == base || partner < 10000 || partner >= 60000 {
+			t.Fatalf("%s: partner of %d = %d, want another port in 10000-59999", id, base, partner)
+		}
+		if got := drainPartnerPort(id, partner); got != base {
+			t.Fatalf("%s: partner of %d = %d, want %d", id, partner, got, base)
+		}
+	}
+}
+
+func TestAppRouteStreams(t *testing.T) {
+	t.Parallel()
+
+	a := &app.App{}
+	if got := appRouteStreams(a); got.IdleTimeout != 0 || got.CloseDelay != 0 {
+		t.Fatalf("appRouteStreams(defaults) = %+v, want zero", got)
+	}
+	a.WebSocket = &app.WebSocketConfig{IdleTimeout: 120, DrainTimeout: 30}
+	got := appRouteStreams(a)
+	if got.IdleTimeout != 2*time.Minute || got.CloseDelay != 30*time.Second {
+		t.Fatalf("appRouteStreams = %+v, want 2m idle and 30s close delay", got)
+	}
+}
+
+func TestDeployStrategies(t *testing.T) {
+	ts := newTestServer(t)
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "strategy-web", Domain: "strategy-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	ts.waitForRoute("basepod-strategy-web")
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/deploy", app.DeployRequest{Image: "ghcr.io/example/web:1"}, &a); code != http.StatusOK {
+		t.Fatalf("first deploy: status %d", code)
+	}
+
+	// deploy releases image and returns the old and new containers and ports
+	deploy := func(image string, recreate *bool) (old, current string, oldPort, newPort int) {
+		t.Helper()
+		old, oldPort = ts.container("basepod-strategy-web").ID, a.Ports.HostPort
+		if code := ts.do("POST", "/api/apps/"+a.ID+"/deploy", app.DeployRequest{Image: image, Recreate: recreate}, &a); code != http.StatusOK {
+			t.Fatalf("deploy %s: status %d", image, code)
+		}
+		return old, a.ContainerID, oldPort, a.Ports.HostPort
+	}
+	routesTo := func(port int) bool {
+		route, _ := json.Marshal(ts.caddyRoute("basepod-strategy-web"))
+		return strings.Contains(string(route), fmt.Sprintf(":%d", port))
+	}
+
+	// Blue/green: the new container starts on the partner port next to the
+	// old one, which is stopped once routes point at the new one
+	old, current, oldPort, newPort := deploy("ghcr.io/example/web:2", nil)
+	if old == current || newPort != drainPartnerPort(a.ID, oldPort) || !routesTo(newPort) {
+		t.Fatalf("blue/green deploy: port %d -> %d, want the partner port routed", oldPort, newPort)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for ts.container(drainingContainerName("strategy-web")) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("old container was never stopped after the blue/green switch")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Recreate: the old container is removed first and the new one takes
+	// its port; the strategy sticks for later deploys
+	recreate := true
+	for _, d := range []struct {
+		image    string
+		recreate *bool
+	}{{"ghcr.io/example/web:3", &recreate}, {"ghcr.io/example/web:4", nil}} {
+		image := d.image
+		old, current, oldPort, newPort = deploy(image, d.recreate)
+		if old == current || newPort != oldPort || !routesTo(newPort) {
+			t.Fatalf("recreate deploy of %s: port %d -> %d, want the same port", image, oldPort, newPort)
+		}
+		if ts.container(drainingContainerName("strategy-web")) != nil {
+			t.Fatalf("recreate deploy of %s set the old container aside", image)
+		}
+		if stored, _ := ts.storage.GetApp(a.ID); !stored.Deployment.Recreate {
+			t.Fatalf("deploy of %s: want the recreate strategy saved", image)
+		}
+	}
+
+	recreate = false
+	if _, _, oldPort, newPort = deploy("ghcr.io/example/web:5", &recreate); newPort != drainPartnerPort(a.ID, oldPort) {
+		t.Fatalf("deploy back to blue/green: port %d -> %d, want the partner port", oldPort, newPort)
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
+
+func TestBlueGreenDeployWaitsForHealthCheck(t *testing.T) {
+	ts := newTestServer(t)
+	ts.runtime.HTTPStatus = func(image, path string) int {
+		if strings.HasSuffix(image, "web:broken") && path == "/healthz" {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	}
+
+	var a app.App
+	ts.do("POST", "/api/apps", app.CreateAppRequest{Name: "health-web", Domain: "health-web.test"}, &a)
+	ts.waitForStatus(a.ID, app.StatusRunning)
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/deploy", app.DeployRequest{Image: "ghcr.io/example/web:1"}, &a); code != http.StatusOK {
+		t.Fatalf("first deploy: status %d", code)
+	}
+	stored, _ := ts.storage.GetApp(a.ID)
+	stored.HealthCheck = &app.HealthCheckConfig{Endpoint: "/healthz"}
+	if err := ts.storage.UpdateApp(stored); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	old, oldPort := stored.ContainerID, stored.Ports.HostPort
+
+	// The new container accepts connections but fails its health check, so
+	// the old one keeps serving
+	if code := ts.do("POST", "/api/apps/"+a.ID+"/deploy", app.DeployRequest{Image: "ghcr.io/example/web:broken"}, nil); code != http.StatusBadGateway {
+		t.Fatalf("deploy failing its health check: status %d, want 502", code)
+	}
+	if c := ts.container("basepod-health-web"); c == nil || c.ID != old {
+		t.Fatalf("container after the failed deploy = %+v, want the old one %s back", c, old)
+	}
+	route, _ := json.Marshal(ts.caddyRoute("basepod-health-web"))
+	if !strings.Contains(string(route), fmt.Sprintf(":%d", oldPort)) {
+		t.Fatalf("route = %s, want it still on the old port %d", route, oldPort)
+	}
+	containers, _ := ts.runtime.ListContainers(context.Background(), true)
+	for _, c := range containers {
+		if strings.HasSuffix(c.Image, "web:broken") {
+			t.Fatalf("container %v from the broken image was kept", c.Names)
+		}
+	}
+
+	if code := ts.do("DELETE", "/api/apps/"+a.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+}
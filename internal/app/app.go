@@ -2,6 +2,13 @@
 package app
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -29,27 +36,80 @@ type App struct {
 	Env         map[string]string  `json:"env"`
 	Ports       PortConfig         `json:"ports"`
 	Volumes     []VolumeMount      `json:"volumes"`
+	Services    []Service          `json:"services,omitempty"` // Containers of a multi-service app, in start order
 	Resources   ResourceConfig     `json:"resources"`
 	Deployment  DeploymentConfig   `json:"deployment"`
 	Deployments []DeploymentRecord `json:"deployments,omitempty"` // Deployment history
 	SSL         SSLConfig          `json:"ssl"`
 	MLX          *MLXConfig          `json:"mlx,omitempty"`          // MLX LLM configuration
 	HealthCheck  *HealthCheckConfig  `json:"health_check,omitempty"` // Health check configuration
+	SEO          *SEOConfig          `json:"seo,omitempty"`          // Search engine controls (nil: defaults for the domain)
+	Privacy      *PrivacyConfig      `json:"privacy,omitempty"`      // Access log privacy (nil: server default)
+	WebSocket    *WebSocketConfig    `json:"websocket,omitempty"`    // Long-lived connection settings (nil: defaults)
+	SecurityHeaders *SecurityHeadersConfig `json:"security_headers,omitempty"` // Security response headers (nil: none added)
+	LastExit     *ContainerExit      `json:"last_exit,omitempty"`    // Why the container last stopped
+	Canary       *Canary             `json:"canary,omitempty"`       // New version taking part of the traffic (nil: none)
+	Routing      *RoutingConfig      `json:"routing,omitempty"`      // Extra proxy rules from basepod.yaml (nil: none)
+	BasicAuth    *BasicAuthConfig    `json:"basic_auth,omitempty"`   // Password protection of the app's domains (nil: public)
+	Static       *StaticConfig       `json:"static,omitempty"`       // How a static site's files are served (nil: defaults)
 	Health       *HealthStatus       `json:"health,omitempty"`       // Runtime health status (not persisted)
+	TLSScan      *TLSScan            `json:"tls_scan,omitempty"`     // Latest TLS scan of the domain (stored apart from the app)
+	Uptime       *UptimeSummary      `json:"uptime,omitempty"`       // Availability of the domain over the last 24h (not persisted)
+	Docs         *AppDocs            `json:"docs,omitempty"`         // README and manifest from the deployed source (stored apart from the app)
+	TemplateUpdate *TemplateUpdate   `json:"template_update,omitempty"` // Newer recommended version of the app's template (not persisted)
 	CreatedAt    time.Time           `json:"created_at"`
 	UpdatedAt    time.Time           `json:"updated_at"`
 }
 
 // DeploymentRecord represents a single deployment
 type DeploymentRecord struct {
-	ID         string    `json:"id"`
-	Image      string    `json:"image,omitempty"`       // Docker image used for this deploy
-	CommitHash string    `json:"commit_hash,omitempty"` // Git commit hash (short)
-	CommitMsg  string    `json:"commit_msg,omitempty"`  // Git commit message (first line)
-	Branch     string    `json:"branch,omitempty"`      // Git branch
-	Status     string    `json:"status"`                // success, failed, building
-	BuildLog   string    `json:"build_log,omitempty"`   // Build output log
-	DeployedAt time.Time `json:"deployed_at"`
+	ID          string           `json:"id"`
+	Image       string           `json:"image,omitempty"`        // Docker image used for this deploy
+	CommitHash  string           `json:"commit_hash,omitempty"`  // Git commit hash (short)
+	CommitMsg   string           `json:"commit_msg,omitempty"`   // Git commit message (first line)
+	Branch      string           `json:"branch,omitempty"`       // Git branch
+	Status      string           `json:"status"`                 // success, failed, building
+	BuildLog    string           `json:"build_log,omitempty"`    // Build output log
+	ImageReport *ImageReport     `json:"image_report,omitempty"` // Size of the image built for this deploy
+	Provenance  *BuildProvenance `json:"provenance,omitempty"`   // Inputs of the build, for audits
+	Verify      string           `json:"verify,omitempty"`       // Post-deploy check: "passed", or why it failed
+	SnapshotID  string           `json:"snapshot_id,omitempty"`  // Snapshot taken just before this deploy
+	// RegistryImage is the copy of Image pushed to the built-in registry,
+	// pulled back if the local image is pruned before a rollback
+	RegistryImage string    `json:"registry_image,omitempty"`
+	DeployedAt    time.Time `json:"deployed_at"`
+}
+
+// BuildProvenance records what went into a built image
+type BuildProvenance struct {
+	SourceSHA256     string            `json:"source_sha256,omitempty"`   // Uploaded source tarball
+	GitCommit        string            `json:"git_commit,omitempty"`      // Commit the source was taken from
+	Dockerfile       string            `json:"dockerfile"`                // Path relative to the source root
+	DockerfileSHA256 string            `json:"dockerfile_sha256"`         // Dockerfile contents
+	BaseImages       []BaseImage       `json:"base_images,omitempty"`     // FROM images and the digests they resolved to
+	BuildArgs        map[string]string `json:"build_args,omitempty"`      // --build-arg values
+	Lockfiles        map[string]string `json:"lockfiles,omitempty"`       // Lockfile path -> SHA-256
+	BuilderVersion   string            `json:"builder_version,omitempty"` // basepod version that ran the build
+	BuiltAt          time.Time         `json:"built_at"`
+}
+
+// BaseImage is an image referenced by a FROM instruction
+type BaseImage struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// ImageReport summarizes the size of a built image
+type ImageReport struct {
+	Size          int64        `json:"size"`                     // Total size in bytes
+	Layers        int          `json:"layers"`                   // Number of layers
+	LargestLayers []ImageLayer `json:"largest_layers,omitempty"` // Biggest layers, largest first
+}
+
+// ImageLayer is a layer of a built image
+type ImageLayer struct {
+	Size      int64  `json:"size"`
+	CreatedBy string `json:"created_by"` // Dockerfile instruction that created the layer
 }
 
 // MLXConfig holds MLX LLM configuration
@@ -71,6 +131,16 @@ type HealthCheckConfig struct {
 	AutoRestart bool   `json:"auto_restart"` // restart on failure (default: true)
 }
 
+// ContainerExit records how an app's container stopped, from Podman inspect
+type ContainerExit struct {
+	ExitCode   int       `json:"exit_code"`
+	OOMKilled  bool      `json:"oom_killed,omitempty"`
+	Error      string    `json:"error,omitempty"`       // Podman's own error, e.g. when the command can't start
+	FinishedAt time.Time `json:"finished_at"`
+	ClockDrift int       `json:"clock_drift,omitempty"` // Seconds Podman's clock is ahead of the host's
+	Reason     string    `json:"reason"`                // Short explanation of why it stopped
+}
+
 // HealthStatus holds runtime health check status (not persisted)
 type HealthStatus struct {
 	Status              string    `json:"status"`                         // "healthy", "unhealthy", "unknown"
@@ -82,6 +152,48 @@ type HealthStatus struct {
 	TotalFailures       int       `json:"total_failures"`
 }
 
+// AppDocs is the documentation shipped in an app's deployed source: its
+// README.md and app.manifest.yaml
+type AppDocs struct {
+	Readme         string    `json:"readme,omitempty"` // Markdown
+	Description    string    `json:"description,omitempty"`
+	Links          []AppLink `json:"links,omitempty"`
+	HealthEndpoint string    `json:"health_endpoint,omitempty"`
+	Env            []EnvDoc  `json:"env,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AppLink is a link listed in app.manifest.yaml, e.g. a repo or runbook
+type AppLink struct {
+	Title string `json:"title" yaml:"title"`
+	URL   string `json:"url" yaml:"url"`
+}
+
+// EnvDoc documents an environment variable the app reads
+type EnvDoc struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description"`
+	Required    bool   `json:"required,omitempty" yaml:"required"`
+	Default     string `json:"default,omitempty" yaml:"default"`
+}
+
+// TLSScan is the result of checking the TLS setup of an app's domain
+type TLSScan struct {
+	Domain      string    `json:"domain"`
+	Grade       string    `json:"grade"`                  // A+ to F, or "-" when the domain couldn't be reached
+	Protocols   []string  `json:"protocols"`              // TLS versions the server accepts
+	WeakCiphers []string  `json:"weak_ciphers,omitempty"` // Accepted cipher suites that are broken or lack forward secrecy
+	HSTS        bool      `json:"hsts"`
+	HSTSMaxAge  int64     `json:"hsts_max_age,omitempty"` // Seconds
+	ChainValid  bool      `json:"chain_valid"`            // Certificate chain verifies against the system roots
+	ChainError  string    `json:"chain_error,omitempty"`
+	CertIssuer  string    `json:"cert_issuer,omitempty"`
+	CertExpires time.Time `json:"cert_expires,omitempty"`
+	Issues      []string  `json:"issues,omitempty"` // What lowered the grade
+	Error       string    `json:"error,omitempty"`  // Why the scan failed
+	ScannedAt   time.Time `json:"scanned_at"`
+}
+
 // AppStatus represents the current status of an app
 type AppStatus string
 
@@ -98,34 +210,130 @@ const (
 type PortConfig struct {
 	ContainerPort  int    `json:"container_port"`  // Port the app listens on inside container
 	HostPort       int    `json:"host_port"`       // Port exposed on the host
-	Protocol       string `json:"protocol"`        // http, https, tcp
+	Protocol       string `json:"protocol"`        // How the edge proxy talks to the app: http, h2c or grpc
 	ExposeExternal bool   `json:"expose_external"` // Whether to expose port externally (default: false)
 }
 
+// Upstream protocols for PortConfig.Protocol
+const (
+	ProtocolHTTP = "http" // HTTP/1.1 (default)
+	ProtocolH2C  = "h2c"  // Cleartext HTTP/2
+	ProtocolGRPC = "grpc" // gRPC over cleartext HTTP/2, with streaming responses
+)
+
+// ValidProtocol reports whether p is an upstream protocol basepod can proxy to
+func ValidProtocol(p string) bool {
+	switch p {
+	case "", ProtocolHTTP, ProtocolH2C, ProtocolGRPC:
+		return true
+	}
+	return false
+}
+
+// Canary is a new version of an app running next to the current one and
+// getting Weight percent of its requests until it's promoted or aborted
+type Canary struct {
+	Image       string    `json:"image"`
+	Weight      int       `json:"weight"` // Percent of requests, 1-99
+	ContainerID string    `json:"container_id"`
+	HostPort    int       `json:"host_port"`
+	CommitHash  string    `json:"commit_hash,omitempty"` // Source deploys
+	CommitMsg   string    `json:"commit_msg,omitempty"`
+	Branch      string    `json:"branch,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// Service is one container of a multi-service app. An app's services share
+// a Podman pod, so they reach each other on localhost or by service name.
+type Service struct {
+	Name        string            `json:"name"`
+	Image       string            `json:"image"`
+	Port        int               `json:"port,omitempty"`      // Container port
+	HostPort    int               `json:"host_port,omitempty"` // Published port of routed services
+	Domain      string            `json:"domain,omitempty"`    // Routed to this service
+	Command     string            `json:"command,omitempty"`
+	Env         map[string]string `json:"env,omitempty"` // On top of the app's env
+	Volumes     []VolumeMount     `json:"volumes,omitempty"`
+	DependsOn   []string          `json:"depends_on,omitempty"`
+	Ready       *ServiceReady     `json:"ready,omitempty"` // Checked before dependents start
+	ContainerID string            `json:"container_id,omitempty"`
+}
+
+// ServiceReady is how a service shows the services that depend on it that
+// it is up: its HTTP path answers below 400, or else its port accepts
+// connections
+type ServiceReady struct {
+	HTTP    string `json:"http,omitempty"`    // e.g. "/health"
+	Timeout int    `json:"timeout,omitempty"` // Seconds (default: 60)
+}
+
 // VolumeMount represents a volume mount
 type VolumeMount struct {
 	Name          string `json:"name"`           // Volume name
 	HostPath      string `json:"host_path"`      // Path on host
 	ContainerPath string `json:"container_path"` // Path inside container
 	ReadOnly      bool   `json:"read_only"`
+	Size          string `json:"size,omitempty"` // Suggested size from the template, e.g., "10GB"
 }
 
 // ResourceConfig holds resource limits
 type ResourceConfig struct {
 	Memory   int64   `json:"memory"`    // Memory limit in MB
 	CPUs     float64 `json:"cpus"`      // CPU limit (e.g., 0.5 = half a core)
-	Replicas int     `json:"replicas"`  // Number of replicas (future: for scaling)
+	Replicas int     `json:"replicas"`  // Number of containers, load balanced by Caddy
 }
 
 // DeploymentConfig holds deployment settings
 type DeploymentConfig struct {
-	Source        DeploymentSource `json:"source"`
-	Dockerfile    string           `json:"dockerfile"`              // Path to Dockerfile (default: Dockerfile)
-	BuildContext  string           `json:"build_context"`           // Build context path (default: .)
-	Branch        string           `json:"branch"`                  // Git branch
-	AutoDeploy    bool             `json:"auto_deploy"`             // Deploy on git push
-	GitURL        string           `json:"git_url,omitempty"`       // Repository clone URL for webhooks
-	WebhookSecret string           `json:"webhook_secret,omitempty"` // HMAC secret for webhook validation
+	Source          DeploymentSource `json:"source"`
+	Dockerfile      string           `json:"dockerfile"`                 // Path to Dockerfile (default: Dockerfile)
+	BuildContext    string           `json:"build_context"`              // Build context path (default: .)
+	Branch          string           `json:"branch"`                     // Git branch
+	AutoDeploy      bool             `json:"auto_deploy"`                // Deploy on git push
+	AutoUpdate      bool             `json:"auto_update,omitempty"`      // Pull newer image and redeploy during the maintenance window
+	Recreate        bool             `json:"recreate,omitempty"`         // Stop the old container before starting the new one instead of a blue/green switch
+	GitURL          string           `json:"git_url,omitempty"`          // Repository clone URL for webhooks
+	WebhookSecret   string           `json:"webhook_secret,omitempty"`   // HMAC secret for webhook validation
+	Template        string           `json:"template,omitempty"`         // Template the app was installed from
+	TemplateVersion string           `json:"template_version,omitempty"` // Template version it runs
+	Snapshot        *SnapshotConfig  `json:"snapshot,omitempty"`         // Snapshot volumes before each deploy
+}
+
+// SnapshotConfig turns on safety snapshots of an app's volumes taken before
+// a deploy replaces its container
+type SnapshotConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Keep        int    `json:"keep,omitempty"`         // Snapshots kept (default 3)
+	DumpCommand string `json:"dump_command,omitempty"` // Run in the old container; its output is saved, e.g. "pg_dump -U postgres app"
+}
+
+// Snapshot is a copy of an app's volumes, and optionally a database dump,
+// taken before a deploy or by hand
+type Snapshot struct {
+	ID        string    `json:"id"`
+	AppID     string    `json:"app_id"`
+	Image     string    `json:"image,omitempty"` // Release that was running
+	Volumes   []string  `json:"volumes"`         // App volume names
+	Dump      bool      `json:"dump,omitempty"`  // A dump_command output was saved
+	Size      int64     `json:"size"`
+	Trigger   string    `json:"trigger"` // "deploy" or "manual"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TemplateUpdate is a newer recommended version of the template an app was
+// installed from
+type TemplateUpdate struct {
+	Template       string         `json:"template"`
+	CurrentVersion string         `json:"current_version"`
+	Version        string         `json:"version"` // Recommended version
+	Image          string         `json:"image"`   // Image the upgrade switches to
+	Notes          []TemplateNote `json:"notes,omitempty"`
+}
+
+// TemplateNote is a migration note to read before upgrading past a version
+type TemplateNote struct {
+	Version string `json:"version"`
+	Text    string `json:"text"`
 }
 
 // WebhookDelivery represents a single webhook delivery from GitHub
@@ -159,6 +367,305 @@ type SSLConfig struct {
 	Key         string `json:"key,omitempty"`         // Path or empty for auto
 }
 
+// SEOConfig controls how search engines treat an app. Unset fields fall back
+// to hiding preview and staging domains and leaving production alone.
+type SEOConfig struct {
+	NoIndex   *bool  `json:"noindex,omitempty"`    // Send X-Robots-Tag: noindex, nofollow
+	RobotsTxt string `json:"robots_txt,omitempty"` // "app", "disallow" or the robots.txt contents to serve
+}
+
+// Robots.txt modes for SEOConfig.RobotsTxt
+const (
+	RobotsApp      = "app"      // Pass /robots.txt through to the app
+	RobotsDisallow = "disallow" // Serve a robots.txt that blocks all crawlers
+)
+
+// DisallowAllRobots is the managed robots.txt served for RobotsDisallow
+const DisallowAllRobots = "User-agent: *\nDisallow: /\n"
+
+// IsPreviewDomain reports whether domain looks like a staging or preview
+// environment, e.g. staging.example.com, myapp-preview.example.com or
+// myapp-pr-42.example.com
+func IsPreviewDomain(domain string) bool {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	if len(labels) > 2 {
+		labels = labels[:len(labels)-2] // Ignore the registered domain itself
+	} else {
+		labels = labels[:1]
+	}
+	for _, label := range labels {
+		parts := strings.Split(label, "-")
+		for i, part := range parts {
+			if part == "staging" || part == "preview" {
+				return true
+			}
+			if part == "pr" && i+1 < len(parts) && strings.Trim(parts[i+1], "0123456789") == "" && parts[i+1] != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SearchPolicy returns whether responses for domain should carry
+// X-Robots-Tag: noindex and the robots.txt to serve instead of the app's
+// own (empty to pass it through)
+func (a *App) SearchPolicy(domain string) (noindex bool, robotsTxt string) {
+	preview := IsPreviewDomain(domain)
+	noindex = preview
+	robots := ""
+	if preview {
+		robots = RobotsDisallow
+	}
+	if a.SEO != nil {
+		if a.SEO.NoIndex != nil {
+			noindex = *a.SEO.NoIndex
+		}
+		if a.SEO.RobotsTxt != "" {
+			robots = a.SEO.RobotsTxt
+		}
+	}
+	switch robots {
+	case "", RobotsApp:
+		return noindex, ""
+	case RobotsDisallow:
+		return noindex, DisallowAllRobots
+	}
+	return noindex, robots
+}
+
+// PrivacyConfig controls what access logs keep about an app's visitors
+type PrivacyConfig struct {
+	AnonymizeIPs *bool `json:"anonymize_ips,omitempty"` // Mask client IPs (IPv4 /24, IPv6 /48); unset follows the server setting
+}
+
+// AnonymizesIPs reports whether client IPs in the app's access logs are masked,
+// given the server-wide setting
+func (a *App) AnonymizesIPs(serverDefault bool) bool {
+	if a.Privacy != nil && a.Privacy.AnonymizeIPs != nil {
+		return *a.Privacy.AnonymizeIPs
+	}
+	return serverDefault
+}
+
+// WebSocketConfig tunes long-lived connections (websockets, server-sent
+// events) for realtime apps
+type WebSocketConfig struct {
+	IdleTimeout  int `json:"idle_timeout,omitempty"`  // Seconds without data from the app before a connection is closed (0: no limit)
+	DrainTimeout int `json:"drain_timeout,omitempty"` // Seconds the old container keeps its open connections after a deploy (0: stop it right away)
+}
+
+// Limits for WebSocketConfig values, in seconds
+const (
+	MinWebSocketIdleTimeout = 10
+	MaxWebSocketIdleTimeout = 24 * 60 * 60
+	MaxDrainTimeout         = 60 * 60
+)
+
+// StreamIdleTimeout is how long a streaming connection may go without data
+// from the app; zero means no limit
+func (a *App) StreamIdleTimeout() time.Duration {
+	if a.WebSocket == nil {
+		return 0
+	}
+	return time.Duration(a.WebSocket.IdleTimeout) * time.Second
+}
+
+// DrainPeriod is how long the previous container keeps serving the
+// connections it has after a deploy; zero stops it before the new one starts
+func (a *App) DrainPeriod() time.Duration {
+	if a.WebSocket == nil {
+		return 0
+	}
+	return time.Duration(a.WebSocket.DrainTimeout) * time.Second
+}
+
+// BasicAuthConfig puts a username and password in front of all of an app's
+// domains, e.g. to keep a staging site private
+type BasicAuthConfig struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash,omitempty"` // bcrypt; left out of API responses
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ProtectRequest turns on basic auth for an app
+type ProtectRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RoutingConfig is extra routing an app declares under routing: in its
+// basepod.yaml, run by Caddy before requests reach the app. Paths are Caddy
+// path matchers like "/admin/*"; an empty path matches every request.
+type RoutingConfig struct {
+	Redirects   []RedirectRule  `json:"redirects,omitempty" yaml:"redirects"`
+	Headers     []HeaderRule    `json:"headers,omitempty" yaml:"headers"`
+	BasicAuth   []BasicAuthRule `json:"basic_auth,omitempty" yaml:"basic_auth"`
+	IPAllowlist []IPAllowRule   `json:"ip_allowlist,omitempty" yaml:"ip_allowlist"`
+	Gzip        bool            `json:"gzip,omitempty" yaml:"gzip"` // Compress responses (static sites always are)
+}
+
+// StaticConfig is how a static site's files are served, from the static:
+// block of its basepod.yaml plus the site's _redirects and _headers files
+type StaticConfig struct {
+	SPA         *bool          `json:"spa,omitempty" yaml:"spa"`             // Serve /index.html for unknown paths (default: on unless not_found is set)
+	NotFound    string         `json:"not_found,omitempty" yaml:"not_found"` // Page served with a 404 for unknown paths, e.g. "/404.html"
+	Redirects   []RedirectRule `json:"redirects,omitempty" yaml:"redirects"`
+	Headers     []HeaderRule   `json:"headers,omitempty" yaml:"headers"`         // e.g. Cache-Control for "/assets/*"
+	Precompress *bool          `json:"precompress,omitempty" yaml:"precompress"` // Write .br and .gz copies of text assets on deploy (default: on)
+}
+
+// SPAFallback reports whether unknown paths get /index.html, for client-side
+// routing. Sites default to it unless they have a 404 page.
+func (c *StaticConfig) SPAFallback() bool {
+	if c == nil {
+		return true
+	}
+	if c.SPA != nil {
+		return *c.SPA
+	}
+	return c.NotFound == ""
+}
+
+// Precompresses reports whether deploys write .br and .gz copies of the
+// site's text assets for Caddy to serve as they are
+func (c *StaticConfig) Precompresses() bool {
+	return c == nil || c.Precompress == nil || *c.Precompress
+}
+
+// RedirectRule answers requests for a path with a redirect
+type RedirectRule struct {
+	From   string `json:"from" yaml:"from"`
+	To     string `json:"to" yaml:"to"`
+	Status int    `json:"status,omitempty" yaml:"status"` // 301 (default), 302, 303, 307 or 308
+}
+
+// HeaderRule sets response headers on requests for a path
+type HeaderRule struct {
+	Path string            `json:"path,omitempty" yaml:"path"`
+	Set  map[string]string `json:"set" yaml:"set"`
+}
+
+// BasicAuthRule asks for a username and password on requests for a path.
+// A plain password is hashed when the config is saved and never stored.
+type BasicAuthRule struct {
+	Path         string `json:"path,omitempty" yaml:"path"`
+	Username     string `json:"username" yaml:"username"`
+	Password     string `json:"password,omitempty" yaml:"password"`
+	PasswordHash string `json:"password_hash,omitempty" yaml:"password_hash"` // bcrypt; left out of API responses
+}
+
+// IPAllowRule only lets requests for a path from the listed addresses or
+// CIDR ranges through
+type IPAllowRule struct {
+	Path string   `json:"path,omitempty" yaml:"path"`
+	IPs  []string `json:"ips" yaml:"ips"`
+}
+
+// SecurityHeadersConfig picks the security headers Caddy adds to an app's
+// responses: a managed preset, its Content-Security-Policy and per-header
+// overrides
+type SecurityHeadersConfig struct {
+	Preset    string            `json:"preset"`              // "strict", "balanced" or "off"
+	CSP       string            `json:"csp,omitempty"`       // Content-Security-Policy; replaces the preset's
+	Overrides map[string]string `json:"overrides,omitempty"` // Header values replacing the preset's; "" removes the header
+}
+
+// Security header presets for SecurityHeadersConfig.Preset
+const (
+	SecurityPresetStrict   = "strict"   // Long HSTS with preload, no framing, a locked-down default CSP
+	SecurityPresetBalanced = "balanced" // HSTS, nosniff and a referrer policy that don't break typical apps
+	SecurityPresetOff      = "off"      // Only the overrides
+)
+
+// securityPresets are the headers each preset sends
+var securityPresets = map[string]map[string]string{
+	SecurityPresetStrict: {
+		"Strict-Transport-Security":  "max-age=63072000; includeSubDomains; preload",
+		"X-Content-Type-Options":     "nosniff",
+		"Referrer-Policy":            "no-referrer",
+		"X-Frame-Options":            "DENY",
+		"Content-Security-Policy":    "default-src 'self'; object-src 'none'; frame-ancestors 'none'; base-uri 'self'",
+		"Permissions-Policy":         "camera=(), microphone=(), geolocation=()",
+		"Cross-Origin-Opener-Policy": "same-origin",
+	},
+	SecurityPresetBalanced: {
+		"Strict-Transport-Security": "max-age=31536000",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "SAMEORIGIN",
+	},
+	SecurityPresetOff: {},
+}
+
+// IsSecurityPreset reports whether preset is a known security header preset
+func IsSecurityPreset(preset string) bool {
+	_, ok := securityPresets[preset]
+	return ok
+}
+
+// ResponseHeaders returns the security headers Caddy sets on the app's responses:
+// the preset's, then the CSP, then the overrides. Header names are
+// canonicalized.
+func (a *App) ResponseHeaders() map[string]string {
+	headers := map[string]string{}
+	if a.SecurityHeaders == nil {
+		return headers
+	}
+	for name, value := range securityPresets[a.SecurityHeaders.Preset] {
+		headers[name] = value
+	}
+	if a.SecurityHeaders.CSP != "" {
+		headers["Content-Security-Policy"] = a.SecurityHeaders.CSP
+	}
+	for name, value := range a.SecurityHeaders.Overrides {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		if value == "" {
+			delete(headers, name)
+		} else {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// MemoryBytes is the memory limit as Podman takes it; zero means no limit
+func (r ResourceConfig) MemoryBytes() int64 {
+	return r.Memory * 1024 * 1024
+}
+
+// ParseMemory parses a memory limit like "512m", "1.5g" or "256" (MB) into
+// MB. "0" and "" mean no limit.
+func ParseMemory(in string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(in))
+	s = strings.TrimSuffix(s, "b")
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "g"):
+		mult, s = 1024, strings.TrimSuffix(s, "g")
+	case strings.HasSuffix(s, "m"):
+		s = strings.TrimSuffix(s, "m")
+	case strings.HasSuffix(s, "k"):
+		mult, s = 1.0/1024, strings.TrimSuffix(s, "k")
+	}
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory %q (expected e.g. 512m or 1g)", in)
+	}
+	return int64(n*mult + 0.5), nil
+}
+
+// FormatMemory renders a memory limit in MB like "512m" or "2g"
+func FormatMemory(mb int64) string {
+	if mb > 0 && mb%1024 == 0 {
+		return fmt.Sprintf("%dg", mb/1024)
+	}
+	return fmt.Sprintf("%dm", mb)
+}
+
 // CreateAppRequest represents a request to create a new app
 type CreateAppRequest struct {
 	Name      string            `json:"name"`
@@ -174,6 +681,23 @@ type CreateAppRequest struct {
 	Volumes   []VolumeMount     `json:"volumes,omitempty"` // Custom volume mounts
 }
 
+// CloneAppRequest represents a request to copy an app under a new name
+type CloneAppRequest struct {
+	Name           string `json:"name"`
+	Domain         string `json:"domain,omitempty"` // Auto-generated if empty
+	IncludeSecrets bool   `json:"include_secrets"`  // Also copy secrets and secret-looking env vars
+	CopyVolumes    bool   `json:"copy_volumes"`     // Copy volume data instead of starting empty
+	NoDeploy       bool   `json:"no_deploy"`        // Create the copy without starting it
+}
+
+// CanaryRequest starts a canary of an app or changes its share of traffic
+type CanaryRequest struct {
+	Image  string `json:"image,omitempty"`  // Required to start a canary
+	Weight int    `json:"weight,omitempty"` // Percent of requests, 1-99 (default: 10)
+	// Why this canary starts during a deploy freeze
+	FreezeOverride string `json:"freeze_override,omitempty"`
+}
+
 // UpdateAppRequest represents a request to update an app
 type UpdateAppRequest struct {
 	Name           *string            `json:"name,omitempty"`
@@ -183,13 +707,19 @@ type UpdateAppRequest struct {
 	Image          *string            `json:"image,omitempty"`
 	Env            *map[string]string `json:"env,omitempty"`
 	Port           *int               `json:"port,omitempty"`
+	Protocol       *string            `json:"protocol,omitempty"` // Upstream protocol: http, h2c or grpc
 	Memory         *int64             `json:"memory,omitempty"`
 	CPUs           *float64           `json:"cpus,omitempty"`
+	Replicas       *int               `json:"replicas,omitempty"`
 	EnableSSL      *bool              `json:"enable_ssl,omitempty"`
 	ExposeExternal *bool               `json:"expose_external,omitempty"`
 	Volumes        *[]VolumeMount      `json:"volumes,omitempty"`
 	HealthCheck    *HealthCheckConfig   `json:"health_check,omitempty"`
 	Deployment     *DeploymentConfig    `json:"deployment,omitempty"`
+	SEO            *SEOConfig           `json:"seo,omitempty"`
+	Privacy        *PrivacyConfig       `json:"privacy,omitempty"`
+	WebSocket      *WebSocketConfig     `json:"websocket,omitempty"`
+	SecurityHeaders *SecurityHeadersConfig `json:"security_headers,omitempty"`
 }
 
 // DeployRequest represents a request to deploy an app
@@ -205,6 +735,42 @@ type DeployRequest struct {
 	Dockerfile   string            `json:"dockerfile,omitempty"`
 	BuildContext string            `json:"build_context,omitempty"`
 	BuildArgs    map[string]string `json:"build_args,omitempty"`
+
+	// Why this deploy goes ahead during a deploy freeze
+	FreezeOverride string `json:"freeze_override,omitempty"`
+
+	// Stop the old container before starting the new one instead of a
+	// blue/green switch; saved as the app's deploy strategy when set
+	Recreate *bool `json:"recreate,omitempty"`
+}
+
+// SourceFile is an entry of a delta upload's manifest. The content of a
+// file is in the server's blob store under its SHA256, sent beforehand.
+type SourceFile struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type"` // file, dir or symlink
+	Mode    int64     `json:"mode"`
+	Size    int64     `json:"size,omitempty"`
+	SHA256  string    `json:"sha256,omitempty"` // Files only
+	Link    string    `json:"link,omitempty"`   // Symlink target
+	ModTime time.Time `json:"mtime"`
+}
+
+// Source file types
+const (
+	SourceFileRegular = "file"
+	SourceFileDir     = "dir"
+	SourceFileSymlink = "symlink"
+)
+
+// BlobCheckRequest asks which file contents the server doesn't have yet
+type BlobCheckRequest struct {
+	Hashes []string `json:"hashes"` // SHA256 of each file, hex
+}
+
+// BlobCheckResponse lists the contents a delta upload has to send
+type BlobCheckResponse struct {
+	Missing []string `json:"missing"`
 }
 
 // CronJob represents a scheduled task for an app
@@ -212,8 +778,10 @@ type CronJob struct {
 	ID         string     `json:"id"`
 	AppID      string     `json:"app_id"`
 	Name       string     `json:"name"`
-	Schedule   string     `json:"schedule"`            // cron expression: "0 2 * * *"
+	Schedule   string     `json:"schedule"`            // cron expression: "0 2 * * *" or "@weekly"
+	Action     string     `json:"action"`              // command (default), run, restart, or redeploy
 	Command    string     `json:"command"`              // shell command to run in container
+	Source     string     `json:"source,omitempty"`    // "config" for jobs defined in basepod.yaml
 	Enabled    bool       `json:"enabled"`
 	LastRun    *time.Time `json:"last_run,omitempty"`
 	LastStatus string     `json:"last_status,omitempty"` // "success", "failed", "running"
@@ -223,6 +791,37 @@ type CronJob struct {
 	UpdatedAt  time.Time  `json:"updated_at"`
 }
 
+// Cron job actions
+const (
+	CronActionCommand  = "command"  // Run Command inside the app container
+	CronActionRun      = "run"      // Run Command in a one-off container from the app image
+	CronActionRestart  = "restart"  // Recreate the app container
+	CronActionRedeploy = "redeploy" // Pull the app image and recreate the container
+)
+
+// CronSourceConfig marks jobs defined in an app's basepod.yaml, which each
+// deploy replaces
+const CronSourceConfig = "config"
+
+// JobConfig is a job in the jobs: section of basepod.yaml, run on schedule
+// in a one-off container from the app image
+type JobConfig struct {
+	Schedule string `json:"schedule"` // cron expression: "0 2 * * *" or "@daily"
+	Command  string `json:"command"`
+}
+
+// VerifyConfig is the verify: section of basepod.yaml, a smoke check of
+// each new release before the deploy succeeds. The HTTP check, the command
+// or both must pass.
+type VerifyConfig struct {
+	Path     string `json:"path,omitempty"`     // HTTP path requested from the new container, e.g. "/healthz"
+	Status   int    `json:"status,omitempty"`   // Expected response status (default: 200)
+	Body     string `json:"body,omitempty"`     // Text the response body must contain
+	Command  string `json:"command,omitempty"`  // Run with /bin/sh -c in a one-off container from the new image; must exit 0
+	Timeout  int    `json:"timeout,omitempty"`  // Seconds the check may take (default: 60)
+	Rollback bool   `json:"rollback,omitempty"` // Roll back to the previous release when the check fails
+}
+
 // CronExecution records a single cron job run
 type CronExecution struct {
 	ID        string     `json:"id"`
@@ -234,6 +833,41 @@ type CronExecution struct {
 	ExitCode  int        `json:"exit_code,omitempty"`
 }
 
+// Addon is a managed backing service (Postgres, MySQL or Redis). It runs
+// as its own app, and its connection URL is set in the env of the apps it's
+// attached to.
+type Addon struct {
+	ID          string            `json:"id"` // The backing app's ID
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`             // postgres, mysql or redis
+	Status      AppStatus         `json:"status,omitempty"` // The backing app's status
+	Attachments []AddonAttachment `json:"attachments"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// AddonAttachment is an app that gets an addon's connection URL in EnvVar
+type AddonAttachment struct {
+	AddonID   string    `json:"addon_id"`
+	AppID     string    `json:"app_id"`
+	AppName   string    `json:"app_name,omitempty"`
+	EnvVar    string    `json:"env_var"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskRun is a one-off command run with bp run-task in a throwaway
+// container from the app's image
+type TaskRun struct {
+	ID        string     `json:"id"`
+	AppID     string     `json:"app_id"`
+	Command   []string   `json:"command"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Status    string     `json:"status"` // "running", "success" or "failed"
+	ExitCode  int        `json:"exit_code"`
+	Output    string     `json:"output,omitempty"` // Tail of the output
+	Error     string     `json:"error,omitempty"`  // Why the task couldn't run or finish
+}
+
 // ActivityLog represents an activity/audit log entry
 type ActivityLog struct {
 	ID         string    `json:"id"`
@@ -259,7 +893,7 @@ type NotificationConfig struct {
 	WebhookURL      string   `json:"webhook_url,omitempty"`
 	SlackWebhookURL string   `json:"slack_webhook_url,omitempty"`
 	DiscordWebhook  string   `json:"discord_webhook_url,omitempty"`
-	Events          []string `json:"events"` // ["deploy_success", "deploy_failed", "health_check_fail"]
+	Events          []string `json:"events"` // ["deploy_success", "deploy_failed", "health_check_fail", "container_exit", "tls_grade_dropped", "template_update", "uptime_down", "uptime_up"]
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -268,9 +902,10 @@ type NotificationConfig struct {
 type DeployToken struct {
 	ID         string     `json:"id"`
 	Name       string     `json:"name"`
-	TokenHash  string     `json:"-"`                      // Never expose
-	Prefix     string     `json:"prefix"`                 // First 8 chars for identification
-	Scopes     []string   `json:"scopes"`                 // ["deploy:*", "deploy:app-123", "status"]
+	TokenHash  string     `json:"-"`                    // Never expose
+	Prefix     string     `json:"prefix"`               // First 8 chars for identification
+	Scopes     []string   `json:"scopes"`               // ["deploy:*", "deploy:app-123", "status"]
+	CreatedBy  string     `json:"created_by,omitempty"` // User who created it; empty for admins
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
 	CreatedAt  time.Time  `json:"created_at"`
 	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
@@ -278,14 +913,148 @@ type DeployToken struct {
 
 // AppMetric represents a point-in-time resource usage metric for an app
 type AppMetric struct {
+	ID          int64     `json:"id"`
+	AppID       string    `json:"app_id"`
+	CPUPercent  float64   `json:"cpu_percent"`
+	MemUsage    int64     `json:"mem_usage"`
+	MemLimit    int64     `json:"mem_limit"`
+	NetInput    int64     `json:"net_input"`
+	NetOutput   int64     `json:"net_output"`
+	BlockInput  int64     `json:"block_input"`  // Bytes read from disk
+	BlockOutput int64     `json:"block_output"` // Bytes written to disk
+	Requests    int64     `json:"requests"`     // Requests served through the app's domains since the previous point
+	Errors      int64     `json:"errors"`       // Of those, responses with a 5xx status
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// UptimeCheck is one probe of an app's public URL
+type UptimeCheck struct {
 	ID         int64     `json:"id"`
 	AppID      string    `json:"app_id"`
-	CPUPercent float64   `json:"cpu_percent"`
-	MemUsage   int64     `json:"mem_usage"`
-	MemLimit   int64     `json:"mem_limit"`
-	NetInput   int64     `json:"net_input"`
-	NetOutput  int64     `json:"net_output"`
-	RecordedAt time.Time `json:"recorded_at"`
+	URL        string    `json:"url"`
+	Up         bool      `json:"up"`
+	StatusCode int       `json:"status_code,omitempty"`
+	ResponseMs int64     `json:"response_ms"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// UptimeSummary sums up an app's uptime checks over a period
+type UptimeSummary struct {
+	Status        string     `json:"status"`       // "up", "down" or "unknown" (no checks yet)
+	Availability  float64    `json:"availability"` // Percent of checks that were up
+	Checks        int        `json:"checks"`
+	Failures      int        `json:"failures"`
+	AvgResponseMs float64    `json:"avg_response_ms"` // Of the checks that were up
+	LastCheck     *time.Time `json:"last_check,omitempty"`
+	DownSince     *time.Time `json:"down_since,omitempty"`
+}
+
+// DeployMarker records the moment a release went live, for overlaying on logs and metrics
+type DeployMarker struct {
+	ID         string    `json:"id"`
+	AppID      string    `json:"app_id"`
+	AppName    string    `json:"app_name"`
+	ReleaseID  string    `json:"release_id"` // DeploymentRecord ID
+	Kind       string    `json:"kind"`       // "deploy" or "rollback"
+	Image      string    `json:"image,omitempty"`
+	CommitHash string    `json:"commit_hash,omitempty"`
+	CommitMsg  string    `json:"commit_msg,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DomainRedirect keeps an app's old domain answering with a 301 to its new one
+// after a domain migration
+type DomainRedirect struct {
+	ID         string     `json:"id"`
+	AppID      string     `json:"app_id"`
+	FromDomain string     `json:"from_domain"`
+	ToDomain   string     `json:"to_domain"`
+	Hits       int64      `json:"hits"`                  // Requests still arriving on the old domain
+	LastHitAt  *time.Time `json:"last_hit_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// DNSRecord is an address record basepod created for an app's domain with
+// the configured DNS provider
+type DNSRecord struct {
+	Domain    string    `json:"domain"`
+	Type      string    `json:"type"` // A or AAAA
+	Value     string    `json:"value"`
+	TTL       int       `json:"ttl"`
+	AppID     string    `json:"app_id"`
+	Provider  string    `json:"provider"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FreezeWindow is a period when deploys are refused unless they're
+// overridden with a reason. A window is weekly (days and hours in the
+// server's time zone) or dated (from and until, inclusive; dates without a
+// year recur every year).
+type FreezeWindow struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Days      []string  `json:"days,omitempty"`  // Weekly: "mon".."sun"; empty is every day
+	Start     string    `json:"start,omitempty"` // Weekly: "16:00" (default 00:00)
+	End       string    `json:"end,omitempty"`   // Weekly: "23:00" (default end of day); before start runs past midnight
+	From      string    `json:"from,omitempty"`  // Dated: "2026-12-24" or "12-24"
+	Until     string    `json:"until,omitempty"` // Dated: "2027-01-02" or "01-02"
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DomainRoute sends requests on a domain that match a path prefix, and
+// optionally a header, to an app, so several apps can share one domain
+type DomainRoute struct {
+	ID          string    `json:"id"`
+	Domain      string    `json:"domain"`
+	PathPrefix  string    `json:"path_prefix"`            // e.g. /api; "/" matches every path
+	HeaderName  string    `json:"header_name,omitempty"`  // Only match requests carrying this header
+	HeaderValue string    `json:"header_value,omitempty"` // ...with this value (empty: any value)
+	AppID       string    `json:"app_id"`
+	AppName     string    `json:"app_name,omitempty"` // Filled in for API responses
+	StripPrefix bool      `json:"strip_prefix"`       // Remove the prefix before proxying
+	Priority    int       `json:"priority"`           // Higher is tried first; ties go to the longer prefix
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SecretInfo describes an app secret. Values are write-only: they are
+// injected into the app's containers but never returned by the API.
+type SecretInfo struct {
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsSecretKey guesses whether an env var holds a secret
+func IsSecretKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range []string{"PASSWORD", "PASS", "SECRET", "TOKEN", "KEY", "PRIVATE", "CREDENTIAL", "DATABASE_URL", "DSN"} {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestSubscription is a recipient of the weekly email digest
+type DigestSubscription struct {
+	Email     string    `json:"email"`
+	UserID    string    `json:"user_id,omitempty"`
+	Token     string    `json:"-"` // Secret in the unsubscribe link
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AIDocument is a chunk of app logs or notes indexed for semantic search
+type AIDocument struct {
+	ID        string    `json:"id"`
+	AppID     string    `json:"app_id,omitempty"`
+	Source    string    `json:"source"` // "log" or "note"
+	Title     string    `json:"title,omitempty"`
+	Content   string    `json:"content"`
+	Embedding []float32 `json:"-"`
+	Timestamp time.Time `json:"timestamp"` // First log line time, or when the note was added
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // User represents a system user
@@ -299,6 +1068,18 @@ type User struct {
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
 }
 
+// TwoFactor is an account's TOTP two-factor auth. It is pending until the
+// first code is verified, and only then required at login.
+type TwoFactor struct {
+	AccountID     string     `json:"-"` // user ID, or "admin" for the server password
+	Secret        string     `json:"-"`
+	RecoveryCodes []string   `json:"-"` // SHA-256 hashes of the unused codes
+	Enabled       bool       `json:"enabled"`
+	LastStep      int64      `json:"-"` // TOTP step of the last accepted code
+	CreatedAt     time.Time  `json:"created_at"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty"`
+}
+
 // AppListResponse represents a list of apps
 type AppListResponse struct {
 	Apps  []App `json:"apps"`
@@ -311,3 +1092,11 @@ type AppLog struct {
 	Stream    string    `json:"stream"` // stdout, stderr
 	Message   string    `json:"message"`
 }
+
+// CertPin is the SHA256 of a certificate's public key. The server logs it for
+// a self-signed API certificate, and `bp login` saves it to trust that
+// certificate.
+func CertPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
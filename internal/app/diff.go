@@ -0,0 +1,153 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Unset stands for an env var one side of a ConfigChange doesn't have
+const Unset = "<unset>"
+
+// ConfigChange is one difference in an app's config between two servers
+type ConfigChange struct {
+	Section string // image, env, resources, domains
+	Field   string
+	From    string
+	To      string
+	Secret  bool
+}
+
+// Values returns the change's old and new values, masked for secrets unless
+// showSecrets is set
+func (c ConfigChange) Values(showSecrets bool) (string, string) {
+	if c.Secret && !showSecrets {
+		return MaskSecret(c.From), MaskSecret(c.To)
+	}
+	return c.From, c.To
+}
+
+// DiffApps lists config differences going from a to b
+func DiffApps(a, b App) []ConfigChange {
+	var changes []ConfigChange
+	add := func(section, field, from, to string) {
+		if from != to {
+			changes = append(changes, ConfigChange{Section: section, Field: field, From: from, To: to})
+		}
+	}
+
+	add("image", "image", a.Image, b.Image)
+
+	keys := make(map[string]bool)
+	for k := range a.Env {
+		keys[k] = true
+	}
+	for k := range b.Env {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		from, inA := a.Env[k]
+		to, inB := b.Env[k]
+		if inA == inB && from == to {
+			continue
+		}
+		if !inA {
+			from = Unset
+		}
+		if !inB {
+			to = Unset
+		}
+		changes = append(changes, ConfigChange{Section: "env", Field: k, From: from, To: to, Secret: IsSecretKey(k)})
+	}
+
+	add("resources", "memory", fmt.Sprintf("%dMB", a.Resources.Memory), fmt.Sprintf("%dMB", b.Resources.Memory))
+	add("resources", "cpus", fmt.Sprintf("%g", a.Resources.CPUs), fmt.Sprintf("%g", b.Resources.CPUs))
+	add("resources", "port", fmt.Sprintf("%d", a.Ports.ContainerPort), fmt.Sprintf("%d", b.Ports.ContainerPort))
+
+	add("domains", "domain", a.Domain, b.Domain)
+	add("domains", "aliases", strings.Join(a.Aliases, ","), strings.Join(b.Aliases, ","))
+
+	return changes
+}
+
+// SelectChanges keeps changes in the given sections, and only envKeys if set
+func SelectChanges(changes []ConfigChange, sections, envKeys []string) []ConfigChange {
+	wantSection := make(map[string]bool)
+	for _, s := range sections {
+		wantSection[strings.TrimSpace(s)] = true
+	}
+	wantKey := make(map[string]bool)
+	for _, k := range envKeys {
+		wantKey[strings.TrimSpace(k)] = true
+	}
+
+	var selected []ConfigChange
+	for _, c := range changes {
+		if !wantSection[c.Section] && !(c.Section == "env" && len(wantKey) > 0) {
+			continue
+		}
+		if c.Section == "env" && len(wantKey) > 0 && !wantKey[c.Field] {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// PromotionUpdate turns selected changes into an update request that sets
+// the target app's fields to the source app's values
+func PromotionUpdate(changes []ConfigChange, from, to App) map[string]interface{} {
+	update := make(map[string]interface{})
+	var env map[string]string
+	for _, c := range changes {
+		switch c.Field {
+		case "image":
+			update["image"] = from.Image
+		case "memory":
+			update["memory"] = from.Resources.Memory
+		case "cpus":
+			update["cpus"] = from.Resources.CPUs
+		case "port":
+			update["port"] = from.Ports.ContainerPort
+		case "domain":
+			update["domain"] = from.Domain
+		case "aliases":
+			update["aliases"] = from.Aliases
+		default:
+			if c.Section != "env" {
+				continue
+			}
+			if env == nil {
+				env = make(map[string]string, len(to.Env))
+				for k, v := range to.Env {
+					env[k] = v
+				}
+			}
+			if v, ok := from.Env[c.Field]; ok {
+				env[c.Field] = v
+			} else {
+				delete(env, c.Field)
+			}
+		}
+	}
+	if env != nil {
+		update["env"] = env
+	}
+	return update
+}
+
+// MaskSecret hides a secret value while still showing that it differs
+func MaskSecret(value string) string {
+	if value == Unset || value == "" {
+		return value
+	}
+	if len(value) <= 8 {
+		return "****"
+	}
+	return value[:2] + "****" + value[len(value)-2:]
+}
@@ -0,0 +1,78 @@
+package app
+
+import "testing"
+
+func TestDiffApps(t *testing.T) {
+	t.Parallel()
+
+	staging := App{
+		Image:     "ghcr.io/example/web:2",
+		Env:       map[string]string{"LOG_LEVEL": "debug", "API_TOKEN": "staging-token-123", "NEW_FLAG": "on"},
+		Resources: ResourceConfig{Memory: 512},
+		Domain:    "web.staging.test",
+	}
+	prod := App{
+		Image:     "ghcr.io/example/web:1",
+		Env:       map[string]string{"LOG_LEVEL": "debug", "API_TOKEN": "prod-token-456", "OLD_FLAG": "on"},
+		Resources: ResourceConfig{Memory: 512},
+		Domain:    "web.test",
+	}
+
+	got := map[string]ConfigChange{}
+	for _, c := range DiffApps(staging, prod) {
+		got[c.Field] = c
+	}
+	if len(got) != 5 {
+		t.Fatalf("changes = %+v, want image, API_TOKEN, NEW_FLAG, OLD_FLAG and domain", got)
+	}
+	if c := got["NEW_FLAG"]; c.From != "on" || c.To != Unset || c.Secret {
+		t.Fatalf("added key = %+v, want on -> %s", c, Unset)
+	}
+	if c := got["OLD_FLAG"]; c.From != Unset || c.To != "on" {
+		t.Fatalf("removed key = %+v, want %s -> on", c, Unset)
+	}
+	if c := got["API_TOKEN"]; !c.Secret {
+		t.Fatalf("API_TOKEN = %+v, want it marked secret", c)
+	}
+	if _, ok := got["LOG_LEVEL"]; ok {
+		t.Fatalf("unchanged LOG_LEVEL listed as a change")
+	}
+
+	// Promoting only env carries added and removed keys over to the target
+	env := SelectChanges(DiffApps(staging, prod), []string{"env"}, nil)
+	update := PromotionUpdate(env, staging, prod)
+	promoted, _ := update["env"].(map[string]string)
+	if len(update) != 1 || promoted["NEW_FLAG"] != "on" || promoted["API_TOKEN"] != "staging-token-123" || promoted["LOG_LEVEL"] != "debug" {
+		t.Fatalf("update = %+v, want only env with staging's values", update)
+	}
+	if _, ok := promoted["OLD_FLAG"]; ok {
+		t.Fatalf("promoted env = %v, want OLD_FLAG removed", promoted)
+	}
+
+	only := SelectChanges(DiffApps(staging, prod), nil, []string{"NEW_FLAG"})
+	if len(only) != 1 || only[0].Field != "NEW_FLAG" {
+		t.Fatalf("selected = %+v, want just NEW_FLAG", only)
+	}
+}
+
+func TestConfigChangeValuesMaskSecrets(t *testing.T) {
+	t.Parallel()
+
+	c := ConfigChange{Section: "env", Field: "API_TOKEN", From: "staging-token-123", To: "short", Secret: true}
+	if from, to := c.Values(false); from != "st****23" || to != "****" {
+		t.Fatalf("masked values = %q, %q", from, to)
+	}
+	if from, to := c.Values(true); from != c.From || to != c.To {
+		t.Fatalf("--show-secrets values = %q, %q", from, to)
+	}
+
+	added := ConfigChange{Section: "env", Field: "DB_PASSWORD", From: "hunter2-hunter2", To: Unset, Secret: true}
+	if from, to := added.Values(false); from != "hu****r2" || to != Unset {
+		t.Fatalf("masked added key = %q, %q, want the unset side kept", from, to)
+	}
+
+	plain := ConfigChange{Section: "env", Field: "LOG_LEVEL", From: "debug", To: "info"}
+	if from, to := plain.Values(false); from != "debug" || to != "info" {
+		t.Fatalf("plain values = %q, %q", from, to)
+	}
+}
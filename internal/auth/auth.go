@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,18 +15,36 @@ import (
 
 // Session represents an authenticated session
 type Session struct {
-	Token     string
-	UserID    string // empty for legacy admin sessions
-	UserEmail string
-	UserRole  string // "admin", "deployer", "viewer"
-	CreatedAt time.Time
-	ExpiresAt time.Time
+	ID         string // Safe to show: the first 16 characters of TokenHash
+	Token      string // Only set on the session Create returns; never stored
+	TokenHash  string
+	UserID     string // empty for legacy admin sessions
+	UserEmail  string
+	UserRole   string // "admin", "deployer", "viewer"
+	IP         string // Client address of the latest request
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
 }
 
+// SessionStore persists sessions so they survive restarts. Only token
+// hashes are stored, so a copy of the database can't be used to sign in.
+type SessionStore interface {
+	LoadSessions() ([]Session, error)
+	SaveSession(s *Session) error
+	DeleteSession(tokenHash string) error
+}
+
+// sessionTouchInterval is how stale a session's last seen time gets before
+// a request updates it, to keep requests from each writing to the store
+const sessionTouchInterval = time.Minute
+
 // Manager handles authentication and sessions
 type Manager struct {
 	passwordHash string
-	sessions     map[string]*Session
+	sessions     map[string]*Session // by token hash
+	store        SessionStore
 	mu           sync.RWMutex
 }
 
@@ -36,6 +56,73 @@ func NewManager(passwordHash string) *Manager {
 	}
 }
 
+// UseStore persists sessions in store from now on, and loads the unexpired
+// sessions it already holds
+func (m *Manager) UseStore(store SessionStore) error {
+	stored, err := store.LoadSessions()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	for i := range stored {
+		session := stored[i]
+		if now.After(session.ExpiresAt) {
+			store.DeleteSession(session.TokenHash)
+			continue
+		}
+		m.sessions[session.TokenHash] = &session
+	}
+	return nil
+}
+
+// hashToken returns the key a session token is stored under
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// persist saves a session to the store, if there is one
+func (m *Manager) persist(session *Session) {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	if store == nil {
+		return
+	}
+	if err := store.SaveSession(session); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+}
+
+// persistLocked is persist for callers holding m.mu, so a session deleted
+// in between can't be saved back to the store
+func (m *Manager) persistLocked(session *Session) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SaveSession(session); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+}
+
+// forget removes sessions from the store, if there is one
+func (m *Manager) forget(tokenHashes ...string) {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	if store == nil {
+		return
+	}
+	for _, h := range tokenHashes {
+		if err := store.DeleteSession(h); err != nil {
+			log.Printf("Failed to delete session: %v", err)
+		}
+	}
+}
+
 // hashPasswordLegacy hashes a password using SHA-256 (legacy, kept for backward compatibility)
 func hashPasswordLegacy(password string) string {
 	hash := sha256.Sum256([]byte(password))
@@ -87,20 +174,29 @@ func (m *Manager) CreateUserSession(userID, email, role string) (*Session, error
 		return nil, err
 	}
 
+	now := time.Now()
+	raw := hex.EncodeToString(token)
+	hash := hashToken(raw)
 	session := &Session{
-		Token:     hex.EncodeToString(token),
-		UserID:    userID,
-		UserEmail: email,
-		UserRole:  role,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+		ID:         hash[:16],
+		TokenHash:  hash,
+		UserID:     userID,
+		UserEmail:  email,
+		UserRole:   role,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(24 * time.Hour),
 	}
 
 	m.mu.Lock()
-	m.sessions[session.Token] = session
+	m.sessions[hash] = session
 	m.mu.Unlock()
+	m.persist(session)
 
-	return session, nil
+	// The raw token goes back to the caller once and isn't kept
+	created := *session
+	created.Token = raw
+	return &created, nil
 }
 
 // GetSession returns the session details for a valid token
@@ -109,7 +205,7 @@ func (m *Manager) GetSession(token string) *Session {
 		return nil
 	}
 	m.mu.RLock()
-	session, exists := m.sessions[token]
+	session, exists := m.sessions[hashToken(token)]
 	m.mu.RUnlock()
 	if !exists || time.Now().After(session.ExpiresAt) {
 		return nil
@@ -117,6 +213,68 @@ func (m *Manager) GetSession(token string) *Session {
 	return session
 }
 
+// Touch records a request made with a session: its client address, user
+// agent and time. The time is only updated every sessionTouchInterval.
+func (m *Manager) Touch(token, ip, userAgent string) {
+	if token == "" {
+		return
+	}
+	hash := hashToken(token)
+	now := time.Now()
+	m.mu.Lock()
+	session, exists := m.sessions[hash]
+	if !exists || (session.IP == ip && session.UserAgent == userAgent && now.Sub(session.LastSeenAt) < sessionTouchInterval) {
+		m.mu.Unlock()
+		return
+	}
+	// Swap in a copy; requests in flight keep reading the old one
+	updated := *session
+	updated.IP, updated.UserAgent, updated.LastSeenAt = ip, userAgent, now
+	m.sessions[hash] = &updated
+	m.persistLocked(&updated)
+	m.mu.Unlock()
+}
+
+// ListSessions returns the unexpired sessions, newest first
+func (m *Manager) ListSessions() []Session {
+	now := time.Now()
+	m.mu.RLock()
+	sessions := make([]Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		if now.Before(session.ExpiresAt) {
+			sessions = append(sessions, *session)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions
+}
+
+// SessionID returns the ID of the session a token belongs to
+func SessionID(token string) string {
+	return hashToken(token)[:16]
+}
+
+// DeleteSessionByID removes the session with the given ID, reporting
+// whether it existed
+func (m *Manager) DeleteSessionByID(id string) bool {
+	m.mu.Lock()
+	var found string
+	for hash, session := range m.sessions {
+		if session.ID == id {
+			found = hash
+			delete(m.sessions, hash)
+			break
+		}
+	}
+	m.mu.Unlock()
+	if found == "" {
+		return false
+	}
+	m.forget(found)
+	return true
+}
+
 // ValidateSession checks if a session token is valid
 func (m *Manager) ValidateSession(token string) bool {
 	if m.passwordHash == "" {
@@ -128,7 +286,7 @@ func (m *Manager) ValidateSession(token string) bool {
 	}
 
 	m.mu.RLock()
-	session, exists := m.sessions[token]
+	session, exists := m.sessions[hashToken(token)]
 	m.mu.RUnlock()
 
 	if !exists {
@@ -166,9 +324,25 @@ func (m *Manager) SetPassword(password string) bool {
 
 // DeleteSession removes a session
 func (m *Manager) DeleteSession(token string) {
+	hash := hashToken(token)
 	m.mu.Lock()
-	delete(m.sessions, token)
+	delete(m.sessions, hash)
 	m.mu.Unlock()
+	m.forget(hash)
+}
+
+// DeleteUserSessions removes every session belonging to a user
+func (m *Manager) DeleteUserSessions(userID string) {
+	m.mu.Lock()
+	var removed []string
+	for hash, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, hash)
+			removed = append(removed, hash)
+		}
+	}
+	m.mu.Unlock()
+	m.forget(removed...)
 }
 
 // UpdatePassword updates the password hash
@@ -189,12 +363,14 @@ func (m *Manager) GetPasswordHash() string {
 // CleanupExpiredSessions removes expired sessions
 func (m *Manager) CleanupExpiredSessions() {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	now := time.Now()
-	for token, session := range m.sessions {
+	var removed []string
+	for hash, session := range m.sessions {
 		if now.After(session.ExpiresAt) {
-			delete(m.sessions, token)
+			delete(m.sessions, hash)
+			removed = append(removed, hash)
 		}
 	}
+	m.mu.Unlock()
+	m.forget(removed...)
 }
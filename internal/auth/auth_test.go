@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a SessionStore kept in a map
+type memoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func (m *memoryStore) LoadSessions() ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Session
+	for _, s := range m.sessions {
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (m *memoryStore) SaveSession(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.TokenHash] = *s
+	return nil
+}
+
+func (m *memoryStore) DeleteSession(tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, tokenHash)
+	return nil
+}
+
+func TestSessionsSurviveRestart(t *testing.T) {
+	t.Parallel()
+	store := &memoryStore{sessions: map[string]Session{}}
+	first := NewManager("hash")
+	if err := first.UseStore(store); err != nil {
+		t.Fatalf("UseStore: %v", err)
+	}
+	kept, _ := first.CreateUserSession("u1", "a@example.com", "deployer")
+	dropped, _ := first.CreateUserSession("u1", "a@example.com", "deployer")
+	first.DeleteSession(dropped.Token)
+	first.Touch(kept.Token, "203.0.113.7", "curl/8")
+
+	for _, s := range store.sessions {
+		if s.Token != "" {
+			t.Fatalf("stored session has its raw token")
+		}
+	}
+
+	second := NewManager("hash")
+	if err := second.UseStore(store); err != nil {
+		t.Fatalf("UseStore: %v", err)
+	}
+	got := second.GetSession(kept.Token)
+	if got == nil || got.UserEmail != "a@example.com" || got.IP != "203.0.113.7" || got.ID != kept.ID {
+		t.Fatalf("session after restart = %+v", got)
+	}
+	if second.GetSession(dropped.Token) != nil {
+		t.Fatalf("deleted session came back after restart")
+	}
+	if !second.DeleteSessionByID(kept.ID) || len(store.sessions) != 0 {
+		t.Fatalf("DeleteSessionByID left %d stored sessions", len(store.sessions))
+	}
+}
+
+// racingStore is a memoryStore that deletes a session while its first
+// save is still being written
+type racingStore struct {
+	memoryStore
+	once   sync.Once
+	delete func()
+	done   sync.WaitGroup
+}
+
+func (r *racingStore) SaveSession(s *Session) error {
+	r.once.Do(func() {
+		r.done.Add(1)
+		go func() {
+			defer r.done.Done()
+			r.delete()
+		}()
+		time.Sleep(50 * time.Millisecond)
+	})
+	return r.memoryStore.SaveSession(s)
+}
+
+func TestTouchDoesNotSaveDeletedSessions(t *testing.T) {
+	t.Parallel()
+	m := NewManager("hash")
+	session, _ := m.CreateUserSession("u1", "a@example.com", "deployer")
+
+	store := &racingStore{memoryStore: memoryStore{sessions: map[string]Session{}}}
+	store.delete = func() { m.DeleteSession(session.Token) }
+	if err := m.UseStore(store); err != nil {
+		t.Fatalf("UseStore: %v", err)
+	}
+	m.Touch(session.Token, "203.0.113.7", "curl/8")
+	store.done.Wait()
+
+	if len(store.sessions) != 0 || m.GetSession(session.Token) != nil {
+		t.Fatalf("session deleted during Touch is back: %d stored", len(store.sessions))
+	}
+}
+
+func TestExpiredSessionsAreNotLoaded(t *testing.T) {
+	t.Parallel()
+	store := &memoryStore{sessions: map[string]Session{
+		"old": {ID: "old", TokenHash: "old", ExpiresAt: time.Now().Add(-time.Minute)},
+	}}
+	m := NewManager("hash")
+	if err := m.UseStore(store); err != nil {
+		t.Fatalf("UseStore: %v", err)
+	}
+	if len(m.ListSessions()) != 0 || len(store.sessions) != 0 {
+		t.Fatalf("expired session was kept")
+	}
+}
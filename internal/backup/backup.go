@@ -141,8 +141,9 @@ func (s *Service) Create(ctx context.Context, opts Options) (*Backup, error) {
 		volumes, err := s.podman.ListVolumes(ctx)
 		if err == nil {
 			for _, vol := range volumes {
-				// Only backup basepod-related volumes
-				if strings.HasPrefix(vol.Name, "basepod-") || strings.Contains(vol.Name, "-data") {
+				// Only backup basepod-related volumes: app volumes, whatever
+				// they are named, carry the app's label
+				if strings.HasPrefix(vol.Name, "basepod-") || strings.Contains(vol.Name, "-data") || vol.Labels["basepod.app"] != "" {
 					volData, err := s.exportVolume(ctx, vol.Name)
 					if err != nil {
 						// Log warning but continue
@@ -289,6 +290,58 @@ func (s *Service) Get(id string) (*Backup, error) {
 	return nil, fmt.Errorf("backup not found: %s", id)
 }
 
+// Verify reads a backup archive end to end and checks it has its metadata file
+func (s *Service) Verify(id string) error {
+	b, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	_, err = verifyArchive(b.Path)
+	return err
+}
+
+// verifyArchive reads a backup archive end to end and returns the ID in its
+// metadata file
+func verifyArchive(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	var metadata *Backup
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read tar header: %w", err)
+		}
+		if header.Name == "backup.json" {
+			metadata = &Backup{}
+			if err := json.NewDecoder(tarReader).Decode(metadata); err != nil {
+				return "", fmt.Errorf("failed to read backup.json: %w", err)
+			}
+		}
+		if _, err := io.Copy(io.Discard, tarReader); err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+	}
+
+	if metadata == nil {
+		return "", fmt.Errorf("backup.json missing from archive")
+	}
+	return metadata.ID, nil
+}
+
 // Delete removes a backup
 func (s *Service) Delete(id string) error {
 	backup, err := s.Get(id)
@@ -531,9 +584,6 @@ func (s *Service) restoreApp(r io.Reader, header *tar.Header) error {
 
 // restoreVolume restores a container volume
 func (s *Service) restoreVolume(ctx context.Context, r io.Reader, header *tar.Header, volumeName string) error {
-	// Find podman binary
-	podmanPath := findPodmanPath()
-
 	// Create a temporary file for the volume tar
 	tmpFile, err := os.CreateTemp("", "volume-*.tar")
 	if err != nil {
@@ -548,6 +598,14 @@ func (s *Service) restoreVolume(ctx context.Context, r io.Reader, header *tar.He
 	}
 	tmpFile.Close()
 
+	return ImportVolume(ctx, volumeName, tmpFile.Name())
+}
+
+// ImportVolume extracts a tar archive into a volume, creating the volume if
+// it doesn't exist
+func ImportVolume(ctx context.Context, volumeName, tarPath string) error {
+	podmanPath := findPodmanPath()
+
 	// Check if volume exists, create if not
 	checkCmd := exec.CommandContext(ctx, podmanPath, "volume", "exists", volumeName)
 	if err := checkCmd.Run(); err != nil {
@@ -559,7 +617,7 @@ func (s *Service) restoreVolume(ctx context.Context, r io.Reader, header *tar.He
 	}
 
 	// Import volume data
-	importCmd := exec.CommandContext(ctx, podmanPath, "volume", "import", volumeName, tmpFile.Name())
+	importCmd := exec.CommandContext(ctx, podmanPath, "volume", "import", volumeName, tarPath)
 	if output, err := importCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to import volume: %w (output: %s)", err, string(output))
 	}
@@ -567,6 +625,15 @@ func (s *Service) restoreVolume(ctx context.Context, r io.Reader, header *tar.He
 	return nil
 }
 
+// ExportVolume writes a volume's contents to a tar archive at tarPath
+func ExportVolume(ctx context.Context, volumeName, tarPath string) error {
+	cmd := exec.CommandContext(ctx, findPodmanPath(), "volume", "export", "--output", tarPath, volumeName)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("podman volume export failed: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
 // Helper functions
 
 func findPodmanPath() string {
@@ -0,0 +1,542 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+// maxS3PutSize is the largest object a single S3 PUT may upload
+const maxS3PutSize = 5 << 30
+
+// RemoteFile is a backup archive stored on a remote target
+type RemoteFile struct {
+	Name    string    `json:"name"` // basepod-backup-<id>.tar.gz
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Remote is an off-server store backups are uploaded to and restored from
+type Remote interface {
+	// Upload copies the local file at src to name
+	Upload(ctx context.Context, src, name string) error
+	// Download copies name to the local file dst
+	Download(ctx context.Context, name, dst string) error
+	// List returns the backup archives on the remote
+	List(ctx context.Context) ([]RemoteFile, error)
+}
+
+// NewRemote returns the Remote for a configured backup target
+func NewRemote(t config.BackupTarget) (Remote, error) {
+	switch t.Type {
+	case "s3":
+		if t.Bucket == "" || t.AccessKeyID == "" || t.SecretAccessKey == "" {
+			return nil, fmt.Errorf("backup target %s: s3 needs bucket, access_key_id and secret_access_key", t.Name)
+		}
+		region := t.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := strings.TrimSuffix(t.Endpoint, "/")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		return &s3Remote{
+			endpoint:  endpoint,
+			region:    region,
+			bucket:    t.Bucket,
+			prefix:    strings.Trim(t.Path, "/"),
+			accessKey: t.AccessKeyID,
+			secretKey: t.SecretAccessKey,
+			client:    &http.Client{Timeout: 2 * time.Hour},
+		}, nil
+	case "sftp":
+		if t.Host == "" {
+			return nil, fmt.Errorf("backup target %s: sftp needs a host", t.Name)
+		}
+		return &sftpRemote{target: t}, nil
+	case "rclone":
+		if t.Remote == "" {
+			return nil, fmt.Errorf("backup target %s: rclone needs a remote", t.Name)
+		}
+		return &rcloneRemote{target: t}, nil
+	default:
+		return nil, fmt.Errorf("backup target %s: unknown type %q (use s3, sftp or rclone)", t.Name, t.Type)
+	}
+}
+
+// backupFileName is the archive name of backup id, locally and on remotes
+func backupFileName(id string) string {
+	return fmt.Sprintf("basepod-backup-%s.tar.gz", id)
+}
+
+// backupID reads the backup id from an archive name; ok is false for other files
+func backupID(name string) (string, bool) {
+	id, found := strings.CutPrefix(name, "basepod-backup-")
+	if !found || !strings.HasSuffix(id, ".tar.gz") {
+		return "", false
+	}
+	return strings.TrimSuffix(id, ".tar.gz"), true
+}
+
+var backupIDPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}$`)
+
+// Upload copies backup id to a remote
+func (s *Service) Upload(ctx context.Context, id string, remote Remote) error {
+	b, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	return remote.Upload(ctx, b.Path, backupFileName(b.ID))
+}
+
+// ListRemote returns the backups on a remote, newest first. Only their
+// IDs, sizes and dates are known until one is fetched.
+func (s *Service) ListRemote(ctx context.Context, remote Remote) ([]Backup, error) {
+	files, err := remote.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	backups := []Backup{}
+	for _, f := range files {
+		id, _ := backupID(f.Name)
+		createdAt, err := time.ParseInLocation("20060102-150405", id, time.Local)
+		if err != nil {
+			createdAt = f.ModTime
+		}
+		backups = append(backups, Backup{ID: id, CreatedAt: createdAt, Size: f.Size})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Fetch downloads backup id from a remote into the backups directory, where
+// it can be restored like one made here. A backup already here is kept.
+func (s *Service) Fetch(ctx context.Context, remote Remote, id string) (*Backup, error) {
+	if !backupIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid backup ID: %s", id)
+	}
+	if b, err := s.Get(id); err == nil {
+		return b, nil
+	}
+	backupsDir := filepath.Join(s.paths.Base, "backups")
+	if err := os.MkdirAll(backupsDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	dst := filepath.Join(backupsDir, backupFileName(id))
+	tmp := dst + ".part" // Not listed until complete
+	if err := remote.Download(ctx, backupFileName(id), tmp); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := s.Verify(id); err != nil {
+		os.Remove(dst)
+		return nil, fmt.Errorf("downloaded backup is unreadable: %w", err)
+	}
+	return s.Get(id)
+}
+
+// Import stores a backup archive read from r, e.g. one uploaded from
+// another server, in the backups directory under the ID in its metadata.
+// An archive already here under that ID is kept.
+func (s *Service) Import(r io.Reader) (*Backup, error) {
+	backupsDir := filepath.Join(s.paths.Base, "backups")
+	if err := os.MkdirAll(backupsDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(backupsDir, "upload-*.part") // Not listed until complete
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to receive backup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	id, err := verifyArchive(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	if !backupIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid backup ID in metadata: %q", id)
+	}
+	if b, err := s.Get(id); err == nil {
+		return b, nil
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(backupsDir, backupFileName(id))); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// s3Remote stores backups in an S3-compatible bucket
+type s3Remote struct {
+	endpoint  string
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func (r *s3Remote) key(name string) string {
+	if r.prefix == "" {
+		return name
+	}
+	return r.prefix + "/" + name
+}
+
+// do signs and sends a request for key (or the bucket when key is empty)
+func (r *s3Remote) do(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	u := r.endpoint + "/" + r.bucket
+	if key != "" {
+		u += "/" + (&url.URL{Path: key}).EscapedPath()
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	signS3(req, r.accessKey, r.secretKey, r.region, time.Now())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		var e struct {
+			Message string `xml:"Message"`
+		}
+		xml.Unmarshal(data, &e)
+		if e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return nil, fmt.Errorf("s3: %s: %s", resp.Status, e.Message)
+	}
+	return resp, nil
+}
+
+func (r *s3Remote) Upload(ctx context.Context, src, name string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() > maxS3PutSize {
+		return fmt.Errorf("s3: %s is %s, above the 5 GiB single upload limit; use an rclone target for it", name, FormatSize(fi.Size()))
+	}
+	resp, err := r.do(ctx, "PUT", r.key(name), nil, file, fi.Size())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (r *s3Remote) Download(ctx context.Context, name, dst string) error {
+	resp, err := r.do(ctx, "GET", r.key(name), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		return fmt.Errorf("s3: %w", err)
+	}
+	return file.Close()
+}
+
+func (r *s3Remote) List(ctx context.Context) ([]RemoteFile, error) {
+	prefix := r.key("basepod-backup-")
+	var files []RemoteFile
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := r.do(ctx, "GET", "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: failed to decode listing: %w", err)
+		}
+		for _, obj := range result.Contents {
+			name := path.Base(obj.Key)
+			if _, ok := backupID(name); ok {
+				files = append(files, RemoteFile{Name: name, Size: obj.Size, ModTime: obj.LastModified})
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// signS3 adds AWS Signature Version 4 headers to an S3 request. The payload
+// isn't hashed, so archives stream from disk; S3 allows this over HTTPS.
+func signS3(req *http.Request, accessKey, secretKey, region string, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate, "x-amz-content-sha256": payloadHash}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalPath := req.URL.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sftpRemote stores backups on an SFTP server with the system's sftp
+// client, which must be able to log in without a password
+type sftpRemote struct {
+	target config.BackupTarget
+}
+
+// run runs sftp batch commands and returns their output
+func (r *sftpRemote) run(ctx context.Context, commands ...string) (string, error) {
+	sftpPath, err := exec.LookPath("sftp")
+	if err != nil {
+		return "", fmt.Errorf("sftp client not found: %w", err)
+	}
+	port := r.target.Port
+	if port == 0 {
+		port = 22
+	}
+	args := []string{"-b", "-", "-P", strconv.Itoa(port), "-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new"}
+	if r.target.KeyFile != "" {
+		args = append(args, "-i", r.target.KeyFile)
+	}
+	host := r.target.Host
+	if r.target.User != "" {
+		host = r.target.User + "@" + host
+	}
+	args = append(args, host)
+
+	cmd := exec.CommandContext(ctx, sftpPath, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("sftp: %w: %s", err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
+
+func (r *sftpRemote) remotePath(name string) string {
+	if r.target.Path == "" {
+		return name
+	}
+	return path.Join(r.target.Path, name)
+}
+
+// sftpQuote quotes a path for an sftp batch command
+func sftpQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func (r *sftpRemote) Upload(ctx context.Context, src, name string) error {
+	var commands []string
+	if r.target.Path != "" {
+		commands = append(commands, "-mkdir "+sftpQuote(r.target.Path)) // "-": already existing is fine
+	}
+	commands = append(commands, "put "+sftpQuote(src)+" "+sftpQuote(r.remotePath(name)))
+	_, err := r.run(ctx, commands...)
+	return err
+}
+
+func (r *sftpRemote) Download(ctx context.Context, name, dst string) error {
+	_, err := r.run(ctx, "get "+sftpQuote(r.remotePath(name))+" "+sftpQuote(dst))
+	return err
+}
+
+func (r *sftpRemote) List(ctx context.Context) ([]RemoteFile, error) {
+	dir := r.target.Path
+	if dir == "" {
+		dir = "."
+	}
+	out, err := r.run(ctx, "ls -ln "+sftpQuote(dir))
+	if err != nil {
+		return nil, err
+	}
+	return parseSFTPListing(out), nil
+}
+
+// parseSFTPListing reads the backups from `ls -ln` output, whose lines look
+// like "-rw-r--r-- 1 1000 1000 52428800 Jan 30 15:12 basepod-backup-....tar.gz"
+func parseSFTPListing(out string) []RemoteFile {
+	var files []RemoteFile
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		name := path.Base(fields[len(fields)-1])
+		id, ok := backupID(name)
+		if !ok {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[4], 10, 64)
+		modTime, _ := time.ParseInLocation("20060102-150405", id, time.Local)
+		files = append(files, RemoteFile{Name: name, Size: size, ModTime: modTime})
+	}
+	return files
+}
+
+// rcloneRemote stores backups with rclone, on any of its backends
+type rcloneRemote struct {
+	target config.BackupTarget
+}
+
+func (r *rcloneRemote) dir() string {
+	if r.target.Path == "" {
+		return r.target.Remote
+	}
+	return strings.TrimSuffix(r.target.Remote, "/") + "/" + strings.Trim(r.target.Path, "/")
+}
+
+// run runs rclone and returns its output
+func (r *rcloneRemote) run(ctx context.Context, args ...string) ([]byte, error) {
+	rclonePath, err := exec.LookPath("rclone")
+	if err != nil {
+		return nil, fmt.Errorf("rclone not found: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, rclonePath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("rclone: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func (r *rcloneRemote) Upload(ctx context.Context, src, name string) error {
+	_, err := r.run(ctx, "copyto", src, r.dir()+"/"+name)
+	return err
+}
+
+func (r *rcloneRemote) Download(ctx context.Context, name, dst string) error {
+	_, err := r.run(ctx, "copyto", r.dir()+"/"+name, dst)
+	return err
+}
+
+func (r *rcloneRemote) List(ctx context.Context) ([]RemoteFile, error) {
+	out, err := r.run(ctx, "lsjson", "--files-only", r.dir())
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		Name    string    `json:"Name"`
+		Size    int64     `json:"Size"`
+		ModTime time.Time `json:"ModTime"`
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, fmt.Errorf("rclone: failed to decode listing: %w", err)
+	}
+	var files []RemoteFile
+	for _, e := range entries {
+		if _, ok := backupID(e.Name); ok {
+			files = append(files, RemoteFile{Name: e.Name, Size: e.Size, ModTime: e.ModTime})
+		}
+	}
+	return files, nil
+}
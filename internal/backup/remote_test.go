@@ -0,0 +1,173 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+// fakeS3 serves PUT, GET and ListObjectsV2 for one bucket
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		http.Error(w, "<Error><Message>unsigned</Message></Error>", http.StatusForbidden)
+		return
+	}
+	key, _ := strings.CutPrefix(r.URL.Path, "/backups/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == "PUT":
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == "GET" && r.URL.Query().Get("list-type") == "2":
+		fmt.Fprint(w, "<ListBucketResult>")
+		for k, v := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>2026-01-30T15:12:00Z</LastModified></Contents>", k, len(v))
+			}
+		}
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+	case r.Method == "GET":
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Message>The specified key does not exist.</Message></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func TestS3RemoteFetch(t *testing.T) {
+	t.Parallel()
+	s3 := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+
+	remote, err := NewRemote(config.BackupTarget{Name: "offsite", Type: "s3", Endpoint: srv.URL, Bucket: "backups", Path: "prod", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatalf("NewRemote: %v", err)
+	}
+
+	// A backup made on the old server
+	oldHome := t.TempDir()
+	oldService := NewService(&config.Paths{Base: oldHome, Data: filepath.Join(oldHome, "data"), Config: filepath.Join(oldHome, "config"), Apps: filepath.Join(oldHome, "apps")}, nil)
+	os.MkdirAll(filepath.Join(oldHome, "config"), 0755)
+	os.WriteFile(filepath.Join(oldHome, "config", "basepod.yaml"), []byte("domain:\n  root: example.com\n"), 0600)
+	b, err := oldService.Create(context.Background(), Options{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := oldService.Upload(context.Background(), b.ID, remote); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if _, ok := s3.objects["prod/"+backupFileName(b.ID)]; !ok {
+		t.Fatalf("objects = %v, want the backup under the prod/ prefix", s3.objects)
+	}
+
+	// A fresh server finds and restores it
+	newHome := t.TempDir()
+	newService := NewService(&config.Paths{Base: newHome, Data: filepath.Join(newHome, "data"), Config: filepath.Join(newHome, "config"), Apps: filepath.Join(newHome, "apps")}, nil)
+	backups, err := newService.ListRemote(context.Background(), remote)
+	if err != nil || len(backups) != 1 || backups[0].ID != b.ID {
+		t.Fatalf("ListRemote = %+v, %v; want backup %s", backups, err, b.ID)
+	}
+	fetched, err := newService.Fetch(context.Background(), remote, b.ID)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if !fetched.Contents.Config {
+		t.Fatalf("fetched backup contents = %+v, want config", fetched.Contents)
+	}
+	if _, err := newService.Fetch(context.Background(), remote, "20990101-000000"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Fetch of a missing backup = %v, want the S3 error", err)
+	}
+	if _, err := newService.Fetch(context.Background(), remote, "../../etc"); err == nil {
+		t.Fatalf("Fetch with a path in the ID: want an error")
+	}
+	if local, _ := newService.List(); len(local) != 1 {
+		t.Fatalf("local backups = %+v, want only the fetched one", local)
+	}
+}
+
+func TestNewRemoteValidates(t *testing.T) {
+	t.Parallel()
+	for _, target := range []config.BackupTarget{
+		{Name: "a", Type: "s3", Bucket: "b"},
+		{Name: "b", Type: "sftp"},
+		{Name: "c", Type: "rclone"},
+		{Name: "d", Type: "ftp"},
+	} {
+		if _, err := NewRemote(target); err == nil {
+			t.Fatalf("NewRemote(%+v): want an error", target)
+		}
+	}
+}
+
+func TestParseSFTPListing(t *testing.T) {
+	t.Parallel()
+	out := `sftp> ls -ln "/backups"
+-rw-r--r--    1 1000     1000     52428800 Jan 30 15:12 /backups/basepod-backup-20260130-151200.tar.gz
+-rw-r--r--    1 1000     1000          10 Jan 30 15:12 /backups/notes.txt
+drwxr-xr-x    2 1000     1000         4096 Jan 30 15:12 /backups/basepod-backup-old`
+	files := parseSFTPListing(out)
+	if len(files) != 1 || files[0].Size != 52428800 || files[0].ModTime.Format("2006-01-02 15:04") != time.Date(2026, 1, 30, 15, 12, 0, 0, time.Local).Format("2006-01-02 15:04") {
+		t.Fatalf("files = %+v", files)
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+	oldHome := t.TempDir()
+	oldService := NewService(&config.Paths{Base: oldHome, Data: filepath.Join(oldHome, "data"), Config: filepath.Join(oldHome, "config"), Apps: filepath.Join(oldHome, "apps")}, nil)
+	os.MkdirAll(filepath.Join(oldHome, "config"), 0755)
+	os.WriteFile(filepath.Join(oldHome, "config", "basepod.yaml"), []byte("domain:\n  root: example.com\n"), 0600)
+	b, err := oldService.Create(context.Background(), Options{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	newHome := t.TempDir()
+	newService := NewService(&config.Paths{Base: newHome, Data: filepath.Join(newHome, "data"), Config: filepath.Join(newHome, "config"), Apps: filepath.Join(newHome, "apps")}, nil)
+	for i := 0; i < 2; i++ { // Importing the same backup twice keeps one copy
+		f, err := os.Open(b.Path)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		imported, err := newService.Import(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if imported.ID != b.ID || !imported.Contents.Config {
+			t.Fatalf("imported = %+v, want backup %s with config", imported, b.ID)
+		}
+	}
+	if _, err := newService.Import(strings.NewReader("not a backup")); err == nil {
+		t.Fatalf("Import of garbage: want an error")
+	}
+	local, _ := newService.List()
+	if len(local) != 1 {
+		t.Fatalf("local backups = %+v, want only the imported one", local)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(local[0].Path))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".part") {
+			t.Fatalf("left a partial upload behind: %s", e.Name())
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,12 +20,239 @@ type Client struct {
 
 // Route represents a reverse proxy route
 type Route struct {
-	ID          string
-	Domain      string
-	Upstream    string // e.g., "localhost:8080" or container IP
-	EnableSSL   bool
-	ForceHTTPS  bool
-	CORS        bool   // Add CORS headers (Access-Control-Allow-Origin: *)
+	ID         string
+	Domain     string
+	Upstream   string   // e.g., "localhost:8080" or container IP
+	Upstreams  []string // More upstreams balanced with Upstream, e.g. an app's replicas
+	Weights    []int    // Share of requests for Upstream then each of Upstreams, e.g. for a canary (empty: equal)
+	EnableSSL  bool
+	ForceHTTPS bool
+	CORS       bool   // Add CORS headers (Access-Control-Allow-Origin: *)
+	SEO        SEO    // Search engine controls
+	Protocol   string // Upstream protocol: "http" (default), "h2c" or "grpc"
+	Streams    Streams
+	Headers    map[string]string // Response headers set on every response, e.g. security headers
+	Snippets   Snippets          // Extra routing the app declares, run before the upstream
+}
+
+// SEO controls how search engines see a route
+type SEO struct {
+	NoIndex   bool   // Send X-Robots-Tag: noindex, nofollow on every response
+	RobotsTxt string // If set, served at /robots.txt instead of the upstream's
+}
+
+// Streams tunes long-lived connections (websockets, server-sent events)
+// proxied through a route
+type Streams struct {
+	IdleTimeout time.Duration // Close connections the upstream sends nothing on for this long (0: no limit)
+	CloseDelay  time.Duration // Keep open connections this long when the config is reloaded (0: close them)
+}
+
+// Snippets are extra routing rules an app declares, e.g. in its
+// basepod.yaml. Paths are Caddy path matchers ("/admin/*"); an empty path
+// matches every request.
+type Snippets struct {
+	IPAllowlist []IPAllow // Checked first: other addresses get 403
+	BasicAuth   []BasicAuth
+	Redirects   []Redirect
+	Headers     []PathHeaders
+	Gzip        bool // Compress responses with zstd or gzip
+}
+
+// IPAllow only lets requests for a path from the listed addresses or CIDR
+// ranges through
+type IPAllow struct {
+	Path string
+	IPs  []string
+}
+
+// BasicAuth asks for a username and password on requests for a path
+type BasicAuth struct {
+	Path         string
+	Username     string
+	PasswordHash string // bcrypt
+}
+
+// Redirect answers requests for a path with a redirect
+type Redirect struct {
+	From   string
+	To     string // May use placeholders, e.g. "/docs{http.request.uri.query}"
+	Status int    // Default: 301
+}
+
+// PathHeaders sets response headers on requests for a path
+type PathHeaders struct {
+	Path string
+	Set  map[string]string
+}
+
+// onPath runs handler only for requests matching path, or for every request
+// when path is empty
+func onPath(path string, handler map[string]interface{}) map[string]interface{} {
+	if path == "" {
+		return handler
+	}
+	return map[string]interface{}{
+		"handler": "subroute",
+		"routes": []map[string]interface{}{
+			{
+				"match":  []map[string]interface{}{{"path": []string{path}}},
+				"handle": []map[string]interface{}{handler},
+			},
+		},
+	}
+}
+
+// withSnippets puts an app's extra routing rules in front of handlers
+func withSnippets(s Snippets, handlers []map[string]interface{}) []map[string]interface{} {
+	var pre []map[string]interface{}
+	for _, allow := range s.IPAllowlist {
+		match := map[string]interface{}{
+			"not": []map[string]interface{}{
+				{"remote_ip": map[string]interface{}{"ranges": allow.IPs}},
+			},
+		}
+		if allow.Path != "" {
+			match["path"] = []string{allow.Path}
+		}
+		pre = append(pre, map[string]interface{}{
+			"handler": "subroute",
+			"routes": []map[string]interface{}{
+				{
+					"match": []map[string]interface{}{match},
+					"handle": []map[string]interface{}{
+						{"handler": "static_response", "status_code": "403", "body": "Forbidden"},
+					},
+				},
+			},
+		})
+	}
+
+	// Accounts sharing a path go in one handler, so any of them gets in
+	var authPaths []string
+	accounts := map[string][]map[string]string{}
+	for _, a := range s.BasicAuth {
+		if _, ok := accounts[a.Path]; !ok {
+			authPaths = append(authPaths, a.Path)
+		}
+		accounts[a.Path] = append(accounts[a.Path], map[string]string{"username": a.Username, "password": a.PasswordHash})
+	}
+	for _, path := range authPaths {
+		pre = append(pre, onPath(path, map[string]interface{}{
+			"handler": "authentication",
+			"providers": map[string]interface{}{
+				"http_basic": map[string]interface{}{
+					"accounts": accounts[path],
+					"hash":     map[string]interface{}{"algorithm": "bcrypt"},
+				},
+			},
+		}))
+	}
+
+	for _, r := range s.Redirects {
+		status := r.Status
+		if status == 0 {
+			status = http.StatusMovedPermanently
+		}
+		pre = append(pre, onPath(r.From, map[string]interface{}{
+			"handler":     "static_response",
+			"status_code": fmt.Sprintf("%d", status),
+			"headers":     map[string][]string{"Location": {r.To}},
+		}))
+	}
+
+	for _, h := range s.Headers {
+		set := make(map[string][]string, len(h.Set))
+		for name, value := range h.Set {
+			set[name] = []string{value}
+		}
+		pre = append(pre, onPath(h.Path, map[string]interface{}{
+			"handler": "headers",
+			"response": map[string]interface{}{
+				"set":      set,
+				"deferred": true,
+			},
+		}))
+	}
+
+	if s.Gzip {
+		pre = append(pre, map[string]interface{}{
+			"handler": "encode",
+			"encodings": map[string]interface{}{
+				"zstd": map[string]interface{}{},
+				"gzip": map[string]interface{}{},
+			},
+			"prefer": []string{"zstd", "gzip"},
+		})
+	}
+	return append(pre, handlers...)
+}
+
+// withSEO puts robots.txt and X-Robots-Tag handling in front of handlers
+func withSEO(seo SEO, handlers []map[string]interface{}) []map[string]interface{} {
+	if seo.RobotsTxt != "" {
+		handlers = []map[string]interface{}{
+			{
+				"handler": "subroute",
+				"routes": []map[string]interface{}{
+					{
+						"match": []map[string]interface{}{
+							{"path": []string{"/robots.txt"}},
+						},
+						"handle": []map[string]interface{}{
+							{
+								"handler":     "static_response",
+								"status_code": "200",
+								"headers": map[string][]string{
+									"Content-Type": {"text/plain; charset=utf-8"},
+								},
+								"body": seo.RobotsTxt,
+							},
+						},
+					},
+					{
+						"handle": handlers,
+					},
+				},
+			},
+		}
+	}
+	if seo.NoIndex {
+		// Set after the upstream responds, so the app can't drop it
+		handlers = append([]map[string]interface{}{
+			{
+				"handler": "headers",
+				"response": map[string]interface{}{
+					"set": map[string][]string{
+						"X-Robots-Tag": {"noindex, nofollow"},
+					},
+					"deferred": true,
+				},
+			},
+		}, handlers...)
+	}
+	return handlers
+}
+
+// withHeaders sets headers on every response of handlers
+func withHeaders(headers map[string]string, handlers []map[string]interface{}) []map[string]interface{} {
+	if len(headers) == 0 {
+		return handlers
+	}
+	set := make(map[string][]string, len(headers))
+	for name, value := range headers {
+		set[name] = []string{value}
+	}
+	// Set after the upstream responds, so they replace the app's own
+	return append([]map[string]interface{}{
+		{
+			"handler": "headers",
+			"response": map[string]interface{}{
+				"set":      set,
+				"deferred": true,
+			},
+		},
+	}, handlers...)
 }
 
 // NewClient creates a new Caddy client
@@ -53,17 +282,53 @@ func (c *Client) Ping() error {
 	return nil
 }
 
-// AddRoute adds a reverse proxy route for an app (removes existing route with same ID first)
-func (c *Client) AddRoute(route Route) error {
-	// Remove existing route with same ID first (ignore errors - route may not exist)
-	c.RemoveRoute(route.ID)
+// dials lists a reverse_proxy handler's upstreams
+func dials(upstream string, more []string) []map[string]string {
+	list := []map[string]string{{"dial": upstream}}
+	for _, u := range more {
+		list = append(list, map[string]string{"dial": u})
+	}
+	return list
+}
 
-	// Build the reverse proxy handler with proper headers
-	proxyHandler := map[string]interface{}{
-		"handler": "reverse_proxy",
-		"upstreams": []map[string]string{
-			{"dial": route.Upstream},
-		},
+// setLoadBalancing spreads requests over a reverse_proxy handler's upstreams
+// round robin. An upstream that fails a request is skipped for a while and
+// the request retried on the next one.
+func setLoadBalancing(proxy map[string]interface{}) {
+	upstreams, _ := proxy["upstreams"].([]map[string]string)
+	if len(upstreams) < 2 {
+		return
+	}
+	proxy["load_balancing"] = map[string]interface{}{
+		"selection_policy": map[string]interface{}{"policy": "round_robin"},
+		"try_duration":     "5s",
+	}
+	proxy["health_checks"] = map[string]interface{}{
+		"passive": map[string]interface{}{"fail_duration": "30s"},
+	}
+}
+
+// setWeights splits requests over a reverse_proxy handler's upstreams by
+// weight instead of evenly. weights lines up with the upstreams; a mismatched
+// list is ignored.
+func setWeights(proxy map[string]interface{}, weights []int) {
+	upstreams, _ := proxy["upstreams"].([]map[string]string)
+	if len(upstreams) < 2 || len(weights) != len(upstreams) {
+		return
+	}
+	lb := proxy["load_balancing"].(map[string]interface{})
+	lb["selection_policy"] = map[string]interface{}{
+		"policy":  "weighted_round_robin",
+		"weights": weights,
+	}
+}
+
+// reverseProxyHandler proxies to upstream and any more upstreams, passing the
+// original host and client
+func reverseProxyHandler(upstream string, more []string) map[string]interface{} {
+	proxy := map[string]interface{}{
+		"handler":   "reverse_proxy",
+		"upstreams": dials(upstream, more),
 		"headers": map[string]interface{}{
 			"request": map[string]interface{}{
 				"set": map[string][]string{
@@ -75,6 +340,183 @@ func (c *Client) AddRoute(route Route) error {
 			},
 		},
 	}
+	setLoadBalancing(proxy)
+	return proxy
+}
+
+// setUpstreamProtocol makes a reverse_proxy handler speak cleartext HTTP/2
+// (h2c) to upstreams that need it. gRPC also needs responses flushed as they
+// arrive so streaming calls work.
+func setUpstreamProtocol(proxy map[string]interface{}, protocol string) {
+	switch protocol {
+	case "h2c", "grpc":
+		proxy["transport"] = map[string]interface{}{
+			"protocol": "http",
+			"versions": []string{"h2c", "2"},
+		}
+	}
+	if protocol == "grpc" {
+		proxy["flush_interval"] = -1
+	}
+}
+
+// setStreams applies a route's long-lived connection settings to a
+// reverse_proxy handler. Caddy closes upgraded connections whenever its config
+// is reloaded; the close delay lets them outlive a route change, e.g. to the
+// previous container while it drains after a deploy.
+func setStreams(proxy map[string]interface{}, streams Streams) {
+	if streams.IdleTimeout > 0 {
+		transport, _ := proxy["transport"].(map[string]interface{})
+		if transport == nil {
+			transport = map[string]interface{}{"protocol": "http"}
+			proxy["transport"] = transport
+		}
+		transport["read_timeout"] = streams.IdleTimeout.String()
+	}
+	if streams.CloseDelay > 0 {
+		proxy["stream_close_delay"] = streams.CloseDelay.String()
+	}
+}
+
+// pathRoutePrefix marks routes for path rules; they stay ahead of the
+// host-only routes for the same domain
+const pathRoutePrefix = "paths-"
+
+// insertURL is where a new route goes: first for path rules, otherwise right
+// after them so they keep precedence
+func (c *Client) insertURL(routeID string) string {
+	base := c.adminURL + "/config/apps/http/servers/srv0/routes/"
+	if strings.HasPrefix(routeID, pathRoutePrefix) {
+		return base + "0"
+	}
+	resp, err := c.httpClient.Get(c.adminURL + "/config/apps/http/servers/srv0/routes")
+	if err != nil {
+		return base + "0"
+	}
+	defer resp.Body.Close()
+	var routes []struct {
+		ID string `json:"@id"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&routes) != nil {
+		return base + "0"
+	}
+	pinned := 0
+	for pinned < len(routes) && strings.HasPrefix(routes[pinned].ID, pathRoutePrefix) {
+		pinned++
+	}
+	return base + strconv.Itoa(pinned)
+}
+
+// PathRule sends requests on a shared domain that match a path prefix, and
+// optionally a header, to an upstream
+type PathRule struct {
+	PathPrefix  string // "/" matches every path
+	HeaderName  string
+	HeaderValue string // Empty matches any value
+	StripPrefix bool
+	Upstream    string
+	Upstreams   []string // As in Route.Upstreams
+	Weights     []int    // As in Route.Weights
+	Protocol    string   // As in Route.Protocol
+	Streams     Streams
+	Headers     map[string]string // As in Route.Headers
+}
+
+// pathRuleMatcher matches requests for domain that a rule applies to
+func pathRuleMatcher(domain string, rule PathRule) map[string]interface{} {
+	match := map[string]interface{}{"host": []string{domain}}
+	if rule.PathPrefix != "/" {
+		match["path"] = []string{rule.PathPrefix, rule.PathPrefix + "/*"}
+	}
+	if rule.HeaderName != "" {
+		value := rule.HeaderValue
+		if value == "" {
+			value = "*"
+		}
+		match["header"] = map[string][]string{rule.HeaderName: {value}}
+	}
+	return match
+}
+
+// SetPathRoutes routes a domain's requests by path and header, trying rules in
+// order. Requests no rule matches fall through to the domain's other routes.
+// With no rules the domain's path routing is removed.
+func (c *Client) SetPathRoutes(domain string, rules []PathRule) error {
+	routeID := pathRoutePrefix + domain
+	c.RemoveRoute(routeID)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	matchers := make([]map[string]interface{}, 0, len(rules))
+	subroutes := make([]map[string]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		var handle []map[string]interface{}
+		if rule.StripPrefix && rule.PathPrefix != "/" {
+			handle = append(handle, map[string]interface{}{
+				"handler":           "rewrite",
+				"strip_path_prefix": rule.PathPrefix,
+			})
+		}
+		proxy := reverseProxyHandler(rule.Upstream, rule.Upstreams)
+		setWeights(proxy, rule.Weights)
+		setUpstreamProtocol(proxy, rule.Protocol)
+		setStreams(proxy, rule.Streams)
+		handle = append(handle, proxy)
+		matcher := pathRuleMatcher(domain, rule)
+		matchers = append(matchers, matcher)
+		subroutes = append(subroutes, map[string]interface{}{
+			"match":    []map[string]interface{}{matcher},
+			"handle":   withHeaders(rule.Headers, handle),
+			"terminal": true,
+		})
+	}
+
+	routeConfig := map[string]interface{}{
+		"@id":   routeID,
+		"match": matchers, // Any rule matching; otherwise the domain's other routes apply
+		"handle": []map[string]interface{}{
+			{
+				"handler": "subroute",
+				"routes":  subroutes,
+			},
+		},
+		"terminal": true,
+	}
+
+	body, err := json.Marshal(routeConfig)
+	if err != nil {
+		return fmt.Errorf("failed to marshal route config: %w", err)
+	}
+
+	req, err := http.NewRequest("PUT", c.insertURL(routeID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to add path routes: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to add path routes (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// AddRoute adds a reverse proxy route for an app (removes existing route with same ID first)
+func (c *Client) AddRoute(route Route) error {
+	// Remove existing route with same ID first (ignore errors - route may not exist)
+	c.RemoveRoute(route.ID)
+
+	// Build the reverse proxy handler with proper headers
+	proxyHandler := reverseProxyHandler(route.Upstream, route.Upstreams)
+	setWeights(proxyHandler, route.Weights)
+	setUpstreamProtocol(proxyHandler, route.Protocol)
+	setStreams(proxyHandler, route.Streams)
 
 	// If CORS is enabled, add response headers to the reverse proxy
 	if route.CORS {
@@ -131,7 +573,7 @@ func (c *Client) AddRoute(route Route) error {
 		"match": []map[string]interface{}{
 			{"host": []string{route.Domain}},
 		},
-		"handle": handlers,
+		"handle": withHeaders(route.Headers, withSEO(route.SEO, withSnippets(route.Snippets, handlers))),
 	}
 
 	body, err := json.Marshal(routeConfig)
@@ -140,7 +582,7 @@ func (c *Client) AddRoute(route Route) error {
 	}
 
 	// Add to main server (srv0) routes - prepend to take priority over wildcard
-	url := c.adminURL + "/config/apps/http/servers/srv0/routes/0"
+	url := c.insertURL(route.ID)
 	req, err := http.NewRequest("PUT", url, bytes.NewReader(body))
 	if err != nil {
 		return err
@@ -186,7 +628,7 @@ func (c *Client) AddRedirectRoute(routeID, domain, targetURL string) error {
 		return fmt.Errorf("failed to marshal redirect config: %w", err)
 	}
 
-	url := c.adminURL + "/config/apps/http/servers/srv0/routes/0"
+	url := c.insertURL(routeID)
 	req, err := http.NewRequest("PUT", url, bytes.NewReader(body))
 	if err != nil {
 		return err
@@ -231,19 +673,20 @@ func (c *Client) InitializeServer(routes []Route) error {
 	// No server exists - create one with HTTPS
 	caddyRoutes := make([]interface{}, 0, len(routes))
 	for _, route := range routes {
+		proxy := map[string]interface{}{
+			"handler":   "reverse_proxy",
+			"upstreams": dials(route.Upstream, route.Upstreams),
+		}
+		setLoadBalancing(proxy)
+		setWeights(proxy, route.Weights)
+		setUpstreamProtocol(proxy, route.Protocol)
+		setStreams(proxy, route.Streams)
 		caddyRoutes = append(caddyRoutes, map[string]interface{}{
 			"@id": route.ID,
 			"match": []map[string]interface{}{
 				{"host": []string{route.Domain}},
 			},
-			"handle": []map[string]interface{}{
-				{
-					"handler": "reverse_proxy",
-					"upstreams": []map[string]string{
-						{"dial": route.Upstream},
-					},
-				},
-			},
+			"handle": withHeaders(route.Headers, withSEO(route.SEO, withSnippets(route.Snippets, []map[string]interface{}{proxy}))),
 		})
 	}
 
@@ -270,19 +713,18 @@ func (c *Client) InitializeServer(routes []Route) error {
 
 // UpdateRoute updates an existing route
 func (c *Client) UpdateRoute(route Route) error {
+	proxy := map[string]interface{}{
+		"handler":   "reverse_proxy",
+		"upstreams": dials(route.Upstream, route.Upstreams),
+	}
+	setLoadBalancing(proxy)
+	setWeights(proxy, route.Weights)
 	routeConfig := map[string]interface{}{
 		"@id": route.ID,
 		"match": []map[string]interface{}{
 			{"host": []string{route.Domain}},
 		},
-		"handle": []map[string]interface{}{
-			{
-				"handler": "reverse_proxy",
-				"upstreams": []map[string]string{
-					{"dial": route.Upstream},
-				},
-			},
-		},
+		"handle": []map[string]interface{}{proxy},
 	}
 
 	body, err := json.Marshal(routeConfig)
@@ -385,65 +827,132 @@ func (c *Client) GetRoutes() ([]Route, error) {
 	return routes, nil
 }
 
-// AddStaticRoute adds a static file serving route for a domain
-func (c *Client) AddStaticRoute(domain, rootDir string) error {
+// StaticSite is how a static site answers paths without a file, and whether
+// its files have precompressed copies
+type StaticSite struct {
+	SPA           bool   // Serve /index.html, for client-side routing
+	NotFound      string // Page served with a 404 when SPA is off, e.g. "/404.html"
+	Precompressed bool   // Serve a file's .br or .gz copy to clients that accept it
+}
+
+// fileRoutes serves the files of a static site: a path's file or its
+// index.html, then /index.html or the 404 page for paths without one
+func fileRoutes(rootDir string, site StaticSite) []map[string]interface{} {
+	tryFiles := []string{"{http.request.uri.path}", "{http.request.uri.path}/index.html"}
+	if site.SPA {
+		tryFiles = append(tryFiles, "/index.html")
+	}
+	serve := func(fileServer map[string]interface{}) []map[string]interface{} {
+		return []map[string]interface{}{
+			// Pages are revalidated on every visit, so a deploy or purge
+			// reaches visitors straight away; header rules can override it
+			{
+				"handler": "headers",
+				"response": map[string]interface{}{
+					"set": map[string][]string{"Cache-Control": {"no-cache"}},
+					"require": map[string]interface{}{
+						"headers": map[string][]string{"Content-Type": {"text/html*"}},
+					},
+					"deferred": true,
+				},
+			},
+			{
+				"handler": "encode",
+				"encodings": map[string]interface{}{
+					"gzip": map[string]interface{}{},
+				},
+				"prefer": []string{"gzip"},
+			},
+			fileServer,
+		}
+	}
+	fileServer := func() map[string]interface{} {
+		fs := map[string]interface{}{
+			"handler": "file_server",
+			"hide":    []string{"./Caddyfile"},
+		}
+		// Copies written at deploy time are served as they are; encode
+		// leaves responses that already have a Content-Encoding alone
+		if site.Precompressed {
+			fs["precompressed"] = map[string]interface{}{
+				"br":   map[string]interface{}{},
+				"gzip": map[string]interface{}{},
+			}
+			fs["precompressed_order"] = []string{"br", "gzip"}
+		}
+		return fs
+	}
+
+	routes := []map[string]interface{}{
+		{
+			"handle": []map[string]interface{}{
+				{
+					"handler": "vars",
+					"root":    rootDir,
+				},
+			},
+		},
+		{
+			"match": []map[string]interface{}{
+				{
+					"file": map[string]interface{}{
+						"try_files": tryFiles,
+					},
+				},
+			},
+			"handle": []map[string]interface{}{
+				{
+					"handler": "rewrite",
+					"uri":     "{http.matchers.file.relative}",
+				},
+			},
+		},
+	}
+	if !site.SPA && site.NotFound != "" {
+		notFound := fileServer()
+		notFound["status_code"] = "404"
+		routes = append(routes, map[string]interface{}{
+			"match": []map[string]interface{}{
+				{
+					"not": []map[string]interface{}{
+						{"file": map[string]interface{}{"try_files": tryFiles}},
+					},
+				},
+			},
+			"handle": append([]map[string]interface{}{
+				{
+					"handler": "rewrite",
+					"uri":     site.NotFound,
+				},
+			}, serve(notFound)...),
+		})
+	}
+	return append(routes, map[string]interface{}{
+		"handle": serve(fileServer()),
+	})
+}
+
+// AddStaticRoute adds a static file serving route for a domain, setting
+// headers on every response
+func (c *Client) AddStaticRoute(domain, rootDir string, seo SEO, headers map[string]string, snippets Snippets, site StaticSite) error {
 	routeID := "static-" + domain
+	snippets.Gzip = false // The file server compresses already
 
 	// Remove existing route with same ID first
 	c.RemoveRoute(routeID)
 
-	// Build static file server route with SPA support
 	routeConfig := map[string]interface{}{
 		"@id": routeID,
 		"match": []map[string]interface{}{
 			{"host": []string{domain}},
 		},
 		"terminal": true,
-		"handle": []map[string]interface{}{
+		"handle": withHeaders(headers, withSEO(seo, withSnippets(snippets, []map[string]interface{}{
 			{
 				"handler": "subroute",
-				"routes": []map[string]interface{}{
-					{
-						"handle": []map[string]interface{}{
-							{
-								"handler": "vars",
-								"root":    rootDir,
-							},
-						},
-					},
-					{
-						"match": []map[string]interface{}{
-							{
-								"file": map[string]interface{}{
-									"try_files": []string{"{http.request.uri.path}", "{http.request.uri.path}/index.html", "/index.html"},
-								},
-							},
-						},
-						"handle": []map[string]interface{}{
-							{
-								"handler": "rewrite",
-								"uri":     "{http.matchers.file.relative}",
-							},
-						},
-					},
-					{
-						"handle": []map[string]interface{}{
-							{
-								"handler": "encode",
-								"encodings": map[string]interface{}{
-									"gzip": map[string]interface{}{},
-								},
-								"prefer": []string{"gzip"},
-							},
-							{
-								"handler": "file_server",
-								"hide":    []string{"./Caddyfile"},
-							},
-						},
-					},
-				},
+				"routes":  fileRoutes(rootDir, site),
 			},
-		},
+		}))),
 	}
 
 	body, err := json.Marshal(routeConfig)
@@ -452,7 +961,7 @@ func (c *Client) AddStaticRoute(domain, rootDir string) error {
 	}
 
 	// Add to main server routes - prepend to take priority
-	url := c.adminURL + "/config/apps/http/servers/srv0/routes/0"
+	url := c.insertURL(routeID)
 	req, err := http.NewRequest("PUT", url, bytes.NewReader(body))
 	if err != nil {
 		return err
@@ -529,16 +1038,217 @@ func (c *Client) EnsureBaseConfig(apiPort int, domain string) error {
 	return nil
 }
 
-// EnableAccessLog enables Caddy access logging on the HTTP server.
-// Logs go to Caddy's stderr (captured by launchd to caddy.err).
-func (c *Client) EnableAccessLog() error {
-	// Enable access logging on the HTTP server (uses default logger which goes to stderr)
+// WildcardTLS is a certificate for a root domain and all its subdomains,
+// obtained with an ACME DNS-01 challenge
+type WildcardTLS struct {
+	Domain          string // Root domain; the certificate covers it and *.Domain
+	Email           string // ACME account email
+	DNSProvider     string // cloudflare, hetzner or route53
+	APIToken        string // Cloudflare or Hetzner DNS API token
+	AccessKeyID     string // Route53
+	SecretAccessKey string // Route53
+}
+
+// wildcardPolicyID is the @id of the wildcard certificate's automation policy
+const wildcardPolicyID = "basepod-wildcard-tls"
+
+// dnsChallengeProvider is the caddy-dns provider config for w
+func dnsChallengeProvider(w WildcardTLS) (map[string]interface{}, error) {
+	switch w.DNSProvider {
+	case "cloudflare", "hetzner":
+		if w.APIToken == "" {
+			return nil, fmt.Errorf("%s: api_token is required", w.DNSProvider)
+		}
+		return map[string]interface{}{"name": w.DNSProvider, "api_token": w.APIToken}, nil
+	case "route53":
+		if w.AccessKeyID == "" || w.SecretAccessKey == "" {
+			return nil, fmt.Errorf("route53: access_key_id and secret_access_key are required")
+		}
+		return map[string]interface{}{"name": "route53", "access_key_id": w.AccessKeyID, "secret_access_key": w.SecretAccessKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown DNS provider %q (cloudflare, hetzner or route53)", w.DNSProvider)
+	}
+}
+
+// wildcardTLSConfig adds the wildcard certificate's automation policy to a
+// TLS app config, replacing one added before, and has Caddy manage the
+// certificate up front rather than on the first request
+func wildcardTLSConfig(tlsApp map[string]interface{}, w WildcardTLS) (map[string]interface{}, error) {
+	provider, err := dnsChallengeProvider(w)
+	if err != nil {
+		return nil, err
+	}
+	subjects := []string{"*." + w.Domain, w.Domain}
+	issuer := map[string]interface{}{
+		"module":     "acme",
+		"challenges": map[string]interface{}{"dns": map[string]interface{}{"provider": provider}},
+	}
+	if w.Email != "" {
+		issuer["email"] = w.Email
+	}
+	policy := map[string]interface{}{
+		"@id":      wildcardPolicyID,
+		"subjects": subjects,
+		"issuers":  []interface{}{issuer},
+	}
+
+	if tlsApp == nil {
+		tlsApp = map[string]interface{}{}
+	}
+	automation, _ := tlsApp["automation"].(map[string]interface{})
+	if automation == nil {
+		automation = map[string]interface{}{}
+	}
+	// Policies are matched in order, so ours goes before catch-all ones
+	policies := []interface{}{policy}
+	existing, _ := automation["policies"].([]interface{})
+	for _, p := range existing {
+		if m, ok := p.(map[string]interface{}); ok && m["@id"] == wildcardPolicyID {
+			continue
+		}
+		policies = append(policies, p)
+	}
+	automation["policies"] = policies
+	tlsApp["automation"] = automation
+
+	certificates, _ := tlsApp["certificates"].(map[string]interface{})
+	if certificates == nil {
+		certificates = map[string]interface{}{}
+	}
+	automate, _ := certificates["automate"].([]interface{})
+	for _, subject := range subjects {
+		found := false
+		for _, name := range automate {
+			if name == subject {
+				found = true
+				break
+			}
+		}
+		if !found {
+			automate = append(automate, subject)
+		}
+	}
+	certificates["automate"] = automate
+	tlsApp["certificates"] = certificates
+	return tlsApp, nil
+}
+
+// EnableWildcardTLS has Caddy get one certificate for w.Domain and its
+// subdomains with a DNS-01 challenge and serve app subdomains from it
+// instead of getting each its own. The rest of the TLS config, such as the
+// on-demand check, is kept. Caddy must be built with the provider's
+// caddy-dns module.
+func (c *Client) EnableWildcardTLS(w WildcardTLS) error {
+	var tlsApp map[string]interface{}
+	resp, err := c.httpClient.Get(c.adminURL + "/config/apps/tls")
+	if err != nil {
+		return fmt.Errorf("failed to read TLS config: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(&tlsApp)
+	}
+	resp.Body.Close()
+
+	tlsApp, err = wildcardTLSConfig(tlsApp, w)
+	if err != nil {
+		return err
+	}
+	if err := c.postConfig("/config/apps/tls", tlsApp); err != nil {
+		if strings.Contains(err.Error(), "unknown module") {
+			return fmt.Errorf("Caddy lacks the %s DNS module; build it with xcaddy build --with github.com/caddy-dns/%s: %w", w.DNSProvider, w.DNSProvider, err)
+		}
+		return fmt.Errorf("failed to configure wildcard certificate: %w", err)
+	}
+
+	// Serve subdomains from the wildcard certificate (Caddy 2.9+)
+	if err := c.postConfig("/config/apps/http/servers/srv0/automatic_https/prefer_wildcard", true); err != nil {
+		if err := c.postConfig("/config/apps/http/servers/srv0/automatic_https", map[string]interface{}{"prefer_wildcard": true}); err != nil {
+			return fmt.Errorf("failed to prefer the wildcard certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// AccessLogOptions configures where access logs go and which hosts get
+// masked client IPs
+type AccessLogOptions struct {
+	Filename       string   // Access log file; Caddy rolls it
+	RetentionDays  int      // Rolled files older than this are deleted
+	AnonymizeAll   bool     // Mask client IPs for every host
+	AnonymizeHosts []string // Mask client IPs for these hosts only
+}
+
+// anonymizedLogger is the access logger for hosts with masked client IPs
+const anonymizedLogger = "anonymized"
+
+// EnableAccessLog enables Caddy access logging on the HTTP server. Access logs
+// go to opts.Filename; everything else stays on Caddy's stderr (caddy.err).
+func (c *Client) EnableAccessLog(opts AccessLogOptions) error {
+	// Route hosts that need masking to their own logger
 	serverLogs := map[string]interface{}{
 		"default_logger_name": "default",
 	}
+	if !opts.AnonymizeAll && len(opts.AnonymizeHosts) > 0 {
+		names := make(map[string]string, len(opts.AnonymizeHosts))
+		for _, host := range opts.AnonymizeHosts {
+			names[host] = anonymizedLogger
+		}
+		serverLogs["logger_names"] = names
+	}
+	if err := c.postConfig("/config/apps/http/servers/srv0/logs", serverLogs); err != nil {
+		return fmt.Errorf("failed to enable server logging: %w", err)
+	}
+
+	writer := map[string]interface{}{
+		"output":         "file",
+		"filename":       opts.Filename,
+		"roll_size_mb":   10,
+		"roll_keep_days": opts.RetentionDays,
+	}
+	// Masks the last octet of IPv4 and all but the /48 of IPv6 addresses
+	ipMask := map[string]interface{}{"filter": "ip_mask", "ipv4_cidr": 24, "ipv6_cidr": 48}
+	masked := map[string]interface{}{
+		"format": "filter",
+		"wrap":   map[string]interface{}{"format": "json"},
+		"fields": map[string]interface{}{
+			"request>remote_ip":               ipMask,
+			"request>client_ip":               ipMask,
+			"request>headers>X-Forwarded-For": map[string]interface{}{"filter": "delete"},
+			"request>headers>X-Real-Ip":       map[string]interface{}{"filter": "delete"},
+		},
+	}
+	access := map[string]interface{}{
+		"writer":  writer,
+		"encoder": map[string]interface{}{"format": "json"},
+		"include": []string{"http.log.access.default"},
+	}
+	if opts.AnonymizeAll {
+		access["encoder"] = masked
+	}
+	logs := map[string]interface{}{
+		"default": map[string]interface{}{
+			"writer":  map[string]interface{}{"output": "stderr"},
+			"exclude": []string{"http.log.access"},
+		},
+		"access": access,
+	}
+	if !opts.AnonymizeAll && len(opts.AnonymizeHosts) > 0 {
+		logs["access_"+anonymizedLogger] = map[string]interface{}{
+			"writer":  writer,
+			"encoder": masked,
+			"include": []string{"http.log.access." + anonymizedLogger},
+		}
+	}
+	if err := c.postConfig("/config/logging", map[string]interface{}{"logs": logs}); err != nil {
+		return fmt.Errorf("failed to configure access log: %w", err)
+	}
+	return nil
+}
 
-	data, _ := json.Marshal(serverLogs)
-	req, err := http.NewRequest("POST", c.adminURL+"/config/apps/http/servers/srv0/logs", bytes.NewReader(data))
+// postConfig sets the value at a Caddy config path
+func (c *Client) postConfig(path string, value interface{}) error {
+	data, _ := json.Marshal(value)
+	req, err := http.NewRequest("POST", c.adminURL+path, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -546,14 +1256,13 @@ func (c *Client) EnableAccessLog() error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to enable server logging: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to enable server logging (status %d): %s", resp.StatusCode, string(bodyBytes))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
-
 	return nil
 }
@@ -0,0 +1,186 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWildcardTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	w := WildcardTLS{Domain: "example.com", Email: "ops@example.com", DNSProvider: "cloudflare", APIToken: "token"}
+	existing := map[string]interface{}{
+		"automation": map[string]interface{}{
+			"on_demand": map[string]interface{}{"ask": "http://localhost:3000/api/caddy/check"},
+			"policies":  []interface{}{map[string]interface{}{"on_demand": true}},
+		},
+	}
+
+	tlsApp, err := wildcardTLSConfig(existing, w)
+	if err != nil {
+		t.Fatalf("wildcardTLSConfig: %v", err)
+	}
+	// Applying it again replaces the policy instead of adding another
+	if tlsApp, err = wildcardTLSConfig(tlsApp, w); err != nil {
+		t.Fatalf("wildcardTLSConfig again: %v", err)
+	}
+
+	automation := tlsApp["automation"].(map[string]interface{})
+	if automation["on_demand"] == nil {
+		t.Fatal("on-demand check was dropped")
+	}
+	policies := automation["policies"].([]interface{})
+	if len(policies) != 2 {
+		t.Fatalf("got %d policies, want the wildcard one and the existing one", len(policies))
+	}
+	policy := policies[0].(map[string]interface{})
+	if policy["@id"] != wildcardPolicyID {
+		t.Fatalf("first policy = %v, want the wildcard policy", policy)
+	}
+	issuer := policy["issuers"].([]interface{})[0].(map[string]interface{})
+	provider := issuer["challenges"].(map[string]interface{})["dns"].(map[string]interface{})["provider"].(map[string]interface{})
+	if provider["name"] != "cloudflare" || provider["api_token"] != "token" || issuer["email"] != "ops@example.com" {
+		t.Fatalf("issuer = %v", issuer)
+	}
+	automate := tlsApp["certificates"].(map[string]interface{})["automate"].([]interface{})
+	if len(automate) != 2 || automate[0] != "*.example.com" || automate[1] != "example.com" {
+		t.Fatalf("automate = %v", automate)
+	}
+
+	for _, bad := range []WildcardTLS{
+		{Domain: "example.com", DNSProvider: "hetzner"},
+		{Domain: "example.com", DNSProvider: "route53", AccessKeyID: "AKID"},
+		{Domain: "example.com", DNSProvider: "digitalocean", APIToken: "token"},
+	} {
+		if _, err := wildcardTLSConfig(nil, bad); err == nil {
+			t.Fatalf("wildcardTLSConfig(%+v) = nil error", bad)
+		}
+	}
+}
+
+func TestWeightedUpstreams(t *testing.T) {
+	t.Parallel()
+
+	proxy := reverseProxyHandler("localhost:10001", []string{"localhost:10002"})
+	setWeights(proxy, []int{90, 10})
+	policy := proxy["load_balancing"].(map[string]interface{})["selection_policy"].(map[string]interface{})
+	weights, _ := policy["weights"].([]int)
+	if policy["policy"] != "weighted_round_robin" || len(weights) != 2 || weights[0] != 90 || weights[1] != 10 {
+		t.Fatalf("selection_policy = %v, want weighted_round_robin 90/10", policy)
+	}
+
+	// Weights that don't line up with the upstreams leave round robin alone
+	proxy = reverseProxyHandler("localhost:10001", []string{"localhost:10002"})
+	setWeights(proxy, []int{100})
+	policy = proxy["load_balancing"].(map[string]interface{})["selection_policy"].(map[string]interface{})
+	if policy["policy"] != "round_robin" {
+		t.Fatalf("selection_policy = %v, want round_robin", policy)
+	}
+
+	// A single upstream has nothing to split
+	proxy = reverseProxyHandler("localhost:10001", nil)
+	setWeights(proxy, []int{100})
+	if proxy["load_balancing"] != nil {
+		t.Fatalf("load_balancing = %v, want none", proxy["load_balancing"])
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	t.Parallel()
+
+	proxy := map[string]interface{}{"handler": "reverse_proxy"}
+	handlers := withSnippets(Snippets{
+		IPAllowlist: []IPAllow{{Path: "/admin/*", IPs: []string{"10.0.0.0/8"}}},
+		BasicAuth: []BasicAuth{
+			{Path: "/admin/*", Username: "ann", PasswordHash: "$2a$10$a"},
+			{Path: "/admin/*", Username: "bob", PasswordHash: "$2a$10$b"},
+			{Username: "all", PasswordHash: "$2a$10$c"},
+		},
+		Redirects: []Redirect{{From: "/old", To: "/new"}},
+		Headers:   []PathHeaders{{Path: "/assets/*", Set: map[string]string{"Cache-Control": "max-age=60"}}},
+		Gzip:      true,
+	}, []map[string]interface{}{proxy})
+
+	var kinds []string
+	for _, h := range handlers {
+		kind := h["handler"].(string)
+		if kind == "subroute" {
+			inner := h["routes"].([]map[string]interface{})[0]["handle"].([]map[string]interface{})[0]
+			kind += ":" + inner["handler"].(string)
+		}
+		kinds = append(kinds, kind)
+	}
+	want := "[subroute:static_response subroute:authentication authentication subroute:static_response subroute:headers encode reverse_proxy]"
+	if got := fmt.Sprint(kinds); got != want {
+		t.Fatalf("handlers = %s, want %s", got, want)
+	}
+
+	// Both admin accounts share one handler, so either gets in
+	auth := handlers[1]["routes"].([]map[string]interface{})[0]["handle"].([]map[string]interface{})[0]
+	accounts := auth["providers"].(map[string]interface{})["http_basic"].(map[string]interface{})["accounts"].([]map[string]string)
+	if len(accounts) != 2 || accounts[1]["username"] != "bob" {
+		t.Fatalf("admin accounts = %v, want ann and bob", accounts)
+	}
+	redirect := handlers[3]["routes"].([]map[string]interface{})[0]["handle"].([]map[string]interface{})[0]
+	if redirect["status_code"] != "301" || redirect["headers"].(map[string][]string)["Location"][0] != "/new" {
+		t.Fatalf("redirect = %v, want a 301 to /new", redirect)
+	}
+
+	if got := withSnippets(Snippets{}, []map[string]interface{}{proxy}); len(got) != 1 {
+		t.Fatalf("no snippets = %v, want only the proxy", got)
+	}
+}
+
+func TestStaticFileRoutes(t *testing.T) {
+	t.Parallel()
+
+	tryFiles := func(route map[string]interface{}) []string {
+		match := route["match"].([]map[string]interface{})[0]
+		return match["file"].(map[string]interface{})["try_files"].([]string)
+	}
+
+	// SPAs fall back to /index.html
+	routes := fileRoutes("/srv/site", StaticSite{SPA: true})
+	if len(routes) != 3 {
+		t.Fatalf("SPA routes = %d, want vars, rewrite and file server", len(routes))
+	}
+	if files := tryFiles(routes[1]); files[len(files)-1] != "/index.html" {
+		t.Fatalf("SPA try_files = %v, want /index.html last", files)
+	}
+
+	// Other sites serve their 404 page with a 404 for unknown paths
+	routes = fileRoutes("/srv/site", StaticSite{NotFound: "/404.html"})
+	if len(routes) != 4 {
+		t.Fatalf("404 page routes = %d, want 4", len(routes))
+	}
+	if files := fmt.Sprint(tryFiles(routes[1])); files != "[{http.request.uri.path} {http.request.uri.path}/index.html]" {
+		t.Fatalf("try_files = %s, want no index.html fallback", files)
+	}
+	handle := routes[2]["handle"].([]map[string]interface{})
+	if handle[0]["uri"] != "/404.html" || handle[3]["status_code"] != "404" {
+		t.Fatalf("not found handlers = %v, want /404.html served with a 404", handle)
+	}
+
+	// Pages are always revalidated
+	body, _ := json.Marshal(routes[3])
+	if !strings.Contains(string(body), `"Cache-Control":["no-cache"]`) || !strings.Contains(string(body), `"Content-Type":["text/html*"]`) {
+		t.Fatalf("file server route = %s, want no-cache on HTML", body)
+	}
+
+	// Without either, unknown paths get the file server's own 404
+	if routes = fileRoutes("/srv/site", StaticSite{}); len(routes) != 3 {
+		t.Fatalf("plain routes = %d, want 3", len(routes))
+	}
+	if _, ok := routes[2]["handle"].([]map[string]interface{})[2]["precompressed"]; ok {
+		t.Fatal("file server should only look for precompressed copies when asked to")
+	}
+
+	// Precompressed copies are preferred brotli first
+	routes = fileRoutes("/srv/site", StaticSite{SPA: true, Precompressed: true})
+	fs := routes[2]["handle"].([]map[string]interface{})[2]
+	if order := fmt.Sprint(fs["precompressed_order"]); order != "[br gzip]" || fs["precompressed"] == nil {
+		t.Fatalf("file server = %v, want br and gzip copies", fs)
+	}
+}
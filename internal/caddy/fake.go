@@ -0,0 +1,265 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeAdmin is an in-memory stand-in for Caddy's admin API, for tests and
+// for running basepod without Caddy (basepod --dev --fake-runtime). It keeps
+// the JSON config and supports what Client uses: GET, POST, PUT, PATCH and
+// DELETE under /config/ and /id/. Nothing is proxied.
+type FakeAdmin struct {
+	mu     sync.Mutex
+	config interface{}
+}
+
+// NewFakeAdmin returns a fake admin API with an empty config
+func NewFakeAdmin() *FakeAdmin {
+	return &FakeAdmin{config: map[string]interface{}{}}
+}
+
+// StartFake serves a fake admin API on a free local port and returns a
+// Client for it, and a function that stops it
+func StartFake() (*Client, *FakeAdmin, func(), error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to listen for fake Caddy: %w", err)
+	}
+	admin := NewFakeAdmin()
+	srv := &http.Server{Handler: admin}
+	go srv.Serve(ln)
+	return NewClient("http://" + ln.Addr().String()), admin, func() { srv.Close() }, nil
+}
+
+// Config returns a copy of the current config
+func (a *FakeAdmin) Config() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	data, _ := json.Marshal(a.config)
+	var cfg map[string]interface{}
+	json.Unmarshal(data, &cfg)
+	return cfg
+}
+
+// fakeError is an admin API error with its HTTP status
+type fakeError struct {
+	status int
+	msg    string
+}
+
+func (e *fakeError) Error() string { return e.msg }
+
+func (a *FakeAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var segs []string
+	switch {
+	case r.URL.Path == "/config" || strings.HasPrefix(r.URL.Path, "/config/"):
+		segs = splitConfigPath(strings.TrimPrefix(r.URL.Path, "/config"))
+	case strings.HasPrefix(r.URL.Path, "/id/"):
+		rest := splitConfigPath(strings.TrimPrefix(r.URL.Path, "/id/"))
+		a.mu.Lock()
+		found, ok := findID(a.config, rest[0], nil)
+		a.mu.Unlock()
+		if !ok {
+			fakeAdminError(w, &fakeError{http.StatusNotFound, "unknown object ID '" + rest[0] + "'"})
+			return
+		}
+		segs = append(found, rest[1:]...)
+	default:
+		fakeAdminError(w, &fakeError{http.StatusNotFound, "not found"})
+		return
+	}
+
+	var body interface{}
+	if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			fakeAdminError(w, &fakeError{http.StatusBadRequest, "decoding request body: " + err.Error()})
+			return
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r.Method == http.MethodGet {
+		value, err := getPath(a.config, segs)
+		if err != nil {
+			fakeAdminError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(value)
+		return
+	}
+	if len(segs) == 0 {
+		if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+			fakeAdminError(w, &fakeError{http.StatusMethodNotAllowed, "method not allowed on the root"})
+			return
+		}
+		a.config = body
+		return
+	}
+	config, err := modifyPath(a.config, segs, r.Method, body)
+	if err != nil {
+		fakeAdminError(w, err)
+		return
+	}
+	a.config = config
+}
+
+func fakeAdminError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if fe, ok := err.(*fakeError); ok {
+		status = fe.status
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func splitConfigPath(p string) []string {
+	var segs []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// findID returns the path to the object whose @id is id
+func findID(node interface{}, id string, path []string) ([]string, bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if n["@id"] == id {
+			return path, true
+		}
+		for k, v := range n {
+			if found, ok := findID(v, id, append(path[:len(path):len(path)], k)); ok {
+				return found, true
+			}
+		}
+	case []interface{}:
+		for i, v := range n {
+			if found, ok := findID(v, id, append(path[:len(path):len(path)], strconv.Itoa(i))); ok {
+				return found, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// child returns the value under key in a map or array
+func child(node interface{}, key string) (interface{}, bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		v, ok := n[key]
+		return v, ok
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return nil, false
+		}
+		return n[i], true
+	}
+	return nil, false
+}
+
+func getPath(node interface{}, segs []string) (interface{}, error) {
+	for _, key := range segs {
+		next, ok := child(node, key)
+		if !ok {
+			return nil, &fakeError{http.StatusNotFound, "invalid traversal path at: " + key}
+		}
+		node = next
+	}
+	return node, nil
+}
+
+// modifyPath applies a POST, PUT, PATCH or DELETE at segs and returns the
+// updated node. Missing objects along the way are created, as they are
+// when Caddy loads a config.
+func modifyPath(node interface{}, segs []string, method string, body interface{}) (interface{}, error) {
+	key := segs[0]
+	if len(segs) > 1 {
+		next, ok := child(node, key)
+		if !ok {
+			if method != http.MethodPost && method != http.MethodPut {
+				return nil, &fakeError{http.StatusNotFound, "invalid traversal path at: " + key}
+			}
+			next = map[string]interface{}{}
+		}
+		updated, err := modifyPath(next, segs[1:], method, body)
+		if err != nil {
+			return nil, err
+		}
+		return setChild(node, key, updated)
+	}
+
+	existing, exists := child(node, key)
+	switch method {
+	case http.MethodPost:
+		// POST to an array appends
+		if arr, ok := existing.([]interface{}); ok {
+			return setChild(node, key, append(arr, body))
+		}
+		return setChild(node, key, body)
+	case http.MethodPut:
+		// PUT to an array index inserts before it
+		if arr, ok := node.([]interface{}); ok {
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i > len(arr) {
+				return nil, &fakeError{http.StatusBadRequest, "array index out of bounds: " + key}
+			}
+			arr = append(arr, nil)
+			copy(arr[i+1:], arr[i:])
+			arr[i] = body
+			return arr, nil
+		}
+		if exists {
+			return nil, &fakeError{http.StatusConflict, "key already exists: " + key}
+		}
+		return setChild(node, key, body)
+	case http.MethodPatch:
+		if !exists {
+			return nil, &fakeError{http.StatusNotFound, "key does not exist: " + key}
+		}
+		return setChild(node, key, body)
+	case http.MethodDelete:
+		if !exists {
+			return nil, &fakeError{http.StatusNotFound, "key does not exist: " + key}
+		}
+		switch n := node.(type) {
+		case map[string]interface{}:
+			delete(n, key)
+			return n, nil
+		case []interface{}:
+			i, _ := strconv.Atoi(key)
+			return append(n[:i:i], n[i+1:]...), nil
+		}
+	}
+	return nil, &fakeError{http.StatusMethodNotAllowed, "method not allowed: " + method}
+}
+
+// setChild sets key in a map or array; nil becomes a new map
+func setChild(node interface{}, key string, value interface{}) (interface{}, error) {
+	switch n := node.(type) {
+	case nil:
+		return map[string]interface{}{key: value}, nil
+	case map[string]interface{}:
+		n[key] = value
+		return n, nil
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return nil, &fakeError{http.StatusBadRequest, "array index out of bounds: " + key}
+		}
+		n[i] = value
+		return n, nil
+	}
+	return nil, &fakeError{http.StatusBadRequest, "cannot traverse into a value at: " + key}
+}
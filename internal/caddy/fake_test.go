@@ -0,0 +1,39 @@
+package caddy
+
+import "testing"
+
+func TestFakeAdminRoutes(t *testing.T) {
+	t.Parallel()
+	client, _, stop, err := StartFake()
+	if err != nil {
+		t.Fatalf("StartFake: %v", err)
+	}
+	defer stop()
+
+	if err := client.EnsureBaseConfig(0, "example.com"); err != nil {
+		t.Fatalf("EnsureBaseConfig: %v", err)
+	}
+	for _, r := range []Route{
+		{ID: "basepod-web", Domain: "web.example.com", Upstream: "localhost:10001"},
+		{ID: "basepod-api", Domain: "api.example.com", Upstream: "localhost:10002"},
+	} {
+		if err := client.AddRoute(r); err != nil {
+			t.Fatalf("AddRoute %s: %v", r.ID, err)
+		}
+	}
+	// Adding a route again replaces it
+	if err := client.AddRoute(Route{ID: "basepod-web", Domain: "web.example.com", Upstream: "localhost:10003"}); err != nil {
+		t.Fatalf("AddRoute again: %v", err)
+	}
+	if err := client.RemoveRoute("basepod-api"); err != nil {
+		t.Fatalf("RemoveRoute: %v", err)
+	}
+
+	routes, err := client.GetRoutes()
+	if err != nil {
+		t.Fatalf("GetRoutes: %v", err)
+	}
+	if len(routes) != 1 || routes[0].ID != "basepod-web" || routes[0].Upstream != "localhost:10003" {
+		t.Fatalf("routes = %+v, want only basepod-web on localhost:10003", routes)
+	}
+}
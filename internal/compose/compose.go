@@ -0,0 +1,467 @@
+// Package compose converts docker-compose files into basepod.yaml services,
+// so existing Compose projects can move over without rewriting their config.
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Project is the basepod.yaml a Compose file converts to. The field names and
+// YAML keys match the services section bp reads.
+type Project struct {
+	Name     string              `yaml:"name" json:"name"`
+	Port     int                 `yaml:"port,omitempty" json:"port,omitempty"` // Container port of the service the app's domain routes to
+	Services map[string]*Service `yaml:"services" json:"services"`
+}
+
+// Service is one basepod.yaml service
+type Service struct {
+	Image     string            `yaml:"image,omitempty" json:"image,omitempty"`
+	Build     Build             `yaml:"build,omitempty" json:"build,omitempty"`
+	Port      int               `yaml:"port,omitempty" json:"port,omitempty"`
+	Command   string            `yaml:"command,omitempty" json:"command,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	EnvFile   []string          `yaml:"env_file,omitempty" json:"env_file,omitempty"`
+	Volumes   []string          `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	DependsOn []string          `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+}
+
+// Build is a service's build section
+type Build struct {
+	Context    string `yaml:"context,omitempty" json:"context,omitempty"`
+	Dockerfile string `yaml:"dockerfile,omitempty" json:"dockerfile,omitempty"`
+}
+
+// composeFile is the part of the Compose spec the converter reads. Fields
+// with several accepted shapes are kept as nodes and decoded by hand.
+type composeFile struct {
+	Name     string    `yaml:"name"`
+	Services yaml.Node `yaml:"services"`
+}
+
+type composeService struct {
+	Image       string    `yaml:"image"`
+	Build       yaml.Node `yaml:"build"`
+	Command     yaml.Node `yaml:"command"`
+	Entrypoint  yaml.Node `yaml:"entrypoint"`
+	Environment yaml.Node `yaml:"environment"`
+	EnvFile     yaml.Node `yaml:"env_file"`
+	Ports       yaml.Node `yaml:"ports"`
+	Expose      []string  `yaml:"expose"`
+	Volumes     yaml.Node `yaml:"volumes"`
+	DependsOn   yaml.Node `yaml:"depends_on"`
+}
+
+// ignoredKeys are Compose service keys basepod has no equivalent for; each
+// one found produces a warning
+var ignoredKeys = []string{"healthcheck", "restart", "networks", "deploy", "profiles", "secrets", "configs", "network_mode", "privileged", "cap_add", "devices", "extra_hosts", "labels", "logging", "user", "working_dir"}
+
+var serviceNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Convert translates a Compose file into a basepod project named name (the
+// Compose name: wins when set). Anything that doesn't carry over is listed
+// in the warnings rather than failing the conversion.
+func Convert(data []byte, name string) (*Project, []string, error) {
+	var file composeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("invalid compose file: %w", err)
+	}
+	if file.Services.Kind != yaml.MappingNode || len(file.Services.Content) == 0 {
+		return nil, nil, fmt.Errorf("compose file has no services")
+	}
+	if file.Name != "" {
+		name = file.Name
+	}
+
+	p := &Project{Name: name, Services: make(map[string]*Service)}
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	var order []string
+	published := make(map[string]bool)
+	renamed := make(map[string]string)
+	nodes := file.Services.Content
+	for i := 0; i+1 < len(nodes); i += 2 {
+		composeName := nodes[i].Value
+		svcName := strings.ToLower(strings.ReplaceAll(composeName, "_", "-"))
+		if !serviceNameRe.MatchString(svcName) {
+			return nil, nil, fmt.Errorf("service %q: name must be letters, digits, - or _", composeName)
+		}
+		if svcName != composeName {
+			renamed[composeName] = svcName
+			warn("%s: renamed to %s", composeName, svcName)
+		}
+
+		var cs composeService
+		if err := nodes[i+1].Decode(&cs); err != nil {
+			return nil, nil, fmt.Errorf("service %s: %w", composeName, err)
+		}
+		for _, key := range ignoredKeys {
+			if hasKey(nodes[i+1], key) {
+				warn("%s: %s is not supported and was dropped", svcName, key)
+			}
+		}
+
+		svc, hostPort, err := convertService(svcName, &cs, warn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %s: %w", composeName, err)
+		}
+		p.Services[svcName] = svc
+		published[svcName] = hostPort
+		order = append(order, svcName)
+	}
+
+	for _, svcName := range order {
+		svc := p.Services[svcName]
+		for i, dep := range svc.DependsOn {
+			if to, ok := renamed[dep]; ok {
+				dep = to
+				svc.DependsOn[i] = dep
+			}
+			if p.Services[dep] == nil {
+				return nil, nil, fmt.Errorf("service %s depends on unknown service %s", svcName, dep)
+			}
+		}
+	}
+
+	// The app's domain goes to the first service published on a host port,
+	// else the first with a port at all
+	for _, svcName := range order {
+		if published[svcName] && p.Services[svcName].Port > 0 {
+			p.Port = p.Services[svcName].Port
+			break
+		}
+	}
+	if p.Port == 0 {
+		for _, svcName := range order {
+			if port := p.Services[svcName].Port; port > 0 {
+				p.Port = port
+				break
+			}
+		}
+	}
+	return p, warnings, nil
+}
+
+// YAML renders the project as basepod.yaml
+func (p *Project) YAML() ([]byte, error) {
+	return yaml.Marshal(p)
+}
+
+func convertService(name string, cs *composeService, warn func(string, ...interface{})) (*Service, bool, error) {
+	svc := &Service{Image: cs.Image}
+
+	switch cs.Build.Kind {
+	case 0:
+	case yaml.ScalarNode:
+		svc.Build.Context = cs.Build.Value
+	case yaml.MappingNode:
+		var b struct {
+			Context    string    `yaml:"context"`
+			Dockerfile string    `yaml:"dockerfile"`
+			Args       yaml.Node `yaml:"args"`
+			Target     string    `yaml:"target"`
+		}
+		if err := cs.Build.Decode(&b); err != nil {
+			return nil, false, fmt.Errorf("build: %w", err)
+		}
+		svc.Build = Build{Context: b.Context, Dockerfile: b.Dockerfile}
+		if svc.Build.Context == "" {
+			svc.Build.Context = "."
+		}
+		if b.Args.Kind != 0 {
+			warn("%s: build args are not supported; put them in the Dockerfile as ARG defaults", name)
+		}
+		if b.Target != "" {
+			warn("%s: build target %s is not supported; the last stage is built", name, b.Target)
+		}
+	default:
+		return nil, false, fmt.Errorf("build must be a path or a mapping")
+	}
+	if svc.Image != "" && svc.Build.Context != "" {
+		warn("%s: has both image and build; basepod uses the image", name)
+		svc.Build = Build{}
+	}
+	if svc.Image == "" && svc.Build.Context == "" {
+		return nil, false, fmt.Errorf("needs an image or a build")
+	}
+
+	command, err := shellCommand(&cs.Command)
+	if err != nil {
+		return nil, false, fmt.Errorf("command: %w", err)
+	}
+	svc.Command = command
+	if cs.Entrypoint.Kind != 0 {
+		entrypoint, err := shellCommand(&cs.Entrypoint)
+		if err != nil {
+			return nil, false, fmt.Errorf("entrypoint: %w", err)
+		}
+		if svc.Command == "" {
+			svc.Command = entrypoint
+		} else {
+			svc.Command = entrypoint + " " + svc.Command
+		}
+		warn("%s: entrypoint was folded into command", name)
+	}
+
+	if svc.Env, err = environment(name, &cs.Environment, warn); err != nil {
+		return nil, false, fmt.Errorf("environment: %w", err)
+	}
+	if svc.EnvFile, err = stringList(&cs.EnvFile, "path"); err != nil {
+		return nil, false, fmt.Errorf("env_file: %w", err)
+	}
+
+	hostPort := false
+	ports, err := portList(&cs.Ports)
+	if err != nil {
+		return nil, false, fmt.Errorf("ports: %w", err)
+	}
+	for i, pm := range ports {
+		if i == 0 {
+			svc.Port = pm.container
+			hostPort = pm.published
+			continue
+		}
+		warn("%s: only one port per service is supported; dropped %d", name, pm.container)
+	}
+	if svc.Port == 0 && len(cs.Expose) > 0 {
+		port, err := strconv.Atoi(strings.Split(cs.Expose[0], "/")[0])
+		if err != nil {
+			return nil, false, fmt.Errorf("expose: invalid port %q", cs.Expose[0])
+		}
+		svc.Port = port
+	}
+
+	if svc.Volumes, err = volumeList(name, &cs.Volumes, warn); err != nil {
+		return nil, false, fmt.Errorf("volumes: %w", err)
+	}
+
+	switch cs.DependsOn.Kind {
+	case 0:
+	case yaml.SequenceNode:
+		if err := cs.DependsOn.Decode(&svc.DependsOn); err != nil {
+			return nil, false, fmt.Errorf("depends_on: %w", err)
+		}
+	case yaml.MappingNode:
+		// Long form: service -> {condition: ...}; basepod always waits for
+		// a dependency to accept connections
+		for i := 0; i+1 < len(cs.DependsOn.Content); i += 2 {
+			svc.DependsOn = append(svc.DependsOn, cs.DependsOn.Content[i].Value)
+		}
+	default:
+		return nil, false, fmt.Errorf("depends_on must be a list or a mapping")
+	}
+	return svc, hostPort, nil
+}
+
+// hasKey reports whether a mapping node has key
+func hasKey(node *yaml.Node, key string) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// shellCommand turns a Compose command (a string, or an exec-form list) into
+// the shell command basepod runs with sh -c
+func shellCommand(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case 0:
+		return "", nil
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		var args []string
+		if err := node.Decode(&args); err != nil {
+			return "", err
+		}
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = shellQuote(arg)
+		}
+		return strings.Join(quoted, " "), nil
+	}
+	return "", fmt.Errorf("must be a string or a list")
+}
+
+// shellQuote quotes s for sh when it has anything but safe characters
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// environment reads a map or KEY=VALUE list. Variables without a value
+// come from the shell in Compose and are dropped with a warning.
+func environment(name string, node *yaml.Node, warn func(string, ...interface{})) (map[string]string, error) {
+	env := make(map[string]string)
+	var unset []string
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i].Value, node.Content[i+1]
+			if val.Tag == "!!null" {
+				unset = append(unset, key)
+				continue
+			}
+			env[key] = val.Value
+		}
+	case yaml.SequenceNode:
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			key, val, ok := strings.Cut(item, "=")
+			if !ok {
+				unset = append(unset, key)
+				continue
+			}
+			env[key] = val
+		}
+	default:
+		return nil, fmt.Errorf("must be a mapping or a list")
+	}
+	if len(unset) > 0 {
+		sort.Strings(unset)
+		warn("%s: %s take their value from the shell in Compose; set them in an env file", name, strings.Join(unset, ", "))
+	}
+	if len(env) == 0 {
+		return nil, nil
+	}
+	return env, nil
+}
+
+// stringList reads a string or a list of strings. List items may also be
+// mappings, in which case field is read from each.
+func stringList(node *yaml.Node, field string) ([]string, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		return []string{node.Value}, nil
+	case yaml.SequenceNode:
+		var out []string
+		for _, item := range node.Content {
+			if item.Kind == yaml.MappingNode {
+				var m map[string]interface{}
+				if err := item.Decode(&m); err != nil {
+					return nil, err
+				}
+				if v, ok := m[field].(string); ok {
+					out = append(out, v)
+				}
+				continue
+			}
+			out = append(out, item.Value)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("must be a string or a list")
+}
+
+type portMapping struct {
+	container int
+	published bool
+}
+
+// portList reads short ("8080:80", "127.0.0.1:8080:80/tcp", "3000") and
+// long ({target: 80, published: 8080}) port entries
+func portList(node *yaml.Node) ([]portMapping, error) {
+	if node.Kind == 0 {
+		return nil, nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("must be a list")
+	}
+	var ports []portMapping
+	for _, item := range node.Content {
+		if item.Kind == yaml.MappingNode {
+			var long struct {
+				Target    int    `yaml:"target"`
+				Published string `yaml:"published"`
+			}
+			if err := item.Decode(&long); err != nil {
+				return nil, err
+			}
+			if long.Target == 0 {
+				return nil, fmt.Errorf("port entry without target")
+			}
+			ports = append(ports, portMapping{container: long.Target, published: long.Published != ""})
+			continue
+		}
+		spec := strings.Split(item.Value, "/")[0]
+		parts := strings.Split(spec, ":")
+		container := parts[len(parts)-1]
+		container = strings.Split(container, "-")[0] // first of a range
+		port, err := strconv.Atoi(container)
+		if err != nil || port <= 0 {
+			return nil, fmt.Errorf("invalid port %q", item.Value)
+		}
+		ports = append(ports, portMapping{container: port, published: len(parts) > 1})
+	}
+	return ports, nil
+}
+
+// volumeList reads short ("data:/var/lib/x:ro") and long volume entries
+// into the source:target[:ro] form bp passes to podman -v
+func volumeList(name string, node *yaml.Node, warn func(string, ...interface{})) ([]string, error) {
+	if node.Kind == 0 {
+		return nil, nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("must be a list")
+	}
+	var volumes []string
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			if !strings.Contains(item.Value, ":") {
+				warn("%s: anonymous volume %s was dropped; give it a name to keep its data", name, item.Value)
+				continue
+			}
+			volumes = append(volumes, item.Value)
+			continue
+		}
+		var long struct {
+			Type     string `yaml:"type"`
+			Source   string `yaml:"source"`
+			Target   string `yaml:"target"`
+			ReadOnly bool   `yaml:"read_only"`
+		}
+		if err := item.Decode(&long); err != nil {
+			return nil, err
+		}
+		if long.Type != "" && long.Type != "volume" && long.Type != "bind" {
+			warn("%s: %s mount at %s is not supported and was dropped", name, long.Type, long.Target)
+			continue
+		}
+		if long.Source == "" {
+			warn("%s: anonymous volume %s was dropped; give it a name to keep its data", name, long.Target)
+			continue
+		}
+		vol := long.Source + ":" + long.Target
+		if long.ReadOnly {
+			vol += ":ro"
+		}
+		volumes = append(volumes, vol)
+	}
+	return volumes, nil
+}
@@ -0,0 +1,114 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleCompose = `
+services:
+  web:
+    build:
+      context: ./web
+      dockerfile: Dockerfile.prod
+    command: ["npm", "run", "start:prod", "--", "--title", "it's live"]
+    ports:
+      - "8080:3000"
+    environment:
+      NODE_ENV: production
+      API_KEY:
+    env_file: .env
+    depends_on:
+      db:
+        condition: service_healthy
+      cache_store:
+        condition: service_started
+    healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost:3000"]
+  db:
+    image: postgres:16
+    environment:
+      - POSTGRES_PASSWORD=secret
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+      - type: tmpfs
+        target: /tmp
+    expose:
+      - "5432"
+  cache_store:
+    image: redis:7
+    ports:
+      - 6379
+volumes:
+  pgdata:
+`
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	p, warnings, err := Convert([]byte(sampleCompose), "shop")
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if p.Name != "shop" || p.Port != 3000 {
+		t.Fatalf("project = %s port %d, want shop port 3000 (the published web service)", p.Name, p.Port)
+	}
+	if len(p.Services) != 3 {
+		t.Fatalf("services = %v, want web, db and cache-store", p.Services)
+	}
+
+	web := p.Services["web"]
+	if web.Build.Context != "./web" || web.Build.Dockerfile != "Dockerfile.prod" || web.Port != 3000 {
+		t.Fatalf("web = %+v", web)
+	}
+	if want := `npm run start:prod -- --title 'it'\''s live'`; web.Command != want {
+		t.Fatalf("web command = %q, want %q", web.Command, want)
+	}
+	if web.Env["NODE_ENV"] != "production" || len(web.Env) != 1 {
+		t.Fatalf("web env = %v, want only NODE_ENV", web.Env)
+	}
+	if strings.Join(web.DependsOn, ",") != "db,cache-store" {
+		t.Fatalf("web depends_on = %v, want db and the renamed cache-store", web.DependsOn)
+	}
+	if len(web.EnvFile) != 1 || web.EnvFile[0] != ".env" {
+		t.Fatalf("web env_file = %v", web.EnvFile)
+	}
+
+	db := p.Services["db"]
+	if db.Image != "postgres:16" || db.Port != 5432 || db.Env["POSTGRES_PASSWORD"] != "secret" {
+		t.Fatalf("db = %+v", db)
+	}
+	if len(db.Volumes) != 1 || db.Volumes[0] != "pgdata:/var/lib/postgresql/data" {
+		t.Fatalf("db volumes = %v, want the named volume only", db.Volumes)
+	}
+	if p.Services["cache-store"].Port != 6379 {
+		t.Fatalf("cache-store = %+v", p.Services["cache-store"])
+	}
+
+	joined := strings.Join(warnings, "\n")
+	for _, want := range []string{"API_KEY", "healthcheck", "tmpfs", "renamed to cache-store"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("warnings = %q, missing %s", warnings, want)
+		}
+	}
+
+	out, err := p.YAML()
+	if err != nil || !strings.Contains(string(out), "depends_on:") {
+		t.Fatalf("YAML() = %s, %v", out, err)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"no services":     "version: '3'\n",
+		"no image":        "services:\n  web:\n    ports: ['80']\n",
+		"unknown dep":     "services:\n  web:\n    image: nginx\n    depends_on: [db]\n",
+		"bad port":        "services:\n  web:\n    image: nginx\n    ports: ['http']\n",
+		"not yaml at all": "services: [\n",
+	}
+	for name, doc := range cases {
+		if _, _, err := Convert([]byte(doc), "app"); err == nil {
+			t.Fatalf("%s: want an error", name)
+		}
+	}
+}
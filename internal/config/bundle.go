@@ -0,0 +1,61 @@
+package config
+
+// Sanitized returns a copy of the config with secrets and machine-specific
+// settings stripped, suitable for `basepod config export`
+func (c *Config) Sanitized() *Config {
+	out := *c
+	out.Auth.PasswordHash = ""
+	out.AI.HuggingFaceToken = ""
+	out.Email.PostmarkToken = ""
+	out.Email.ResendKey = ""
+	out.Email.SMTPPassword = ""
+	out.DNSProvider.APIToken = ""
+	out.DNSProvider.SecretAccessKey = ""
+	out.Domain.WildcardTLS.APIToken = ""
+	out.Domain.WildcardTLS.SecretAccessKey = ""
+	out.Podman.SocketPath = ""
+	out.WebUI.Path = ""
+	out.Database.Path = ""
+	if c.DNS.Upstream != nil {
+		out.DNS.Upstream = append([]string(nil), c.DNS.Upstream...)
+	}
+	if c.Backup.Targets != nil {
+		out.Backup.Targets = make([]BackupTarget, len(c.Backup.Targets))
+		for i, t := range c.Backup.Targets {
+			t.AccessKeyID = ""
+			t.SecretAccessKey = ""
+			t.KeyFile = ""
+			out.Backup.Targets[i] = t
+		}
+	}
+	return &out
+}
+
+// MergeImported applies an exported config on top of c. Secrets and
+// machine-specific settings already set on this server are kept.
+func (c *Config) MergeImported(in *Config) {
+	local := *c
+	*c = *in.Sanitized()
+
+	c.Auth.PasswordHash = local.Auth.PasswordHash
+	c.AI.HuggingFaceToken = local.AI.HuggingFaceToken
+	c.Email.PostmarkToken = local.Email.PostmarkToken
+	c.Email.ResendKey = local.Email.ResendKey
+	c.Email.SMTPPassword = local.Email.SMTPPassword
+	c.DNSProvider.APIToken = local.DNSProvider.APIToken
+	c.DNSProvider.SecretAccessKey = local.DNSProvider.SecretAccessKey
+	c.Domain.WildcardTLS.APIToken = local.Domain.WildcardTLS.APIToken
+	c.Domain.WildcardTLS.SecretAccessKey = local.Domain.WildcardTLS.SecretAccessKey
+	c.Podman.SocketPath = local.Podman.SocketPath
+	c.WebUI.Path = local.WebUI.Path
+	c.WebUI.HiddenFeatures = local.WebUI.HiddenFeatures
+	c.Branding.Logo = local.Branding.Logo
+	c.Database.Path = local.Database.Path
+	for i, t := range c.Backup.Targets {
+		if lt, ok := local.Backup.Target(t.Name); ok && t.Name != "" {
+			c.Backup.Targets[i].AccessKeyID = lt.AccessKeyID
+			c.Backup.Targets[i].SecretAccessKey = lt.SecretAccessKey
+			c.Backup.Targets[i].KeyFile = lt.KeyFile
+		}
+	}
+}
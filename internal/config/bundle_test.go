@@ -0,0 +1,65 @@
+package config
+
+import "testing"
+
+func TestSanitizedStripsSecrets(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.Domain.Root = "example.com"
+	cfg.Auth.PasswordHash = "hash"
+	cfg.AI.HuggingFaceToken = "hf_token"
+	cfg.Email.ResendKey = "re_key"
+	cfg.Email.SMTPPassword = "smtp-pass"
+	cfg.DNSProvider.APIToken = "cf-token"
+	cfg.Domain.WildcardTLS.SecretAccessKey = "aws-secret"
+	cfg.Backup.Targets = []BackupTarget{{Name: "offsite", Type: "s3", Bucket: "backups", SecretAccessKey: "s3-secret"}}
+
+	out := cfg.Sanitized()
+	if out.Auth.PasswordHash != "" || out.AI.HuggingFaceToken != "" || out.Email.ResendKey != "" || out.Email.SMTPPassword != "" ||
+		out.DNSProvider.APIToken != "" || out.Domain.WildcardTLS.SecretAccessKey != "" {
+		t.Fatalf("expected secrets to be stripped, got %+v", out)
+	}
+	if out.Domain.Root != "example.com" {
+		t.Fatalf("expected domain to be kept, got %q", out.Domain.Root)
+	}
+	if out.Backup.Targets[0].SecretAccessKey != "" || out.Backup.Targets[0].Bucket != "backups" {
+		t.Fatalf("expected backup target without its secret, got %+v", out.Backup.Targets[0])
+	}
+	if cfg.Auth.PasswordHash != "hash" || cfg.Backup.Targets[0].SecretAccessKey != "s3-secret" {
+		t.Fatal("expected original config to be left untouched")
+	}
+}
+
+func TestMergeImportedKeepsLocalSecrets(t *testing.T) {
+	t.Parallel()
+
+	local := DefaultConfig()
+	local.Auth.PasswordHash = "local-hash"
+	local.Podman.SocketPath = "/run/podman/podman.sock"
+	local.WebUI.HiddenFeatures = []string{"models"}
+	local.Backup.Targets = []BackupTarget{{Name: "offsite", Type: "s3", SecretAccessKey: "s3-secret"}}
+
+	exported := DefaultConfig()
+	exported.Domain.Root = "example.com"
+	exported.Domain.Email = "ops@example.com"
+	exported.Podman.SocketPath = "/other/socket"
+	exported.Backup.Targets = []BackupTarget{{Name: "offsite", Type: "s3", Bucket: "new-bucket"}}
+
+	local.MergeImported(exported)
+	if local.Domain.Root != "example.com" || local.Domain.Email != "ops@example.com" {
+		t.Fatalf("expected exported domain settings, got %+v", local.Domain)
+	}
+	if local.Auth.PasswordHash != "local-hash" {
+		t.Fatalf("expected local password hash to be kept, got %q", local.Auth.PasswordHash)
+	}
+	if local.Podman.SocketPath != "/run/podman/podman.sock" {
+		t.Fatalf("expected local podman socket to be kept, got %q", local.Podman.SocketPath)
+	}
+	if len(local.WebUI.HiddenFeatures) != 1 || local.WebUI.HiddenFeatures[0] != "models" {
+		t.Fatalf("expected local hidden features to be kept, got %v", local.WebUI.HiddenFeatures)
+	}
+	if target := local.Backup.Targets[0]; target.Bucket != "new-bucket" || target.SecretAccessKey != "s3-secret" {
+		t.Fatalf("expected the exported backup target with the local secret, got %+v", target)
+	}
+}
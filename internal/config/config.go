@@ -36,20 +36,215 @@ type Config struct {
 	// DNS settings
 	DNS DNSConfig `yaml:"dns"`
 
+	// External DNS provider for app domain records
+	DNSProvider DNSProviderConfig `yaml:"dns_provider"`
+
 	// AI settings (HuggingFace, etc.)
 	AI AIConfig `yaml:"ai"`
 
-	// Email settings (for invite emails)
+	// Email settings (for invite emails and digests)
 	Email EmailConfig `yaml:"email"`
 
 	// Construct integration (OAuth-based deploy for Construct users)
 	Construct ConstructConfig `yaml:"construct"`
 
+	// MCP server settings (Model Context Protocol for external agents)
+	MCP MCPConfig `yaml:"mcp"`
+
+	// Scheduled maintenance window
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+
+	// Weekly email digest
+	Digest DigestConfig `yaml:"digest"`
+
+	// Daemon self-monitoring
+	Watchdog WatchdogConfig `yaml:"watchdog"`
+
+	// Profiling endpoints
+	Debug DebugConfig `yaml:"debug"`
+
+	// Access log privacy
+	Privacy PrivacyConfig `yaml:"privacy"`
+
+	// White-label branding for the web UI, emails and notifications
+	Branding BrandingConfig `yaml:"branding"`
+
+	// Presets for generated Dockerfiles
+	Build BuildConfig `yaml:"build"`
+
+	// Remote template catalog
+	Templates TemplatesConfig `yaml:"templates"`
+
+	// Container resource metrics
+	Metrics MetricsConfig `yaml:"metrics"`
+
+	// Off-server backup copies
+	Backup BackupConfig `yaml:"backup"`
+
+	// Probes of each app's public URL
+	Uptime UptimeConfig `yaml:"uptime"`
+}
+
+// BackupConfig lists the places backups are uploaded to, so a server can be
+// rebuilt from them after losing its disk
+type BackupConfig struct {
+	Targets []BackupTarget `yaml:"targets"`
+}
+
+// BackupTarget is an S3-compatible bucket, an SFTP server or an rclone
+// remote. Backups are stored under Path as basepod-backup-<id>.tar.gz.
+type BackupTarget struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"` // s3, sftp or rclone
+	Path string `yaml:"path" json:"path"` // Key prefix or directory (default: the bucket or login directory)
+
+	// S3-compatible storage (AWS, R2, B2, MinIO...), addressed path-style
+	Endpoint        string `yaml:"endpoint" json:"endpoint,omitempty"` // e.g. https://<account>.r2.cloudflarestorage.com (default: AWS in Region)
+	Region          string `yaml:"region" json:"region,omitempty"`     // Signing region (default: us-east-1)
+	Bucket          string `yaml:"bucket" json:"bucket,omitempty"`
+	AccessKeyID     string `yaml:"access_key_id" json:"-"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"-"`
+
+	// SFTP, with the system's sftp client and a key
+	Host    string `yaml:"host" json:"host,omitempty"`
+	Port    int    `yaml:"port" json:"port,omitempty"` // Default: 22
+	User    string `yaml:"user" json:"user,omitempty"`
+	KeyFile string `yaml:"key_file" json:"-"` // Private key (default: the ssh client's)
+
+	// rclone, with a remote set up by `rclone config`
+	Remote string `yaml:"remote" json:"remote,omitempty"` // e.g. b2:my-bucket
+}
+
+// Target returns the backup target named name, or the first one when
+// name is empty
+func (b BackupConfig) Target(name string) (BackupTarget, bool) {
+	for _, t := range b.Targets {
+		if name == "" || t.Name == name {
+			return t, true
+		}
+	}
+	return BackupTarget{}, false
+}
+
+// MetricsConfig controls how often app containers' resource use is sampled
+// and how long the samples are kept
+type MetricsConfig struct {
+	Interval      int `yaml:"interval"`       // Seconds between samples (default: 30)
+	RetentionDays int `yaml:"retention_days"` // Days of samples kept (default: 7)
+}
+
+// UptimeConfig controls how often each app's public URL is probed, how long
+// the results are kept and when an app counts as down
+type UptimeConfig struct {
+	Disabled      bool `yaml:"disabled"`       // Don't probe apps
+	Interval      int  `yaml:"interval"`       // Seconds between probes (default: 60)
+	RetentionDays int  `yaml:"retention_days"` // Days of results kept (default: 30)
+	Failures      int  `yaml:"failures"`       // Failed probes in a row before alerting (default: 2)
+}
+
+// TemplatesConfig points at a remote catalog with recommended versions,
+// ratings and migration notes for the built-in templates
+type TemplatesConfig struct {
+	CatalogURL string `yaml:"catalog_url"` // JSON catalog, synced every few hours (empty: built-in versions only)
+}
+
+// BuildConfig holds the org-wide presets Dockerfiles are generated from when
+// an app doesn't have one, and how many builds may run at once
+type BuildConfig struct {
+	DefaultPreset string        `yaml:"default_preset"` // Preset used when none is chosen (default: the built-in images)
+	Presets       []BuildPreset `yaml:"presets"`
+	MaxParallel   int           `yaml:"max_parallel"` // Image builds run at once; more wait in a queue (default: 2)
+}
+
+// BuildPreset replaces the base images, or whole Dockerfile templates, of
+// generated Dockerfiles per stack (node, go, python, pyproject, ruby, rust)
+type BuildPreset struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description string            `yaml:"description" json:"description,omitempty"`
+	Images      map[string]string `yaml:"images" json:"images,omitempty"`           // Image key → base image, e.g. node: registry.example.com/node:20-hardened
+	Dockerfiles map[string]string `yaml:"dockerfiles" json:"dockerfiles,omitempty"` // Stack → Go template of the whole Dockerfile
+}
+
+// Preset returns the named preset, or nil if there is none
+func (b BuildConfig) Preset(name string) *BuildPreset {
+	for i := range b.Presets {
+		if b.Presets[i].Name == name {
+			return &b.Presets[i]
+		}
+	}
+	return nil
+}
+
+// BrandingConfig replaces Basepod's name and look for hosted dashboards
+type BrandingConfig struct {
+	Name        string `yaml:"name"`         // Product name (default: Basepod)
+	AccentColor string `yaml:"accent_color"` // Hex color like #3b82f6 for buttons and links
+	SupportURL  string `yaml:"support_url"`  // Where users go for help (https: or mailto: link)
+	Logo        string `yaml:"logo"`         // Uploaded logo file in the data directory (set via the API)
+}
+
+// ProductName returns the configured product name or Basepod
+func (b BrandingConfig) ProductName() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return "Basepod"
+}
+
+// PrivacyConfig controls what access logs keep about visitors
+type PrivacyConfig struct {
+	AnonymizeIPs           bool `yaml:"anonymize_ips"`             // Mask client IPs for all apps (IPv4 /24, IPv6 /48); apps can override
+	AccessLogRetentionDays int  `yaml:"access_log_retention_days"` // Delete access logs older than this (default: 30)
+}
+
+// DebugConfig controls the profiling endpoints (/debug/pprof and
+// /api/debug/goroutines). They are off by default and admin-only when on.
+type DebugConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// WatchdogConfig holds soft limits for the daemon and AI subprocess supervision.
+// Exceeding a limit logs a warning; it never stops the daemon.
+type WatchdogConfig struct {
+	MemoryMB         int    `yaml:"memory_mb"`          // Warn when memory obtained from the OS exceeds this (default: 1024)
+	Goroutines       int    `yaml:"goroutines"`         // Warn above this many goroutines (default: 10000)
+	OpenFiles        int    `yaml:"open_files"`         // Warn above this many open files (default: 80% of the process limit)
+	AIHangTimeout    string `yaml:"ai_hang_timeout"`    // Restart an AI server that hasn't answered for this long (default: 2m)
+	DisableAIRestart bool   `yaml:"disable_ai_restart"` // Only report unresponsive AI servers
+}
+
+// MaintenanceConfig holds the weekly maintenance window settings
+type MaintenanceConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Day      string   `yaml:"day"`      // Weekday the window opens (default: sunday)
+	Start    string   `yaml:"start"`    // Local time the window opens, HH:MM (default: 03:00)
+	Duration string   `yaml:"duration"` // Window length (default: 1h)
+	Tasks    []string `yaml:"tasks"`    // Subset of image_updates, prune, backup_verify, self_update (default: all)
+}
+
+// DigestConfig holds the weekly email digest schedule. Users subscribe
+// themselves; the digest goes out once a week while it is enabled.
+type DigestConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Day     string `yaml:"day"`   // Weekday it is sent (default: monday)
+	Start   string `yaml:"start"` // Local time it is sent, HH:MM (default: 08:00)
+}
+
+// MCPConfig holds Model Context Protocol server settings
+type MCPConfig struct {
+	Disabled bool `yaml:"disabled"`  // Turn off the /api/mcp endpoint entirely
+	ReadOnly bool `yaml:"read_only"` // Only expose tools that don't mutate state
 }
 
 // AIConfig holds AI-related configuration
 type AIConfig struct {
-	HuggingFaceToken string `yaml:"huggingface_token"` // HuggingFace API token for gated models
+	HuggingFaceToken   string `yaml:"huggingface_token"`    // HuggingFace API token for gated models
+	EmbeddingModel     string `yaml:"embedding_model"`      // MLX embedding model for /api/ai/embeddings and search
+	IndexLogs          bool   `yaml:"index_logs"`           // Opt-in: periodically embed app logs for /api/ai/search
+	IndexRetentionDays int    `yaml:"index_retention_days"` // How long indexed log chunks are kept (default: 14)
+	EvictionPolicy     string `yaml:"eviction_policy"`      // "none" (refuse jobs that don't fit in memory) or "evict" (stop the chat model while they run, once it is idle)
+	MaxConcurrentJobs  int    `yaml:"max_concurrent_jobs"`  // AI jobs run at once across all classes (default: 1)
+	MaxBackgroundJobs  int    `yaml:"max_background_jobs"`  // Of those, how many may be background jobs like log indexing (default: 1)
 }
 
 // ConstructConfig holds Construct OAuth integration settings
@@ -59,12 +254,16 @@ type ConstructConfig struct {
 	Enabled      bool   `yaml:"enabled"`       // Enable Construct OAuth deploy
 }
 
-// EmailConfig holds email provider configuration for invite emails
+// EmailConfig holds email provider configuration for invite emails and digests
 type EmailConfig struct {
-	Provider      string `yaml:"provider"`       // "postmark", "resend", or "" (disabled)
+	Provider      string `yaml:"provider"`       // "postmark", "resend", "smtp", or "" (disabled)
 	PostmarkToken string `yaml:"postmark_token"` // X-Postmark-Server-Token
 	ResendKey     string `yaml:"resend_key"`     // Resend API key
 	FromAddress   string `yaml:"from_address"`   // e.g. "info@base.al"
+	SMTPHost      string `yaml:"smtp_host"`      // SMTP server; STARTTLS is used when offered
+	SMTPPort      int    `yaml:"smtp_port"`      // Default: 587
+	SMTPUsername  string `yaml:"smtp_username"`
+	SMTPPassword  string `yaml:"smtp_password"`
 }
 
 // DNSConfig holds DNS server configuration
@@ -74,20 +273,63 @@ type DNSConfig struct {
 	Upstream []string `yaml:"upstream"`  // Upstream DNS servers
 }
 
+// DNSProviderConfig lets basepod create A/AAAA records for app domains in
+// an external DNS zone. Only records basepod created are ever removed.
+type DNSProviderConfig struct {
+	Provider        string `yaml:"provider"`          // cloudflare, hetzner or route53 ("" = off)
+	APIToken        string `yaml:"api_token"`         // Cloudflare or Hetzner DNS API token
+	AccessKeyID     string `yaml:"access_key_id"`     // Route53 IAM access key
+	SecretAccessKey string `yaml:"secret_access_key"` // Route53 IAM secret key
+	Zone            string `yaml:"zone"`              // Only manage this zone, e.g. example.com (default: the closest zone the credentials can see)
+	IPv4            string `yaml:"ipv4"`              // Address of A records (default: this server's outbound address, if public)
+	IPv6            string `yaml:"ipv6"`              // Address of AAAA records (default: no AAAA records)
+	TTL             int    `yaml:"ttl"`               // Record TTL in seconds (default: 300)
+	Proxied         bool   `yaml:"proxied"`           // Cloudflare: proxy traffic through Cloudflare
+}
+
 type WebUIConfig struct {
 	// Path to serve static files from disk (empty = use embedded)
 	Path string `yaml:"path"`
+
+	APIURL         string   `yaml:"api_url"`         // API base URL the web UI calls (default: /api on the same host)
+	HiddenFeatures []string `yaml:"hidden_features"` // Web UI sections an admin turned off on this server, e.g. ["models"]
 }
 
 type AuthConfig struct {
-	PasswordHash string `yaml:"password_hash"` // SHA256 hash of the password
+	PasswordHash string           `yaml:"password_hash"` // SHA256 hash of the password
+	LoginLimit   LoginLimitConfig `yaml:"login_limit"`
+}
+
+// LoginLimitConfig throttles failed logins per client IP. Zero values use
+// the defaults.
+type LoginLimitConfig struct {
+	MaxAttempts       int `yaml:"max_attempts"`        // Failed logins allowed within the window before a lockout (default: 5)
+	WindowSeconds     int `yaml:"window_seconds"`      // Sliding window failures are counted over (default: 900)
+	LockoutSeconds    int `yaml:"lockout_seconds"`     // First lockout; each repeat doubles it (default: 60)
+	MaxLockoutSeconds int `yaml:"max_lockout_seconds"` // Longest lockout (default: 3600)
 }
 
 type ServerConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	APIPort  int    `yaml:"api_port"`
-	LogLevel string `yaml:"log_level"`
+	Host      string `yaml:"host"`
+	Port      int    `yaml:"port"`
+	APIPort   int    `yaml:"api_port"`
+	LogLevel  string `yaml:"log_level"`
+	LocalOnly bool   `yaml:"local_only"` // Bind the API to 127.0.0.1 so it is only reachable through Caddy (bp.{root})
+
+	TLS APITLSConfig `yaml:"tls"`
+}
+
+// APITLSConfig serves the API port over HTTPS. Loopback clients such as
+// Caddy and the CLI on the server itself may still use plain HTTP.
+type APITLSConfig struct {
+	CertFile   string `yaml:"cert_file"`   // PEM certificate chain
+	KeyFile    string `yaml:"key_file"`    // PEM private key
+	SelfSigned bool   `yaml:"self_signed"` // Generate and renew a self-signed certificate when cert_file is empty
+}
+
+// Enabled reports whether the API should serve HTTPS
+func (t APITLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
 }
 
 type DomainConfig struct {
@@ -96,11 +338,43 @@ type DomainConfig struct {
 	Suffix   string `yaml:"suffix"`   // Local dev: domain suffix (e.g., .pod) - apps become {name}.pod
 	Wildcard bool   `yaml:"wildcard"` // Enable wildcard subdomains
 	Email    string `yaml:"email"`    // For Let's Encrypt SSL certificates
+
+	WildcardTLS WildcardTLSConfig `yaml:"wildcard_tls"`
+}
+
+// WildcardTLSConfig gets one *.{root} certificate with an ACME DNS-01
+// challenge instead of a certificate per app domain. Caddy must be built
+// with the provider's caddy-dns module.
+type WildcardTLSConfig struct {
+	DNSProvider     string `yaml:"dns_provider"`      // cloudflare, hetzner or route53 ("" = off)
+	APIToken        string `yaml:"api_token"`         // Cloudflare or Hetzner DNS API token (default: dns_provider's, for the same provider)
+	AccessKeyID     string `yaml:"access_key_id"`     // Route53 IAM access key (default: dns_provider's)
+	SecretAccessKey string `yaml:"secret_access_key"` // Route53 IAM secret key (default: dns_provider's)
 }
 
 type PodmanConfig struct {
-	SocketPath string `yaml:"socket_path"` // Auto-detected if empty
-	Network    string `yaml:"network"`     // Default network name
+	SocketPath     string `yaml:"socket_path"`      // Auto-detected if empty
+	Network        string `yaml:"network"`          // Default network name
+	VolumeWarnSize string `yaml:"volume_warn_size"` // Flag app volumes larger than this, e.g. "50GB" (default: the template's suggested size only)
+
+	PullCache PullCacheConfig `yaml:"pull_cache"`
+	Registry  RegistryConfig  `yaml:"registry"`
+}
+
+// PullCacheConfig holds settings for the Docker Hub pull-through cache
+type PullCacheConfig struct {
+	Enabled bool   `yaml:"enabled"`  // Run a registry mirror and pull Docker Hub images through it
+	Port    int    `yaml:"port"`     // Host port for the mirror (default: 5050)
+	MaxSize string `yaml:"max_size"` // Clear the cache once it grows past this, e.g. "20GB" (default: 20GB)
+	TTL     string `yaml:"ttl"`      // How long cached manifests are trusted before rechecking upstream (default: 168h)
+}
+
+// RegistryConfig holds settings for the built-in image registry that
+// source-deploy builds are pushed to
+type RegistryConfig struct {
+	Enabled bool `yaml:"enabled"` // Run a local registry and push every built image to it as basepod/{app}:{git-sha}
+	Port    int  `yaml:"port"`    // Host port for the registry (default: 5055)
+	Expose  bool `yaml:"expose"`  // Listen on all interfaces so other nodes can pull (plain HTTP; default: localhost only)
 }
 
 type DatabaseConfig struct {
@@ -114,6 +388,7 @@ type Paths struct {
 	Config string // /usr/local/basepod/config
 	Data   string // /usr/local/basepod/data
 	Apps   string // /usr/local/basepod/data/apps
+	Git    string // /usr/local/basepod/data/git
 	Certs  string // /usr/local/basepod/data/certs
 	Logs   string // /usr/local/basepod/logs
 	Caddy  string // /usr/local/basepod/caddy
@@ -145,6 +420,7 @@ func GetPaths() (*Paths, error) {
 		Config: filepath.Join(base, "config"),
 		Data:   filepath.Join(base, "data"),
 		Apps:   filepath.Join(base, "data", "apps"),
+		Git:    filepath.Join(base, "data", "git"),
 		Certs:  filepath.Join(base, "data", "certs"),
 		Logs:   filepath.Join(base, "logs"),
 		Caddy:  filepath.Join(base, "caddy"),
@@ -165,6 +441,7 @@ func EnsureDirectories() error {
 		paths.Config,
 		paths.Data,
 		paths.Apps,
+		paths.Git,
 		paths.Certs,
 		paths.Logs,
 		paths.Caddy,
@@ -203,6 +480,28 @@ func DefaultConfig() *Config {
 	}
 }
 
+// WildcardTLSProvider returns the DNS provider and credentials for the
+// wildcard certificate's DNS-01 challenge. Credentials not set under
+// domain.wildcard_tls come from dns_provider when it's the same provider.
+// It returns false when wildcard TLS is off or there's no root domain.
+func (c *Config) WildcardTLSProvider() (DNSProviderConfig, bool) {
+	w := c.Domain.WildcardTLS
+	if w.DNSProvider == "" || c.Domain.Root == "" {
+		return DNSProviderConfig{}, false
+	}
+	p := DNSProviderConfig{Provider: w.DNSProvider, APIToken: w.APIToken, AccessKeyID: w.AccessKeyID, SecretAccessKey: w.SecretAccessKey}
+	if c.DNSProvider.Provider == w.DNSProvider {
+		if p.APIToken == "" {
+			p.APIToken = c.DNSProvider.APIToken
+		}
+		if p.AccessKeyID == "" && p.SecretAccessKey == "" {
+			p.AccessKeyID = c.DNSProvider.AccessKeyID
+			p.SecretAccessKey = c.DNSProvider.SecretAccessKey
+		}
+	}
+	return p, true
+}
+
 // GetAppDomain generates the domain for an app
 // Production: {appname}.{root} (e.g., myapp.example.com)
 // Local dev:  {appname}{suffix} (e.g., myapp.base.code)
@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestWildcardTLSProvider(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.Domain.Root = "example.com"
+	if _, ok := cfg.WildcardTLSProvider(); ok {
+		t.Fatal("expected wildcard TLS to be off by default")
+	}
+
+	cfg.Domain.WildcardTLS.DNSProvider = "cloudflare"
+	cfg.DNSProvider = DNSProviderConfig{Provider: "cloudflare", APIToken: "records-token"}
+	if p, ok := cfg.WildcardTLSProvider(); !ok || p.Provider != "cloudflare" || p.APIToken != "records-token" {
+		t.Fatalf("expected dns_provider's token, got %+v, %v", p, ok)
+	}
+
+	cfg.Domain.WildcardTLS.APIToken = "tls-token"
+	if p, _ := cfg.WildcardTLSProvider(); p.APIToken != "tls-token" {
+		t.Fatalf("expected wildcard_tls's own token, got %q", p.APIToken)
+	}
+
+	cfg.Domain.WildcardTLS = WildcardTLSConfig{DNSProvider: "route53"}
+	if p, _ := cfg.WildcardTLSProvider(); p.APIToken != "" || p.AccessKeyID != "" {
+		t.Fatalf("expected no credentials from a different provider, got %+v", p)
+	}
+
+	cfg.Domain.Root = ""
+	if _, ok := cfg.WildcardTLSProvider(); ok {
+		t.Fatal("expected wildcard TLS to need a root domain")
+	}
+}
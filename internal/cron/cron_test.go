@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+
+	// 2024-06-05 is a Wednesday
+	from := time.Date(2024, 6, 5, 10, 17, 30, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 4 * * *", time.Date(2024, 6, 6, 4, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 6, 5, 10, 30, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 6, 9, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, 6, 6, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, 6, 9, 12, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC)}, // Day-of-month or Friday
+		// A day field covering its whole range restricts nothing, like *
+		{"0 0 */1 * 5", time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1-31 * 5", time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 0-6", time.Date(2024, 6, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 1-7", time.Date(2024, 6, 13, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", c.expr, err)
+		}
+		if got := s.Next(from); !got.Equal(c.want) {
+			t.Fatalf("Next(%q) = %s, want %s", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestNextImpossibleDate(t *testing.T) {
+	t.Parallel()
+
+	s, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Fatalf("Next = %s, want zero time", got)
+	}
+}
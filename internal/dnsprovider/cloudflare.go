@@ -0,0 +1,162 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// cloudflare manages records through the Cloudflare API with an API token
+// that has Zone:Read and DNS:Edit
+type cloudflare struct {
+	token   string
+	zone    string
+	proxied bool
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	zoneIDs map[string]string // Zone name to ID, "" for names that aren't zones
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+func (c *cloudflare) Name() string { return "cloudflare" }
+
+// do calls the API and decodes the result into out
+func (c *cloudflare) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var cr cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		return fmt.Errorf("cloudflare: %s", resp.Status)
+	}
+	if !cr.Success {
+		var messages []string
+		for _, e := range cr.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("cloudflare: %s", strings.Join(messages, "; "))
+	}
+	if out != nil {
+		return json.Unmarshal(cr.Result, out)
+	}
+	return nil
+}
+
+// zoneID finds the zone holding name
+func (c *cloudflare) zoneID(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.zoneIDs == nil {
+		c.zoneIDs = map[string]string{}
+	}
+	for _, zone := range candidateZones(name, c.zone) {
+		id, ok := c.zoneIDs[zone]
+		if !ok {
+			var zones []struct {
+				ID string `json:"id"`
+			}
+			if err := c.do(ctx, "GET", "/zones?name="+url.QueryEscape(zone), nil, &zones); err != nil {
+				return "", err
+			}
+			if len(zones) > 0 {
+				id = zones[0].ID
+			}
+			c.zoneIDs[zone] = id
+		}
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", ErrNoZone
+}
+
+// find returns the existing record of r's name and type, or nil
+func (c *cloudflare) find(ctx context.Context, zoneID string, r Record) (*cloudflareRecord, error) {
+	var records []cloudflareRecord
+	path := fmt.Sprintf("/zones/%s/dns_records?type=%s&name=%s", zoneID, url.QueryEscape(r.Type), url.QueryEscape(r.Name))
+	if err := c.do(ctx, "GET", path, nil, &records); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+func (c *cloudflare) Upsert(ctx context.Context, r Record) error {
+	zoneID, err := c.zoneID(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+	existing, err := c.find(ctx, zoneID, r)
+	if err != nil {
+		return err
+	}
+	ttl := r.TTL
+	if c.proxied {
+		ttl = 1 // Automatic; proxied records ignore the TTL
+	}
+	record := cloudflareRecord{Type: r.Type, Name: r.Name, Content: r.Value, TTL: ttl, Proxied: c.proxied}
+	if existing == nil {
+		return c.do(ctx, "POST", fmt.Sprintf("/zones/%s/dns_records", zoneID), record, nil)
+	}
+	if existing.Content == r.Value && existing.Proxied == c.proxied {
+		return nil
+	}
+	return c.do(ctx, "PUT", fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, existing.ID), record, nil)
+}
+
+func (c *cloudflare) Delete(ctx context.Context, r Record) error {
+	zoneID, err := c.zoneID(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+	existing, err := c.find(ctx, zoneID, r)
+	if err != nil || existing == nil {
+		return err
+	}
+	return c.do(ctx, "DELETE", fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, existing.ID), nil, nil)
+}
@@ -0,0 +1,122 @@
+// Package dnsprovider creates and removes A/AAAA records for app domains in
+// external DNS zones (Cloudflare, Hetzner DNS, Route53).
+package dnsprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+// defaultTTL is the record TTL unless configured
+const defaultTTL = 300
+
+// ErrNoZone is returned for names outside every zone the provider manages
+var ErrNoZone = errors.New("no DNS zone for this domain")
+
+// Record is an address record
+type Record struct {
+	Name  string // Fully qualified, without the trailing dot
+	Type  string // A or AAAA
+	Value string
+	TTL   int
+}
+
+// Provider manages address records in an external DNS service
+type Provider interface {
+	// Name is the provider's config name
+	Name() string
+	// Upsert creates the record, or updates the record of the same name and type
+	Upsert(ctx context.Context, r Record) error
+	// Delete removes the record; a record that doesn't exist is not an error
+	Delete(ctx context.Context, r Record) error
+}
+
+// New returns the provider configured in cfg, or nil if none is
+func New(cfg config.DNSProviderConfig) (Provider, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "cloudflare":
+		if cfg.APIToken == "" {
+			return nil, fmt.Errorf("cloudflare: api_token is required")
+		}
+		return &cloudflare{token: cfg.APIToken, zone: cfg.Zone, proxied: cfg.Proxied, baseURL: cloudflareAPI, client: client}, nil
+	case "hetzner":
+		if cfg.APIToken == "" {
+			return nil, fmt.Errorf("hetzner: api_token is required")
+		}
+		return &hetzner{token: cfg.APIToken, zone: cfg.Zone, baseURL: hetznerAPI, client: client}, nil
+	case "route53":
+		if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+			return nil, fmt.Errorf("route53: access_key_id and secret_access_key are required")
+		}
+		return &route53{accessKey: cfg.AccessKeyID, secretKey: cfg.SecretAccessKey, zone: cfg.Zone, baseURL: route53API, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown DNS provider %q (cloudflare, hetzner or route53)", cfg.Provider)
+	}
+}
+
+// TTL returns the configured record TTL or the default
+func TTL(cfg config.DNSProviderConfig) int {
+	if cfg.TTL > 0 {
+		return cfg.TTL
+	}
+	return defaultTTL
+}
+
+// candidateZones lists the zones name could be in, closest first. With a
+// configured zone only that zone is a candidate, and only if it holds name.
+func candidateZones(name, zone string) []string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if zone != "" {
+		zone = strings.TrimSuffix(strings.ToLower(zone), ".")
+		if name == zone || strings.HasSuffix(name, "."+zone) {
+			return []string{zone}
+		}
+		return nil
+	}
+	labels := strings.Split(strings.TrimPrefix(name, "*."), ".")
+	var zones []string
+	for i := 0; i+1 < len(labels); i++ {
+		zones = append(zones, strings.Join(labels[i:], "."))
+	}
+	return zones
+}
+
+// relativeName is name within zone, "@" for the apex
+func relativeName(name, zone string) string {
+	if name == zone {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+// DetectIPv4 returns the address this host uses for outbound traffic if it
+// is public, so A records can point at it without configuration
+func DetectIPv4() (string, error) {
+	conn, err := net.Dial("udp4", "1.1.1.1:53")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return "", fmt.Errorf("this server's address %s is not public; set dns_provider.ipv4", ip)
+	}
+	return ip.String(), nil
+}
+
+// apiError reads an error response body into an error
+func apiError(provider string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s: %s: %s", provider, resp.Status, strings.TrimSpace(string(body)))
+}
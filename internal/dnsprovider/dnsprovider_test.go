@@ -0,0 +1,132 @@
+package dnsprovider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCandidateZones(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, zone string
+		want       []string
+	}{
+		{"app.example.com", "", []string{"app.example.com", "example.com"}},
+		{"a.b.example.co.uk.", "", []string{"a.b.example.co.uk", "b.example.co.uk", "example.co.uk", "co.uk"}},
+		{"*.example.com", "", []string{"example.com"}},
+		{"App.Example.com", "example.com", []string{"example.com"}},
+		{"example.com", "example.com", []string{"example.com"}},
+		{"app.other.org", "example.com", nil},
+		{"notexample.com", "example.com", nil},
+	}
+	for _, tt := range tests {
+		if got := candidateZones(tt.name, tt.zone); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("candidateZones(%q, %q) = %v, want %v", tt.name, tt.zone, got, tt.want)
+		}
+	}
+}
+
+func TestCloudflareUpsert(t *testing.T) {
+	t.Parallel()
+
+	var created cloudflareRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/zones":
+			result := `[]`
+			if r.URL.Query().Get("name") == "example.com" {
+				result = `[{"id":"z1"}]`
+			}
+			w.Write([]byte(`{"success":true,"result":` + result + `}`))
+		case r.Method == "GET" && r.URL.Path == "/zones/z1/dns_records":
+			w.Write([]byte(`{"success":true,"result":[]}`))
+		case r.Method == "POST" && r.URL.Path == "/zones/z1/dns_records":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.Write([]byte(`{"success":true,"result":{}}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+			w.Write([]byte(`{"success":false,"errors":[{"message":"unexpected"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	cf := &cloudflare{token: "token", proxied: true, baseURL: srv.URL, client: srv.Client()}
+	if err := cf.Upsert(context.Background(), Record{Name: "app.example.com", Type: "A", Value: "203.0.113.7", TTL: 300}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	want := cloudflareRecord{Type: "A", Name: "app.example.com", Content: "203.0.113.7", TTL: 1, Proxied: true}
+	if created != want {
+		t.Fatalf("created %+v, want %+v", created, want)
+	}
+}
+
+func TestHetznerUpsert(t *testing.T) {
+	t.Parallel()
+
+	var updated hetznerRecord
+	var updatedPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Auth-API-Token") != "token" {
+			t.Errorf("Auth-API-Token = %q", r.Header.Get("Auth-API-Token"))
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/zones":
+			if r.URL.Query().Get("name") != "example.com" {
+				http.Error(w, `{"error":"zone not found"}`, http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"zones":[{"id":"z1"}]}`))
+		case r.Method == "GET" && r.URL.Path == "/records":
+			w.Write([]byte(`{"records":[{"id":"r1","zone_id":"z1","type":"A","name":"@","value":"198.51.100.1","ttl":300}]}`))
+		case r.Method == "PUT":
+			updatedPath = r.URL.Path
+			json.NewDecoder(r.Body).Decode(&updated)
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+			http.Error(w, "unexpected", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	h := &hetzner{token: "token", baseURL: srv.URL, client: srv.Client()}
+	if err := h.Upsert(context.Background(), Record{Name: "example.com", Type: "A", Value: "203.0.113.7", TTL: 300}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	want := hetznerRecord{ZoneID: "z1", Type: "A", Name: "@", Value: "203.0.113.7", TTL: 300}
+	if updatedPath != "/records/r1" || updated != want {
+		t.Fatalf("PUT %s %+v, want /records/r1 %+v", updatedPath, updated, want)
+	}
+
+	if err := h.Upsert(context.Background(), Record{Name: "app.other.org", Type: "A", Value: "203.0.113.7"}); err != ErrNoZone {
+		t.Fatalf("Upsert outside every zone = %v, want ErrNoZone", err)
+	}
+}
+
+func TestSignV4(t *testing.T) {
+	t.Parallel()
+
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+
+	auth := req.Header.Get("Authorization")
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth != want {
+		t.Fatalf("Authorization = %q, want %q", auth, want)
+	}
+	if !strings.HasPrefix(req.Header.Get("X-Amz-Date"), "20150830T123600Z") {
+		t.Fatalf("X-Amz-Date = %q", req.Header.Get("X-Amz-Date"))
+	}
+}
@@ -0,0 +1,152 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const hetznerAPI = "https://dns.hetzner.com/api/v1"
+
+// hetzner manages records through the Hetzner DNS API
+type hetzner struct {
+	token   string
+	zone    string
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	zoneIDs map[string]string // Zone name to ID, "" for names that aren't zones
+}
+
+type hetznerRecord struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"` // Relative to the zone, @ for the apex
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl,omitempty"`
+}
+
+func (h *hetzner) Name() string { return "hetzner" }
+
+// do calls the API and decodes the response into out. It also returns the
+// HTTP status so callers can tell a missing zone or record from a failure.
+func (h *hetzner) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Auth-API-Token", h.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("hetzner: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, apiError("hetzner", resp)
+	}
+	if out != nil {
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode, nil
+}
+
+// zoneFor finds the zone holding name and returns its ID and name
+func (h *hetzner) zoneFor(ctx context.Context, name string) (string, string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.zoneIDs == nil {
+		h.zoneIDs = map[string]string{}
+	}
+	for _, zone := range candidateZones(name, h.zone) {
+		id, ok := h.zoneIDs[zone]
+		if !ok {
+			var result struct {
+				Zones []struct {
+					ID string `json:"id"`
+				} `json:"zones"`
+			}
+			status, err := h.do(ctx, "GET", "/zones?name="+url.QueryEscape(zone), nil, &result)
+			if err != nil && status != http.StatusNotFound {
+				return "", "", err
+			}
+			if len(result.Zones) > 0 {
+				id = result.Zones[0].ID
+			}
+			h.zoneIDs[zone] = id
+		}
+		if id != "" {
+			return id, zone, nil
+		}
+	}
+	return "", "", ErrNoZone
+}
+
+// find returns the existing record of r's name and type, or nil
+func (h *hetzner) find(ctx context.Context, zoneID, name, recordType string) (*hetznerRecord, error) {
+	var result struct {
+		Records []hetznerRecord `json:"records"`
+	}
+	if _, err := h.do(ctx, "GET", "/records?zone_id="+url.QueryEscape(zoneID), nil, &result); err != nil {
+		return nil, err
+	}
+	for i := range result.Records {
+		if result.Records[i].Name == name && result.Records[i].Type == recordType {
+			return &result.Records[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *hetzner) Upsert(ctx context.Context, r Record) error {
+	zoneID, zone, err := h.zoneFor(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+	name := relativeName(r.Name, zone)
+	existing, err := h.find(ctx, zoneID, name, r.Type)
+	if err != nil {
+		return err
+	}
+	record := hetznerRecord{ZoneID: zoneID, Type: r.Type, Name: name, Value: r.Value, TTL: r.TTL}
+	if existing == nil {
+		_, err = h.do(ctx, "POST", "/records", record, nil)
+		return err
+	}
+	if existing.Value == r.Value && existing.TTL == r.TTL {
+		return nil
+	}
+	_, err = h.do(ctx, "PUT", "/records/"+url.PathEscape(existing.ID), record, nil)
+	return err
+}
+
+func (h *hetzner) Delete(ctx context.Context, r Record) error {
+	zoneID, zone, err := h.zoneFor(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+	existing, err := h.find(ctx, zoneID, relativeName(r.Name, zone), r.Type)
+	if err != nil || existing == nil {
+		return err
+	}
+	status, err := h.do(ctx, "DELETE", "/records/"+url.PathEscape(existing.ID), nil, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
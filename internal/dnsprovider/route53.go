@@ -0,0 +1,199 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	route53API     = "https://route53.amazonaws.com"
+	route53Region  = "us-east-1" // Route53 is global and signed for us-east-1
+	route53Service = "route53"
+)
+
+// route53 manages records through the Route53 API with an IAM access key
+// allowed route53:ListHostedZonesByName and route53:ChangeResourceRecordSets
+type route53 struct {
+	accessKey string
+	secretKey string
+	zone      string
+	baseURL   string
+	client    *http.Client
+
+	mu      sync.Mutex
+	zoneIDs map[string]string // Zone name to hosted zone ID, "" for names that aren't zones
+}
+
+func (r53 *route53) Name() string { return "route53" }
+
+// do signs and sends a request and returns its body
+func (r53 *route53) do(ctx context.Context, method, path string, query url.Values, body []byte) ([]byte, int, error) {
+	u := r53.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	signV4(req, body, r53.accessKey, r53.secretKey, route53Region, route53Service, time.Now())
+
+	resp, err := r53.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("route53: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(data, &e)
+		if e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return data, resp.StatusCode, fmt.Errorf("route53: %s: %s", resp.Status, e.Message)
+	}
+	return data, resp.StatusCode, nil
+}
+
+// zoneID finds the hosted zone holding name
+func (r53 *route53) zoneID(ctx context.Context, name string) (string, error) {
+	r53.mu.Lock()
+	defer r53.mu.Unlock()
+	if r53.zoneIDs == nil {
+		r53.zoneIDs = map[string]string{}
+	}
+	for _, zone := range candidateZones(name, r53.zone) {
+		id, ok := r53.zoneIDs[zone]
+		if !ok {
+			data, _, err := r53.do(ctx, "GET", "/2013-04-01/hostedzonesbyname", url.Values{"dnsname": {zone}, "maxitems": {"1"}}, nil)
+			if err != nil {
+				return "", err
+			}
+			var result struct {
+				Zones []struct {
+					ID   string `xml:"Id"`
+					Name string `xml:"Name"`
+				} `xml:"HostedZones>HostedZone"`
+			}
+			if err := xml.Unmarshal(data, &result); err != nil {
+				return "", fmt.Errorf("route53: %w", err)
+			}
+			// Zones are listed from dnsname on; the first is only ours if it matches
+			if len(result.Zones) > 0 && strings.TrimSuffix(result.Zones[0].Name, ".") == zone {
+				id = strings.TrimPrefix(result.Zones[0].ID, "/hostedzone/")
+			}
+			r53.zoneIDs[zone] = id
+		}
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", ErrNoZone
+}
+
+// change submits a single record change
+func (r53 *route53) change(ctx context.Context, action string, r Record) (int, error) {
+	zoneID, err := r53.zoneID(ctx, r.Name)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<ChangeResourceRecordSetsRequest xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><ChangeBatch><Changes><Change><Action>%s</Action><ResourceRecordSet><Name>`, action)
+	xml.EscapeText(&buf, []byte(r.Name+"."))
+	fmt.Fprintf(&buf, `</Name><Type>%s</Type><TTL>%d</TTL><ResourceRecords><ResourceRecord><Value>`, r.Type, r.TTL)
+	xml.EscapeText(&buf, []byte(r.Value))
+	buf.WriteString(`</Value></ResourceRecord></ResourceRecords></ResourceRecordSet></Change></Changes></ChangeBatch></ChangeResourceRecordSetsRequest>`)
+
+	_, status, err := r53.do(ctx, "POST", "/2013-04-01/hostedzone/"+zoneID+"/rrset", nil, buf.Bytes())
+	return status, err
+}
+
+func (r53 *route53) Upsert(ctx context.Context, r Record) error {
+	_, err := r53.change(ctx, "UPSERT", r)
+	return err
+}
+
+// Delete removes the record. Route53 only deletes a record set that matches
+// exactly, so r needs the value and TTL it was created with.
+func (r53 *route53) Delete(ctx context.Context, r Record) error {
+	status, err := r53.change(ctx, "DELETE", r)
+	if err != nil && status == http.StatusBadRequest && strings.Contains(err.Error(), "not found") {
+		return nil
+	}
+	return err
+}
+
+// signV4 adds AWS Signature Version 4 headers to req
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,273 @@
+// Package k8s renders basepod apps and basepod.yaml services as Kubernetes
+// manifests, for moving a workload to a cluster or staging it on basepod
+// first.
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultVolumeSize is the claim size for volumes without a suggested size
+const defaultVolumeSize = "1Gi"
+
+// Workload is one container to run: a basepod app or one service of a
+// basepod.yaml stack
+type Workload struct {
+	Name       string
+	Image      string
+	Replicas   int
+	Port       int               // Container port (0: no Service)
+	Protocol   string            // Upstream protocol: http, h2c or grpc
+	Command    string            // Run with sh -c; empty keeps the image's
+	Env        map[string]string // Put in a Secret, since app env often holds credentials
+	Volumes    []Volume
+	MemoryMB   int64
+	CPUs       float64
+	HealthPath string   // HTTP readiness and liveness probe path
+	Hosts      []string // Ingress hosts (empty: no Ingress)
+}
+
+// Volume is a persistent directory of a workload
+type Volume struct {
+	Name      string
+	MountPath string
+	ReadOnly  bool
+	Size      string // e.g. "10GB" (default: 1Gi)
+}
+
+// Options adjust the generated manifests
+type Options struct {
+	Namespace    string // metadata.namespace of every object (empty: none)
+	IngressClass string // spec.ingressClassName of Ingresses (empty: the cluster default)
+	PartOf       string // app.kubernetes.io/part-of label, e.g. a stack's name
+}
+
+var dns1123 = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// objectName makes s a valid Kubernetes object name
+func objectName(s string) string {
+	s = strings.Trim(dns1123.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-")
+	}
+	return s
+}
+
+// Manifests renders workloads as a multi-document YAML stream, with a
+// Secret, PersistentVolumeClaims, a Deployment, a Service and an Ingress per
+// workload as needed. The warnings list what didn't carry over cleanly.
+func Manifests(workloads []Workload, opts Options) ([]byte, []string, error) {
+	var docs []interface{}
+	var warnings []string
+	for _, w := range workloads {
+		if w.Image == "" {
+			return nil, nil, fmt.Errorf("%s has no image", w.Name)
+		}
+		objs, warns := workloadObjects(w, opts)
+		docs = append(docs, objs...)
+		warnings = append(warnings, warns...)
+	}
+
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, nil, err
+		}
+		enc.Close()
+	}
+	return buf.Bytes(), warnings, nil
+}
+
+type objectMeta struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type object struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   objectMeta  `yaml:"metadata"`
+	Type       string      `yaml:"type,omitempty"`
+	StringData interface{} `yaml:"stringData,omitempty"`
+	Spec       interface{} `yaml:"spec,omitempty"`
+}
+
+type m = map[string]interface{}
+
+func workloadObjects(w Workload, opts Options) ([]interface{}, []string) {
+	name := objectName(w.Name)
+	labels := map[string]string{"app.kubernetes.io/name": name}
+	if opts.PartOf != "" {
+		labels["app.kubernetes.io/part-of"] = objectName(opts.PartOf)
+	}
+	meta := func(objName string) objectMeta {
+		return objectMeta{Name: objName, Namespace: opts.Namespace, Labels: labels}
+	}
+	var objs []interface{}
+	var warnings []string
+
+	if strings.HasPrefix(w.Image, "localhost/") || strings.HasPrefix(w.Image, "localhost:") {
+		warnings = append(warnings, fmt.Sprintf("%s: image %s was built on basepod; push it to a registry the cluster can pull from", w.Name, w.Image))
+	}
+
+	container := m{"name": name, "image": w.Image}
+	if w.Command != "" {
+		container["command"] = []string{"sh", "-c", w.Command}
+	}
+	if w.Port > 0 {
+		container["ports"] = []m{{"name": "http", "containerPort": w.Port}}
+	}
+	if len(w.Env) > 0 {
+		secretName := name + "-env"
+		objs = append(objs, object{APIVersion: "v1", Kind: "Secret", Metadata: meta(secretName), Type: "Opaque", StringData: w.Env})
+		container["envFrom"] = []m{{"secretRef": m{"name": secretName}}}
+	}
+	if w.MemoryMB > 0 || w.CPUs > 0 {
+		limits := m{}
+		if w.MemoryMB > 0 {
+			limits["memory"] = fmt.Sprintf("%dMi", w.MemoryMB)
+		}
+		if w.CPUs > 0 {
+			limits["cpu"] = strconv.FormatFloat(w.CPUs, 'f', -1, 64)
+		}
+		container["resources"] = m{"limits": limits}
+	}
+	if w.HealthPath != "" && w.Port > 0 {
+		probe := m{"httpGet": m{"path": w.HealthPath, "port": "http"}, "periodSeconds": 10}
+		container["readinessProbe"] = probe
+		container["livenessProbe"] = m{"httpGet": m{"path": w.HealthPath, "port": "http"}, "periodSeconds": 30, "failureThreshold": 3}
+	}
+
+	var mounts, volumes []m
+	for _, v := range w.Volumes {
+		claim := objectName(name + "-" + v.Name)
+		size, ok := quantity(v.Size)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("%s: volume %s has size %q; claimed %s", w.Name, v.Name, v.Size, defaultVolumeSize))
+		}
+		objs = append(objs, object{
+			APIVersion: "v1", Kind: "PersistentVolumeClaim", Metadata: meta(claim),
+			Spec: m{
+				"accessModes": []string{"ReadWriteOnce"},
+				"resources":   m{"requests": m{"storage": size}},
+			},
+		})
+		mount := m{"name": objectName(v.Name), "mountPath": v.MountPath}
+		if v.ReadOnly {
+			mount["readOnly"] = true
+		}
+		mounts = append(mounts, mount)
+		volumes = append(volumes, m{"name": objectName(v.Name), "persistentVolumeClaim": m{"claimName": claim}})
+	}
+	if len(mounts) > 0 {
+		container["volumeMounts"] = mounts
+	}
+
+	replicas := w.Replicas
+	if replicas < 1 {
+		replicas = 1
+	}
+	if replicas > 1 && len(w.Volumes) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%s: %d replicas share ReadWriteOnce volumes; use a ReadWriteMany storage class or a StatefulSet", w.Name, replicas))
+	}
+	podSpec := m{"containers": []m{container}}
+	if len(volumes) > 0 {
+		podSpec["volumes"] = volumes
+	}
+	deploySpec := m{
+		"replicas": replicas,
+		"selector": m{"matchLabels": map[string]string{"app.kubernetes.io/name": name}},
+		"template": m{"metadata": m{"labels": labels}, "spec": podSpec},
+	}
+	if len(w.Volumes) > 0 {
+		// RWO volumes can't attach to the old and new pod at once
+		deploySpec["strategy"] = m{"type": "Recreate"}
+	}
+	objs = append(objs, object{APIVersion: "apps/v1", Kind: "Deployment", Metadata: meta(name), Spec: deploySpec})
+
+	if w.Port == 0 {
+		if len(w.Hosts) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: has domains but no port; no Ingress was made", w.Name))
+		}
+		return objs, warnings
+	}
+	portSpec := m{"name": "http", "port": w.Port, "targetPort": "http"}
+	if w.Protocol == "grpc" || w.Protocol == "h2c" {
+		portSpec["appProtocol"] = "kubernetes.io/h2c"
+	}
+	objs = append(objs, object{
+		APIVersion: "v1", Kind: "Service", Metadata: meta(name),
+		Spec: m{
+			"selector": map[string]string{"app.kubernetes.io/name": name},
+			"ports":    []m{portSpec},
+		},
+	})
+
+	if len(w.Hosts) == 0 {
+		return objs, warnings
+	}
+	hosts := append([]string(nil), w.Hosts...)
+	sort.Strings(hosts)
+	var rules []m
+	for _, host := range hosts {
+		rules = append(rules, m{
+			"host": host,
+			"http": m{"paths": []m{{
+				"path":     "/",
+				"pathType": "Prefix",
+				"backend":  m{"service": m{"name": name, "port": m{"name": "http"}}},
+			}}},
+		})
+	}
+	ingressSpec := m{
+		"rules": rules,
+		"tls":   []m{{"hosts": hosts, "secretName": name + "-tls"}},
+	}
+	if opts.IngressClass != "" {
+		ingressSpec["ingressClassName"] = opts.IngressClass
+	}
+	ingressMeta := meta(name)
+	if w.Protocol == "grpc" {
+		ingressMeta.Annotations = map[string]string{"nginx.ingress.kubernetes.io/backend-protocol": "GRPC"}
+	}
+	objs = append(objs, object{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Metadata: ingressMeta, Spec: ingressSpec})
+	return objs, warnings
+}
+
+// quantity converts a size like "10GB" or "512MB" to a Kubernetes quantity.
+// ok is false when size was set but couldn't be read.
+func quantity(size string) (string, bool) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	if s == "" {
+		return defaultVolumeSize, true
+	}
+	units := []struct{ suffix, unit string }{
+		{"TIB", "Ti"}, {"GIB", "Gi"}, {"MIB", "Mi"},
+		{"TB", "Ti"}, {"GB", "Gi"}, {"MB", "Mi"},
+		{"TI", "Ti"}, {"GI", "Gi"}, {"MI", "Mi"},
+		{"T", "Ti"}, {"G", "Gi"}, {"M", "Mi"},
+	}
+	for _, u := range units {
+		if num, found := strings.CutSuffix(s, u.suffix); found {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(num), 64); err == nil {
+				return strings.TrimSpace(num) + u.unit, true
+			}
+			break
+		}
+	}
+	return defaultVolumeSize, false
+}
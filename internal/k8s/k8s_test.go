@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeAll splits a manifest stream into its objects
+func decodeAll(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var objs []map[string]interface{}
+	for {
+		var obj map[string]interface{}
+		if err := dec.Decode(&obj); err != nil {
+			break
+		}
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+func TestManifests(t *testing.T) {
+	t.Parallel()
+	web := Workload{
+		Name:       "web",
+		Image:      "localhost/basepod/web:1700000000",
+		Replicas:   2,
+		Port:       3000,
+		Env:        map[string]string{"DATABASE_URL": "postgres://db/app"},
+		MemoryMB:   512,
+		CPUs:       0.5,
+		HealthPath: "/health",
+		Hosts:      []string{"www.example.com", "example.com"},
+	}
+	db := Workload{
+		Name:    "db",
+		Image:   "postgres:16",
+		Port:    5432,
+		Volumes: []Volume{{Name: "data", MountPath: "/var/lib/postgresql/data", Size: "10GB"}},
+	}
+	out, warnings, err := Manifests([]Workload{web, db}, Options{Namespace: "shop", IngressClass: "nginx", PartOf: "shop"})
+	if err != nil {
+		t.Fatalf("Manifests: %v", err)
+	}
+
+	var kinds []string
+	for _, obj := range decodeAll(t, out) {
+		meta := obj["metadata"].(map[string]interface{})
+		if meta["namespace"] != "shop" {
+			t.Fatalf("%s %s has namespace %v, want shop", obj["kind"], meta["name"], meta["namespace"])
+		}
+		kinds = append(kinds, obj["kind"].(string)+"/"+meta["name"].(string))
+	}
+	want := "Secret/web-env Deployment/web Service/web Ingress/web PersistentVolumeClaim/db-data Deployment/db Service/db"
+	if strings.Join(kinds, " ") != want {
+		t.Fatalf("objects = %v, want %s", kinds, want)
+	}
+
+	text := string(out)
+	for _, want := range []string{"storage: 10Gi", "memory: 512Mi", "cpu: \"0.5\"", "ingressClassName: nginx", "path: /health", "type: Recreate", "- example.com"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("manifests missing %q:\n%s", want, text)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "built on basepod") {
+		t.Fatalf("warnings = %v, want one about the local image", warnings)
+	}
+
+	if _, _, err := Manifests([]Workload{{Name: "x"}}, Options{}); err == nil {
+		t.Fatalf("workload without image: want an error")
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{"": "1Gi", "10GB": "10Gi", "512mb": "512Mi", "1.5G": "1.5Gi", "2Ti": "2Ti"}
+	for in, want := range cases {
+		if got, ok := quantity(in); !ok || got != want {
+			t.Fatalf("quantity(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := quantity("lots"); ok {
+		t.Fatalf("quantity(lots): want ok = false")
+	}
+}
@@ -26,6 +26,8 @@ type Service struct {
 	process     *exec.Cmd
 	pid         int
 	activeModel string
+	inFlight    int       // Chat requests being served
+	lastUsed    time.Time // When the chat model last started or finished a request
 	mu          sync.RWMutex
 	client      *http.Client
 }
@@ -795,6 +797,59 @@ func (s *Service) DeleteModel(modelID string) error {
 
 // Transcribe audio file using Whisper model
 func (s *Service) Transcribe(audioPath, modelID string) (string, error) {
+	return s.TranscribeLanguage(audioPath, modelID, "")
+}
+
+// TranscribeLanguage transcribes audio with an optional ISO-639-1 language hint (empty = auto-detect)
+func (s *Service) TranscribeLanguage(audioPath, modelID, language string) (string, error) {
+	t, err := s.TranscribeSegments(audioPath, modelID, language)
+	if err != nil {
+		return "", err
+	}
+	return t.Text, nil
+}
+
+// Transcript is a transcription with its timed segments
+type Transcript struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language"` // Detected when no hint was given
+	Duration float64             `json:"duration"` // Seconds, to the end of the last segment
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+// TranscriptSegment is a stretch of a transcription, timed in seconds
+type TranscriptSegment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// TranscribeSegments is TranscribeLanguage with the segments and detected language
+func (s *Service) TranscribeSegments(audioPath, modelID, language string) (*Transcript, error) {
+	var t *Transcript
+	err := s.runJob("transcribe", modelID, ClassInteractive, func(context.Context) (err error) {
+		t, err = s.transcribe(audioPath, modelID, language)
+		return err
+	})
+	return t, err
+}
+
+// parseTranscript reads the JSON the transcription script prints last
+func parseTranscript(output []byte) (*Transcript, error) {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var t Transcript
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &t); err != nil {
+		return nil, fmt.Errorf("transcription failed: unreadable output: %w", err)
+	}
+	t.Text = strings.TrimSpace(t.Text)
+	if n := len(t.Segments); n > 0 {
+		t.Duration = t.Segments[n-1].End
+	}
+	return &t, nil
+}
+
+func (s *Service) transcribe(audioPath, modelID, language string) (*Transcript, error) {
 	venvPath := filepath.Join(s.baseDir, "venv")
 	pythonPath := filepath.Join(venvPath, "bin", "python")
 
@@ -805,10 +860,15 @@ func (s *Service) Transcribe(audioPath, modelID string) (string, error) {
 		installCmd := exec.Command(filepath.Join(venvPath, "bin", "pip"), "install", "mlx-whisper")
 		installCmd.Env = append(os.Environ(), "HF_HOME="+filepath.Join(s.baseDir, "cache"))
 		if err := installCmd.Run(); err != nil {
-			return "", fmt.Errorf("failed to install mlx-whisper: %w", err)
+			return nil, fmt.Errorf("failed to install mlx-whisper: %w", err)
 		}
 	}
 
+	languageArg := "None"
+	if language != "" {
+		languageArg = fmt.Sprintf("%q", language)
+	}
+
 	// Use mlx-whisper for transcription
 	// Patch: monkey-patch ModelDimensions to ignore unknown kwargs (e.g. acoustic_tokenizer_config)
 	transcribeScript := fmt.Sprintf(`
@@ -827,9 +887,14 @@ lm.whisper.ModelDimensions.__init__ = _patched_init
 result = mlx_whisper.transcribe(
     %q,
     path_or_hf_repo=%q,
+    language=%s,
 )
-print(result.get("text", ""))
-`, audioPath, modelID)
+segments = [
+    {"id": i, "start": seg["start"], "end": seg["end"], "text": seg["text"].strip()}
+    for i, seg in enumerate(result.get("segments", []))
+]
+print(json.dumps({"text": result.get("text", ""), "language": result.get("language", ""), "segments": segments}))
+`, audioPath, modelID, languageArg)
 
 	cmd := exec.Command(pythonPath, "-c", transcribeScript)
 	cmd.Env = append(os.Environ(),
@@ -839,16 +904,102 @@ print(result.get("text", ""))
 	output, err := cmd.Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return "", fmt.Errorf("transcription failed: %s", string(exitErr.Stderr))
+			return nil, fmt.Errorf("transcription failed: %s", string(exitErr.Stderr))
 		}
-		return "", fmt.Errorf("transcription failed: %w", err)
+		return nil, fmt.Errorf("transcription failed: %w", err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	return parseTranscript(output)
+}
+
+// DefaultEmbeddingModel is used when no embedding model is configured.
+const DefaultEmbeddingModel = "mlx-community/all-MiniLM-L6-v2-4bit"
+
+// Embed returns one embedding vector per input text using an MLX embedding model
+func (s *Service) Embed(texts []string, modelID string) ([][]float32, error) {
+	return s.EmbedClass(texts, modelID, ClassInteractive)
+}
+
+// EmbedClass is Embed with an explicit scheduler class, e.g. ClassBackground for indexing
+func (s *Service) EmbedClass(texts []string, modelID, class string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if modelID == "" {
+		modelID = DefaultEmbeddingModel
+	}
+
+	var vectors [][]float32
+	err := s.runJob("embed", modelID, class, func(ctx context.Context) (err error) {
+		vectors, err = s.embed(ctx, texts, modelID)
+		return err
+	})
+	return vectors, err
+}
+
+func (s *Service) embed(ctx context.Context, texts []string, modelID string) ([][]float32, error) {
+	venvPath := filepath.Join(s.baseDir, "venv")
+	pythonPath := filepath.Join(venvPath, "bin", "python")
+
+	// Check if mlx-embeddings is installed, install if not
+	checkCmd := exec.Command(pythonPath, "-c", "import mlx_embeddings")
+	if err := checkCmd.Run(); err != nil {
+		installCmd := exec.Command(filepath.Join(venvPath, "bin", "pip"), "install", "mlx-embeddings")
+		installCmd.Env = append(os.Environ(), "HF_HOME="+filepath.Join(s.baseDir, "cache"))
+		if output, err := installCmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("failed to install mlx-embeddings: %s", string(output))
+		}
+	}
+
+	// Texts are passed on stdin to avoid argument length limits
+	embedScript := fmt.Sprintf(`
+import sys, json
+import mlx.core as mx
+from mlx_embeddings.utils import load
+
+texts = json.load(sys.stdin)
+model, tokenizer = load(%q)
+inputs = tokenizer.batch_encode_plus(texts, return_tensors="mlx", padding=True, truncation=True, max_length=512)
+outputs = model(inputs["input_ids"], attention_mask=inputs["attention_mask"])
+json.dump(outputs.text_embeds.tolist(), sys.stdout)
+`, modelID)
+
+	input, _ := json.Marshal(texts)
+	cmd := exec.CommandContext(ctx, pythonPath, "-c", embedScript)
+	cmd.Env = append(os.Environ(),
+		"HF_HOME="+filepath.Join(s.baseDir, "cache"),
+	)
+	cmd.Stdin = strings.NewReader(string(input))
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("embedding failed: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+
+	var vectors [][]float32
+	if err := json.Unmarshal(output, &vectors); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(vectors))
+	}
+	return vectors, nil
 }
 
 // Synthesize generates speech audio from text using a TTS model
 func (s *Service) Synthesize(text, modelID string) ([]byte, error) {
+	var audio []byte
+	err := s.runJob("synthesize", modelID, ClassInteractive, func(context.Context) (err error) {
+		audio, err = s.synthesize(text, modelID)
+		return err
+	})
+	return audio, err
+}
+
+func (s *Service) synthesize(text, modelID string) ([]byte, error) {
 	venvPath := filepath.Join(s.baseDir, "venv")
 	pythonPath := filepath.Join(venvPath, "bin", "python")
 
@@ -907,11 +1058,20 @@ func (s *Service) Run(modelID string) error {
 		return fmt.Errorf("model not downloaded: %s", modelID)
 	}
 
-	// Stop current server if running different model
+	// Refuse models that won't fit, counting memory freed by replacing the current one
+	reclaim := 0
 	if s.isRunning() {
 		if s.activeModel == modelID {
 			return nil // Already running this model
 		}
+		reclaim = EstimateModelRAM(s.activeModel)
+	}
+	if err := checkMemory(modelID, reclaim); err != nil {
+		return err
+	}
+
+	// Stop current server if running different model
+	if s.isRunning() {
 		s.stopServer()
 	}
 
@@ -942,6 +1102,7 @@ func (s *Service) Run(modelID string) error {
 	s.process = cmd
 	s.pid = cmd.Process.Pid
 	s.activeModel = modelID
+	s.lastUsed = time.Now()
 
 	// Wait for server in background
 	go func() {
@@ -952,6 +1113,20 @@ func (s *Service) Run(modelID string) error {
 	return nil
 }
 
+// Restart stops the MLX server and starts it again with the same model.
+// Used by the watchdog when the server stops answering.
+func (s *Service) Restart() error {
+	s.mu.Lock()
+	model := s.activeModel
+	if model == "" {
+		s.mu.Unlock()
+		return fmt.Errorf("no model running")
+	}
+	s.stopServer()
+	s.mu.Unlock()
+	return s.Run(model)
+}
+
 // Stop stops the MLX server
 func (s *Service) Stop() error {
 	s.mu.Lock()
@@ -984,10 +1159,37 @@ func (s *Service) stopServer() error {
 var (
 	assistantProcess *exec.Cmd
 	assistantPID     int
+	assistantPort    int
 	assistantModel   string
 	assistantMu      sync.Mutex
 )
 
+// AssistantStatus reports the assistant model process, if one is running
+func AssistantStatus() Status {
+	assistantMu.Lock()
+	defer assistantMu.Unlock()
+	if assistantPID == 0 {
+		return Status{}
+	}
+	if proc, err := os.FindProcess(assistantPID); err != nil || proc.Signal(syscall.Signal(0)) != nil {
+		return Status{}
+	}
+	return Status{Running: true, Port: assistantPort, PID: assistantPID, ActiveModel: assistantModel}
+}
+
+// RestartAssistant stops the assistant model process and starts it again on
+// the same port
+func (s *Service) RestartAssistant() error {
+	assistantMu.Lock()
+	model, port := assistantModel, assistantPort
+	stopAssistantProcess()
+	assistantMu.Unlock()
+	if model == "" {
+		return fmt.Errorf("no assistant model running")
+	}
+	return s.RunOnPort(model, port)
+}
+
 // RunOnPort starts a model on a specific port (used for the AI assistant).
 // This runs independently of the primary chat model.
 func (s *Service) RunOnPort(modelID string, port int) error {
@@ -1002,7 +1204,15 @@ func (s *Service) RunOnPort(modelID string, port int) error {
 		}
 	}
 
-	// Stop existing assistant if running
+	// Refuse models that won't fit before stopping the existing assistant,
+	// counting the memory it frees
+	reclaim := 0
+	if assistantPID != 0 {
+		reclaim = EstimateModelRAM(assistantModel)
+	}
+	if err := checkMemory(modelID, reclaim); err != nil {
+		return err
+	}
 	stopAssistantProcess()
 
 	// Check if model is downloaded
@@ -1036,6 +1246,7 @@ func (s *Service) RunOnPort(modelID string, port int) error {
 
 	assistantProcess = cmd
 	assistantPID = cmd.Process.Pid
+	assistantPort = port
 	assistantModel = modelID
 
 	go func() {
@@ -1084,6 +1295,7 @@ func stopAssistantProcess() {
 
 	assistantProcess = nil
 	assistantPID = 0
+	assistantPort = 0
 	assistantModel = ""
 }
 
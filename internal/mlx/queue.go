@@ -0,0 +1,298 @@
+package mlx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job classes. Interactive jobs (a user is waiting on the response) are always
+// dispatched ahead of background jobs (log indexing and other batch work), and
+// one waiting for a slot preempts a running background job: its context is
+// cancelled and it runs again from the queue once the interactive job is done.
+const (
+	ClassInteractive = "interactive"
+	ClassBackground  = "background"
+)
+
+// backgroundMaxWait is how long a background job can be passed over by
+// interactive jobs before it is promoted so it doesn't starve
+const backgroundMaxWait = 2 * time.Minute
+
+// Job is a queued or running AI job
+type Job struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"` // transcribe, embed, synthesize, assistant-warmup
+	Model      string    `json:"model"`
+	Class      string    `json:"class"`
+	Running    bool      `json:"running"`
+	Promoted   bool      `json:"promoted,omitempty"`  // Background job aged into the interactive lane
+	Preempted  int       `json:"preempted,omitempty"` // Times it was stopped for an interactive job
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+
+	ready      chan struct{}
+	ctx        context.Context // Cancelled when the job is preempted
+	cancel     context.CancelFunc
+	preempting bool // Cancelled, and holds its slot until fn returns
+}
+
+// QueueStatus is a snapshot of the AI job queue
+type QueueStatus struct {
+	MaxConcurrent int    `json:"max_concurrent"`
+	MaxBackground int    `json:"max_background"`
+	Running       []*Job `json:"running"`
+	Queued        []*Job `json:"queued"`
+}
+
+// Scheduler admits AI jobs by class priority under total and per-class concurrency limits
+type Scheduler struct {
+	mu            sync.Mutex
+	queue         []*Job
+	running       []*Job
+	maxConcurrent int
+	maxBackground int
+	nextID        int
+}
+
+var scheduler = NewScheduler(1, 1)
+
+// NewScheduler creates a scheduler running at most maxConcurrent jobs, of which
+// at most maxBackground may be background jobs
+func NewScheduler(maxConcurrent, maxBackground int) *Scheduler {
+	s := &Scheduler{}
+	s.SetLimits(maxConcurrent, maxBackground)
+	return s
+}
+
+// GetScheduler returns the shared AI job scheduler
+func GetScheduler() *Scheduler {
+	return scheduler
+}
+
+// SetLimits updates the concurrency limits. Values below 1 fall back to 1.
+func (s *Scheduler) SetLimits(maxConcurrent, maxBackground int) {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	if maxBackground < 1 || maxBackground > maxConcurrent {
+		maxBackground = maxConcurrent
+	}
+	s.mu.Lock()
+	s.maxConcurrent = maxConcurrent
+	s.maxBackground = maxBackground
+	s.dispatch()
+	s.mu.Unlock()
+}
+
+// Run queues fn as a job of the given class and runs it once admitted. A
+// background job's ctx is cancelled when an interactive job preempts it, and
+// fn is run again once it is dispatched anew, so it must be safe to repeat.
+func (s *Scheduler) Run(kind, modelID, class string, fn func(ctx context.Context) error) error {
+	job := s.enqueue(kind, modelID, class)
+	for {
+		<-job.ready
+		err := fn(job.ctx)
+		if !s.finish(job, err != nil) {
+			return err
+		}
+	}
+}
+
+func (s *Scheduler) enqueue(kind, modelID, class string) *Job {
+	if class != ClassBackground {
+		class = ClassInteractive
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	job := &Job{
+		ID:         fmt.Sprintf("job-%d", s.nextID),
+		Kind:       kind,
+		Model:      modelID,
+		Class:      class,
+		EnqueuedAt: time.Now(),
+		ready:      make(chan struct{}),
+	}
+	s.insert(job)
+	s.dispatch()
+	return job
+}
+
+// insert queues job behind the jobs of its lane. Interactive jobs go ahead of
+// every queued background job, and a preempted background job goes ahead of
+// the other background jobs. Caller must hold s.mu.
+func (s *Scheduler) insert(job *Job) {
+	pos := len(s.queue)
+	for i, queued := range s.queue {
+		lane := queued.Class == ClassInteractive || queued.Promoted
+		if job.Class == ClassInteractive && !lane || job.Preempted > 0 && !lane && queued.Preempted == 0 {
+			pos = i
+			break
+		}
+	}
+	s.queue = append(s.queue, nil)
+	copy(s.queue[pos+1:], s.queue[pos:])
+	s.queue[pos] = job
+}
+
+// finish frees job's slot. It returns true when the job failed because it
+// was preempted and is queued again.
+func (s *Scheduler) finish(job *Job, failed bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, running := range s.running {
+		if running == job {
+			s.running = append(s.running[:i], s.running[i+1:]...)
+			break
+		}
+	}
+	job.Running = false
+	job.cancel()
+	requeue := job.preempting && failed
+	job.preempting = false
+	if requeue {
+		job.ready = make(chan struct{})
+		s.insert(job)
+	}
+	s.dispatch()
+	return requeue
+}
+
+// dispatch starts queued jobs in order while limits allow. Caller must hold s.mu.
+func (s *Scheduler) dispatch() {
+	s.promoteStarved()
+
+	for len(s.running) < s.maxConcurrent {
+		background := 0
+		for _, job := range s.running {
+			if job.Class == ClassBackground {
+				background++
+			}
+		}
+
+		next := -1
+		for i, job := range s.queue {
+			if job.Class == ClassBackground && background >= s.maxBackground {
+				continue
+			}
+			next = i
+			break
+		}
+		if next < 0 {
+			return
+		}
+
+		job := s.queue[next]
+		s.queue = append(s.queue[:next], s.queue[next+1:]...)
+		job.Running = true
+		job.StartedAt = time.Now()
+		job.ctx, job.cancel = context.WithCancel(context.Background())
+		s.running = append(s.running, job)
+		close(job.ready)
+	}
+	s.preempt()
+}
+
+// preempt cancels one running background job for each interactive job still
+// waiting for a slot. Promoted jobs have waited long enough and aren't
+// preempted. Caller must hold s.mu.
+func (s *Scheduler) preempt() {
+	waiting := 0
+	for _, job := range s.queue {
+		if job.Class == ClassInteractive || job.Promoted {
+			waiting++
+		}
+	}
+	for _, job := range s.running {
+		if job.preempting {
+			waiting--
+		}
+	}
+	for _, job := range s.running {
+		if waiting <= 0 {
+			return
+		}
+		if job.Class != ClassBackground || job.Promoted || job.preempting {
+			continue
+		}
+		job.preempting = true
+		job.Preempted++
+		job.cancel()
+		waiting--
+	}
+}
+
+// promoteStarved moves background jobs that have waited too long to the end of
+// the interactive lane. Caller must hold s.mu.
+func (s *Scheduler) promoteStarved() {
+	for i, job := range s.queue {
+		if job.Class != ClassBackground || job.Promoted || time.Since(job.EnqueuedAt) < backgroundMaxWait {
+			continue
+		}
+		job.Promoted = true
+		pos := 0
+		for pos < i && (s.queue[pos].Class == ClassInteractive || s.queue[pos].Promoted) {
+			pos++
+		}
+		copy(s.queue[pos+1:i+1], s.queue[pos:i])
+		s.queue[pos] = job
+	}
+}
+
+// Move reorders a queued job to position (0 = next to run)
+func (s *Scheduler) Move(id string, position int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := -1
+	for i, job := range s.queue {
+		if job.ID == id {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		return fmt.Errorf("job %s is not queued", id)
+	}
+
+	job := s.queue[from]
+	s.queue = append(s.queue[:from], s.queue[from+1:]...)
+	if position < 0 {
+		position = 0
+	}
+	if position > len(s.queue) {
+		position = len(s.queue)
+	}
+	s.queue = append(s.queue, nil)
+	copy(s.queue[position+1:], s.queue[position:])
+	s.queue[position] = job
+
+	s.dispatch()
+	return nil
+}
+
+// Status returns a snapshot of running and queued jobs
+func (s *Scheduler) Status() QueueStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := QueueStatus{
+		MaxConcurrent: s.maxConcurrent,
+		MaxBackground: s.maxBackground,
+		Running:       make([]*Job, 0, len(s.running)),
+		Queued:        make([]*Job, 0, len(s.queue)),
+	}
+	for _, job := range s.running {
+		copied := *job
+		status.Running = append(status.Running, &copied)
+	}
+	for _, job := range s.queue {
+		copied := *job
+		status.Queued = append(status.Queued, &copied)
+	}
+	return status
+}
@@ -0,0 +1,159 @@
+package mlx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSchedulerRunsInteractiveJobsBeforeBackground(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(1, 1)
+	running := s.enqueue("embed", "m", ClassBackground)
+	background := s.enqueue("embed", "m", ClassBackground)
+	interactive := s.enqueue("transcribe", "m", ClassInteractive)
+
+	status := s.Status()
+	if len(status.Running) != 1 || status.Running[0].ID != running.ID {
+		t.Fatalf("expected first job to run, got %+v", status.Running)
+	}
+	if len(status.Queued) != 2 || status.Queued[0].ID != interactive.ID || status.Queued[1].ID != background.ID {
+		t.Fatalf("expected interactive job to be queued ahead of background, got %+v", status.Queued)
+	}
+
+	s.finish(running, false)
+	select {
+	case <-interactive.ready:
+	default:
+		t.Fatal("expected interactive job to be dispatched next")
+	}
+}
+
+func TestSchedulerLimitsBackgroundJobs(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(2, 1)
+	s.enqueue("embed", "m", ClassBackground)
+	blocked := s.enqueue("embed", "m", ClassBackground)
+	interactive := s.enqueue("transcribe", "m", ClassInteractive)
+
+	select {
+	case <-blocked.ready:
+		t.Fatal("expected second background job to wait for the background limit")
+	default:
+	}
+	select {
+	case <-interactive.ready:
+	default:
+		t.Fatal("expected interactive job to use the free slot")
+	}
+}
+
+func TestSchedulerMoveReordersQueue(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(1, 1)
+	s.enqueue("transcribe", "m", ClassInteractive)
+	first := s.enqueue("transcribe", "m", ClassInteractive)
+	second := s.enqueue("transcribe", "m", ClassInteractive)
+
+	if err := s.Move(second.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	queued := s.Status().Queued
+	if queued[0].ID != second.ID || queued[1].ID != first.ID {
+		t.Fatalf("expected %s to be moved to the front, got %+v", second.ID, queued)
+	}
+	if err := s.Move("job-missing", 0); err == nil {
+		t.Fatal("expected moving an unknown job to fail")
+	}
+}
+
+func TestSchedulerPreemptsBackgroundJobs(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(1, 1)
+	background := s.enqueue("embed", "m", ClassBackground)
+	queued := s.enqueue("embed", "m", ClassBackground)
+	interactive := s.enqueue("transcribe", "m", ClassInteractive)
+
+	if background.ctx.Err() == nil {
+		t.Fatal("expected the running background job to be cancelled for the interactive one")
+	}
+	if !s.finish(background, true) {
+		t.Fatal("expected the preempted job to be queued again")
+	}
+	select {
+	case <-interactive.ready:
+	default:
+		t.Fatal("expected the interactive job to take the freed slot")
+	}
+	status := s.Status()
+	if len(status.Queued) != 2 || status.Queued[0].ID != background.ID || status.Queued[0].Preempted != 1 || status.Queued[1].ID != queued.ID {
+		t.Fatalf("expected the preempted job queued ahead of the other background job, got %+v", status.Queued)
+	}
+
+	s.finish(interactive, false)
+	select {
+	case <-background.ready:
+	default:
+		t.Fatal("expected the preempted job to run again after the interactive one")
+	}
+	if background.ctx.Err() != nil {
+		t.Fatal("expected the rerun to get a fresh context")
+	}
+}
+
+func TestSchedulerKeepsJobsThatFinishedDespitePreemption(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(1, 1)
+	background := s.enqueue("embed", "m", ClassBackground)
+	s.enqueue("transcribe", "m", ClassInteractive)
+	if s.finish(background, false) {
+		t.Fatal("expected a job that succeeded before noticing the preemption not to run again")
+	}
+	if queued := s.Status().Queued; len(queued) != 0 {
+		t.Fatalf("expected nothing queued, got %+v", queued)
+	}
+}
+
+func TestSchedulerDoesNotPreemptPromotedJobs(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(1, 1)
+	promoted := s.enqueue("embed", "m", ClassBackground)
+	promoted.Promoted = true
+	s.enqueue("transcribe", "m", ClassInteractive)
+	if promoted.ctx.Err() != nil {
+		t.Fatal("expected a promoted job not to be preempted")
+	}
+}
+
+func TestSchedulerRunRetriesPreemptedJobs(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(1, 1)
+	started := make(chan struct{}, 2)
+	runs := 0
+	done := make(chan error)
+	go func() {
+		done <- s.Run("embed", "m", ClassBackground, func(ctx context.Context) error {
+			runs++
+			started <- struct{}{}
+			if runs == 1 {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+	<-started
+
+	if err := s.Run("transcribe", "m", ClassInteractive, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil || runs != 2 {
+		t.Fatalf("expected the background job to succeed on its second run, got %v after %d runs", err, runs)
+	}
+}
@@ -0,0 +1,186 @@
+package mlx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Eviction policies for jobs that don't fit in free unified memory
+const (
+	EvictNone = "none"  // refuse the job with a clear error
+	EvictChat = "evict" // stop the chat model for the job when it's idle and restart it afterwards
+)
+
+// memoryHeadroomGB is kept free for macOS and containers when admitting a model
+const memoryHeadroomGB = 2
+
+// chatIdleAfter is how long the chat model must go without requests before
+// the evict policy may stop it for a job
+const chatIdleAfter = 5 * time.Minute
+
+var (
+	evictionPolicy   = EvictNone
+	evictionPolicyMu sync.RWMutex
+)
+
+// MemoryError is returned when a model doesn't fit in free memory
+type MemoryError struct {
+	ModelID  string
+	NeededGB int
+	FreeGB   int
+	InUse    string // Chat model the evict policy left running because it isn't idle
+}
+
+func (e *MemoryError) Error() string {
+	if e.InUse != "" {
+		return fmt.Sprintf("not enough free memory for %s: needs ~%dGB, ~%dGB free. The chat model %s is in use; it is only stopped for jobs after %s without requests",
+			e.ModelID, e.NeededGB, e.FreeGB, e.InUse, chatIdleAfter)
+	}
+	return fmt.Sprintf("not enough free memory for %s: needs ~%dGB, ~%dGB free (%dGB kept for the system). Stop a running model or set ai.eviction_policy: evict",
+		e.ModelID, e.NeededGB, e.FreeGB, memoryHeadroomGB)
+}
+
+// SetEvictionPolicy sets how jobs that don't fit in memory are handled
+func SetEvictionPolicy(policy string) {
+	evictionPolicyMu.Lock()
+	defer evictionPolicyMu.Unlock()
+	if policy == EvictChat {
+		evictionPolicy = EvictChat
+	} else {
+		evictionPolicy = EvictNone
+	}
+}
+
+func getEvictionPolicy() string {
+	evictionPolicyMu.RLock()
+	defer evictionPolicyMu.RUnlock()
+	return evictionPolicy
+}
+
+// FreeMemoryGB returns reclaimable unified memory in GB (0 if unknown)
+func FreeMemoryGB() int {
+	if runtime.GOOS != "darwin" {
+		return 0
+	}
+	output, err := exec.Command("vm_stat").Output()
+	if err != nil {
+		return 0
+	}
+	return int(parseVMStat(string(output)) / (1024 * 1024 * 1024))
+}
+
+var (
+	vmStatPageSize = regexp.MustCompile(`page size of (\d+) bytes`)
+	vmStatLine     = regexp.MustCompile(`^(Pages [a-z ]+):\s+(\d+)\.?$`)
+)
+
+// parseVMStat sums free, inactive, speculative and purgeable pages from vm_stat output
+func parseVMStat(output string) uint64 {
+	pageSize := uint64(4096)
+	if m := vmStatPageSize.FindStringSubmatch(output); m != nil {
+		if n, err := strconv.ParseUint(m[1], 10, 64); err == nil {
+			pageSize = n
+		}
+	}
+
+	var pages uint64
+	for _, line := range strings.Split(output, "\n") {
+		m := vmStatLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		switch m[1] {
+		case "Pages free", "Pages inactive", "Pages speculative", "Pages purgeable":
+			n, _ := strconv.ParseUint(m[2], 10, 64)
+			pages += n
+		}
+	}
+	return pages * pageSize
+}
+
+// checkMemory returns a MemoryError if modelID doesn't fit in free memory plus reclaimGB
+func checkMemory(modelID string, reclaimGB int) error {
+	free := FreeMemoryGB()
+	if free == 0 {
+		return nil // Can't determine, assume ok
+	}
+	needed := EstimateModelRAM(modelID)
+	if needed > free+reclaimGB-memoryHeadroomGB {
+		return &MemoryError{ModelID: modelID, NeededGB: needed, FreeGB: free + reclaimGB}
+	}
+	return nil
+}
+
+// TrackRequest marks the chat model busy until the returned func is called,
+// so the evict policy doesn't stop it in the middle of a conversation
+func (s *Service) TrackRequest() func() {
+	s.mu.Lock()
+	s.inFlight++
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.lastUsed = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// chatIdle reports whether the chat model has no requests in flight and had
+// none for chatIdleAfter. Callers hold s.mu.
+func (s *Service) chatIdle(now time.Time) bool {
+	return s.inFlight == 0 && now.Sub(s.lastUsed) >= chatIdleAfter
+}
+
+// runJob runs a one-shot model job once the scheduler admits it and enough memory is
+// available. With the evict policy an idle chat model is stopped for the job and
+// restarted afterwards.
+func (s *Service) runJob(kind, modelID, class string, fn func(ctx context.Context) error) error {
+	return scheduler.Run(kind, modelID, class, func(ctx context.Context) error {
+		return s.admitJob(ctx, modelID, fn)
+	})
+}
+
+func (s *Service) admitJob(ctx context.Context, modelID string, fn func(ctx context.Context) error) error {
+	err := checkMemory(modelID, 0)
+	if err == nil {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	evicted := ""
+	if getEvictionPolicy() == EvictChat && s.isRunning() {
+		evicted = s.activeModel
+		if checkMemory(modelID, EstimateModelRAM(evicted)) != nil {
+			evicted = "" // Wouldn't fit even with the chat model gone
+		} else if !s.chatIdle(time.Now()) {
+			if memErr, ok := err.(*MemoryError); ok {
+				memErr.InUse = evicted
+			}
+			evicted = ""
+		} else {
+			log.Printf("MLX: stopping %s to make room for %s", evicted, modelID)
+			s.stopServer()
+		}
+	}
+	s.mu.Unlock()
+
+	if evicted == "" {
+		return err
+	}
+
+	defer func() {
+		if err := s.Run(evicted); err != nil {
+			log.Printf("MLX: failed to restart %s after job: %v", evicted, err)
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,43 @@
+package mlx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseVMStatCountsReclaimablePages(t *testing.T) {
+	t.Parallel()
+
+	output := `Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                               10000.
+Pages active:                            500000.
+Pages inactive:                           20000.
+Pages speculative:                         3000.
+Pages throttled:                              0.
+Pages wired down:                        200000.
+Pages purgeable:                           1000.
+"Translation faults":                 123456789.
+`
+
+	want := uint64(10000+20000+3000+1000) * 16384
+	if got := parseVMStat(output); got != want {
+		t.Fatalf("expected %d bytes, got %d", want, got)
+	}
+}
+
+func TestChatIdleTracksRequests(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{}
+	done := s.TrackRequest()
+	if s.chatIdle(time.Now().Add(time.Hour)) {
+		t.Fatalf("expected the chat model to be busy with a request in flight")
+	}
+	done()
+	if s.chatIdle(time.Now()) {
+		t.Fatalf("expected the chat model to be busy right after a request")
+	}
+	if !s.chatIdle(time.Now().Add(chatIdleAfter)) {
+		t.Fatalf("expected the chat model to be idle %s after its last request", chatIdleAfter)
+	}
+}
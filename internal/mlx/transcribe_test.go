@@ -0,0 +1,18 @@
+package mlx
+
+import "testing"
+
+func TestParseTranscript(t *testing.T) {
+	t.Parallel()
+	output := "Fetching 4 files\n" + `{"text": " Hello there. General Kenobi.", "language": "en", "segments": [{"id": 0, "start": 0.0, "end": 1.5, "text": "Hello there."}, {"id": 1, "start": 1.5, "end": 3.25, "text": "General Kenobi."}]}` + "\n"
+	tr, err := parseTranscript([]byte(output))
+	if err != nil {
+		t.Fatalf("parseTranscript: %v", err)
+	}
+	if tr.Text != "Hello there. General Kenobi." || tr.Language != "en" || tr.Duration != 3.25 || len(tr.Segments) != 2 || tr.Segments[1].Text != "General Kenobi." {
+		t.Fatalf("transcript = %+v", tr)
+	}
+	if _, err := parseTranscript([]byte("Traceback (most recent call last)")); err == nil {
+		t.Fatal("parseTranscript accepted output without a transcript")
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -27,23 +29,32 @@ type Client interface {
 	StartContainer(ctx context.Context, id string) error
 	StopContainer(ctx context.Context, id string, timeout int) error
 	RemoveContainer(ctx context.Context, id string, force bool) error
+	RenameContainer(ctx context.Context, id, name string) error
 	ListContainers(ctx context.Context, all bool) ([]Container, error)
 	InspectContainer(ctx context.Context, id string) (*ContainerInspect, error)
 	ContainerLogs(ctx context.Context, id string, opts LogOpts) (io.ReadCloser, error)
+	ContainerEvents(ctx context.Context) (<-chan Event, error)
+	WaitContainer(ctx context.Context, id string) (int, error)
 
 	// Image operations
 	PullImage(ctx context.Context, image string) error
+	SetPullMirror(mirror PullMirror)
 	BuildImage(ctx context.Context, opts BuildOpts) (string, error)
 	ListImages(ctx context.Context) ([]Image, error)
+	ImageHistory(ctx context.Context, image string) ([]ImageLayer, error)
 	RemoveImage(ctx context.Context, id string, force bool) error
 
+	// Pod operations
+	CreatePod(ctx context.Context, opts CreatePodOpts) (string, error)
+	RemovePod(ctx context.Context, name string, force bool) error
+
 	// Network operations
 	CreateNetwork(ctx context.Context, name string) error
 	RemoveNetwork(ctx context.Context, name string) error
 	ListNetworks(ctx context.Context) ([]Network, error)
 
 	// Volume operations
-	CreateVolume(ctx context.Context, name string) error
+	CreateVolume(ctx context.Context, name string, labels map[string]string) error
 	RemoveVolume(ctx context.Context, name string, force bool) error
 	ListVolumes(ctx context.Context) ([]Volume, error)
 
@@ -52,6 +63,11 @@ type Client interface {
 	ExecCreateDetached(ctx context.Context, containerID string, cmd []string) (string, error)
 	ExecStart(ctx context.Context, execID string) (string, error)
 	ExecResize(ctx context.Context, execID string, height, width int) error
+	ExecInspect(ctx context.Context, execID string) (*ExecInspect, error)
+
+	// Files
+	CopyFromContainer(ctx context.Context, id, path string) (io.ReadCloser, error)
+	CopyToContainer(ctx context.Context, id, dir string, archive io.Reader) error
 
 	// Stats
 	ContainerStats(ctx context.Context, id string) (*ContainerStatsResult, error)
@@ -64,11 +80,13 @@ type Client interface {
 
 // ContainerStatsResult holds resource usage stats for a container
 type ContainerStatsResult struct {
-	CPUPercent float64 `json:"cpu_percent"`
-	MemUsage   int64   `json:"mem_usage"`  // bytes
-	MemLimit   int64   `json:"mem_limit"`  // bytes
-	NetInput   int64   `json:"net_input"`  // bytes
-	NetOutput  int64   `json:"net_output"` // bytes
+	CPUPercent  float64 `json:"cpu_percent"`
+	MemUsage    int64   `json:"mem_usage"`    // bytes
+	MemLimit    int64   `json:"mem_limit"`    // bytes
+	NetInput    int64   `json:"net_input"`    // bytes
+	NetOutput   int64   `json:"net_output"`   // bytes
+	BlockInput  int64   `json:"block_input"`  // bytes read from disk
+	BlockOutput int64   `json:"block_output"` // bytes written to disk
 }
 
 type dockerCPUUsage struct {
@@ -87,6 +105,15 @@ type dockerMemoryStats struct {
 	Limit uint64 `json:"limit"`
 }
 
+type dockerBlkioEntry struct {
+	Op    string `json:"op"`
+	Value uint64 `json:"value"`
+}
+
+type dockerBlkioStats struct {
+	IOServiceBytesRecursive []dockerBlkioEntry `json:"io_service_bytes_recursive"`
+}
+
 type dockerNetworkStats struct {
 	RXBytes uint64 `json:"rx_bytes"`
 	TXBytes uint64 `json:"tx_bytes"`
@@ -106,6 +133,18 @@ type CreateContainerOpts struct {
 	Labels         map[string]string
 	Memory         int64 // Memory limit in bytes
 	CPUs           float64
+	Pod            string // Pod to join; it owns the ports and networks, so those are ignored
+}
+
+// CreatePodOpts contains options for creating a pod. Its containers share
+// one network namespace and reach each other on localhost.
+type CreatePodOpts struct {
+	Name           string
+	Ports          map[string]string // container:host
+	ExposeExternal bool              // If true, bind to 0.0.0.0; if false, bind to 127.0.0.1
+	Networks       []string
+	Hosts          []string // Extra /etc/hosts entries as name:ip
+	Labels         map[string]string
 }
 
 // FlexibleTime handles Podman's Created field which can be int64 or string
@@ -163,10 +202,11 @@ type PortMapping struct {
 
 // ContainerInspect holds detailed container information
 type ContainerInspect struct {
-	ID      string `json:"Id"`
-	Name    string `json:"Name"`
-	Created string `json:"Created"`
-	State   struct {
+	ID           string `json:"Id"`
+	Name         string `json:"Name"`
+	Created      string `json:"Created"`
+	RestartCount int    `json:"RestartCount"` // Restarts by the container's restart policy
+	State        struct {
 		Status     string `json:"Status"`
 		Running    bool   `json:"Running"`
 		Paused     bool   `json:"Paused"`
@@ -174,6 +214,7 @@ type ContainerInspect struct {
 		Dead       bool   `json:"Dead"`
 		Pid        int    `json:"Pid"`
 		ExitCode   int    `json:"ExitCode"`
+		Error      string `json:"Error"`
 		StartedAt  string `json:"StartedAt"`
 		FinishedAt string `json:"FinishedAt"`
 	} `json:"State"`
@@ -189,6 +230,16 @@ type ContainerInspect struct {
 		Ports     map[string][]PortBinding  `json:"Ports"`
 		Networks  map[string]NetworkSetting `json:"Networks"`
 	} `json:"NetworkSettings"`
+	Mounts []Mount `json:"Mounts"`
+}
+
+// Mount is a volume or bind mount of a container
+type Mount struct {
+	Type        string `json:"Type"` // volume or bind
+	Name        string `json:"Name"` // Volume name, for volume mounts
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	RW          bool   `json:"RW"`
 }
 
 // PortBinding represents a port binding
@@ -204,6 +255,18 @@ type NetworkSetting struct {
 	Gateway   string `json:"Gateway"`
 }
 
+// Event is a container event from Podman's event stream, such as start,
+// died or health_status
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"` // Container name, labels, exit code, health
+	} `json:"Actor"`
+	TimeNano int64 `json:"timeNano"`
+}
+
 // LogOpts holds options for fetching container logs
 type LogOpts struct {
 	Follow     bool
@@ -232,6 +295,14 @@ type Image struct {
 	Size        int64        `json:"Size"`
 }
 
+// ImageLayer is one entry of an image's history
+type ImageLayer struct {
+	ID        string       `json:"Id"`
+	Created   FlexibleTime `json:"Created"`
+	CreatedBy string       `json:"CreatedBy"`
+	Size      int64        `json:"Size"`
+}
+
 // Network represents a Podman network
 type Network struct {
 	Name    string `json:"name"`
@@ -242,10 +313,16 @@ type Network struct {
 
 // Volume represents a Podman volume
 type Volume struct {
-	Name       string `json:"Name"`
-	Driver     string `json:"Driver"`
-	Mountpoint string `json:"Mountpoint"`
-	CreatedAt  string `json:"CreatedAt"`
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	CreatedAt  string            `json:"CreatedAt"`
+	Labels     map[string]string `json:"Labels"`
+}
+
+// PullMirror rewrites image references to a pull-through registry mirror
+type PullMirror interface {
+	MirrorRef(ctx context.Context, image string) (string, bool)
 }
 
 // client implements the Client interface
@@ -253,6 +330,7 @@ type client struct {
 	httpClient *http.Client
 	baseURL    string
 	socketPath string
+	mirror     PullMirror
 }
 
 // NewClient creates a new Podman client
@@ -299,6 +377,18 @@ func (c *client) request(ctx context.Context, method, path string, body io.Reade
 	return c.httpClient.Do(req)
 }
 
+// stream makes a GET request whose response stays open until ctx is done, so
+// it isn't cut off by the client timeout
+func (c *client) stream(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	return streamClient.Do(req)
+}
+
 // Ping checks if Podman is accessible
 func (c *client) Ping(ctx context.Context) error {
 	resp, err := c.request(ctx, "GET", "/_ping", nil)
@@ -355,7 +445,7 @@ func (c *client) CreateContainer(ctx context.Context, opts CreateContainerOpts)
 				})
 			} else {
 				// Named volume - use "volumes" field with dest/name format
-				_ = c.CreateVolume(ctx, source) // Ignore error if already exists
+				_ = c.CreateVolume(ctx, source, nil) // Ignore error if already exists
 				volumes = append(volumes, map[string]interface{}{
 					"dest": destination,
 					"name": source,
@@ -374,6 +464,13 @@ func (c *client) CreateContainer(ctx context.Context, opts CreateContainerOpts)
 		"working_dir":  opts.WorkingDir,
 		"labels":       opts.Labels,
 	}
+	if opts.Pod != "" {
+		// The pod's infra container holds the network namespace
+		spec["pod"] = opts.Pod
+		delete(spec, "portmappings")
+		delete(spec, "netns")
+		opts.Networks = nil
+	}
 
 	// Only add mounts if there are any
 	if len(mounts) > 0 {
@@ -474,6 +571,23 @@ func (c *client) StopContainer(ctx context.Context, id string, timeout int) erro
 	return nil
 }
 
+// RenameContainer gives a container a new name
+func (c *client) RenameContainer(ctx context.Context, id, name string) error {
+	path := fmt.Sprintf("/containers/%s/rename?name=%s", id, url.QueryEscape(name))
+	resp, err := c.request(ctx, "POST", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to rename container: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to rename container (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
+
 // RemoveContainer removes a container
 func (c *client) RemoveContainer(ctx context.Context, id string, force bool) error {
 	path := fmt.Sprintf("/containers/%s?force=%t", id, force)
@@ -546,7 +660,13 @@ func (c *client) ContainerLogs(ctx context.Context, id string, opts LogOpts) (io
 		path += "&since=" + opts.Since
 	}
 
-	resp, err := c.request(ctx, "GET", path, nil)
+	var resp *http.Response
+	var err error
+	if opts.Follow {
+		resp, err = c.stream(ctx, path)
+	} else {
+		resp, err = c.request(ctx, "GET", path, nil)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container logs: %w", err)
 	}
@@ -559,6 +679,65 @@ func (c *client) ContainerLogs(ctx context.Context, id string, opts LogOpts) (io
 	return resp.Body, nil
 }
 
+// ContainerEvents streams container events until ctx is done or the stream
+// breaks; the channel is closed when it ends
+func (c *client) ContainerEvents(ctx context.Context) (<-chan Event, error) {
+	filters := url.QueryEscape(`{"type":["container"]}`)
+	resp, err := c.stream(ctx, "/events?stream=true&filters="+filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to subscribe to events (status %d)", resp.StatusCode)
+	}
+
+	events := make(chan Event, 16)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var e Event
+			if err := dec.Decode(&e); err != nil {
+				return
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// WaitContainer blocks until a container stops and returns its exit code
+func (c *client) WaitContainer(ctx context.Context, id string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+fmt.Sprintf("/containers/%s/wait", id), nil)
+	if err != nil {
+		return 0, err
+	}
+	waitClient := *c.httpClient
+	waitClient.Timeout = 0
+	resp, err := waitClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to wait for container: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("failed to wait for container (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var exitCode int
+	if err := json.NewDecoder(resp.Body).Decode(&exitCode); err != nil {
+		return 0, fmt.Errorf("failed to decode exit code: %w", err)
+	}
+	return exitCode, nil
+}
+
 // PullImage pulls an image from a registry
 func (c *client) PullImage(ctx context.Context, image string) error {
 	// Podman requires fully-qualified image names — add docker.io/library/ for short names
@@ -567,7 +746,28 @@ func (c *client) PullImage(ctx context.Context, image string) error {
 	} else if !strings.Contains(image, ".") && strings.Count(image, "/") == 1 {
 		image = "docker.io/" + image
 	}
-	path := fmt.Sprintf("/images/pull?reference=%s", image)
+
+	// Go through the pull cache when one is configured, falling back to a direct pull
+	if c.mirror != nil {
+		if ref, ok := c.mirror.MirrorRef(ctx, image); ok {
+			if err := c.pull(ctx, ref, false); err == nil {
+				if err := c.tagImage(ctx, ref, image); err == nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	return c.pull(ctx, image, true)
+}
+
+// SetPullMirror routes Docker Hub pulls through a registry mirror (nil disables it)
+func (c *client) SetPullMirror(mirror PullMirror) {
+	c.mirror = mirror
+}
+
+func (c *client) pull(ctx context.Context, image string, tlsVerify bool) error {
+	path := fmt.Sprintf("/images/pull?reference=%s&tlsVerify=%t", image, tlsVerify)
 	resp, err := c.request(ctx, "POST", path, nil)
 	if err != nil {
 		return fmt.Errorf("failed to pull image: %w", err)
@@ -585,6 +785,26 @@ func (c *client) PullImage(ctx context.Context, image string) error {
 	return nil
 }
 
+// tagImage tags source with target's repository and tag
+func (c *client) tagImage(ctx context.Context, source, target string) error {
+	repo, tag := target, "latest"
+	if i := strings.LastIndex(target, ":"); i > strings.LastIndex(target, "/") {
+		repo, tag = target[:i], target[i+1:]
+	}
+	path := fmt.Sprintf("/images/%s/tag?repo=%s&tag=%s", url.PathEscape(source), url.QueryEscape(repo), url.QueryEscape(tag))
+	resp, err := c.request(ctx, "POST", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to tag image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to tag image (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
 // BuildImage builds an image from a Dockerfile
 func (c *client) BuildImage(ctx context.Context, opts BuildOpts) (string, error) {
 	// TODO: Implement image building with tar context
@@ -612,6 +832,27 @@ func (c *client) ListImages(ctx context.Context) ([]Image, error) {
 	return images, nil
 }
 
+// ImageHistory returns the layers of an image, newest first
+func (c *client) ImageHistory(ctx context.Context, image string) ([]ImageLayer, error) {
+	resp, err := c.request(ctx, "GET", "/images/"+url.PathEscape(image)+"/history", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image history: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get image history (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var layers []ImageLayer
+	if err := json.NewDecoder(resp.Body).Decode(&layers); err != nil {
+		return nil, fmt.Errorf("failed to decode image history: %w", err)
+	}
+
+	return layers, nil
+}
+
 // RemoveImage removes an image
 func (c *client) RemoveImage(ctx context.Context, id string, force bool) error {
 	path := fmt.Sprintf("/images/%s?force=%t", id, force)
@@ -629,6 +870,88 @@ func (c *client) RemoveImage(ctx context.Context, id string, force bool) error {
 	return nil
 }
 
+// CreatePod creates a pod whose ports and networks its containers share
+func (c *client) CreatePod(ctx context.Context, opts CreatePodOpts) (string, error) {
+	hostIP := "127.0.0.1"
+	if opts.ExposeExternal {
+		hostIP = "0.0.0.0"
+	}
+	portMappings := make([]map[string]interface{}, 0)
+	for containerPort, hostPort := range opts.Ports {
+		cPort, _ := strconv.Atoi(containerPort)
+		hPort, _ := strconv.Atoi(hostPort)
+		portMappings = append(portMappings, map[string]interface{}{
+			"container_port": cPort,
+			"host_port":      hPort,
+			"host_ip":        hostIP,
+		})
+	}
+
+	spec := map[string]interface{}{
+		"name":         opts.Name,
+		"portmappings": portMappings,
+		"labels":       opts.Labels,
+		"netns":        map[string]interface{}{"nsmode": "bridge"},
+	}
+	if len(opts.Hosts) > 0 {
+		spec["hostadd"] = opts.Hosts
+	}
+	if len(opts.Networks) > 0 {
+		networksMap := make(map[string]interface{})
+		for _, network := range opts.Networks {
+			networksMap[network] = map[string]interface{}{
+				"aliases": []string{opts.Name},
+			}
+		}
+		spec["Networks"] = networksMap
+	}
+
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pod spec: %w", err)
+	}
+
+	resp, err := c.request(ctx, "POST", "/pods/create", strings.NewReader(string(body)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create pod: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create pod (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result struct {
+		ID string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.ID, nil
+}
+
+// RemovePod removes a pod; force also removes its containers
+func (c *client) RemovePod(ctx context.Context, name string, force bool) error {
+	path := fmt.Sprintf("/pods/%s", name)
+	if force {
+		path += "?force=true"
+	}
+	resp, err := c.request(ctx, "DELETE", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to remove pod: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to remove pod (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
+
 // CreateNetwork creates a new network
 func (c *client) CreateNetwork(ctx context.Context, name string) error {
 	spec := map[string]interface{}{
@@ -693,11 +1016,14 @@ func (c *client) ListNetworks(ctx context.Context) ([]Network, error) {
 	return networks, nil
 }
 
-// CreateVolume creates a new volume
-func (c *client) CreateVolume(ctx context.Context, name string) error {
+// CreateVolume creates a new volume with optional labels
+func (c *client) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
 	spec := map[string]interface{}{
 		"name": name,
 	}
+	if len(labels) > 0 {
+		spec["labels"] = labels
+	}
 
 	body, err := json.Marshal(spec)
 	if err != nil {
@@ -876,6 +1202,81 @@ func (c *client) ExecResize(ctx context.Context, execID string, height, width in
 	return nil
 }
 
+// ExecInspect is the state of an exec session
+type ExecInspect struct {
+	Running  bool `json:"Running"`
+	ExitCode int  `json:"ExitCode"`
+}
+
+// ExecInspect returns whether an exec session is still running and its exit code
+func (c *client) ExecInspect(ctx context.Context, execID string) (*ExecInspect, error) {
+	resp, err := c.request(ctx, "GET", fmt.Sprintf("/exec/%s/json", execID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to inspect exec (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var inspect ExecInspect
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return nil, fmt.Errorf("failed to decode exec inspect: %w", err)
+	}
+	return &inspect, nil
+}
+
+// ErrNoSuchPath is returned when a path copied from or to doesn't exist in
+// the container
+var ErrNoSuchPath = errors.New("no such file or directory")
+
+// CopyFromContainer returns a tar archive of a file or directory in a
+// container. The archive's top entry is named after the path's last element.
+func (c *client) CopyFromContainer(ctx context.Context, id, path string) (io.ReadCloser, error) {
+	resp, err := c.stream(ctx, fmt.Sprintf("/containers/%s/archive?path=%s", id, url.QueryEscape(path)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrNoSuchPath, path)
+		}
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to copy from container (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+	return resp.Body, nil
+}
+
+// CopyToContainer extracts a tar archive into a directory in a container
+func (c *client) CopyToContainer(ctx context.Context, id, dir string, archive io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.baseURL+fmt.Sprintf("/containers/%s/archive?path=%s", id, url.QueryEscape(dir)), archive)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	// Uploads can outlast the client timeout, like streams
+	uploadClient := *c.httpClient
+	uploadClient.Timeout = 0
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrNoSuchPath, dir)
+		}
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to copy to container (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
 // ContainerStats returns resource usage stats for a container
 func (c *client) ContainerStats(ctx context.Context, id string) (*ContainerStatsResult, error) {
 	resp, err := c.request(ctx, "GET", fmt.Sprintf("/containers/%s/stats?stream=false", id), nil)
@@ -920,26 +1321,32 @@ func decodeContainerStats(r io.Reader) (*ContainerStatsResult, error) {
 
 	type statsPayload struct {
 		Stats []struct {
-			CPU       float64 `json:"CPU"`
-			MemUsage  int64   `json:"MemUsage"`
-			MemLimit  int64   `json:"MemLimit"`
-			NetInput  int64   `json:"NetInput"`
-			NetOutput int64   `json:"NetOutput"`
+			CPU         float64 `json:"CPU"`
+			MemUsage    int64   `json:"MemUsage"`
+			MemLimit    int64   `json:"MemLimit"`
+			NetInput    int64   `json:"NetInput"`
+			NetOutput   int64   `json:"NetOutput"`
+			BlockInput  int64   `json:"BlockInput"`
+			BlockOutput int64   `json:"BlockOutput"`
 		} `json:"Stats"`
 
-		CPU       float64 `json:"CPU"`
-		MemUsage  int64   `json:"MemUsage"`
-		MemLimit  int64   `json:"MemLimit"`
-		NetInput  int64   `json:"NetInput"`
-		NetOutput int64   `json:"NetOutput"`
+		CPU         float64 `json:"CPU"`
+		MemUsage    int64   `json:"MemUsage"`
+		MemLimit    int64   `json:"MemLimit"`
+		NetInput    int64   `json:"NetInput"`
+		NetOutput   int64   `json:"NetOutput"`
+		BlockInput  int64   `json:"BlockInput"`
+		BlockOutput int64   `json:"BlockOutput"`
 
 		CPUPercentText string `json:"cpu_percent"`
 		MemUsageText   string `json:"mem_usage"`
 		NetIOText      string `json:"net_io"`
+		BlockIOText    string `json:"block_io"`
 
 		CPUStats    dockerCPUStats                `json:"cpu_stats"`
 		PreCPUStats dockerCPUStats                `json:"precpu_stats"`
 		MemoryStats dockerMemoryStats             `json:"memory_stats"`
+		BlkioStats  dockerBlkioStats              `json:"blkio_stats"`
 		Networks    map[string]dockerNetworkStats `json:"networks"`
 	}
 
@@ -951,21 +1358,25 @@ func decodeContainerStats(r io.Reader) (*ContainerStatsResult, error) {
 	if len(raw.Stats) > 0 {
 		s := raw.Stats[0]
 		return &ContainerStatsResult{
-			CPUPercent: s.CPU,
-			MemUsage:   s.MemUsage,
-			MemLimit:   s.MemLimit,
-			NetInput:   s.NetInput,
-			NetOutput:  s.NetOutput,
+			CPUPercent:  s.CPU,
+			MemUsage:    s.MemUsage,
+			MemLimit:    s.MemLimit,
+			NetInput:    s.NetInput,
+			NetOutput:   s.NetOutput,
+			BlockInput:  s.BlockInput,
+			BlockOutput: s.BlockOutput,
 		}, nil
 	}
 
 	if raw.CPU != 0 || raw.MemUsage != 0 || raw.MemLimit != 0 || raw.NetInput != 0 || raw.NetOutput != 0 {
 		return &ContainerStatsResult{
-			CPUPercent: raw.CPU,
-			MemUsage:   raw.MemUsage,
-			MemLimit:   raw.MemLimit,
-			NetInput:   raw.NetInput,
-			NetOutput:  raw.NetOutput,
+			CPUPercent:  raw.CPU,
+			MemUsage:    raw.MemUsage,
+			MemLimit:    raw.MemLimit,
+			NetInput:    raw.NetInput,
+			NetOutput:   raw.NetOutput,
+			BlockInput:  raw.BlockInput,
+			BlockOutput: raw.BlockOutput,
 		}, nil
 	}
 
@@ -982,6 +1393,10 @@ func decodeContainerStats(r io.Reader) (*ContainerStatsResult, error) {
 			result.NetInput = input
 			result.NetOutput = output
 		}
+		if input, output, ok := parseUsagePair(raw.BlockIOText); ok {
+			result.BlockInput = input
+			result.BlockOutput = output
+		}
 		return result, nil
 	}
 
@@ -995,6 +1410,14 @@ func decodeContainerStats(r io.Reader) (*ContainerStatsResult, error) {
 			result.NetInput += int64(network.RXBytes)
 			result.NetOutput += int64(network.TXBytes)
 		}
+		for _, entry := range raw.BlkioStats.IOServiceBytesRecursive {
+			switch strings.ToLower(entry.Op) {
+			case "read":
+				result.BlockInput += int64(entry.Value)
+			case "write":
+				result.BlockOutput += int64(entry.Value)
+			}
+		}
 		return result, nil
 	}
 
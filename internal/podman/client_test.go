@@ -85,3 +85,33 @@ func TestDecodeContainerStatsSupportsDockerCompatiblePayloads(t *testing.T) {
 		t.Fatalf("unexpected docker-compatible stats decode: %+v", stats)
 	}
 }
+
+func TestDecodeContainerStatsBlockIO(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name              string
+		payload           string
+		wantRead, wantOut int64
+	}{
+		{"libpod", `{"Stats": [{"CPU": 1, "BlockInput": 4096, "BlockOutput": 8192}]}`, 4096, 8192},
+		{"summary", `{"cpu_percent": "1%", "block_io": "4.096kB / 8.192kB"}`, 4096, 8192},
+		{"docker", `{
+			"memory_stats": {"usage": 1},
+			"blkio_stats": {"io_service_bytes_recursive": [
+				{"op": "Read", "value": 4000},
+				{"op": "Write", "value": 8000},
+				{"op": "read", "value": 96},
+				{"op": "Total", "value": 12096}
+			]}
+		}`, 4096, 8000},
+	} {
+		stats, err := decodeContainerStats(strings.NewReader(tc.payload))
+		if err != nil {
+			t.Fatalf("%s: decodeContainerStats returned error: %v", tc.name, err)
+		}
+		if stats.BlockInput != tc.wantRead || stats.BlockOutput != tc.wantOut {
+			t.Fatalf("%s: block I/O = %d / %d, want %d / %d", tc.name, stats.BlockInput, stats.BlockOutput, tc.wantRead, tc.wantOut)
+		}
+	}
+}
@@ -0,0 +1,890 @@
+package podman
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory Client for tests and for running basepod without a
+// container runtime (basepod --dev --fake-runtime). Containers run nothing,
+// but a started container answers HTTP on its published host ports so
+// readiness and health checks pass. It is safe for concurrent use.
+type Fake struct {
+	// ServePorts makes started containers listen on their host ports
+	ServePorts bool
+	// ExecOutput, if set, gives the output and exit code of a command run in
+	// a container; by default commands succeed without output
+	ExecOutput func(containerID string, cmd []string) (string, int)
+	// HTTPStatus, if set, gives the status a started container's ports answer
+	// a request for path with, by the container's image; by default 200
+	HTTPStatus func(image, path string) int
+
+	mu         sync.Mutex
+	containers map[string]*fakeContainer // By ID
+	images     map[string]*Image         // By ID
+	volumes    map[string]*Volume
+	networks   map[string]*Network
+	pods       map[string]*fakePod
+	execs      map[string]*fakeExec
+	subs       map[chan Event]struct{}
+	mirror     PullMirror
+}
+
+type fakeContainer struct {
+	info      Container
+	opts      CreateContainerOpts
+	startedAt time.Time
+	stoppedAt time.Time
+	exitCode  int
+	servers   []*http.Server
+	logs      []string
+	waiters   []chan int
+}
+
+type fakePod struct {
+	id      string
+	opts    CreatePodOpts
+	servers []*http.Server
+}
+
+type fakeExec struct {
+	containerID string
+	cmd         []string
+	exitCode    int
+	done        bool
+}
+
+var _ Client = (*Fake)(nil)
+
+// NewFake returns an empty fake runtime whose containers serve their ports
+func NewFake() *Fake {
+	return &Fake{
+		ServePorts: true,
+		containers: map[string]*fakeContainer{},
+		images:     map[string]*Image{},
+		volumes:    map[string]*Volume{},
+		networks:   map[string]*Network{},
+		pods:       map[string]*fakePod{},
+		execs:      map[string]*fakeExec{},
+		subs:       map[chan Event]struct{}{},
+	}
+}
+
+func fakeID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// qualifyImage adds the registry and tag Podman would, so nginx becomes
+// docker.io/library/nginx:latest
+func qualifyImage(ref string) string {
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") && !strings.Contains(ref, "@") {
+		ref += ":latest"
+	}
+	first := ref
+	if i := strings.Index(ref, "/"); i >= 0 {
+		first = ref[:i]
+	} else {
+		return "docker.io/library/" + ref
+	}
+	if !strings.ContainsAny(first, ".:") && first != "localhost" {
+		return "docker.io/" + ref
+	}
+	return ref
+}
+
+// findContainer resolves a container by ID, ID prefix or name. Callers hold mu.
+func (f *Fake) findContainer(id string) *fakeContainer {
+	if c, ok := f.containers[id]; ok {
+		return c
+	}
+	for _, c := range f.containers {
+		if c.info.Names[0] == id || (len(id) >= 12 && strings.HasPrefix(c.info.ID, id)) {
+			return c
+		}
+	}
+	return nil
+}
+
+// findImage resolves an image by ID, ID prefix or reference. Callers hold mu.
+func (f *Fake) findImage(ref string) *Image {
+	if img, ok := f.images[ref]; ok {
+		return img
+	}
+	qualified := qualifyImage(ref)
+	for _, img := range f.images {
+		if len(ref) >= 12 && strings.HasPrefix(img.ID, ref) {
+			return img
+		}
+		for _, tag := range img.RepoTags {
+			if tag == ref || tag == qualified {
+				return img
+			}
+		}
+	}
+	return nil
+}
+
+// emit sends an event to subscribers without blocking. Callers hold mu.
+func (f *Fake) emit(c *fakeContainer, action string) {
+	e := Event{Type: "container", Action: action, TimeNano: time.Now().UnixNano()}
+	e.Actor.ID = c.info.ID
+	e.Actor.Attributes = map[string]string{"name": c.info.Names[0], "image": c.info.Image}
+	for k, v := range c.info.Labels {
+		e.Actor.Attributes[k] = v
+	}
+	if action == "died" {
+		e.Actor.Attributes["containerExitCode"] = strconv.Itoa(c.exitCode)
+	}
+	for ch := range f.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Ping always succeeds
+func (f *Fake) Ping(ctx context.Context) error {
+	return nil
+}
+
+// CreateContainer creates a container from an image that is already present
+func (f *Fake) CreateContainer(ctx context.Context, opts CreateContainerOpts) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if opts.Name != "" && f.findContainer(opts.Name) != nil {
+		return "", fmt.Errorf("failed to create container (status 409): the container name %q is already in use", opts.Name)
+	}
+	img := f.findImage(opts.Image)
+	if img == nil {
+		return "", fmt.Errorf("failed to create container (status 404): %s: image not known", opts.Image)
+	}
+	if opts.Pod != "" && f.pods[opts.Pod] == nil {
+		return "", fmt.Errorf("failed to create container (status 404): no pod with name or ID %s found", opts.Pod)
+	}
+	// Named volumes are created on first use, as Podman does
+	for _, v := range opts.Volumes {
+		source, _, _ := strings.Cut(v, ":")
+		if source != "" && !strings.HasPrefix(source, "/") && f.volumes[source] == nil {
+			f.volumes[source] = &Volume{Name: source, Driver: "local", CreatedAt: time.Now().Format(time.RFC3339)}
+		}
+	}
+
+	id := fakeID()
+	name := opts.Name
+	if name == "" {
+		name = "fake_" + id[:8]
+	}
+	c := &fakeContainer{opts: opts}
+	c.info = Container{
+		ID:      id,
+		Names:   []string{name},
+		Image:   opts.Image,
+		ImageID: img.ID,
+		State:   "created",
+		Status:  "Created",
+		Created: FlexibleTime(time.Now().Unix()),
+		Labels:  opts.Labels,
+	}
+	hostIP := "127.0.0.1"
+	if opts.ExposeExternal {
+		hostIP = "0.0.0.0"
+	}
+	for containerPort, hostPort := range opts.Ports {
+		cp, _ := strconv.Atoi(strings.TrimSuffix(containerPort, "/tcp"))
+		hp, _ := strconv.Atoi(hostPort)
+		c.info.Ports = append(c.info.Ports, PortMapping{HostIP: hostIP, ContainerPort: cp, HostPort: hp, Protocol: "tcp"})
+	}
+	f.containers[id] = c
+	f.emit(c, "create")
+	return id, nil
+}
+
+// StartContainer marks a container running and, with ServePorts, serves
+// HTTP on its host ports
+func (f *Fake) StartContainer(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return fmt.Errorf("failed to start container (status 404): no such container %s", id)
+	}
+	if c.info.State == "running" {
+		return nil
+	}
+	if f.ServePorts {
+		for _, p := range c.info.Ports {
+			if p.HostPort == 0 {
+				continue
+			}
+			srv, err := f.fakeServe(p.HostPort, c.info.Names[0], c.info.Image)
+			if err != nil {
+				f.stopServersLocked(c)
+				return fmt.Errorf("failed to start container (status 500): %v", err)
+			}
+			c.servers = append(c.servers, srv)
+		}
+		// A pod's ports answer while any of its containers runs
+		if pod := f.pods[c.opts.Pod]; pod != nil && len(pod.servers) == 0 {
+			for _, hostPort := range pod.opts.Ports {
+				hp, _ := strconv.Atoi(hostPort)
+				srv, err := f.fakeServe(hp, pod.opts.Name, "")
+				if err != nil {
+					f.stopPodServersLocked(pod)
+					return fmt.Errorf("failed to start container (status 500): %v", err)
+				}
+				pod.servers = append(pod.servers, srv)
+			}
+		}
+	}
+	c.info.State = "running"
+	c.info.Status = "Up"
+	c.startedAt = time.Now()
+	c.logs = append(c.logs, fmt.Sprintf("fake container %s started from %s", c.info.Names[0], c.info.Image))
+	f.emit(c, "start")
+	return nil
+}
+
+// fakeServe answers HTTP on a local port as the named container or pod
+func (f *Fake) fakeServe(port int, name, image string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	status := f.HTTPStatus
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != nil {
+			w.WriteHeader(status(image, r.URL.Path))
+		}
+		fmt.Fprintf(w, "fake container %s\n", name)
+	})}
+	go srv.Serve(ln)
+	return srv, nil
+}
+
+// Close stops containers and pods answering on their host ports, and any
+// started later from doing so, freeing the ports without changing any state
+func (f *Fake) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ServePorts = false
+	for _, c := range f.containers {
+		for _, srv := range c.servers {
+			srv.Close()
+		}
+		c.servers = nil
+	}
+	for _, pod := range f.pods {
+		f.stopPodServersLocked(pod)
+	}
+}
+
+func (f *Fake) stopServersLocked(c *fakeContainer) {
+	for _, srv := range c.servers {
+		srv.Close()
+	}
+	c.servers = nil
+	pod := f.pods[c.opts.Pod]
+	if pod == nil {
+		return
+	}
+	for _, other := range f.containers {
+		if other != c && other.opts.Pod == c.opts.Pod && other.info.State == "running" {
+			return
+		}
+	}
+	f.stopPodServersLocked(pod)
+}
+
+func (f *Fake) stopPodServersLocked(pod *fakePod) {
+	for _, srv := range pod.servers {
+		srv.Close()
+	}
+	pod.servers = nil
+}
+
+// stopLocked stops a running container with the given exit code
+func (f *Fake) stopLocked(c *fakeContainer, exitCode int) {
+	if c.info.State != "running" {
+		return
+	}
+	f.stopServersLocked(c)
+	c.info.State = "exited"
+	c.info.Status = fmt.Sprintf("Exited (%d)", exitCode)
+	c.exitCode = exitCode
+	c.stoppedAt = time.Now()
+	c.logs = append(c.logs, fmt.Sprintf("fake container %s stopped", c.info.Names[0]))
+	for _, ch := range c.waiters {
+		ch <- exitCode
+	}
+	c.waiters = nil
+	f.emit(c, "died")
+}
+
+// StopContainer stops a container; stopping a stopped container is not an error
+func (f *Fake) StopContainer(ctx context.Context, id string, timeout int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return fmt.Errorf("failed to stop container (status 404): no such container %s", id)
+	}
+	f.stopLocked(c, 0)
+	return nil
+}
+
+// Crash stops a running container as if its process exited with exitCode,
+// for testing how basepod reacts to containers dying
+func (f *Fake) Crash(id string, exitCode int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return fmt.Errorf("no such container %s", id)
+	}
+	f.stopLocked(c, exitCode)
+	return nil
+}
+
+// RemoveContainer removes a container, which must be stopped unless force is set
+func (f *Fake) RemoveContainer(ctx context.Context, id string, force bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return fmt.Errorf("failed to remove container (status 404): no such container %s", id)
+	}
+	if c.info.State == "running" {
+		if !force {
+			return fmt.Errorf("failed to remove container (status 409): container %s is running", id)
+		}
+		f.stopLocked(c, 137)
+	}
+	delete(f.containers, c.info.ID)
+	f.emit(c, "remove")
+	return nil
+}
+
+// RenameContainer renames a container
+func (f *Fake) RenameContainer(ctx context.Context, id, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return fmt.Errorf("failed to rename container (status 404): no such container %s", id)
+	}
+	if other := f.findContainer(name); other != nil && other != c {
+		return fmt.Errorf("failed to rename container (status 409): the container name %q is already in use", name)
+	}
+	c.info.Names = []string{name}
+	return nil
+}
+
+// ListContainers lists running containers, or all of them, oldest first
+func (f *Fake) ListContainers(ctx context.Context, all bool) ([]Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	containers := []Container{}
+	for _, c := range f.containers {
+		if all || c.info.State == "running" {
+			containers = append(containers, c.info)
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].Created != containers[j].Created {
+			return containers[i].Created < containers[j].Created
+		}
+		return containers[i].Names[0] < containers[j].Names[0]
+	})
+	return containers, nil
+}
+
+// InspectContainer returns a container's state and configuration
+func (f *Fake) InspectContainer(ctx context.Context, id string) (*ContainerInspect, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return nil, fmt.Errorf("failed to inspect container (status 404): no such container %s", id)
+	}
+	info := &ContainerInspect{ID: c.info.ID, Name: c.info.Names[0], Created: time.Unix(int64(c.info.Created), 0).Format(time.RFC3339)}
+	info.State.Status = c.info.State
+	info.State.Running = c.info.State == "running"
+	info.State.ExitCode = c.exitCode
+	if !c.startedAt.IsZero() {
+		info.State.StartedAt = c.startedAt.Format(time.RFC3339Nano)
+	}
+	if !c.stoppedAt.IsZero() {
+		info.State.FinishedAt = c.stoppedAt.Format(time.RFC3339Nano)
+	}
+	for k, v := range c.opts.Env {
+		info.Config.Env = append(info.Config.Env, k+"="+v)
+	}
+	sort.Strings(info.Config.Env)
+	info.Config.Cmd = c.opts.Command
+	info.Config.Image = c.info.Image
+	info.Config.WorkingDir = c.opts.WorkingDir
+	info.Config.Labels = c.info.Labels
+	info.NetworkSettings.Ports = map[string][]PortBinding{}
+	for _, p := range c.info.Ports {
+		key := fmt.Sprintf("%d/tcp", p.ContainerPort)
+		info.NetworkSettings.Ports[key] = append(info.NetworkSettings.Ports[key], PortBinding{HostIP: p.HostIP, HostPort: strconv.Itoa(p.HostPort)})
+	}
+	info.NetworkSettings.Networks = map[string]NetworkSetting{}
+	for _, n := range c.opts.Networks {
+		info.NetworkSettings.Networks[n] = NetworkSetting{NetworkID: n, IPAddress: "10.89.0.2"}
+	}
+	for _, v := range c.opts.Volumes {
+		parts := strings.Split(v, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		m := Mount{Type: "bind", Source: parts[0], Destination: parts[1], RW: len(parts) < 3 || parts[2] != "ro"}
+		if !strings.HasPrefix(parts[0], "/") {
+			m.Type, m.Name = "volume", parts[0]
+			if vol := f.volumes[parts[0]]; vol != nil {
+				m.Source = vol.Mountpoint
+			}
+		}
+		info.Mounts = append(info.Mounts, m)
+	}
+	return info, nil
+}
+
+// fakeLogFrame wraps a line in Podman's multiplexed stream framing
+func fakeLogFrame(stream byte, line string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+	return append(header, line...)
+}
+
+// ContainerLogs returns the lines the fake logged for a container (start and
+// stop), framed like Podman's log stream. Following does not wait for more.
+func (f *Fake) ContainerLogs(ctx context.Context, id string, opts LogOpts) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return nil, fmt.Errorf("failed to get container logs (status 404)")
+	}
+	lines := c.logs
+	if n, err := strconv.Atoi(opts.Tail); err == nil && n >= 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(fakeLogFrame(1, line+"\n"))
+	}
+	return io.NopCloser(&buf), nil
+}
+
+// ContainerEvents streams container events until ctx is done
+func (f *Fake) ContainerEvents(ctx context.Context) (<-chan Event, error) {
+	ch := make(chan Event, 64)
+	f.mu.Lock()
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// WaitContainer blocks until a container stops and returns its exit code
+func (f *Fake) WaitContainer(ctx context.Context, id string) (int, error) {
+	f.mu.Lock()
+	c := f.findContainer(id)
+	if c == nil {
+		f.mu.Unlock()
+		return 0, fmt.Errorf("failed to wait for container (status 404): no such container %s", id)
+	}
+	if c.info.State != "running" {
+		code := c.exitCode
+		f.mu.Unlock()
+		return code, nil
+	}
+	ch := make(chan int, 1)
+	c.waiters = append(c.waiters, ch)
+	f.mu.Unlock()
+
+	select {
+	case code := <-ch:
+		return code, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// addImageLocked tags a new image, or returns the image already tagged ref
+func (f *Fake) addImageLocked(tags ...string) *Image {
+	for i, tag := range tags {
+		tags[i] = qualifyImage(tag)
+	}
+	// A tag moves to the new image, as it does on rebuild
+	for _, img := range f.images {
+		kept := img.RepoTags[:0]
+		for _, t := range img.RepoTags {
+			if !containsString(tags, t) {
+				kept = append(kept, t)
+			}
+		}
+		img.RepoTags = kept
+	}
+	img := &Image{ID: fakeID(), RepoTags: tags, Created: FlexibleTime(time.Now().Unix()), Size: 10 << 20}
+	f.images[img.ID] = img
+	return img
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PullImage adds an image for ref unless one is already present
+func (f *Fake) PullImage(ctx context.Context, image string) error {
+	if image == "" {
+		return fmt.Errorf("failed to pull image: no image given")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.findImage(image) == nil {
+		f.addImageLocked(image)
+	}
+	return nil
+}
+
+// SetPullMirror records the mirror; the fake never pulls through it
+func (f *Fake) SetPullMirror(mirror PullMirror) {
+	f.mu.Lock()
+	f.mirror = mirror
+	f.mu.Unlock()
+}
+
+// BuildImage adds an image with the build's tags without reading its context
+func (f *Fake) BuildImage(ctx context.Context, opts BuildOpts) (string, error) {
+	if len(opts.Tags) == 0 {
+		return "", fmt.Errorf("build failed: no tags")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addImageLocked(append([]string(nil), opts.Tags...)...).ID, nil
+}
+
+// ListImages lists images, newest first
+func (f *Fake) ListImages(ctx context.Context) ([]Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	images := []Image{}
+	for _, img := range f.images {
+		images = append(images, *img)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Created != images[j].Created {
+			return images[i].Created > images[j].Created
+		}
+		return images[i].ID < images[j].ID
+	})
+	return images, nil
+}
+
+// ImageHistory returns a single layer holding the whole image
+func (f *Fake) ImageHistory(ctx context.Context, image string) ([]ImageLayer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	img := f.findImage(image)
+	if img == nil {
+		return nil, fmt.Errorf("failed to get image history (status 404): %s: image not known", image)
+	}
+	return []ImageLayer{{ID: img.ID, Created: img.Created, CreatedBy: "fake", Size: img.Size}}, nil
+}
+
+// RemoveImage removes an image that no container uses, unless force is set
+func (f *Fake) RemoveImage(ctx context.Context, id string, force bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	img := f.findImage(id)
+	if img == nil {
+		return fmt.Errorf("failed to remove image (status 404): %s: image not known", id)
+	}
+	for _, c := range f.containers {
+		if c.info.ImageID == img.ID {
+			if !force {
+				return fmt.Errorf("failed to remove image (status 409): image used by container %s", c.info.ID[:12])
+			}
+			f.stopLocked(c, 137)
+			delete(f.containers, c.info.ID)
+		}
+	}
+	delete(f.images, img.ID)
+	return nil
+}
+
+// CreatePod creates a pod
+func (f *Fake) CreatePod(ctx context.Context, opts CreatePodOpts) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pods[opts.Name] != nil {
+		return "", fmt.Errorf("failed to create pod (status 409): pod %s already exists", opts.Name)
+	}
+	pod := &fakePod{id: fakeID(), opts: opts}
+	f.pods[opts.Name] = pod
+	return pod.id, nil
+}
+
+// RemovePod removes a pod and, with force, its containers
+func (f *Fake) RemovePod(ctx context.Context, name string, force bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pod := f.pods[name]
+	if pod == nil {
+		return fmt.Errorf("failed to remove pod (status 404): no pod with name or ID %s found", name)
+	}
+	for id, c := range f.containers {
+		if c.opts.Pod != name {
+			continue
+		}
+		if !force {
+			return fmt.Errorf("failed to remove pod (status 409): pod %s has containers", name)
+		}
+		f.stopLocked(c, 137)
+		delete(f.containers, id)
+		f.emit(c, "remove")
+	}
+	f.stopPodServersLocked(pod)
+	delete(f.pods, name)
+	return nil
+}
+
+// CreateNetwork creates a network
+func (f *Fake) CreateNetwork(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.networks[name] != nil {
+		return fmt.Errorf("failed to create network (status 409): network already exists")
+	}
+	f.networks[name] = &Network{Name: name, ID: fakeID(), Driver: "bridge", Created: time.Now().Format(time.RFC3339)}
+	return nil
+}
+
+// RemoveNetwork removes a network
+func (f *Fake) RemoveNetwork(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.networks[name] == nil {
+		return fmt.Errorf("failed to remove network (status 404): network not found")
+	}
+	delete(f.networks, name)
+	return nil
+}
+
+// ListNetworks lists networks
+func (f *Fake) ListNetworks(ctx context.Context) ([]Network, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	networks := []Network{}
+	for _, n := range f.networks {
+		networks = append(networks, *n)
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	return networks, nil
+}
+
+// CreateVolume creates a volume
+func (f *Fake) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.volumes[name] != nil {
+		return fmt.Errorf("failed to create volume (status 409): volume with name %s already exists", name)
+	}
+	f.volumes[name] = &Volume{Name: name, Driver: "local", CreatedAt: time.Now().Format(time.RFC3339), Labels: labels}
+	return nil
+}
+
+// RemoveVolume removes a volume no container mounts, unless force is set
+func (f *Fake) RemoveVolume(ctx context.Context, name string, force bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.volumes[name] == nil {
+		return fmt.Errorf("failed to remove volume (status 404): no volume with name %s found", name)
+	}
+	if !force {
+		for _, c := range f.containers {
+			for _, v := range c.opts.Volumes {
+				if source, _, _ := strings.Cut(v, ":"); source == name {
+					return fmt.Errorf("failed to remove volume (status 409): volume %s is being used by container %s", name, c.info.ID[:12])
+				}
+			}
+		}
+	}
+	delete(f.volumes, name)
+	return nil
+}
+
+// ListVolumes lists volumes
+func (f *Fake) ListVolumes(ctx context.Context) ([]Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	volumes := []Volume{}
+	for _, v := range f.volumes {
+		volumes = append(volumes, *v)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes, nil
+}
+
+// ExecCreate creates an exec session in a running container
+func (f *Fake) ExecCreate(ctx context.Context, containerID string, cmd []string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(containerID)
+	if c == nil || c.info.State != "running" {
+		return "", fmt.Errorf("failed to create exec (status 409): container %s is not running", containerID)
+	}
+	id := fakeID()
+	f.execs[id] = &fakeExec{containerID: c.info.ID, cmd: cmd}
+	return id, nil
+}
+
+// ExecCreateDetached creates an exec session; the fake has no TTYs to skip
+func (f *Fake) ExecCreateDetached(ctx context.Context, containerID string, cmd []string) (string, error) {
+	return f.ExecCreate(ctx, containerID, cmd)
+}
+
+// ExecStart runs an exec session through ExecOutput and returns its output
+// framed like Podman's stream
+func (f *Fake) ExecStart(ctx context.Context, execID string) (string, error) {
+	f.mu.Lock()
+	e := f.execs[execID]
+	run := f.ExecOutput
+	f.mu.Unlock()
+	if e == nil {
+		return "", fmt.Errorf("failed to start exec (status 404): no such exec session %s", execID)
+	}
+
+	output, code := "", 0
+	if run != nil {
+		output, code = run(e.containerID, e.cmd)
+	}
+	f.mu.Lock()
+	e.exitCode = code
+	e.done = true
+	f.mu.Unlock()
+	if output == "" {
+		return "", nil
+	}
+	return string(fakeLogFrame(1, output)), nil
+}
+
+// ExecResize does nothing
+func (f *Fake) ExecResize(ctx context.Context, execID string, height, width int) error {
+	return nil
+}
+
+// ExecInspect returns whether an exec session is still running and its exit code
+func (f *Fake) ExecInspect(ctx context.Context, execID string) (*ExecInspect, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := f.execs[execID]
+	if e == nil {
+		return nil, fmt.Errorf("failed to inspect exec (status 404): no such exec session %s", execID)
+	}
+	return &ExecInspect{Running: !e.done, ExitCode: e.exitCode}, nil
+}
+
+// CopyFromContainer returns an empty tar archive
+func (f *Fake) CopyFromContainer(ctx context.Context, id, path string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	c := f.findContainer(id)
+	f.mu.Unlock()
+	if c == nil {
+		return nil, fmt.Errorf("failed to copy from container (status 404): no such container %s", id)
+	}
+	var buf bytes.Buffer
+	tar.NewWriter(&buf).Close()
+	return io.NopCloser(&buf), nil
+}
+
+// CopyToContainer reads and discards the archive
+func (f *Fake) CopyToContainer(ctx context.Context, id, dir string, archive io.Reader) error {
+	f.mu.Lock()
+	c := f.findContainer(id)
+	f.mu.Unlock()
+	if c == nil {
+		return fmt.Errorf("failed to copy to container (status 404): no such container %s", id)
+	}
+	_, err := io.Copy(io.Discard, archive)
+	return err
+}
+
+// ContainerStats reports an idle container at its memory limit's scale
+func (f *Fake) ContainerStats(ctx context.Context, id string) (*ContainerStatsResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if c == nil || c.info.State != "running" {
+		return nil, fmt.Errorf("failed to get container stats (status 409): container %s is not running", id)
+	}
+	limit := c.opts.Memory
+	if limit == 0 {
+		limit = 2 << 30
+	}
+	return &ContainerStatsResult{MemUsage: 16 << 20, MemLimit: limit}, nil
+}
+
+// GetHTTPClient returns a client whose requests fail: the fake has no API to
+// hijack connections from
+func (f *Fake) GetHTTPClient() *http.Client {
+	return &http.Client{Transport: fakeTransport{}}
+}
+
+type fakeTransport struct{}
+
+func (fakeTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("not supported by the fake container runtime")
+}
+
+// GetBaseURL returns a URL nothing listens on
+func (f *Fake) GetBaseURL() string {
+	return "http://fake/v4.0.0/libpod"
+}
+
+// GetSocketPath returns "": the fake has no socket
+func (f *Fake) GetSocketPath() string {
+	return ""
+}
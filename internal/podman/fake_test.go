@@ -0,0 +1,83 @@
+package podman
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFakeContainerLifecycle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := NewFake()
+	f.ServePorts = false
+
+	if _, err := f.CreateContainer(ctx, CreateContainerOpts{Name: "web", Image: "nginx:alpine"}); err == nil {
+		t.Fatal("created a container from an image that was never pulled")
+	}
+	if err := f.PullImage(ctx, "nginx:alpine"); err != nil {
+		t.Fatalf("PullImage: %v", err)
+	}
+	id, err := f.CreateContainer(ctx, CreateContainerOpts{Name: "web", Image: "nginx:alpine", Volumes: []string{"web-data:/data"}})
+	if err != nil {
+		t.Fatalf("CreateContainer: %v", err)
+	}
+	if _, err := f.CreateContainer(ctx, CreateContainerOpts{Name: "web", Image: "nginx:alpine"}); err == nil {
+		t.Fatal("created two containers with the same name")
+	}
+	if vols, _ := f.ListVolumes(ctx); len(vols) != 1 || vols[0].Name != "web-data" {
+		t.Fatalf("volumes = %+v, want web-data", vols)
+	}
+
+	if err := f.StartContainer(ctx, "web"); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	info, err := f.InspectContainer(ctx, id)
+	if err != nil || !info.State.Running {
+		t.Fatalf("InspectContainer = %+v, %v; want running", info, err)
+	}
+	if err := f.Crash(id, 137); err != nil {
+		t.Fatalf("Crash: %v", err)
+	}
+	if code, err := f.WaitContainer(ctx, id); err != nil || code != 137 {
+		t.Fatalf("WaitContainer = %d, %v; want 137", code, err)
+	}
+	if err := f.RemoveImage(ctx, "nginx:alpine", false); err == nil {
+		t.Fatal("removed an image a container uses")
+	}
+	if err := f.RemoveContainer(ctx, id, false); err != nil {
+		t.Fatalf("RemoveContainer: %v", err)
+	}
+	if containers, _ := f.ListContainers(ctx, true); len(containers) != 0 {
+		t.Fatalf("containers after remove = %+v", containers)
+	}
+}
+
+func TestFakePod(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := NewFake()
+	f.ServePorts = false
+	f.PullImage(ctx, "postgres:16")
+
+	if _, err := f.CreateContainer(ctx, CreateContainerOpts{Name: "db", Image: "postgres:16", Pod: "shop"}); err == nil {
+		t.Fatal("created a container in a pod that doesn't exist")
+	}
+	if _, err := f.CreatePod(ctx, CreatePodOpts{Name: "shop"}); err != nil {
+		t.Fatalf("CreatePod: %v", err)
+	}
+	if _, err := f.CreateContainer(ctx, CreateContainerOpts{Name: "db", Image: "postgres:16", Pod: "shop"}); err != nil {
+		t.Fatalf("CreateContainer: %v", err)
+	}
+	if err := f.StartContainer(ctx, "db"); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	if err := f.RemovePod(ctx, "shop", false); err == nil {
+		t.Fatal("removed a pod with containers without force")
+	}
+	if err := f.RemovePod(ctx, "shop", true); err != nil {
+		t.Fatalf("RemovePod: %v", err)
+	}
+	if containers, _ := f.ListContainers(ctx, true); len(containers) != 0 {
+		t.Fatalf("containers after pod removal = %+v", containers)
+	}
+}
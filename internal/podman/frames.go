@@ -0,0 +1,70 @@
+package podman
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// maxFrameSize is the largest frame payload trusted as a real frame
+const maxFrameSize = 1 << 20
+
+// ReadLogFrames calls fn with the payload of each frame of a multiplexed log
+// or exec stream. Each frame is [stream_type(1), padding(3), size(4
+// big-endian)] followed by the payload; stream type 2 is stderr. Output that
+// isn't multiplexed (TTY containers) is passed on unchanged as stdout. data is
+// only valid during the call.
+func ReadLogFrames(r io.Reader, fn func(stream string, data []byte)) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, 8)
+	for {
+		n, err := io.ReadFull(reader, header)
+		if err != nil {
+			if n > 0 {
+				fn("stdout", header[:n])
+			}
+			return
+		}
+		frameSize := int(header[4])<<24 | int(header[5])<<16 | int(header[6])<<8 | int(header[7])
+		if header[0] > 2 || frameSize > maxFrameSize {
+			// Not multiplexed: pass the rest through as it comes
+			fn("stdout", header)
+			buf := make([]byte, 4096)
+			for {
+				n, err := reader.Read(buf)
+				if n > 0 {
+					fn("stdout", buf[:n])
+				}
+				if err != nil {
+					return
+				}
+			}
+		}
+		if frameSize == 0 {
+			continue
+		}
+		stream := "stdout"
+		if header[0] == 2 {
+			stream = "stderr"
+		}
+		payload := make([]byte, frameSize)
+		n, err = io.ReadFull(reader, payload)
+		if n > 0 {
+			fn(stream, payload[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// DemuxLogs returns the payload of a buffered multiplexed log stream, with
+// the frame headers stripped
+func DemuxLogs(data []byte) string {
+	var sb strings.Builder
+	ReadLogFrames(bytes.NewReader(data), func(_ string, payload []byte) {
+		sb.Write(payload)
+	})
+	return sb.String()
+}
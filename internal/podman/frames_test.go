@@ -0,0 +1,58 @@
+package podman
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func logFrame(stream byte, payload string) []byte {
+	n := len(payload)
+	return append([]byte{stream, 0, 0, 0, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, payload...)
+}
+
+func TestReadLogFrames(t *testing.T) {
+	t.Parallel()
+
+	var data []byte
+	data = append(data, logFrame(1, "migrating\n")...)
+	data = append(data, logFrame(2, "warning: slow\n")...)
+	data = append(data, logFrame(1, "done\n")...)
+
+	var got []string
+	ReadLogFrames(bytes.NewReader(data), func(stream string, payload []byte) {
+		got = append(got, stream+":"+string(payload))
+	})
+	want := []string{"stdout:migrating\n", "stderr:warning: slow\n", "stdout:done\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("frames = %q, want %q", got, want)
+	}
+
+	// TTY output has no frame headers and is passed through
+	var raw strings.Builder
+	ReadLogFrames(strings.NewReader("plain output, no frames\n"), func(stream string, payload []byte) {
+		if stream != "stdout" {
+			t.Fatalf("raw output stream = %s", stream)
+		}
+		raw.Write(payload)
+	})
+	if raw.String() != "plain output, no frames\n" {
+		t.Fatalf("raw output = %q", raw.String())
+	}
+}
+
+func TestDemuxLogs(t *testing.T) {
+	t.Parallel()
+
+	data := append(logFrame(1, "first\n"), logFrame(2, "second\n")...)
+	if got := DemuxLogs(data); got != "first\nsecond\n" {
+		t.Fatalf("DemuxLogs = %q", got)
+	}
+	// A stream cut off mid-frame keeps what arrived
+	if got := DemuxLogs(append(logFrame(1, "whole\n"), logFrame(1, "partial line\n")[:12]...)); got != "whole\npart" {
+		t.Fatalf("DemuxLogs of a truncated stream = %q", got)
+	}
+	if got := DemuxLogs([]byte("tty")); got != "tty" {
+		t.Fatalf("DemuxLogs of unframed output = %q", got)
+	}
+}
@@ -0,0 +1,279 @@
+// Package pullcache runs a pull-through registry mirror for Docker Hub so
+// repeated deploys don't re-download the same images or hit rate limits.
+package pullcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/diskutil"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+const (
+	containerName = "basepod-pullcache"
+	volumeName    = "basepod-pullcache"
+	registryImage = "docker.io/library/registry:2"
+
+	defaultPort    = 5050
+	defaultMaxSize = "20GB"
+	defaultTTL     = "168h"
+	checkInterval  = time.Hour
+)
+
+// Stats reports cache usage for `bp status`
+type Stats struct {
+	Enabled   bool   `json:"enabled"`
+	Running   bool   `json:"running"`
+	Mirror    string `json:"mirror,omitempty"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Size      int64  `json:"size"`
+	Formatted string `json:"formatted"`
+	MaxSize   string `json:"max_size,omitempty"`
+}
+
+// Cache manages the registry mirror container and rewrites Docker Hub pulls through it
+type Cache struct {
+	cfg    config.PullCacheConfig
+	podman podman.Client
+	client *http.Client
+	stopCh chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	hits    int64
+	misses  int64
+}
+
+// New creates a pull cache manager. Call Start to launch the mirror.
+func New(cfg config.PullCacheConfig, pm podman.Client) *Cache {
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	if cfg.MaxSize == "" {
+		cfg.MaxSize = defaultMaxSize
+	}
+	if cfg.TTL == "" {
+		cfg.TTL = defaultTTL
+	}
+	return &Cache{
+		cfg:    cfg,
+		podman: pm,
+		client: &http.Client{Timeout: 5 * time.Second},
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start launches the mirror container and the periodic size check
+func (c *Cache) Start() {
+	if !c.cfg.Enabled || c.podman == nil {
+		return
+	}
+	go func() {
+		if err := c.ensureRunning(context.Background()); err != nil {
+			log.Printf("Pull cache: %v", err)
+			return
+		}
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.enforceMaxSize(context.Background())
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic size check (the mirror container keeps running)
+func (c *Cache) Stop() {
+	close(c.stopCh)
+}
+
+func (c *Cache) mirrorHost() string {
+	return fmt.Sprintf("localhost:%d", c.cfg.Port)
+}
+
+// ensureRunning creates and starts the mirror container if it isn't running
+func (c *Cache) ensureRunning(ctx context.Context) error {
+	if info, err := c.podman.InspectContainer(ctx, containerName); err == nil && info.State.Running && localOnly(info) {
+		c.setRunning(true)
+		return nil
+	}
+	_ = c.podman.RemoveContainer(ctx, containerName, true)
+
+	// The registry image itself comes straight from Docker Hub
+	if err := c.podman.PullImage(ctx, registryImage); err != nil {
+		return fmt.Errorf("failed to pull registry image: %w", err)
+	}
+	_ = c.podman.CreateVolume(ctx, volumeName, map[string]string{"basepod.pullcache": "true"})
+
+	id, err := c.podman.CreateContainer(ctx, podman.CreateContainerOpts{
+		Name:  containerName,
+		Image: registryImage,
+		Env: map[string]string{
+			"REGISTRY_PROXY_REMOTEURL": "https://registry-1.docker.io",
+			"REGISTRY_PROXY_TTL":       c.cfg.TTL,
+		},
+		Volumes: []string{volumeName + ":/var/lib/registry"},
+		Ports:   map[string]string{"5000": fmt.Sprintf("%d", c.cfg.Port)},
+		// The mirror answers anyone who reaches it, so it's only published
+		// on 127.0.0.1 for this host's pulls
+		ExposeExternal: false,
+		Labels:         map[string]string{"basepod.pullcache": "true"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create mirror container: %w", err)
+	}
+	if err := c.podman.StartContainer(ctx, id); err != nil {
+		return fmt.Errorf("failed to start mirror container: %w", err)
+	}
+	c.setRunning(true)
+	log.Printf("Pull cache: Docker Hub mirror running on %s", c.mirrorHost())
+	return nil
+}
+
+// localOnly reports whether a mirror container is only published on
+// 127.0.0.1, rather than on every interface by an older version
+func localOnly(info *podman.ContainerInspect) bool {
+	for _, bindings := range info.NetworkSettings.Ports {
+		for _, b := range bindings {
+			if b.HostIP != "127.0.0.1" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *Cache) setRunning(running bool) {
+	c.mu.Lock()
+	c.running = running
+	c.mu.Unlock()
+}
+
+// MirrorRef returns the mirror reference for a fully-qualified Docker Hub image
+// (e.g. docker.io/library/nginx:latest -> localhost:5050/library/nginx:latest).
+// ok is false when the image isn't from Docker Hub or the mirror is down.
+func (c *Cache) MirrorRef(ctx context.Context, image string) (string, bool) {
+	c.mu.Lock()
+	running := c.running
+	c.mu.Unlock()
+
+	repo, ok := strings.CutPrefix(image, "docker.io/")
+	if !running || !ok || strings.Contains(repo, "@") {
+		return "", false
+	}
+
+	name := repo
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	hit := c.isCached(ctx, name)
+
+	c.mu.Lock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	c.mu.Unlock()
+
+	return c.mirrorHost() + "/" + repo, true
+}
+
+// isCached reports whether the mirror already stores the repository. The catalog
+// only lists repositories the proxy has pulled before.
+func (c *Cache) isCached(ctx context.Context, repo string) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+c.mirrorHost()+"/v2/_catalog?n=10000", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		return false
+	}
+	for _, r := range catalog.Repositories {
+		if r == repo {
+			return true
+		}
+	}
+	return false
+}
+
+// size returns the on-disk size of the cache volume (0 if unknown)
+func (c *Cache) size(ctx context.Context) int64 {
+	volumes, err := c.podman.ListVolumes(ctx)
+	if err != nil {
+		return 0
+	}
+	for _, vol := range volumes {
+		if vol.Name == volumeName && vol.Mountpoint != "" {
+			return diskutil.DirSize(vol.Mountpoint)
+		}
+	}
+	return 0
+}
+
+// enforceMaxSize drops the whole cache once it outgrows MaxSize. The registry
+// has no per-blob eviction, and a cold cache just refills from Docker Hub.
+func (c *Cache) enforceMaxSize(ctx context.Context) {
+	max, err := diskutil.ParseBytes(c.cfg.MaxSize)
+	if err != nil || max <= 0 {
+		return
+	}
+	size := c.size(ctx)
+	if size <= max {
+		return
+	}
+
+	log.Printf("Pull cache: %s exceeds %s, clearing", diskutil.FormatBytes(size), c.cfg.MaxSize)
+	c.setRunning(false)
+	_ = c.podman.RemoveContainer(ctx, containerName, true)
+	if err := c.podman.RemoveVolume(ctx, volumeName, true); err != nil {
+		log.Printf("Pull cache: failed to remove volume: %v", err)
+	}
+	if err := c.ensureRunning(ctx); err != nil {
+		log.Printf("Pull cache: %v", err)
+	}
+}
+
+// Stats returns hit/miss counters and the current cache size
+func (c *Cache) Stats(ctx context.Context) Stats {
+	c.mu.Lock()
+	stats := Stats{
+		Enabled: c.cfg.Enabled,
+		Running: c.running,
+		Hits:    c.hits,
+		Misses:  c.misses,
+		MaxSize: c.cfg.MaxSize,
+	}
+	c.mu.Unlock()
+
+	if stats.Enabled {
+		stats.Mirror = c.mirrorHost()
+		if c.podman != nil {
+			stats.Size = c.size(ctx)
+		}
+	}
+	stats.Formatted = diskutil.FormatBytes(stats.Size)
+	return stats
+}
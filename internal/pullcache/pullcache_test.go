@@ -0,0 +1,75 @@
+package pullcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+func TestMirrorRefRewritesDockerHubImages(t *testing.T) {
+	t.Parallel()
+
+	c := New(config.PullCacheConfig{Enabled: true, Port: 1}, nil)
+	if _, ok := c.MirrorRef(context.Background(), "docker.io/library/nginx:latest"); ok {
+		t.Fatal("expected no rewrite while the mirror isn't running")
+	}
+
+	c.setRunning(true)
+	for image, want := range map[string]string{
+		"docker.io/library/nginx:latest": "localhost:1/library/nginx:latest",
+		"docker.io/grafana/grafana":      "localhost:1/grafana/grafana",
+	} {
+		got, ok := c.MirrorRef(context.Background(), image)
+		if !ok || got != want {
+			t.Fatalf("expected %s to map to %s, got %q (ok=%t)", image, want, got, ok)
+		}
+	}
+
+	for _, image := range []string{"ghcr.io/open-webui/open-webui:main", "docker.io/library/nginx@sha256:abc"} {
+		if _, ok := c.MirrorRef(context.Background(), image); ok {
+			t.Fatalf("expected %s to bypass the mirror", image)
+		}
+	}
+
+	if stats := c.Stats(context.Background()); stats.Misses != 2 {
+		t.Fatalf("expected 2 misses against an empty mirror, got %+v", stats)
+	}
+}
+
+func TestMirrorIsOnlyPublishedOnLocalhost(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fake := podman.NewFake()
+	fake.ServePorts = false
+
+	// A mirror an older version published on every interface is replaced
+	fake.PullImage(ctx, registryImage)
+	id, _ := fake.CreateContainer(ctx, podman.CreateContainerOpts{Name: containerName, Image: registryImage, Ports: map[string]string{"5000": "15050"}, ExposeExternal: true})
+	fake.StartContainer(ctx, id)
+
+	c := New(config.PullCacheConfig{Enabled: true, Port: 15050}, fake)
+	if err := c.ensureRunning(ctx); err != nil {
+		t.Fatalf("ensureRunning: %v", err)
+	}
+	info, err := fake.InspectContainer(ctx, containerName)
+	if err != nil || !info.State.Running {
+		t.Fatalf("mirror not running: %v", err)
+	}
+	if info.ID == id {
+		t.Fatal("mirror published on every interface was kept")
+	}
+	bindings := info.NetworkSettings.Ports["5000/tcp"]
+	if len(bindings) != 1 || bindings[0].HostIP != "127.0.0.1" || bindings[0].HostPort != "15050" {
+		t.Fatalf("mirror bindings = %+v, want 127.0.0.1:15050", bindings)
+	}
+
+	// A local one is left running
+	if err := c.ensureRunning(ctx); err != nil {
+		t.Fatalf("ensureRunning: %v", err)
+	}
+	if again, _ := fake.InspectContainer(ctx, containerName); again.ID != info.ID {
+		t.Fatal("local mirror was recreated")
+	}
+}
@@ -0,0 +1,104 @@
+// Package rag provides a small local vector index for searching app logs and notes.
+// Vectors are kept in SQLite next to their text and ranked by brute-force cosine
+// similarity, which is plenty for the few thousand chunks a single server produces.
+package rag
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// Result is a ranked search hit
+type Result struct {
+	app.AIDocument
+	Score float64 `json:"score"`
+}
+
+// Cosine returns the cosine similarity of two vectors (0 if either is empty or sizes differ)
+func Cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Rank scores docs against the query vector and returns the best limit results
+func Rank(query []float32, docs []app.AIDocument, limit int) []Result {
+	results := make([]Result, 0, len(docs))
+	for _, d := range docs {
+		results = append(results, Result{AIDocument: d, Score: Cosine(query, d.Embedding)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// LogChunk is a group of consecutive log lines
+type LogChunk struct {
+	Content   string
+	Timestamp time.Time
+}
+
+// ChunkLogs groups timestamped log lines ("<RFC3339Nano> message") into chunks
+// of at most size lines, keeping the timestamp of each chunk's first line.
+// Blank lines are dropped.
+func ChunkLogs(lines []string, size int) []LogChunk {
+	if size <= 0 {
+		size = 20
+	}
+	var chunks []LogChunk
+	var current []string
+	var start time.Time
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, LogChunk{Content: strings.Join(current, "\n"), Timestamp: start})
+		}
+		current = nil
+		start = time.Time{}
+	}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		ts, msg := splitTimestamp(line)
+		if len(current) == 0 {
+			start = ts
+		}
+		current = append(current, msg)
+		if len(current) >= size {
+			flush()
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitTimestamp separates a leading RFC3339 timestamp from a log line
+func splitTimestamp(line string) (time.Time, string) {
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			return ts, line[i+1:]
+		}
+	}
+	return time.Time{}, line
+}
@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+func TestRankOrdersBySimilarity(t *testing.T) {
+	t.Parallel()
+
+	docs := []app.AIDocument{
+		{ID: "far", Embedding: []float32{0, 1}},
+		{ID: "near", Embedding: []float32{1, 0.1}},
+		{ID: "mismatched", Embedding: []float32{1}},
+	}
+
+	results := Rank([]float32{1, 0}, docs, 2)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != "near" {
+		t.Fatalf("expected nearest document first, got %q", results[0].ID)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Fatalf("expected descending scores, got %v then %v", results[0].Score, results[1].Score)
+	}
+}
+
+func TestChunkLogsKeepsFirstTimestamp(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{
+		"2026-01-02T03:04:05.000000000Z GET /checkout 500",
+		"",
+		"2026-01-02T03:04:06.000000000Z panic: nil map",
+		"2026-01-02T03:04:07.000000000Z retrying",
+	}
+
+	chunks := ChunkLogs(lines, 2)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !chunks[0].Timestamp.Equal(want) {
+		t.Fatalf("expected first chunk timestamp %v, got %v", want, chunks[0].Timestamp)
+	}
+	if chunks[0].Content != "GET /checkout 500\npanic: nil map" {
+		t.Fatalf("unexpected chunk content %q", chunks[0].Content)
+	}
+}
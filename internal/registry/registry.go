@@ -0,0 +1,339 @@
+// Package registry runs a local OCI registry that source-deploy builds are
+// pushed to, so every deploy keeps an exact image to roll back to and other
+// nodes can pull it.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/base-go/basepod/internal/config"
+	"github.com/base-go/basepod/internal/podman"
+)
+
+const (
+	containerName = "basepod-registry"
+	volumeName    = "basepod-registry"
+	registryImage = "docker.io/library/registry:2"
+	registryConf  = "/etc/docker/registry/config.yml"
+
+	defaultPort = 5055
+
+	// Namespace is the repository prefix app images are pushed under
+	Namespace = "basepod"
+)
+
+// manifestTypes are the manifest media types asked for when resolving a tag
+// to its digest
+var manifestTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// ErrTagNotFound is returned by DeleteTag for a tag the registry doesn't have
+var ErrTagNotFound = errors.New("tag not found")
+
+// Repository is one app's images in the registry
+type Repository struct {
+	Name string   `json:"name"` // e.g. basepod/web
+	App  string   `json:"app"`
+	Tags []string `json:"tags"`
+}
+
+// Stats reports whether the registry is up, for the status endpoints
+type Stats struct {
+	Enabled bool   `json:"enabled"`
+	Running bool   `json:"running"`
+	Host    string `json:"host,omitempty"`
+}
+
+// Registry manages the registry container and talks to its HTTP API
+type Registry struct {
+	cfg    config.RegistryConfig
+	podman podman.Client
+	client *http.Client
+
+	// mu keeps garbage collection from running while an image is pushed
+	mu      sync.RWMutex
+	stateMu sync.Mutex
+	running bool
+}
+
+// New creates a registry manager. Call Start to launch the registry.
+func New(cfg config.RegistryConfig, pm podman.Client) *Registry {
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	return &Registry{
+		cfg:    cfg,
+		podman: pm,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Enabled reports whether registry.enabled is set
+func (r *Registry) Enabled() bool {
+	return r.cfg.Enabled
+}
+
+// Host returns the registry's host:port as Podman on this server reaches it
+func (r *Registry) Host() string {
+	return fmt.Sprintf("localhost:%d", r.cfg.Port)
+}
+
+// Start launches the registry container in the background
+func (r *Registry) Start() {
+	if !r.cfg.Enabled || r.podman == nil {
+		return
+	}
+	go func() {
+		if err := r.ensureRunning(context.Background()); err != nil {
+			log.Printf("Registry: %v", err)
+		}
+	}()
+}
+
+// ensureRunning creates and starts the registry container if it isn't running
+func (r *Registry) ensureRunning(ctx context.Context) error {
+	if info, err := r.podman.InspectContainer(ctx, containerName); err == nil && info.State.Running {
+		r.setRunning(true)
+		return nil
+	}
+	_ = r.podman.RemoveContainer(ctx, containerName, true)
+
+	if err := r.podman.PullImage(ctx, registryImage); err != nil {
+		return fmt.Errorf("failed to pull registry image: %w", err)
+	}
+	_ = r.podman.CreateVolume(ctx, volumeName, map[string]string{"basepod.registry": "true"})
+
+	id, err := r.podman.CreateContainer(ctx, podman.CreateContainerOpts{
+		Name:           containerName,
+		Image:          registryImage,
+		Env:            map[string]string{"REGISTRY_STORAGE_DELETE_ENABLED": "true"},
+		Volumes:        []string{volumeName + ":/var/lib/registry"},
+		Ports:          map[string]string{"5000": fmt.Sprintf("%d", r.cfg.Port)},
+		ExposeExternal: r.cfg.Expose,
+		Labels:         map[string]string{"basepod.registry": "true"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create registry container: %w", err)
+	}
+	if err := r.podman.StartContainer(ctx, id); err != nil {
+		return fmt.Errorf("failed to start registry container: %w", err)
+	}
+	r.setRunning(true)
+	log.Printf("Registry: running on %s", r.Host())
+	return nil
+}
+
+func (r *Registry) setRunning(running bool) {
+	r.stateMu.Lock()
+	r.running = running
+	r.stateMu.Unlock()
+}
+
+// Running reports whether the registry container is up
+func (r *Registry) Running() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.running
+}
+
+// Stats returns the registry's state
+func (r *Registry) Stats() Stats {
+	stats := Stats{Enabled: r.cfg.Enabled, Running: r.Running()}
+	if stats.Enabled {
+		stats.Host = r.Host()
+	}
+	return stats
+}
+
+// Repo returns the repository an app's images are pushed to
+func Repo(appName string) string {
+	return Namespace + "/" + appName
+}
+
+// Tag returns the tag for a build: the short git commit when there is one,
+// else fallback (the deploy timestamp)
+func Tag(gitCommit, fallback string) string {
+	tag := gitCommit
+	if len(tag) > 12 {
+		tag = tag[:12]
+	}
+	if tag == "" {
+		tag = fallback
+	}
+	tag = invalidTagChars.ReplaceAllString(tag, "-")
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return tag
+}
+
+// Ref returns the full reference of an app image in the registry
+func (r *Registry) Ref(appName, tag string) string {
+	return r.Host() + "/" + Repo(appName) + ":" + tag
+}
+
+// Push pushes a local image to the registry as basepod/{app}:{tag} and
+// returns its registry reference
+func (r *Registry) Push(ctx context.Context, podmanPath, image, appName, tag string) (string, error) {
+	if !r.Running() {
+		return "", fmt.Errorf("registry is not running")
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ref := r.Ref(appName, tag)
+	out, err := exec.CommandContext(ctx, podmanPath, "push", "--tls-verify=false", image, "docker://"+ref).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("push %s: %v: %s", ref, err, strings.TrimSpace(string(out)))
+	}
+	return ref, nil
+}
+
+// Pull fetches a registry image into local storage, for rolling back to a
+// build whose local image was pruned
+func (r *Registry) Pull(ctx context.Context, podmanPath, ref string) error {
+	out, err := exec.CommandContext(ctx, podmanPath, "pull", "--tls-verify=false", ref).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pull %s: %v: %s", ref, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// get decodes a JSON response from the registry API
+func (r *Registry) get(ctx context.Context, path string, out interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+r.Host()+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Repositories lists app repositories and their tags, sorted by name
+func (r *Registry) Repositories(ctx context.Context) ([]Repository, error) {
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	if status, err := r.get(ctx, "/v2/_catalog?n=10000", &catalog); err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	} else if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to list repositories (status %d)", status)
+	}
+
+	repos := []Repository{}
+	for _, name := range catalog.Repositories {
+		appName, ok := strings.CutPrefix(name, Namespace+"/")
+		if !ok {
+			continue
+		}
+		tags, err := r.Tags(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		repos = append(repos, Repository{Name: name, App: appName, Tags: tags})
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	return repos, nil
+}
+
+// Tags returns a repository's tags, sorted
+func (r *Registry) Tags(ctx context.Context, repo string) ([]string, error) {
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	status, err := r.get(ctx, "/v2/"+repo+"/tags/list", &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", repo, err)
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to list tags of %s (status %d)", repo, status)
+	}
+	sort.Strings(list.Tags)
+	return list.Tags, nil
+}
+
+// DeleteTag removes a tag's manifest. Other tags of the same manifest go
+// with it; the layers are freed by the next GarbageCollect.
+func (r *Registry) DeleteTag(ctx context.Context, repo, tag string) error {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", "http://"+r.Host()+"/v2/"+repo+"/manifests/"+url.PathEscape(tag), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s:%s: %w", repo, tag, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s:%s", ErrTagNotFound, repo, tag)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if resp.StatusCode != http.StatusOK || digest == "" {
+		return fmt.Errorf("failed to resolve %s:%s (status %d)", repo, tag, resp.StatusCode)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "DELETE", "http://"+r.Host()+"/v2/"+repo+"/manifests/"+digest, nil)
+	if err != nil {
+		return err
+	}
+	resp, err = r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s:%s: %w", repo, tag, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete %s:%s (status %d): %s", repo, tag, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// GarbageCollect frees the layers no remaining tag uses. Pushes wait until
+// it finishes.
+func (r *Registry) GarbageCollect(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	execID, err := r.podman.ExecCreate(ctx, containerName, []string{"registry", "garbage-collect", "--delete-untagged", registryConf})
+	if err != nil {
+		return "", fmt.Errorf("failed to start garbage collection: %w", err)
+	}
+	out, err := r.podman.ExecStart(ctx, execID)
+	if err != nil {
+		return "", fmt.Errorf("garbage collection failed: %w", err)
+	}
+	return out, nil
+}
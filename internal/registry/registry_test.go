@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/base-go/basepod/internal/config"
+)
+
+func TestTag(t *testing.T) {
+	t.Parallel()
+	cases := []struct{ commit, fallback, want string }{
+		{"0123456789abcdef0123", "1700000000", "0123456789ab"},
+		{"", "1700000000", "1700000000"},
+		{"feature/x", "", "feature-x"},
+	}
+	for _, c := range cases {
+		if got := Tag(c.commit, c.fallback); got != c.want {
+			t.Fatalf("Tag(%q, %q) = %q, want %q", c.commit, c.fallback, got, c.want)
+		}
+	}
+}
+
+// fakeRegistry serves the parts of the registry API the client uses
+type fakeRegistry struct {
+	mu   sync.Mutex
+	tags map[string]map[string]string // repo -> tag -> digest
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case path == "_catalog":
+		repos := []string{}
+		for repo := range f.tags {
+			repos = append(repos, `"`+repo+`"`)
+		}
+		w.Write([]byte(`{"repositories":[` + strings.Join(repos, ",") + `]}`))
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		tags := []string{}
+		for tag := range f.tags[repo] {
+			tags = append(tags, `"`+tag+`"`)
+		}
+		w.Write([]byte(`{"name":"` + repo + `","tags":[` + strings.Join(tags, ",") + `]}`))
+	case strings.Contains(path, "/manifests/"):
+		i := strings.LastIndex(path, "/manifests/")
+		repo, ref := path[:i], path[i+len("/manifests/"):]
+		if r.Method == "HEAD" {
+			digest, ok := f.tags[repo][ref]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", digest)
+			return
+		}
+		for tag, digest := range f.tags[repo] {
+			if digest == ref {
+				delete(f.tags[repo], tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRepositoriesAndDeleteTag(t *testing.T) {
+	t.Parallel()
+	fake := &fakeRegistry{tags: map[string]map[string]string{
+		"basepod/web": {"abc123": "sha256:1", "def456": "sha256:2"},
+		"other/image": {"latest": "sha256:3"},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	reg := New(config.RegistryConfig{Enabled: true, Port: p}, nil)
+	ctx := context.Background()
+
+	repos, err := reg.Repositories(ctx)
+	if err != nil {
+		t.Fatalf("Repositories: %v", err)
+	}
+	if len(repos) != 1 || repos[0].App != "web" || strings.Join(repos[0].Tags, ",") != "abc123,def456" {
+		t.Fatalf("repositories = %+v, want basepod/web with abc123,def456", repos)
+	}
+
+	if err := reg.DeleteTag(ctx, Repo("web"), "abc123"); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	if err := reg.DeleteTag(ctx, Repo("web"), "abc123"); !errors.Is(err, ErrTagNotFound) {
+		t.Fatalf("deleting a deleted tag: err = %v, want ErrTagNotFound", err)
+	}
+	tags, err := reg.Tags(ctx, Repo("web"))
+	if err != nil || len(tags) != 1 || tags[0] != "def456" {
+		t.Fatalf("tags after delete = %v, %v; want [def456]", tags, err)
+	}
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestActivityLogFilter(t *testing.T) {
+	t.Parallel()
+
+	where, args := activityLogFilter("", "deploy", nil)
+	if strings.Contains(where, "IN") || len(args) != 1 {
+		t.Fatalf("unscoped filter = %q %v", where, args)
+	}
+
+	where, args = activityLogFilter("", "", []string{"a", "b"})
+	if !strings.Contains(where, "target_id IN (?, ?)") || len(args) != 2 {
+		t.Fatalf("scoped filter = %q %v", where, args)
+	}
+
+	where, _ = activityLogFilter("", "", []string{})
+	if !strings.Contains(where, "1=0") {
+		t.Fatalf("empty scope should match nothing, got %q", where)
+	}
+}
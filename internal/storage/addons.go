@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// CreateAddon marks an app as an addon of the given type
+func (s *Storage) CreateAddon(a *app.Addon) error {
+	_, err := s.db.Exec(`INSERT INTO addons (app_id, type, created_at) VALUES (?, ?, ?)`, a.ID, a.Type, a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create addon: %w", err)
+	}
+	return nil
+}
+
+// GetAddon returns the addon backed by an app with its attachments, or nil
+// if the app isn't an addon
+func (s *Storage) GetAddon(appID string) (*app.Addon, error) {
+	var a app.Addon
+	err := s.db.QueryRow(`SELECT app_id, type, created_at FROM addons WHERE app_id = ?`, appID).Scan(&a.ID, &a.Type, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addon: %w", err)
+	}
+	if a.Attachments, err = s.listAddonAttachments("addon_id = ?", appID); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAddons lists every addon with its attachments
+func (s *Storage) ListAddons() ([]app.Addon, error) {
+	rows, err := s.db.Query(`SELECT app_id, type, created_at FROM addons ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addons: %w", err)
+	}
+	var addons []app.Addon
+	for rows.Next() {
+		var a app.Addon
+		if err := rows.Scan(&a.ID, &a.Type, &a.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan addon: %w", err)
+		}
+		addons = append(addons, a)
+	}
+	rows.Close()
+
+	for i := range addons {
+		if addons[i].Attachments, err = s.listAddonAttachments("addon_id = ?", addons[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return addons, nil
+}
+
+// DeleteAddon removes an addon record and its attachments; the backing app
+// is left to the caller
+func (s *Storage) DeleteAddon(appID string) error {
+	if _, err := s.db.Exec("DELETE FROM addon_attachments WHERE addon_id = ?", appID); err != nil {
+		return fmt.Errorf("failed to delete addon attachments: %w", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM addons WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("failed to delete addon: %w", err)
+	}
+	return nil
+}
+
+// AttachAddon records that an app gets an addon's connection URL, replacing
+// an earlier attachment of the same pair
+func (s *Storage) AttachAddon(att *app.AddonAttachment) error {
+	_, err := s.db.Exec(`
+		INSERT INTO addon_attachments (addon_id, app_id, env_var, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(addon_id, app_id) DO UPDATE SET env_var = excluded.env_var
+	`, att.AddonID, att.AppID, att.EnvVar, att.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to attach addon: %w", err)
+	}
+	return nil
+}
+
+// DetachAddon removes an app's attachment to an addon
+func (s *Storage) DetachAddon(addonID, appID string) error {
+	if _, err := s.db.Exec("DELETE FROM addon_attachments WHERE addon_id = ? AND app_id = ?", addonID, appID); err != nil {
+		return fmt.Errorf("failed to detach addon: %w", err)
+	}
+	return nil
+}
+
+// ListAttachmentsForApp lists the addons attached to an app
+func (s *Storage) ListAttachmentsForApp(appID string) ([]app.AddonAttachment, error) {
+	return s.listAddonAttachments("app_id = ?", appID)
+}
+
+// DeleteAttachmentsForApp removes every attachment of an app
+func (s *Storage) DeleteAttachmentsForApp(appID string) error {
+	if _, err := s.db.Exec("DELETE FROM addon_attachments WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("failed to delete addon attachments: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) listAddonAttachments(where string, arg interface{}) ([]app.AddonAttachment, error) {
+	rows, err := s.db.Query(`
+		SELECT t.addon_id, t.app_id, COALESCE(a.name, ''), t.env_var, t.created_at
+		FROM addon_attachments t LEFT JOIN apps a ON a.id = t.app_id
+		WHERE t.`+where+` ORDER BY t.created_at`, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addon attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []app.AddonAttachment{}
+	for rows.Next() {
+		var att app.AddonAttachment
+		if err := rows.Scan(&att.AddonID, &att.AppID, &att.AppName, &att.EnvVar, &att.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan addon attachment: %w", err)
+		}
+		attachments = append(attachments, att)
+	}
+	return attachments, rows.Err()
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// SubscribeDigest adds email to the weekly digest and returns its
+// unsubscribe token. Subscribing again keeps the existing token.
+func (s *Storage) SubscribeDigest(email, userID string) (string, error) {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate unsubscribe token: %w", err)
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO digest_subscriptions (email, user_id, token, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(email) DO UPDATE SET user_id = excluded.user_id
+	`, email, userID, hex.EncodeToString(tokenBytes), time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to subscribe to digest: %w", err)
+	}
+	var token string
+	if err := s.db.QueryRow("SELECT token FROM digest_subscriptions WHERE email = ?", email).Scan(&token); err != nil {
+		return "", fmt.Errorf("failed to read digest subscription: %w", err)
+	}
+	return token, nil
+}
+
+// UnsubscribeDigest removes email from the weekly digest
+func (s *Storage) UnsubscribeDigest(email string) error {
+	if _, err := s.db.Exec("DELETE FROM digest_subscriptions WHERE email = ?", email); err != nil {
+		return fmt.Errorf("failed to unsubscribe from digest: %w", err)
+	}
+	return nil
+}
+
+// UnsubscribeDigestByToken removes the subscription an unsubscribe link
+// points at and returns its email, or "" if the token is unknown
+func (s *Storage) UnsubscribeDigestByToken(token string) (string, error) {
+	var email string
+	err := s.db.QueryRow("SELECT email FROM digest_subscriptions WHERE token = ?", token).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find digest subscription: %w", err)
+	}
+	return email, s.UnsubscribeDigest(email)
+}
+
+// ListDigestSubscriptions returns everyone subscribed to the weekly digest
+func (s *Storage) ListDigestSubscriptions() ([]app.DigestSubscription, error) {
+	rows, err := s.db.Query("SELECT email, user_id, token, created_at FROM digest_subscriptions ORDER BY email")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digest subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []app.DigestSubscription
+	for rows.Next() {
+		var sub app.DigestSubscription
+		if err := rows.Scan(&sub.Email, &sub.UserID, &sub.Token, &sub.CreatedAt); err != nil {
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
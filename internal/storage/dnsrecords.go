@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// SaveDNSRecord records an address record created with the DNS provider,
+// replacing an earlier one for the same domain and type
+func (s *Storage) SaveDNSRecord(r *app.DNSRecord) error {
+	_, err := s.db.Exec(`
+		INSERT INTO dns_records (domain, type, value, ttl, app_id, provider, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(domain, type) DO UPDATE SET value = excluded.value, ttl = excluded.ttl,
+			app_id = excluded.app_id, provider = excluded.provider, updated_at = excluded.updated_at
+	`, r.Domain, r.Type, r.Value, r.TTL, r.AppID, r.Provider, r.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save DNS record: %w", err)
+	}
+	return nil
+}
+
+// ListDNSRecords lists the address records basepod created for an app, or
+// for every app when appID is empty
+func (s *Storage) ListDNSRecords(appID string) ([]app.DNSRecord, error) {
+	query := `SELECT domain, type, value, ttl, app_id, provider, updated_at FROM dns_records`
+	var args []interface{}
+	if appID != "" {
+		query += ` WHERE app_id = ?`
+		args = append(args, appID)
+	}
+	rows, err := s.db.Query(query+` ORDER BY domain, type`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DNS records: %w", err)
+	}
+	defer rows.Close()
+
+	records := []app.DNSRecord{}
+	for rows.Next() {
+		var r app.DNSRecord
+		if err := rows.Scan(&r.Domain, &r.Type, &r.Value, &r.TTL, &r.AppID, &r.Provider, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan DNS record: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// DeleteDNSRecord forgets an address record
+func (s *Storage) DeleteDNSRecord(domain, recordType string) error {
+	if _, err := s.db.Exec("DELETE FROM dns_records WHERE domain = ? AND type = ?", domain, recordType); err != nil {
+		return fmt.Errorf("failed to delete DNS record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// SaveAppDocs stores the docs from an app's latest deploy, replacing the
+// previous ones
+func (s *Storage) SaveAppDocs(appID string, docs *app.AppDocs) error {
+	data, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO app_docs (app_id, docs, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(app_id) DO UPDATE SET docs = excluded.docs, updated_at = excluded.updated_at
+	`, appID, string(data), docs.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save app docs: %w", err)
+	}
+	return nil
+}
+
+// GetAppDocs returns the docs from an app's latest deploy, or nil if it
+// shipped none
+func (s *Storage) GetAppDocs(appID string) (*app.AppDocs, error) {
+	var data string
+	err := s.db.QueryRow("SELECT docs FROM app_docs WHERE app_id = ?", appID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app docs: %w", err)
+	}
+	var docs app.AppDocs
+	if err := json.Unmarshal([]byte(data), &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode app docs: %w", err)
+	}
+	return &docs, nil
+}
+
+// DeleteAppDocs removes an app's docs
+func (s *Storage) DeleteAppDocs(appID string) error {
+	if _, err := s.db.Exec("DELETE FROM app_docs WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("failed to delete app docs: %w", err)
+	}
+	return nil
+}
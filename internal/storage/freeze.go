@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// CreateFreezeWindow saves a deploy freeze window
+func (s *Storage) CreateFreezeWindow(fw *app.FreezeWindow) error {
+	_, err := s.db.Exec(`
+		INSERT INTO freeze_windows (id, name, days, start_time, end_time, from_date, until_date, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fw.ID, fw.Name, strings.Join(fw.Days, ","), fw.Start, fw.End, fw.From, fw.Until, fw.CreatedBy, fw.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create freeze window: %w", err)
+	}
+	return nil
+}
+
+// ListFreezeWindows returns every deploy freeze window, oldest first
+func (s *Storage) ListFreezeWindows() ([]app.FreezeWindow, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, days, start_time, end_time, from_date, until_date, created_by, created_at
+		FROM freeze_windows ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list freeze windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []app.FreezeWindow{}
+	for rows.Next() {
+		var fw app.FreezeWindow
+		var days string
+		if err := rows.Scan(&fw.ID, &fw.Name, &days, &fw.Start, &fw.End, &fw.From, &fw.Until, &fw.CreatedBy, &fw.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan freeze window: %w", err)
+		}
+		if days != "" {
+			fw.Days = strings.Split(days, ",")
+		}
+		windows = append(windows, fw)
+	}
+	return windows, rows.Err()
+}
+
+// DeleteFreezeWindow removes a freeze window and reports whether it existed
+func (s *Storage) DeleteFreezeWindow(id string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM freeze_windows WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete freeze window: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// masterKeyFile holds the key app secrets are encrypted with. It lives next
+// to basepod.yaml but is not part of backups, so a backup never carries both
+// the ciphertext and its key.
+const masterKeyFile = "master.key"
+
+// MasterKeyEnv overrides the master key file with a base64 encoded key
+const MasterKeyEnv = "BASEPOD_MASTER_KEY"
+
+// loadMasterKey reads the 32-byte master key from the environment or path,
+// creating the file with a random key if neither exists
+func loadMasterKey(path string) ([]byte, error) {
+	if encoded := os.Getenv(MasterKeyEnv); encoded != "" {
+		return decodeMasterKey(encoded)
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return decodeMasterKey(string(data))
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// O_EXCL so two processes starting at once can't overwrite each other's key
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return loadMasterKey(path)
+		}
+		return nil, fmt.Errorf("failed to create master key: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		return nil, fmt.Errorf("failed to write master key: %w", err)
+	}
+	return key, nil
+}
+
+func decodeMasterKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, base64 encoded")
+	}
+	return key, nil
+}
+
+// masterKey returns the secrets key, loading it on first use
+func (s *Storage) masterKey() ([]byte, error) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	if s.secretKey == nil {
+		key, err := loadMasterKey(s.keyPath)
+		if err != nil {
+			return nil, err
+		}
+		s.secretKey = key
+	}
+	return s.secretKey, nil
+}
+
+// sealSecret encrypts value with AES-256-GCM. The app ID and key name are
+// authenticated too, so a value copied to another app or key won't decrypt.
+func sealSecret(key []byte, appID, name, value string) ([]byte, error) {
+	gcm, err := secretCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, []byte(value), []byte(appID+"/"+name)), nil
+}
+
+// openSecret decrypts a value sealed by sealSecret
+func openSecret(key []byte, appID, name string, sealed []byte) (string, error) {
+	gcm, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("secret %s is corrupt", name)
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	value, err := gcm.Open(nil, nonce, ciphertext, []byte(appID+"/"+name))
+	if err != nil {
+		return "", fmt.Errorf("secret %s can't be decrypted with this server's master key", name)
+	}
+	return string(value), nil
+}
+
+func secretCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SetSecret creates or replaces an app secret
+func (s *Storage) SetSecret(appID, name, value string) error {
+	key, err := s.masterKey()
+	if err != nil {
+		return err
+	}
+	sealed, err := sealSecret(key, appID, name, value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	now := time.Now()
+	_, err = s.db.Exec(`
+		INSERT INTO app_secrets (app_id, key, value, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(app_id, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, appID, name, sealed, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save secret: %w", err)
+	}
+	return nil
+}
+
+// ListSecrets lists an app's secret names, without their values
+func (s *Storage) ListSecrets(appID string) ([]app.SecretInfo, error) {
+	rows, err := s.db.Query("SELECT key, created_at, updated_at FROM app_secrets WHERE app_id = ? ORDER BY key", appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []app.SecretInfo
+	for rows.Next() {
+		var info app.SecretInfo
+		if err := rows.Scan(&info.Key, &info.CreatedAt, &info.UpdatedAt); err != nil {
+			continue
+		}
+		secrets = append(secrets, info)
+	}
+	return secrets, nil
+}
+
+// SecretValues decrypts an app's secrets for injection into its containers
+func (s *Storage) SecretValues(appID string) (map[string]string, error) {
+	rows, err := s.db.Query("SELECT key, value FROM app_secrets WHERE app_id = ?", appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+	defer rows.Close()
+
+	type sealedSecret struct {
+		name  string
+		value []byte
+	}
+	var sealed []sealedSecret
+	for rows.Next() {
+		var sec sealedSecret
+		if err := rows.Scan(&sec.name, &sec.value); err != nil {
+			continue
+		}
+		sealed = append(sealed, sec)
+	}
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+
+	key, err := s.masterKey()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(sealed))
+	for _, sec := range sealed {
+		value, err := openSecret(key, appID, sec.name, sec.value)
+		if err != nil {
+			return nil, err
+		}
+		values[sec.name] = value
+	}
+	return values, nil
+}
+
+// DeleteSecret removes an app secret, reporting whether it existed
+func (s *Storage) DeleteSecret(appID, name string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM app_secrets WHERE app_id = ? AND key = ?", appID, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete secret: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteSecretsForApp removes every secret of an app
+func (s *Storage) DeleteSecretsForApp(appID string) error {
+	_, err := s.db.Exec("DELETE FROM app_secrets WHERE app_id = ?", appID)
+	if err != nil {
+		return fmt.Errorf("failed to delete secrets: %w", err)
+	}
+	return nil
+}
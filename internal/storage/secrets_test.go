@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMasterKeyCreatedOnce(t *testing.T) {
+	t.Parallel()
+	if os.Getenv(MasterKeyEnv) != "" {
+		t.Skip(MasterKeyEnv + " is set")
+	}
+
+	path := filepath.Join(t.TempDir(), "config", masterKeyFile)
+	key, err := loadMasterKey(path)
+	if err != nil {
+		t.Fatalf("loadMasterKey: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("master key file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("master key mode = %v, want 0600", info.Mode().Perm())
+	}
+	again, err := loadMasterKey(path)
+	if err != nil || !bytes.Equal(key, again) {
+		t.Fatalf("second load = %x, %v; want the same key", again, err)
+	}
+}
+
+func TestSealSecret(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{7}, 32)
+	sealed, err := sealSecret(key, "app-1", "DB_PASSWORD", "hunter2")
+	if err != nil {
+		t.Fatalf("sealSecret: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("hunter2")) {
+		t.Fatalf("sealed value contains the plaintext")
+	}
+	if got, err := openSecret(key, "app-1", "DB_PASSWORD", sealed); err != nil || got != "hunter2" {
+		t.Fatalf("openSecret = %q, %v; want hunter2", got, err)
+	}
+	if _, err := openSecret(key, "app-2", "DB_PASSWORD", sealed); err == nil {
+		t.Fatalf("secret opened under another app")
+	}
+	if _, err := openSecret(bytes.Repeat([]byte{8}, 32), "app-1", "DB_PASSWORD", sealed); err == nil {
+		t.Fatalf("secret opened with another master key")
+	}
+}
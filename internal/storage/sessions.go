@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/base-go/basepod/internal/auth"
+)
+
+// Storage persists login sessions for the auth manager
+var _ auth.SessionStore = (*Storage)(nil)
+
+// LoadSessions returns every stored session
+func (s *Storage) LoadSessions() ([]auth.Session, error) {
+	rows, err := s.db.Query(`
+		SELECT token_hash, user_id, user_email, user_role, ip, user_agent, created_at, last_seen_at, expires_at
+		FROM sessions
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []auth.Session
+	for rows.Next() {
+		var sess auth.Session
+		if err := rows.Scan(&sess.TokenHash, &sess.UserID, &sess.UserEmail, &sess.UserRole, &sess.IP, &sess.UserAgent,
+			&sess.CreatedAt, &sess.LastSeenAt, &sess.ExpiresAt); err != nil {
+			continue
+		}
+		if len(sess.TokenHash) < 16 {
+			continue
+		}
+		sess.ID = sess.TokenHash[:16]
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
+
+// SaveSession creates or updates a session
+func (s *Storage) SaveSession(sess *auth.Session) error {
+	_, err := s.db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, user_email, user_role, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(token_hash) DO UPDATE SET user_role = excluded.user_role, ip = excluded.ip,
+			user_agent = excluded.user_agent, last_seen_at = excluded.last_seen_at, expires_at = excluded.expires_at
+	`, sess.TokenHash, sess.UserID, sess.UserEmail, sess.UserRole, sess.IP, sess.UserAgent, sess.CreatedAt, sess.LastSeenAt, sess.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession removes a session
+func (s *Storage) DeleteSession(tokenHash string) error {
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/base-go/basepod/internal/app"
@@ -16,6 +18,12 @@ import (
 // Storage provides data persistence operations
 type Storage struct {
 	db *sql.DB
+
+	keyPath   string // Master key file for secrets
+	keyMu     sync.Mutex
+	secretKey []byte // Loaded on first use
+
+	appUpdated func(a app.App) // Called after each app update, see OnAppUpdate
 }
 
 // New creates a new storage instance
@@ -31,7 +39,7 @@ func New() (*Storage, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	s := &Storage{db: db}
+	s := &Storage{db: db, keyPath: filepath.Join(paths.Config, masterKeyFile)}
 	if err := s.migrate(); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
@@ -44,6 +52,12 @@ func (s *Storage) Close() error {
 	return s.db.Close()
 }
 
+// OnAppUpdate registers fn to be called with the saved app after every
+// UpdateApp, so status changes can be pushed to clients
+func (s *Storage) OnAppUpdate(fn func(a app.App)) {
+	s.appUpdated = fn
+}
+
 // DB returns the underlying database connection
 func (s *Storage) DB() *sql.DB {
 	return s.db
@@ -251,6 +265,208 @@ func (s *Storage) migrate() error {
 		// Add owner_id for Construct user-scoped apps
 		`ALTER TABLE apps ADD COLUMN owner_id TEXT DEFAULT ''`,
 		`CREATE INDEX IF NOT EXISTS idx_apps_owner ON apps(owner_id)`,
+		// Semantic search index over app logs and notes
+		`CREATE TABLE IF NOT EXISTS ai_documents (
+			id TEXT PRIMARY KEY,
+			app_id TEXT,
+			source TEXT NOT NULL,
+			title TEXT,
+			content TEXT NOT NULL,
+			embedding TEXT NOT NULL,
+			timestamp DATETIME,
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_documents_app ON ai_documents(app_id)`,
+		// Deploy markers overlaid on logs and metrics
+		`CREATE TABLE IF NOT EXISTS deploy_markers (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			app_name TEXT NOT NULL,
+			release_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			image TEXT,
+			commit_hash TEXT,
+			commit_msg TEXT,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_deploy_markers_app_time ON deploy_markers(app_id, created_at)`,
+		// Cron job action: command (exec in container), restart or redeploy
+		`ALTER TABLE cron_jobs ADD COLUMN action TEXT DEFAULT 'command'`,
+		// Redirects left behind by domain migrations
+		`CREATE TABLE IF NOT EXISTS domain_redirects (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			from_domain TEXT NOT NULL UNIQUE,
+			to_domain TEXT NOT NULL,
+			hits INTEGER DEFAULT 0,
+			last_hit_at DATETIME,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Add seo column for search engine controls
+		`ALTER TABLE apps ADD COLUMN seo TEXT`,
+		// Add privacy column for access log privacy settings
+		`ALTER TABLE apps ADD COLUMN privacy TEXT`,
+		// Add websocket column for long-lived connection settings
+		`ALTER TABLE apps ADD COLUMN websocket TEXT`,
+		// Add last_exit column for why the container last stopped
+		`ALTER TABLE apps ADD COLUMN last_exit TEXT`,
+		// Add security_headers column for the security header preset and overrides
+		`ALTER TABLE apps ADD COLUMN security_headers TEXT`,
+		// Path and header rules that share one domain between apps
+		`CREATE TABLE IF NOT EXISTS domain_routes (
+			id TEXT PRIMARY KEY,
+			domain TEXT NOT NULL,
+			path_prefix TEXT NOT NULL,
+			header_name TEXT DEFAULT '',
+			header_value TEXT DEFAULT '',
+			app_id TEXT NOT NULL,
+			strip_prefix INTEGER DEFAULT 0,
+			priority INTEGER DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_domain_routes_domain ON domain_routes(domain)`,
+		// App secrets, encrypted with the server master key
+		`CREATE TABLE IF NOT EXISTS app_secrets (
+			app_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value BLOB NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY (app_id, key),
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// User who created a deploy token; empty for tokens made by an admin
+		`ALTER TABLE deploy_tokens ADD COLUMN created_by TEXT DEFAULT ''`,
+		// Weekly digest recipients; the token is the unsubscribe link's secret
+		`CREATE TABLE IF NOT EXISTS digest_subscriptions (
+			email TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL DEFAULT '',
+			token TEXT NOT NULL UNIQUE,
+			created_at DATETIME NOT NULL
+		)`,
+		// Where a cron job was defined; "config" for basepod.yaml jobs
+		`ALTER TABLE cron_jobs ADD COLUMN source TEXT DEFAULT ''`,
+		// Latest TLS scan of each app's domain
+		`CREATE TABLE IF NOT EXISTS tls_scans (
+			app_id TEXT PRIMARY KEY,
+			result TEXT NOT NULL,
+			scanned_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// One-off task runs (bp run-task)
+		`CREATE TABLE IF NOT EXISTS task_runs (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			command TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME,
+			status TEXT NOT NULL,
+			exit_code INTEGER DEFAULT 0,
+			output TEXT,
+			error TEXT,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_task_runs_app ON task_runs(app_id, started_at)`,
+		// Request counts alongside each app's resource metrics
+		`ALTER TABLE app_metrics ADD COLUMN requests INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE app_metrics ADD COLUMN errors INTEGER NOT NULL DEFAULT 0`,
+		// README and manifest from each app's deployed source
+		`CREATE TABLE IF NOT EXISTS app_docs (
+			app_id TEXT PRIMARY KEY,
+			docs TEXT NOT NULL,
+			updated_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Managed backing services and the apps they're attached to
+		`CREATE TABLE IF NOT EXISTS addons (
+			app_id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS addon_attachments (
+			addon_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			env_var TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (addon_id, app_id),
+			FOREIGN KEY (addon_id) REFERENCES addons(app_id) ON DELETE CASCADE,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS dns_records (
+			domain TEXT NOT NULL,
+			type TEXT NOT NULL,
+			value TEXT NOT NULL,
+			ttl INTEGER NOT NULL DEFAULT 0,
+			app_id TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY (domain, type)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_dns_records_app ON dns_records(app_id)`,
+		// Periods when deploys are refused without an override
+		`CREATE TABLE IF NOT EXISTS freeze_windows (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			days TEXT NOT NULL DEFAULT '',
+			start_time TEXT NOT NULL DEFAULT '',
+			end_time TEXT NOT NULL DEFAULT '',
+			from_date TEXT NOT NULL DEFAULT '',
+			until_date TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		)`,
+		// Disk reads and writes alongside each app's resource metrics
+		`ALTER TABLE app_metrics ADD COLUMN block_input INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE app_metrics ADD COLUMN block_output INTEGER NOT NULL DEFAULT 0`,
+		// TOTP two-factor auth, keyed by user ID or "admin" for the server password
+		`CREATE TABLE IF NOT EXISTS two_factor (
+			account_id TEXT PRIMARY KEY,
+			secret BLOB NOT NULL,
+			recovery_codes TEXT NOT NULL DEFAULT '[]',
+			enabled INTEGER NOT NULL DEFAULT 0,
+			last_step INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			enabled_at DATETIME
+		)`,
+		// Login sessions, so a restart doesn't sign everyone out
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL DEFAULT '',
+			user_email TEXT NOT NULL DEFAULT '',
+			user_role TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			last_seen_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL
+		)`,
+		// Add services column for the containers of multi-service apps
+		`ALTER TABLE apps ADD COLUMN services TEXT`,
+		// Add canary column for a new version taking part of an app's traffic
+		`ALTER TABLE apps ADD COLUMN canary TEXT`,
+		// Add routing column for extra proxy rules declared in basepod.yaml
+		`ALTER TABLE apps ADD COLUMN routing TEXT`,
+		// Add basic_auth column for password protected apps
+		`ALTER TABLE apps ADD COLUMN basic_auth TEXT`,
+		// Add static column for how a static site's files are served
+		`ALTER TABLE apps ADD COLUMN static TEXT`,
+		// Probes of each app's public URL
+		`CREATE TABLE IF NOT EXISTS uptime_checks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			up INTEGER NOT NULL,
+			status_code INTEGER DEFAULT 0,
+			response_ms INTEGER DEFAULT 0,
+			error TEXT DEFAULT '',
+			checked_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_uptime_checks_app_time ON uptime_checks(app_id, checked_at)`,
 	}
 
 	for _, migration := range migrations {
@@ -298,6 +514,16 @@ func (s *Storage) CreateApp(a *app.App) error {
 	mlxJSON, _ := json.Marshal(a.MLX)
 	aliasesJSON, _ := json.Marshal(a.Aliases)
 	healthCheckJSON, _ := json.Marshal(a.HealthCheck)
+	seoJSON, _ := json.Marshal(a.SEO)
+	privacyJSON, _ := json.Marshal(a.Privacy)
+	websocketJSON, _ := json.Marshal(a.WebSocket)
+	lastExitJSON, _ := json.Marshal(a.LastExit)
+	securityHeadersJSON, _ := json.Marshal(a.SecurityHeaders)
+	servicesJSON, _ := json.Marshal(a.Services)
+	canaryJSON, _ := json.Marshal(a.Canary)
+	routingJSON, _ := json.Marshal(a.Routing)
+	basicAuthJSON, _ := json.Marshal(a.BasicAuth)
+	staticJSON, _ := json.Marshal(a.Static)
 
 	// Convert empty domain to NULL (for database apps without domains)
 	var domain interface{} = a.Domain
@@ -312,12 +538,12 @@ func (s *Storage) CreateApp(a *app.App) error {
 	}
 
 	_, err := s.db.Exec(`
-		INSERT INTO apps (id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, owner_id, redirect_url, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO apps (id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, seo, privacy, websocket, last_exit, security_headers, services, canary, routing, basic_auth, static, owner_id, redirect_url, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, a.ID, a.Name, domain, string(aliasesJSON), a.ContainerID, a.Image, a.Status,
 		string(envJSON), string(portsJSON), string(volumesJSON),
 		string(resourcesJSON), string(deploymentJSON), string(deploymentsJSON), string(sslJSON),
-		appType, string(mlxJSON), string(healthCheckJSON),
+		appType, string(mlxJSON), string(healthCheckJSON), string(seoJSON), string(privacyJSON), string(websocketJSON), string(lastExitJSON), string(securityHeadersJSON), string(servicesJSON), string(canaryJSON), string(routingJSON), string(basicAuthJSON), string(staticJSON),
 		a.OwnerID, a.RedirectURL, a.CreatedAt, a.UpdatedAt)
 
 	if err != nil {
@@ -330,7 +556,7 @@ func (s *Storage) CreateApp(a *app.App) error {
 // GetApp retrieves an app by ID
 func (s *Storage) GetApp(id string) (*app.App, error) {
 	row := s.db.QueryRow(`
-		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
+		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, seo, privacy, websocket, last_exit, security_headers, services, canary, routing, basic_auth, static, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
 		FROM apps WHERE id = ?
 	`, id)
 
@@ -340,7 +566,7 @@ func (s *Storage) GetApp(id string) (*app.App, error) {
 // GetAppByName retrieves an app by name
 func (s *Storage) GetAppByName(name string) (*app.App, error) {
 	row := s.db.QueryRow(`
-		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
+		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, seo, privacy, websocket, last_exit, security_headers, services, canary, routing, basic_auth, static, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
 		FROM apps WHERE name = ?
 	`, name)
 
@@ -350,7 +576,7 @@ func (s *Storage) GetAppByName(name string) (*app.App, error) {
 // GetAppByDomain retrieves an app by domain
 func (s *Storage) GetAppByDomain(domain string) (*app.App, error) {
 	row := s.db.QueryRow(`
-		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
+		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, seo, privacy, websocket, last_exit, security_headers, services, canary, routing, basic_auth, static, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
 		FROM apps WHERE domain = ?
 	`, domain)
 
@@ -367,7 +593,7 @@ func (s *Storage) GetAppByDomainOrAlias(domain string) (*app.App, error) {
 
 	// Search aliases (stored as JSON array, use LIKE for SQLite)
 	row := s.db.QueryRow(`
-		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
+		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, seo, privacy, websocket, last_exit, security_headers, services, canary, routing, basic_auth, static, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
 		FROM apps WHERE aliases LIKE ?
 	`, `%"`+domain+`"%`)
 
@@ -378,12 +604,12 @@ func (s *Storage) GetAppByDomainOrAlias(domain string) (*app.App, error) {
 func (s *Storage) scanApp(row *sql.Row) (*app.App, error) {
 	var a app.App
 	var envJSON, portsJSON, volumesJSON, resourcesJSON, deploymentJSON, sslJSON string
-	var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON sql.NullString
+	var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON, seoJSON, privacyJSON, websocketJSON, lastExitJSON, securityHeadersJSON, servicesJSON, canaryJSON, routingJSON, basicAuthJSON, staticJSON sql.NullString
 
 	err := row.Scan(
 		&a.ID, &a.Name, &domain, &aliasesJSON, &containerID, &image, &a.Status,
 		&envJSON, &portsJSON, &volumesJSON, &resourcesJSON, &deploymentJSON, &deploymentsJSON, &sslJSON,
-		&appType, &mlxJSON, &healthCheckJSON, &a.OwnerID, &a.RedirectURL,
+		&appType, &mlxJSON, &healthCheckJSON, &seoJSON, &privacyJSON, &websocketJSON, &lastExitJSON, &securityHeadersJSON, &servicesJSON, &canaryJSON, &routingJSON, &basicAuthJSON, &staticJSON, &a.OwnerID, &a.RedirectURL,
 		&a.CreatedAt, &a.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -419,6 +645,36 @@ func (s *Storage) scanApp(row *sql.Row) (*app.App, error) {
 	if healthCheckJSON.Valid && healthCheckJSON.String != "" {
 		json.Unmarshal([]byte(healthCheckJSON.String), &a.HealthCheck)
 	}
+	if seoJSON.Valid && seoJSON.String != "" {
+		json.Unmarshal([]byte(seoJSON.String), &a.SEO)
+	}
+	if privacyJSON.Valid && privacyJSON.String != "" {
+		json.Unmarshal([]byte(privacyJSON.String), &a.Privacy)
+	}
+	if websocketJSON.Valid && websocketJSON.String != "" {
+		json.Unmarshal([]byte(websocketJSON.String), &a.WebSocket)
+	}
+	if lastExitJSON.Valid && lastExitJSON.String != "" {
+		json.Unmarshal([]byte(lastExitJSON.String), &a.LastExit)
+	}
+	if securityHeadersJSON.Valid && securityHeadersJSON.String != "" {
+		json.Unmarshal([]byte(securityHeadersJSON.String), &a.SecurityHeaders)
+	}
+	if servicesJSON.Valid && servicesJSON.String != "" {
+		json.Unmarshal([]byte(servicesJSON.String), &a.Services)
+	}
+	if canaryJSON.Valid && canaryJSON.String != "" {
+		json.Unmarshal([]byte(canaryJSON.String), &a.Canary)
+	}
+	if routingJSON.Valid && routingJSON.String != "" {
+		json.Unmarshal([]byte(routingJSON.String), &a.Routing)
+	}
+	if basicAuthJSON.Valid && basicAuthJSON.String != "" {
+		json.Unmarshal([]byte(basicAuthJSON.String), &a.BasicAuth)
+	}
+	if staticJSON.Valid && staticJSON.String != "" {
+		json.Unmarshal([]byte(staticJSON.String), &a.Static)
+	}
 
 	return &a, nil
 }
@@ -426,7 +682,7 @@ func (s *Storage) scanApp(row *sql.Row) (*app.App, error) {
 // ListApps retrieves all apps
 func (s *Storage) ListApps() ([]app.App, error) {
 	rows, err := s.db.Query(`
-		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
+		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, seo, privacy, websocket, last_exit, security_headers, services, canary, routing, basic_auth, static, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
 		FROM apps ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -438,12 +694,12 @@ func (s *Storage) ListApps() ([]app.App, error) {
 	for rows.Next() {
 		var a app.App
 		var envJSON, portsJSON, volumesJSON, resourcesJSON, deploymentJSON, sslJSON string
-		var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON sql.NullString
+		var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON, seoJSON, privacyJSON, websocketJSON, lastExitJSON, securityHeadersJSON, servicesJSON, canaryJSON, routingJSON, basicAuthJSON, staticJSON sql.NullString
 
 		err := rows.Scan(
 			&a.ID, &a.Name, &domain, &aliasesJSON, &containerID, &image, &a.Status,
 			&envJSON, &portsJSON, &volumesJSON, &resourcesJSON, &deploymentJSON, &deploymentsJSON, &sslJSON,
-			&appType, &mlxJSON, &healthCheckJSON, &a.OwnerID, &a.RedirectURL,
+			&appType, &mlxJSON, &healthCheckJSON, &seoJSON, &privacyJSON, &websocketJSON, &lastExitJSON, &securityHeadersJSON, &servicesJSON, &canaryJSON, &routingJSON, &basicAuthJSON, &staticJSON, &a.OwnerID, &a.RedirectURL,
 			&a.CreatedAt, &a.UpdatedAt,
 		)
 		if err != nil {
@@ -476,6 +732,36 @@ func (s *Storage) ListApps() ([]app.App, error) {
 		if healthCheckJSON.Valid && healthCheckJSON.String != "" {
 			json.Unmarshal([]byte(healthCheckJSON.String), &a.HealthCheck)
 		}
+		if seoJSON.Valid && seoJSON.String != "" {
+			json.Unmarshal([]byte(seoJSON.String), &a.SEO)
+		}
+		if privacyJSON.Valid && privacyJSON.String != "" {
+			json.Unmarshal([]byte(privacyJSON.String), &a.Privacy)
+		}
+		if websocketJSON.Valid && websocketJSON.String != "" {
+			json.Unmarshal([]byte(websocketJSON.String), &a.WebSocket)
+		}
+		if lastExitJSON.Valid && lastExitJSON.String != "" {
+			json.Unmarshal([]byte(lastExitJSON.String), &a.LastExit)
+		}
+		if securityHeadersJSON.Valid && securityHeadersJSON.String != "" {
+			json.Unmarshal([]byte(securityHeadersJSON.String), &a.SecurityHeaders)
+		}
+		if servicesJSON.Valid && servicesJSON.String != "" {
+			json.Unmarshal([]byte(servicesJSON.String), &a.Services)
+		}
+		if canaryJSON.Valid && canaryJSON.String != "" {
+			json.Unmarshal([]byte(canaryJSON.String), &a.Canary)
+		}
+		if routingJSON.Valid && routingJSON.String != "" {
+			json.Unmarshal([]byte(routingJSON.String), &a.Routing)
+		}
+		if basicAuthJSON.Valid && basicAuthJSON.String != "" {
+			json.Unmarshal([]byte(basicAuthJSON.String), &a.BasicAuth)
+		}
+		if staticJSON.Valid && staticJSON.String != "" {
+			json.Unmarshal([]byte(staticJSON.String), &a.Static)
+		}
 
 		apps = append(apps, a)
 	}
@@ -486,7 +772,7 @@ func (s *Storage) ListApps() ([]app.App, error) {
 // ListAppsByOwner retrieves apps owned by a specific user
 func (s *Storage) ListAppsByOwner(ownerID string) ([]app.App, error) {
 	rows, err := s.db.Query(`
-		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
+		SELECT id, name, domain, aliases, container_id, image, status, env, ports, volumes, resources, deployment, deployments, ssl, type, mlx, health_check, seo, privacy, websocket, last_exit, security_headers, services, canary, routing, basic_auth, static, COALESCE(owner_id,'') as owner_id, COALESCE(redirect_url,'') as redirect_url, created_at, updated_at
 		FROM apps WHERE owner_id = ? ORDER BY created_at DESC
 	`, ownerID)
 	if err != nil {
@@ -498,12 +784,12 @@ func (s *Storage) ListAppsByOwner(ownerID string) ([]app.App, error) {
 	for rows.Next() {
 		var a app.App
 		var envJSON, portsJSON, volumesJSON, resourcesJSON, deploymentJSON, sslJSON string
-		var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON sql.NullString
+		var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON, seoJSON, privacyJSON, websocketJSON, lastExitJSON, securityHeadersJSON, servicesJSON, canaryJSON, routingJSON, basicAuthJSON, staticJSON sql.NullString
 
 		err := rows.Scan(
 			&a.ID, &a.Name, &domain, &aliasesJSON, &containerID, &image, &a.Status,
 			&envJSON, &portsJSON, &volumesJSON, &resourcesJSON, &deploymentJSON, &deploymentsJSON, &sslJSON,
-			&appType, &mlxJSON, &healthCheckJSON, &a.OwnerID, &a.RedirectURL,
+			&appType, &mlxJSON, &healthCheckJSON, &seoJSON, &privacyJSON, &websocketJSON, &lastExitJSON, &securityHeadersJSON, &servicesJSON, &canaryJSON, &routingJSON, &basicAuthJSON, &staticJSON, &a.OwnerID, &a.RedirectURL,
 			&a.CreatedAt, &a.UpdatedAt,
 		)
 		if err != nil {
@@ -536,6 +822,36 @@ func (s *Storage) ListAppsByOwner(ownerID string) ([]app.App, error) {
 		if healthCheckJSON.Valid && healthCheckJSON.String != "" {
 			json.Unmarshal([]byte(healthCheckJSON.String), &a.HealthCheck)
 		}
+		if seoJSON.Valid && seoJSON.String != "" {
+			json.Unmarshal([]byte(seoJSON.String), &a.SEO)
+		}
+		if privacyJSON.Valid && privacyJSON.String != "" {
+			json.Unmarshal([]byte(privacyJSON.String), &a.Privacy)
+		}
+		if websocketJSON.Valid && websocketJSON.String != "" {
+			json.Unmarshal([]byte(websocketJSON.String), &a.WebSocket)
+		}
+		if lastExitJSON.Valid && lastExitJSON.String != "" {
+			json.Unmarshal([]byte(lastExitJSON.String), &a.LastExit)
+		}
+		if securityHeadersJSON.Valid && securityHeadersJSON.String != "" {
+			json.Unmarshal([]byte(securityHeadersJSON.String), &a.SecurityHeaders)
+		}
+		if servicesJSON.Valid && servicesJSON.String != "" {
+			json.Unmarshal([]byte(servicesJSON.String), &a.Services)
+		}
+		if canaryJSON.Valid && canaryJSON.String != "" {
+			json.Unmarshal([]byte(canaryJSON.String), &a.Canary)
+		}
+		if routingJSON.Valid && routingJSON.String != "" {
+			json.Unmarshal([]byte(routingJSON.String), &a.Routing)
+		}
+		if basicAuthJSON.Valid && basicAuthJSON.String != "" {
+			json.Unmarshal([]byte(basicAuthJSON.String), &a.BasicAuth)
+		}
+		if staticJSON.Valid && staticJSON.String != "" {
+			json.Unmarshal([]byte(staticJSON.String), &a.Static)
+		}
 
 		apps = append(apps, a)
 	}
@@ -557,6 +873,16 @@ func (s *Storage) UpdateApp(a *app.App) error {
 	mlxJSON, _ := json.Marshal(a.MLX)
 	aliasesJSON, _ := json.Marshal(a.Aliases)
 	healthCheckJSON, _ := json.Marshal(a.HealthCheck)
+	seoJSON, _ := json.Marshal(a.SEO)
+	privacyJSON, _ := json.Marshal(a.Privacy)
+	websocketJSON, _ := json.Marshal(a.WebSocket)
+	lastExitJSON, _ := json.Marshal(a.LastExit)
+	securityHeadersJSON, _ := json.Marshal(a.SecurityHeaders)
+	servicesJSON, _ := json.Marshal(a.Services)
+	canaryJSON, _ := json.Marshal(a.Canary)
+	routingJSON, _ := json.Marshal(a.Routing)
+	basicAuthJSON, _ := json.Marshal(a.BasicAuth)
+	staticJSON, _ := json.Marshal(a.Static)
 
 	// Convert empty domain to NULL (for database apps without domains)
 	var domain interface{} = a.Domain
@@ -574,19 +900,22 @@ func (s *Storage) UpdateApp(a *app.App) error {
 		UPDATE apps SET
 			name = ?, domain = ?, aliases = ?, container_id = ?, image = ?, status = ?,
 			env = ?, ports = ?, volumes = ?, resources = ?, deployment = ?, deployments = ?, ssl = ?,
-			type = ?, mlx = ?, health_check = ?, redirect_url = ?,
+			type = ?, mlx = ?, health_check = ?, seo = ?, privacy = ?, websocket = ?, last_exit = ?, security_headers = ?, services = ?, canary = ?, routing = ?, basic_auth = ?, static = ?, redirect_url = ?,
 			updated_at = ?
 		WHERE id = ?
 	`, a.Name, domain, string(aliasesJSON), a.ContainerID, a.Image, a.Status,
 		string(envJSON), string(portsJSON), string(volumesJSON),
 		string(resourcesJSON), string(deploymentJSON), string(deploymentsJSON), string(sslJSON),
-		appType, string(mlxJSON), string(healthCheckJSON), a.RedirectURL,
+		appType, string(mlxJSON), string(healthCheckJSON), string(seoJSON), string(privacyJSON), string(websocketJSON), string(lastExitJSON), string(securityHeadersJSON), string(servicesJSON), string(canaryJSON), string(routingJSON), string(basicAuthJSON), string(staticJSON), a.RedirectURL,
 		a.UpdatedAt, a.ID)
 
 	if err != nil {
 		return fmt.Errorf("failed to update app: %w", err)
 	}
 
+	if s.appUpdated != nil {
+		s.appUpdated(*a)
+	}
 	return nil
 }
 
@@ -777,9 +1106,9 @@ func (s *Storage) UpdateWebhookDeliveryStatus(id, status, errMsg string) error {
 // CreateCronJob creates a new cron job
 func (s *Storage) CreateCronJob(j *app.CronJob) error {
 	_, err := s.db.Exec(`
-		INSERT INTO cron_jobs (id, app_id, name, schedule, command, enabled, next_run, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, j.ID, j.AppID, j.Name, j.Schedule, j.Command, j.Enabled, j.NextRun, j.CreatedAt, j.UpdatedAt)
+		INSERT INTO cron_jobs (id, app_id, name, schedule, action, command, source, enabled, next_run, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, j.ID, j.AppID, j.Name, j.Schedule, j.Action, j.Command, j.Source, j.Enabled, j.NextRun, j.CreatedAt, j.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create cron job: %w", err)
 	}
@@ -792,9 +1121,9 @@ func (s *Storage) GetCronJob(id string) (*app.CronJob, error) {
 	var lastRun, nextRun sql.NullTime
 	var lastStatus, lastError sql.NullString
 	err := s.db.QueryRow(`
-		SELECT id, app_id, name, schedule, command, enabled, last_run, last_status, last_error, next_run, created_at, updated_at
+		SELECT id, app_id, name, schedule, COALESCE(action,'command'), command, COALESCE(source,''), enabled, last_run, last_status, last_error, next_run, created_at, updated_at
 		FROM cron_jobs WHERE id = ?
-	`, id).Scan(&j.ID, &j.AppID, &j.Name, &j.Schedule, &j.Command, &j.Enabled,
+	`, id).Scan(&j.ID, &j.AppID, &j.Name, &j.Schedule, &j.Action, &j.Command, &j.Source, &j.Enabled,
 		&lastRun, &lastStatus, &lastError, &nextRun, &j.CreatedAt, &j.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -816,12 +1145,40 @@ func (s *Storage) GetCronJob(id string) (*app.CronJob, error) {
 // ListCronJobs lists cron jobs for an app
 func (s *Storage) ListCronJobs(appID string) ([]app.CronJob, error) {
 	rows, err := s.db.Query(`
-		SELECT id, app_id, name, schedule, command, enabled, last_run, last_status, last_error, next_run, created_at, updated_at
+		SELECT id, app_id, name, schedule, COALESCE(action,'command'), command, COALESCE(source,''), enabled, last_run, last_status, last_error, next_run, created_at, updated_at
 		FROM cron_jobs WHERE app_id = ? ORDER BY created_at DESC
 	`, appID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list cron jobs: %w", err)
 	}
+	return scanCronJobs(rows)
+}
+
+// ListEnabledCronJobs lists enabled cron jobs across all apps
+func (s *Storage) ListEnabledCronJobs() ([]app.CronJob, error) {
+	rows, err := s.db.Query(`
+		SELECT id, app_id, name, schedule, COALESCE(action,'command'), command, COALESCE(source,''), enabled, last_run, last_status, last_error, next_run, created_at, updated_at
+		FROM cron_jobs WHERE enabled = 1 ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cron jobs: %w", err)
+	}
+	return scanCronJobs(rows)
+}
+
+// ListAllCronJobs lists cron jobs across all apps, enabled or not
+func (s *Storage) ListAllCronJobs() ([]app.CronJob, error) {
+	rows, err := s.db.Query(`
+		SELECT id, app_id, name, schedule, COALESCE(action,'command'), command, COALESCE(source,''), enabled, last_run, last_status, last_error, next_run, created_at, updated_at
+		FROM cron_jobs ORDER BY app_id, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cron jobs: %w", err)
+	}
+	return scanCronJobs(rows)
+}
+
+func scanCronJobs(rows *sql.Rows) ([]app.CronJob, error) {
 	defer rows.Close()
 
 	var jobs []app.CronJob
@@ -829,7 +1186,7 @@ func (s *Storage) ListCronJobs(appID string) ([]app.CronJob, error) {
 		var j app.CronJob
 		var lastRun, nextRun sql.NullTime
 		var lastStatus, lastError sql.NullString
-		if err := rows.Scan(&j.ID, &j.AppID, &j.Name, &j.Schedule, &j.Command, &j.Enabled,
+		if err := rows.Scan(&j.ID, &j.AppID, &j.Name, &j.Schedule, &j.Action, &j.Command, &j.Source, &j.Enabled,
 			&lastRun, &lastStatus, &lastError, &nextRun, &j.CreatedAt, &j.UpdatedAt); err != nil {
 			continue
 		}
@@ -850,9 +1207,9 @@ func (s *Storage) ListCronJobs(appID string) ([]app.CronJob, error) {
 func (s *Storage) UpdateCronJob(j *app.CronJob) error {
 	j.UpdatedAt = time.Now()
 	_, err := s.db.Exec(`
-		UPDATE cron_jobs SET name=?, schedule=?, command=?, enabled=?, last_run=?, last_status=?, last_error=?, next_run=?, updated_at=?
+		UPDATE cron_jobs SET name=?, schedule=?, action=?, command=?, enabled=?, last_run=?, last_status=?, last_error=?, next_run=?, updated_at=?
 		WHERE id = ?
-	`, j.Name, j.Schedule, j.Command, j.Enabled, j.LastRun, j.LastStatus, j.LastError, j.NextRun, j.UpdatedAt, j.ID)
+	`, j.Name, j.Schedule, j.Action, j.Command, j.Enabled, j.LastRun, j.LastStatus, j.LastError, j.NextRun, j.UpdatedAt, j.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update cron job: %w", err)
 	}
@@ -938,25 +1295,42 @@ func (s *Storage) SaveActivityLog(l *app.ActivityLog) error {
 
 // ListActivityLogs retrieves activity logs with optional filters
 func (s *Storage) ListActivityLogs(targetID string, action string, limit int) ([]app.ActivityLog, error) {
-	return s.ListActivityLogsPaginated(targetID, action, limit, 0)
+	return s.ListActivityLogsPaginated(targetID, action, nil, limit, 0)
 }
 
-func (s *Storage) ListActivityLogsPaginated(targetID string, action string, limit int, offset int) ([]app.ActivityLog, error) {
-	if limit <= 0 {
-		limit = 50
-	}
-
-	query := "SELECT id, actor_type, action, target_type, target_id, target_name, details, status, ip_address, created_at FROM activity_log WHERE 1=1"
+// activityLogFilter builds the WHERE clause shared by activity log queries.
+// A non-nil appIDs limits results to entries about those apps.
+func activityLogFilter(targetID string, action string, appIDs []string) (string, []interface{}) {
+	where := " WHERE 1=1"
 	var args []interface{}
 
 	if targetID != "" {
-		query += " AND target_id = ?"
+		where += " AND target_id = ?"
 		args = append(args, targetID)
 	}
 	if action != "" {
-		query += " AND action = ?"
+		where += " AND action = ?"
 		args = append(args, action)
 	}
+	if appIDs != nil {
+		if len(appIDs) == 0 {
+			return where + " AND 1=0", args
+		}
+		where += " AND target_type = 'app' AND target_id IN (?" + strings.Repeat(", ?", len(appIDs)-1) + ")"
+		for _, id := range appIDs {
+			args = append(args, id)
+		}
+	}
+	return where, args
+}
+
+func (s *Storage) ListActivityLogsPaginated(targetID string, action string, appIDs []string, limit int, offset int) ([]app.ActivityLog, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	where, args := activityLogFilter(targetID, action, appIDs)
+	query := "SELECT id, actor_type, action, target_type, target_id, target_name, details, status, ip_address, created_at FROM activity_log" + where
 	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
 	args = append(args, limit, offset)
 
@@ -965,7 +1339,22 @@ func (s *Storage) ListActivityLogsPaginated(targetID string, action string, limi
 		return nil, fmt.Errorf("failed to list activity logs: %w", err)
 	}
 	defer rows.Close()
+	return scanActivityLogs(rows), nil
+}
 
+// ListActivityLogsSince returns activity logs in [since, until), oldest first
+func (s *Storage) ListActivityLogsSince(since, until time.Time) ([]app.ActivityLog, error) {
+	rows, err := s.db.Query(`SELECT id, actor_type, action, target_type, target_id, target_name, details, status, ip_address, created_at
+		FROM activity_log WHERE created_at >= ? AND created_at < ? ORDER BY created_at ASC`, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list activity logs: %w", err)
+	}
+	defer rows.Close()
+	return scanActivityLogs(rows), nil
+}
+
+// scanActivityLogs reads activity log rows, skipping any that fail to scan
+func scanActivityLogs(rows *sql.Rows) []app.ActivityLog {
 	var logs []app.ActivityLog
 	for rows.Next() {
 		var l app.ActivityLog
@@ -981,24 +1370,14 @@ func (s *Storage) ListActivityLogsPaginated(targetID string, action string, limi
 		l.IPAddress = ipAddr.String
 		logs = append(logs, l)
 	}
-	return logs, nil
+	return logs
 }
 
-func (s *Storage) CountActivityLogs(targetID string, action string) (int, error) {
-	query := "SELECT COUNT(*) FROM activity_log WHERE 1=1"
-	var args []interface{}
-
-	if targetID != "" {
-		query += " AND target_id = ?"
-		args = append(args, targetID)
-	}
-	if action != "" {
-		query += " AND action = ?"
-		args = append(args, action)
-	}
+func (s *Storage) CountActivityLogs(targetID string, action string, appIDs []string) (int, error) {
+	where, args := activityLogFilter(targetID, action, appIDs)
 
 	var count int
-	err := s.db.QueryRow(query, args...).Scan(&count)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM activity_log"+where, args...).Scan(&count)
 	return count, err
 }
 
@@ -1119,9 +1498,9 @@ func (s *Storage) DeleteNotificationConfig(id string) error {
 func (s *Storage) CreateDeployToken(t *app.DeployToken) error {
 	scopesJSON, _ := json.Marshal(t.Scopes)
 	_, err := s.db.Exec(`
-		INSERT INTO deploy_tokens (id, name, token_hash, prefix, scopes, created_at, expires_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, t.ID, t.Name, t.TokenHash, t.Prefix, string(scopesJSON), t.CreatedAt, t.ExpiresAt)
+		INSERT INTO deploy_tokens (id, name, token_hash, prefix, scopes, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, t.Name, t.TokenHash, t.Prefix, string(scopesJSON), t.CreatedBy, t.CreatedAt, t.ExpiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to create deploy token: %w", err)
 	}
@@ -1134,9 +1513,9 @@ func (s *Storage) GetDeployTokenByHash(hash string) (*app.DeployToken, error) {
 	var scopesJSON string
 	var lastUsed, expires sql.NullTime
 	err := s.db.QueryRow(`
-		SELECT id, name, token_hash, prefix, scopes, last_used_at, created_at, expires_at
+		SELECT id, name, token_hash, prefix, scopes, COALESCE(created_by,''), last_used_at, created_at, expires_at
 		FROM deploy_tokens WHERE token_hash = ?
-	`, hash).Scan(&t.ID, &t.Name, &t.TokenHash, &t.Prefix, &scopesJSON, &lastUsed, &t.CreatedAt, &expires)
+	`, hash).Scan(&t.ID, &t.Name, &t.TokenHash, &t.Prefix, &scopesJSON, &t.CreatedBy, &lastUsed, &t.CreatedAt, &expires)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1156,7 +1535,7 @@ func (s *Storage) GetDeployTokenByHash(hash string) (*app.DeployToken, error) {
 // ListDeployTokens lists all deploy tokens
 func (s *Storage) ListDeployTokens() ([]app.DeployToken, error) {
 	rows, err := s.db.Query(`
-		SELECT id, name, token_hash, prefix, scopes, last_used_at, created_at, expires_at
+		SELECT id, name, token_hash, prefix, scopes, COALESCE(created_by,''), last_used_at, created_at, expires_at
 		FROM deploy_tokens ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -1169,7 +1548,7 @@ func (s *Storage) ListDeployTokens() ([]app.DeployToken, error) {
 		var t app.DeployToken
 		var scopesJSON string
 		var lastUsed, expires sql.NullTime
-		if err := rows.Scan(&t.ID, &t.Name, &t.TokenHash, &t.Prefix, &scopesJSON, &lastUsed, &t.CreatedAt, &expires); err != nil {
+		if err := rows.Scan(&t.ID, &t.Name, &t.TokenHash, &t.Prefix, &scopesJSON, &t.CreatedBy, &lastUsed, &t.CreatedAt, &expires); err != nil {
 			continue
 		}
 		json.Unmarshal([]byte(scopesJSON), &t.Scopes)
@@ -1292,9 +1671,26 @@ func (s *Storage) UpdateUserPassword(id, passwordHash string) error {
 	return err
 }
 
+// DeleteUser removes a user with their app grants and two-factor auth.
+// SQLite doesn't enforce ON DELETE CASCADE unless foreign keys are switched
+// on, so those are removed here.
 func (s *Storage) DeleteUser(id string) error {
-	_, err := s.db.Exec("DELETE FROM users WHERE id = ?", id)
-	return err
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM user_app_access WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear user app access: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM two_factor WHERE account_id = ?", id); err != nil {
+		return fmt.Errorf("failed to clear two-factor auth: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *Storage) ClearInviteToken(id string) error {
@@ -1334,6 +1730,15 @@ func (s *Storage) SetUserAppAccess(userID string, appIDs []string) error {
 	return tx.Commit()
 }
 
+// GrantUserAppAccess gives a user access to one more app
+func (s *Storage) GrantUserAppAccess(userID, appID string) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO user_app_access (user_id, app_id, created_at) VALUES (?, ?, ?)", userID, appID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to grant user app access: %w", err)
+	}
+	return nil
+}
+
 // GetUserAppAccess returns list of app IDs a user can access
 func (s *Storage) GetUserAppAccess(userID string) ([]string, error) {
 	rows, err := s.db.Query("SELECT app_id FROM user_app_access WHERE user_id = ?", userID)
@@ -1356,7 +1761,7 @@ func (s *Storage) GetUserAppAccess(userID string) ([]string, error) {
 // ListAppsForUser returns apps filtered by user_app_access
 func (s *Storage) ListAppsForUser(userID string) ([]app.App, error) {
 	rows, err := s.db.Query(`
-		SELECT a.id, a.name, a.domain, a.aliases, a.container_id, a.image, a.status, a.env, a.ports, a.volumes, a.resources, a.deployment, a.deployments, a.ssl, a.type, a.mlx, a.health_check, COALESCE(a.owner_id,'') as owner_id, COALESCE(a.redirect_url,'') as redirect_url, a.created_at, a.updated_at
+		SELECT a.id, a.name, a.domain, a.aliases, a.container_id, a.image, a.status, a.env, a.ports, a.volumes, a.resources, a.deployment, a.deployments, a.ssl, a.type, a.mlx, a.health_check, a.seo, a.privacy, a.websocket, a.last_exit, a.security_headers, a.services, a.canary, a.routing, a.basic_auth, a.static, COALESCE(a.owner_id,'') as owner_id, COALESCE(a.redirect_url,'') as redirect_url, a.created_at, a.updated_at
 		FROM apps a
 		INNER JOIN user_app_access ua ON a.id = ua.app_id
 		WHERE ua.user_id = ?
@@ -1371,12 +1776,12 @@ func (s *Storage) ListAppsForUser(userID string) ([]app.App, error) {
 	for rows.Next() {
 		var a app.App
 		var envJSON, portsJSON, volumesJSON, resourcesJSON, deploymentJSON, sslJSON string
-		var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON sql.NullString
+		var domain, aliasesJSON, deploymentsJSON, containerID, image, appType, mlxJSON, healthCheckJSON, seoJSON, privacyJSON, websocketJSON, lastExitJSON, securityHeadersJSON, servicesJSON, canaryJSON, routingJSON, basicAuthJSON, staticJSON sql.NullString
 
 		err := rows.Scan(
 			&a.ID, &a.Name, &domain, &aliasesJSON, &containerID, &image, &a.Status,
 			&envJSON, &portsJSON, &volumesJSON, &resourcesJSON, &deploymentJSON, &deploymentsJSON, &sslJSON,
-			&appType, &mlxJSON, &healthCheckJSON, &a.OwnerID, &a.RedirectURL,
+			&appType, &mlxJSON, &healthCheckJSON, &seoJSON, &privacyJSON, &websocketJSON, &lastExitJSON, &securityHeadersJSON, &servicesJSON, &canaryJSON, &routingJSON, &basicAuthJSON, &staticJSON, &a.OwnerID, &a.RedirectURL,
 			&a.CreatedAt, &a.UpdatedAt,
 		)
 		if err != nil {
@@ -1409,6 +1814,36 @@ func (s *Storage) ListAppsForUser(userID string) ([]app.App, error) {
 		if healthCheckJSON.Valid && healthCheckJSON.String != "" {
 			json.Unmarshal([]byte(healthCheckJSON.String), &a.HealthCheck)
 		}
+		if seoJSON.Valid && seoJSON.String != "" {
+			json.Unmarshal([]byte(seoJSON.String), &a.SEO)
+		}
+		if privacyJSON.Valid && privacyJSON.String != "" {
+			json.Unmarshal([]byte(privacyJSON.String), &a.Privacy)
+		}
+		if websocketJSON.Valid && websocketJSON.String != "" {
+			json.Unmarshal([]byte(websocketJSON.String), &a.WebSocket)
+		}
+		if lastExitJSON.Valid && lastExitJSON.String != "" {
+			json.Unmarshal([]byte(lastExitJSON.String), &a.LastExit)
+		}
+		if securityHeadersJSON.Valid && securityHeadersJSON.String != "" {
+			json.Unmarshal([]byte(securityHeadersJSON.String), &a.SecurityHeaders)
+		}
+		if servicesJSON.Valid && servicesJSON.String != "" {
+			json.Unmarshal([]byte(servicesJSON.String), &a.Services)
+		}
+		if canaryJSON.Valid && canaryJSON.String != "" {
+			json.Unmarshal([]byte(canaryJSON.String), &a.Canary)
+		}
+		if routingJSON.Valid && routingJSON.String != "" {
+			json.Unmarshal([]byte(routingJSON.String), &a.Routing)
+		}
+		if basicAuthJSON.Valid && basicAuthJSON.String != "" {
+			json.Unmarshal([]byte(basicAuthJSON.String), &a.BasicAuth)
+		}
+		if staticJSON.Valid && staticJSON.String != "" {
+			json.Unmarshal([]byte(staticJSON.String), &a.Static)
+		}
 
 		apps = append(apps, a)
 	}
@@ -1431,9 +1866,9 @@ func (s *Storage) UserHasAppAccess(userID, appID string) (bool, error) {
 // SaveAppMetric stores a metric data point
 func (s *Storage) SaveAppMetric(m *app.AppMetric) error {
 	_, err := s.db.Exec(
-		`INSERT INTO app_metrics (app_id, cpu_percent, mem_usage, mem_limit, net_input, net_output, recorded_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		m.AppID, m.CPUPercent, m.MemUsage, m.MemLimit, m.NetInput, m.NetOutput, m.RecordedAt,
+		`INSERT INTO app_metrics (app_id, cpu_percent, mem_usage, mem_limit, net_input, net_output, block_input, block_output, requests, errors, recorded_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.AppID, m.CPUPercent, m.MemUsage, m.MemLimit, m.NetInput, m.NetOutput, m.BlockInput, m.BlockOutput, m.Requests, m.Errors, m.RecordedAt,
 	)
 	return err
 }
@@ -1441,7 +1876,7 @@ func (s *Storage) SaveAppMetric(m *app.AppMetric) error {
 // ListAppMetrics retrieves metrics for an app within a time range
 func (s *Storage) ListAppMetrics(appID string, since time.Time, limit int) ([]app.AppMetric, error) {
 	rows, err := s.db.Query(
-		`SELECT id, app_id, cpu_percent, mem_usage, mem_limit, net_input, net_output, recorded_at
+		`SELECT id, app_id, cpu_percent, mem_usage, mem_limit, net_input, net_output, block_input, block_output, requests, errors, recorded_at
 		 FROM app_metrics WHERE app_id = ? AND recorded_at > ? ORDER BY recorded_at ASC LIMIT ?`,
 		appID, since, limit,
 	)
@@ -1449,16 +1884,33 @@ func (s *Storage) ListAppMetrics(appID string, since time.Time, limit int) ([]ap
 		return nil, err
 	}
 	defer rows.Close()
+	return scanAppMetrics(rows)
+}
+
+// ListMetricsSince retrieves every app's metrics newer than since, oldest first
+func (s *Storage) ListMetricsSince(since time.Time) ([]app.AppMetric, error) {
+	rows, err := s.db.Query(
+		`SELECT id, app_id, cpu_percent, mem_usage, mem_limit, net_input, net_output, block_input, block_output, requests, errors, recorded_at
+		 FROM app_metrics WHERE recorded_at > ? ORDER BY recorded_at ASC`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAppMetrics(rows)
+}
 
+func scanAppMetrics(rows *sql.Rows) ([]app.AppMetric, error) {
 	var metrics []app.AppMetric
 	for rows.Next() {
 		var m app.AppMetric
-		if err := rows.Scan(&m.ID, &m.AppID, &m.CPUPercent, &m.MemUsage, &m.MemLimit, &m.NetInput, &m.NetOutput, &m.RecordedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.AppID, &m.CPUPercent, &m.MemUsage, &m.MemLimit, &m.NetInput, &m.NetOutput, &m.BlockInput, &m.BlockOutput, &m.Requests, &m.Errors, &m.RecordedAt); err != nil {
 			return nil, err
 		}
 		metrics = append(metrics, m)
 	}
-	return metrics, nil
+	return metrics, rows.Err()
 }
 
 // CleanOldMetrics removes metrics older than the specified duration
@@ -1467,6 +1919,125 @@ func (s *Storage) CleanOldMetrics(before time.Time) error {
 	return err
 }
 
+// --- Uptime Checks ---
+
+// SaveUptimeCheck stores a probe of an app's public URL
+func (s *Storage) SaveUptimeCheck(c *app.UptimeCheck) error {
+	res, err := s.db.Exec(
+		`INSERT INTO uptime_checks (app_id, url, up, status_code, response_ms, error, checked_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.AppID, c.URL, c.Up, c.StatusCode, c.ResponseMs, c.Error, c.CheckedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save uptime check: %w", err)
+	}
+	c.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// ListUptimeChecks returns an app's uptime checks newer than since, oldest first
+func (s *Storage) ListUptimeChecks(appID string, since time.Time) ([]app.UptimeCheck, error) {
+	rows, err := s.db.Query(
+		`SELECT id, app_id, url, up, status_code, response_ms, error, checked_at
+		 FROM uptime_checks WHERE app_id = ? AND checked_at > ? ORDER BY checked_at ASC`,
+		appID, since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uptime checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []app.UptimeCheck
+	for rows.Next() {
+		var c app.UptimeCheck
+		if err := rows.Scan(&c.ID, &c.AppID, &c.URL, &c.Up, &c.StatusCode, &c.ResponseMs, &c.Error, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// UptimeCounts is how many of an app's uptime checks were up
+type UptimeCounts struct {
+	Checks        int
+	Up            int
+	AvgResponseMs float64 // Of the checks that were up
+}
+
+// CountUptimeChecks tallies every app's uptime checks newer than since, by app ID
+func (s *Storage) CountUptimeChecks(since time.Time) (map[string]UptimeCounts, error) {
+	rows, err := s.db.Query(
+		`SELECT app_id, COUNT(*), COALESCE(SUM(up), 0), COALESCE(AVG(CASE WHEN up = 1 THEN response_ms END), 0)
+		 FROM uptime_checks WHERE checked_at > ? GROUP BY app_id`,
+		since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count uptime checks: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]UptimeCounts{}
+	for rows.Next() {
+		var appID string
+		var c UptimeCounts
+		if err := rows.Scan(&appID, &c.Checks, &c.Up, &c.AvgResponseMs); err != nil {
+			return nil, err
+		}
+		counts[appID] = c
+	}
+	return counts, rows.Err()
+}
+
+// CleanOldUptimeChecks removes uptime checks older than before
+func (s *Storage) CleanOldUptimeChecks(before time.Time) error {
+	_, err := s.db.Exec("DELETE FROM uptime_checks WHERE checked_at < ?", before)
+	return err
+}
+
+// SaveDeployMarker stores a deploy marker
+func (s *Storage) SaveDeployMarker(m *app.DeployMarker) error {
+	_, err := s.db.Exec(`
+		INSERT INTO deploy_markers (id, app_id, app_name, release_id, kind, image, commit_hash, commit_msg, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, m.ID, m.AppID, m.AppName, m.ReleaseID, m.Kind, m.Image, m.CommitHash, m.CommitMsg, m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save deploy marker: %w", err)
+	}
+	return nil
+}
+
+// ListDeployMarkers returns deploy markers in a time range, oldest first (empty appID = all apps)
+func (s *Storage) ListDeployMarkers(appID string, since, until time.Time) ([]app.DeployMarker, error) {
+	query := `SELECT id, app_id, app_name, release_id, kind, COALESCE(image,''), COALESCE(commit_hash,''), COALESCE(commit_msg,''), created_at
+		FROM deploy_markers WHERE created_at >= ?`
+	args := []interface{}{since}
+	if !until.IsZero() {
+		query += ` AND created_at <= ?`
+		args = append(args, until)
+	}
+	if appID != "" {
+		query += ` AND app_id = ?`
+		args = append(args, appID)
+	}
+	query += ` ORDER BY created_at ASC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deploy markers: %w", err)
+	}
+	defer rows.Close()
+
+	var markers []app.DeployMarker
+	for rows.Next() {
+		var m app.DeployMarker
+		if err := rows.Scan(&m.ID, &m.AppID, &m.AppName, &m.ReleaseID, &m.Kind, &m.Image, &m.CommitHash, &m.CommitMsg, &m.CreatedAt); err != nil {
+			continue
+		}
+		markers = append(markers, m)
+	}
+	return markers, nil
+}
+
 // ListWebhookDeliveries retrieves recent webhook deliveries for an app
 func (s *Storage) ListWebhookDeliveries(appID string, limit int) ([]app.WebhookDelivery, error) {
 	if limit <= 0 {
@@ -1500,3 +2071,230 @@ func (s *Storage) ListWebhookDeliveries(appID string, limit int) ([]app.WebhookD
 	}
 	return deliveries, nil
 }
+
+// SaveAIDocument stores an embedded document in the search index
+func (s *Storage) SaveAIDocument(d *app.AIDocument) error {
+	embeddingJSON, _ := json.Marshal(d.Embedding)
+	_, err := s.db.Exec(`
+		INSERT INTO ai_documents (id, app_id, source, title, content, embedding, timestamp, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.ID, d.AppID, d.Source, d.Title, d.Content, string(embeddingJSON), d.Timestamp, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save ai document: %w", err)
+	}
+	return nil
+}
+
+// ListAIDocuments returns indexed documents, optionally filtered by app and/or time range
+func (s *Storage) ListAIDocuments(appID string, since, until time.Time) ([]app.AIDocument, error) {
+	query := `SELECT id, COALESCE(app_id,''), source, COALESCE(title,''), content, embedding, timestamp, created_at FROM ai_documents WHERE 1=1`
+	var args []interface{}
+	if appID != "" {
+		query += ` AND app_id = ?`
+		args = append(args, appID)
+	}
+	if !since.IsZero() {
+		query += ` AND timestamp >= ?`
+		args = append(args, since)
+	}
+	if !until.IsZero() {
+		query += ` AND timestamp <= ?`
+		args = append(args, until)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ai documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []app.AIDocument
+	for rows.Next() {
+		var d app.AIDocument
+		var embeddingJSON string
+		var ts sql.NullTime
+		if err := rows.Scan(&d.ID, &d.AppID, &d.Source, &d.Title, &d.Content, &embeddingJSON, &ts, &d.CreatedAt); err != nil {
+			continue
+		}
+		if ts.Valid {
+			d.Timestamp = ts.Time
+		}
+		json.Unmarshal([]byte(embeddingJSON), &d.Embedding)
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+// GetAIDocument returns an indexed document by ID, or nil if there is none
+func (s *Storage) GetAIDocument(id string) (*app.AIDocument, error) {
+	var d app.AIDocument
+	var embeddingJSON string
+	var ts sql.NullTime
+	err := s.db.QueryRow(`SELECT id, COALESCE(app_id,''), source, COALESCE(title,''), content, embedding, timestamp, created_at FROM ai_documents WHERE id = ?`, id).
+		Scan(&d.ID, &d.AppID, &d.Source, &d.Title, &d.Content, &embeddingJSON, &ts, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ai document: %w", err)
+	}
+	if ts.Valid {
+		d.Timestamp = ts.Time
+	}
+	json.Unmarshal([]byte(embeddingJSON), &d.Embedding)
+	return &d, nil
+}
+
+// DeleteAIDocument removes a single indexed document
+func (s *Storage) DeleteAIDocument(id string) error {
+	_, err := s.db.Exec("DELETE FROM ai_documents WHERE id = ?", id)
+	return err
+}
+
+// CleanOldAIDocuments removes indexed log chunks older than the given time (notes are kept)
+func (s *Storage) CleanOldAIDocuments(before time.Time) error {
+	_, err := s.db.Exec("DELETE FROM ai_documents WHERE source = 'log' AND created_at < ?", before)
+	return err
+}
+
+// --- Domain Redirects ---
+
+// SaveDomainRedirect creates a domain redirect, replacing any existing one for the same old domain
+func (s *Storage) SaveDomainRedirect(d *app.DomainRedirect) error {
+	_, err := s.db.Exec(`
+		INSERT INTO domain_redirects (id, app_id, from_domain, to_domain, hits, created_at)
+		VALUES (?, ?, ?, ?, 0, ?)
+		ON CONFLICT(from_domain) DO UPDATE SET id = excluded.id, app_id = excluded.app_id,
+			to_domain = excluded.to_domain, hits = 0, last_hit_at = NULL, created_at = excluded.created_at
+	`, d.ID, d.AppID, d.FromDomain, d.ToDomain, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save domain redirect: %w", err)
+	}
+	return nil
+}
+
+// GetDomainRedirect returns the redirect for an old domain, or nil if there is none
+func (s *Storage) GetDomainRedirect(fromDomain string) (*app.DomainRedirect, error) {
+	redirects, err := s.queryDomainRedirects("WHERE from_domain = ?", fromDomain)
+	if err != nil || len(redirects) == 0 {
+		return nil, err
+	}
+	return &redirects[0], nil
+}
+
+// ListDomainRedirects lists domain redirects (empty appID = all apps)
+func (s *Storage) ListDomainRedirects(appID string) ([]app.DomainRedirect, error) {
+	if appID == "" {
+		return s.queryDomainRedirects("")
+	}
+	return s.queryDomainRedirects("WHERE app_id = ?", appID)
+}
+
+func (s *Storage) queryDomainRedirects(where string, args ...interface{}) ([]app.DomainRedirect, error) {
+	rows, err := s.db.Query(`SELECT id, app_id, from_domain, to_domain, hits, last_hit_at, created_at
+		FROM domain_redirects `+where+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domain redirects: %w", err)
+	}
+	defer rows.Close()
+
+	var redirects []app.DomainRedirect
+	for rows.Next() {
+		var d app.DomainRedirect
+		var lastHit sql.NullTime
+		if err := rows.Scan(&d.ID, &d.AppID, &d.FromDomain, &d.ToDomain, &d.Hits, &lastHit, &d.CreatedAt); err != nil {
+			continue
+		}
+		if lastHit.Valid {
+			d.LastHitAt = &lastHit.Time
+		}
+		redirects = append(redirects, d)
+	}
+	return redirects, nil
+}
+
+// SaveDomainRoute creates a domain route rule
+func (s *Storage) SaveDomainRoute(r *app.DomainRoute) error {
+	_, err := s.db.Exec(`
+		INSERT INTO domain_routes (id, domain, path_prefix, header_name, header_value, app_id, strip_prefix, priority, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.ID, r.Domain, r.PathPrefix, r.HeaderName, r.HeaderValue, r.AppID, r.StripPrefix, r.Priority, r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save domain route: %w", err)
+	}
+	return nil
+}
+
+// ListDomainRoutes lists the route rules for a domain (empty domain = all domains)
+func (s *Storage) ListDomainRoutes(domain string) ([]app.DomainRoute, error) {
+	if domain == "" {
+		return s.queryDomainRoutes("")
+	}
+	return s.queryDomainRoutes("WHERE domain = ?", domain)
+}
+
+// ListDomainRoutesForApp lists the route rules that point at an app
+func (s *Storage) ListDomainRoutesForApp(appID string) ([]app.DomainRoute, error) {
+	return s.queryDomainRoutes("WHERE app_id = ?", appID)
+}
+
+func (s *Storage) queryDomainRoutes(where string, args ...interface{}) ([]app.DomainRoute, error) {
+	rows, err := s.db.Query(`SELECT id, domain, path_prefix, COALESCE(header_name,''), COALESCE(header_value,''), app_id, strip_prefix, priority, created_at
+		FROM domain_routes `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domain routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []app.DomainRoute
+	for rows.Next() {
+		var r app.DomainRoute
+		if err := rows.Scan(&r.ID, &r.Domain, &r.PathPrefix, &r.HeaderName, &r.HeaderValue, &r.AppID, &r.StripPrefix, &r.Priority, &r.CreatedAt); err != nil {
+			continue
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// DeleteDomainRoute removes a domain route rule
+func (s *Storage) DeleteDomainRoute(id string) error {
+	_, err := s.db.Exec("DELETE FROM domain_routes WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain route: %w", err)
+	}
+	return nil
+}
+
+// DeleteDomainRoutesForApp removes every route rule that points at an app
+func (s *Storage) DeleteDomainRoutesForApp(appID string) error {
+	_, err := s.db.Exec("DELETE FROM domain_routes WHERE app_id = ?", appID)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain routes: %w", err)
+	}
+	return nil
+}
+
+// RetargetDomainRedirects points an app's redirects to oldTo at newTo instead
+func (s *Storage) RetargetDomainRedirects(appID, oldTo, newTo string) error {
+	_, err := s.db.Exec("UPDATE domain_redirects SET to_domain = ? WHERE app_id = ? AND to_domain = ?", newTo, appID, oldTo)
+	if err != nil {
+		return fmt.Errorf("failed to update domain redirects: %w", err)
+	}
+	return nil
+}
+
+// RecordDomainRedirectHit counts a request that arrived on an old domain
+func (s *Storage) RecordDomainRedirectHit(fromDomain string) error {
+	_, err := s.db.Exec("UPDATE domain_redirects SET hits = hits + 1, last_hit_at = ? WHERE from_domain = ?", time.Now(), fromDomain)
+	return err
+}
+
+// DeleteDomainRedirect removes a domain redirect
+func (s *Storage) DeleteDomainRedirect(id string) error {
+	_, err := s.db.Exec("DELETE FROM domain_redirects WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain redirect: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// CreateTaskRun records a task run as it starts
+func (s *Storage) CreateTaskRun(t *app.TaskRun) error {
+	command, _ := json.Marshal(t.Command)
+	_, err := s.db.Exec(`
+		INSERT INTO task_runs (id, app_id, command, started_at, status, exit_code, output, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, t.AppID, string(command), t.StartedAt, t.Status, t.ExitCode, t.Output, t.Error)
+	if err != nil {
+		return fmt.Errorf("failed to create task run: %w", err)
+	}
+	return nil
+}
+
+// UpdateTaskRun records how a task run ended
+func (s *Storage) UpdateTaskRun(t *app.TaskRun) error {
+	_, err := s.db.Exec(`
+		UPDATE task_runs SET ended_at = ?, status = ?, exit_code = ?, output = ?, error = ? WHERE id = ?
+	`, t.EndedAt, t.Status, t.ExitCode, t.Output, t.Error, t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update task run: %w", err)
+	}
+	return nil
+}
+
+// ListTaskRuns lists an app's most recent task runs, newest first
+func (s *Storage) ListTaskRuns(appID string, limit int) ([]app.TaskRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.db.Query(`
+		SELECT id, app_id, command, started_at, ended_at, status, exit_code, output, error
+		FROM task_runs WHERE app_id = ? ORDER BY started_at DESC LIMIT ?
+	`, appID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []app.TaskRun{}
+	for rows.Next() {
+		var t app.TaskRun
+		var command string
+		var endedAt sql.NullTime
+		var output, errMsg sql.NullString
+		if err := rows.Scan(&t.ID, &t.AppID, &command, &t.StartedAt, &endedAt, &t.Status, &t.ExitCode, &output, &errMsg); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(command), &t.Command)
+		if endedAt.Valid {
+			t.EndedAt = &endedAt.Time
+		}
+		t.Output = output.String
+		t.Error = errMsg.String
+		runs = append(runs, t)
+	}
+	return runs, nil
+}
+
+// DeleteTaskRuns removes an app's task runs
+func (s *Storage) DeleteTaskRuns(appID string) error {
+	if _, err := s.db.Exec("DELETE FROM task_runs WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("failed to delete task runs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// SaveTLSScan stores the latest TLS scan of an app's domain, replacing the
+// previous one
+func (s *Storage) SaveTLSScan(appID string, scan *app.TLSScan) error {
+	result, err := json.Marshal(scan)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO tls_scans (app_id, result, scanned_at) VALUES (?, ?, ?)
+		ON CONFLICT(app_id) DO UPDATE SET result = excluded.result, scanned_at = excluded.scanned_at
+	`, appID, string(result), scan.ScannedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save TLS scan: %w", err)
+	}
+	return nil
+}
+
+// GetTLSScan returns the latest TLS scan of an app's domain, or nil if it
+// hasn't been scanned
+func (s *Storage) GetTLSScan(appID string) (*app.TLSScan, error) {
+	var result string
+	err := s.db.QueryRow("SELECT result FROM tls_scans WHERE app_id = ?", appID).Scan(&result)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TLS scan: %w", err)
+	}
+	var scan app.TLSScan
+	if err := json.Unmarshal([]byte(result), &scan); err != nil {
+		return nil, fmt.Errorf("failed to decode TLS scan: %w", err)
+	}
+	return &scan, nil
+}
+
+// DeleteTLSScan removes the TLS scan of an app
+func (s *Storage) DeleteTLSScan(appID string) error {
+	if _, err := s.db.Exec("DELETE FROM tls_scans WHERE app_id = ?", appID); err != nil {
+		return fmt.Errorf("failed to delete TLS scan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/base-go/basepod/internal/app"
+)
+
+// twoFactorSecretScope is the associated data TOTP secrets are sealed with,
+// alongside the account ID, so they can't be swapped with app secrets
+const twoFactorSecretScope = "two_factor"
+
+// GetTwoFactor returns an account's two-factor auth with the secret
+// decrypted, or nil if it has none
+func (s *Storage) GetTwoFactor(accountID string) (*app.TwoFactor, error) {
+	var tf app.TwoFactor
+	var sealed []byte
+	var codesJSON string
+	var enabledAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT account_id, secret, recovery_codes, enabled, last_step, created_at, enabled_at
+		FROM two_factor WHERE account_id = ?
+	`, accountID).Scan(&tf.AccountID, &sealed, &codesJSON, &tf.Enabled, &tf.LastStep, &tf.CreatedAt, &enabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor auth: %w", err)
+	}
+	key, err := s.masterKey()
+	if err != nil {
+		return nil, err
+	}
+	if tf.Secret, err = openSecret(key, twoFactorSecretScope, accountID, sealed); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(codesJSON), &tf.RecoveryCodes)
+	if enabledAt.Valid {
+		tf.EnabledAt = &enabledAt.Time
+	}
+	return &tf, nil
+}
+
+// StartTwoFactor saves a new, not yet enabled TOTP secret for an account,
+// replacing any earlier one
+func (s *Storage) StartTwoFactor(accountID, secret string) error {
+	key, err := s.masterKey()
+	if err != nil {
+		return err
+	}
+	sealed, err := sealSecret(key, twoFactorSecretScope, accountID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO two_factor (account_id, secret, recovery_codes, enabled, last_step, created_at)
+		VALUES (?, ?, '[]', 0, 0, ?)
+		ON CONFLICT(account_id) DO UPDATE SET secret = excluded.secret, recovery_codes = '[]',
+			enabled = 0, last_step = 0, created_at = excluded.created_at, enabled_at = NULL
+	`, accountID, sealed, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save two-factor auth: %w", err)
+	}
+	return nil
+}
+
+// EnableTwoFactor turns on an account's two-factor auth with the hashed
+// recovery codes, recording the step of the code that confirmed it
+func (s *Storage) EnableTwoFactor(accountID string, codeHashes []string, step int64) error {
+	codesJSON, _ := json.Marshal(codeHashes)
+	_, err := s.db.Exec(`UPDATE two_factor SET enabled = 1, recovery_codes = ?, last_step = ?, enabled_at = ? WHERE account_id = ?`,
+		string(codesJSON), step, time.Now(), accountID)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor auth: %w", err)
+	}
+	return nil
+}
+
+// SetRecoveryCodes replaces an account's hashed recovery codes
+func (s *Storage) SetRecoveryCodes(accountID string, codeHashes []string) error {
+	codesJSON, _ := json.Marshal(codeHashes)
+	if _, err := s.db.Exec(`UPDATE two_factor SET recovery_codes = ? WHERE account_id = ?`, string(codesJSON), accountID); err != nil {
+		return fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return nil
+}
+
+// SetTwoFactorLastStep records the TOTP step of the last accepted code
+func (s *Storage) SetTwoFactorLastStep(accountID string, step int64) error {
+	if _, err := s.db.Exec(`UPDATE two_factor SET last_step = ? WHERE account_id = ?`, step, accountID); err != nil {
+		return fmt.Errorf("failed to update two-factor auth: %w", err)
+	}
+	return nil
+}
+
+// DeleteTwoFactor turns off an account's two-factor auth
+func (s *Storage) DeleteTwoFactor(accountID string) error {
+	if _, err := s.db.Exec(`DELETE FROM two_factor WHERE account_id = ?`, accountID); err != nil {
+		return fmt.Errorf("failed to delete two-factor auth: %w", err)
+	}
+	return nil
+}
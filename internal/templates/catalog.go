@@ -0,0 +1,181 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Catalog is the remote template catalog: recommended versions, ratings and
+// migration notes for the built-in templates
+type Catalog struct {
+	Templates []CatalogEntry `json:"templates"`
+	SyncedAt  time.Time      `json:"synced_at,omitempty"`
+}
+
+// CatalogEntry is a template's metadata in the remote catalog
+type CatalogEntry struct {
+	ID                 string          `json:"id"`
+	RecommendedVersion string          `json:"recommended_version,omitempty"`
+	Rating             float64         `json:"rating,omitempty"`  // Average rating, 0-5
+	Ratings            int             `json:"ratings,omitempty"` // Number of ratings
+	Installs           int             `json:"installs,omitempty"`
+	MigrationNotes     []MigrationNote `json:"migration_notes,omitempty"`
+}
+
+// MigrationNote is what to know before upgrading to a version
+type MigrationNote struct {
+	Version string `json:"version"`
+	Text    string `json:"text"`
+}
+
+// ParseCatalog decodes a catalog, dropping entries for unknown templates
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid template catalog: %w", err)
+	}
+	known := c.Templates[:0]
+	for _, e := range c.Templates {
+		if GetTemplate(e.ID) != nil {
+			known = append(known, e)
+		}
+	}
+	c.Templates = known
+	return &c, nil
+}
+
+// FetchCatalog downloads the catalog at url
+func FetchCatalog(ctx context.Context, client *http.Client, url string) (*Catalog, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("template catalog returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCatalog(data)
+	if err != nil {
+		return nil, err
+	}
+	c.SyncedAt = time.Now().UTC()
+	return c, nil
+}
+
+// Entry returns a template's catalog entry, or nil
+func (c *Catalog) Entry(id string) *CatalogEntry {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Templates {
+		if c.Templates[i].ID == id {
+			return &c.Templates[i]
+		}
+	}
+	return nil
+}
+
+// Apply adds the catalog's ratings and recommended versions to templates
+func (c *Catalog) Apply(list []Template) []Template {
+	for i := range list {
+		if e := c.Entry(list[i].ID); e != nil {
+			list[i].Rating = e.Rating
+			list[i].Ratings = e.Ratings
+			list[i].Installs = e.Installs
+		}
+		list[i].RecommendedVersion = c.RecommendedVersion(&list[i])
+	}
+	return list
+}
+
+// RecommendedVersion is the catalog's recommended version of a template,
+// falling back to the built-in default
+func (c *Catalog) RecommendedVersion(t *Template) string {
+	if e := c.Entry(t.ID); e != nil && e.RecommendedVersion != "" {
+		return e.RecommendedVersion
+	}
+	if t.DefaultVersion != "" {
+		return t.DefaultVersion
+	}
+	if len(t.Versions) > 0 {
+		return t.Versions[0]
+	}
+	return ""
+}
+
+// NotesBetween returns the migration notes for versions after from, up to
+// and including to
+func (e *CatalogEntry) NotesBetween(from, to string) []MigrationNote {
+	if e == nil {
+		return nil
+	}
+	var notes []MigrationNote
+	for _, n := range e.MigrationNotes {
+		if VersionNewer(n.Version, from) && !VersionNewer(n.Version, to) {
+			notes = append(notes, n)
+		}
+	}
+	return notes
+}
+
+// VersionNewer reports whether version a is newer than b. Only numeric
+// versions ("17", "8.4", "5.2.1-alpine") compare; tags like "latest" are
+// never newer or older than anything.
+func VersionNewer(a, b string) bool {
+	pa, ok := versionParts(a)
+	if !ok {
+		return false
+	}
+	pb, ok := versionParts(b)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+// versionParts splits the numeric part of a version ("5.2.1-alpine" ->
+// [5 2 1])
+func versionParts(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
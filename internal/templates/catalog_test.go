@@ -0,0 +1,73 @@
+package templates
+
+import "testing"
+
+func TestVersionNewer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"6", "5", true},
+		{"5", "6", false},
+		{"5.10", "5.9", true},
+		{"5.1", "5", true},
+		{"5", "5.0", false},
+		{"17.2-alpine", "17", true},
+		{"v2", "1.9", true},
+		{"latest", "5", false},
+		{"6", "latest", false},
+		{"5", "5", false},
+	}
+	for _, tt := range tests {
+		if got := VersionNewer(tt.a, tt.b); got != tt.want {
+			t.Fatalf("VersionNewer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	t.Parallel()
+
+	c, err := ParseCatalog([]byte(`{"templates": [
+		{"id": "ghost", "recommended_version": "6", "rating": 4.5, "ratings": 12, "migration_notes": [
+			{"version": "5.2", "text": "old"},
+			{"version": "6", "text": "Run the 6.0 migration"},
+			{"version": "7", "text": "future"}
+		]},
+		{"id": "not-a-template", "recommended_version": "1"}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParseCatalog: %v", err)
+	}
+	if len(c.Templates) != 1 || c.Entry("not-a-template") != nil {
+		t.Fatalf("unknown templates were kept: %+v", c.Templates)
+	}
+
+	ghost := GetTemplate("ghost")
+	if got := c.RecommendedVersion(ghost); got != "6" {
+		t.Fatalf("RecommendedVersion(ghost) = %q, want 6", got)
+	}
+	if got := c.RecommendedVersion(GetTemplate("postgres")); got != "17" {
+		t.Fatalf("RecommendedVersion(postgres) = %q, want the built-in default 17", got)
+	}
+	var none *Catalog
+	if got := none.RecommendedVersion(ghost); got != ghost.DefaultVersion {
+		t.Fatalf("nil catalog recommended %q, want %q", got, ghost.DefaultVersion)
+	}
+
+	notes := c.Entry("ghost").NotesBetween("5.2", "6")
+	if len(notes) != 1 || notes[0].Version != "6" {
+		t.Fatalf("NotesBetween(5.2, 6) = %+v", notes)
+	}
+
+	list := c.Apply([]Template{*ghost})
+	if list[0].Rating != 4.5 || list[0].Ratings != 12 || list[0].RecommendedVersion != "6" {
+		t.Fatalf("Apply = %+v", list[0])
+	}
+
+	if _, err := ParseCatalog([]byte("not json")); err == nil {
+		t.Fatal("ParseCatalog accepted invalid JSON")
+	}
+}
@@ -7,6 +7,7 @@ import "runtime"
 type VolumeConfig struct {
 	Name          string `json:"name"`           // e.g., "data"
 	ContainerPath string `json:"container_path"` // e.g., "/var/lib/mysql"
+	Size          string `json:"size,omitempty"` // Suggested size, e.g., "10GB". Usage above it is flagged in app details
 }
 
 // Template represents a predefined app configuration
@@ -25,7 +26,14 @@ type Template struct {
 	Volumes        []VolumeConfig    `json:"volumes,omitempty"` // Persistent volume mounts
 	Category       string            `json:"category"`
 	Icon           string            `json:"icon"`
-	Arch           []string          `json:"arch,omitempty"` // Supported architectures: amd64, arm64. Empty means all
+	Arch           []string          `json:"arch,omitempty"`          // Supported architectures: amd64, arm64. Empty means all
+	ModelBinding   bool              `json:"model_binding,omitempty"` // Inject OPENAI_API_BASE/KEY for the local model gateway at deploy time
+
+	// From the remote catalog, when one is configured
+	RecommendedVersion string  `json:"recommended_version,omitempty"`
+	Rating             float64 `json:"rating,omitempty"`
+	Ratings            int     `json:"ratings,omitempty"`
+	Installs           int     `json:"installs,omitempty"`
 }
 
 // GetArch returns the current system architecture
@@ -138,7 +146,7 @@ var Templates = []Template{
 			"MYSQL_DATABASE":      "app",
 		},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/var/lib/mysql"},
+			{Name: "data", ContainerPath: "/var/lib/mysql", Size: "10GB"},
 		},
 		Category: "database",
 		Icon:     "i-lucide-database",
@@ -157,7 +165,7 @@ var Templates = []Template{
 			"MARIADB_DATABASE":      "app",
 		},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/var/lib/mysql"},
+			{Name: "data", ContainerPath: "/var/lib/mysql", Size: "10GB"},
 		},
 		Category: "database",
 		Icon:     "i-lucide-database",
@@ -176,7 +184,7 @@ var Templates = []Template{
 			"POSTGRES_DB":       "app",
 		},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/var/lib/postgresql/data"},
+			{Name: "data", ContainerPath: "/var/lib/postgresql/data", Size: "10GB"},
 		},
 		Category: "database",
 		Icon:     "i-lucide-database",
@@ -195,7 +203,7 @@ var Templates = []Template{
 			"MONGO_INITDB_ROOT_PASSWORD": "changeme",
 		},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/data/db"},
+			{Name: "data", ContainerPath: "/data/db", Size: "10GB"},
 		},
 		Category: "database",
 		Icon:     "i-lucide-database",
@@ -378,7 +386,7 @@ var Templates = []Template{
 		Port:        8080,
 		Env:         map[string]string{},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/pb_data"},
+			{Name: "data", ContainerPath: "/pb_data", Size: "5GB"},
 		},
 		Category: "cms",
 		Icon:     "i-lucide-pocket",
@@ -441,7 +449,7 @@ var Templates = []Template{
 		Port:           5678,
 		Env:            map[string]string{},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/home/node/.n8n"},
+			{Name: "data", ContainerPath: "/home/node/.n8n", Size: "1GB"},
 		},
 		Category: "automation",
 		Icon:     "i-lucide-workflow",
@@ -457,7 +465,7 @@ var Templates = []Template{
 		Port:           3000,
 		Env:            map[string]string{},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/var/lib/grafana"},
+			{Name: "data", ContainerPath: "/var/lib/grafana", Size: "1GB"},
 		},
 		Category: "analytics",
 		Icon:     "i-lucide-bar-chart",
@@ -560,7 +568,7 @@ var Templates = []Template{
 		Port:        11434,
 		Env:         map[string]string{},
 		Volumes: []VolumeConfig{
-			{Name: "models", ContainerPath: "/root/.ollama"},
+			{Name: "models", ContainerPath: "/root/.ollama", Size: "50GB"},
 		},
 		Category: "ai",
 		Icon:     "i-lucide-brain",
@@ -582,6 +590,40 @@ var Templates = []Template{
 		Category: "ai",
 		Icon:     "i-lucide-workflow",
 	},
+	{
+		ID:          "openwebui",
+		Name:        "Open WebUI",
+		Description: "Chat UI bound to the local model gateway",
+		Image:       "ghcr.io/open-webui/open-webui",
+		Versions:    []string{"main"},
+		Port:        8080,
+		Env: map[string]string{
+			"OPENAI_API_BASE_URL": "${OPENAI_API_BASE}",
+			"ENABLE_OLLAMA_API":   "false",
+			"WEBUI_SECRET_KEY":    "changeme",
+		},
+		Volumes: []VolumeConfig{
+			{Name: "data", ContainerPath: "/app/backend/data"},
+		},
+		Category:     "ai",
+		Icon:         "i-lucide-message-square",
+		ModelBinding: true,
+	},
+	{
+		ID:          "lobechat",
+		Name:        "LobeChat",
+		Description: "Lightweight chat UI bound to the local model gateway",
+		Image:       "lobehub/lobe-chat",
+		Versions:    []string{"latest"},
+		Port:        3210,
+		Env: map[string]string{
+			"OPENAI_PROXY_URL": "${OPENAI_API_BASE}",
+			"ACCESS_CODE":      "changeme",
+		},
+		Category:     "ai",
+		Icon:         "i-lucide-messages-square",
+		ModelBinding: true,
+	},
 	// Security
 	{
 		ID:             "vaultwarden",
@@ -613,7 +655,7 @@ var Templates = []Template{
 		Volumes: []VolumeConfig{
 			{Name: "config", ContainerPath: "/config"},
 			{Name: "cache", ContainerPath: "/cache"},
-			{Name: "media", ContainerPath: "/media"},
+			{Name: "media", ContainerPath: "/media", Size: "100GB"},
 		},
 		Category: "media",
 		Icon:     "i-lucide-play-circle",
@@ -631,7 +673,7 @@ var Templates = []Template{
 			"MEILI_MASTER_KEY": "changeme",
 		},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/meili_data"},
+			{Name: "data", ContainerPath: "/meili_data", Size: "5GB"},
 		},
 		Category: "search",
 		Icon:     "i-lucide-search",
@@ -650,7 +692,7 @@ var Templates = []Template{
 			"RABBITMQ_DEFAULT_PASS": "changeme",
 		},
 		Volumes: []VolumeConfig{
-			{Name: "data", ContainerPath: "/var/lib/rabbitmq"},
+			{Name: "data", ContainerPath: "/var/lib/rabbitmq", Size: "2GB"},
 		},
 		Category: "messaging",
 		Icon:     "i-lucide-mail",
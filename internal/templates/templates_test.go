@@ -11,7 +11,6 @@ func TestCatalogExcludesTemplatesThatDoNotFitBasepod(t *testing.T) {
 		"chatwoot",
 		"immich",
 		"invoiceninja",
-		"openwebui",
 		"plausible",
 		"portainer",
 		"rocketchat",
@@ -27,6 +26,19 @@ func TestCatalogExcludesTemplatesThatDoNotFitBasepod(t *testing.T) {
 	}
 }
 
+// Open WebUI was once left out of the catalog above; it's back as a chat UI
+// bound to the local model gateway, which serves the API it needs
+func TestChatUITemplatesAreModelBound(t *testing.T) {
+	t.Parallel()
+
+	for _, id := range []string{"openwebui", "lobechat"} {
+		tmpl := GetTemplate(id)
+		if tmpl == nil || !tmpl.ModelBinding {
+			t.Fatalf("template %q = %+v, want a model-bound template", id, tmpl)
+		}
+	}
+}
+
 func TestPostgresTemplateUsesVersionCompatibleDataPath(t *testing.T) {
 	t.Parallel()
 